@@ -0,0 +1,193 @@
+// Command loadgen drives a configurable load against a running instance of the API and reports the latency percentiles
+// and the error rate of every path, so the read path can be compared across configurations (e.g. with and without a cache).
+//
+// It logs in once, then every worker requests the paths in turn with the access token until the duration elapses:
+//
+//	go run ./cmd/loadgen -url https://localhost:1000 -username admin -password P@ssw0rd -concurrency 50 -duration 30s
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// defaultPaths are the read endpoints requested when no path is given
+const defaultPaths = "/api/v1/consumers?page=1&limit=10,/api/v1/users/me/login-history"
+
+// result is the outcome of one request.
+type result struct {
+	path    string
+	latency time.Duration
+	failed  bool
+}
+
+func main() {
+	baseURL := flag.String("url", "https://localhost:1000", "base URL of the running instance")
+	username := flag.String("username", "", "username used to log in")
+	password := flag.String("password", "", "password used to log in")
+	tenantID := flag.Int64("tenant", 0, "tenant to log in to, the default tenant when 0")
+	paths := flag.String("paths", defaultPaths, "comma-separated paths requested in turn by every worker")
+	concurrency := flag.Int("concurrency", 10, "number of concurrent workers")
+	duration := flag.Duration("duration", 30*time.Second, "duration of the load")
+	insecure := flag.Bool("insecure", true, "skip the verification of the TLS certificate (e.g. self-signed certificates)")
+	flag.Parse()
+
+	if *username == "" || *password == "" || *concurrency < 1 || *duration <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig:     &tls.Config{InsecureSkipVerify: *insecure},
+			MaxIdleConnsPerHost: *concurrency,
+		},
+	}
+
+	// Log in once, every worker shares the access token
+	token, err := login(client, strings.TrimRight(*baseURL, "/"), *username, *password, *tenantID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to log in: %v\n", err)
+		os.Exit(1)
+	}
+
+	targets := strings.Split(*paths, ",")
+	results := make(chan result, *concurrency*16)
+	deadline := time.Now().Add(*duration)
+
+	// Every worker requests the paths in turn until the deadline
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			for i := offset; time.Now().Before(deadline); i++ {
+				path := strings.TrimSpace(targets[i%len(targets)])
+				results <- request(client, strings.TrimRight(*baseURL, "/")+path, path, token)
+			}
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Collect the latencies per path, and for all the paths together
+	latencies := make(map[string][]time.Duration)
+	failures := make(map[string]int)
+	for r := range results {
+		for _, key := range []string{r.path, "TOTAL"} {
+			latencies[key] = append(latencies[key], r.latency)
+			if r.failed {
+				failures[key]++
+			}
+		}
+	}
+
+	report(os.Stdout, latencies, failures, *duration)
+}
+
+// login authenticates the user and returns the access token.
+func login(client *http.Client, baseURL, username, password string, tenantID int64) (string, error) {
+	req := entity.LoginRequest{Username: username, Password: password}
+	if tenantID > 0 {
+		req.TenantID = &tenantID
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Post(baseURL+"/auth/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var payload struct {
+		Data entity.LoginResponse `json:"data"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", err
+	}
+	if payload.Data.AccessToken == "" {
+		return "", fmt.Errorf("no access token in the response")
+	}
+
+	return payload.Data.AccessToken, nil
+}
+
+// request performs one authenticated GET request, a transport error or a non-2xx status counts as a failure.
+func request(client *http.Client, url, path, token string) result {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return result{path: path, failed: true}
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return result{path: path, latency: time.Since(start), failed: true}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return result{path: path, latency: time.Since(start), failed: resp.StatusCode < 200 || resp.StatusCode > 299}
+}
+
+// report writes the number of requests, the throughput, the latency percentiles and the error rate of every path.
+func report(w io.Writer, latencies map[string][]time.Duration, failures map[string]int, duration time.Duration) {
+	keys := make([]string, 0, len(latencies))
+	for key := range latencies {
+		if key != "TOTAL" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	keys = append(keys, "TOTAL")
+
+	fmt.Fprintf(w, "%-50s %10s %10s %10s %10s %10s %8s\n", "PATH", "REQUESTS", "REQ/S", "P50", "P95", "P99", "ERRORS")
+	for _, key := range keys {
+		values := latencies[key]
+		if len(values) == 0 {
+			continue
+		}
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+		fmt.Fprintf(w, "%-50s %10d %10.1f %10s %10s %10s %7.2f%%\n",
+			key,
+			len(values),
+			float64(len(values))/duration.Seconds(),
+			percentile(values, 50).Round(time.Microsecond),
+			percentile(values, 95).Round(time.Microsecond),
+			percentile(values, 99).Round(time.Microsecond),
+			float64(failures[key])*100/float64(len(values)),
+		)
+	}
+}
+
+// percentile returns the p-th percentile of the sorted latencies, using the nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -2,17 +2,27 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/yoanesber/go-consumer-api-with-jwt/config"
 	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/server"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
 	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/diagnostics"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/hash"
 	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/secret"
 	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
 	"github.com/yoanesber/go-consumer-api-with-jwt/routes"
 )
@@ -20,37 +30,53 @@ import (
 var (
 	validatorInitialized bool
 	dbInitialized        bool
+
+	// skipPreflight starts the server even though the preflight fails, for the emergencies only
+	skipPreflight = flag.Bool("skip-preflight", false, "start without running the startup preflight")
 )
 
 func init() {
 	logger.Init()
 }
 
+// @title        Consumer API with JWT
+// @version      1.0
+// @description  Consumer management API secured with JWT authentication and role-based access control.
+// @BasePath     /api/v1
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
 func main() {
+	flag.Parse()
+
 	// Create base context with cancel for graceful shutdown
 	_, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Get environment variables
-	env := os.Getenv("ENV")
-	port := os.Getenv("PORT")
-	isSSL := os.Getenv("IS_SSL")
-	apiVersion := os.Getenv("API_VERSION")
-	sslKeys := os.Getenv("SSL_KEYS")
-	sslCert := os.Getenv("SSL_CERT")
-
-	if env == "" || port == "" || isSSL == "" || apiVersion == "" || sslKeys == "" || sslCert == "" {
-		logger.Panic("One or more required environment variables are not set", log.Fields{
-			"ENV":         env,
-			"PORT":        port,
-			"IS_SSL":      isSSL,
-			"API_VERSION": apiVersion,
-			"SSL_KEYS":    sslKeys,
-			"SSL_CERT":    sslCert,
-		})
+	// Apply the optional configuration file over the environment, its reloadable settings are reloaded on SIGHUP
+	reloader, err := config.NewReloader(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		logger.Panic(err.Error(), nil)
+		return
+	}
+
+	// Source the secrets from the configured provider, over the environment, and watch their rotation
+	secrets, err := newSecretRefresher(context.Background())
+	if err != nil {
+		logger.Panic(err.Error(), nil)
 		return
 	}
 
+	// Load and validate the configuration, fail fast listing every missing or invalid setting
+	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		logger.Panic(err.Error(), nil)
+		return
+	}
+	env := cfg.Env
+	apiVersion := cfg.APIVersion
+	serverCfg := cfg.Server
+
 	// Set Gin mode
 	gin.SetMode(gin.DebugMode)
 	if env == "PRODUCTION" {
@@ -67,33 +93,101 @@ func main() {
 	// Init all dependencies
 	initializeDependencies()
 
+	// Check the database, its schema, the keys and the seed data before serving, so a misconfiguration fails the deployment
+	runPreflight()
+
 	// Log memory stats after initialization
 	diagnostics.LogMemoryStats("After initialization")
 
+	// Apply the rotated secrets in the background, once the services using them are initialized
+	secrets.Start()
+
+	// Create the HTTP server, with TLS and HTTP/2 when SSL is enabled
+	srv, err := server.NewServer(serverCfg, r)
+	if err != nil {
+		logger.Fatal(fmt.Sprintf("Failed to create server: %v", err), nil)
+		return
+	}
+
+	// Reload the certificate files on SIGHUP without dropping connections
+	if reloader := srv.CertReloader(); reloader != nil {
+		reloader.WatchSIGHUP(func(err error) {
+			if err != nil {
+				logger.Error(fmt.Sprintf("Failed to reload TLS certificate: %v", err), nil)
+				return
+			}
+			logger.Info("TLS certificate reloaded", nil)
+		})
+	}
+
+	// Reload the log level, the feature flags and the CORS origins on SIGHUP without a restart
+	reloader.WatchSIGHUP(logConfigReload)
+
 	// Graceful shutdown
-	gracefulShutdown(cancel)
+	shutdownDone := gracefulShutdown(cancel, srv, secrets)
 
 	// Start the server
-	var err error
-	if isSSL == "TRUE" {
-		//Generated using sh generate-certificate.sh
-		err = r.RunTLS(":"+port, sslCert, sslKeys)
-
-	} else {
-		err = r.Run(":" + port)
+	//Certificates generated using sh generate-certificate.sh
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Error(fmt.Sprintf("Failed to start server: %v", err), log.Fields{
+			"environment":    env,
+			"port":           serverCfg.Port,
+			"is_ssl":         serverCfg.IsSSL,
+			"api_version":    apiVersion,
+			"ssl_cert":       serverCfg.CertFile,
+			"ssl_keys":       serverCfg.KeyFile,
+			"autocert_cache": serverCfg.AutocertCacheDir,
+		})
+		return
 	}
 
+	// The server is closed by the shutdown, wait for its cleanup to finish before exiting
+	<-shutdownDone
+	logger.Exit()
+}
+
+// logConfigReload logs the result of a reload of the configuration file, the settings only read at startup are warned about.
+func logConfigReload(changes []config.ConfigChange, err error) {
 	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to start server with SSL: %v", err), log.Fields{
-			"environment": env,
-			"port":        port,
-			"is_ssl":      isSSL,
-			"api_version": apiVersion,
-			"ssl_cert":    sslCert,
-			"ssl_keys":    sslKeys,
-		})
+		logger.Error(fmt.Sprintf("Failed to reload the configuration, nothing was applied: %v", err), nil)
 		return
 	}
+
+	var applied []string
+	for _, change := range changes {
+		if !change.Applied {
+			logger.Warn(fmt.Sprintf("%s changed but is only read at startup, it is applied at the next restart", change.Key), nil)
+			continue
+		}
+		applied = append(applied, change.String())
+	}
+	logger.Info("Configuration reloaded", log.Fields{"changed": applied})
+}
+
+// newSecretRefresher creates the provider selected with SECRET_PROVIDER and loads the JWT secret and the database credentials from it.
+// The rotated values are applied to the signer and the verifier of the tokens, and to the next database connections.
+func newSecretRefresher(ctx context.Context) (*secret.Refresher, error) {
+	provider, err := secret.New(secret.GetProviderName())
+	if err != nil {
+		return nil, err
+	}
+
+	// The key ID and the previous keys are read when the keys are loaded again, they are refreshed before the secret
+	// so a secret rotated along with them keeps the issued tokens valid
+	refresher := secret.NewRefresher(provider, secret.GetRefreshInterval())
+	refresher.Watch("JWT_KEY_ID", nil)
+	refresher.Watch("JWT_PREVIOUS_KEYS", nil)
+	refresher.Watch("JWT_SECRET", func(value string) {
+		service.SetJWTSecret(value)
+		authorization.SetJWTSecret(value)
+	})
+	refresher.Watch("DB_USER", database.SetDBUser)
+	refresher.Watch("DB_PASS", database.SetDBPass)
+
+	if err := refresher.Load(ctx); err != nil {
+		return nil, err
+	}
+	return refresher, nil
 }
 
 func initializeDependencies() {
@@ -112,20 +206,85 @@ func initializeDependencies() {
 			dbInitialized = true
 		}
 	}
+
+	// The configured default role of the new users must exist
+	if err := service.ValidateDefaultUserRole(); err != nil {
+		logger.Fatal(fmt.Sprintf("Invalid default user role: %v", err), nil)
+	}
+
+	// Record the login attempts in the background
+	service.StartLoginAttemptRecorder()
+
+	// Remind and delete the deactivated accounts in the background
+	service.StartAccountJanitor(clock.New())
+}
+
+// runPreflight runs the startup preflight and exits listing the checks when one of them did not pass,
+// then benchmarks the password hashing, see hash.LatencyGuard.
+// It is skipped with --skip-preflight, the report stays available at GET /admin/selfcheck.
+func runPreflight() {
+	if *skipPreflight {
+		logger.Warn("The startup preflight is skipped (--skip-preflight), check GET /admin/selfcheck once started", nil)
+		return
+	}
+
+	report := service.NewPreflightService(clock.New()).Run(context.Background())
+	if !report.Passed {
+		fmt.Fprintln(os.Stderr, report.String())
+		logger.Fatal("Startup preflight failed, start with --skip-preflight to bypass it", nil)
+		return
+	}
+
+	logger.Info(report.String(), nil)
+
+	// A hash too fast is cheap to brute force, a hash too slow slows down the logins
+	guard := hash.LoadLatencyGuard(clock.New())
+	latency, err := guard.Check(hash.FromEnv(), func(message string) {
+		logger.Warn(message, nil)
+	})
+	if err != nil {
+		logger.Fatal(fmt.Sprintf("Startup preflight failed, start with --skip-preflight to bypass it: %v", err), nil)
+		return
+	}
+	logger.Info(fmt.Sprintf("Password hashing takes %s", latency), nil)
 }
 
-func gracefulShutdown(cancel context.CancelFunc) {
+// gracefulShutdown shuts the server down and releases the dependencies on SIGINT or SIGTERM.
+// The returned channel is closed once the cleanup is complete, the server stops serving before that.
+func gracefulShutdown(cancel context.CancelFunc, srv *server.Server, secrets *secret.Refresher) <-chan struct{} {
 	// Handle graceful shutdown signals
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
+
 		sig := <-quit
 		logger.Info(fmt.Sprintf("Received signal: %s. Initiating graceful shutdown...", sig), nil)
 
 		// Cancel context
 		cancel()
 
+		// Stop accepting new connections and wait for in-flight requests
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer shutdownCancel()
+		if err := srv.HTTPServer().Shutdown(shutdownCtx); err != nil {
+			logger.Error(fmt.Sprintf("Failed to shut down server: %v", err), nil)
+		}
+		if redirect := srv.RedirectServer(); redirect != nil {
+			redirect.Shutdown(shutdownCtx)
+		}
+
+		logger.Info("Stopping secret refresh...", nil)
+		secrets.Stop()
+
+		logger.Info("Stopping account janitor...", nil)
+		service.StopAccountJanitor()
+
+		logger.Info("Flushing login attempts...", nil)
+		service.StopLoginAttemptRecorder()
+
 		if dbInitialized {
 			logger.Info("Closing Postgres connection...", nil)
 			database.ClosePostgres()
@@ -138,7 +297,7 @@ func gracefulShutdown(cancel context.CancelFunc) {
 		diagnostics.LogMemoryStats("After shutdown cleanup")
 
 		logger.Info("Shutdown complete. Bye 👋", nil)
-		logger.Exit()
-		os.Exit(0)
 	}()
+
+	return done
 }
@@ -0,0 +1,286 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/server"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+	fieldutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/field-util"
+	jwtutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/jwt-util"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+const (
+	// minJWTSecretLength is the minimum length of the HS256 secret (256 bits)
+	minJWTSecretLength = 32
+)
+
+// Config holds the whole application configuration loaded from environment variables.
+// It is loaded and validated once at startup, so a missing or invalid setting fails fast
+// instead of failing at the first request that needs it.
+type Config struct {
+	Env         string
+	APIVersion  string
+	APIBasePath string
+	LogLevel    string
+	FieldNaming string
+	DefaultRole string
+	Server      server.ServerConfig
+	Database    DatabaseConfig
+	JWT         JWTConfig
+	Session     SessionConfig
+}
+
+// DatabaseConfig holds the database connection settings.
+// Only the name of the database is used with the SQLite dialect, as the path of its file.
+type DatabaseConfig struct {
+	Dialect  string
+	Host     string
+	Port     string
+	User     string
+	Pass     string
+	Name     string
+	Schema   string
+	SSLMode  string
+	TimeZone string
+}
+
+// JWTConfig holds the settings used to sign and validate the tokens.
+type JWTConfig struct {
+	Algorithm                  string
+	Secret                     string
+	PrivateKeyPath             string
+	PublicKeyPath              string
+	PreviousKeys               string
+	TokenType                  string
+	Issuer                     string
+	Audience                   string
+	ExpirationHour             string
+	RefreshTokenExpirationHour string
+	AccessTokenTTLMinutes      string
+}
+
+// SessionConfig holds the limit of active sessions per user and the retention of the login history.
+type SessionConfig struct {
+	MaxSessionsPerUser        string
+	LimitPolicy               string
+	LoginHistoryRetentionDays string
+}
+
+// Load loads the configuration from environment variables.
+// It does not validate the values, call Validate for that.
+func Load() Config {
+	return Config{
+		Env:         os.Getenv("ENV"),
+		APIVersion:  os.Getenv("API_VERSION"),
+		APIBasePath: os.Getenv("API_BASE_PATH"),
+		LogLevel:    os.Getenv("LOG_LEVEL"),
+		FieldNaming: os.Getenv("JSON_FIELD_NAMING"),
+		DefaultRole: os.Getenv("DEFAULT_USER_ROLE"),
+		Server:      server.LoadServerConfig(),
+		Database: DatabaseConfig{
+			Dialect:  os.Getenv("DB_DIALECT"),
+			Host:     os.Getenv("DB_HOST"),
+			Port:     os.Getenv("DB_PORT"),
+			User:     os.Getenv("DB_USER"),
+			Pass:     os.Getenv("DB_PASS"),
+			Name:     os.Getenv("DB_NAME"),
+			Schema:   os.Getenv("DB_SCHEMA"),
+			SSLMode:  os.Getenv("DB_SSL_MODE"),
+			TimeZone: os.Getenv("DB_TIMEZONE"),
+		},
+		JWT: JWTConfig{
+			Algorithm:                  os.Getenv("JWT_ALGORITHM"),
+			Secret:                     os.Getenv("JWT_SECRET"),
+			PrivateKeyPath:             os.Getenv("JWT_PRIVATE_KEY_PATH"),
+			PublicKeyPath:              os.Getenv("JWT_PUBLIC_KEY_PATH"),
+			PreviousKeys:               os.Getenv("JWT_PREVIOUS_KEYS"),
+			TokenType:                  os.Getenv("TOKEN_TYPE"),
+			Issuer:                     os.Getenv("JWT_ISSUER"),
+			Audience:                   os.Getenv("JWT_AUDIENCE"),
+			ExpirationHour:             os.Getenv("JWT_EXPIRATION_HOUR"),
+			RefreshTokenExpirationHour: os.Getenv("JWT_REFRESH_TOKEN_EXPIRATION_HOUR"),
+			AccessTokenTTLMinutes:      os.Getenv("ACCESS_TOKEN_TTL_MINUTES"),
+		},
+		Session: SessionConfig{
+			MaxSessionsPerUser:        os.Getenv("MAX_SESSIONS_PER_USER"),
+			LimitPolicy:               os.Getenv("SESSION_LIMIT_POLICY"),
+			LoginHistoryRetentionDays: os.Getenv("LOGIN_HISTORY_RETENTION_DAYS"),
+		},
+	}
+}
+
+// Validate checks every setting and returns a single error listing all the missing or invalid ones.
+// It returns nil if the configuration is valid.
+func (cfg Config) Validate() error {
+	var errs []error
+
+	errs = appendRequired(errs, "ENV", cfg.Env)
+	errs = appendRequired(errs, "API_VERSION", cfg.APIVersion)
+
+	if strings.ContainsAny(cfg.APIBasePath, " ?#*:") {
+		errs = append(errs, fmt.Errorf("API_BASE_PATH must be a plain URL path (e.g. /api), got %q", cfg.APIBasePath))
+	}
+
+	if _, err := logger.ParseLevel(cfg.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL is invalid: %v", err))
+	}
+
+	if _, err := fieldutil.ParseFieldNaming(cfg.FieldNaming); err != nil {
+		errs = append(errs, fmt.Errorf("JSON_FIELD_NAMING is invalid: %v", err))
+	}
+
+	// The existence of the default role is checked once connected to the database
+	if cfg.DefaultRole != "" && !validation.IsValidRoleName(cfg.DefaultRole) {
+		errs = append(errs, fmt.Errorf("DEFAULT_USER_ROLE must be a role name like ROLE_USER, got %q", cfg.DefaultRole))
+	}
+
+	if err := cfg.Server.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	errs = append(errs, cfg.Database.validate()...)
+	errs = append(errs, cfg.JWT.validate()...)
+	errs = append(errs, cfg.Session.validate()...)
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = "  - " + err.Error()
+	}
+	return fmt.Errorf("invalid configuration (%d errors):\n%s", len(errs), strings.Join(messages, "\n"))
+}
+
+// validate checks the database settings, the required ones depend on the dialect.
+func (cfg DatabaseConfig) validate() []error {
+	var errs []error
+
+	switch strings.ToLower(cfg.Dialect) {
+	case "", database.DialectPostgres:
+	case database.DialectSQLite:
+		// An SQLite database only needs the path of its file
+		return appendRequired(errs, "DB_NAME", cfg.Name)
+	default:
+		return append(errs, fmt.Errorf("DB_DIALECT must be one of %s, %s, got %q", database.DialectPostgres, database.DialectSQLite, cfg.Dialect))
+	}
+
+	errs = appendRequired(errs, "DB_HOST", cfg.Host)
+	errs = appendRequired(errs, "DB_USER", cfg.User)
+	errs = appendRequired(errs, "DB_PASS", cfg.Pass)
+	errs = appendRequired(errs, "DB_NAME", cfg.Name)
+	errs = appendRequired(errs, "DB_SCHEMA", cfg.Schema)
+
+	if cfg.Port == "" {
+		errs = append(errs, errors.New("DB_PORT is not set"))
+	} else if port, err := strconv.Atoi(cfg.Port); err != nil || port <= 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("DB_PORT must be a valid port number, got %q", cfg.Port))
+	}
+
+	switch cfg.SSLMode {
+	case "", "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("DB_SSL_MODE must be one of disable, allow, prefer, require, verify-ca, verify-full, got %q", cfg.SSLMode))
+	}
+
+	return errs
+}
+
+// validate checks the JWT settings, the required keys depend on the signing algorithm.
+func (cfg JWTConfig) validate() []error {
+	var errs []error
+
+	switch cfg.Algorithm {
+	case "HS256":
+		if cfg.Secret == "" {
+			errs = append(errs, errors.New("JWT_SECRET is not set (required for HS256)"))
+		} else if len(cfg.Secret) < minJWTSecretLength {
+			errs = append(errs, fmt.Errorf("JWT_SECRET must be at least %d characters long", minJWTSecretLength))
+		}
+	case "RS256":
+		errs = appendFile(errs, "JWT_PRIVATE_KEY_PATH", cfg.PrivateKeyPath)
+		errs = appendFile(errs, "JWT_PUBLIC_KEY_PATH", cfg.PublicKeyPath)
+	case "":
+		errs = append(errs, errors.New("JWT_ALGORITHM is not set"))
+	default:
+		errs = append(errs, fmt.Errorf("JWT_ALGORITHM must be HS256 or RS256, got %q", cfg.Algorithm))
+	}
+
+	// The previous keys of a rotation keep verifying their tokens, they must be readable as the current ones
+	if cfg.PreviousKeys != "" && (cfg.Algorithm == "HS256" || cfg.Algorithm == "RS256") {
+		if _, err := jwtutil.ParsePreviousKeys(cfg.Algorithm, cfg.PreviousKeys); err != nil {
+			errs = append(errs, fmt.Errorf("JWT_PREVIOUS_KEYS is invalid: %v", err))
+		}
+	}
+
+	errs = appendRequired(errs, "TOKEN_TYPE", cfg.TokenType)
+	errs = appendRequired(errs, "JWT_ISSUER", cfg.Issuer)
+	errs = appendRequired(errs, "JWT_AUDIENCE", cfg.Audience)
+	errs = appendPositiveInt(errs, "JWT_EXPIRATION_HOUR", cfg.ExpirationHour, true)
+	errs = appendPositiveInt(errs, "JWT_REFRESH_TOKEN_EXPIRATION_HOUR", cfg.RefreshTokenExpirationHour, false)
+	errs = appendPositiveInt(errs, "ACCESS_TOKEN_TTL_MINUTES", cfg.AccessTokenTTLMinutes, false)
+
+	return errs
+}
+
+// validate checks the session settings, all of them are optional.
+func (cfg SessionConfig) validate() []error {
+	var errs []error
+
+	if cfg.MaxSessionsPerUser != "" {
+		if n, err := strconv.Atoi(cfg.MaxSessionsPerUser); err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("MAX_SESSIONS_PER_USER must be a non-negative integer, got %q", cfg.MaxSessionsPerUser))
+		}
+	}
+
+	switch strings.ToUpper(cfg.LimitPolicy) {
+	case "", "REJECT", "EVICT_OLDEST":
+	default:
+		errs = append(errs, fmt.Errorf("SESSION_LIMIT_POLICY must be REJECT or EVICT_OLDEST, got %q", cfg.LimitPolicy))
+	}
+
+	errs = appendPositiveInt(errs, "LOGIN_HISTORY_RETENTION_DAYS", cfg.LoginHistoryRetentionDays, false)
+
+	return errs
+}
+
+// appendRequired appends an error if the value is empty.
+func appendRequired(errs []error, key string, value string) []error {
+	if strings.TrimSpace(value) == "" {
+		return append(errs, fmt.Errorf("%s is not set", key))
+	}
+	return errs
+}
+
+// appendPositiveInt appends an error if the value is not a positive integer.
+// Optional values are only checked when they are set.
+func appendPositiveInt(errs []error, key string, value string, required bool) []error {
+	if value == "" {
+		if required {
+			return append(errs, fmt.Errorf("%s is not set", key))
+		}
+		return errs
+	}
+	if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+		return append(errs, fmt.Errorf("%s must be a positive integer, got %q", key, value))
+	}
+	return errs
+}
+
+// appendFile appends an error if the path is empty or does not point to a readable file.
+func appendFile(errs []error, key string, path string) []error {
+	if path == "" {
+		return append(errs, fmt.Errorf("%s is not set", key))
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return append(errs, fmt.Errorf("%s does not point to a readable file: %s", key, path))
+	}
+	return errs
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+/**
+* The audit callbacks fill the CreatedBy and UpdatedBy fields of the models having them from the user of the context
+* of the statement, so the services only have to bind their queries to the request context with db.WithContext(ctx).
+* - create: CreatedBy and UpdatedBy are set to the user, unless they are already set.
+* - update: UpdatedBy is set to the user, whether the update is a struct or a map of columns.
+* A statement without a user in its context (e.g. a background job or an anonymous registration) is left unchanged.
+ */
+
+// RegisterAuditCallbacks installs the audit callbacks on every create and update run by the database instance.
+func RegisterAuditCallbacks(conn *gorm.DB) error {
+	cb := conn.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("audit:before_create", auditBeforeCreate),
+		cb.Update().Before("gorm:update").Register("audit:before_update", auditBeforeUpdate),
+	)
+}
+
+// auditBeforeCreate sets the CreatedBy and UpdatedBy fields left empty by the caller, on a single model or a batch.
+func auditBeforeCreate(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil {
+		return
+	}
+	meta, ok := metacontext.ExtractUserInformationMeta(tx.Statement.Context)
+	if !ok {
+		return
+	}
+
+	for _, name := range []string{"CreatedBy", "UpdatedBy"} {
+		field := tx.Statement.Schema.LookUpField(name)
+		if field == nil {
+			continue
+		}
+
+		switch tx.Statement.ReflectValue.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < tx.Statement.ReflectValue.Len(); i++ {
+				setIfZero(tx, field, reflect.Indirect(tx.Statement.ReflectValue.Index(i)), meta.UserID)
+			}
+		case reflect.Struct:
+			setIfZero(tx, field, tx.Statement.ReflectValue, meta.UserID)
+		}
+	}
+}
+
+// auditBeforeUpdate sets the UpdatedBy field to the user performing the update.
+func auditBeforeUpdate(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.LookUpField("UpdatedBy") == nil {
+		return
+	}
+	meta, ok := metacontext.ExtractUserInformationMeta(tx.Statement.Context)
+	if !ok {
+		return
+	}
+
+	// SetColumn updates the model and, for a map of columns, adds the column to the map
+	tx.Statement.SetColumn("UpdatedBy", meta.UserID, true)
+}
+
+// setIfZero sets the field of the model to the user ID if it is empty.
+func setIfZero(tx *gorm.DB, field *schema.Field, model reflect.Value, userID int64) {
+	if _, zero := field.ValueOf(tx.Statement.Context, model); zero {
+		_ = tx.AddError(field.Set(tx.Statement.Context, model, userID))
+	}
+}
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+)
+
+/**
+* The circuit breaker stops sending queries to the database once it looks unreachable, so the requests fail fast
+* with a 503 instead of all blocking until their timeout and piling up on a database trying to recover.
+* - closed: the queries run, and the consecutive connection failures are counted.
+* - open: after DB_BREAKER_THRESHOLD consecutive failures, every query fails with ErrCircuitOpen for DB_BREAKER_COOLDOWN.
+* - half-open: after the cooldown, a single query probes the database. Its success closes the breaker, its failure opens it again.
+* Only the connection failures count, an error returned by a reachable database (e.g. a record not found) is a success.
+ */
+const (
+	// defaultBreakerThreshold is the default number of consecutive failures opening the breaker
+	defaultBreakerThreshold = 5
+
+	// defaultBreakerCooldown is the default time the breaker stays open before probing the database again
+	defaultBreakerCooldown = 30 * time.Second
+
+	// breakerRejectedKey marks the statements rejected by the breaker, so their error is not counted as a failure
+	breakerRejectedKey = "breaker:rejected"
+)
+
+// ErrCircuitOpen is returned when the circuit breaker is open and the query is not sent to the database.
+var ErrCircuitOpen = errors.New("database is unavailable, the circuit breaker is open")
+
+// BreakerState is the state of the circuit breaker.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// CircuitBreaker guards the database against the queries sent while it is unreachable.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	probing   time.Time
+
+	// Now returns the current time, it is replaced in the tests
+	Now func() time.Time
+}
+
+// NewCircuitBreaker creates a closed circuit breaker opening after the given number of consecutive failures
+// for the given cooldown. A threshold below 1 disables the breaker.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+		Now:       time.Now,
+	}
+}
+
+// Allow reports whether a query may be sent to the database, it returns ErrCircuitOpen otherwise.
+// Once the cooldown has elapsed, a single query is allowed to probe the database.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.Now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = b.Now()
+		logger.Info("Database circuit breaker half-open, probing the database", nil)
+		return nil
+	case BreakerHalfOpen:
+		// A probe lost without an outcome (e.g. a panic) does not keep the breaker half-open forever
+		if b.Now().Sub(b.probing) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.probing = b.Now()
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Record records the outcome of a query allowed by the breaker.
+func (b *CircuitBreaker) Record(err error) {
+	// A cancelled request says nothing about the database
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !IsConnectionError(err) {
+		if b.state != BreakerClosed {
+			logger.Info("Database circuit breaker closed, the database is reachable again", nil)
+		}
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.threshold < 1 || (b.state == BreakerClosed && b.failures < b.threshold) {
+		return
+	}
+
+	if b.state == BreakerClosed {
+		logger.Error(fmt.Sprintf("Database circuit breaker open after %d consecutive failures: %v", b.failures, err), nil)
+	}
+	b.state = BreakerOpen
+	b.openedAt = b.Now()
+}
+
+// State returns the current state of the breaker. An open breaker whose cooldown has elapsed is reported half-open,
+// since the next query probes the database.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.Now().Sub(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// RetryAfter returns the time left before the open breaker probes the database again, 0 if it is not open.
+func (b *CircuitBreaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerOpen {
+		return 0
+	}
+	return max(b.cooldown-b.Now().Sub(b.openedAt), 0)
+}
+
+// Register installs the breaker on every statement run by the database instance, in or out of a transaction.
+// The writes are checked before their implicit transaction begins, which would otherwise wait for a connection.
+func (b *CircuitBreaker) Register(conn *gorm.DB) error {
+	cb := conn.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:begin_transaction").Register("breaker:before_create", b.before),
+		cb.Create().After("gorm:create").Register("breaker:after_create", b.after),
+		cb.Query().Before("gorm:query").Register("breaker:before_query", b.before),
+		cb.Query().After("gorm:query").Register("breaker:after_query", b.after),
+		cb.Update().Before("gorm:begin_transaction").Register("breaker:before_update", b.before),
+		cb.Update().After("gorm:update").Register("breaker:after_update", b.after),
+		cb.Delete().Before("gorm:begin_transaction").Register("breaker:before_delete", b.before),
+		cb.Delete().After("gorm:delete").Register("breaker:after_delete", b.after),
+		cb.Row().Before("gorm:row").Register("breaker:before_row", b.before),
+		cb.Row().After("gorm:row").Register("breaker:after_row", b.after),
+		cb.Raw().Before("gorm:raw").Register("breaker:before_raw", b.before),
+		cb.Raw().After("gorm:raw").Register("breaker:after_raw", b.after),
+	)
+}
+
+// before rejects the statement while the breaker is open.
+func (b *CircuitBreaker) before(tx *gorm.DB) {
+	if tx.Error != nil {
+		return
+	}
+	if err := b.Allow(); err != nil {
+		tx.Statement.Settings.Store(breakerRejectedKey, true)
+		_ = tx.AddError(err)
+	}
+}
+
+// after records the outcome of the statement sent to the database.
+func (b *CircuitBreaker) after(tx *gorm.DB) {
+	if _, rejected := tx.Statement.Settings.LoadAndDelete(breakerRejectedKey); rejected {
+		return
+	}
+	b.Record(tx.Error)
+}
+
+// IsConnectionError reports whether the error means the database could not be reached or stopped answering.
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+var (
+	breakerOnce sync.Once
+	breaker     *CircuitBreaker
+)
+
+// GetCircuitBreaker returns the circuit breaker of the PostgreSQL connection.
+// It retrieves the threshold and the cooldown from environment variables, a threshold of 0 disables it.
+func GetCircuitBreaker() *CircuitBreaker {
+	breakerOnce.Do(func() {
+		threshold, err := strconv.Atoi(os.Getenv("DB_BREAKER_THRESHOLD"))
+		if err != nil || threshold < 0 {
+			threshold = defaultBreakerThreshold
+		}
+
+		cooldown, err := time.ParseDuration(os.Getenv("DB_BREAKER_COOLDOWN"))
+		if err != nil || cooldown <= 0 {
+			cooldown = defaultBreakerCooldown
+		}
+
+		breaker = NewCircuitBreaker(threshold, cooldown)
+	})
+
+	return breaker
+}
+
+// unavailablePool is the connection pool of the database instance returned while the breaker is open.
+// Every statement and transaction fails with ErrCircuitOpen without reaching the database.
+type unavailablePool struct{}
+
+func (unavailablePool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, ErrCircuitOpen
+}
+
+func (unavailablePool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, ErrCircuitOpen
+}
+
+func (unavailablePool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, ErrCircuitOpen
+}
+
+// QueryRowContext is never reached, the breaker rejects the statement before it is run.
+func (unavailablePool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+func (unavailablePool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return nil, ErrCircuitOpen
+}
+
+// unavailable returns a database instance failing every statement and transaction with ErrCircuitOpen.
+func unavailable(conn *gorm.DB) *gorm.DB {
+	tx := conn.Session(&gorm.Session{NewDB: true, Context: context.Background()})
+	tx.Statement.ConnPool = unavailablePool{}
+	return tx
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+const (
+	// sqlStateNotNullViolation, sqlStateForeignKeyViolation and sqlStateCheckViolation are the errors PostgreSQL
+	// rejects a statement with when it breaks a constraint of the schema
+	sqlStateNotNullViolation    = "23502"
+	sqlStateForeignKeyViolation = "23503"
+	sqlStateCheckViolation      = "23514"
+
+	// sqliteConstraintCheck, sqliteConstraintForeignKey and sqliteConstraintNotNull are the extended result codes
+	// of SQLite for the same violations
+	sqliteConstraintCheck      = 275
+	sqliteConstraintForeignKey = 787
+	sqliteConstraintNotNull    = 1299
+)
+
+var (
+	// ErrForeignKeyViolation is returned when a statement refers to a missing row, or deletes a row still referenced.
+	ErrForeignKeyViolation = gorm.ErrForeignKeyViolated
+
+	// ErrNotNullViolation is returned when a statement leaves a required column empty.
+	ErrNotNullViolation = errors.New("not null constraint violated")
+
+	// ErrCheckViolation is returned when a statement writes a value rejected by a check constraint.
+	ErrCheckViolation = gorm.ErrCheckConstraintViolated
+
+	// pgKeyDetail extracts the column and the referencing table from the detail of a foreign key violation,
+	// e.g. Key (created_by)=(42) is not present in table "users".
+	pgKeyDetail = regexp.MustCompile(`^Key \(([^)]+)\)=.*?(still referenced from table "([^"]+)")?\.?$`)
+)
+
+// ConstraintError is a constraint violation of the database, along with the offending column when it is known.
+// It matches its kind with errors.Is, e.g. errors.Is(err, ErrForeignKeyViolation).
+type ConstraintError struct {
+	Kind       error
+	Table      string
+	Column     string
+	Constraint string
+
+	// ReferencedBy is the table still referencing a row that could not be deleted
+	ReferencedBy string
+
+	Err error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+}
+
+func (e *ConstraintError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Message returns a sentence describing the violation for the client, without the raw database error.
+func (e *ConstraintError) Message() string {
+	switch e.Kind {
+	case ErrForeignKeyViolation:
+		if e.ReferencedBy != "" {
+			return fmt.Sprintf("The record is still referenced by %s", e.ReferencedBy)
+		}
+		if e.Column != "" {
+			return fmt.Sprintf("The value of %s does not refer to an existing record", e.Column)
+		}
+		return "The record refers to a missing record, or is still referenced by another one"
+	case ErrNotNullViolation:
+		if e.Column != "" {
+			return fmt.Sprintf("The value of %s is required", e.Column)
+		}
+		return "A required value is missing"
+	default:
+		if e.Column != "" {
+			return fmt.Sprintf("The value of %s is not allowed", e.Column)
+		}
+		if e.Constraint != "" {
+			return fmt.Sprintf("A value is rejected by the %s constraint", e.Constraint)
+		}
+		return "A value is rejected by a constraint"
+	}
+}
+
+// TranslateConstraintError turns the foreign key, not null and check violations of PostgreSQL and SQLite
+// into a ConstraintError, the other errors are returned unchanged.
+func TranslateConstraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return translatePgConstraintError(err, pgErr)
+	}
+
+	// The SQLite driver exposes the extended result code of its errors
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		return translateSQLiteConstraintError(err, sqliteErr.Code())
+	}
+
+	return err
+}
+
+// translatePgConstraintError reads the offending column from the fields of the PostgreSQL error.
+func translatePgConstraintError(err error, pgErr *pgconn.PgError) error {
+	cerr := &ConstraintError{
+		Table:      pgErr.TableName,
+		Column:     pgErr.ColumnName,
+		Constraint: pgErr.ConstraintName,
+		Err:        err,
+	}
+
+	switch pgErr.Code {
+	case sqlStateForeignKeyViolation:
+		cerr.Kind = ErrForeignKeyViolation
+		// The column of a foreign key is only named in the detail of the error
+		if match := pgKeyDetail.FindStringSubmatch(pgErr.Detail); match != nil {
+			cerr.Column = match[1]
+			cerr.ReferencedBy = match[3]
+		}
+	case sqlStateNotNullViolation:
+		cerr.Kind = ErrNotNullViolation
+	case sqlStateCheckViolation:
+		cerr.Kind = ErrCheckViolation
+	default:
+		return err
+	}
+
+	return cerr
+}
+
+// translateSQLiteConstraintError reads the offending column from the message of the SQLite error,
+// e.g. NOT NULL constraint failed: users.email. SQLite does not name the column of a foreign key.
+func translateSQLiteConstraintError(err error, code int) error {
+	cerr := &ConstraintError{Err: err}
+
+	// The subject follows the last "constraint failed: ", the driver prefixes the message with the same words
+	subject := ""
+	if i := strings.LastIndex(err.Error(), "constraint failed: "); i >= 0 {
+		subject, _, _ = strings.Cut(err.Error()[i+len("constraint failed: "):], " ")
+	}
+
+	switch code {
+	case sqliteConstraintForeignKey:
+		cerr.Kind = ErrForeignKeyViolation
+	case sqliteConstraintNotNull:
+		cerr.Kind = ErrNotNullViolation
+		if table, column, ok := strings.Cut(subject, "."); ok {
+			cerr.Table, cerr.Column = table, column
+		}
+	case sqliteConstraintCheck:
+		cerr.Kind = ErrCheckViolation
+		cerr.Constraint = subject
+	default:
+		return err
+	}
+
+	return cerr
+}
+
+// constraintDialector translates the constraint violations before the dialector does,
+// since the translation of GORM drops the offending column along with the database error.
+type constraintDialector struct {
+	gorm.Dialector
+}
+
+// WithConstraintErrors wraps the dialector, so the statements breaking a constraint fail with a ConstraintError.
+// The other errors are left to the translation of the dialector.
+func WithConstraintErrors(dialector gorm.Dialector) gorm.Dialector {
+	return constraintDialector{Dialector: dialector}
+}
+
+// Apply lets the dialector adjust the configuration, e.g. PostgreSQL bounds the length of the identifiers.
+func (d constraintDialector) Apply(config *gorm.Config) error {
+	if applier, ok := d.Dialector.(interface{ Apply(*gorm.Config) error }); ok {
+		return applier.Apply(config)
+	}
+	return nil
+}
+
+func (d constraintDialector) Translate(err error) error {
+	if translated := TranslateConstraintError(err); translated != err {
+		return translated
+	}
+	if translator, ok := d.Dialector.(gorm.ErrorTranslator); ok {
+		return translator.Translate(err)
+	}
+	return err
+}
+
+func (d constraintDialector) SavePoint(tx *gorm.DB, name string) error {
+	if savePointer, ok := d.Dialector.(gorm.SavePointerDialectorInterface); ok {
+		return savePointer.SavePoint(tx, name)
+	}
+	return gorm.ErrUnsupportedDriver
+}
+
+func (d constraintDialector) RollbackTo(tx *gorm.DB, name string) error {
+	if savePointer, ok := d.Dialector.(gorm.SavePointerDialectorInterface); ok {
+		return savePointer.RollbackTo(tx, name)
+	}
+	return gorm.ErrUnsupportedDriver
+}
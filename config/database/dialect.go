@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite" // Import the pure Go SQLite driver for GORM
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+/**
+* The dialect of the database is selected with DB_DIALECT, PostgreSQL is the default.
+* - postgres: the production database, built from DB_HOST, DB_PORT, DB_USER, DB_PASS, DB_NAME, DB_SCHEMA, etc.
+* - sqlite: a file database at DB_NAME, for local development and the portability checks.
+*   The schema is not migrated on SQLite, since the entities use PostgreSQL column types.
+* The repositories stay portable across dialects, a PostgreSQL-only construct must have a fallback for the others.
+ */
+const (
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+// DBDialect is the dialect of the database, one of the Dialect constants
+var DBDialect string
+
+// GetDialect returns the configured dialect of the database, normalized to lowercase.
+func GetDialect() string {
+	if DBDialect == "" {
+		return DialectPostgres
+	}
+	return strings.ToLower(DBDialect)
+}
+
+// OpenDialector returns the GORM dialector of the configured dialect.
+// It returns an error if the dialect is not supported.
+func OpenDialector() (gorm.Dialector, error) {
+	switch GetDialect() {
+	case DialectPostgres:
+		user, pass := getCredentials()
+		dsn := fmt.Sprintf(
+			"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s TimeZone=%s search_path=%s",
+			DBHost,
+			DBPort,
+			user,
+			pass,
+			DBName,
+			DBSSLMode,
+			DBTimeZone,
+			DBSchema,
+		)
+		config, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PostgreSQL settings: %w", err)
+		}
+		config.RuntimeParams["timezone"] = DBTimeZone
+
+		// The new connections log in with the current credentials, so the pool keeps connecting after a rotation
+		// The timestamps are scanned in the time zone of the connection, as the driver does for a DSN
+		conn := stdlib.OpenDB(*config,
+			stdlib.OptionBeforeConnect(func(ctx context.Context, config *pgx.ConnConfig) error {
+				config.User, config.Password = getCredentials()
+				return nil
+			}),
+			stdlib.OptionAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+				loc, err := time.LoadLocation(DBTimeZone)
+				if err != nil {
+					return err
+				}
+				conn.TypeMap().RegisterType(&pgtype.Type{
+					Name:  "timestamp",
+					OID:   pgtype.TimestampOID,
+					Codec: &pgtype.TimestampCodec{ScanLocation: loc},
+				})
+				return nil
+			}),
+		)
+		return postgres.New(postgres.Config{DSN: dsn, Conn: conn}), nil
+	case DialectSQLite:
+		return sqlite.Open(DBName), nil
+	default:
+		return nil, fmt.Errorf("unsupported database dialect %q, expected %s or %s", DBDialect, DialectPostgres, DialectSQLite)
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	sqlite "github.com/glebarez/go-sqlite"
+	"gorm.io/gorm"
+
+	textutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/text-util"
+)
+
+/**
+* The lookups of the users compare the usernames and the emails case-insensitively with lower, and the role names
+* with upper. The built-in ones of SQLite only fold the ASCII letters, so they are replaced by the Unicode-aware
+* ones of Go, which fold like PostgreSQL: JOSÉ and josé are the same username on both dialects.
+*
+* The duplicate detection of the users compares them in SQL with two functions:
+* - normalize_email(email): the canonical form of an email address, see text_util.NormalizeEmail.
+* - similarity(a, b): the trigram similarity of two strings, from 0 to 1, see text_util.Similarity.
+* PostgreSQL gets similarity from the pg_trgm extension and normalize_email from the migration,
+* SQLite gets both from their Go implementation, so the queries are the same on both dialects.
+ */
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("lower", 1, caseFunction(strings.ToLower))
+	sqlite.MustRegisterDeterministicScalarFunction("upper", 1, caseFunction(strings.ToUpper))
+
+	sqlite.MustRegisterDeterministicScalarFunction("normalize_email", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		email, ok := textArg(args[0])
+		if !ok {
+			return nil, nil
+		}
+		return textutil.NormalizeEmail(email), nil
+	})
+
+	sqlite.MustRegisterDeterministicScalarFunction("similarity", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		a, okA := textArg(args[0])
+		b, okB := textArg(args[1])
+		if !okA || !okB {
+			return nil, nil
+		}
+		return textutil.Similarity(a, b), nil
+	})
+}
+
+// caseFunction returns an SQLite function converting the case of its text argument with the given function.
+func caseFunction(convert func(string) string) func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+	return func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		text, ok := textArg(args[0])
+		if !ok {
+			return nil, nil
+		}
+		return convert(text), nil
+	}
+}
+
+// textArg returns the text of an argument of an SQLite function, it reports false for NULL.
+func textArg(value driver.Value) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case nil:
+		return "", false
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+// migrateDuplicateDetection enables pg_trgm, creates normalize_email and the indexes of the duplicate detection.
+// The indexes only cover the users that are not deleted, which are the only ones compared:
+// the normalized emails are grouped through the first one, and the users are only compared to the users
+// having the same first and last names through the second one, which keeps the trigram comparisons few.
+func migrateDuplicateDetection(tx *gorm.DB) error {
+	domains := make([]string, 0, len(textutil.GmailStyleDomains))
+	for _, domain := range textutil.GmailStyleDomains {
+		domains = append(domains, "'"+domain+"'")
+	}
+
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION normalize_email(email text) RETURNS text
+			LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+				SELECT CASE
+					WHEN split_part(lower(email), '@', 2) IN (%s)
+					THEN replace(split_part(split_part(lower(email), '@', 1), '+', 1), '.', '') || '@%s'
+					ELSE lower(email)
+				END
+			$$`, strings.Join(domains, ", "), textutil.GmailStyleDomains[0]),
+		`CREATE INDEX IF NOT EXISTS idx_users_normalized_email ON users (tenant_id, normalize_email(email)) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_users_full_name ON users (tenant_id, lower(firstname), lower(COALESCE(lastname, ''))) WHERE deleted_at IS NULL`,
+	}
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
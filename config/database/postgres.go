@@ -1,16 +1,18 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
-	"gorm.io/driver/postgres"        // Import the PostgreSQL driver for GORM
 	"gorm.io/gorm"                   // Import GORM for ORM functionalities
 	gormLogger "gorm.io/gorm/logger" // Import GORM logger for logging SQL queries
 	"gorm.io/gorm/schema"
 
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
 	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
 )
 
@@ -29,6 +31,17 @@ var (
 	DBSeed     string
 	DBSeedFile string
 	DBLog      string
+
+	// DBPrepareStmt enables the prepared statement cache unless it is set to FALSE
+	DBPrepareStmt string
+)
+
+// credentialsMu guards DBUser and DBPass, which are replaced when the secret provider rotates them
+var credentialsMu sync.RWMutex
+
+const (
+	// prepareStmtMaxSize bounds the number of cached prepared statements, the least recently used are closed first
+	prepareStmtMaxSize = 1000
 )
 
 // LoadPostgresEnv loads environment variables from the .env file
@@ -36,8 +49,8 @@ var (
 func LoadPostgresEnv() bool {
 	DBHost = os.Getenv("DB_HOST")
 	DBPort = os.Getenv("DB_PORT")
-	DBUser = os.Getenv("DB_USER")
-	DBPass = os.Getenv("DB_PASS")
+	SetDBUser(os.Getenv("DB_USER"))
+	SetDBPass(os.Getenv("DB_PASS"))
 	DBName = os.Getenv("DB_NAME")
 	DBSchema = os.Getenv("DB_SCHEMA")
 	DBSSLMode = os.Getenv("DB_SSL_MODE")
@@ -46,6 +59,17 @@ func LoadPostgresEnv() bool {
 	DBSeed = os.Getenv("DB_SEED")
 	DBSeedFile = os.Getenv("DB_SEED_FILE")
 	DBLog = os.Getenv("DB_LOG")
+	DBPrepareStmt = os.Getenv("DB_PREPARE_STMT")
+	DBDialect = os.Getenv("DB_DIALECT")
+
+	// An SQLite database only needs the path of its file
+	if GetDialect() == DialectSQLite {
+		if DBName == "" {
+			logger.Panic("DB_NAME environment variable is not set", nil)
+			return false
+		}
+		return true
+	}
 
 	if DBHost == "" || DBPort == "" || DBUser == "" || DBPass == "" || DBName == "" || DBSchema == "" {
 		logger.Panic("One or more required environment variables are not set", nil)
@@ -55,6 +79,32 @@ func LoadPostgresEnv() bool {
 	return true
 }
 
+// SetDBUser replaces the user of the database, e.g. rotated by the secret provider.
+// The open connections are kept, the next ones log in as the new user.
+func SetDBUser(user string) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+
+	DBUser = user
+}
+
+// SetDBPass replaces the password of the database, e.g. rotated by the secret provider.
+// The open connections are kept, the next ones log in with the new password.
+func SetDBPass(pass string) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+
+	DBPass = pass
+}
+
+// getCredentials returns the current user and password of the database.
+func getCredentials() (string, string) {
+	credentialsMu.RLock()
+	defer credentialsMu.RUnlock()
+
+	return DBUser, DBPass
+}
+
 // InitPostgres initializes the GORM database connection
 func InitPostgres() bool {
 	isSuccess := true
@@ -64,50 +114,49 @@ func InitPostgres() bool {
 			return
 		}
 
-		// Create the connection string
-		dsn := fmt.Sprintf(
-			"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s TimeZone=%s search_path=%s",
-			DBHost,
-			DBPort,
-			DBUser,
-			DBPass,
-			DBName,
-			DBSSLMode,
-			DBTimeZone,
-			DBSchema,
-		)
-
-		// Set the log level based on the environment variable
-		var logLevel gormLogger.LogLevel
-		if DBLog == "INFO" {
-			logLevel = gormLogger.Info
-		} else if DBLog == "ERROR" {
-			logLevel = gormLogger.Error
-		} else if DBLog == "SILENT" {
-			logLevel = gormLogger.Silent
-		} else {
-			logLevel = gormLogger.Warn
-		}
-
-		// Open the connection using GORM and PostgreSQL driver
-		var err error
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-			NamingStrategy: schema.NamingStrategy{
-				TablePrefix:   DBSchema + ".",
-				SingularTable: false,
-			},
-			Logger: gormLogger.Default.LogMode(logLevel),
-		})
+		// Select the driver of the configured dialect
+		dialector, err := OpenDialector()
 		if err != nil {
-			logger.Fatal(fmt.Sprintf("Failed to connect to PostgreSQL: %v", err), nil)
+			logger.Fatal(fmt.Sprintf("Failed to configure the database: %v", err), nil)
+			isSuccess = false
+			return
+		}
+
+		// Open the connection using GORM
+		db, err = gorm.Open(WithConstraintErrors(dialector), NewGormConfig())
+		if err != nil {
+			logger.Fatal(fmt.Sprintf("Failed to connect to the %s database: %v", GetDialect(), err), nil)
+			isSuccess = false
+			return
+		}
+
+		logger.Info(fmt.Sprintf("Connected to the %s database", GetDialect()), nil)
+
+		// Fail fast while the database is unreachable, instead of letting every request wait for its timeout
+		if err = GetCircuitBreaker().Register(db); err != nil {
+			logger.Fatal(fmt.Sprintf("Failed to register the database circuit breaker: %v", err), nil)
 			isSuccess = false
 			return
 		}
 
-		logger.Info("Connected to PostgreSQL database", nil)
+		// Record the user of the request as the author of the created and updated rows
+		if err = RegisterAuditCallbacks(db); err != nil {
+			logger.Fatal(fmt.Sprintf("Failed to register the audit callbacks: %v", err), nil)
+			isSuccess = false
+			return
+		}
+
+		// Store the times of the created and updated rows in UTC
+		if err = RegisterUTCCallbacks(db); err != nil {
+			logger.Fatal(fmt.Sprintf("Failed to register the UTC callbacks: %v", err), nil)
+			isSuccess = false
+			return
+		}
 
-		// Migrate the database schema and all tables
-		if DBMigrate == "TRUE" {
+		// Migrate the database schema and all tables, the entities use PostgreSQL column types
+		if DBMigrate == "TRUE" && GetDialect() != DialectPostgres {
+			logger.Warn(fmt.Sprintf("DB_MIGRATE is ignored with the %s dialect, the schema must be created beforehand", GetDialect()), nil)
+		} else if DBMigrate == "TRUE" {
 			if err = MigratePostgres(); err != nil {
 				logger.Fatal(fmt.Sprintf("Failed to migrate PostgreSQL database: %v", err), nil)
 				isSuccess = false
@@ -148,24 +197,54 @@ func MigratePostgres() error {
 		// Drop and recreate tables if they exist
 		err := tx.Migrator().DropTable(
 			&entity.Consumer{},
+			&entity.UserTenant{},
 			&entity.User{},
+			&entity.Tenant{},
 			&entity.Role{},
 			&entity.UserRole{},
-			&entity.RefreshToken{})
+			&entity.Permission{},
+			&entity.RolePermission{},
+			&entity.RefreshToken{},
+			&entity.PasswordHistory{},
+			&entity.LoginAttempt{},
+			&entity.AuditLog{},
+			&entity.ScheduledDeletion{},
+			&entity.RoleReassignment{},
+			&entity.UserNote{},
+			&entity.UserRegistration{},
+			&entity.GroupMembership{},
+			&entity.Group{},
+			&entity.OutboxEvent{})
 		if err != nil {
 			return fmt.Errorf("failed to drop tables: %v", err)
 		}
 
 		// Migrate the database schema
-		err = tx.AutoMigrate(
-			&entity.Role{},
-			&entity.User{},
-			&entity.RefreshToken{},
-			&entity.Consumer{})
+		err = tx.AutoMigrate(MigratedModels()...)
 		if err != nil {
 			return fmt.Errorf("failed to migrate database: %v", err)
 		}
 
+		// Make the usernames and the emails unique among the users that are not deleted
+		if err := MigrateUserUniqueIndexes(tx); err != nil {
+			return fmt.Errorf("failed to migrate user unique indexes: %v", err)
+		}
+
+		// Index the times of the changes of the users, for the integrations polling them
+		if err := MigrateUserChangeIndexes(tx); err != nil {
+			return fmt.Errorf("failed to migrate user change indexes: %v", err)
+		}
+
+		// Enable the trigram similarity and index the users for the duplicate detection
+		if err := migrateDuplicateDetection(tx); err != nil {
+			return fmt.Errorf("failed to migrate duplicate detection: %v", err)
+		}
+
+		// Seed the default tenant and the reserved system user, which every database requires
+		if err := seedSystemData(tx); err != nil {
+			return fmt.Errorf("failed to seed system data: %v", err)
+		}
+
 		if DBSeed == "TRUE" {
 			// Import initial data from the seed file
 			if DBSeedFile == "" {
@@ -196,7 +275,46 @@ func MigratePostgres() error {
 	return nil
 }
 
-// GetPostgres returns the GORM database instance
+// NewGormConfig creates the GORM configuration from the environment variables.
+// The prepared statement cache lets the hot lookups (e.g. the user by ID or username) skip the parsing
+// and planning on every call. The statements are prepared on the pool and re-prepared by database/sql
+// on each connection they run on, and statements used in a transaction are bound to it.
+func NewGormConfig() *gorm.Config {
+	// Set the log level based on the environment variable
+	var logLevel gormLogger.LogLevel
+	if DBLog == "INFO" {
+		logLevel = gormLogger.Info
+	} else if DBLog == "ERROR" {
+		logLevel = gormLogger.Error
+	} else if DBLog == "SILENT" {
+		logLevel = gormLogger.Silent
+	} else {
+		logLevel = gormLogger.Warn
+	}
+
+	// Prefix the tables with the schema when it is set
+	tablePrefix := ""
+	if DBSchema != "" {
+		tablePrefix = DBSchema + "."
+	}
+
+	return &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix:   tablePrefix,
+			SingularTable: false,
+		},
+		Logger:             gormLogger.Default.LogMode(logLevel),
+		PrepareStmt:        DBPrepareStmt != "FALSE",
+		PrepareStmtMaxSize: prepareStmtMaxSize,
+		// Translate the unique violations into gorm.ErrDuplicatedKey, so the services can answer them with a conflict
+		TranslateError: true,
+		// Stamp the CreatedAt, UpdatedAt and DeletedAt fields in UTC, whatever the zone of the server
+		NowFunc: func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// GetPostgres returns the GORM database instance of the configured dialect.
+// The services should prefer GetDB, which also returns the transaction of the request.
 func GetPostgres() *gorm.DB {
 	if db == nil {
 		if !InitPostgres() {
@@ -207,6 +325,28 @@ func GetPostgres() *gorm.DB {
 	return db
 }
 
+// SetPostgres replaces the GORM database instance returned by GetPostgres.
+// It is used to run the services against another connection, e.g. an SQLite database in the tests.
+// The instance is not initialized from the environment afterwards, a nil instance makes the database unavailable.
+func SetPostgres(conn *gorm.DB) {
+	once.Do(func() {})
+	db = conn
+}
+
+// PingPostgres checks that the database answers, without initializing the connection if it is not yet.
+func PingPostgres(ctx context.Context) error {
+	if db == nil {
+		return ErrDatabaseUnavailable
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
 // ClosePostgres closes the database connection (optional, for when needed)
 func ClosePostgres() {
 	sqlDB, err := db.DB()
@@ -223,3 +363,63 @@ func ClosePostgres() {
 	db = nil           // Clear the db variable to prevent further use
 	logger.Info("Database connection closed successfully", nil)
 }
+
+// seedSystemData inserts the default tenant and the reserved system user if they do not exist.
+// The system user is recorded as the actor of the operations performed without a human, it cannot log in:
+// it is disabled and its password is not a valid bcrypt hash.
+func seedSystemData(tx *gorm.DB) error {
+	err := tx.Exec(`INSERT INTO tenants (id, name) VALUES (?, 'Default') ON CONFLICT DO NOTHING`,
+		metacontext.DefaultTenantID).Error
+	if err != nil {
+		return err
+	}
+
+	// Move the sequence past the explicit ID, so the next tenants do not collide with it
+	err = tx.Exec(`SELECT setval(pg_get_serial_sequence('tenants', 'id'), (SELECT MAX(id) FROM tenants))`).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Exec(`INSERT INTO users (id, tenant_id, username, password, email, firstname, is_enabled, user_type, created_by, updated_by)
+		VALUES (?, ?, ?, '!', 'system@localhost', 'System', false, 'SERVICE_ACCOUNT', ?, ?) ON CONFLICT DO NOTHING`,
+		metacontext.SystemUserID, metacontext.DefaultTenantID, metacontext.SystemUsername,
+		metacontext.SystemUserID, metacontext.SystemUserID).Error
+}
+
+// MigrateUserUniqueIndexes replaces the unique indexes of the usernames and the emails of the users by partial ones,
+// which only cover the users that are not deleted: the service only checks the uniqueness among them, so a soft-deleted
+// user must not block the reuse of its username or email. The indexes are the same on PostgreSQL and SQLite.
+func MigrateUserUniqueIndexes(tx *gorm.DB) error {
+	statements := []string{
+		`DROP INDEX IF EXISTS idx_users_tenant_username`,
+		`DROP INDEX IF EXISTS idx_users_tenant_email`,
+		`CREATE UNIQUE INDEX idx_users_tenant_username ON users (tenant_id, username) WHERE is_deleted = false`,
+		`CREATE UNIQUE INDEX idx_users_tenant_email ON users (tenant_id, email) WHERE is_deleted = false`,
+	}
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MigrateUserChangeIndexes creates the indexes of the lists of the users changed since a time, see entity.UserFilter.
+// The lists are ordered by the time and then by ID, the composite indexes serve both the range and the order,
+// so the query plans stay index scans whatever the size of the table. The indexes are the same on PostgreSQL and SQLite.
+func MigrateUserChangeIndexes(tx *gorm.DB) error {
+	statements := []string{
+		// The composite index replaces the index of the update time alone
+		`DROP INDEX IF EXISTS idx_users_updated_at`,
+		`CREATE INDEX IF NOT EXISTS idx_users_updated_at_id ON users (updated_at, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users (created_at, id)`,
+	}
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// ErrSchemaMismatch is returned when the tables or the columns of the migration are missing from the database,
+// e.g. a database migrated by an older version of the application.
+var ErrSchemaMismatch = errors.New("the database schema does not match the migration")
+
+// MigratedModels returns the models of the tables created by the migration, in the order they are migrated.
+func MigratedModels() []any {
+	return []any{
+		&entity.Tenant{},
+		&entity.Role{},
+		&entity.User{},
+		&entity.UserRole{},
+		&entity.Permission{},
+		&entity.RolePermission{},
+		&entity.UserTenant{},
+		&entity.RefreshToken{},
+		&entity.PasswordHistory{},
+		&entity.LoginAttempt{},
+		&entity.AuditLog{},
+		&entity.ScheduledDeletion{},
+		&entity.RoleReassignment{},
+		&entity.UserNote{},
+		&entity.UserRegistration{},
+		&entity.Group{},
+		&entity.GroupMembership{},
+		&entity.OutboxEvent{},
+		&entity.Consumer{},
+	}
+}
+
+// VerifySchema checks that the tables and the columns of the models exist in the database, and returns the fingerprint
+// of the schema the models expect: the first bytes of the SHA-256 of their sorted table.column names, in hexadecimal,
+// without the prefix of DB_SCHEMA.
+// The fingerprint changes with every table or column added to the migration, so two deployments report the same one
+// when they expect the same schema. The error wraps ErrSchemaMismatch and lists every missing table and column.
+// The columns the models do not map are ignored, as are their types.
+func VerifySchema(conn *gorm.DB, models ...any) (string, error) {
+	var expected, missing []string
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: conn}
+		if err := stmt.Parse(model); err != nil {
+			return "", fmt.Errorf("failed to parse the model %T: %w", model, err)
+		}
+		table := stmt.Schema.Table
+		_, name, found := strings.Cut(table, ".")
+		if !found {
+			name = table
+		}
+
+		var columns []string
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" {
+				columns = append(columns, field.DBName)
+				expected = append(expected, name+"."+field.DBName)
+			}
+		}
+
+		if !conn.Migrator().HasTable(model) {
+			missing = append(missing, "table "+table)
+			continue
+		}
+
+		columnTypes, err := conn.Migrator().ColumnTypes(model)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the columns of %s: %w", table, err)
+		}
+		existing := make(map[string]bool, len(columnTypes))
+		for _, columnType := range columnTypes {
+			existing[columnType.Name()] = true
+		}
+		for _, column := range columns {
+			if !existing[column] {
+				missing = append(missing, "column "+table+"."+column)
+			}
+		}
+	}
+
+	slices.Sort(expected)
+	sum := sha256.Sum256([]byte(strings.Join(expected, "\n")))
+	fingerprint := hex.EncodeToString(sum[:6])
+
+	if len(missing) > 0 {
+		return fingerprint, fmt.Errorf("%w, missing %s", ErrSchemaMismatch, strings.Join(missing, ", "))
+	}
+	return fingerprint, nil
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrDatabaseUnavailable is returned when the database connection is not initialized,
+// e.g. the connection failed at startup, so the request cannot be served until the application is restarted.
+var ErrDatabaseUnavailable = errors.New("database connection is not initialized")
+
+// txContextKey is the key of the request-scoped transaction in the context
+type txContextKey struct{}
+
+// InjectTx stores the transaction of the request into the context.
+// The services run their queries in this transaction instead of opening their own connection.
+func InjectTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// ExtractTx retrieves the transaction of the request from the context.
+func ExtractTx(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*gorm.DB)
+	return tx, ok && tx != nil
+}
+
+// GetDB returns the transaction of the request if the context carries one, or the connection of the configured dialect.
+// A transaction opened by a service on the returned instance is nested in the request transaction as a savepoint,
+// so it is only made permanent when the request transaction commits.
+// While the circuit breaker is open, the returned instance fails every statement and transaction with ErrCircuitOpen.
+func GetDB(ctx context.Context) *gorm.DB {
+	if tx, ok := ExtractTx(ctx); ok {
+		return tx
+	}
+
+	conn := GetPostgres()
+	if conn != nil && GetCircuitBreaker().State() == BreakerOpen {
+		return unavailable(conn)
+	}
+
+	return conn
+}
+
+// RequireDB returns the instance of GetDB, or ErrDatabaseUnavailable if the database connection is not initialized.
+func RequireDB(ctx context.Context) (*gorm.DB, error) {
+	db := GetDB(ctx)
+	if db == nil {
+		return nil, ErrDatabaseUnavailable
+	}
+	return db, nil
+}
+
+// RequirePostgres returns the connection of the configured dialect, outside of the request transaction,
+// or ErrDatabaseUnavailable if the database connection is not initialized.
+func RequirePostgres() (*gorm.DB, error) {
+	db := GetPostgres()
+	if db == nil {
+		return nil, ErrDatabaseUnavailable
+	}
+	return db, nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+)
+
+const (
+	// defaultTxMaxAttempts is the default number of times a transaction is run before giving up on the contention
+	defaultTxMaxAttempts = 3
+
+	// txRetryBaseDelay is the delay before the second attempt, it doubles on every attempt and is jittered
+	txRetryBaseDelay = 20 * time.Millisecond
+
+	// sqlStateSerializationFailure and sqlStateDeadlockDetected are the errors PostgreSQL aborts
+	// a transaction with when it conflicts with concurrent transactions
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// ErrContention is returned when a transaction kept conflicting with concurrent transactions after all its attempts.
+var ErrContention = errors.New("transaction aborted by concurrent transactions")
+
+// IsContentionError reports whether PostgreSQL aborted the transaction because of a serialization failure or a deadlock.
+// Such a transaction did not change anything and succeeds when it is run again.
+func IsContentionError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == sqlStateSerializationFailure || pgErr.Code == sqlStateDeadlockDetected)
+}
+
+// TransactionWithRetry runs fn in a transaction, and runs it again in a new transaction with a jittered backoff
+// when PostgreSQL aborts it because of the concurrent transactions. It returns ErrContention once all attempts failed.
+// fn must be safe to run again: its only effects before the commit are the statements of the transaction.
+// A transaction nested in the request transaction is not retried, the abort rolls back the request transaction too.
+func TransactionWithRetry(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	maxAttempts := GetTxMaxAttempts()
+	if _, nested := ExtractTx(ctx); nested {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = db.WithContext(ctx).Transaction(fn); !IsContentionError(err) {
+			return err
+		}
+		if attempt == maxAttempts {
+			break
+		}
+
+		// Back off with a random delay, so the conflicting transactions do not collide again
+		delay := txRetryBaseDelay << (attempt - 1)
+		delay = delay/2 + rand.N(delay)
+		logger.Warn(fmt.Sprintf("Transaction aborted by concurrent transactions, retrying in %v (attempt %d of %d): %v",
+			delay, attempt+1, maxAttempts, err), nil)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	return fmt.Errorf("%w: %v", ErrContention, err)
+}
+
+// GetTxMaxAttempts returns the number of times a transaction aborted by the concurrent transactions is run.
+// It retrieves the number from an environment variable, 1 disables the retries.
+func GetTxMaxAttempts() int {
+	attempts, err := strconv.Atoi(os.Getenv("DB_TX_MAX_ATTEMPTS"))
+	if err != nil || attempts < 1 {
+		return defaultTxMaxAttempts // Default to 3 attempts if the environment variable is not set or invalid
+	}
+
+	return attempts
+}
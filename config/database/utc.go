@@ -0,0 +1,83 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+/**
+* The UTC callbacks convert the times of the created and updated rows to UTC before they are written,
+* so the stored times never depend on the zone of the server or of the client that sent them.
+* - create and update: the time fields of the model, on a single model or a batch.
+* - update with a map of columns: the time values of the map.
+* The CreatedAt, UpdatedAt and DeletedAt fields are already stamped in UTC by the NowFunc of the GORM configuration.
+ */
+
+// RegisterUTCCallbacks installs the UTC callbacks on every create and update run by the database instance.
+func RegisterUTCCallbacks(conn *gorm.DB) error {
+	cb := conn.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("utc:before_create", utcBeforeSave),
+		cb.Update().Before("gorm:update").Register("utc:before_update", utcBeforeSave),
+	)
+}
+
+// utcBeforeSave converts the time fields of the model, and the time values of a map of columns, to UTC.
+func utcBeforeSave(tx *gorm.DB) {
+	if tx.Error != nil {
+		return
+	}
+
+	if columns, ok := tx.Statement.Dest.(map[string]interface{}); ok {
+		for column, value := range columns {
+			switch t := value.(type) {
+			case time.Time:
+				columns[column] = t.UTC()
+			case *time.Time:
+				if t != nil {
+					columns[column] = t.UTC()
+				}
+			}
+		}
+	}
+
+	if tx.Statement.Schema == nil {
+		return
+	}
+	for _, field := range tx.Statement.Schema.Fields {
+		if field.FieldType != reflect.TypeOf(time.Time{}) && field.FieldType != reflect.TypeOf(&time.Time{}) {
+			continue
+		}
+
+		switch tx.Statement.ReflectValue.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < tx.Statement.ReflectValue.Len(); i++ {
+				setUTC(tx, field, reflect.Indirect(tx.Statement.ReflectValue.Index(i)))
+			}
+		case reflect.Struct:
+			setUTC(tx, field, tx.Statement.ReflectValue)
+		}
+	}
+}
+
+// setUTC converts the time field of the model to UTC if it is set.
+func setUTC(tx *gorm.DB, field *schema.Field, model reflect.Value) {
+	value, zero := field.ValueOf(tx.Statement.Context, model)
+	if zero {
+		return
+	}
+
+	switch t := value.(type) {
+	case time.Time:
+		_ = tx.AddError(field.Set(tx.Statement.Context, model, t.UTC()))
+	case *time.Time:
+		if t != nil {
+			utc := t.UTC()
+			_ = tx.AddError(field.Set(tx.Statement.Context, model, &utc))
+		}
+	}
+}
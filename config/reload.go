@@ -0,0 +1,238 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/featureflag"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/headers"
+)
+
+/**
+* The reloadable settings are applied without a restart when the process receives SIGHUP:
+* - LOG_LEVEL: the minimum level of the logged messages.
+* - FEATURE_FLAGS: the values of the static feature flags.
+* - CORS_ALLOWED_ORIGINS: the origins allowed by the CORS headers.
+* The environment of a running process cannot change, so the new values come from the optional configuration file
+* named by CONFIG_FILE: a YAML mapping of the environment variables to their values, which overrides the environment.
+* The other settings, e.g. the database connection, the ports or the JWT keys, are only read at startup:
+* a change of them in the file is reported and ignored until the next restart.
+ */
+
+// reloadableSetting validates and applies the new value of a reloadable setting.
+// The value is set in the environment before apply is called, apply reads it from there like at startup.
+type reloadableSetting struct {
+	validate func(value string) error
+	apply    func()
+}
+
+// reloadableSettings lists the settings applied without a restart by their environment variable.
+var reloadableSettings = map[string]reloadableSetting{
+	"LOG_LEVEL": {
+		validate: func(value string) error {
+			_, err := logger.ParseLevel(value)
+			return err
+		},
+		apply: ApplyLogLevel,
+	},
+	"FEATURE_FLAGS": {
+		validate: func(value string) error {
+			_, err := featureflag.NewStaticProvider(value)
+			return err
+		},
+		apply: featureflag.Reload,
+	},
+	"CORS_ALLOWED_ORIGINS": {
+		apply: headers.ReloadCorsConfig,
+	},
+}
+
+// secretMarkers are the parts of the names of the settings whose values are never logged
+var secretMarkers = []string{"SECRET", "PASS", "PRIVATE", "KEY", "TOKEN"}
+
+// IsReloadable reports whether the setting of the environment variable is applied without a restart.
+func IsReloadable(key string) bool {
+	_, ok := reloadableSettings[key]
+	return ok
+}
+
+// ApplyLogLevel sets the minimum level of the logged messages from LOG_LEVEL, an invalid level is ignored.
+func ApplyLogLevel() {
+	if level, err := logger.ParseLevel(os.Getenv("LOG_LEVEL")); err == nil {
+		logger.SetLevel(level)
+	}
+}
+
+// ConfigChange is a setting whose value was changed in the configuration file.
+// Applied is false for the settings only read at startup, their change waits for the next restart.
+type ConfigChange struct {
+	Key     string
+	Old     string
+	New     string
+	Applied bool
+}
+
+// String describes the change for the logs, the values of the secrets are redacted.
+func (c ConfigChange) String() string {
+	if isSecret(c.Key) {
+		return fmt.Sprintf("%s: [REDACTED]", c.Key)
+	}
+	return fmt.Sprintf("%s: %q -> %q", c.Key, c.Old, c.New)
+}
+
+// isSecret reports whether the value of the setting must not be logged.
+func isSecret(key string) bool {
+	return slices.ContainsFunc(secretMarkers, func(marker string) bool { return strings.Contains(key, marker) })
+}
+
+// Reloader applies the configuration file over the environment, and reloads its reloadable settings.
+// It is safe for concurrent use.
+type Reloader struct {
+	path string
+
+	mu sync.Mutex
+	// original holds the values of the environment replaced by the file, nil for the variables that were not set,
+	// so a setting removed from the file gets back its value of the environment
+	original map[string]*string
+}
+
+// NewReloader applies the configuration file at the path over the environment, the path can be empty for no file.
+// It must be called before the configuration is loaded, so the settings of the file are read like the environment.
+func NewReloader(path string) (*Reloader, error) {
+	r := &Reloader{path: path, original: make(map[string]*string)}
+
+	values, err := r.readFile()
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range values {
+		r.set(key, &value)
+	}
+
+	// The reloadable settings may have been read before, e.g. by a previous reloader
+	for _, setting := range reloadableSettings {
+		setting.apply()
+	}
+	return r, nil
+}
+
+// Reload reads the configuration file again and applies the changes of the reloadable settings,
+// the changes of the other settings are returned with Applied false and left out.
+// The reload is all or nothing: an invalid reloadable value is returned as an error and nothing is applied.
+func (r *Reloader) Reload() ([]ConfigChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	values, err := r.readFile()
+	if err != nil {
+		return nil, err
+	}
+
+	// The settings of the file, and those removed from it since they were applied
+	keys := make([]string, 0, len(values)+len(r.original))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	for key := range r.original {
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	var changes []ConfigChange
+	targets := make(map[string]*string)
+	for _, key := range keys {
+		target := r.original[key]
+		if value, ok := values[key]; ok {
+			target = &value
+		}
+
+		current, ok := os.LookupEnv(key)
+		if (target == nil && !ok) || (target != nil && ok && *target == current) {
+			continue
+		}
+
+		change := ConfigChange{Key: key, Old: current, Applied: IsReloadable(key)}
+		if target != nil {
+			change.New = *target
+		}
+		if setting := reloadableSettings[key]; change.Applied && setting.validate != nil {
+			if err := setting.validate(change.New); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", key, err)
+			}
+		}
+
+		changes = append(changes, change)
+		targets[key] = target
+	}
+
+	// Swap the values of the reloadable settings, the next requests read the new values
+	for _, change := range changes {
+		if change.Applied {
+			r.set(change.Key, targets[change.Key])
+			reloadableSettings[change.Key].apply()
+		}
+	}
+
+	return changes, nil
+}
+
+// WatchSIGHUP reloads the configuration file each time the process receives SIGHUP.
+// The onReload callback is called with the result of every reload.
+func (r *Reloader) WatchSIGHUP(onReload func(changes []ConfigChange, err error)) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	go func() {
+		for range sighup {
+			changes, err := r.Reload()
+			if onReload != nil {
+				onReload(changes, err)
+			}
+		}
+	}()
+}
+
+// readFile reads the settings of the configuration file, there are none without a file.
+func (r *Reloader) readFile() (map[string]string, error) {
+	if r.path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the configuration file: %w", err)
+	}
+
+	var values map[string]string
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s, expected a mapping of the environment variables to their values: %w", r.path, err)
+	}
+	return values, nil
+}
+
+// set sets the environment variable to the value, or unsets it for nil, remembering its value of the environment.
+func (r *Reloader) set(key string, value *string) {
+	if _, ok := r.original[key]; !ok {
+		if current, ok := os.LookupEnv(key); ok {
+			r.original[key] = &current
+		} else {
+			r.original[key] = nil
+		}
+	}
+
+	if value == nil {
+		os.Unsetenv(key)
+		return
+	}
+	os.Setenv(key, *value)
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// CertReloader serves a TLS certificate loaded from files and reloads it on demand.
+// The certificate is swapped atomically, so established connections are not dropped
+// and new handshakes pick up the new certificate.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+// NewCertReloader loads the certificate from the given files and returns a new CertReloader.
+func NewCertReloader(certFile string, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate files again and swaps the served certificate.
+// The previous certificate is kept if the files cannot be loaded.
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	r.cert.Store(&cert)
+	return nil
+}
+
+// GetCertificate returns the current certificate, it is used as the tls.Config GetCertificate callback.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// WatchSIGHUP reloads the certificate each time the process receives SIGHUP.
+// The onReload callback is called with the result of every reload.
+func (r *CertReloader) WatchSIGHUP(onReload func(err error)) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	go func() {
+		for range sighup {
+			err := r.Reload()
+			if onReload != nil {
+				onReload(err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+)
+
+// ServerConfig holds the configuration of the HTTP server.
+// TLS is served either from certificate files or from certificates obtained through Let's Encrypt (autocert).
+type ServerConfig struct {
+	Port             string
+	IsSSL            bool
+	CertFile         string
+	KeyFile          string
+	AutocertCacheDir string
+	AutocertDomains  []string
+	HTTPRedirectPort string
+}
+
+// LoadServerConfig loads the server configuration from environment variables.
+func LoadServerConfig() ServerConfig {
+	cfg := ServerConfig{
+		Port:             os.Getenv("PORT"),
+		IsSSL:            strings.ToUpper(os.Getenv("IS_SSL")) == "TRUE",
+		CertFile:         os.Getenv("SSL_CERT"),
+		KeyFile:          os.Getenv("SSL_KEYS"),
+		AutocertCacheDir: os.Getenv("AUTOCERT_CACHE_DIR"),
+		HTTPRedirectPort: os.Getenv("HTTP_REDIRECT_PORT"),
+	}
+
+	for _, domain := range strings.Split(os.Getenv("AUTOCERT_DOMAINS"), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			cfg.AutocertDomains = append(cfg.AutocertDomains, domain)
+		}
+	}
+
+	return cfg
+}
+
+// UseAutocert reports whether the certificates are obtained through Let's Encrypt.
+func (cfg ServerConfig) UseAutocert() bool {
+	return cfg.IsSSL && cfg.AutocertCacheDir != ""
+}
+
+// Validate checks that the configuration is consistent.
+func (cfg ServerConfig) Validate() error {
+	if cfg.Port == "" {
+		return fmt.Errorf("PORT is not set")
+	}
+	if !cfg.IsSSL {
+		return nil
+	}
+	if cfg.UseAutocert() {
+		if len(cfg.AutocertDomains) == 0 {
+			return fmt.Errorf("AUTOCERT_DOMAINS must be set when AUTOCERT_CACHE_DIR is set")
+		}
+		return nil
+	}
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return fmt.Errorf("SSL_CERT and SSL_KEYS must be set when IS_SSL is TRUE")
+	}
+	return nil
+}
+
+// Server wraps the main HTTP server and the optional HTTP to HTTPS redirect server.
+type Server struct {
+	cfg      ServerConfig
+	main     *http.Server
+	redirect *http.Server
+	reloader *CertReloader
+}
+
+// NewServer creates the HTTP server(s) for the given configuration and handler.
+// When TLS is enabled, HTTP/2 is negotiated through ALPN.
+func NewServer(cfg ServerConfig, handler http.Handler) (*Server, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		cfg: cfg,
+		main: &http.Server{
+			Addr:              ":" + cfg.Port,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+
+	if !cfg.IsSSL {
+		return s, nil
+	}
+
+	// Handler used by the optional plain HTTP listener to redirect clients to HTTPS
+	var redirectHandler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if cfg.Port != "443" {
+			host = net.JoinHostPort(host, cfg.Port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+
+	if cfg.UseAutocert() {
+		// Obtain and renew the certificates from Let's Encrypt
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+		}
+		s.main.TLSConfig = manager.TLSConfig()
+
+		// The HTTP listener must also answer the ACME HTTP-01 challenges
+		redirectHandler = manager.HTTPHandler(redirectHandler)
+	} else {
+		// Serve the certificate files through a reloadable certificate
+		reloader, err := NewCertReloader(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		s.reloader = reloader
+		s.main.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+	}
+
+	// Enable HTTP/2 with a fallback to HTTP/1.1
+	s.main.TLSConfig.NextProtos = appendIfMissing(s.main.TLSConfig.NextProtos, "h2", "http/1.1")
+
+	if cfg.HTTPRedirectPort != "" {
+		s.redirect = &http.Server{
+			Addr:              ":" + cfg.HTTPRedirectPort,
+			Handler:           redirectHandler,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+	}
+
+	return s, nil
+}
+
+// CertReloader returns the certificate reloader, or nil when the certificates are not loaded from files.
+func (s *Server) CertReloader() *CertReloader {
+	return s.reloader
+}
+
+// HTTPServer returns the main HTTP server.
+func (s *Server) HTTPServer() *http.Server {
+	return s.main
+}
+
+// RedirectServer returns the HTTP to HTTPS redirect server, or nil when it is not configured.
+func (s *Server) RedirectServer() *http.Server {
+	return s.redirect
+}
+
+// ListenAndServe starts the redirect listener (if configured) in the background and serves the main server.
+// It returns http.ErrServerClosed after a graceful shutdown.
+func (s *Server) ListenAndServe() error {
+	if !s.cfg.IsSSL {
+		return s.main.ListenAndServe()
+	}
+
+	if s.redirect != nil {
+		go func() {
+			if err := s.redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error(fmt.Sprintf("HTTP redirect server stopped: %v", err), nil)
+			}
+		}()
+	}
+
+	// The certificates are provided by the TLS configuration
+	return s.main.ListenAndServeTLS("", "")
+}
+
+// appendIfMissing appends the values which are not already present in the slice.
+func appendIfMissing(slice []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, s := range slice {
+			if s == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			slice = append(slice, v)
+		}
+	}
+	return slice
+}
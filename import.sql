@@ -1,3 +1,4 @@
+-- The default tenant and the system user (ID 0) are seeded by the migration.
 -- Description: SQL script to import initial user data into the database.
 INSERT INTO users (username,"password",email,firstname,lastname,is_enabled,is_account_non_expired,is_account_non_locked,is_credentials_non_expired,is_deleted,account_expiration_date,credentials_expiration_date,user_type,last_login,created_by,updated_by) VALUES
 	 ('admin','$2a$10$eP5Sddi7Q5Jv6seppeF93.XsWGY8r4PnsqprWGb5AxsZ9TpwULIGa','admin@mygmail.com','Admin','Admin',true,true,true,true,false,'2025-04-23 21:52:38.000','2025-02-28 01:58:35.000','USER_ACCOUNT','2025-02-11 22:54:32.000',0,0),
@@ -5,16 +6,42 @@ INSERT INTO users (username,"password",email,firstname,lastname,is_enabled,is_ac
 
 
 -- Description: SQL script to import initial role data into the database.
-INSERT INTO roles ("name") VALUES
-	 ('ROLE_USER'),
-	 ('ROLE_MODERATOR'),
-	 ('ROLE_ADMIN');
+INSERT INTO roles ("name",description,is_default) VALUES
+	 ('ROLE_USER','Read access to the consumers',true),
+	 ('ROLE_MODERATOR','Moderation of the consumers',false),
+	 ('ROLE_ADMIN','Full access to the consumers and the users',false),
+	 ('ROLE_SUPER_ADMIN','Full access to the users of every tenant',false);
 
 -- Description: SQL script to import initial user-role mapping data into the database.
 INSERT INTO user_roles (user_id,role_id) VALUES
 	 (1,3),
 	 (2,1);
 
+-- Description: SQL script to import initial permission data into the database.
+INSERT INTO permissions ("name",description) VALUES
+	 ('consumers:read','Read the consumers'),
+	 ('consumers:write','Create and update the consumers'),
+	 ('consumers:moderate','Change the status of the consumers'),
+	 ('users:read','Read the users'),
+	 ('users:write','Create, update and delete the users'),
+	 ('tenants:manage','Manage the users of every tenant'),
+	 ('users:impersonate','Act as a user with a short-lived access token');
+
+-- Description: SQL script to import initial role-permission mapping data into the database.
+INSERT INTO role_permissions (role_id,permission_id) VALUES
+	 (1,1),
+	 (2,1),
+	 (2,3),
+	 (3,1),
+	 (3,2),
+	 (3,3),
+	 (3,4),
+	 (3,5),
+	 (3,7),
+	 (4,4),
+	 (4,5),
+	 (4,6);
+
 -- Description: SQL script to import initial consumer data into the database.
 INSERT INTO consumers (
 	id, fullname, username, email, phone, address, birth_date, status
@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"time"
+)
+
+// AuditLog represents an administrative action recorded in the database, e.g. the update of a user by an admin.
+// The entries are indexed by actor, by entity and by date, the filters of the audit log retrieval.
+type AuditLog struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	TenantID   int64     `gorm:"not null;default:1;index" json:"tenantId"`
+	ActorID    *int64    `gorm:"column:actor_id;index" json:"actorId,omitempty"`
+	Actor      string    `gorm:"type:varchar(100);not null;index" json:"actor"`
+	Action     string    `gorm:"type:varchar(50);not null;index" json:"action"`
+	EntityType string    `gorm:"type:varchar(50);not null;index:idx_audit_logs_entity" json:"entityType"`
+	EntityID   string    `gorm:"type:varchar(100);index:idx_audit_logs_entity" json:"entityId"`
+	Details    *string   `gorm:"type:text" json:"details,omitempty"`
+	CreatedAt  time.Time `gorm:"type:timestamptz;not null;index" json:"createdAt"`
+}
+
+// AuditLogFilter represents the filters applied when retrieving the audit log.
+// The empty and nil fields are not applied.
+type AuditLogFilter struct {
+	Actor      string
+	EntityType string
+	EntityID   string
+	Action     string
+	From       *time.Time
+	To         *time.Time
+	Page       int
+	Limit      int
+}
+
+// TableName override the table name used by AuditLog to `audit_logs`.
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
@@ -7,17 +7,52 @@ import (
 )
 
 // LoginRequest represents the request payload for user login.
+// The username is unique per tenant, the default tenant is used when no tenant is provided.
+// The client address and user agent are not part of the payload, they are set by the handler for the new session.
 type LoginRequest struct {
-	Username string `json:"username" validate:"required,min=3,max=20"`
-	Password string `json:"password" validate:"required,min=8,max=20"`
+	TenantID  *int64 `json:"tenantId" validate:"omitempty,min=1"`
+	Username  string `json:"username" validate:"required,min=3,max=20"`
+	Password  string `json:"password" validate:"required,min=8,max=20"`
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// ChangePasswordRequest represents the request payload for a user changing their own password with the current one.
+// It does not need a session, so a user whose credentials are expired can change the password before logging in.
+type ChangePasswordRequest struct {
+	TenantID    *int64 `json:"tenantId" validate:"omitempty,min=1"`
+	Username    string `json:"username" validate:"required,min=3,max=20"`
+	OldPassword string `json:"oldPassword" validate:"required,min=8,max=72,password"`
+	NewPassword string `json:"newPassword" validate:"required,min=8,max=72,password"`
 }
 
 // LoginResponse represents the response payload for user login.
+// The profile of the user is included unless the minimal login response is configured.
 type LoginResponse struct {
+	AccessToken    string       `json:"accessToken"`
+	RefreshToken   string       `json:"refreshToken"`
+	ExpirationDate string       `json:"expirationDate"`
+	TokenType      string       `json:"tokenType"`
+	User           *UserProfile `json:"user,omitempty"`
+}
+
+// ImpersonationResponse represents the response payload of an admin impersonating a user.
+// The access token is issued for the user and carries the ID of the admin in its impersonator claim.
+// It expires sooner than the tokens issued at login, and comes without a refresh token.
+type ImpersonationResponse struct {
 	AccessToken    string `json:"accessToken"`
-	RefreshToken   string `json:"refreshToken"`
 	ExpirationDate string `json:"expirationDate"`
 	TokenType      string `json:"tokenType"`
+	ImpersonatorID int64  `json:"impersonatorId"`
+}
+
+// UserProfile represents the profile of the logged in user, so the clients do not need a follow-up request.
+// It only carries the identity of the user and the names of its roles.
+type UserProfile struct {
+	ID       int64    `json:"id"`
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Roles    []string `json:"roles"`
 }
 
 // Validate validates the LoginRequest struct using the validator package.
@@ -30,3 +65,13 @@ func (a *LoginRequest) Validate() error {
 	}
 	return nil
 }
+
+// Validate validates the ChangePasswordRequest struct using the validator package.
+func (r *ChangePasswordRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"gopkg.in/go-playground/validator.v9"
 
 	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/customtype"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
 )
 
 const (
@@ -14,6 +15,13 @@ const (
 	ConsumerStatusSuspended = "suspended"
 )
 
+// ConsumerFields lists the JSON fields of a consumer that can be requested
+// through the `fields` query parameter (partial response).
+var ConsumerFields = []string{
+	"id", "fullname", "username", "email", "phone", "address",
+	"birthDate", "status", "createdAt", "updatedAt",
+}
+
 // Consumer represents the consumer entity in the database.
 type Consumer struct {
 	ID        string           `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
@@ -59,7 +67,7 @@ func (c *Consumer) Equals(other *Consumer) bool {
 
 // Validate validates the Consumer struct using the validator package.
 func (c *Consumer) Validate() error {
-	var v *validator.Validate = validator.New()
+	var v *validator.Validate = validation.GetValidator()
 
 	if err := v.Struct(c); err != nil {
 		return err
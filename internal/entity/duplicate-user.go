@@ -0,0 +1,20 @@
+package entity
+
+const (
+	// DuplicateReasonEmail groups the users whose email addresses reach the same inbox once normalized
+	DuplicateReasonEmail = "email"
+
+	// DuplicateReasonName groups the users having the same first and last names and similar usernames
+	DuplicateReasonName = "name"
+)
+
+// DuplicateUserCluster represents a group of users of a tenant that are likely the same person.
+// The key is the normalized email address or the lowercased full name shared by the users, depending on the reason,
+// and the confidence, from 0 to 1, tells how likely the users are duplicates.
+type DuplicateUserCluster struct {
+	TenantID   int64          `gorm:"column:tenant_id" json:"tenantId"`
+	Reason     string         `gorm:"column:reason" json:"reason"`
+	Key        string         `gorm:"column:cluster_key" json:"key"`
+	Confidence float64        `gorm:"column:confidence" json:"confidence"`
+	Users      []UserResponse `gorm:"-" json:"users"`
+}
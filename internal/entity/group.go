@@ -0,0 +1,109 @@
+package entity
+
+import (
+	"time"
+
+	"gopkg.in/go-playground/validator.v9"
+
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+const (
+	// GroupRoleOwner, GroupRoleManager and GroupRoleMember are the roles of a member within its group.
+	// They are told to the downstream services along with the membership, which decide what each role may do.
+	GroupRoleOwner   = "owner"
+	GroupRoleManager = "manager"
+	GroupRoleMember  = "member"
+)
+
+// Group represents a team or a department of a tenant in the database, e.g. "Support EMEA".
+// The users are added to the groups as members, with a role within the group, and their access tokens carry
+// the IDs of their groups so the downstream services can authorize by group. The names are unique within a tenant.
+type Group struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TenantID    int64      `gorm:"not null;default:1;uniqueIndex:idx_groups_tenant_name" json:"tenantId"`
+	Name        string     `gorm:"type:varchar(100);not null;uniqueIndex:idx_groups_tenant_name" json:"name"`
+	Description *string    `gorm:"type:varchar(500)" json:"description"`
+	CreatedBy   *int64     `json:"createdBy"`
+	CreatedAt   *time.Time `gorm:"type:timestamptz;autoCreateTime;default:now()" json:"createdAt"`
+	UpdatedBy   *int64     `json:"updatedBy"`
+	UpdatedAt   *time.Time `gorm:"type:timestamptz;autoUpdateTime;default:now()" json:"updatedAt"`
+}
+
+// GroupMembership represents the membership of a user in a group of its tenant, along with its role within the group.
+// The memberships are removed along with their group or their user.
+type GroupMembership struct {
+	GroupID   int64  `gorm:"primaryKey;not null"`
+	Group     *Group `gorm:"foreignKey:GroupID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	UserID    int64  `gorm:"primaryKey;not null;index"`
+	User      *User  `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Role      string `gorm:"type:varchar(20);not null;default:'member';check:role IN ('owner','manager','member')"`
+	CreatedBy *int64
+	CreatedAt time.Time `gorm:"type:timestamptz;not null;autoCreateTime"`
+}
+
+// GroupRequest represents the request payload for creating or updating a group.
+type GroupRequest struct {
+	Name        string  `json:"name" validate:"required,max=100"`
+	Description *string `json:"description" validate:"omitempty,max=500"`
+}
+
+// GroupMemberRequest represents the request payload for adding a user to a group, as a member unless another role is given.
+type GroupMemberRequest struct {
+	UserID int64  `json:"userId" validate:"required,min=1"`
+	Role   string `json:"role" validate:"omitempty,oneof=owner manager member"`
+}
+
+// GroupMemberRoleRequest represents the request payload for changing the role of a member within its group.
+type GroupMemberRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner manager member"`
+}
+
+// GroupMemberResponse represents a member of a group returned by the API, the user along with its role within the group.
+type GroupMemberResponse struct {
+	User     UserResponse `json:"user"`
+	Role     string       `json:"role"`
+	JoinedAt time.Time    `json:"joinedAt"`
+}
+
+// Override the TableName method to specify the table name
+// in the database. This is optional if you want to use the default naming convention.
+func (Group) TableName() string {
+	return "groups"
+}
+
+// Override the TableName method to specify the table name
+// in the database. This is optional if you want to use the default naming convention.
+func (GroupMembership) TableName() string {
+	return "group_memberships"
+}
+
+// Validate validates the GroupRequest struct using the validator package.
+func (r *GroupRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate validates the GroupMemberRequest struct using the validator package.
+func (r *GroupMemberRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate validates the GroupMemberRoleRequest struct using the validator package.
+func (r *GroupMemberRoleRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+)
+
+// LoginAttempt represents an authentication attempt, successful or not, in the database.
+// The attempted username is always recorded with its tenant, the user ID only when the username matches an existing user.
+type LoginAttempt struct {
+	ID            int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID        *int64    `gorm:"column:user_id;index" json:"userId,omitempty"`
+	Username      string    `gorm:"type:varchar(100);not null;index" json:"username"`
+	Success       bool      `gorm:"not null" json:"success"`
+	FailureReason *string   `gorm:"type:varchar(255)" json:"failureReason,omitempty"`
+	IPAddress     string    `gorm:"column:ip_address;type:varchar(45)" json:"ipAddress"`
+	UserAgent     string    `gorm:"type:varchar(255)" json:"userAgent"`
+	AttemptedAt   time.Time `gorm:"type:timestamptz;not null;index" json:"attemptedAt"`
+	TenantID      int64     `gorm:"not null;default:1;index" json:"tenantId"`
+}
+
+// LoginAttemptFilter represents the filters applied when retrieving the login history.
+// The nil fields are not applied.
+type LoginAttemptFilter struct {
+	From    *time.Time
+	To      *time.Time
+	Success *bool
+	Page    int
+	Limit   int
+}
+
+// TableName override the table name used by LoginAttempt to `login_attempts`.
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
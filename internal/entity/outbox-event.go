@@ -0,0 +1,81 @@
+package entity
+
+import (
+	"time"
+)
+
+const (
+	// OutboxEventAccountDeactivated is emitted when a user deactivates their account
+	OutboxEventAccountDeactivated = "account.deactivated"
+
+	// OutboxEventAccountDeletionReminder is emitted a few days before a deactivated account is deleted
+	OutboxEventAccountDeletionReminder = "account.deletion_reminder"
+
+	// OutboxEventAccountDeleted is emitted when a deactivated account has been anonymized
+	OutboxEventAccountDeleted = "account.deleted"
+
+	// OutboxEventUserMerged is emitted when a duplicate user has been merged into another one
+	OutboxEventUserMerged = "user.merged"
+
+	// OutboxEventCredentialsExpired is emitted when an admin expired the credentials of a user,
+	// who must change the password before logging in again
+	OutboxEventCredentialsExpired = "user.credentials_expired"
+
+	// OutboxEventGroupMemberAdded, OutboxEventGroupMemberUpdated and OutboxEventGroupMemberRemoved are emitted
+	// when a user is added to a group, changes role within it or is removed from it,
+	// so the downstream services authorizing by group are told before the tokens of the user are renewed
+	OutboxEventGroupMemberAdded   = "group.member_added"
+	OutboxEventGroupMemberUpdated = "group.member_updated"
+	OutboxEventGroupMemberRemoved = "group.member_removed"
+
+	// OutboxEventGroupDeleted is emitted when a group has been deleted, along with the members it had
+	OutboxEventGroupDeleted = "group.deleted"
+)
+
+// OutboxEvent represents an event recorded in the same transaction as the change it describes,
+// e.g. the deactivation of an account. A relay publishes the pending events (e.g. as emails to the user)
+// and sets their PublishedAt, so an event is never lost nor sent for a change that was rolled back.
+type OutboxEvent struct {
+	ID            int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TenantID      int64      `gorm:"not null;default:1;index" json:"tenantId"`
+	Type          string     `gorm:"type:varchar(100);not null;index" json:"type"`
+	AggregateType string     `gorm:"type:varchar(50);not null" json:"aggregateType"`
+	AggregateID   string     `gorm:"type:varchar(100);not null" json:"aggregateId"`
+	Payload       string     `gorm:"type:jsonb;not null;default:'{}'" json:"payload"`
+	CreatedAt     time.Time  `gorm:"type:timestamptz;not null;autoCreateTime" json:"createdAt"`
+	PublishedAt   *time.Time `gorm:"type:timestamptz;index" json:"publishedAt,omitempty"`
+}
+
+// AccountEventPayload is the payload of the account events, it holds what the email to the user needs.
+// The deletion event keeps the address the account had before it was anonymized.
+type AccountEventPayload struct {
+	UserID    int64      `json:"userId"`
+	Username  string     `json:"username"`
+	Email     string     `json:"email"`
+	Firstname string     `json:"firstName"`
+	DeleteAt  *time.Time `json:"deleteAt,omitempty"`
+}
+
+// UserMergedPayload is the payload of the user.merged event, it identifies the merged user by its former credentials.
+type UserMergedPayload struct {
+	TargetID       int64    `json:"targetId"`
+	SourceID       int64    `json:"sourceId"`
+	SourceUsername string   `json:"sourceUsername"`
+	SourceEmail    string   `json:"sourceEmail"`
+	AddedRoles     []string `json:"addedRoles"`
+}
+
+// GroupEventPayload is the payload of the group events, it identifies the group and the member concerned.
+// The deletion event lists the users that were still members of the deleted group instead.
+type GroupEventPayload struct {
+	GroupID        int64   `json:"groupId"`
+	GroupName      string  `json:"groupName"`
+	UserID         *int64  `json:"userId,omitempty"`
+	Role           string  `json:"role,omitempty"`
+	RemovedMembers []int64 `json:"removedMembers,omitempty"`
+}
+
+// TableName override the table name used by OutboxEvent to `outbox_events`.
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"time"
+
+	"gopkg.in/go-playground/validator.v9"
+
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// PasswordHistory represents a password previously set for a user in the database, stored as its bcrypt hash.
+// Only the most recent passwords of every user are kept, to prevent their reuse.
+type PasswordHistory struct {
+	ID           int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       int64     `gorm:"not null;index" json:"userId"`
+	User         *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	PasswordHash string    `gorm:"type:varchar(150);not null" json:"-"`
+	CreatedAt    time.Time `gorm:"type:timestamptz;not null;autoCreateTime" json:"createdAt"`
+}
+
+// UserPasswordRequest represents the request payload for resetting the password of a user.
+// bcrypt ignores the bytes beyond 72, so longer passwords are refused.
+type UserPasswordRequest struct {
+	Password string `json:"password" validate:"required,min=8,max=72,password"`
+}
+
+// Override the TableName method to specify the table name
+// in the database. This is optional if you want to use the default naming convention.
+func (PasswordHistory) TableName() string {
+	return "password_history"
+}
+
+// Validate validates the UserPasswordRequest struct using the validator package.
+func (r *UserPasswordRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package entity
+
+// Permission represents a permission entity in the database, a fine-grained right granted through the roles,
+// e.g. `consumers:read`. The names are in lowercase, the resource and the action separated by a colon.
+type Permission struct {
+	ID          uint    `gorm:"primaryKey;autoIncrement" json:"permissionId"`
+	Name        string  `gorm:"type:varchar(100);not null;uniqueIndex" json:"permissionName"`
+	Description *string `gorm:"type:varchar(255)" json:"description"`
+}
+
+// RolePermission represents the grant of a permission to a role, the many-to-many relationship between roles and permissions.
+// A user holds the permissions of the roles it holds, as long as their assignment has not expired.
+type RolePermission struct {
+	RoleID       uint `gorm:"primaryKey;not null"`
+	PermissionID uint `gorm:"primaryKey;not null;index"`
+}
+
+// UserPermissionsResponse represents the effective permissions of a user, the union of the permissions of its roles.
+type UserPermissionsResponse struct {
+	UserID      int64    `json:"userId"`
+	Permissions []string `json:"permissions"`
+}
+
+// Override the TableName method to specify the table name
+// in the database. This is optional if you want to use the default naming convention.
+func (Permission) TableName() string {
+	return "permissions"
+}
+
+// Override the TableName method to specify the table name
+// in the database. This is optional if you want to use the default naming convention.
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The statuses of a check of the preflight
+const (
+	PreflightPassed  = "PASSED"
+	PreflightFailed  = "FAILED"
+	PreflightSkipped = "SKIPPED"
+)
+
+// PreflightCheck represents the result of a check of the preflight, e.g. the existence of the tables of the migration.
+// The detail tells what was checked when it passed, and what is wrong or why it was skipped otherwise.
+type PreflightCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// PreflightReport represents the result of the preflight run at startup and by GET /admin/selfcheck.
+// It passed when every check passed, a check skipped because another one failed fails the preflight as well.
+type PreflightReport struct {
+	Passed    bool             `json:"passed"`
+	Checks    []PreflightCheck `json:"checks"`
+	CheckedAt time.Time        `json:"checkedAt"`
+}
+
+// Failed returns the checks of the report that did not pass.
+func (r PreflightReport) Failed() []PreflightCheck {
+	var failed []PreflightCheck
+	for _, check := range r.Checks {
+		if check.Status != PreflightPassed {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// String returns the report printed at startup, a line per check, e.g.
+//
+//	Preflight FAILED (1 of 5 checks did not pass)
+//	  [PASSED]  database: the database answers
+//	  [FAILED]  jwt_keys: the public key does not match the private key
+func (r PreflightReport) String() string {
+	var b strings.Builder
+	if r.Passed {
+		fmt.Fprintf(&b, "Preflight %s (%d checks)", PreflightPassed, len(r.Checks))
+	} else {
+		fmt.Fprintf(&b, "Preflight %s (%d of %d checks did not pass)", PreflightFailed, len(r.Failed()), len(r.Checks))
+	}
+	for _, check := range r.Checks {
+		fmt.Fprintf(&b, "\n  %-9s %s: %s", "["+check.Status+"]", check.Name, check.Detail)
+	}
+	return b.String()
+}
@@ -9,11 +9,26 @@ import (
 )
 
 // RefreshToken represents the refresh token entity in the database.
+// Each refresh token is an active session of the user, a user can have several sessions at a time.
+// The session ID, the client and the creation time of the session are kept when its refresh token is rotated.
 type RefreshToken struct {
 	Token      string    `gorm:"column:token;type:text;primaryKey;unique;not null" json:"token" validate:"required"`
-	UserID     int64     `gorm:"column:user_id;primaryKey;unique;not null" json:"userId" validate:"required"`
-	User       *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"user,omitempty"`
+	SessionID  string    `gorm:"column:session_id;type:varchar(36);not null;index" json:"sessionId"`
+	UserID     int64     `gorm:"column:user_id;not null;index" json:"userId" validate:"required"`
+	User       *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user,omitempty"`
+	IPAddress  string    `gorm:"column:ip_address;type:varchar(45)" json:"ipAddress"`
+	UserAgent  string    `gorm:"column:user_agent;type:varchar(255)" json:"userAgent"`
 	ExpiryDate time.Time `gorm:"column:expiry_date;type:timestamptz;not null" json:"expiryDate" validate:"required"`
+	CreatedAt  time.Time `gorm:"column:created_at;type:timestamptz;not null;autoCreateTime" json:"createdAt"`
+}
+
+// SessionResponse represents an active session of a user, without its refresh token.
+type SessionResponse struct {
+	ID        string    `json:"id"`
+	IPAddress string    `json:"ipAddress"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
 // RefreshTokenRequest represents the request payload for refreshing a token.
@@ -36,6 +51,17 @@ func (RefreshToken) TableName() string {
 	return "refresh_token"
 }
 
+// ToSession converts the refresh token to the session it belongs to.
+func (r *RefreshToken) ToSession() SessionResponse {
+	return SessionResponse{
+		ID:        r.SessionID,
+		IPAddress: r.IPAddress,
+		UserAgent: r.UserAgent,
+		CreatedAt: r.CreatedAt,
+		ExpiresAt: r.ExpiryDate,
+	}
+}
+
 // Equals compares two RefreshToken objects for equality.
 func (r *RefreshToken) Equals(other *RefreshToken) bool {
 	if r == nil && other == nil {
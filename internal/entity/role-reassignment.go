@@ -0,0 +1,71 @@
+package entity
+
+import (
+	"time"
+
+	"gopkg.in/go-playground/validator.v9"
+
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+const (
+	// RoleReassignmentRunning is the status of a reassignment in progress, or interrupted and resumed by the next request
+	RoleReassignmentRunning = "RUNNING"
+
+	// RoleReassignmentCompleted is the status of a reassignment that went through every holder of the role
+	RoleReassignmentCompleted = "COMPLETED"
+)
+
+// RoleReassignment represents the progress of the move of the holders of a role to another one, within a tenant.
+// The holders are processed by ID, batch by batch, and the progress is saved along with every batch:
+// LastUserID is the greatest ID processed, so an interrupted reassignment resumes after it.
+type RoleReassignment struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TenantID    int64      `gorm:"not null;default:1;index:idx_role_reassignments_roles" json:"tenantId"`
+	FromRoleID  uint       `gorm:"not null;index:idx_role_reassignments_roles" json:"fromRoleId"`
+	ToRoleID    uint       `gorm:"not null;index:idx_role_reassignments_roles" json:"toRoleId"`
+	Status      string     `gorm:"type:varchar(20);not null;check:status IN ('RUNNING','COMPLETED')" json:"status"`
+	LastUserID  int64      `gorm:"not null;default:0" json:"lastUserId"`
+	Reassigned  int        `gorm:"not null;default:0" json:"reassigned"`
+	Skipped     int        `gorm:"not null;default:0" json:"skipped"`
+	CreatedBy   *int64     `json:"createdBy"`
+	CreatedAt   time.Time  `gorm:"type:timestamptz;not null;autoCreateTime" json:"createdAt"`
+	UpdatedAt   time.Time  `gorm:"type:timestamptz;not null;autoUpdateTime" json:"updatedAt"`
+	CompletedAt *time.Time `gorm:"type:timestamptz" json:"completedAt"`
+}
+
+// RoleReassignmentRequest represents the request payload for moving the holders of a role to another one,
+// e.g. when a role is renamed or split. With DryRun, the holders are counted without being changed.
+type RoleReassignmentRequest struct {
+	From   string `json:"from" validate:"required,rolename"`
+	To     string `json:"to" validate:"required,rolename"`
+	DryRun bool   `json:"dryRun"`
+}
+
+// RoleReassignmentResult reports the reassignment of the holders of a role, or what it would do with DryRun.
+// The holders already holding the target role are skipped: they keep both roles and are not counted as reassigned.
+// A resumed reassignment reports the users processed before the interruption as well.
+type RoleReassignmentResult struct {
+	ID              int64  `json:"id,omitempty"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	DryRun          bool   `json:"dryRun"`
+	Resumed         bool   `json:"resumed"`
+	ReassignedUsers int    `json:"reassignedUsers"`
+	SkippedUsers    int    `json:"skippedUsers"`
+}
+
+// TableName override the table name used by RoleReassignment to `role_reassignments`.
+func (RoleReassignment) TableName() string {
+	return "role_reassignments"
+}
+
+// Validate validates the RoleReassignmentRequest struct using the validator package.
+func (r *RoleReassignmentRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,21 +1,36 @@
 package entity
 
 import (
+	"time"
+
 	"gopkg.in/go-playground/validator.v9"
 
 	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
 )
 
 // Role represents the role entity in the database.
+// The default roles are attached to the new users created without any role.
+// ROLE_SUPER_ADMIN may operate on any tenant, it is granted along with ROLE_ADMIN.
+// The names follow the ROLE_<NAME> convention in uppercase, they are normalized before being validated.
+// ExpiresAt is the end of the assignment of the role, it is only set on the roles of a user which are granted
+// until a given time, e.g. to a contractor until the end of the contract.
 type Role struct {
-	ID   uint   `gorm:"primaryKey;autoIncrement" json:"roleId"`
-	Name string `gorm:"type:varchar(20);not null;check:name IN ('ROLE_USER','ROLE_MODERATOR','ROLE_ADMIN')" json:"roleName" validate:"required,max=20,oneof=ROLE_USER ROLE_MODERATOR ROLE_ADMIN"`
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"roleId"`
+	Name        string     `gorm:"type:varchar(20);not null;check:name IN ('ROLE_USER','ROLE_MODERATOR','ROLE_ADMIN','ROLE_SUPER_ADMIN')" json:"roleName" validate:"required,max=20,rolename"`
+	Description *string    `gorm:"type:varchar(100)" json:"description" validate:"omitempty,max=100"`
+	IsDefault   bool       `gorm:"not null;default:false" json:"isDefault"`
+	ExpiresAt   *time.Time `gorm:"-" json:"expiresAt"`
 }
 
-// UserRole represents the many-to-many relationship between users and roles.
+// UserRole represents the assignment of a role to a user, the many-to-many relationship between users and roles.
+// An assignment with an ExpiresAt no longer grants the role once that time has passed, the user is loaded without it.
+// Its row is kept for a while after, then removed by the account janitor.
+// The foreign keys are those of the many-to-many relationship, the Role relationship does not add any.
 type UserRole struct {
-	UserID int64 `gorm:"primaryKey;not null"`
-	RoleID int   `gorm:"primaryKey;not null"`
+	UserID    int64      `gorm:"primaryKey;not null"`
+	RoleID    uint       `gorm:"primaryKey;not null"`
+	ExpiresAt *time.Time `gorm:"type:timestamptz;index"`
+	Role      *Role      `gorm:"foreignKey:RoleID;references:ID;constraint:-"`
 }
 
 // Override the TableName method to specify the table name
@@ -30,9 +45,16 @@ func (UserRole) TableName() string {
 	return "user_roles"
 }
 
-// Validate validates the Role struct using the validator package.
+// Expired reports whether the assignment has expired at the given time, a permanent assignment never expires.
+func (ur *UserRole) Expired(now time.Time) bool {
+	return ur.ExpiresAt != nil && !ur.ExpiresAt.After(now)
+}
+
+// Validate normalizes the name of the role and validates the Role struct using the validator package.
 // It checks if the struct fields meet the specified validation rules.
 func (r *Role) Validate() error {
+	r.Name = validation.NormalizeRoleName(r.Name)
+
 	var v *validator.Validate = validation.GetValidator()
 
 	if err := v.Struct(r); err != nil {
@@ -52,7 +74,8 @@ func (r *Role) Equals(other *Role) bool {
 	}
 
 	if (r.ID != other.ID) ||
-		(r.Name != other.Name) {
+		(r.Name != other.Name) ||
+		(r.IsDefault != other.IsDefault) {
 		return false
 	}
 
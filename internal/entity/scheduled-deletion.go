@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"time"
+)
+
+// ScheduledDeletion represents the deletion of a self-deactivated account, planned at the end of its grace period.
+// The janitor sends a reminder ahead of the deletion and then anonymizes the user, the row is removed once it is done
+// or when the user reactivates the account.
+type ScheduledDeletion struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TenantID       int64      `gorm:"not null;default:1;index" json:"tenantId"`
+	UserID         int64      `gorm:"not null;uniqueIndex" json:"userId"`
+	User           *User      `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	DeleteAt       time.Time  `gorm:"type:timestamptz;not null;index" json:"deleteAt"`
+	ReminderSentAt *time.Time `gorm:"type:timestamptz" json:"reminderSentAt,omitempty"`
+	CreatedAt      time.Time  `gorm:"type:timestamptz;not null;autoCreateTime" json:"createdAt"`
+}
+
+// ScheduledDeletionRun reports the work done by one run of the janitor processing the scheduled deletions.
+type ScheduledDeletionRun struct {
+	Reminded int `json:"reminded"`
+	Deleted  int `json:"deleted"`
+}
+
+// TableName override the table name used by ScheduledDeletion to `scheduled_deletions`.
+func (ScheduledDeletion) TableName() string {
+	return "scheduled_deletions"
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+)
+
+// Tenant represents a customer organization in the database.
+// The users belong to one tenant, and the queries on the users are restricted to the tenant of the request.
+type Tenant struct {
+	ID        int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string     `gorm:"type:varchar(100);not null;unique" json:"name"`
+	CreatedAt *time.Time `gorm:"type:timestamptz;autoCreateTime;default:now()" json:"createdAt,omitempty"`
+}
+
+// UserTenant represents the membership of a user in a tenant other than its own.
+// A member can operate on the tenant by sending its ID in the X-Tenant-ID header.
+type UserTenant struct {
+	UserID   int64   `gorm:"primaryKey;not null"`
+	TenantID int64   `gorm:"primaryKey;not null"`
+	User     *User   `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user,omitempty"`
+	Tenant   *Tenant `gorm:"foreignKey:TenantID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"tenant,omitempty"`
+}
+
+// Override the TableName method to specify the table name
+// in the database. This is optional if you want to use the default naming convention.
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+// Override the TableName method to specify the table name
+// in the database. This is optional if you want to use the default naming convention.
+func (UserTenant) TableName() string {
+	return "user_tenants"
+}
@@ -0,0 +1,134 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	// MaxUserMetadataEntries is the maximum number of metadata entries a user can hold
+	MaxUserMetadataEntries = 20
+
+	// MaxUserMetadataValueLength is the maximum length of a metadata value
+	MaxUserMetadataValueLength = 255
+)
+
+// ErrTooManyUserMetadataEntries is returned when an update would exceed the maximum number of metadata entries
+var ErrTooManyUserMetadataEntries = errors.New("too many metadata entries")
+
+// UserMetadata holds the external identifiers of a user (e.g. CRM ID, HR employee number), keyed by name.
+// It is stored as a jsonb column.
+type UserMetadata map[string]string
+
+// UserMetadataRequest represents the request payload for updating the metadata of a user.
+// The provided keys are set, a null value removes the key, and the omitted keys are left unchanged.
+type UserMetadataRequest struct {
+	Metadata map[string]*string `json:"metadata"`
+}
+
+// UserMetadataChange represents the change of a metadata key, a nil value means the key is absent.
+type UserMetadataChange struct {
+	Key string  `json:"key"`
+	Old *string `json:"old"`
+	New *string `json:"new"`
+}
+
+// Value converts the metadata into its JSON representation for the database.
+func (m UserMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan reads the metadata from its JSON representation in the database.
+func (m *UserMetadata) Scan(value any) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for user metadata: %T", value)
+	}
+
+	return json.Unmarshal(b, m)
+}
+
+// Validate checks that the request is not empty, that every key is in the allowed keys,
+// and that every value is within the maximum length.
+func (r *UserMetadataRequest) Validate(allowedKeys []string) error {
+	if len(r.Metadata) == 0 {
+		return fmt.Errorf("at least one metadata key must be provided")
+	}
+
+	for _, key := range r.sortedKeys() {
+		if !slices.Contains(allowedKeys, key) {
+			return fmt.Errorf("metadata key %q is not allowed", key)
+		}
+		if value := r.Metadata[key]; value != nil && (*value == "" || utf8.RuneCountInString(*value) > MaxUserMetadataValueLength) {
+			return fmt.Errorf("metadata value of %q must be between 1 and %d characters", key, MaxUserMetadataValueLength)
+		}
+	}
+
+	return nil
+}
+
+// ApplyTo applies the request to the metadata of the user and returns the changes, ordered by key.
+// It returns ErrTooManyUserMetadataEntries if the user would hold more than the maximum number of entries.
+func (r *UserMetadataRequest) ApplyTo(u *User) ([]UserMetadataChange, error) {
+	metadata := make(UserMetadata, len(u.Metadata)+len(r.Metadata))
+	for key, value := range u.Metadata {
+		metadata[key] = value
+	}
+
+	var changes []UserMetadataChange
+	for _, key := range r.sortedKeys() {
+		value := r.Metadata[key]
+		old, exists := metadata[key]
+
+		switch {
+		case value == nil && exists:
+			delete(metadata, key)
+			changes = append(changes, UserMetadataChange{Key: key, Old: &old})
+		case value != nil && (!exists || old != *value):
+			metadata[key] = *value
+			change := UserMetadataChange{Key: key, New: value}
+			if exists {
+				change.Old = &old
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	if len(metadata) > MaxUserMetadataEntries {
+		return nil, fmt.Errorf("%w: a user cannot hold more than %d", ErrTooManyUserMetadataEntries, MaxUserMetadataEntries)
+	}
+
+	u.Metadata = metadata
+	return changes, nil
+}
+
+// sortedKeys returns the keys of the request in a stable order.
+func (r *UserMetadataRequest) sortedKeys() []string {
+	keys := make([]string, 0, len(r.Metadata))
+	for key := range r.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"time"
+
+	"gopkg.in/go-playground/validator.v9"
+
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// UserNote represents an internal note of the support on the account of a user in the database,
+// e.g. "verified identity via ticket #1234". The notes are only shown to the admins, never to the user itself.
+// The pinned notes are listed first.
+type UserNote struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int64     `gorm:"not null;index" json:"userId"`
+	User      *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	AuthorID  int64     `gorm:"not null;index" json:"authorId"`
+	Body      string    `gorm:"type:text;not null" json:"body"`
+	Pinned    bool      `gorm:"not null;default:false" json:"pinned"`
+	CreatedAt time.Time `gorm:"type:timestamptz;not null;autoCreateTime" json:"createdAt"`
+}
+
+// UserNoteRequest represents the request payload for adding a note to the account of a user.
+type UserNoteRequest struct {
+	Body   string `json:"body" validate:"required,max=2000"`
+	Pinned bool   `json:"pinned"`
+}
+
+// Override the TableName method to specify the table name
+// in the database. This is optional if you want to use the default naming convention.
+func (UserNote) TableName() string {
+	return "user_notes"
+}
+
+// Validate validates the UserNoteRequest struct using the validator package.
+func (r *UserNoteRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"time"
+)
+
+const (
+	// RegistrationStatusPendingApproval is the status of a registered account waiting for an admin to approve it
+	RegistrationStatusPendingApproval = "pending_approval"
+
+	// RegistrationStatusApproved is the status of a registered account approved by an admin, or not requiring an approval
+	RegistrationStatusApproved = "approved"
+
+	// RegistrationStatusRejected is the status of a registered account rejected by an admin, the account is anonymized
+	RegistrationStatusRejected = "rejected"
+)
+
+// UserRegistration represents the registration of an account through POST /auth/register in the database.
+// It tells the accounts created by the admins from the registered ones, whose email is not verified yet,
+// and holds the approval status of the account along with the admin who decided it.
+type UserRegistration struct {
+	UserID        int64      `gorm:"primaryKey;autoIncrement:false" json:"userId"`
+	TenantID      int64      `gorm:"not null;default:1;index" json:"tenantId"`
+	User          *User      `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Status        string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	EmailVerified bool       `gorm:"not null;default:false" json:"emailVerified"`
+	DecidedBy     *int64     `json:"decidedBy"`
+	DecidedAt     *time.Time `gorm:"type:timestamptz" json:"decidedAt"`
+	CreatedAt     time.Time  `gorm:"type:timestamptz;not null;autoCreateTime" json:"createdAt"`
+}
+
+// PendingRegistrationResponse represents a registered account waiting for an approval in the responses.
+type PendingRegistrationResponse struct {
+	User          UserResponse `json:"user"`
+	EmailVerified bool         `json:"emailVerified"`
+	RegisteredAt  time.Time    `json:"registeredAt"`
+}
+
+// Override the TableName method to specify the table name
+// in the database. This is optional if you want to use the default naming convention.
+func (UserRegistration) TableName() string {
+	return "user_registrations"
+}
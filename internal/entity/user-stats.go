@@ -0,0 +1,12 @@
+package entity
+
+// UserSummary represents the number of users of a tenant in each state, for the admin dashboards.
+// Total, Enabled, Disabled and Locked count the users that are not deleted, Deleted the soft-deleted ones.
+// A locked user is counted as enabled or disabled as well.
+type UserSummary struct {
+	Total    int64 `json:"total"`
+	Enabled  int64 `json:"enabled"`
+	Disabled int64 `json:"disabled"`
+	Locked   int64 `json:"locked"`
+	Deleted  int64 `json:"deleted"`
+}
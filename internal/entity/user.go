@@ -1,6 +1,9 @@
 package entity
 
 import (
+	"cmp"
+	"fmt"
+	"slices"
 	"time"
 
 	"gopkg.in/go-playground/validator.v9"
@@ -10,29 +13,209 @@ import (
 )
 
 // User represents the user entity in the database.
+// The users are soft-deleted, GORM excludes the rows with a DeletedAt from the queries unless Unscoped is used.
+// IsDeleted is kept in sync with DeletedAt for the existing readers of the flag.
+// A user merged into another one is soft-deleted with MergedInto referencing the user it was merged into.
+// The usernames and the emails are unique per tenant among the users that are not deleted, see database.MigrateUserUniqueIndexes.
+// UpdatedAt must be bumped by every write of the user, the lists of the users changed since a time rely on it (see UserFilter):
+// the users are written with the GORM updates, which stamp it, never with UpdateColumn or raw SQL,
+// and a write of the rows returned along with the user, e.g. its roles, touches the user as well.
+// The roles of a user are loaded through its RoleAssignments, which are turned into the Roles of the user by AfterFind.
+// Permissions are the effective permissions of the user, and GroupIDs the IDs of its groups, only loaded to embed them in its access tokens.
+// ImpersonatorID is the ID of the admin acting as the user, only set to issue an impersonation token.
 type User struct {
-	ID                        int64           `gorm:"primaryKey;autoIncrement" json:"id"`
-	Username                  string          `gorm:"type:varchar(20);not null;unique" json:"username" validate:"required,min=3,max=20"`
-	Password                  string          `gorm:"type:varchar(150);not null" json:"password" validate:"required,min=8"`
-	Email                     string          `gorm:"type:varchar(100);not null;unique" json:"email" validate:"required,email,max=100"`
-	Firstname                 string          `gorm:"type:varchar(20);not null" json:"firstName" validate:"required,max=20"`
-	Lastname                  *string         `gorm:"type:varchar(20)" json:"lastName,omitempty" validate:"omitempty,max=20"`
-	IsEnabled                 *bool           `gorm:"not null;default:false" json:"isEnabled,omitempty"`
-	IsAccountNonExpired       *bool           `gorm:"not null;default:false" json:"isAccountNonExpired,omitempty"`
-	IsAccountNonLocked        *bool           `gorm:"not null;default:false" json:"isAccountNonLocked,omitempty"`
-	IsCredentialsNonExpired   *bool           `gorm:"not null;default:false" json:"isCredentialsNonExpired,omitempty"`
-	IsDeleted                 *bool           `gorm:"not null;default:false" json:"isDeleted,omitempty"`
-	AccountExpirationDate     *time.Time      `gorm:"type:timestamptz" json:"accountExpirationDate,omitempty"`
-	CredentialsExpirationDate *time.Time      `gorm:"type:timestamptz" json:"credentialsExpirationDate,omitempty"`
-	UserType                  string          `gorm:"type:varchar(20);not null;check:user_type IN ('SERVICE_ACCOUNT','USER_ACCOUNT')" json:"userType" validate:"required,max=20,oneof=SERVICE_ACCOUNT USER_ACCOUNT"`
-	LastLogin                 *time.Time      `json:"lastLogin,omitempty"`
-	CreatedBy                 *int64          `json:"createdBy,omitempty"`
-	CreatedAt                 *time.Time      `gorm:"type:timestamptz;autoCreateTime;default:now()" json:"createdAt,omitempty"`
-	UpdatedBy                 *int64          `json:"updatedBy,omitempty"`
-	UpdatedAt                 *time.Time      `gorm:"type:timestamptz;autoUpdateTime;default:now()" json:"updatedAt,omitempty"`
-	DeletedBy                 *int64          `json:"deletedBy,omitempty"`
-	DeletedAt                 *gorm.DeletedAt `gorm:"type:timestamptz;index" json:"deletedAt,omitempty"`
-	Roles                     []Role          `gorm:"many2many:user_roles;constraint:OnUpdate:RESTRICT,OnDelete:SET NULL" json:"roles,omitempty"`
+	ID                        int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	TenantID                  int64          `gorm:"not null;default:1" json:"tenantId"`
+	Tenant                    *Tenant        `gorm:"foreignKey:TenantID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Username                  string         `gorm:"type:varchar(20);not null" json:"username" validate:"required,min=3,max=20,username"`
+	Password                  string         `gorm:"type:varchar(150);not null" json:"password" validate:"required,min=8"`
+	Email                     string         `gorm:"type:varchar(100);not null" json:"email" validate:"required,email,max=100"`
+	Firstname                 string         `gorm:"type:varchar(20);not null" json:"firstName" validate:"required,max=20"`
+	Lastname                  *string        `gorm:"type:varchar(20)" json:"lastName,omitempty" validate:"omitempty,max=20"`
+	IsEnabled                 *bool          `gorm:"not null;default:false" json:"isEnabled,omitempty"`
+	IsAccountNonExpired       *bool          `gorm:"not null;default:false" json:"isAccountNonExpired,omitempty"`
+	IsAccountNonLocked        *bool          `gorm:"not null;default:false" json:"isAccountNonLocked,omitempty"`
+	IsCredentialsNonExpired   *bool          `gorm:"not null;default:false" json:"isCredentialsNonExpired,omitempty"`
+	IsDeleted                 *bool          `gorm:"not null;default:false" json:"isDeleted,omitempty"`
+	AccountExpirationDate     *time.Time     `gorm:"type:timestamptz" json:"accountExpirationDate,omitempty"`
+	CredentialsExpirationDate *time.Time     `gorm:"type:timestamptz" json:"credentialsExpirationDate,omitempty"`
+	UserType                  string         `gorm:"type:varchar(20);not null;check:user_type IN ('SERVICE_ACCOUNT','USER_ACCOUNT')" json:"userType" validate:"required,usertype"`
+	LastLogin                 *time.Time     `json:"lastLogin,omitempty"`
+	MaxSessions               *int           `gorm:"column:max_sessions" json:"maxSessions,omitempty"`
+	Metadata                  UserMetadata   `gorm:"type:jsonb;not null;default:'{}';index:idx_users_metadata,type:gin" json:"metadata,omitempty"`
+	CreatedBy                 *int64         `json:"createdBy,omitempty"`
+	CreatedAt                 *time.Time     `gorm:"type:timestamptz;autoCreateTime;default:now()" json:"createdAt,omitempty"`
+	UpdatedBy                 *int64         `json:"updatedBy,omitempty"`
+	UpdatedAt                 *time.Time     `gorm:"type:timestamptz;autoUpdateTime;default:now()" json:"updatedAt,omitempty"`
+	DeletedBy                 *int64         `json:"deletedBy,omitempty"`
+	DeletedAt                 gorm.DeletedAt `gorm:"type:timestamptz;index" json:"deletedAt,omitempty"`
+	MergedInto                *int64         `gorm:"column:merged_into;index" json:"mergedInto,omitempty"`
+	Roles                     []Role         `gorm:"many2many:user_roles;constraint:OnUpdate:RESTRICT,OnDelete:SET NULL" json:"roles,omitempty" validate:"dive"`
+	RoleAssignments           []UserRole     `gorm:"foreignKey:UserID;constraint:-" json:"-"`
+	Permissions               []string       `gorm:"-" json:"-"`
+	GroupIDs                  []int64        `gorm:"-" json:"-"`
+	ImpersonatorID            *int64         `gorm:"-" json:"-"`
+}
+
+// UserFilter represents the filters applied when listing the users, the nil fields are not applied.
+// ModifiedSince is the delta synchronization, the users updated strictly after it ordered by update time.
+// UpdatedSince and CreatedSince are the half-open intervals starting at them, the users updated or created at or after them.
+// The lists of the changes are served by the indexes of database.MigrateUserChangeIndexes.
+// GroupID restricts the list to the members of the group.
+type UserFilter struct {
+	ModifiedSince *time.Time
+	UpdatedSince  *time.Time
+	CreatedSince  *time.Time
+	GroupID       *int64
+}
+
+// UserResponse represents the user returned by the API.
+// It never contains the password of the user.
+// Every field is always present, so the clients may rely on the same shape for every user:
+// the unset optional fields, e.g. lastLogin or deletedBy, are null and the metadata is an empty object without any key.
+// Only the roles are omitted, when they were not loaded along with the user; a loaded user without any role has [] roles.
+// The roles are sorted by name, so the same user is always serialized to the same bytes.
+type UserResponse struct {
+	ID                        int64        `json:"id"`
+	TenantID                  int64        `json:"tenantId"`
+	Username                  string       `json:"username"`
+	Email                     string       `json:"email"`
+	Firstname                 string       `json:"firstName"`
+	Lastname                  *string      `json:"lastName"`
+	IsEnabled                 *bool        `json:"isEnabled"`
+	IsAccountNonExpired       *bool        `json:"isAccountNonExpired"`
+	IsAccountNonLocked        *bool        `json:"isAccountNonLocked"`
+	IsCredentialsNonExpired   *bool        `json:"isCredentialsNonExpired"`
+	IsDeleted                 *bool        `json:"isDeleted"`
+	AccountExpirationDate     *time.Time   `json:"accountExpirationDate"`
+	CredentialsExpirationDate *time.Time   `json:"credentialsExpirationDate"`
+	UserType                  string       `json:"userType"`
+	LastLogin                 *time.Time   `json:"lastLogin"`
+	MaxSessions               *int         `json:"maxSessions"`
+	Metadata                  UserMetadata `json:"metadata"`
+	CreatedBy                 *int64       `json:"createdBy"`
+	CreatedAt                 *time.Time   `json:"createdAt"`
+	UpdatedBy                 *int64       `json:"updatedBy"`
+	UpdatedAt                 *time.Time   `json:"updatedAt"`
+	DeletedBy                 *int64       `json:"deletedBy"`
+	DeletedAt                 *time.Time   `json:"deletedAt"`
+	MergedInto                *int64       `json:"mergedInto"`
+	Roles                     []Role       `json:"roles,omitzero"`
+}
+
+// UserCreateRequest represents the request payload for creating a user.
+// The default roles are attached when no role is provided.
+type UserCreateRequest struct {
+	Username  string   `json:"username" validate:"required,min=3,max=20,username"`
+	Password  string   `json:"password" validate:"required,min=8,max=72,password"`
+	Email     string   `json:"email" validate:"required,email,max=100"`
+	Firstname string   `json:"firstName" validate:"required,max=20"`
+	Lastname  *string  `json:"lastName" validate:"omitempty,max=20"`
+	UserType  string   `json:"userType" validate:"required,usertype"`
+	Roles     []string `json:"roles" validate:"omitempty,max=4,dive,rolename"`
+}
+
+// UserRegisterRequest represents the request payload for registering an account through the self-registration.
+// The registered account always gets the self-registration role, and stays disabled until an admin approves it
+// when the approval is required. The captcha token is only checked for the anonymous registrations, along with
+// the client address, which is not part of the payload but set by the handler.
+type UserRegisterRequest struct {
+	Username     string  `json:"username" validate:"required,min=3,max=20,username"`
+	Password     string  `json:"password" validate:"required,min=8,max=72,password"`
+	Email        string  `json:"email" validate:"required,email,max=100"`
+	Firstname    string  `json:"firstName" validate:"required,max=20"`
+	Lastname     *string `json:"lastName" validate:"omitempty,max=20"`
+	CaptchaToken string  `json:"captchaToken" validate:"omitempty,max=4096"`
+	IPAddress    string  `json:"-"`
+}
+
+// UserStatusRequest represents the request payload for updating the status flags of a user.
+// Only the provided flags are applied, the omitted ones are left unchanged.
+type UserStatusRequest struct {
+	IsEnabled               *bool `json:"isEnabled"`
+	IsAccountNonLocked      *bool `json:"isAccountNonLocked"`
+	IsAccountNonExpired     *bool `json:"isAccountNonExpired"`
+	IsCredentialsNonExpired *bool `json:"isCredentialsNonExpired"`
+}
+
+// UserRolesRequest represents the request payload for replacing the roles of a user.
+// The admin-level roles may only be granted or removed by an admin.
+type UserRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,min=1,max=4,dive,rolename"`
+}
+
+// UserRolesPatchRequest represents the request payload for adding and removing some roles of a user.
+// The roles not listed are left unchanged, a role listed in both Add and Remove is rejected.
+// The added roles are assigned until ExpiresAt, which must be in the future, or permanently without it.
+type UserRolesPatchRequest struct {
+	Add       []string   `json:"add" validate:"required_without=Remove,max=4,dive,rolename"`
+	Remove    []string   `json:"remove" validate:"required_without=Add,max=4,dive,rolename"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// UserSessionLimitRequest represents the request payload for overriding the session limit of a user.
+// A null value removes the override, so the global limit applies again, and 0 means unlimited.
+type UserSessionLimitRequest struct {
+	MaxSessions *int `json:"maxSessions" validate:"omitempty,min=0,max=100"`
+}
+
+// UserBatchGetRequest represents the request payload for retrieving many users by their IDs.
+type UserBatchGetRequest struct {
+	IDs []int64 `json:"ids" validate:"required,min=1,max=100,dive,min=1"`
+}
+
+// UserBulkDeleteRequest represents the request payload for soft-deleting many users by their IDs.
+// The confirmation token must match the number of users to delete, e.g. "DELETE 3 USERS",
+// so a request built by mistake does not delete users.
+type UserBulkDeleteRequest struct {
+	IDs               []int64 `json:"ids" validate:"required,min=1,max=100,unique,dive,min=1"`
+	ConfirmationToken string  `json:"confirmationToken" validate:"required"`
+}
+
+const (
+	// UserBulkDeleteDeleted is the status of a user deleted by a bulk delete
+	UserBulkDeleteDeleted = "DELETED"
+
+	// UserBulkDeleteNotFound is the status of an unknown or already deleted user in a bulk delete
+	UserBulkDeleteNotFound = "NOT_FOUND"
+
+	// UserBulkDeleteSkipped is the status of the user performing the bulk delete, who cannot delete themselves
+	UserBulkDeleteSkipped = "SKIPPED"
+)
+
+// UserBulkDeleteResult represents the outcome of a bulk delete for one user.
+type UserBulkDeleteResult struct {
+	ID     int64   `json:"id"`
+	Status string  `json:"status"`
+	Error  *string `json:"error,omitempty"`
+}
+
+// UserMergeRequest represents the request payload for merging a duplicate user into the target user.
+// With DryRun, nothing is changed and the result reports what the merge would change.
+type UserMergeRequest struct {
+	SourceID int64 `json:"sourceId" validate:"required,min=1"`
+	DryRun   bool  `json:"dryRun"`
+}
+
+// UserMergeResult represents the changes of a merge, or the changes it would make for a dry run.
+// The metadata keys of the source already held by the target keep the value of the target, they are reported as conflicting.
+type UserMergeResult struct {
+	TargetID                int64        `json:"targetId"`
+	SourceID                int64        `json:"sourceId"`
+	DryRun                  bool         `json:"dryRun"`
+	AddedRoles              []string     `json:"addedRoles"`
+	AddedMetadataKeys       []string     `json:"addedMetadataKeys"`
+	ConflictingMetadataKeys []string     `json:"conflictingMetadataKeys"`
+	RevokedSessions         int          `json:"revokedSessions"`
+	Target                  UserResponse `json:"target"`
+}
+
+// CredentialExpiryResult reports the expiration of the credentials of the holders of a role.
+// The holders whose credentials were already expired are left untouched and not counted.
+type CredentialExpiryResult struct {
+	Role            string `json:"role"`
+	AffectedUsers   int    `json:"affectedUsers"`
+	SessionsRevoked bool   `json:"sessionsRevoked"`
 }
 
 // Override the TableName method to specify the table name
@@ -41,6 +224,29 @@ func (User) TableName() string {
 	return "users"
 }
 
+// AfterFind turns the role assignments loaded along with the user into its roles, each with the expiry of its assignment.
+// The assignments are only loaded when they have not expired, see the preloadRoles scope of the user repository.
+// They are cleared afterwards, so a save of the user never writes them back.
+func (u *User) AfterFind(tx *gorm.DB) error {
+	if u.RoleAssignments == nil {
+		return nil
+	}
+
+	roles := make([]Role, 0, len(u.RoleAssignments))
+	for _, assignment := range u.RoleAssignments {
+		if assignment.Role == nil {
+			continue
+		}
+		role := *assignment.Role
+		role.ExpiresAt = assignment.ExpiresAt
+		roles = append(roles, role)
+	}
+
+	u.Roles = roles
+	u.RoleAssignments = nil
+	return nil
+}
+
 // Equals compares two User objects for equality.
 func (u *User) Equals(other *User) bool {
 	if u == nil && other == nil {
@@ -73,8 +279,22 @@ func (u *User) Equals(other *User) bool {
 	return true
 }
 
+func init() {
+	validation.RegisterStructValidation(validateUserRoles, User{})
+}
+
+// validateUserRoles is the struct-level validation of a user, which must hold at least one role,
+// so the rule applies to every user validated, whether it is created or updated.
+func validateUserRoles(sl validator.StructLevel) {
+	user := sl.Current().Interface().(User)
+	if len(user.Roles) == 0 {
+		sl.ReportError(user.Roles, "roles", "Roles", validation.AtLeastOneRoleTag, "")
+	}
+}
+
 // Validate validates the User struct using the validator package.
-// It checks if the struct fields meet the specified validation rules.
+// It checks if the struct fields meet the specified validation rules, those of each of its roles,
+// and that the user holds at least one role. Every failing field is reported at once.
 func (u *User) Validate() error {
 	var v *validator.Validate = validation.GetValidator()
 
@@ -83,3 +303,208 @@ func (u *User) Validate() error {
 	}
 	return nil
 }
+
+// ToResponse converts the User into a UserResponse, without the password.
+func (u *User) ToResponse() UserResponse {
+	return UserResponse{
+		ID:                        u.ID,
+		TenantID:                  u.TenantID,
+		Username:                  u.Username,
+		Email:                     u.Email,
+		Firstname:                 u.Firstname,
+		Lastname:                  u.Lastname,
+		IsEnabled:                 u.IsEnabled,
+		IsAccountNonExpired:       u.IsAccountNonExpired,
+		IsAccountNonLocked:        u.IsAccountNonLocked,
+		IsCredentialsNonExpired:   u.IsCredentialsNonExpired,
+		IsDeleted:                 u.IsDeleted,
+		AccountExpirationDate:     u.AccountExpirationDate,
+		CredentialsExpirationDate: u.CredentialsExpirationDate,
+		UserType:                  u.UserType,
+		LastLogin:                 u.LastLogin,
+		MaxSessions:               u.MaxSessions,
+		Metadata:                  responseMetadata(u.Metadata),
+		CreatedBy:                 u.CreatedBy,
+		CreatedAt:                 u.CreatedAt,
+		UpdatedBy:                 u.UpdatedBy,
+		UpdatedAt:                 u.UpdatedAt,
+		DeletedBy:                 u.DeletedBy,
+		DeletedAt:                 deletedAt(u.DeletedAt),
+		MergedInto:                u.MergedInto,
+		Roles:                     sortedRoles(u.Roles),
+	}
+}
+
+// ToProfile converts the User into the UserProfile returned at login, with the names of its roles sorted.
+func (u *User) ToProfile() UserProfile {
+	roles := make([]string, 0, len(u.Roles))
+	for _, role := range sortedRoles(u.Roles) {
+		roles = append(roles, role.Name)
+	}
+
+	return UserProfile{
+		ID:       u.ID,
+		Username: u.Username,
+		Email:    u.Email,
+		Roles:    roles,
+	}
+}
+
+// sortedRoles returns a copy of the roles sorted by name, whatever the order they were loaded or assigned in.
+// The roles that were not loaded stay nil.
+func sortedRoles(roles []Role) []Role {
+	if roles == nil {
+		return nil
+	}
+	sorted := slices.Clone(roles)
+	slices.SortStableFunc(sorted, func(a, b Role) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return sorted
+}
+
+// responseMetadata returns the metadata of a user for the response, an empty object rather than null without any key.
+func responseMetadata(m UserMetadata) UserMetadata {
+	if m == nil {
+		return UserMetadata{}
+	}
+	return m
+}
+
+// deletedAt returns the deletion timestamp of a soft-deleted row, or nil if the row is not deleted.
+func deletedAt(d gorm.DeletedAt) *time.Time {
+	if !d.Valid {
+		return nil
+	}
+	return &d.Time
+}
+
+// IsEmpty reports whether no flag is provided in the request.
+func (r *UserStatusRequest) IsEmpty() bool {
+	return r.IsEnabled == nil &&
+		r.IsAccountNonLocked == nil &&
+		r.IsAccountNonExpired == nil &&
+		r.IsCredentialsNonExpired == nil
+}
+
+// ApplyTo applies the provided flags to the user, the omitted flags are left unchanged.
+func (r *UserStatusRequest) ApplyTo(u *User) {
+	if r.IsEnabled != nil {
+		u.IsEnabled = boolPtr(*r.IsEnabled)
+	}
+	if r.IsAccountNonLocked != nil {
+		u.IsAccountNonLocked = boolPtr(*r.IsAccountNonLocked)
+	}
+	if r.IsAccountNonExpired != nil {
+		u.IsAccountNonExpired = boolPtr(*r.IsAccountNonExpired)
+	}
+	if r.IsCredentialsNonExpired != nil {
+		u.IsCredentialsNonExpired = boolPtr(*r.IsCredentialsNonExpired)
+	}
+}
+
+// RevokesSessions reports whether the request prevents the user from logging in,
+// in which case the active sessions of the user must be revoked.
+func (r *UserStatusRequest) RevokesSessions() bool {
+	for _, flag := range []*bool{r.IsEnabled, r.IsAccountNonLocked, r.IsAccountNonExpired, r.IsCredentialsNonExpired} {
+		if flag != nil && !*flag {
+			return true
+		}
+	}
+	return false
+}
+
+// boolPtr returns a pointer to a copy of the given bool.
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// Validate validates the UserCreateRequest struct using the validator package.
+func (r *UserCreateRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate validates the UserRegisterRequest struct using the validator package.
+func (r *UserRegisterRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate validates the UserRolesRequest struct using the validator package.
+func (r *UserRolesRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate validates the UserRolesPatchRequest struct using the validator package.
+func (r *UserRolesPatchRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate validates the UserSessionLimitRequest struct using the validator package.
+func (r *UserSessionLimitRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate validates the UserBatchGetRequest struct using the validator package.
+func (r *UserBatchGetRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate validates the UserBulkDeleteRequest struct using the validator package.
+func (r *UserBulkDeleteRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate validates the UserMergeRequest struct using the validator package.
+func (r *UserMergeRequest) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ExpectedConfirmationToken returns the confirmation token matching the users to delete.
+func (r *UserBulkDeleteRequest) ExpectedConfirmationToken() string {
+	return fmt.Sprintf("DELETE %d USERS", len(r.IDs))
+}
+
+// IsConfirmed reports whether the confirmation token matches the users to delete.
+func (r *UserBulkDeleteRequest) IsConfirmed() bool {
+	return r.ConfirmationToken == r.ExpectedConfirmationToken()
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// This struct defines the AccountHandler which handles HTTP requests related to the account of the authenticated user.
+// It contains a service field of type AccountService which is used to interact with the account data layer.
+type AccountHandler struct {
+	Service service.AccountService
+}
+
+// NewAccountHandler creates a new instance of AccountHandler.
+// It initializes the AccountHandler struct with the provided AccountService.
+func NewAccountHandler(accountService service.AccountService) *AccountHandler {
+	return &AccountHandler{Service: accountService}
+}
+
+// DeactivateAccount deactivates the account of the authenticated user and schedules its deletion.
+// @Summary      Deactivate own account
+// @Description  Disable the account of the authenticated user, revoke its sessions and schedule its deletion at the end of the grace period
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  model.HttpResponse for successful deactivation
+// @Failure      401  {object}  model.HttpResponse for unauthorized
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for already deactivated or last enabled admin
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/me/deactivate [post]
+func (h *AccountHandler) DeactivateAccount(c *gin.Context) {
+	deletion, err := h.Service.DeactivateAccount(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+
+		if errors.Is(err, service.ErrAccountAlreadyDeactivated) {
+			httputil.Conflict(c, "Failed to deactivate account", "The account is already scheduled for deletion")
+			return
+		}
+
+		if errors.Is(err, service.ErrLastAdmin) {
+			httputil.Conflict(c, "Failed to deactivate account", "The last enabled admin cannot deactivate their account")
+			return
+		}
+
+		httputil.ServerError(c, "Failed to deactivate account", err)
+		return
+	}
+
+	httputil.Success(c, "Account deactivated successfully", deletion)
+}
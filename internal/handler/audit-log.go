@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// This struct defines the AuditLogHandler which handles HTTP requests related to the audit log.
+// It contains a service field of type AuditLogService which is used to interact with the audit log data layer.
+type AuditLogHandler struct {
+	Service service.AuditLogService
+}
+
+// NewAuditLogHandler creates a new instance of AuditLogHandler.
+// It initializes the AuditLogHandler struct with the provided AuditLogService.
+func NewAuditLogHandler(auditLogService service.AuditLogService) *AuditLogHandler {
+	return &AuditLogHandler{Service: auditLogService}
+}
+
+// GetAuditLogs retrieves the audit log entries matching the filters and returns them as JSON.
+// @Summary      Get audit log
+// @Description  Get the administrative actions recorded in the audit log, the most recent first
+// @Tags         audit
+// @Accept       json
+// @Produce      json
+// @Param        page        query     string  false "Page number (default is 1)"
+// @Param        limit       query     string  false "Number of entries per page (default is 10)"
+// @Param        actor       query     string  false "Only the entries of this actor (username)"
+// @Param        entityType  query     string  false "Only the entries about this type of entity (e.g. user)"
+// @Param        entityId    query     string  false "Only the entries about this entity, along with entityType"
+// @Param        action      query     string  false "Only the entries of this action (e.g. update)"
+// @Param        from        query     string  false "Only the entries at or after this time (RFC3339)"
+// @Param        to          query     string  false "Only the entries at or before this time (RFC3339)"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /audit [get]
+func (h *AuditLogHandler) GetAuditLogs(c *gin.Context) {
+	filter, ok := parseAuditLogFilter(c)
+	if !ok {
+		return
+	}
+
+	auditLogs, total, err := h.Service.GetAuditLogs(c.Request.Context(), filter)
+	if err != nil {
+		httputil.ServerError(c, "Failed to retrieve audit log", err)
+		return
+	}
+
+	// An empty page is a valid result and is returned as an empty array
+	if auditLogs == nil {
+		auditLogs = []entity.AuditLog{}
+	}
+
+	httputil.SuccessWithPagination(c, "Audit log retrieved successfully", auditLogs, httputil.NewPagination(filter.Page, filter.Limit, total))
+}
+
+// parseAuditLogFilter parses the pagination and filter query parameters.
+// It writes a bad request response and returns false if a parameter is invalid.
+func parseAuditLogFilter(c *gin.Context) (entity.AuditLogFilter, bool) {
+	filter := entity.AuditLogFilter{
+		Actor:      c.Query("actor"),
+		EntityType: c.Query("entityType"),
+		EntityID:   c.Query("entityId"),
+		Action:     c.Query("action"),
+	}
+	var err error
+
+	filter.Page, err = strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || filter.Page < 1 {
+		httputil.BadRequest(c, "Invalid page number", "Page must be a positive integer")
+		return filter, false
+	}
+	filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || filter.Limit < 1 {
+		httputil.BadRequest(c, "Invalid limit", "Limit must be a positive integer")
+		return filter, false
+	}
+
+	// An entity ID is only meaningful along with the type of the entity
+	if filter.EntityID != "" && filter.EntityType == "" {
+		httputil.BadRequest(c, "Invalid entity filter", "EntityId requires entityType")
+		return filter, false
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			httputil.BadRequest(c, "Invalid from date", "From must be an RFC3339 timestamp (e.g. 2025-01-01T00:00:00Z)")
+			return filter, false
+		}
+		t = t.UTC()
+		filter.From = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			httputil.BadRequest(c, "Invalid to date", "To must be an RFC3339 timestamp (e.g. 2025-01-31T23:59:59Z)")
+			return filter, false
+		}
+		t = t.UTC()
+		filter.To = &t
+	}
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		httputil.BadRequest(c, "Invalid date range", "From must be before To")
+		return filter, false
+	}
+
+	return filter, true
+}
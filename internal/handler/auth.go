@@ -2,27 +2,36 @@ package handler
 
 import (
 	"errors"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gopkg.in/go-playground/validator.v9"
 	"gorm.io/gorm"
 
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/featureflag"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
 	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
 	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
 )
 
 // This struct defines the AuthHandler which handles HTTP requests related to authentication.
-// It contains a service field of type AuthService which is used to interact with the authentication data layer.
+// It contains a service field of type AuthService which is used to interact with the authentication data layer,
+// and a LoginAttempts field of type LoginAttemptService which records every login attempt.
 type AuthHandler struct {
-	Service service.AuthService
+	Service       service.AuthService
+	LoginAttempts service.LoginAttemptService
 }
 
 // NewAuthHandler creates a new instance of AuthHandler.
-// It initializes the AuthHandler struct with the provided AuthService.
-func NewAuthHandler(authService service.AuthService) *AuthHandler {
-	return &AuthHandler{Service: authService}
+// It initializes the AuthHandler struct with the provided AuthService and LoginAttemptService.
+func NewAuthHandler(authService service.AuthService, loginAttemptService service.LoginAttemptService) *AuthHandler {
+	return &AuthHandler{Service: authService, LoginAttempts: loginAttemptService}
 }
 
 // Login handles user login requests.
@@ -36,39 +45,88 @@ func NewAuthHandler(authService service.AuthService) *AuthHandler {
 // @Success      200  {object}  model.HttpResponse for successful login
 // @Failure      400  {object}  model.HttpResponse for bad request
 // @Failure      401  {object}  model.HttpResponse for unauthorized
+// @Failure      403  {object}  model.HttpResponse for password change required or account deactivated
+// @Failure      409  {object}  model.HttpResponse for session limit reached
+// @Failure      422  {object}  model.HttpResponse for validation failure
 // @Router       /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	// Bind the request body to the LoginRequest struct
 	// This struct contains the username and password fields
 	var loginReq entity.LoginRequest
-	if err := c.ShouldBindJSON(&loginReq); err != nil {
+	if err := httputil.BindJSON(c, &loginReq); err != nil {
 		httputil.BadRequest(c, "Invalid request", err.Error())
 		return
 	}
+	setSessionClient(c, &loginReq)
 
 	// Call the service to authenticate the user and get the token
 	loginResp, err := h.Service.Login(loginReq)
 
+	// Record the attempt in the login history, the recording is asynchronous
+	h.recordLoginAttempt(c, loginReq, err)
+
 	if err != nil {
 		// Check if the error is a validation error
 		var ve validator.ValidationErrors
 		if errors.As(err, &ve) {
-			httputil.BadRequestMap(c, "Failed to login", validation.FormatValidationErrors(err))
+			httputil.UnprocessableEntityMap(c, "Failed to login", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
 			return
 		}
 
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, service.ErrInvalidCredentials) {
 			httputil.Unauthorized(c, "Invalid credentials", "Username or password is incorrect")
 			return
 		}
 
+		if errors.Is(err, service.ErrSessionLimitReached) {
+			httputil.Conflict(c, "Failed to login", "Maximum number of active sessions reached, log out from another device first")
+			return
+		}
+
+		if errors.Is(err, service.ErrPasswordChangeRequired) {
+			httputil.Forbidden(c, "Password change required", "The credentials of the user are expired, change the password with POST /auth/change-password before logging in")
+			return
+		}
+
+		if errors.Is(err, service.ErrAccountDeactivated) {
+			httputil.Forbidden(c, "Account deactivated", err.Error()+", with POST /auth/reactivate")
+			return
+		}
+
+		// The database being unavailable is not a failure of the credentials
+		if errors.Is(err, database.ErrDatabaseUnavailable) {
+			httputil.ServerError(c, "Failed to login", err)
+			return
+		}
+
 		httputil.Unauthorized(c, "Failed to login", err.Error())
 		return
 	}
 
+	// In cookie mode, the access token is also issued in an HttpOnly cookie the browsers send back automatically
+	tenantID := metacontext.DefaultTenantID
+	if loginReq.TenantID != nil {
+		tenantID = *loginReq.TenantID
+	}
+	if featureflag.EnabledForTenant(tenantID, featureflag.CookieAuth) {
+		setAccessTokenCookie(c, loginResp)
+	}
+
 	httputil.Success(c, "Login successful", loginResp)
 }
 
+// setAccessTokenCookie sets the access token cookie, it expires along with the token.
+// The cookie is only sent back over TLS when the login itself arrived over TLS.
+func setAccessTokenCookie(c *gin.Context, loginResp entity.LoginResponse) {
+	maxAge := 0
+	if expiration, err := time.Parse(time.RFC3339, loginResp.ExpirationDate); err == nil {
+		maxAge = int(time.Until(expiration).Seconds())
+	}
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(authorization.AccessTokenCookie, loginResp.AccessToken, maxAge, "/", "", c.Request.TLS != nil, true)
+}
+
 // RefreshToken handles token refresh requests.
 // It validates the request, checks the refresh token, and returns a new JWT token if successful.
 // @Summary      Refresh token
@@ -80,12 +138,14 @@ func (h *AuthHandler) Login(c *gin.Context) {
 // @Success      200  {object}  model.HttpResponse for successful token refresh
 // @Failure      400  {object}  model.HttpResponse for bad request
 // @Failure      401  {object}  model.HttpResponse for unauthorized
+// @Failure      403  {object}  model.HttpResponse for expired credentials
+// @Failure      422  {object}  model.HttpResponse for validation failure
 // @Router       /auth/refresh-token [post]
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	// Bind the request body to the RefreshTokenRequest struct
 	// This struct contains the refresh token field
 	var refreshTokenReq entity.RefreshTokenRequest
-	if err := c.ShouldBindJSON(&refreshTokenReq); err != nil {
+	if err := httputil.BindJSON(c, &refreshTokenReq); err != nil {
 		httputil.BadRequest(c, "Invalid request", err.Error())
 		return
 	}
@@ -97,7 +157,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		// Check if the error is a validation error
 		var ve validator.ValidationErrors
 		if errors.As(err, &ve) {
-			httputil.BadRequestMap(c, "Failed to refresh token", validation.FormatValidationErrors(err))
+			httputil.UnprocessableEntityMap(c, "Failed to refresh token", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
 			return
 		}
 
@@ -106,11 +166,236 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 			return
 		}
 
-		// Handle other errors, such as database connection issues
-		// or query execution errors
+		if errors.Is(err, service.ErrPasswordChangeRequired) {
+			httputil.Forbidden(c, "Password change required", "The credentials of the user are expired, change the password with POST /auth/change-password before refreshing the token")
+			return
+		}
+
+		// The database being unavailable is not a failure of the refresh token
+		if errors.Is(err, database.ErrDatabaseUnavailable) {
+			httputil.ServerError(c, "Failed to refresh token", err)
+			return
+		}
+
+		// Handle other errors, such as query execution errors
 		httputil.Unauthorized(c, "Failed to refresh token", err.Error())
 		return
 	}
 
 	httputil.Success(c, "Token refreshed successfully", refreshTokenResp)
 }
+
+// ReactivateAccount handles the reactivation of an account deactivated by its user, during its grace period.
+// It checks the credentials, cancels the scheduled deletion and logs the user in.
+// @Summary      Reactivate account
+// @Description  Reactivate an account deactivated by its user before its scheduled deletion, and log in
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      entity.LoginRequest  true  "Credentials of the account"
+// @Success      200  {object}  model.HttpResponse for successful reactivation
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      401  {object}  model.HttpResponse for unauthorized
+// @Failure      409  {object}  model.HttpResponse for account not deactivated or grace period over
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Router       /auth/reactivate [post]
+func (h *AuthHandler) ReactivateAccount(c *gin.Context) {
+	var loginReq entity.LoginRequest
+	if err := httputil.BindJSON(c, &loginReq); err != nil {
+		httputil.BadRequest(c, "Invalid request", err.Error())
+		return
+	}
+	setSessionClient(c, &loginReq)
+
+	loginResp, err := h.Service.ReactivateAccount(loginReq)
+
+	// The reactivation logs the user in, it is recorded in the login history like a login
+	h.recordLoginAttempt(c, loginReq, err)
+
+	if err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Failed to reactivate account", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, service.ErrInvalidCredentials) {
+			httputil.Unauthorized(c, "Invalid credentials", "Username or password is incorrect")
+			return
+		}
+
+		if errors.Is(err, service.ErrAccountNotDeactivated) {
+			httputil.Conflict(c, "Failed to reactivate account", "The account is not scheduled for deletion")
+			return
+		}
+
+		if errors.Is(err, service.ErrAccountGracePeriodOver) {
+			httputil.Conflict(c, "Failed to reactivate account", "The grace period of the account is over, it can no longer be reactivated")
+			return
+		}
+
+		// The login following the reactivation may still be refused, e.g. when the credentials are expired
+		if errors.Is(err, service.ErrPasswordChangeRequired) {
+			httputil.Forbidden(c, "Password change required", "The account is reactivated, its password must be changed before logging in")
+			return
+		}
+
+		if errors.Is(err, service.ErrSessionLimitReached) {
+			httputil.Conflict(c, "Failed to login", "Maximum number of active sessions reached, log out from another device first")
+			return
+		}
+
+		if errors.Is(err, database.ErrDatabaseUnavailable) {
+			httputil.ServerError(c, "Failed to reactivate account", err)
+			return
+		}
+
+		httputil.Unauthorized(c, "Failed to reactivate account", err.Error())
+		return
+	}
+
+	tenantID := metacontext.DefaultTenantID
+	if loginReq.TenantID != nil {
+		tenantID = *loginReq.TenantID
+	}
+	if featureflag.EnabledForTenant(tenantID, featureflag.CookieAuth) {
+		setAccessTokenCookie(c, loginResp)
+	}
+
+	httputil.Success(c, "Account reactivated successfully", loginResp)
+}
+
+// ChangePassword handles the change of the password of a user with the current one, without a session.
+// It is how a user whose credentials are expired changes the password before logging in again.
+// @Summary      Change password
+// @Description  Change the password of a user given the current one, it lifts the expiration of the credentials and revokes the sessions
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      entity.ChangePasswordRequest  true  "Current and new passwords of the account"
+// @Success      200  {object}  model.HttpResponse for successful change
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      401  {object}  model.HttpResponse for unauthorized
+// @Failure      422  {object}  model.HttpResponse for validation failure or rejected password
+// @Router       /auth/change-password [post]
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req entity.ChangePasswordRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request", err.Error())
+		return
+	}
+
+	if err := h.Service.ChangePassword(req); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Failed to change password", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, service.ErrInvalidCredentials) {
+			httputil.Unauthorized(c, "Invalid credentials", "Username or password is incorrect")
+			return
+		}
+
+		if errors.Is(err, service.ErrPasswordReused) || errors.Is(err, service.ErrWeakPassword) {
+			httputil.UnprocessableEntity(c, "Invalid password", err.Error())
+			return
+		}
+
+		if errors.Is(err, database.ErrDatabaseUnavailable) {
+			httputil.ServerError(c, "Failed to change password", err)
+			return
+		}
+
+		httputil.Unauthorized(c, "Failed to change password", err.Error())
+		return
+	}
+
+	httputil.Success(c, "Password changed successfully, log in with the new password", nil)
+}
+
+// Impersonate issues a short-lived access token for a user by its ID to the admin, and returns it as JSON.
+// @Summary      Impersonate user
+// @Description  Issue an access token for a user to the admin, carrying the ID of the admin in its impersonator claim. The token expires after IMPERSONATION_TOKEN_TTL_MINUTES, without a refresh token, and the impersonation is recorded in the audit log
+// @Tags         users
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  model.HttpResponse for successful impersonation
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      403  {object}  model.HttpResponse for forbidden impersonation
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/impersonate [post]
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Issue the impersonation token using the service
+	impersonationResp, err := h.Service.Impersonate(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+		if errors.Is(err, service.ErrImpersonationForbidden) {
+			httputil.Forbidden(c, "Impersonation forbidden", err.Error())
+			return
+		}
+
+		// If the error is not a known error, return a generic server error
+		// This is to avoid exposing internal details of the error
+		httputil.ServerError(c, "Failed to impersonate user", err)
+		return
+	}
+
+	httputil.Success(c, "Impersonation token issued successfully", impersonationResp)
+}
+
+// recordLoginAttempt records the outcome of a login attempt along with the client IP address and user agent.
+func (h *AuthHandler) recordLoginAttempt(c *gin.Context, loginReq entity.LoginRequest, err error) {
+	if h.LoginAttempts == nil || loginReq.Username == "" {
+		return
+	}
+
+	tenantID := metacontext.DefaultTenantID
+	if loginReq.TenantID != nil {
+		tenantID = *loginReq.TenantID
+	}
+
+	attempt := entity.LoginAttempt{
+		TenantID:    tenantID,
+		Username:    loginReq.Username,
+		Success:     err == nil,
+		IPAddress:   loginReq.IPAddress,
+		UserAgent:   loginReq.UserAgent,
+		AttemptedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		reason := truncate(err.Error(), 255)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			reason = "user not found"
+		}
+		attempt.FailureReason = &reason
+	}
+
+	h.LoginAttempts.RecordLoginAttempt(attempt)
+}
+
+// setSessionClient sets the client address and user agent of the request on the login request,
+// they are recorded with the session created by the login.
+func setSessionClient(c *gin.Context, loginReq *entity.LoginRequest) {
+	loginReq.IPAddress = c.ClientIP()
+	loginReq.UserAgent = truncate(c.Request.UserAgent(), 255)
+}
+
+// truncate shortens the string to at most n characters.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
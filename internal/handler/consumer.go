@@ -2,6 +2,8 @@ package handler
 
 import (
 	"errors"
+	"net/url"
+	"path"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	fieldutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/field-util"
 	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
 	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
 )
@@ -34,12 +37,19 @@ func NewConsumerHandler(consumerService service.ConsumerService) *ConsumerHandle
 // @Produce      json
 // @Param        page   query     string  false "Page number (default is 1)"
 // @Param        limit  query     string  false "Number of transactions per page (default is 10)"
+// @Param        fields query     string  false "Comma-separated list of fields to return (e.g. id,username,email)"
 // @Success      200  {array}   model.HttpResponse for successful retrieval
 // @Failure      400  {object}  model.HttpResponse for bad request
-// @Failure      404  {object}  model.HttpResponse for not found
 // @Failure      500  {object}  model.HttpResponse for internal server error
 // @Router       /consumers [get]
 func (h *ConsumerHandler) GetAllConsumers(c *gin.Context) {
+	// Parse the optional list of fields to return
+	fields, err := fieldutil.ParseFields(c.Query("fields"), entity.ConsumerFields)
+	if err != nil {
+		httputil.BadRequest(c, "Invalid fields", err.Error())
+		return
+	}
+
 	pageStr := c.DefaultQuery("page", "1")
 	limitStr := c.DefaultQuery("limit", "10")
 
@@ -54,18 +64,25 @@ func (h *ConsumerHandler) GetAllConsumers(c *gin.Context) {
 		return
 	}
 
-	consumers, err := h.Service.GetAllConsumers(page, limit)
+	consumers, total, err := h.Service.GetAllConsumers(c.Request.Context(), page, limit)
 	if err != nil {
-		httputil.InternalServerError(c, "Failed to retrieve consumers", err.Error())
+		httputil.ServerError(c, "Failed to retrieve consumers", err)
 		return
 	}
 
-	if len(consumers) == 0 {
-		httputil.NotFound(c, "No consumers found", "No consumers available in the database")
+	// An empty page is a valid result and is returned as an empty array
+	if consumers == nil {
+		consumers = []entity.Consumer{}
+	}
+
+	// Keep only the requested fields in the response
+	data, err := fieldutil.SelectFields(consumers, fields)
+	if err != nil {
+		httputil.InternalServerError(c, "Failed to retrieve consumers", err.Error())
 		return
 	}
 
-	httputil.Success(c, "All consumers retrieved successfully", consumers)
+	httputil.SuccessWithPagination(c, "All consumers retrieved successfully", data, httputil.NewPagination(page, limit, total))
 }
 
 // GetConsumerByID retrieves a consumer by its ID from the database and returns it as JSON.
@@ -75,6 +92,7 @@ func (h *ConsumerHandler) GetAllConsumers(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "Consumer ID"
+// @Param        fields query   string  false "Comma-separated list of fields to return (e.g. id,username,email)"
 // @Success      200  {object}  model.HttpResponse for successful retrieval
 // @Failure      400  {object}  model.HttpResponse for bad request
 // @Failure      404  {object}  model.HttpResponse for not found
@@ -88,21 +106,35 @@ func (h *ConsumerHandler) GetConsumerByID(c *gin.Context) {
 		return
 	}
 
+	// Parse the optional list of fields to return
+	fields, err := fieldutil.ParseFields(c.Query("fields"), entity.ConsumerFields)
+	if err != nil {
+		httputil.BadRequest(c, "Invalid fields", err.Error())
+		return
+	}
+
 	// Retrieve the consumer by ID from the service
-	consumer, err := h.Service.GetConsumerByID(id)
+	consumer, err := h.Service.GetConsumerByID(c.Request.Context(), id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			httputil.NotFound(c, "Consumer not found", "No consumer found with the given ID")
 			return
 		}
 
-		// If the error is not a record not found error, return a generic internal server error
+		// If the error is not a record not found error, return a generic server error
 		// This is to avoid exposing internal details of the error
+		httputil.ServerError(c, "Failed to retrieve consumer", err)
+		return
+	}
+
+	// Keep only the requested fields in the response
+	data, err := fieldutil.SelectFields(consumer, fields)
+	if err != nil {
 		httputil.InternalServerError(c, "Failed to retrieve consumer", err.Error())
 		return
 	}
 
-	httputil.Success(c, "Consumer retrieved successfully", consumer)
+	httputil.Success(c, "Consumer retrieved successfully", data)
 }
 
 // GetActiveConsumers retrieves all active consumers from the database and returns them as JSON.
@@ -115,7 +147,6 @@ func (h *ConsumerHandler) GetConsumerByID(c *gin.Context) {
 // @Param        limit  query     string  false "Number of transactions per page (default is 10)"
 // @Success      200  {array}   model.HttpResponse for successful retrieval
 // @Failure      400  {object}  model.HttpResponse for bad request
-// @Failure      404  {object}  model.HttpResponse for not found
 // @Failure      500  {object}  model.HttpResponse for internal server error
 // @Router       /consumers/active [get]
 func (h *ConsumerHandler) GetActiveConsumers(c *gin.Context) {
@@ -133,18 +164,18 @@ func (h *ConsumerHandler) GetActiveConsumers(c *gin.Context) {
 		return
 	}
 
-	activeConsumers, err := h.Service.GetActiveConsumers(page, limit)
+	activeConsumers, total, err := h.Service.GetActiveConsumers(c.Request.Context(), page, limit)
 	if err != nil {
-		httputil.InternalServerError(c, "Failed to retrieve active consumers", err.Error())
+		httputil.ServerError(c, "Failed to retrieve active consumers", err)
 		return
 	}
 
-	if len(activeConsumers) == 0 {
-		httputil.NotFound(c, "No active consumers found", "No active consumers available in the database")
-		return
+	// An empty page is a valid result and is returned as an empty array
+	if activeConsumers == nil {
+		activeConsumers = []entity.Consumer{}
 	}
 
-	httputil.Success(c, "Active consumers retrieved successfully", activeConsumers)
+	httputil.SuccessWithPagination(c, "Active consumers retrieved successfully", activeConsumers, httputil.NewPagination(page, limit, total))
 }
 
 // GetInactiveConsumers retrieves all inactive consumers from the database and returns them as JSON.
@@ -157,7 +188,6 @@ func (h *ConsumerHandler) GetActiveConsumers(c *gin.Context) {
 // @Param        limit  query     string  false "Number of transactions per page (default is 10)"
 // @Success      200  {array}   model.HttpResponse for successful retrieval
 // @Failure      400  {object}  model.HttpResponse for bad request
-// @Failure      404  {object}  model.HttpResponse for not found
 // @Failure      500  {object}  model.HttpResponse for internal server error
 // @Router       /consumers/inactive [get]
 func (h *ConsumerHandler) GetInactiveConsumers(c *gin.Context) {
@@ -175,18 +205,18 @@ func (h *ConsumerHandler) GetInactiveConsumers(c *gin.Context) {
 		return
 	}
 
-	inactiveConsumers, err := h.Service.GetInactiveConsumers(page, limit)
+	inactiveConsumers, total, err := h.Service.GetInactiveConsumers(c.Request.Context(), page, limit)
 	if err != nil {
-		httputil.InternalServerError(c, "Failed to retrieve inactive consumers", err.Error())
+		httputil.ServerError(c, "Failed to retrieve inactive consumers", err)
 		return
 	}
 
-	if len(inactiveConsumers) == 0 {
-		httputil.NotFound(c, "No inactive consumers found", "No inactive consumers available in the database")
-		return
+	// An empty page is a valid result and is returned as an empty array
+	if inactiveConsumers == nil {
+		inactiveConsumers = []entity.Consumer{}
 	}
 
-	httputil.Success(c, "Inactive consumers retrieved successfully", inactiveConsumers)
+	httputil.SuccessWithPagination(c, "Inactive consumers retrieved successfully", inactiveConsumers, httputil.NewPagination(page, limit, total))
 }
 
 // GetSuspendedConsumers retrieves all suspended consumers from the database and returns them as JSON.
@@ -199,7 +229,6 @@ func (h *ConsumerHandler) GetInactiveConsumers(c *gin.Context) {
 // @Param        limit  query     string  false "Number of transactions per page (default is 10)"
 // @Success      200  {array}   model.HttpResponse for successful retrieval
 // @Failure      400  {object}  model.HttpResponse for bad request
-// @Failure      404  {object}  model.HttpResponse for not found
 // @Failure      500  {object}  model.HttpResponse for internal server error
 // @Router       /consumers/suspended [get]
 func (h *ConsumerHandler) GetSuspendedConsumers(c *gin.Context) {
@@ -217,18 +246,18 @@ func (h *ConsumerHandler) GetSuspendedConsumers(c *gin.Context) {
 		return
 	}
 
-	suspendedConsumers, err := h.Service.GetSuspendedConsumers(page, limit)
+	suspendedConsumers, total, err := h.Service.GetSuspendedConsumers(c.Request.Context(), page, limit)
 	if err != nil {
-		httputil.InternalServerError(c, "Failed to retrieve suspended consumers", err.Error())
+		httputil.ServerError(c, "Failed to retrieve suspended consumers", err)
 		return
 	}
 
-	if len(suspendedConsumers) == 0 {
-		httputil.NotFound(c, "No suspended consumers found", "No suspended consumers available in the database")
-		return
+	// An empty page is a valid result and is returned as an empty array
+	if suspendedConsumers == nil {
+		suspendedConsumers = []entity.Consumer{}
 	}
 
-	httputil.Success(c, "Suspended consumers retrieved successfully", suspendedConsumers)
+	httputil.SuccessWithPagination(c, "Suspended consumers retrieved successfully", suspendedConsumers, httputil.NewPagination(page, limit, total))
 }
 
 // CreateConsumer creates a new consumer in the database and returns it as JSON.
@@ -239,35 +268,44 @@ func (h *ConsumerHandler) GetSuspendedConsumers(c *gin.Context) {
 // @Produce      json
 // @Param        consumer  body      Consumer  true  "Consumer object"
 // @Success      201  {object}  model.HttpResponse for successful creation
+// @Header       201  {string}  Location  "URL of the created consumer"
 // @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      409  {object}  model.HttpResponse for conflict
+// @Failure      422  {object}  model.HttpResponse for validation failure
 // @Failure      500  {object}  model.HttpResponse for internal server error
 // @Router       /consumers [post]
 func (h *ConsumerHandler) CreateConsumer(c *gin.Context) {
 	// Bind the JSON request body to the Consumer struct
 	// This will automatically validate the request body against the struct tags
 	var consumer entity.Consumer
-	if err := c.ShouldBindJSON(&consumer); err != nil {
+	if err := httputil.BindJSON(c, &consumer); err != nil {
 		httputil.BadRequest(c, "Invalid request body", err.Error())
 		return
 	}
 
 	// Create the consumer using the service
-	createdConsumer, err := h.Service.CreateConsumer(consumer)
+	createdConsumer, err := h.Service.CreateConsumer(c.Request.Context(), consumer)
 	if err != nil {
 		// Check if the error is a validation error
 		var ve validator.ValidationErrors
 		if errors.As(err, &ve) {
-			httputil.BadRequestMap(c, "Failed to create consumer", validation.FormatValidationErrors(err))
+			httputil.UnprocessableEntityMap(c, "Failed to create consumer", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		if errors.Is(err, service.ErrConsumerAlreadyExists) {
+			httputil.Conflict(c, "Failed to create consumer", err.Error())
 			return
 		}
 
-		// If the error is not a validation error, return a generic internal server error
+		// If the error is not a known error, return a generic server error
 		// This is to avoid exposing internal details of the error
-		httputil.InternalServerError(c, "Failed to create consumer", err.Error())
+		httputil.ServerError(c, "Failed to create consumer", err)
 		return
 	}
 
-	httputil.Created(c, "Consumer created successfully", createdConsumer)
+	// Point the Location header at the new consumer, under the prefix the route is mounted on
+	location := path.Join(c.FullPath(), url.PathEscape(createdConsumer.ID))
+	httputil.Created(c, "Consumer created successfully", location, createdConsumer)
 }
 
 // UpdateConsumerStatus updates the status of a consumer by its ID and returns the updated consumer as JSON.
@@ -301,16 +339,16 @@ func (h *ConsumerHandler) UpdateConsumerStatus(c *gin.Context) {
 	}
 
 	// Update the consumer status using the service
-	updatedConsumer, err := h.Service.UpdateConsumerStatus(id, status)
+	updatedConsumer, err := h.Service.UpdateConsumerStatus(c.Request.Context(), id, status)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			httputil.NotFound(c, "Consumer not found", "No consumer found with the given ID")
 			return
 		}
 
-		// If the error is not a record not found error, return a generic internal server error
+		// If the error is not a record not found error, return a generic server error
 		// This is to avoid exposing internal details of the error
-		httputil.InternalServerError(c, "Failed to update consumer status", err.Error())
+		httputil.ServerError(c, "Failed to update consumer status", err)
 		return
 	}
 
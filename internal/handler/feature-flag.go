@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/featureflag"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// This struct defines the FeatureFlagHandler which handles HTTP requests related to the feature flags.
+type FeatureFlagHandler struct{}
+
+// NewFeatureFlagHandler creates a new instance of FeatureFlagHandler.
+func NewFeatureFlagHandler() *FeatureFlagHandler {
+	return &FeatureFlagHandler{}
+}
+
+// FeatureFlagsResponse represents the effective feature flags of a tenant.
+type FeatureFlagsResponse struct {
+	TenantID int64           `json:"tenantId"`
+	Flags    map[string]bool `json:"flags"`
+}
+
+// GetFlags returns the effective value of every feature flag for the tenant the request operates on.
+// @Summary      Get feature flags
+// @Description  Get the effective value of every feature flag for the tenant of the request, for debugging
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  model.HttpResponse for successful retrieval
+// @Router       /admin/flags [get]
+func (h *FeatureFlagHandler) GetFlags(c *gin.Context) {
+	tenantID, ok := metacontext.ExtractTenantID(c.Request.Context())
+	if !ok {
+		tenantID = metacontext.DefaultTenantID
+	}
+
+	httputil.Success(c, "Feature flags retrieved successfully", FeatureFlagsResponse{
+		TenantID: tenantID,
+		Flags:    featureflag.Effective(c.Request.Context()),
+	})
+}
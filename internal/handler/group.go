@@ -0,0 +1,408 @@
+package handler
+
+import (
+	"errors"
+	"path"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/go-playground/validator.v9"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// This struct defines the GroupHandler which handles HTTP requests related to the groups and their members.
+// It contains a service field of type GroupService which is used to interact with the group data layer.
+type GroupHandler struct {
+	Service service.GroupService
+}
+
+// NewGroupHandler creates a new instance of GroupHandler.
+// It initializes the GroupHandler struct with the provided GroupService.
+func NewGroupHandler(groupService service.GroupService) *GroupHandler {
+	return &GroupHandler{Service: groupService}
+}
+
+// GetGroups retrieves the groups of the tenant and returns them as JSON.
+// @Summary      Get groups
+// @Description  Get the groups of the tenant of the request, ordered by name
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param        page   query     string  false "Page number (default is 1)"
+// @Param        limit  query     string  false "Number of groups per page (default is 10)"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /groups [get]
+func (h *GroupHandler) GetGroups(c *gin.Context) {
+	// Parse the pagination parameters from the query
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		httputil.BadRequest(c, "Invalid page number", "Page must be a positive integer")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		httputil.BadRequest(c, "Invalid limit", "Limit must be a positive integer")
+		return
+	}
+
+	groups, total, err := h.Service.GetGroups(c.Request.Context(), page, limit)
+	if err != nil {
+		httputil.ServerError(c, "Failed to retrieve groups", err)
+		return
+	}
+
+	// An empty page is a valid result and is returned as an empty array
+	if groups == nil {
+		groups = []entity.Group{}
+	}
+
+	httputil.SuccessWithPagination(c, "Groups retrieved successfully", groups, httputil.NewPagination(page, limit, total))
+}
+
+// GetGroupByID retrieves a group by its ID and returns it as JSON.
+// @Summary      Get group by ID
+// @Description  Get a group of the tenant of the request by its ID
+// @Tags         groups
+// @Produce      json
+// @Param        id   path      int  true  "Group ID"
+// @Success      200  {object}  model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /groups/{id} [get]
+func (h *GroupHandler) GetGroupByID(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	group, err := h.Service.GetGroupByID(c.Request.Context(), id)
+	if err != nil {
+		if !writeGroupError(c, err) {
+			httputil.ServerError(c, "Failed to retrieve group", err)
+		}
+		return
+	}
+
+	httputil.Success(c, "Group retrieved successfully", group)
+}
+
+// CreateGroup creates a group and returns it as JSON.
+// @Summary      Create group
+// @Description  Create a group in the tenant of the request, its name must be unique within the tenant
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param        request  body      entity.GroupRequest  true  "Group to create"
+// @Success      201  {object}  model.HttpResponse for successful creation
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      409  {object}  model.HttpResponse for a name already taken
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /groups [post]
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	var req entity.GroupRequest
+	if !bindGroupRequest(c, &req, req.Validate) {
+		return
+	}
+
+	createdGroup, err := h.Service.CreateGroup(c.Request.Context(), req)
+	if err != nil {
+		if !writeGroupError(c, err) {
+			httputil.ServerError(c, "Failed to create group", err)
+		}
+		return
+	}
+
+	// Point the Location header at the new group, under the prefix the route is mounted on
+	location := path.Join(c.FullPath(), strconv.FormatInt(createdGroup.ID, 10))
+	httputil.Created(c, "Group created successfully", location, createdGroup)
+}
+
+// UpdateGroup updates a group by its ID and returns it as JSON.
+// @Summary      Update group
+// @Description  Replace the name and the description of a group, its name must be unique within the tenant
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                  true  "Group ID"
+// @Param        request  body      entity.GroupRequest  true  "Updated group"
+// @Success      200  {object}  model.HttpResponse for successful update
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for a name already taken
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /groups/{id} [put]
+func (h *GroupHandler) UpdateGroup(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	var req entity.GroupRequest
+	if !bindGroupRequest(c, &req, req.Validate) {
+		return
+	}
+
+	updatedGroup, err := h.Service.UpdateGroup(c.Request.Context(), id, req)
+	if err != nil {
+		if !writeGroupError(c, err) {
+			httputil.ServerError(c, "Failed to update group", err)
+		}
+		return
+	}
+
+	httputil.Success(c, "Group updated successfully", updatedGroup)
+}
+
+// DeleteGroup deletes a group by its ID.
+// @Summary      Delete group
+// @Description  Delete a group, a group with members is only deleted with force=true, its members are then removed along with it
+// @Tags         groups
+// @Produce      json
+// @Param        id     path      int     true   "Group ID"
+// @Param        force  query     bool    false  "Remove the members along with the group (default is false)"
+// @Success      200  {object}  model.HttpResponse for successful deletion
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for a group with members
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /groups/{id} [delete]
+func (h *GroupHandler) DeleteGroup(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid force", "Force must be true or false")
+		return
+	}
+
+	if err := h.Service.DeleteGroup(c.Request.Context(), id, force); err != nil {
+		if !writeGroupError(c, err) {
+			httputil.ServerError(c, "Failed to delete group", err)
+		}
+		return
+	}
+
+	httputil.Success(c, "Group deleted successfully", nil)
+}
+
+// GetGroupMembers retrieves the members of a group by its ID and returns them as JSON.
+// @Summary      Get group members
+// @Description  Get the members of a group along with their role within the group, the oldest members first
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int     true  "Group ID"
+// @Param        page   query     string  false "Page number (default is 1)"
+// @Param        limit  query     string  false "Number of members per page (default is 10)"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /groups/{id}/members [get]
+func (h *GroupHandler) GetGroupMembers(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Parse the pagination parameters from the query
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		httputil.BadRequest(c, "Invalid page number", "Page must be a positive integer")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		httputil.BadRequest(c, "Invalid limit", "Limit must be a positive integer")
+		return
+	}
+
+	members, total, err := h.Service.GetGroupMembers(c.Request.Context(), id, page, limit)
+	if err != nil {
+		if !writeGroupError(c, err) {
+			httputil.ServerError(c, "Failed to retrieve group members", err)
+		}
+		return
+	}
+
+	// An empty page is a valid result and is returned as an empty array
+	if members == nil {
+		members = []entity.GroupMemberResponse{}
+	}
+
+	httputil.SuccessWithPagination(c, "Group members retrieved successfully", members, httputil.NewPagination(page, limit, total))
+}
+
+// AddGroupMember adds a user to a group by its ID and returns the member as JSON.
+// @Summary      Add group member
+// @Description  Add a user of the tenant to a group, as a member unless another role is given
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                        true  "Group ID"
+// @Param        request  body      entity.GroupMemberRequest  true  "Member to add"
+// @Success      201  {object}  model.HttpResponse for successful addition
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for a user already member
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /groups/{id}/members [post]
+func (h *GroupHandler) AddGroupMember(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	var req entity.GroupMemberRequest
+	if !bindGroupRequest(c, &req, req.Validate) {
+		return
+	}
+
+	member, err := h.Service.AddGroupMember(c.Request.Context(), id, req)
+	if err != nil {
+		if !writeGroupError(c, err) {
+			httputil.ServerError(c, "Failed to add group member", err)
+		}
+		return
+	}
+
+	// Point the Location header at the new membership, the path of the request holds the ID of the group
+	location := path.Join(c.Request.URL.Path, strconv.FormatInt(req.UserID, 10))
+	httputil.Created(c, "Group member added successfully", location, member)
+}
+
+// UpdateGroupMember changes the role of a member of a group and returns the member as JSON.
+// @Summary      Update group member
+// @Description  Change the role of a member within its group
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                            true  "Group ID"
+// @Param        userId   path      int                            true  "User ID"
+// @Param        request  body      entity.GroupMemberRoleRequest  true  "Role of the member"
+// @Success      200  {object}  model.HttpResponse for successful update
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /groups/{id}/members/{userId} [patch]
+func (h *GroupHandler) UpdateGroupMember(c *gin.Context) {
+	// Retrieve the IDs validated from the URL parameters
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+	userID, ok := pathparam.Int64(c, "userId")
+	if !ok {
+		return
+	}
+
+	var req entity.GroupMemberRoleRequest
+	if !bindGroupRequest(c, &req, req.Validate) {
+		return
+	}
+
+	member, err := h.Service.UpdateGroupMember(c.Request.Context(), id, userID, req)
+	if err != nil {
+		if !writeGroupError(c, err) {
+			httputil.ServerError(c, "Failed to update group member", err)
+		}
+		return
+	}
+
+	httputil.Success(c, "Group member updated successfully", member)
+}
+
+// RemoveGroupMember removes a member from a group.
+// @Summary      Remove group member
+// @Description  Remove a member from its group
+// @Tags         groups
+// @Produce      json
+// @Param        id      path      int  true  "Group ID"
+// @Param        userId  path      int  true  "User ID"
+// @Success      200  {object}  model.HttpResponse for successful removal
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /groups/{id}/members/{userId} [delete]
+func (h *GroupHandler) RemoveGroupMember(c *gin.Context) {
+	// Retrieve the IDs validated from the URL parameters
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+	userID, ok := pathparam.Int64(c, "userId")
+	if !ok {
+		return
+	}
+
+	if err := h.Service.RemoveGroupMember(c.Request.Context(), id, userID); err != nil {
+		if !writeGroupError(c, err) {
+			httputil.ServerError(c, "Failed to remove group member", err)
+		}
+		return
+	}
+
+	httputil.Success(c, "Group member removed successfully", nil)
+}
+
+// bindGroupRequest binds the JSON request body to the given request and validates it,
+// and reports whether it is valid. The response of an invalid request is written.
+func bindGroupRequest(c *gin.Context, req any, validate func() error) bool {
+	if err := httputil.BindJSON(c, req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return false
+	}
+	if err := validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Invalid request body", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return false
+		}
+		httputil.UnprocessableEntity(c, "Invalid request body", err.Error())
+		return false
+	}
+	return true
+}
+
+// writeGroupError writes the response of the known errors of the groups and their members,
+// and reports whether the error was one of them.
+func writeGroupError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrGroupMemberNotFound):
+		httputil.NotFound(c, "Group member not found", err.Error())
+	case errors.Is(err, service.ErrGroupUserNotFound):
+		httputil.NotFound(c, "User not found", err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		httputil.NotFound(c, "Group not found", "No group found with the given ID")
+	case errors.Is(err, service.ErrGroupAlreadyExists):
+		httputil.Conflict(c, "Group already exists", err.Error())
+	case errors.Is(err, service.ErrGroupHasMembers):
+		httputil.Conflict(c, "Group has members", err.Error())
+	case errors.Is(err, service.ErrGroupMemberAlreadyExists):
+		httputil.Conflict(c, "Group member already exists", err.Error())
+	default:
+		return false
+	}
+	return true
+}
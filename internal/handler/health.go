@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// This struct defines the HealthHandler which answers the liveness and readiness probes.
+// It contains a service field of type HealthService which is used to check the dependencies of the application.
+type HealthHandler struct {
+	Service service.HealthService
+}
+
+// HealthResponse represents the health of the application.
+type HealthResponse struct {
+	Status         string `json:"status"`
+	Database       string `json:"database,omitempty"`
+	CircuitBreaker string `json:"circuitBreaker,omitempty"`
+}
+
+// NewHealthHandler creates a new instance of HealthHandler.
+// It initializes the HealthHandler struct with the provided HealthService.
+func NewHealthHandler(healthService service.HealthService) *HealthHandler {
+	return &HealthHandler{Service: healthService}
+}
+
+// GetLiveness reports that the application is running.
+// @Summary      Liveness probe
+// @Description  Report that the application is running
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  model.HttpResponse for a running application
+// @Router       /health/live [get]
+func (h *HealthHandler) GetLiveness(c *gin.Context) {
+	httputil.Success(c, "Service is alive", HealthResponse{Status: "UP"})
+}
+
+// GetReadiness reports whether the application can serve the requests, i.e. whether the database answers.
+// The application is not ready while the circuit breaker of the database is open.
+// @Summary      Readiness probe
+// @Description  Report whether the database answers and the state of its circuit breaker
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  model.HttpResponse for a ready application
+// @Failure      503  {object}  model.HttpResponse for an application not ready
+// @Router       /health/ready [get]
+func (h *HealthHandler) GetReadiness(c *gin.Context) {
+	state, err := h.Service.CheckReadiness(c.Request.Context())
+	if err != nil {
+		httputil.ServiceUnavailable(c, "Service is not ready", err.Error())
+		return
+	}
+
+	httputil.Success(c, "Service is ready", HealthResponse{Status: "UP", Database: "UP", CircuitBreaker: string(state)})
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// This struct defines the LoginAttemptHandler which handles HTTP requests related to the login history.
+// It contains a service field of type LoginAttemptService which is used to interact with the login attempt data layer.
+type LoginAttemptHandler struct {
+	Service service.LoginAttemptService
+}
+
+// NewLoginAttemptHandler creates a new instance of LoginAttemptHandler.
+// It initializes the LoginAttemptHandler struct with the provided LoginAttemptService.
+func NewLoginAttemptHandler(loginAttemptService service.LoginAttemptService) *LoginAttemptHandler {
+	return &LoginAttemptHandler{Service: loginAttemptService}
+}
+
+// GetLoginHistory retrieves the login history of a user by its ID and returns it as JSON.
+// @Summary      Get login history of a user
+// @Description  Get the successful and failed login attempts of a user, the most recent first
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int     true  "User ID"
+// @Param        page     query     string  false "Page number (default is 1)"
+// @Param        limit    query     string  false "Number of login attempts per page (default is 10)"
+// @Param        from     query     string  false "Only the attempts at or after this time (RFC3339)"
+// @Param        to       query     string  false "Only the attempts at or before this time (RFC3339)"
+// @Param        outcome  query     string  false "Only the successful or failed attempts (success or failure)"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/login-history [get]
+func (h *LoginAttemptHandler) GetLoginHistory(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	h.getLoginHistory(c, id)
+}
+
+// GetMyLoginHistory retrieves the login history of the authenticated user and returns it as JSON.
+// @Summary      Get own login history
+// @Description  Get the successful and failed login attempts against the account of the authenticated user
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        page     query     string  false "Page number (default is 1)"
+// @Param        limit    query     string  false "Number of login attempts per page (default is 10)"
+// @Param        from     query     string  false "Only the attempts at or after this time (RFC3339)"
+// @Param        to       query     string  false "Only the attempts at or before this time (RFC3339)"
+// @Param        outcome  query     string  false "Only the successful or failed attempts (success or failure)"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      401  {object}  model.HttpResponse for unauthorized
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/me/login-history [get]
+func (h *LoginAttemptHandler) GetMyLoginHistory(c *gin.Context) {
+	// Get the authenticated user from the context
+	meta, ok := metacontext.ExtractUserInformationMeta(c.Request.Context())
+	if !ok || meta.UserID < 1 {
+		httputil.Unauthorized(c, "Unauthorized", "Missing user context")
+		return
+	}
+
+	h.getLoginHistory(c, meta.UserID)
+}
+
+// getLoginHistory parses the pagination and filter parameters, and writes the login history of the user.
+func (h *LoginAttemptHandler) getLoginHistory(c *gin.Context, userID int64) {
+	filter, ok := parseLoginAttemptFilter(c)
+	if !ok {
+		return
+	}
+
+	attempts, total, err := h.Service.GetLoginHistory(c.Request.Context(), userID, filter)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+
+		httputil.ServerError(c, "Failed to retrieve login history", err)
+		return
+	}
+
+	// An empty page is a valid result and is returned as an empty array
+	if attempts == nil {
+		attempts = []entity.LoginAttempt{}
+	}
+
+	httputil.SuccessWithPagination(c, "Login history retrieved successfully", attempts, httputil.NewPagination(filter.Page, filter.Limit, total))
+}
+
+// parseLoginAttemptFilter parses the pagination and filter query parameters.
+// It writes a bad request response and returns false if a parameter is invalid.
+func parseLoginAttemptFilter(c *gin.Context) (entity.LoginAttemptFilter, bool) {
+	var filter entity.LoginAttemptFilter
+	var err error
+
+	filter.Page, err = strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || filter.Page < 1 {
+		httputil.BadRequest(c, "Invalid page number", "Page must be a positive integer")
+		return filter, false
+	}
+	filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || filter.Limit < 1 {
+		httputil.BadRequest(c, "Invalid limit", "Limit must be a positive integer")
+		return filter, false
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			httputil.BadRequest(c, "Invalid from date", "From must be an RFC3339 timestamp (e.g. 2025-01-01T00:00:00Z)")
+			return filter, false
+		}
+		t = t.UTC()
+		filter.From = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			httputil.BadRequest(c, "Invalid to date", "To must be an RFC3339 timestamp (e.g. 2025-01-31T23:59:59Z)")
+			return filter, false
+		}
+		t = t.UTC()
+		filter.To = &t
+	}
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		httputil.BadRequest(c, "Invalid date range", "From must be before To")
+		return filter, false
+	}
+
+	switch c.Query("outcome") {
+	case "":
+	case "success":
+		success := true
+		filter.Success = &success
+	case "failure":
+		success := false
+		filter.Success = &success
+	default:
+		httputil.BadRequest(c, "Invalid outcome", "Outcome must be success or failure")
+		return filter, false
+	}
+
+	return filter, true
+}
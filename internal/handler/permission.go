@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// This struct defines the PermissionHandler which handles HTTP requests related to the permissions of the users.
+// It contains a service field of type PermissionService which is used to interact with the permission data layer.
+type PermissionHandler struct {
+	Service service.PermissionService
+}
+
+// NewPermissionHandler creates a new instance of PermissionHandler.
+// It initializes the PermissionHandler struct with the provided PermissionService.
+func NewPermissionHandler(permissionService service.PermissionService) *PermissionHandler {
+	return &PermissionHandler{Service: permissionService}
+}
+
+// GetUserPermissions retrieves the effective permissions of a user by its ID and returns them as JSON.
+// @Summary      Get permissions of a user
+// @Description  Get the effective permissions of a user, the union of the permissions of its roles without duplicates, only the admins may read the permissions of another user
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      403  {object}  model.HttpResponse for the permissions of another user
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/permissions [get]
+func (h *PermissionHandler) GetUserPermissions(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	permissions, err := h.Service.GetUserPermissions(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrPermissionAccessForbidden) {
+			authorization.DenyAccess(c, "User", "Only the admins may read the permissions of another user")
+			return
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+
+		httputil.ServerError(c, "Failed to retrieve permissions", err)
+		return
+	}
+
+	httputil.Success(c, "Permissions retrieved successfully", permissions)
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// This struct defines the PreflightHandler which answers the self-check of the application after a deployment.
+// It contains a service field of type PreflightService which is used to run the checks of the startup preflight.
+type PreflightHandler struct {
+	Service service.PreflightService
+}
+
+// NewPreflightHandler creates a new instance of PreflightHandler.
+// It initializes the PreflightHandler struct with the provided PreflightService.
+func NewPreflightHandler(preflightService service.PreflightService) *PreflightHandler {
+	return &PreflightHandler{Service: preflightService}
+}
+
+// GetSelfCheck runs the checks of the startup preflight again and reports their result.
+// @Summary      Self-check
+// @Description  Run the checks of the startup preflight: the database, its schema, the JWT keys, the system user and the roles
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  model.HttpResponse for every check passed, with the report
+// @Failure      503  {object}  model.HttpResponse listing the checks that did not pass
+// @Router       /admin/selfcheck [get]
+func (h *PreflightHandler) GetSelfCheck(c *gin.Context) {
+	report := h.Service.Run(c.Request.Context())
+	if !report.Passed {
+		var failed []map[string]string
+		for _, check := range report.Failed() {
+			failed = append(failed, map[string]string{
+				"name":    check.Name,
+				"status":  check.Status,
+				"message": check.Detail,
+			})
+		}
+		httputil.ServiceUnavailableMap(c, "Self-check failed", failed)
+		return
+	}
+
+	httputil.Success(c, "Self-check passed", report)
+}
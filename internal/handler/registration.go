@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// This struct defines the RegistrationHandler which handles HTTP requests related to the approval of the registered accounts.
+// It contains a service field of type RegistrationService which is used to interact with the registration data layer.
+type RegistrationHandler struct {
+	Service service.RegistrationService
+}
+
+// NewRegistrationHandler creates a new instance of RegistrationHandler.
+// It initializes the RegistrationHandler struct with the provided RegistrationService.
+func NewRegistrationHandler(registrationService service.RegistrationService) *RegistrationHandler {
+	return &RegistrationHandler{Service: registrationService}
+}
+
+// GetPendingRegistrations retrieves the registered accounts waiting for an approval and returns them as JSON.
+// @Summary      Get pending registrations
+// @Description  Get the accounts registered through POST /auth/register that wait for the approval of an admin, the oldest first
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        page   query     string  false "Page number (default is 1)"
+// @Param        limit  query     string  false "Number of accounts per page (default is 10)"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/users/pending [get]
+func (h *RegistrationHandler) GetPendingRegistrations(c *gin.Context) {
+	// Parse the pagination parameters from the query
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		httputil.BadRequest(c, "Invalid page number", "Page must be a positive integer")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		httputil.BadRequest(c, "Invalid limit", "Limit must be a positive integer")
+		return
+	}
+
+	pending, total, err := h.Service.GetPendingRegistrations(c.Request.Context(), page, limit)
+	if err != nil {
+		httputil.ServerError(c, "Failed to retrieve pending registrations", err)
+		return
+	}
+
+	// An empty page is a valid result and is returned as an empty array
+	if pending == nil {
+		pending = []entity.PendingRegistrationResponse{}
+	}
+
+	httputil.SuccessWithPagination(c, "Pending registrations retrieved successfully", pending, httputil.NewPagination(page, limit, total))
+}
+
+// ApproveRegistration approves the registration of a user by its ID and returns the enabled user as JSON.
+// @Summary      Approve registration
+// @Description  Approve the registration of an account pending approval, the account is enabled
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  model.HttpResponse for successful approval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for a registration already decided
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/users/{id}/approve [post]
+func (h *RegistrationHandler) ApproveRegistration(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	approvedUser, err := h.Service.ApproveRegistration(c.Request.Context(), id)
+	if err != nil {
+		if !writeRegistrationError(c, err) {
+			httputil.ServerError(c, "Failed to approve registration", err)
+		}
+		return
+	}
+
+	httputil.Success(c, "Registration approved successfully", approvedUser.ToResponse())
+}
+
+// RejectRegistration rejects the registration of a user by its ID.
+// @Summary      Reject registration
+// @Description  Reject the registration of an account pending approval, the account stays disabled and is anonymized
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  model.HttpResponse for successful rejection
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for a registration already decided
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/users/{id}/reject [post]
+func (h *RegistrationHandler) RejectRegistration(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.Service.RejectRegistration(c.Request.Context(), id); err != nil {
+		if !writeRegistrationError(c, err) {
+			httputil.ServerError(c, "Failed to reject registration", err)
+		}
+		return
+	}
+
+	httputil.Success(c, "Registration rejected successfully", nil)
+}
+
+// ForceEmailVerification marks the email of a registered user by its ID as verified and returns the registration as JSON.
+// @Summary      Force email verification
+// @Description  Mark the email of a registered account as verified without a verification by the user, the manual override is recorded in the audit log
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  model.HttpResponse for successful verification
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for an email already verified
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/users/{id}/verification/force [post]
+func (h *RegistrationHandler) ForceEmailVerification(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	registration, err := h.Service.ForceEmailVerification(c.Request.Context(), id)
+	if err != nil {
+		if !writeRegistrationError(c, err) {
+			httputil.ServerError(c, "Failed to verify email", err)
+		}
+		return
+	}
+
+	httputil.Success(c, "Email verified successfully", registration)
+}
+
+// writeRegistrationError writes the response of the known errors of a decision on a registration or its email,
+// and reports whether the error was one of them.
+func writeRegistrationError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		httputil.NotFound(c, "Registration not found", "No registered user found with the given ID")
+	case errors.Is(err, service.ErrRegistrationNotPending):
+		httputil.Conflict(c, "Registration already decided", err.Error())
+	case errors.Is(err, service.ErrEmailAlreadyVerified):
+		httputil.Conflict(c, "Email already verified", err.Error())
+	default:
+		return false
+	}
+	return true
+}
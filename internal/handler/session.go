@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// This struct defines the SessionHandler which handles HTTP requests related to the sessions of the users.
+// It contains a service field of type SessionService which is used to interact with the session data layer.
+type SessionHandler struct {
+	Service service.SessionService
+}
+
+// NewSessionHandler creates a new instance of SessionHandler.
+// It initializes the SessionHandler struct with the provided SessionService.
+func NewSessionHandler(sessionService service.SessionService) *SessionHandler {
+	return &SessionHandler{Service: sessionService}
+}
+
+// GetUserSessions retrieves the active sessions of a user by its ID and returns them as JSON.
+// @Summary      Get sessions of a user
+// @Description  Get the active (neither revoked nor expired) sessions of a user, the most recent first, only the admins may list the sessions of another user
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      403  {object}  model.HttpResponse for the sessions of another user
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/sessions [get]
+func (h *SessionHandler) GetUserSessions(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	sessions, err := h.Service.GetUserSessions(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrSessionAccessForbidden) {
+			authorization.DenyAccess(c, "User", "Only the admins may list the sessions of another user")
+			return
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+
+		httputil.ServerError(c, "Failed to retrieve sessions", err)
+		return
+	}
+
+	httputil.Success(c, "Sessions retrieved successfully", sessions)
+}
+
+// RevokeUserSession revokes a session of a user by its ID, its refresh token can no longer be used.
+// @Summary      Revoke a session of a user
+// @Description  Revoke a session of a user, only the admins may revoke the sessions of another user
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id         path      int     true  "User ID"
+// @Param        sessionId  path      string  true  "Session ID"
+// @Success      200  {object}  model.HttpResponse for successful revocation
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      403  {object}  model.HttpResponse for the sessions of another user
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/sessions/{sessionId} [delete]
+func (h *SessionHandler) RevokeUserSession(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.Service.RevokeUserSession(c.Request.Context(), id, c.Param("sessionId")); err != nil {
+		if errors.Is(err, service.ErrSessionAccessForbidden) {
+			authorization.DenyAccess(c, "User", "Only the admins may revoke the sessions of another user")
+			return
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "Session not found", "No session found with the given ID for the user")
+			return
+		}
+
+		httputil.ServerError(c, "Failed to revoke session", err)
+		return
+	}
+
+	httputil.Success(c, "Session revoked successfully", nil)
+}
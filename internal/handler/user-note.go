@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/go-playground/validator.v9"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// This struct defines the UserNoteHandler which handles HTTP requests related to the internal notes on the users.
+// It contains a service field of type UserNoteService which is used to interact with the user note data layer.
+type UserNoteHandler struct {
+	Service service.UserNoteService
+}
+
+// NewUserNoteHandler creates a new instance of UserNoteHandler.
+// It initializes the UserNoteHandler struct with the provided UserNoteService.
+func NewUserNoteHandler(userNoteService service.UserNoteService) *UserNoteHandler {
+	return &UserNoteHandler{Service: userNoteService}
+}
+
+// GetUserNotes retrieves the notes on a user by its ID and returns them as JSON.
+// @Summary      Get notes on a user
+// @Description  Get the internal notes on the account of a user, the pinned notes first, then the most recent first
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int     true  "User ID"
+// @Param        page   query     string  false "Page number (default is 1)"
+// @Param        limit  query     string  false "Number of notes per page (default is 10)"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/users/{id}/notes [get]
+func (h *UserNoteHandler) GetUserNotes(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Parse the pagination parameters from the query
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		httputil.BadRequest(c, "Invalid page number", "Page must be a positive integer")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		httputil.BadRequest(c, "Invalid limit", "Limit must be a positive integer")
+		return
+	}
+
+	notes, total, err := h.Service.GetUserNotes(c.Request.Context(), id, page, limit)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+
+		httputil.ServerError(c, "Failed to retrieve user notes", err)
+		return
+	}
+
+	// An empty page is a valid result and is returned as an empty array
+	if notes == nil {
+		notes = []entity.UserNote{}
+	}
+
+	httputil.SuccessWithPagination(c, "User notes retrieved successfully", notes, httputil.NewPagination(page, limit, total))
+}
+
+// CreateUserNote adds a note to a user by its ID and returns it as JSON.
+// @Summary      Add a note on a user
+// @Description  Add an internal note on the account of a user, written by the caller, the user never sees it
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                     true  "User ID"
+// @Param        request  body      entity.UserNoteRequest  true  "Note to add"
+// @Success      201  {object}  model.HttpResponse for successful creation
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/users/{id}/notes [post]
+func (h *UserNoteHandler) CreateUserNote(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Bind the JSON request body to the UserNoteRequest struct
+	var req entity.UserNoteRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Invalid request body", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		httputil.UnprocessableEntity(c, "Invalid request body", err.Error())
+		return
+	}
+
+	note, err := h.Service.CreateUserNote(c.Request.Context(), id, req)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+
+		httputil.ServerError(c, "Failed to add user note", err)
+		return
+	}
+
+	// The notes are only read along with the other notes of the user, so no Location is returned
+	httputil.Created(c, "User note added successfully", "", note)
+}
+
+// DeleteUserNote deletes a note on a user by its ID.
+// @Summary      Delete a note on a user
+// @Description  Delete an internal note on the account of a user, only its author or a super admin may delete it
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id      path      int  true  "User ID"
+// @Param        noteId  path      int  true  "Note ID"
+// @Success      200  {object}  model.HttpResponse for successful deletion
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      403  {object}  model.HttpResponse for the notes of another author
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/users/{id}/notes/{noteId} [delete]
+func (h *UserNoteHandler) DeleteUserNote(c *gin.Context) {
+	// Retrieve the IDs validated from the URL parameters
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+	noteID, ok := pathparam.Int64(c, "noteId")
+	if !ok {
+		return
+	}
+
+	if err := h.Service.DeleteUserNote(c.Request.Context(), id, noteID); err != nil {
+		if errors.Is(err, service.ErrUserNoteDeleteForbidden) {
+			httputil.Forbidden(c, "Forbidden", "Only the author of the note or a super admin may delete it")
+			return
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "Note not found", "No note found with the given ID for the user")
+			return
+		}
+
+		httputil.ServerError(c, "Failed to delete user note", err)
+		return
+	}
+
+	httputil.Success(c, "User note deleted successfully", nil)
+}
@@ -0,0 +1,1352 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/go-playground/validator.v9"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// This struct defines the UserHandler which handles HTTP requests related to users.
+// It contains a service field of type UserService which is used to interact with the user data layer.
+type UserHandler struct {
+	Service service.UserService
+}
+
+// NewUserHandler creates a new instance of UserHandler.
+// It initializes the UserHandler struct with the provided UserService.
+func NewUserHandler(userService service.UserService) *UserHandler {
+	return &UserHandler{Service: userService}
+}
+
+// GetUsers retrieves a page of users and returns them as JSON.
+// With modifiedSince, only the users updated strictly after it are returned, including the soft-deleted ones,
+// which carry isDeleted and deletedAt. An integration mirroring the users resumes from the updatedAt of the last user it received.
+// With updatedSince or createdSince, only the users updated or created at or after it are returned, updatedSince including
+// the soft-deleted ones as well. With group, only the members of the group are returned.
+// The filters are combined, and the users are ordered by the time they filter on.
+// With partial=true, the users are streamed in the order of their IDs from the cursor instead of a page:
+// the users fetched before the request times out are returned, and the response carries the cursor of the next ones
+// and whether the list was cut short, so a bulk consumer resumes the list instead of starting it over.
+// @Summary      Get users
+// @Description  Get a page of users, the users modified since a time for a delta synchronization, or a streamed list returning the users fetched before the timeout
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        modifiedSince  query     string  false "Return the users updated strictly after this RFC 3339 time, deleted users included"
+// @Param        updatedSince   query     string  false "Return the users updated at or after this RFC 3339 time, deleted users included"
+// @Param        createdSince   query     string  false "Return the users created at or after this RFC 3339 time"
+// @Param        group          query     int     false "Return the members of the group with this ID"
+// @Param        includeDeleted query     bool    false "Include the soft-deleted users (default is false)"
+// @Param        page           query     string  false "Page number (default is 1)"
+// @Param        limit          query     string  false "Number of users per page, or streamed with partial (default is 10)"
+// @Param        partial        query     bool    false "Stream the users and return those fetched before the timeout along with a cursor (default is false)"
+// @Param        cursor         query     string  false "Resume a streamed list after the users already received, from its cursor.next"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users [get]
+func (h *UserHandler) GetUsers(c *gin.Context) {
+	// Parse the optional times of the changes
+	filter, ok := parseUserFilter(c)
+	if !ok {
+		return
+	}
+
+	// The soft-deleted users are left out unless they are asked for
+	includeDeleted, err := strconv.ParseBool(c.DefaultQuery("includeDeleted", "false"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid includeDeleted", "includeDeleted must be true or false")
+		return
+	}
+
+	// The bulk consumers opt in to the streamed list, which returns what was fetched before the timeout
+	partial, err := strconv.ParseBool(c.DefaultQuery("partial", "false"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid partial", "partial must be true or false")
+		return
+	}
+	if !partial && c.Query("cursor") != "" {
+		httputil.BadRequest(c, "Invalid cursor", "cursor requires partial=true")
+		return
+	}
+	if partial && filter != (entity.UserFilter{}) {
+		httputil.BadRequest(c, "Invalid partial", "partial cannot be combined with modifiedSince, updatedSince, createdSince or group")
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		httputil.BadRequest(c, "Invalid page number", "Page must be a positive integer")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		httputil.BadRequest(c, "Invalid limit", "Limit must be a positive integer")
+		return
+	}
+
+	if partial {
+		h.streamUsers(c, includeDeleted, c.Query("cursor"), limit)
+		return
+	}
+
+	users, total, err := h.Service.GetUsers(c.Request.Context(), filter, includeDeleted, page, limit)
+	if err != nil {
+		httputil.ServerError(c, "Failed to retrieve users", err)
+		return
+	}
+
+	// An empty page is a valid result and is returned as an empty array
+	responses := make([]entity.UserResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, user.ToResponse())
+	}
+
+	httputil.SuccessWithPagination(c, "Users retrieved successfully", responses, httputil.NewPagination(page, limit, total))
+}
+
+// parseUserFilter parses the times filtering the users, as RFC 3339 times converted to UTC, and the group of the users.
+// It writes a bad request response and returns false if a time or the group is invalid.
+func parseUserFilter(c *gin.Context) (entity.UserFilter, bool) {
+	var filter entity.UserFilter
+	for _, param := range []struct {
+		name  string
+		value **time.Time
+	}{
+		{"modifiedSince", &filter.ModifiedSince},
+		{"updatedSince", &filter.UpdatedSince},
+		{"createdSince", &filter.CreatedSince},
+	} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			httputil.BadRequest(c, "Invalid "+param.name, param.name+" must be an RFC 3339 time, e.g. 2025-01-31T23:59:59Z")
+			return filter, false
+		}
+		since = since.UTC()
+		*param.value = &since
+	}
+
+	if value := c.Query("group"); value != "" {
+		groupID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || groupID < 1 {
+			httputil.BadRequest(c, "Invalid group", "group must be the ID of a group, a positive integer")
+			return filter, false
+		}
+		filter.GroupID = &groupID
+	}
+
+	return filter, true
+}
+
+// streamUsers writes the streamed list of the users, flushing every batch as soon as it is fetched.
+func (h *UserHandler) streamUsers(c *gin.Context, includeDeleted bool, cursor string, limit int) {
+	stream := httputil.StreamList(c, "Users retrieved successfully")
+	next, partial, err := h.Service.StreamUsers(c.Request.Context(), includeDeleted, cursor, limit, func(users []entity.User) error {
+		responses := make([]entity.UserResponse, 0, len(users))
+		for _, user := range users {
+			responses = append(responses, user.ToResponse())
+		}
+		return stream.Write(responses)
+	})
+
+	switch {
+	case errors.Is(err, service.ErrInvalidCursor):
+		httputil.BadRequest(c, "Invalid cursor", "cursor must be the cursor.next of a previous response")
+	case err != nil && stream.Started():
+		// The status is already sent, the truncated body tells the client the list failed
+		logger.Error(fmt.Sprintf("Failed to stream users: %v", err), nil)
+		c.Abort()
+	case err != nil:
+		httputil.ServerError(c, "Failed to retrieve users", err)
+	default:
+		if err := stream.End(&httputil.Cursor{Next: next, Partial: partial}); err != nil {
+			logger.Error(fmt.Sprintf("Failed to stream users: %v", err), nil)
+		}
+	}
+}
+
+// ExportUsers streams every user as newline-delimited JSON, one user per line, flushing every batch as soon as it is fetched,
+// so a data pipeline syncs the whole table in one request instead of a pagination loop.
+// With format=csv, the users are streamed as a CSV file instead, one row per user with a header row.
+// With updatedSince, only the users updated strictly after it are returned, including the soft-deleted ones;
+// the users come in the order of their IDs, an incremental sync resumes from the greatest updatedAt it received.
+// The export stops as soon as the client disconnects.
+// @Summary      Export users
+// @Description  Stream every user, or the users updated since a time, as newline-delimited JSON or CSV for the data pipelines
+// @Tags         users
+// @Produce      application/x-ndjson
+// @Produce      text/csv
+// @Param        updatedSince   query     string  false "Return the users updated strictly after this RFC 3339 time, deleted users included"
+// @Param        includeDeleted query     bool    false "Include the soft-deleted users (default is false)"
+// @Param        format         query     string  false "ndjson or csv (default is ndjson)"
+// @Success      200  {array}   entity.UserResponse for successful retrieval, one user per line
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/stream [get]
+func (h *UserHandler) ExportUsers(c *gin.Context) {
+	// Parse the optional time of the last synchronization
+	var updatedSince *time.Time
+	if value := c.Query("updatedSince"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			httputil.BadRequest(c, "Invalid updatedSince", "updatedSince must be an RFC 3339 time, e.g. 2025-01-31T23:59:59Z")
+			return
+		}
+		since = since.UTC()
+		updatedSince = &since
+	}
+
+	// The soft-deleted users are left out unless they are asked for
+	includeDeleted, err := strconv.ParseBool(c.DefaultQuery("includeDeleted", "false"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid includeDeleted", "includeDeleted must be true or false")
+		return
+	}
+
+	// The users are written in the format of the export, every batch flushed as soon as it is fetched
+	var (
+		write   func(users []entity.UserResponse) error
+		started func() bool
+		end     func() error
+	)
+	switch strings.ToLower(c.DefaultQuery("format", "ndjson")) {
+	case "ndjson":
+		stream := httputil.StreamNDJSON(c)
+		write = func(users []entity.UserResponse) error { return stream.Write(users) }
+		started = stream.Started
+		end = func() error { stream.End(); return nil }
+	case "csv":
+		stream := httputil.StreamCSV(c, "users.csv", userCSVHeader)
+		write = func(users []entity.UserResponse) error {
+			records := make([][]string, 0, len(users))
+			for _, user := range users {
+				records = append(records, userCSVRecord(stream, user))
+			}
+			return stream.Write(records)
+		}
+		started = stream.Started
+		end = stream.End
+	default:
+		httputil.BadRequest(c, "Invalid format", "format must be ndjson or csv")
+		return
+	}
+
+	err = h.Service.ExportUsers(c.Request.Context(), updatedSince, includeDeleted, func(users []entity.User) error {
+		responses := make([]entity.UserResponse, 0, len(users))
+		for _, user := range users {
+			responses = append(responses, user.ToResponse())
+		}
+		return write(responses)
+	})
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The client is gone, there is no one left to respond to
+		logger.Info("User export cancelled by the client", nil)
+		c.Abort()
+	case err != nil && started():
+		// The status is already sent, the truncated body tells the client the export failed
+		logger.Error(fmt.Sprintf("Failed to export users: %v", err), nil)
+		c.Abort()
+	case err != nil:
+		httputil.ServerError(c, "Failed to export users", err)
+	default:
+		if err := end(); err != nil {
+			logger.Error(fmt.Sprintf("Failed to export users: %v", err), nil)
+		}
+	}
+}
+
+// userCSVHeader holds the columns of the CSV export of the users, the fields of UserResponse but the audit fields.
+// The roles are joined with a semicolon and the metadata is a JSON object.
+var userCSVHeader = []string{
+	"id", "tenantId", "username", "email", "firstName", "lastName",
+	"isEnabled", "isAccountNonExpired", "isAccountNonLocked", "isCredentialsNonExpired", "isDeleted",
+	"accountExpirationDate", "credentialsExpirationDate", "userType", "lastLogin", "maxSessions",
+	"metadata", "roles", "createdAt", "updatedAt", "deletedAt",
+}
+
+// userCSVRecord returns the row of a user in the CSV export, in the order of userCSVHeader.
+func userCSVRecord(stream *httputil.CSVStream, user entity.UserResponse) []string {
+	formatBool := func(b *bool) string {
+		if b == nil {
+			return ""
+		}
+		return strconv.FormatBool(*b)
+	}
+
+	lastname := ""
+	if user.Lastname != nil {
+		lastname = *user.Lastname
+	}
+	maxSessions := ""
+	if user.MaxSessions != nil {
+		maxSessions = strconv.Itoa(*user.MaxSessions)
+	}
+	metadata, err := json.Marshal(user.Metadata)
+	if err != nil || user.Metadata == nil {
+		metadata = []byte("{}")
+	}
+	roles := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		roles = append(roles, role.Name)
+	}
+
+	return []string{
+		strconv.FormatInt(user.ID, 10), strconv.FormatInt(user.TenantID, 10), user.Username, user.Email, user.Firstname, lastname,
+		formatBool(user.IsEnabled), formatBool(user.IsAccountNonExpired), formatBool(user.IsAccountNonLocked),
+		formatBool(user.IsCredentialsNonExpired), formatBool(user.IsDeleted),
+		stream.FormatTime(user.AccountExpirationDate), stream.FormatTime(user.CredentialsExpirationDate), user.UserType,
+		stream.FormatTime(user.LastLogin), maxSessions,
+		string(metadata), strings.Join(roles, ";"), stream.FormatTime(user.CreatedAt), stream.FormatTime(user.UpdatedAt),
+		stream.FormatTime(user.DeletedAt),
+	}
+}
+
+// GetDeletedUsers retrieves a page of the soft-deleted users and returns them as JSON, the oldest deletions first.
+// Every user carries who deleted it and when, so an admin can tell which ones can be purged.
+// @Summary      Get deleted users
+// @Description  Get a page of the soft-deleted users with who deleted them and when
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        page   query     string  false "Page number (default is 1)"
+// @Param        limit  query     string  false "Number of users per page (default is 10)"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/deleted [get]
+func (h *UserHandler) GetDeletedUsers(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		httputil.BadRequest(c, "Invalid page number", "Page must be a positive integer")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		httputil.BadRequest(c, "Invalid limit", "Limit must be a positive integer")
+		return
+	}
+
+	users, total, err := h.Service.GetDeletedUsers(c.Request.Context(), page, limit)
+	if err != nil {
+		httputil.ServerError(c, "Failed to retrieve deleted users", err)
+		return
+	}
+
+	// An empty page is a valid result and is returned as an empty array
+	responses := make([]entity.UserResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, user.ToResponse())
+	}
+
+	httputil.SuccessWithPagination(c, "Deleted users retrieved successfully", responses, httputil.NewPagination(page, limit, total))
+}
+
+// GetUserCountsByRole counts the users holding each role and returns the counts by role name as JSON, for the admin dashboards.
+// @Summary      Count users by role
+// @Description  Count the users that are not deleted holding each role, the roles without any user are left out
+// @Tags         stats
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  model.HttpResponse for successful retrieval
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /stats/users-by-role [get]
+func (h *UserHandler) GetUserCountsByRole(c *gin.Context) {
+	counts, err := h.Service.GetUserCountsByRole(c.Request.Context())
+	if err != nil {
+		httputil.ServerError(c, "Failed to count users by role", err)
+		return
+	}
+
+	httputil.Success(c, "User counts by role retrieved successfully", counts)
+}
+
+// GetUserSummary counts the users by state and returns the totals as JSON, for the admin dashboards.
+// @Summary      Summarize users
+// @Description  Count the users that are not deleted, enabled, disabled and locked, along with the deleted users
+// @Tags         stats
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  model.HttpResponse for successful retrieval
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /stats/users-summary [get]
+func (h *UserHandler) GetUserSummary(c *gin.Context) {
+	summary, err := h.Service.GetUserSummary(c.Request.Context())
+	if err != nil {
+		httputil.ServerError(c, "Failed to summarize users", err)
+		return
+	}
+
+	httputil.Success(c, "User summary retrieved successfully", summary)
+}
+
+// GetDuplicateUsers retrieves a page of the clusters of users that are likely the same person and returns them as JSON.
+// @Summary      Get duplicate users
+// @Description  Get a page of the clusters of users sharing a normalized email address, or a full name along with similar usernames, the most confident first
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        page   query     string  false "Page number (default is 1)"
+// @Param        limit  query     string  false "Number of clusters per page (default is 10)"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/users/duplicates [get]
+func (h *UserHandler) GetDuplicateUsers(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		httputil.BadRequest(c, "Invalid page number", "Page must be a positive integer")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		httputil.BadRequest(c, "Invalid limit", "Limit must be a positive integer")
+		return
+	}
+
+	clusters, total, err := h.Service.GetDuplicateUsers(c.Request.Context(), page, limit)
+	if err != nil {
+		httputil.ServerError(c, "Failed to retrieve duplicate users", err)
+		return
+	}
+
+	// An empty page is a valid result and is returned as an empty array
+	if clusters == nil {
+		clusters = []entity.DuplicateUserCluster{}
+	}
+
+	httputil.SuccessWithPagination(c, "Duplicate users retrieved successfully", clusters, httputil.NewPagination(page, limit, total))
+}
+
+// PurgeUser permanently deletes a soft-deleted user by its ID along with its sessions and history.
+// @Summary      Purge user
+// @Description  Permanently delete a user soft-deleted for longer than USER_PURGE_RETENTION_DAYS, along with its dependent records
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  model.HttpResponse for successful purge
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for a user not deleted or deleted within the retention period
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/purge [delete]
+func (h *UserHandler) PurgeUser(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Purge the user using the service
+	if err := h.Service.PurgeUser(c.Request.Context(), id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+		if errors.Is(err, service.ErrUserNotDeleted) {
+			httputil.Conflict(c, "Failed to purge user", "The user must be deleted before it is purged")
+			return
+		}
+		if errors.Is(err, service.ErrUserDeletedRecently) {
+			httputil.Conflict(c, "Failed to purge user", err.Error())
+			return
+		}
+
+		// If the error is not a known error, return a generic server error
+		// This is to avoid exposing internal details of the error
+		httputil.ServerError(c, "Failed to purge user", err)
+		return
+	}
+
+	httputil.Success(c, "User purged successfully", nil)
+}
+
+// CreateUser creates a new user and returns it as JSON.
+// @Summary      Create user
+// @Description  Create a new enabled user, the default roles are attached when no role is provided
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body      entity.UserCreateRequest  true  "User to create"
+// @Success      201  {object}  model.HttpResponse for successful creation
+// @Header       201  {string}  Location  "URL of the created user"
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      409  {object}  model.HttpResponse for conflict
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users [post]
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	// Bind the JSON request body to the UserCreateRequest struct
+	var req entity.UserCreateRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Failed to create user", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		httputil.UnprocessableEntity(c, "Failed to create user", err.Error())
+		return
+	}
+
+	// Create the user using the service
+	createdUser, err := h.Service.CreateUser(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrUserAlreadyExists) {
+			httputil.Conflict(c, "Failed to create user", err.Error())
+			return
+		}
+		var fieldErrs validation.FieldErrors
+		if errors.As(err, &fieldErrs) {
+			httputil.UnprocessableEntityMap(c, "Failed to create user", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		if errors.Is(err, service.ErrUnknownRole) || errors.Is(err, service.ErrInvalidRoleName) {
+			httputil.UnprocessableEntity(c, "Failed to create user", err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrWeakPassword) {
+			httputil.UnprocessableEntity(c, "Invalid password", err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrRoleAssignmentForbidden) {
+			httputil.Forbidden(c, "Failed to create user", err.Error())
+			return
+		}
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Failed to create user", validation.FormatLocalizedValidationErrors(c.Request.Context(), ve))
+			return
+		}
+
+		// If the error is not a known error, return a generic server error
+		// This is to avoid exposing internal details of the error
+		httputil.ServerError(c, "Failed to create user", err)
+		return
+	}
+
+	// Point the Location header at the new user, under the prefix the route is mounted on
+	location := path.Join(c.FullPath(), strconv.FormatInt(createdUser.ID, 10))
+	httputil.Created(c, "User created successfully", location, createdUser.ToResponse())
+}
+
+// RegisterUser registers a new user account and returns it as JSON.
+// The account gets the self-registration role and, when the approval is required, stays disabled until an admin approves it.
+// The route is anonymous and rate-limited when the self-registration is enabled, otherwise it is restricted to admin users.
+// @Summary      Register user
+// @Description  Register a user account with the self-registration role, pending approval unless REGISTRATION_APPROVAL_REQUIRED is false
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      entity.UserRegisterRequest  true  "Account to register"
+// @Success      201  {object}  model.HttpResponse for successful registration
+// @Failure      400  {object}  model.HttpResponse for bad request or a failed captcha
+// @Failure      401  {object}  model.HttpResponse for unauthorized when the self-registration is disabled
+// @Failure      403  {object}  model.HttpResponse for forbidden when the self-registration is disabled
+// @Failure      409  {object}  model.HttpResponse for conflict
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      429  {object}  model.HttpResponse for too many registrations from the client address
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /auth/register [post]
+func (h *UserHandler) RegisterUser(c *gin.Context) {
+	// Bind the JSON request body to the UserRegisterRequest struct
+	var req entity.UserRegisterRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Failed to register user", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		httputil.UnprocessableEntity(c, "Failed to register user", err.Error())
+		return
+	}
+
+	// The captcha is verified along with the address of the client
+	req.IPAddress = c.ClientIP()
+
+	// Register the user using the service
+	registeredUser, err := h.Service.RegisterUser(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrCaptchaRejected) {
+			httputil.BadRequest(c, "Failed to register user", "The captcha verification failed")
+			return
+		}
+		if errors.Is(err, service.ErrUserAlreadyExists) {
+			httputil.Conflict(c, "Failed to register user", err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrWeakPassword) {
+			httputil.UnprocessableEntity(c, "Invalid password", err.Error())
+			return
+		}
+
+		// If the error is not a known error, return a generic server error
+		// This is to avoid exposing internal details of the error
+		httputil.ServerError(c, "Failed to register user", err)
+		return
+	}
+
+	// The account is not readable by its anonymous creator, so no Location is returned
+	message := "User registered successfully"
+	if !*registeredUser.IsEnabled {
+		message = "User registered successfully, the account is disabled until it is approved"
+	}
+	httputil.Created(c, message, "", registeredUser.ToResponse())
+}
+
+// UpdateUserStatus updates the status flags of a user by its ID and returns the updated user as JSON.
+// @Summary      Update user status
+// @Description  Update any subset of the status flags of a user in a single call
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                       true  "User ID"
+// @Param        request  body      entity.UserStatusRequest  true  "Status flags to update"
+// @Success      200  {object}  model.HttpResponse for successful update
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/status [patch]
+func (h *UserHandler) UpdateUserStatus(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Bind the JSON request body to the UserStatusRequest struct
+	var req entity.UserStatusRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if req.IsEmpty() {
+		httputil.UnprocessableEntity(c, "Invalid request body", "At least one status flag must be provided")
+		return
+	}
+
+	// Update the user status using the service
+	updatedUser, err := h.Service.UpdateUserStatus(c.Request.Context(), id, req)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+
+		// If the error is not a record not found error, return a generic server error
+		// This is to avoid exposing internal details of the error
+		httputil.ServerError(c, "Failed to update user status", err)
+		return
+	}
+
+	httputil.Success(c, "User status updated successfully", updatedUser.ToResponse())
+}
+
+// MergeUsers merges a duplicate user into the target user and returns the changes as JSON.
+// @Summary      Merge users
+// @Description  Merge the source user into the target user: the roles are added to the target, the missing metadata keys are copied to it, the sessions of the source are revoked and the source is deleted. With dryRun, the changes are reported but not applied
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id        path      int                      true  "Target user ID"
+// @Param        request   body      entity.UserMergeRequest  true  "Source user ID and dry-run flag"
+// @Success      200  {object}  model.HttpResponse for successful merge
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      403  {object}  model.HttpResponse for forbidden role assignment
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for conflict
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/users/{id}/merge [post]
+func (h *UserHandler) MergeUsers(c *gin.Context) {
+	// Retrieve the target ID validated from the URL parameter
+	targetID, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Bind the JSON request body to the UserMergeRequest struct
+	var req entity.UserMergeRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Failed to merge users", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		httputil.UnprocessableEntity(c, "Failed to merge users", err.Error())
+		return
+	}
+
+	// Merge the users using the service
+	result, err := h.Service.MergeUsers(c.Request.Context(), targetID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrMergeIntoSelf) {
+			httputil.BadRequest(c, "Failed to merge users", err.Error())
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+		if errors.Is(err, service.ErrRoleAssignmentForbidden) {
+			httputil.Forbidden(c, "Failed to merge users", err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrMergeDeletedUser) || errors.Is(err, entity.ErrTooManyUserMetadataEntries) {
+			httputil.Conflict(c, "Failed to merge users", err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrLastAdmin) {
+			httputil.Conflict(c, "Failed to merge users", "The last enabled admin cannot be merged into a disabled user")
+			return
+		}
+
+		httputil.ServerError(c, "Failed to merge users", err)
+		return
+	}
+
+	if result.DryRun {
+		httputil.Success(c, "User merge previewed successfully", result)
+		return
+	}
+	httputil.Success(c, "Users merged successfully", result)
+}
+
+// UpdateUserRoles replaces the roles of a user and returns the updated user as JSON.
+// @Summary      Update user roles
+// @Description  Replace the roles of a user, only an admin may grant or remove the admin-level roles
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                      true  "User ID"
+// @Param        request  body      entity.UserRolesRequest  true  "New roles of the user"
+// @Success      200  {object}  model.HttpResponse for successful update
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      403  {object}  model.HttpResponse for forbidden role assignment
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for conflict
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/roles [put]
+func (h *UserHandler) UpdateUserRoles(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Bind the JSON request body to the UserRolesRequest struct
+	var req entity.UserRolesRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Failed to update user roles", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		httputil.UnprocessableEntity(c, "Failed to update user roles", err.Error())
+		return
+	}
+
+	// Replace the roles of the user using the service
+	updatedUser, err := h.Service.UpdateUserRoles(c.Request.Context(), id, req)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+		var fieldErrs validation.FieldErrors
+		if errors.As(err, &fieldErrs) {
+			httputil.UnprocessableEntityMap(c, "Failed to update user roles", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		if errors.Is(err, service.ErrUnknownRole) || errors.Is(err, service.ErrInvalidRoleName) {
+			httputil.UnprocessableEntity(c, "Failed to update user roles", err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrRoleAssignmentForbidden) {
+			httputil.Forbidden(c, "Failed to update user roles", err.Error())
+			return
+		}
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Failed to update user roles", validation.FormatLocalizedValidationErrors(c.Request.Context(), ve))
+			return
+		}
+		if errors.Is(err, service.ErrLastAdmin) {
+			httputil.Conflict(c, "Failed to update user roles", "The last enabled admin cannot lose ROLE_ADMIN")
+			return
+		}
+
+		httputil.ServerError(c, "Failed to update user roles", err)
+		return
+	}
+
+	httputil.Success(c, "User roles updated successfully", updatedUser.ToResponse())
+}
+
+// PatchUserRoles adds and removes some roles of a user and returns the updated user as JSON.
+// @Summary      Patch user roles
+// @Description  Add and remove some roles of a user, the other roles are left unchanged, only an admin may grant or remove the admin-level roles
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                           true  "User ID"
+// @Param        request  body      entity.UserRolesPatchRequest  true  "Roles to add and to remove"
+// @Success      200  {object}  model.HttpResponse for successful update
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      403  {object}  model.HttpResponse for forbidden role assignment
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for conflict
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/roles [patch]
+func (h *UserHandler) PatchUserRoles(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Bind the JSON request body to the UserRolesPatchRequest struct
+	var req entity.UserRolesPatchRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Failed to update user roles", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		httputil.UnprocessableEntity(c, "Failed to update user roles", err.Error())
+		return
+	}
+
+	// Add and remove the roles of the user using the service
+	updatedUser, err := h.Service.PatchUserRoles(c.Request.Context(), id, req)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+		var fieldErrs validation.FieldErrors
+		if errors.As(err, &fieldErrs) {
+			httputil.UnprocessableEntityMap(c, "Failed to update user roles", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		if errors.Is(err, service.ErrUnknownRole) || errors.Is(err, service.ErrInvalidRoleName) {
+			httputil.UnprocessableEntity(c, "Failed to update user roles", err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrRoleAssignmentForbidden) {
+			httputil.Forbidden(c, "Failed to update user roles", err.Error())
+			return
+		}
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Failed to update user roles", validation.FormatLocalizedValidationErrors(c.Request.Context(), ve))
+			return
+		}
+		if errors.Is(err, service.ErrLastAdmin) {
+			httputil.Conflict(c, "Failed to update user roles", "The last enabled admin cannot lose ROLE_ADMIN")
+			return
+		}
+
+		httputil.ServerError(c, "Failed to update user roles", err)
+		return
+	}
+
+	httputil.Success(c, "User roles updated successfully", updatedUser.ToResponse())
+}
+
+// UpdateUserSessionLimit overrides the maximum number of active sessions of a user and returns the updated user as JSON.
+// @Summary      Update user session limit
+// @Description  Override the maximum number of active sessions of a user, null restores the global limit
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                             true  "User ID"
+// @Param        request  body      entity.UserSessionLimitRequest  true  "Session limit"
+// @Success      200  {object}  model.HttpResponse for successful update
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/session-limit [patch]
+func (h *UserHandler) UpdateUserSessionLimit(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Bind the JSON request body to the UserSessionLimitRequest struct
+	var req entity.UserSessionLimitRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Invalid request body", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		httputil.UnprocessableEntity(c, "Invalid request body", err.Error())
+		return
+	}
+
+	// Update the session limit using the service
+	updatedUser, err := h.Service.UpdateUserSessionLimit(c.Request.Context(), id, req.MaxSessions)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+
+		// If the error is not a record not found error, return a generic server error
+		// This is to avoid exposing internal details of the error
+		httputil.ServerError(c, "Failed to update user session limit", err)
+		return
+	}
+
+	httputil.Success(c, "User session limit updated successfully", updatedUser.ToResponse())
+}
+
+// BatchGetUsers retrieves many users by their IDs and returns them as JSON, keyed by ID.
+// @Summary      Get users by IDs
+// @Description  Get up to 100 users by their IDs in one request, the unknown IDs are absent from the result
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body      entity.UserBatchGetRequest  true  "User IDs"
+// @Success      200  {object}  model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/batch-get [post]
+func (h *UserHandler) BatchGetUsers(c *gin.Context) {
+	// Bind the JSON request body to the UserBatchGetRequest struct
+	var req entity.UserBatchGetRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Invalid request body", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		httputil.UnprocessableEntity(c, "Invalid request body", err.Error())
+		return
+	}
+
+	// Retrieve the users using the service
+	users, _, err := h.Service.GetUsersByIDs(c.Request.Context(), req.IDs, true)
+	if err != nil {
+		httputil.ServerError(c, "Failed to retrieve users", err)
+		return
+	}
+
+	// Return the users without their password, keyed by ID
+	data := make(map[int64]entity.UserResponse, len(users))
+	for _, user := range users {
+		data[user.ID] = user.ToResponse()
+	}
+
+	httputil.Success(c, "Users retrieved successfully", data)
+}
+
+// BulkDeleteUsers soft-deletes many users by their IDs in a single transaction and returns the outcome for every ID as JSON.
+// @Summary      Delete users in bulk
+// @Description  Soft-delete up to 100 users in one transaction, the confirmation token must be "DELETE <number of IDs> USERS"
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body      entity.UserBulkDeleteRequest  true  "User IDs and confirmation token"
+// @Success      200  {object}  model.HttpResponse for successful deletion
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/bulk-delete [post]
+func (h *UserHandler) BulkDeleteUsers(c *gin.Context) {
+	// Bind the JSON request body to the UserBulkDeleteRequest struct
+	var req entity.UserBulkDeleteRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Invalid request body", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		httputil.UnprocessableEntity(c, "Invalid request body", err.Error())
+		return
+	}
+
+	// Reject the whole operation if the confirmation token does not match the users to delete
+	if !req.IsConfirmed() {
+		httputil.UnprocessableEntity(c, "Invalid confirmation token", fmt.Sprintf("Confirmation token must be '%s'", req.ExpectedConfirmationToken()))
+		return
+	}
+
+	// Delete the users using the service
+	results, err := h.Service.BulkDeleteUsers(c.Request.Context(), req.IDs)
+	if err != nil {
+		httputil.ServerError(c, "Failed to delete users", err)
+		return
+	}
+
+	httputil.Success(c, "Users deleted successfully", results)
+}
+
+// ResetUserPassword replaces the password of a user by its ID and returns the updated user as JSON.
+// @Summary      Reset user password
+// @Description  Replace the password of a user and revoke their sessions, the recent passwords of the user cannot be reused
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                         true  "User ID"
+// @Param        request  body      entity.UserPasswordRequest  true  "New password"
+// @Success      200  {object}  model.HttpResponse for successful update
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/password [patch]
+func (h *UserHandler) ResetUserPassword(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Bind the JSON request body to the UserPasswordRequest struct
+	var req entity.UserPasswordRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Invalid request body", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		httputil.UnprocessableEntity(c, "Invalid request body", err.Error())
+		return
+	}
+
+	// Reset the password using the service
+	updatedUser, err := h.Service.ResetUserPassword(c.Request.Context(), id, req.Password)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+		if errors.Is(err, service.ErrPasswordReused) || errors.Is(err, service.ErrWeakPassword) {
+			httputil.UnprocessableEntity(c, "Invalid password", err.Error())
+			return
+		}
+
+		// If the error is not a known error, return a generic server error
+		// This is to avoid exposing internal details of the error
+		httputil.ServerError(c, "Failed to reset user password", err)
+		return
+	}
+
+	httputil.Success(c, "User password reset successfully", updatedUser.ToResponse())
+}
+
+// ForcePasswordChange expires the credentials of a user by its ID and returns the updated user as JSON.
+// @Summary      Force password change
+// @Description  Expire the credentials of a user and revoke their sessions, the user cannot log in until the password is reset
+// @Tags         users
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  model.HttpResponse for successful update
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/force-password-change [post]
+func (h *UserHandler) ForcePasswordChange(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Expire the credentials using the service
+	updatedUser, err := h.Service.ForcePasswordChange(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+
+		// If the error is not a record not found error, return a generic server error
+		// This is to avoid exposing internal details of the error
+		httputil.ServerError(c, "Failed to force password change", err)
+		return
+	}
+
+	httputil.Success(c, "Password change forced successfully", updatedUser.ToResponse())
+}
+
+// ExpireUserCredentials expires the credentials of a user by its ID and returns the updated user as JSON.
+// @Summary      Expire user credentials
+// @Description  Expire the credentials of a user, who must change the password with POST /auth/change-password before logging in again. The sessions are revoked unless CREDENTIAL_EXPIRY_REVOKE_SESSIONS is false
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  model.HttpResponse for successful update
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/users/{id}/expire-credentials [post]
+func (h *UserHandler) ExpireUserCredentials(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	updatedUser, err := h.Service.ExpireUserCredentials(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+
+		httputil.ServerError(c, "Failed to expire user credentials", err)
+		return
+	}
+
+	httputil.Success(c, "User credentials expired successfully", updatedUser.ToResponse())
+}
+
+// ExpireRoleCredentials expires the credentials of every user holding the role and returns how many were affected as JSON.
+// @Summary      Expire role credentials
+// @Description  Expire the credentials of the users holding the role, batch by batch, except the caller and the users whose credentials are already expired. The sessions are revoked unless CREDENTIAL_EXPIRY_REVOKE_SESSIONS is false
+// @Tags         admin
+// @Produce      json
+// @Param        name  path      string  true  "Role name, e.g. ROLE_FINANCE"
+// @Success      200  {object}  model.HttpResponse for successful update
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/roles/{name}/expire-credentials [post]
+func (h *UserHandler) ExpireRoleCredentials(c *gin.Context) {
+	result, err := h.Service.ExpireRoleCredentials(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "Role not found", "No role found with the given name")
+			return
+		}
+
+		// The batches committed before the failure stay expired, running the request again expires the others
+		httputil.ServerError(c, "Failed to expire role credentials", err)
+		return
+	}
+
+	httputil.Success(c, "Role credentials expired successfully", result)
+}
+
+// ReassignRole moves the holders of a role to another one and returns how many were reassigned as JSON.
+// @Summary      Reassign role
+// @Description  Move the holders of a role to another one, batch by batch, the holders already holding the new role are skipped. With dryRun, the holders are counted without being changed. An interrupted reassignment is resumed by the next request for the same roles
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        request  body      entity.RoleReassignmentRequest  true  "Role to reassign and the role replacing it"
+// @Success      200  {object}  model.HttpResponse for successful reassignment
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      403  {object}  model.HttpResponse for forbidden role assignment
+// @Failure      409  {object}  model.HttpResponse for conflict
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/roles/reassign [post]
+func (h *UserHandler) ReassignRole(c *gin.Context) {
+	// Bind the JSON request body to the RoleReassignmentRequest struct
+	var req entity.RoleReassignmentRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.UnprocessableEntityMap(c, "Failed to reassign role", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		httputil.UnprocessableEntity(c, "Failed to reassign role", err.Error())
+		return
+	}
+
+	// Reassign the holders of the role using the service
+	result, err := h.Service.ReassignRole(c.Request.Context(), req)
+	if err != nil {
+		var fieldErrs validation.FieldErrors
+		if errors.As(err, &fieldErrs) {
+			httputil.UnprocessableEntityMap(c, "Failed to reassign role", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		if errors.Is(err, service.ErrSameRole) {
+			httputil.UnprocessableEntity(c, "Failed to reassign role", err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrRoleAssignmentForbidden) {
+			httputil.Forbidden(c, "Failed to reassign role", err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrLastAdmin) {
+			httputil.Conflict(c, "Failed to reassign role", "The last enabled admin cannot lose ROLE_ADMIN")
+			return
+		}
+
+		// The batches committed before the failure stay reassigned, running the request again resumes after them
+		httputil.ServerError(c, "Failed to reassign role", err)
+		return
+	}
+
+	if result.DryRun {
+		httputil.Success(c, "Role reassignment previewed successfully", result)
+		return
+	}
+	httputil.Success(c, "Role reassigned successfully", result)
+}
+
+// UpdateUserMetadata sets or removes metadata keys of a user by its ID and returns the updated user as JSON.
+// @Summary      Update user metadata
+// @Description  Set the provided metadata keys of a user, a null value removes the key, the keys must be in USER_METADATA_KEYS
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                         true  "User ID"
+// @Param        request  body      entity.UserMetadataRequest  true  "Metadata keys to update"
+// @Success      200  {object}  model.HttpResponse for successful update
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      422  {object}  model.HttpResponse for validation failure
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/{id}/metadata [patch]
+func (h *UserHandler) UpdateUserMetadata(c *gin.Context) {
+	// Retrieve the ID validated from the URL parameter
+	id, ok := pathparam.Int64(c, "id")
+	if !ok {
+		return
+	}
+
+	// Bind the JSON request body to the UserMetadataRequest struct
+	var req entity.UserMetadataRequest
+	if err := httputil.BindJSON(c, &req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(service.GetAllowedUserMetadataKeys()); err != nil {
+		httputil.UnprocessableEntity(c, "Invalid request body", err.Error())
+		return
+	}
+
+	// Update the user metadata using the service
+	updatedUser, err := h.Service.UpdateUserMetadata(c.Request.Context(), id, req)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "User not found", "No user found with the given ID")
+			return
+		}
+		if errors.Is(err, entity.ErrTooManyUserMetadataEntries) {
+			httputil.UnprocessableEntity(c, "Invalid request body", err.Error())
+			return
+		}
+
+		// If the error is not a record not found error, return a generic server error
+		// This is to avoid exposing internal details of the error
+		httputil.ServerError(c, "Failed to update user metadata", err)
+		return
+	}
+
+	httputil.Success(c, "User metadata updated successfully", updatedUser.ToResponse())
+}
+
+// GetUsersByMetadata retrieves the users holding a metadata key with a given value and returns them as JSON.
+// @Summary      Get users by metadata
+// @Description  Look up the users by an external identifier stored in their metadata (e.g. key=crmId&value=C-123)
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        key    query     string  true  "Metadata key"
+// @Param        value  query     string  true  "Metadata value"
+// @Success      200  {object}  model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /users/by-metadata [get]
+func (h *UserHandler) GetUsersByMetadata(c *gin.Context) {
+	// Parse the key and value from the query parameters
+	key, value := c.Query("key"), c.Query("value")
+	if key == "" || value == "" {
+		httputil.BadRequest(c, "Invalid query", "Key and value are required")
+		return
+	}
+	if !slices.Contains(service.GetAllowedUserMetadataKeys(), key) {
+		httputil.BadRequest(c, "Invalid query", fmt.Sprintf("metadata key %q is not allowed", key))
+		return
+	}
+
+	// Retrieve the users using the service
+	users, err := h.Service.GetUsersByMetadata(c.Request.Context(), key, value)
+	if err != nil {
+		httputil.ServerError(c, "Failed to retrieve users", err)
+		return
+	}
+
+	// Return the users without their password, an empty result is returned as an empty array
+	data := make([]entity.UserResponse, 0, len(users))
+	for _, user := range users {
+		data = append(data, user.ToResponse())
+	}
+
+	httputil.Success(c, "Users retrieved successfully", data)
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// Interface for audit log repository
+// This interface defines the methods that the audit log repository should implement
+type AuditLogRepository interface {
+	GetAuditLogs(tx *gorm.DB, filter entity.AuditLogFilter) ([]entity.AuditLog, error)
+	CountAuditLogs(tx *gorm.DB, filter entity.AuditLogFilter) (int64, error)
+	CreateAuditLog(tx *gorm.DB, auditLog entity.AuditLog) (entity.AuditLog, error)
+}
+
+// This struct defines the AuditLogRepository that contains methods for interacting with the database
+// It implements the AuditLogRepository interface and provides methods for audit log-related operations
+type auditLogRepository struct{}
+
+// NewAuditLogRepository creates a new instance of AuditLogRepository.
+// It initializes the auditLogRepository struct and returns it.
+func NewAuditLogRepository() AuditLogRepository {
+	return &auditLogRepository{}
+}
+
+// GetAuditLogs retrieves the audit log entries matching the filter from the database, the most recent first.
+func (r *auditLogRepository) GetAuditLogs(tx *gorm.DB, filter entity.AuditLogFilter) ([]entity.AuditLog, error) {
+	// Select the filtered audit log entries with pagination
+	var auditLogs []entity.AuditLog
+	offset := (filter.Page - 1) * filter.Limit
+	err := filterAuditLogs(tx, filter).
+		Order("created_at DESC").Order("id DESC").
+		Limit(filter.Limit).Offset(offset).
+		Find(&auditLogs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return auditLogs, nil
+}
+
+// CountAuditLogs counts the audit log entries matching the filter.
+func (r *auditLogRepository) CountAuditLogs(tx *gorm.DB, filter entity.AuditLogFilter) (int64, error) {
+	// Count the filtered audit log entries
+	var count int64
+	if err := filterAuditLogs(tx, filter).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CreateAuditLog creates a new audit log entry in the database.
+func (r *auditLogRepository) CreateAuditLog(tx *gorm.DB, auditLog entity.AuditLog) (entity.AuditLog, error) {
+	// Create the audit log entry in the database
+	if err := tx.Create(&auditLog).Error; err != nil {
+		return entity.AuditLog{}, fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	return auditLog, nil
+}
+
+// filterAuditLogs builds the query selecting the audit log entries of the tenant matching the filter.
+// Each filter is an equality or a range on an indexed column.
+func filterAuditLogs(tx *gorm.DB, filter entity.AuditLogFilter) *gorm.DB {
+	query := tx.Model(&entity.AuditLog{}).Scopes(TenantScope)
+
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+
+	return query
+}
@@ -0,0 +1,962 @@
+// Package conformance holds the test suites every implementation of a repository interface must pass,
+// so the implementations cannot drift apart, e.g. on the case of the lookups or on the error of a missing record.
+// A new method of an interface is added to its suite first: the suite fails for any method it does not cover.
+package conformance
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+// UserRepositoryFactory returns a new implementation of UserRepository along with the database it is called with.
+// The database holds the tenants 1 and 2, the roles ROLE_USER and ROLE_ADMIN, and no user. It enforces the unique
+// usernames and emails of database.MigrateUserUniqueIndexes, reports their violations as gorm.ErrDuplicatedKey,
+// and provides the normalize_email and similarity functions of the duplicate detection.
+type UserRepositoryFactory func(t *testing.T) (repository.UserRepository, *gorm.DB)
+
+// minSimilarity is the username similarity of the name clusters used by the suite.
+const minSimilarity = 0.3
+
+// userFixture holds the users created for a test of the suite, all in the tenant 1 unless stated otherwise:
+// alice (ROLE_USER and ROLE_ADMIN) and her duplicate alice.smith, reaching the same gmail inbox with the same name,
+// bob, the Unicode josé, the deleted carol, and dave of the tenant 2.
+type userFixture struct {
+	repo  repository.UserRepository
+	db    *gorm.DB // Without tenant, as the background jobs
+	tx    *gorm.DB // Restricted to the tenant 1, as the requests
+	roles map[string]entity.Role
+
+	alice, aliceSmith, bob, jose, carol, dave entity.User
+}
+
+// userTests are the tests of the suite, by the name of the method of UserRepository they cover.
+var userTests = map[string]func(t *testing.T, f *userFixture){
+	"GetUserByID":                testGetUserByID,
+	"GetUserByIDForUpdate":       testGetUserByIDForUpdate,
+	"GetUsersByIDs":              testGetUsersByIDs,
+	"GetUserByUsername":          testGetUserByUsername,
+	"GetUserByEmail":             testGetUserByEmail,
+	"GetUsersByMetadata":         testGetUsersByMetadata,
+	"GetUsers":                   testGetUsers,
+	"CountUsers":                 testCountUsers,
+	"GetUsersAfter":              testGetUsersAfter,
+	"GetUsersInBatches":          testGetUsersInBatches,
+	"GetDeletedUsers":            testGetDeletedUsers,
+	"CountDeletedUsers":          testCountDeletedUsers,
+	"CountEnabledUsersWithRole":  testCountEnabledUsersWithRole,
+	"CountUsersWithRoles":        testCountUsersWithRoles,
+	"CountUsersByRole":           testCountUsersByRole,
+	"GetUserSummary":             testGetUserSummary,
+	"GetUsersWithRoleForUpdate":  testGetUsersWithRoleForUpdate,
+	"GetDuplicateUserClusters":   testGetDuplicateUserClusters,
+	"CountDuplicateUserClusters": testCountDuplicateUserClusters,
+	"GetDuplicateClusterUsers":   testGetDuplicateClusterUsers,
+	"CreateUser":                 testCreateUser,
+	"UpdateUser":                 testUpdateUser,
+	"UpdatePasswordHash":         testUpdatePasswordHash,
+	"ReplaceUserRoles":           testReplaceUserRoles,
+	"PatchUserRoles":             testPatchUserRoles,
+	"DeleteUser":                 testDeleteUser,
+	"MergeUser":                  testMergeUser,
+	"AnonymizeUser":              testAnonymizeUser,
+	"PurgeUser":                  testPurgeUser,
+	"DeleteExpiredUserRoles":     testDeleteExpiredUserRoles,
+}
+
+// RunUserRepositoryTests runs the conformance suite of UserRepository against the implementations of the factory,
+// every test on a new one. It fails for the methods of the interface the suite does not cover.
+func RunUserRepositoryTests(t *testing.T, factory UserRepositoryFactory) {
+	repoType := reflect.TypeOf((*repository.UserRepository)(nil)).Elem()
+	for i := 0; i < repoType.NumMethod(); i++ {
+		name := repoType.Method(i).Name
+		if _, ok := userTests[name]; !ok {
+			t.Errorf("%s is not covered by the user repository conformance suite, add its test to it first", name)
+		}
+	}
+
+	for i := 0; i < repoType.NumMethod(); i++ {
+		name := repoType.Method(i).Name
+		test, ok := userTests[name]
+		if !ok {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			test(t, newUserFixture(t, factory))
+		})
+	}
+}
+
+// newUserFixture creates the users of the fixture with the implementation of the factory.
+func newUserFixture(t *testing.T, factory UserRepositoryFactory) *userFixture {
+	repo, db := factory(t)
+	f := &userFixture{
+		repo:  repo,
+		db:    db,
+		tx:    db.WithContext(metacontext.InjectTenantID(context.Background(), 1)),
+		roles: make(map[string]entity.Role),
+	}
+
+	var roles []entity.Role
+	require.NoError(t, db.Find(&roles).Error)
+	for _, role := range roles {
+		f.roles[role.Name] = role
+	}
+	require.Contains(t, f.roles, "ROLE_USER")
+	require.Contains(t, f.roles, "ROLE_ADMIN")
+
+	f.alice = f.createUser(t, 1, "alice", "alicesmith@gmail.com", "Alice", "Smith", entity.UserMetadata{"team": "blue"}, "ROLE_USER", "ROLE_ADMIN")
+	f.aliceSmith = f.createUser(t, 1, "alice.smith", "Alice.Smith+news@googlemail.com", "alice", "SMITH", entity.UserMetadata{"team": "red"}, "ROLE_USER")
+	f.bob = f.createUser(t, 1, "bob", "bob@example.com", "Bob", "", entity.UserMetadata{"team": "red", "a.b": "dotted"}, "ROLE_USER")
+	f.jose = f.createUser(t, 1, "josé", "josé@example.com", "José", "", nil, "ROLE_USER")
+	f.carol = f.createUser(t, 1, "carol", "carol@example.com", "Carol", "", entity.UserMetadata{"team": "blue"}, "ROLE_ADMIN")
+	f.dave = f.createUser(t, 2, "dave", "dave@example.com", "Dave", "", entity.UserMetadata{"team": "blue"}, "ROLE_ADMIN")
+
+	require.NoError(t, repo.DeleteUser(db, f.carol, f.alice.ID))
+	return f
+}
+
+// createUser creates an enabled user of the tenant with the given roles.
+func (f *userFixture) createUser(t *testing.T, tenantID int64, username string, email string, firstname string, lastname string,
+	metadata entity.UserMetadata, roles ...string) entity.User {
+	user := newUser(tenantID, username, email, firstname, lastname, metadata)
+	for _, name := range roles {
+		user.Roles = append(user.Roles, f.roles[name])
+	}
+
+	created, err := f.repo.CreateUser(f.db, user)
+	require.NoError(t, err)
+	require.NotZero(t, created.ID)
+	return created
+}
+
+// createGroup creates a group of the tenant with the given members and returns its ID.
+func (f *userFixture) createGroup(t *testing.T, tenantID int64, name string, members ...entity.User) int64 {
+	group := entity.Group{TenantID: tenantID, Name: name}
+	require.NoError(t, f.db.Create(&group).Error)
+	for _, member := range members {
+		membership := entity.GroupMembership{GroupID: group.ID, UserID: member.ID, Role: entity.GroupRoleMember, CreatedAt: time.Now().UTC()}
+		require.NoError(t, f.db.Omit(clause.Associations).Create(&membership).Error)
+	}
+	return group.ID
+}
+
+// newUser returns an enabled user of the tenant, ready to be created.
+func newUser(tenantID int64, username string, email string, firstname string, lastname string, metadata entity.UserMetadata) entity.User {
+	enabled, deleted := true, false
+	user := entity.User{
+		TenantID:  tenantID,
+		Username:  username,
+		Password:  "!",
+		Email:     email,
+		Firstname: firstname,
+		IsEnabled: &enabled,
+		IsDeleted: &deleted,
+		UserType:  "USER_ACCOUNT",
+		Metadata:  metadata,
+	}
+	if lastname != "" {
+		user.Lastname = &lastname
+	}
+	if user.Metadata == nil {
+		user.Metadata = entity.UserMetadata{}
+	}
+	return user
+}
+
+// ids returns the IDs of the users, in their order.
+func ids(users []entity.User) []int64 {
+	result := make([]int64, 0, len(users))
+	for _, user := range users {
+		result = append(result, user.ID)
+	}
+	return result
+}
+
+// roleNames returns the sorted names of the roles.
+func roleNames(roles []entity.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func testGetUserByID(t *testing.T, f *userFixture) {
+	user, err := f.repo.GetUserByID(f.tx, f.alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, entity.UserMetadata{"team": "blue"}, user.Metadata)
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, roleNames(user.Roles))
+
+	_, err = f.repo.GetUserByID(f.tx, 1_000_000)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// A deleted user is only found with WithDeleted, a user of another tenant never
+	_, err = f.repo.GetUserByID(f.tx, f.carol.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	user, err = f.repo.GetUserByID(f.tx, f.carol.ID, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.True(t, user.DeletedAt.Valid)
+	_, err = f.repo.GetUserByID(f.tx, f.dave.ID, repository.WithDeleted())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// Without tenant, the users of every tenant are found
+	_, err = f.repo.GetUserByID(f.db, f.dave.ID)
+	assert.NoError(t, err)
+}
+
+func testGetUserByIDForUpdate(t *testing.T, f *userFixture) {
+	err := f.tx.Transaction(func(tx *gorm.DB) error {
+		user, err := f.repo.GetUserByIDForUpdate(tx, f.bob.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "bob", user.Username)
+
+		_, err = f.repo.GetUserByIDForUpdate(tx, 1_000_000)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+		_, err = f.repo.GetUserByIDForUpdate(tx, f.carol.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		_, err = f.repo.GetUserByIDForUpdate(tx, f.carol.ID, repository.WithDeleted())
+		assert.NoError(t, err)
+
+		_, err = f.repo.GetUserByIDForUpdate(tx, f.dave.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testGetUsersByIDs(t *testing.T, f *userFixture) {
+	// The users come in the order of the IDs, once, and the IDs of no visible user are missing
+	users, missing, err := f.repo.GetUsersByIDs(f.tx, []int64{f.bob.ID, 1_000_000, f.alice.ID, f.bob.ID, f.carol.ID, f.dave.ID}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.bob.ID, f.alice.ID}, ids(users))
+	assert.Equal(t, []int64{1_000_000, f.carol.ID, f.dave.ID}, missing)
+	assert.Equal(t, []string{"ROLE_USER"}, roleNames(users[0].Roles))
+
+	users, missing, err = f.repo.GetUsersByIDs(f.tx, []int64{f.alice.ID, f.carol.ID}, false, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.alice.ID, f.carol.ID}, ids(users))
+	assert.Empty(t, missing)
+	assert.Empty(t, users[0].Roles)
+
+	users, missing, err = f.repo.GetUsersByIDs(f.tx, nil, true)
+	require.NoError(t, err)
+	assert.NotNil(t, users)
+	assert.Empty(t, users)
+	assert.Empty(t, missing)
+}
+
+func testGetUserByUsername(t *testing.T, f *userFixture) {
+	// The usernames are compared case-insensitively, Unicode letters included
+	for _, username := range []string{"alice", "ALICE", "Alice"} {
+		user, err := f.repo.GetUserByUsername(f.tx, username)
+		require.NoError(t, err, username)
+		assert.Equal(t, f.alice.ID, user.ID, username)
+		assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, roleNames(user.Roles))
+	}
+	for _, username := range []string{"josé", "JOSÉ"} {
+		user, err := f.repo.GetUserByUsername(f.tx, username)
+		require.NoError(t, err, username)
+		assert.Equal(t, f.jose.ID, user.ID, username)
+	}
+
+	// Neither a deleted user nor a user of another tenant is found, nor a partial username
+	for _, username := range []string{"carol", "dave", "ali", "jose", ""} {
+		_, err := f.repo.GetUserByUsername(f.tx, username)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, username)
+	}
+}
+
+func testGetUserByEmail(t *testing.T, f *userFixture) {
+	for _, email := range []string{"bob@example.com", "BOB@Example.COM"} {
+		user, err := f.repo.GetUserByEmail(f.tx, email)
+		require.NoError(t, err, email)
+		assert.Equal(t, f.bob.ID, user.ID, email)
+	}
+	user, err := f.repo.GetUserByEmail(f.tx, "JOSÉ@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, f.jose.ID, user.ID)
+
+	// The emails are compared as they are, not normalized like the duplicate detection does
+	for _, email := range []string{"alice.smith@gmail.com", "carol@example.com", "dave@example.com"} {
+		_, err := f.repo.GetUserByEmail(f.tx, email)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, email)
+	}
+}
+
+func testGetUsersByMetadata(t *testing.T, f *userFixture) {
+	users, err := f.repo.GetUsersByMetadata(f.tx, "team", "red")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.aliceSmith.ID, f.bob.ID}, ids(users))
+
+	users, err = f.repo.GetUsersByMetadata(f.tx, "team", "blue", repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.alice.ID, f.carol.ID}, ids(users))
+
+	// A key holding a dot is a plain key, not a nested path
+	users, err = f.repo.GetUsersByMetadata(f.tx, "a.b", "dotted")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.bob.ID}, ids(users))
+
+	users, err = f.repo.GetUsersByMetadata(f.tx, "team", "Red")
+	require.NoError(t, err)
+	assert.Empty(t, users)
+	users, err = f.repo.GetUsersByMetadata(f.tx, "missing", "red")
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func testGetUsers(t *testing.T, f *userFixture) {
+	users, err := f.repo.GetUsers(f.tx, entity.UserFilter{}, 1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.alice.ID, f.aliceSmith.ID, f.bob.ID}, ids(users))
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, roleNames(users[0].Roles))
+
+	// The last page is partial, the pages past it are empty
+	users, err = f.repo.GetUsers(f.tx, entity.UserFilter{}, 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.jose.ID}, ids(users))
+	users, err = f.repo.GetUsers(f.tx, entity.UserFilter{}, 3, 3)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	users, err = f.repo.GetUsers(f.tx, entity.UserFilter{}, 1, 10, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.alice.ID, f.aliceSmith.ID, f.bob.ID, f.jose.ID, f.carol.ID}, ids(users))
+
+	// The users modified after the given time come in the order of their updates
+	time.Sleep(10 * time.Millisecond)
+	since := time.Now().UTC()
+	time.Sleep(10 * time.Millisecond)
+	for _, user := range []entity.User{f.jose, f.bob} {
+		user.Firstname += "!"
+		_, err := f.repo.UpdateUser(f.db, user)
+		require.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	users, err = f.repo.GetUsers(f.tx, entity.UserFilter{ModifiedSince: &since}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.jose.ID, f.bob.ID}, ids(users))
+
+	// The update time of a user is included by UpdatedSince, excluded by ModifiedSince
+	jose, err := f.repo.GetUserByID(f.tx, f.jose.ID)
+	require.NoError(t, err)
+	users, err = f.repo.GetUsers(f.tx, entity.UserFilter{UpdatedSince: jose.UpdatedAt}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.jose.ID, f.bob.ID}, ids(users))
+	users, err = f.repo.GetUsers(f.tx, entity.UserFilter{ModifiedSince: jose.UpdatedAt}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.bob.ID}, ids(users))
+
+	// The users created since a time come in the order of their creation
+	createdSince := time.Now().UTC()
+	time.Sleep(10 * time.Millisecond)
+	erin := f.createUser(t, 1, "erin", "erin@example.com", "Erin", "", nil, "ROLE_USER")
+	time.Sleep(10 * time.Millisecond)
+	frank := f.createUser(t, 1, "frank", "frank@example.com", "Frank", "", nil, "ROLE_USER")
+	require.NoError(t, f.repo.DeleteUser(f.db, frank, f.alice.ID))
+
+	users, err = f.repo.GetUsers(f.tx, entity.UserFilter{CreatedSince: &createdSince}, 1, 10, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, []int64{erin.ID, frank.ID}, ids(users))
+	users, err = f.repo.GetUsers(f.tx, entity.UserFilter{CreatedSince: &createdSince}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{erin.ID}, ids(users))
+
+	// The filters are combined, the users updated but created before are left out
+	users, err = f.repo.GetUsers(f.tx, entity.UserFilter{UpdatedSince: &since, CreatedSince: &createdSince}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{erin.ID}, ids(users))
+
+	// Only the members of the group are returned
+	groupID := f.createGroup(t, 1, "Support", f.bob, erin)
+	users, err = f.repo.GetUsers(f.tx, entity.UserFilter{GroupID: &groupID}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.bob.ID, erin.ID}, ids(users))
+	users, err = f.repo.GetUsers(f.tx, entity.UserFilter{GroupID: &groupID, CreatedSince: &createdSince}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{erin.ID}, ids(users))
+}
+
+func testCountUsers(t *testing.T, f *userFixture) {
+	total, err := f.repo.CountUsers(f.tx, entity.UserFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+
+	total, err = f.repo.CountUsers(f.tx, entity.UserFilter{}, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+
+	// Without tenant, the users of every tenant are counted
+	total, err = f.repo.CountUsers(f.db, entity.UserFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+
+	future := time.Now().UTC().Add(time.Hour)
+	total, err = f.repo.CountUsers(f.tx, entity.UserFilter{ModifiedSince: &future})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	total, err = f.repo.CountUsers(f.tx, entity.UserFilter{CreatedSince: &future})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	// The deleted carol is only counted as a member with WithDeleted
+	groupID := f.createGroup(t, 1, "Support", f.bob, f.carol)
+	total, err = f.repo.CountUsers(f.tx, entity.UserFilter{GroupID: &groupID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	total, err = f.repo.CountUsers(f.tx, entity.UserFilter{GroupID: &groupID}, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+}
+
+func testGetUsersAfter(t *testing.T, f *userFixture) {
+	users, err := f.repo.GetUsersAfter(f.tx, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.alice.ID, f.aliceSmith.ID}, ids(users))
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, roleNames(users[0].Roles))
+
+	// The list resumes after the last ID, a user deleted meanwhile does not shift it
+	require.NoError(t, f.repo.DeleteUser(f.db, f.alice, f.alice.ID))
+	users, err = f.repo.GetUsersAfter(f.tx, f.aliceSmith.ID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.bob.ID, f.jose.ID}, ids(users))
+
+	users, err = f.repo.GetUsersAfter(f.tx, f.jose.ID, 2)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+	users, err = f.repo.GetUsersAfter(f.tx, f.jose.ID, 2, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.carol.ID}, ids(users))
+}
+
+func testGetUsersInBatches(t *testing.T, f *userFixture) {
+	var batches [][]int64
+	err := f.repo.GetUsersInBatches(f.tx, nil, 3, func(users []entity.User) error {
+		batches = append(batches, ids(users))
+		if len(batches) == 1 {
+			assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, roleNames(users[0].Roles))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]int64{{f.alice.ID, f.aliceSmith.ID, f.bob.ID}, {f.jose.ID}}, batches)
+
+	// The users updated after the given time, the deleted ones with WithDeleted
+	time.Sleep(10 * time.Millisecond)
+	since := time.Now().UTC()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, f.repo.DeleteUser(f.db, f.bob, f.alice.ID))
+	f.jose.Firstname += "!"
+	_, err = f.repo.UpdateUser(f.db, f.jose)
+	require.NoError(t, err)
+
+	var users []int64
+	err = f.repo.GetUsersInBatches(f.tx, &since, 3, func(batch []entity.User) error {
+		users = append(users, ids(batch)...)
+		return nil
+	}, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.bob.ID, f.jose.ID}, users)
+
+	// The reading stops at the first error of the callback
+	calls := 0
+	err = f.repo.GetUsersInBatches(f.tx, nil, 1, func([]entity.User) error {
+		calls++
+		return context.Canceled
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+}
+
+func testGetDeletedUsers(t *testing.T, f *userFixture) {
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, f.repo.DeleteUser(f.db, f.bob, f.alice.ID))
+
+	// The oldest deletions first
+	users, err := f.repo.GetDeletedUsers(f.tx, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.carol.ID, f.bob.ID}, ids(users))
+	assert.Equal(t, []string{"ROLE_ADMIN"}, roleNames(users[0].Roles))
+
+	users, err = f.repo.GetDeletedUsers(f.tx, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.bob.ID}, ids(users))
+	users, err = f.repo.GetDeletedUsers(f.tx, 3, 1)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func testCountDeletedUsers(t *testing.T, f *userFixture) {
+	total, err := f.repo.CountDeletedUsers(f.tx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	require.NoError(t, f.repo.DeleteUser(f.db, f.dave, f.alice.ID))
+	total, err = f.repo.CountDeletedUsers(f.tx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	total, err = f.repo.CountDeletedUsers(f.db)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+}
+
+func testCountEnabledUsersWithRole(t *testing.T, f *userFixture) {
+	// The role names are compared case-insensitively, the deleted carol is only counted with WithDeleted
+	for _, name := range []string{"ROLE_ADMIN", "role_admin"} {
+		total, err := f.repo.CountEnabledUsersWithRole(f.tx, name)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total, name)
+	}
+	total, err := f.repo.CountEnabledUsersWithRole(f.tx, "ROLE_ADMIN", repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	total, err = f.repo.CountEnabledUsersWithRole(f.tx, "ROLE_USER")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+
+	// A disabled user is not counted
+	disabled := false
+	f.bob.IsEnabled = &disabled
+	_, err = f.repo.UpdateUser(f.db, f.bob)
+	require.NoError(t, err)
+	total, err = f.repo.CountEnabledUsersWithRole(f.tx, "ROLE_USER")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+
+	total, err = f.repo.CountEnabledUsersWithRole(f.tx, "ROLE_UNKNOWN")
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func testCountUsersWithRoles(t *testing.T, f *userFixture) {
+	// The users holding every role are counted, the names are compared case-insensitively
+	total, err := f.repo.CountUsersWithRoles(f.tx, []string{"ROLE_USER"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+
+	total, err = f.repo.CountUsersWithRoles(f.tx, []string{"role_user", "ROLE_ADMIN"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	// The deleted carol is only counted with WithDeleted, and dave of the other tenant never
+	total, err = f.repo.CountUsersWithRoles(f.tx, []string{"ROLE_ADMIN"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	total, err = f.repo.CountUsersWithRoles(f.tx, []string{"ROLE_ADMIN"}, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	// A disabled user is counted
+	disabled := false
+	f.bob.IsEnabled = &disabled
+	_, err = f.repo.UpdateUser(f.db, f.bob)
+	require.NoError(t, err)
+	total, err = f.repo.CountUsersWithRoles(f.tx, []string{"ROLE_USER"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+
+	total, err = f.repo.CountUsersWithRoles(f.tx, []string{"ROLE_USER", "ROLE_UNKNOWN"})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func testCountUsersByRole(t *testing.T, f *userFixture) {
+	// The deleted carol is only counted with WithDeleted, the roles without users are left out
+	counts, err := f.repo.CountUsersByRole(f.tx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"ROLE_ADMIN": 1, "ROLE_USER": 4}, counts)
+
+	counts, err = f.repo.CountUsersByRole(f.tx, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"ROLE_ADMIN": 2, "ROLE_USER": 4}, counts)
+
+	// Without tenant, the users of every tenant are counted
+	counts, err = f.repo.CountUsersByRole(f.db)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"ROLE_ADMIN": 2, "ROLE_USER": 4}, counts)
+}
+
+func testGetUserSummary(t *testing.T, f *userFixture) {
+	// bob is disabled and stays locked, the other users of the tenant are unlocked
+	enabled, disabled, unlocked := true, false, true
+	for _, user := range []entity.User{f.alice, f.aliceSmith, f.bob, f.jose} {
+		if user.ID == f.bob.ID {
+			user.IsEnabled = &disabled
+		} else {
+			user.IsEnabled = &enabled
+			user.IsAccountNonLocked = &unlocked
+		}
+		_, err := f.repo.UpdateUser(f.db, user)
+		require.NoError(t, err)
+	}
+
+	summary, err := f.repo.GetUserSummary(f.tx)
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserSummary{Total: 4, Enabled: 3, Disabled: 1, Locked: 1, Deleted: 1}, summary)
+
+	// Without tenant, the users of every tenant are counted
+	summary, err = f.repo.GetUserSummary(f.db)
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserSummary{Total: 5, Enabled: 4, Disabled: 1, Locked: 2, Deleted: 1}, summary)
+}
+
+func testGetUsersWithRoleForUpdate(t *testing.T, f *userFixture) {
+	// The holders of the role are walked through batch by batch, the role name is compared case-insensitively
+	users, err := f.repo.GetUsersWithRoleForUpdate(f.tx, "role_user", 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.alice.ID, f.aliceSmith.ID}, ids(users))
+	users, err = f.repo.GetUsersWithRoleForUpdate(f.tx, "ROLE_USER", f.aliceSmith.ID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.bob.ID, f.jose.ID}, ids(users))
+	users, err = f.repo.GetUsersWithRoleForUpdate(f.tx, "ROLE_USER", f.jose.ID, 2)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	// The deleted carol only holds the role with WithDeleted, dave is of another tenant
+	users, err = f.repo.GetUsersWithRoleForUpdate(f.tx, "ROLE_ADMIN", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.alice.ID}, ids(users))
+	users, err = f.repo.GetUsersWithRoleForUpdate(f.tx, "ROLE_ADMIN", 0, 10, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, []int64{f.alice.ID, f.carol.ID}, ids(users))
+
+	users, err = f.repo.GetUsersWithRoleForUpdate(f.tx, "ROLE_UNKNOWN", 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func testGetDuplicateUserClusters(t *testing.T, f *userFixture) {
+	// alice and alice.smith reach the same inbox and share a name, the email is the most confident
+	clusters, err := f.repo.GetDuplicateUserClusters(f.tx, minSimilarity, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, clusters, 2)
+	assert.Equal(t, entity.DuplicateReasonEmail, clusters[0].Reason)
+	assert.Equal(t, "alicesmith@gmail.com", clusters[0].Key)
+	assert.Equal(t, int64(1), clusters[0].TenantID)
+	assert.Equal(t, entity.DuplicateReasonName, clusters[1].Reason)
+	assert.Equal(t, "alice smith", clusters[1].Key)
+	assert.Less(t, clusters[1].Confidence, clusters[0].Confidence)
+
+	clusters, err = f.repo.GetDuplicateUserClusters(f.tx, minSimilarity, 2, 1)
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+	assert.Equal(t, entity.DuplicateReasonName, clusters[0].Reason)
+	clusters, err = f.repo.GetDuplicateUserClusters(f.tx, minSimilarity, 3, 1)
+	require.NoError(t, err)
+	assert.Empty(t, clusters)
+
+	// The usernames are not similar enough for a stricter threshold
+	clusters, err = f.repo.GetDuplicateUserClusters(f.tx, 0.99, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+	assert.Equal(t, entity.DuplicateReasonEmail, clusters[0].Reason)
+}
+
+func testCountDuplicateUserClusters(t *testing.T, f *userFixture) {
+	total, err := f.repo.CountDuplicateUserClusters(f.tx, minSimilarity)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	// A deleted user leaves its clusters, which no longer hold two users
+	require.NoError(t, f.repo.DeleteUser(f.db, f.aliceSmith, f.alice.ID))
+	total, err = f.repo.CountDuplicateUserClusters(f.tx, minSimilarity)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	total, err = f.repo.CountDuplicateUserClusters(f.tx, minSimilarity, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+}
+
+func testGetDuplicateClusterUsers(t *testing.T, f *userFixture) {
+	for _, cluster := range []entity.DuplicateUserCluster{
+		{TenantID: 1, Reason: entity.DuplicateReasonEmail, Key: "alicesmith@gmail.com"},
+		{TenantID: 1, Reason: entity.DuplicateReasonName, Key: "alice smith"},
+	} {
+		users, err := f.repo.GetDuplicateClusterUsers(f.tx, cluster, minSimilarity)
+		require.NoError(t, err, cluster.Reason)
+		assert.Equal(t, []int64{f.alice.ID, f.aliceSmith.ID}, ids(users), cluster.Reason)
+	}
+
+	users, err := f.repo.GetDuplicateClusterUsers(f.tx, entity.DuplicateUserCluster{TenantID: 2, Reason: entity.DuplicateReasonEmail, Key: "alicesmith@gmail.com"}, minSimilarity)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	_, err = f.repo.GetDuplicateClusterUsers(f.tx, entity.DuplicateUserCluster{TenantID: 1, Reason: "phone", Key: "alice"}, minSimilarity)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func testCreateUser(t *testing.T, f *userFixture) {
+	user, err := f.repo.GetUserByID(f.tx, f.alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), user.TenantID)
+	assert.NotNil(t, user.CreatedAt)
+
+	// The usernames and the emails are unique per tenant among the users that are not deleted
+	for _, taken := range [][2]string{{"bob", "bob2@example.com"}, {"bob2", "bob@example.com"}} {
+		_, err := f.repo.CreateUser(f.db, newUser(1, taken[0], taken[1], "Bob", "", nil))
+		assert.ErrorIs(t, err, gorm.ErrDuplicatedKey, taken)
+	}
+	f.createUser(t, 2, "bob", "bob@example.com", "Bob", "", nil, "ROLE_USER")
+	carol := f.createUser(t, 1, "carol", "carol@example.com", "Carol", "", nil)
+	assert.NotEqual(t, f.carol.ID, carol.ID)
+
+	// The roles are not created, only assigned
+	user, err = f.repo.GetUserByID(f.tx, carol.ID)
+	require.NoError(t, err)
+	assert.Empty(t, user.Roles)
+	var roles int64
+	require.NoError(t, f.db.Model(&entity.Role{}).Count(&roles).Error)
+	assert.Equal(t, int64(len(f.roles)), roles)
+}
+
+func testUpdateUser(t *testing.T, f *userFixture) {
+	// The update time is copied, the updates write it through the pointer
+	createdUpdatedAt := *f.bob.UpdatedAt
+	lastname := "Builder"
+	f.bob.Firstname = "Robert"
+	f.bob.Lastname = &lastname
+	f.bob.Metadata = entity.UserMetadata{"team": "green"}
+	updated, err := f.repo.UpdateUser(f.tx, f.bob)
+	require.NoError(t, err)
+	assert.Equal(t, "Robert", updated.Firstname)
+
+	user, err := f.repo.GetUserByID(f.tx, f.bob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Robert", user.Firstname)
+	require.NotNil(t, user.Lastname)
+	assert.Equal(t, "Builder", *user.Lastname)
+	assert.Equal(t, entity.UserMetadata{"team": "green"}, user.Metadata)
+	assert.True(t, user.UpdatedAt.After(createdUpdatedAt))
+
+	// The login only sets the last login time, the user is touched all the same
+	updatedAt := *user.UpdatedAt
+	lastLogin := time.Now().UTC()
+	user.LastLogin = &lastLogin
+	_, err = f.repo.UpdateUser(f.tx, user)
+	require.NoError(t, err)
+	loggedIn, err := f.repo.GetUserByID(f.tx, f.bob.ID)
+	require.NoError(t, err)
+	assert.True(t, loggedIn.UpdatedAt.After(updatedAt))
+
+	// A username taken by another user is refused
+	f.bob.Username = "alice"
+	_, err = f.repo.UpdateUser(f.tx, f.bob)
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+}
+
+func testUpdatePasswordHash(t *testing.T, f *userFixture) {
+	updatedAt := *f.bob.UpdatedAt
+	require.NoError(t, f.repo.UpdatePasswordHash(f.tx, f.bob.ID, "$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$a2V5"))
+
+	// Only the hash changes, the user is not touched
+	user, err := f.repo.GetUserByID(f.tx, f.bob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$a2V5", user.Password)
+	assert.True(t, user.UpdatedAt.Equal(updatedAt))
+	alice, err := f.repo.GetUserByID(f.tx, f.alice.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, user.Password, alice.Password)
+
+	assert.ErrorIs(t, f.repo.UpdatePasswordHash(f.tx, 1_000_000, "hash"), gorm.ErrRecordNotFound)
+}
+
+func testReplaceUserRoles(t *testing.T, f *userFixture) {
+	updated, err := f.repo.ReplaceUserRoles(f.tx, f.bob, []entity.Role{f.roles["ROLE_ADMIN"], f.roles["ROLE_USER"]})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, roleNames(updated.Roles))
+
+	user, err := f.repo.GetUserByID(f.tx, f.bob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, roleNames(user.Roles))
+
+	// The user is touched, so the lists of the changes see its new roles
+	assert.True(t, user.UpdatedAt.After(*f.bob.UpdatedAt))
+
+	_, err = f.repo.ReplaceUserRoles(f.tx, f.bob, nil)
+	require.NoError(t, err)
+	user, err = f.repo.GetUserByID(f.tx, f.bob.ID)
+	require.NoError(t, err)
+	assert.Empty(t, user.Roles)
+
+	// The roles of the other users are untouched
+	user, err = f.repo.GetUserByID(f.tx, f.alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, roleNames(user.Roles))
+}
+
+func testPatchUserRoles(t *testing.T, f *userFixture) {
+	bob, err := f.repo.ReplaceUserRoles(f.tx, f.bob, []entity.Role{f.roles["ROLE_USER"]})
+	require.NoError(t, err)
+
+	updated, err := f.repo.PatchUserRoles(f.tx, bob, []entity.Role{f.roles["ROLE_ADMIN"]}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, roleNames(updated.Roles))
+
+	updated, err = f.repo.PatchUserRoles(f.tx, updated, nil, []entity.Role{f.roles["ROLE_USER"]})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_ADMIN"}, roleNames(updated.Roles))
+
+	user, err := f.repo.GetUserByID(f.tx, f.bob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_ADMIN"}, roleNames(user.Roles))
+
+	// The user is touched, so the lists of the changes see its new roles
+	assert.True(t, user.UpdatedAt.After(*f.bob.UpdatedAt))
+
+	// The roles of the other users are untouched
+	user, err = f.repo.GetUserByID(f.tx, f.alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, roleNames(user.Roles))
+}
+
+func testDeleteUser(t *testing.T, f *userFixture) {
+	require.NoError(t, f.repo.DeleteUser(f.tx, f.bob, f.alice.ID))
+
+	_, err := f.repo.GetUserByID(f.tx, f.bob.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = f.repo.GetUserByUsername(f.tx, "bob")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	user, err := f.repo.GetUserByID(f.tx, f.bob.ID, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.True(t, user.DeletedAt.Valid)
+	require.NotNil(t, user.IsDeleted)
+	assert.True(t, *user.IsDeleted)
+	require.NotNil(t, user.DeletedBy)
+	assert.Equal(t, f.alice.ID, *user.DeletedBy)
+	assert.Nil(t, user.MergedInto)
+}
+
+func testMergeUser(t *testing.T, f *userFixture) {
+	require.NoError(t, f.repo.MergeUser(f.tx, f.aliceSmith, f.alice.ID, f.bob.ID))
+
+	_, err := f.repo.GetUserByID(f.tx, f.aliceSmith.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	user, err := f.repo.GetUserByID(f.tx, f.aliceSmith.ID, repository.WithDeleted())
+	require.NoError(t, err)
+	require.NotNil(t, user.MergedInto)
+	assert.Equal(t, f.alice.ID, *user.MergedInto)
+	require.NotNil(t, user.DeletedBy)
+	assert.Equal(t, f.bob.ID, *user.DeletedBy)
+	require.NotNil(t, user.IsDeleted)
+	assert.True(t, *user.IsDeleted)
+
+	// The target is untouched
+	user, err = f.repo.GetUserByID(f.tx, f.alice.ID)
+	require.NoError(t, err)
+	assert.Nil(t, user.MergedInto)
+}
+
+func testAnonymizeUser(t *testing.T, f *userFixture) {
+	require.NoError(t, f.db.Create(&entity.UserNote{UserID: f.bob.ID, AuthorID: f.alice.ID, Body: "Closed on request", CreatedAt: time.Now().UTC()}).Error)
+	anonymized, err := f.repo.AnonymizeUser(f.tx, f.bob)
+	require.NoError(t, err)
+
+	user, err := f.repo.GetUserByID(f.tx, f.bob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, anonymized.Username, user.Username)
+	assert.NotContains(t, user.Username, "bob")
+	assert.NotContains(t, user.Email, "bob")
+	assert.NotEqual(t, "Bob", user.Firstname)
+	assert.Nil(t, user.Lastname)
+	assert.Empty(t, user.Metadata)
+	assert.Equal(t, []string{"ROLE_USER"}, roleNames(user.Roles))
+
+	// The notes on the user are the data of the support, they are kept
+	var notes int64
+	require.NoError(t, f.db.Model(&entity.UserNote{}).Where("user_id = ?", f.bob.ID).Count(&notes).Error)
+	assert.Equal(t, int64(1), notes)
+
+	// The username and the email are released
+	_, err = f.repo.GetUserByUsername(f.tx, "bob")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	f.createUser(t, 1, "bob", "bob@example.com", "Bob", "", nil)
+}
+
+func testPurgeUser(t *testing.T, f *userFixture) {
+	require.NoError(t, f.db.Create(&entity.UserNote{UserID: f.carol.ID, AuthorID: f.alice.ID, Body: "Closed on request", CreatedAt: time.Now().UTC()}).Error)
+	require.NoError(t, f.db.Create(&entity.UserRegistration{UserID: f.carol.ID, TenantID: 1, Status: entity.RegistrationStatusApproved, CreatedAt: time.Now().UTC()}).Error)
+	groupID := f.createGroup(t, 1, "Support", f.bob, f.carol)
+	require.NoError(t, f.repo.PurgeUser(f.tx, f.carol.ID))
+
+	_, err := f.repo.GetUserByID(f.tx, f.carol.ID, repository.WithDeleted())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	total, err := f.repo.CountDeletedUsers(f.tx)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	// The roles of the purged user are gone, the roles of the others are kept
+	total, err = f.repo.CountEnabledUsersWithRole(f.tx, "ROLE_ADMIN", repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	var userRoles int64
+	require.NoError(t, f.db.Model(&entity.UserRole{}).Where("user_id = ?", f.carol.ID).Count(&userRoles).Error)
+	assert.Zero(t, userRoles)
+
+	// The notes on the purged user and its registration are gone along with it
+	var notes, registrations int64
+	require.NoError(t, f.db.Model(&entity.UserNote{}).Where("user_id = ?", f.carol.ID).Count(&notes).Error)
+	assert.Zero(t, notes)
+	require.NoError(t, f.db.Model(&entity.UserRegistration{}).Where("user_id = ?", f.carol.ID).Count(&registrations).Error)
+	assert.Zero(t, registrations)
+
+	// The purged user leaves its groups, the other members stay
+	var members []int64
+	require.NoError(t, f.db.Model(&entity.GroupMembership{}).Where("group_id = ?", groupID).Pluck("user_id", &members).Error)
+	assert.Equal(t, []int64{f.bob.ID}, members)
+}
+
+func testDeleteExpiredUserRoles(t *testing.T, f *userFixture) {
+	now := time.Now().UTC()
+	expire := func(user entity.User, role string, expiresAt time.Time) {
+		require.NoError(t, f.db.Model(&entity.UserRole{}).
+			Where("user_id = ? AND role_id = ?", user.ID, f.roles[role].ID).
+			Update("expires_at", expiresAt).Error)
+	}
+	expire(f.bob, "ROLE_USER", now.Add(-60*24*time.Hour))
+	expire(f.jose, "ROLE_USER", now.Add(-time.Hour))
+	expire(f.alice, "ROLE_ADMIN", now.Add(time.Hour))
+
+	// The expired roles are ignored by the lookups before being removed
+	bob, err := f.repo.GetUserByID(f.tx, f.bob.ID)
+	require.NoError(t, err)
+	assert.Empty(t, bob.Roles)
+	alice, err := f.repo.GetUserByID(f.tx, f.alice.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, roleNames(alice.Roles))
+
+	// Only the assignments expired before the given time are removed, across the tenants
+	deleted, err := f.repo.DeleteExpiredUserRoles(f.db, now.Add(-30*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	var userRoles int64
+	require.NoError(t, f.db.Model(&entity.UserRole{}).Where("user_id = ?", f.bob.ID).Count(&userRoles).Error)
+	assert.Zero(t, userRoles)
+	require.NoError(t, f.db.Model(&entity.UserRole{}).Where("user_id IN ?", []int64{f.jose.ID, f.alice.ID}).Count(&userRoles).Error)
+	assert.Equal(t, int64(3), userRoles)
+
+	deleted, err = f.repo.DeleteExpiredUserRoles(f.db, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
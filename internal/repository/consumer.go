@@ -17,6 +17,8 @@ type ConsumerRepository interface {
 	GetConsumerByEmail(tx *gorm.DB, email string) (entity.Consumer, error)
 	GetConsumerByPhone(tx *gorm.DB, phone string) (entity.Consumer, error)
 	GetConsumersByStatus(tx *gorm.DB, status string, page int, limit int) ([]entity.Consumer, error)
+	CountConsumers(tx *gorm.DB) (int64, error)
+	CountConsumersByStatus(tx *gorm.DB, status string) (int64, error)
 	CreateConsumer(tx *gorm.DB, d entity.Consumer) (entity.Consumer, error)
 	UpdateConsumer(tx *gorm.DB, d entity.Consumer) (entity.Consumer, error)
 }
@@ -111,6 +113,26 @@ func (r *consumerRepository) GetConsumersByStatus(tx *gorm.DB, status string, pa
 	return consumers, nil
 }
 
+// CountConsumers counts all consumers in the database.
+func (r *consumerRepository) CountConsumers(tx *gorm.DB) (int64, error) {
+	var total int64
+	if err := tx.Model(&entity.Consumer{}).Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// CountConsumersByStatus counts the consumers with the given status in the database.
+func (r *consumerRepository) CountConsumersByStatus(tx *gorm.DB, status string) (int64, error) {
+	var total int64
+	if err := tx.Model(&entity.Consumer{}).Where("status = ?", status).Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
 // CreateConsumer creates a new consumer in the database and returns the created consumer.
 func (r *consumerRepository) CreateConsumer(tx *gorm.DB, t entity.Consumer) (entity.Consumer, error) {
 	// Insert new consumer
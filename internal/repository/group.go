@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// Interface for group repository
+// This interface defines the methods that the group repository should implement
+type GroupRepository interface {
+	GetGroups(tx *gorm.DB, page int, limit int) ([]entity.Group, error)
+	CountGroups(tx *gorm.DB) (int64, error)
+	GetGroupByID(tx *gorm.DB, id int64) (entity.Group, error)
+	GetGroupByIDForUpdate(tx *gorm.DB, id int64) (entity.Group, error)
+	GetGroupByName(tx *gorm.DB, name string) (entity.Group, error)
+	CreateGroup(tx *gorm.DB, group entity.Group) (entity.Group, error)
+	UpdateGroup(tx *gorm.DB, group entity.Group) (entity.Group, error)
+	DeleteGroup(tx *gorm.DB, group entity.Group) error
+	GetGroupMembers(tx *gorm.DB, groupID int64, page int, limit int) ([]entity.GroupMembership, error)
+	CountGroupMembers(tx *gorm.DB, groupID int64) (int64, error)
+	GetGroupMemberIDs(tx *gorm.DB, groupID int64) ([]int64, error)
+	GetGroupMembershipForUpdate(tx *gorm.DB, groupID int64, userID int64) (entity.GroupMembership, error)
+	CreateGroupMembership(tx *gorm.DB, membership entity.GroupMembership) (entity.GroupMembership, error)
+	UpdateGroupMembership(tx *gorm.DB, membership entity.GroupMembership) (entity.GroupMembership, error)
+	DeleteGroupMembership(tx *gorm.DB, membership entity.GroupMembership) error
+	DeleteGroupMemberships(tx *gorm.DB, groupID int64) (int64, error)
+	GetGroupIDsByUserID(tx *gorm.DB, userID int64) ([]int64, error)
+}
+
+// This struct defines the GroupRepository that contains methods for interacting with the database
+// It implements the GroupRepository interface and provides methods for group-related operations
+type groupRepository struct{}
+
+// NewGroupRepository creates a new instance of GroupRepository.
+// It initializes the groupRepository struct and returns it.
+func NewGroupRepository() GroupRepository {
+	return &groupRepository{}
+}
+
+// activeMembersCondition matches the memberships of the users that are not soft-deleted,
+// a deleted user is no longer listed among the members of its groups.
+const activeMembersCondition = "user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)"
+
+// GetGroups retrieves a page of the groups of the tenant of the context, ordered by name.
+func (r *groupRepository) GetGroups(tx *gorm.DB, page int, limit int) ([]entity.Group, error) {
+	var groups []entity.Group
+	offset := (page - 1) * limit
+	err := tx.Scopes(TenantScope).
+		Order("lower(name) ASC").Order("id ASC").
+		Limit(limit).Offset(offset).
+		Find(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// CountGroups counts the groups of the tenant of the context.
+func (r *groupRepository) CountGroups(tx *gorm.DB) (int64, error) {
+	var count int64
+	if err := tx.Model(&entity.Group{}).Scopes(TenantScope).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetGroupByID retrieves a group of the tenant of the context by its ID.
+// It returns gorm.ErrRecordNotFound if the group does not exist or belongs to another tenant.
+func (r *groupRepository) GetGroupByID(tx *gorm.DB, id int64) (entity.Group, error) {
+	var group entity.Group
+	if err := tx.Scopes(TenantScope).First(&group, "id = ?", id).Error; err != nil {
+		return entity.Group{}, err
+	}
+
+	return group, nil
+}
+
+// GetGroupByIDForUpdate retrieves a group of the tenant of the context by its ID and locks it until the end
+// of the transaction, so its members are not changed while the group is updated or deleted.
+func (r *groupRepository) GetGroupByIDForUpdate(tx *gorm.DB, id int64) (entity.Group, error) {
+	var group entity.Group
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(TenantScope).First(&group, "id = ?", id).Error; err != nil {
+		return entity.Group{}, err
+	}
+
+	return group, nil
+}
+
+// GetGroupByName retrieves a group of the tenant of the context by its name, compared case-insensitively.
+func (r *groupRepository) GetGroupByName(tx *gorm.DB, name string) (entity.Group, error) {
+	var group entity.Group
+	if err := tx.Scopes(TenantScope).First(&group, "lower(name) = lower(?)", name).Error; err != nil {
+		return entity.Group{}, err
+	}
+
+	return group, nil
+}
+
+// CreateGroup creates a group in the database.
+func (r *groupRepository) CreateGroup(tx *gorm.DB, group entity.Group) (entity.Group, error) {
+	if err := tx.Create(&group).Error; err != nil {
+		return entity.Group{}, fmt.Errorf("failed to create group: %w", err)
+	}
+
+	return group, nil
+}
+
+// UpdateGroup updates the name and the description of a group in the database.
+func (r *groupRepository) UpdateGroup(tx *gorm.DB, group entity.Group) (entity.Group, error) {
+	if err := tx.Model(&group).Select("name", "description").Updates(&group).Error; err != nil {
+		return entity.Group{}, fmt.Errorf("failed to update group: %w", err)
+	}
+
+	return group, nil
+}
+
+// DeleteGroup deletes a group from the database, its memberships must be deleted beforehand.
+func (r *groupRepository) DeleteGroup(tx *gorm.DB, group entity.Group) error {
+	if err := tx.Delete(&entity.Group{}, "id = ?", group.ID).Error; err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+
+	return nil
+}
+
+// GetGroupMembers retrieves a page of the memberships of a group, the oldest members first.
+// The memberships of the soft-deleted users are left out.
+func (r *groupRepository) GetGroupMembers(tx *gorm.DB, groupID int64, page int, limit int) ([]entity.GroupMembership, error) {
+	var memberships []entity.GroupMembership
+	offset := (page - 1) * limit
+	err := tx.Where("group_id = ?", groupID).Where(activeMembersCondition).
+		Order("created_at ASC").Order("user_id ASC").
+		Limit(limit).Offset(offset).
+		Find(&memberships).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return memberships, nil
+}
+
+// CountGroupMembers counts the members of a group returned by GetGroupMembers over all pages.
+func (r *groupRepository) CountGroupMembers(tx *gorm.DB, groupID int64) (int64, error) {
+	var count int64
+	err := tx.Model(&entity.GroupMembership{}).Where("group_id = ?", groupID).Where(activeMembersCondition).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetGroupMemberIDs retrieves the IDs of the users of every membership of a group, the soft-deleted users included.
+func (r *groupRepository) GetGroupMemberIDs(tx *gorm.DB, groupID int64) ([]int64, error) {
+	ids := []int64{}
+	err := tx.Model(&entity.GroupMembership{}).Where("group_id = ?", groupID).Order("user_id ASC").Pluck("user_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// GetGroupMembershipForUpdate retrieves the membership of a user in a group and locks it until the end of the transaction.
+// It returns gorm.ErrRecordNotFound if the user is not a member of the group.
+func (r *groupRepository) GetGroupMembershipForUpdate(tx *gorm.DB, groupID int64, userID int64) (entity.GroupMembership, error) {
+	var membership entity.GroupMembership
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&membership, "group_id = ? AND user_id = ?", groupID, userID).Error
+	if err != nil {
+		return entity.GroupMembership{}, err
+	}
+
+	return membership, nil
+}
+
+// CreateGroupMembership adds a user to a group in the database.
+func (r *groupRepository) CreateGroupMembership(tx *gorm.DB, membership entity.GroupMembership) (entity.GroupMembership, error) {
+	if err := tx.Omit(clause.Associations).Create(&membership).Error; err != nil {
+		return entity.GroupMembership{}, fmt.Errorf("failed to create group membership: %w", err)
+	}
+
+	return membership, nil
+}
+
+// UpdateGroupMembership updates the role of a member within its group in the database.
+func (r *groupRepository) UpdateGroupMembership(tx *gorm.DB, membership entity.GroupMembership) (entity.GroupMembership, error) {
+	err := tx.Model(&entity.GroupMembership{}).
+		Where("group_id = ? AND user_id = ?", membership.GroupID, membership.UserID).
+		Update("role", membership.Role).Error
+	if err != nil {
+		return entity.GroupMembership{}, fmt.Errorf("failed to update group membership: %w", err)
+	}
+
+	return membership, nil
+}
+
+// DeleteGroupMembership removes a user from a group in the database.
+func (r *groupRepository) DeleteGroupMembership(tx *gorm.DB, membership entity.GroupMembership) error {
+	err := tx.Delete(&entity.GroupMembership{}, "group_id = ? AND user_id = ?", membership.GroupID, membership.UserID).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete group membership: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteGroupMemberships removes every member of a group in the database, and returns how many were removed.
+func (r *groupRepository) DeleteGroupMemberships(tx *gorm.DB, groupID int64) (int64, error) {
+	result := tx.Where("group_id = ?", groupID).Delete(&entity.GroupMembership{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete group memberships: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
+
+// GetGroupIDsByUserID retrieves the IDs of the groups of a user, in ascending order.
+func (r *groupRepository) GetGroupIDsByUserID(tx *gorm.DB, userID int64) ([]int64, error) {
+	ids := []int64{}
+	err := tx.Model(&entity.GroupMembership{}).Where("user_id = ?", userID).Order("group_id ASC").Pluck("group_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
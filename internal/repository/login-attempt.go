@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// Interface for login attempt repository
+// This interface defines the methods that the login attempt repository should implement
+type LoginAttemptRepository interface {
+	GetLoginAttemptsByUserID(tx *gorm.DB, userID int64, username string, filter entity.LoginAttemptFilter) ([]entity.LoginAttempt, error)
+	CountLoginAttemptsByUserID(tx *gorm.DB, userID int64, username string, filter entity.LoginAttemptFilter) (int64, error)
+	CreateLoginAttempts(tx *gorm.DB, attempts []entity.LoginAttempt) error
+	RemoveLoginAttemptsBefore(tx *gorm.DB, before time.Time) (int64, error)
+}
+
+// This struct defines the LoginAttemptRepository that contains methods for interacting with the database
+// It implements the LoginAttemptRepository interface and provides methods for login attempt-related operations
+type loginAttemptRepository struct{}
+
+// NewLoginAttemptRepository creates a new instance of LoginAttemptRepository.
+// It initializes the loginAttemptRepository struct and returns it.
+func NewLoginAttemptRepository() LoginAttemptRepository {
+	return &loginAttemptRepository{}
+}
+
+// GetLoginAttemptsByUserID retrieves the login attempts of a user from the database, the most recent first.
+// The attempts recorded with the username of the user, before its ID was known, are included.
+func (r *loginAttemptRepository) GetLoginAttemptsByUserID(tx *gorm.DB, userID int64, username string, filter entity.LoginAttemptFilter) ([]entity.LoginAttempt, error) {
+	// Select the filtered login attempts of the user with pagination
+	var attempts []entity.LoginAttempt
+	offset := (filter.Page - 1) * filter.Limit
+	err := filterLoginAttempts(tx, userID, username, filter).
+		Order("attempted_at DESC").Order("id DESC").
+		Limit(filter.Limit).Offset(offset).
+		Find(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+// CountLoginAttemptsByUserID counts the login attempts of a user matching the filter.
+func (r *loginAttemptRepository) CountLoginAttemptsByUserID(tx *gorm.DB, userID int64, username string, filter entity.LoginAttemptFilter) (int64, error) {
+	// Count the filtered login attempts of the user
+	var count int64
+	if err := filterLoginAttempts(tx, userID, username, filter).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CreateLoginAttempts creates the login attempts in the database in a single batch.
+func (r *loginAttemptRepository) CreateLoginAttempts(tx *gorm.DB, attempts []entity.LoginAttempt) error {
+	// Create the login attempts in the database
+	if err := tx.Create(&attempts).Error; err != nil {
+		return fmt.Errorf("failed to create login attempts: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveLoginAttemptsBefore removes the login attempts older than the given time from the database.
+// It returns the number of removed login attempts.
+func (r *loginAttemptRepository) RemoveLoginAttemptsBefore(tx *gorm.DB, before time.Time) (int64, error) {
+	// Delete the login attempts older than the given time from the database, in the tenant of the context if any
+	result := tx.Scopes(TenantScope).Where("attempted_at < ?", before).Delete(&entity.LoginAttempt{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to remove login attempts: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
+
+// filterLoginAttempts builds the query selecting the login attempts of a user matching the filter.
+// The attempts are restricted to the tenant of the context, the same username may exist in another tenant.
+func filterLoginAttempts(tx *gorm.DB, userID int64, username string, filter entity.LoginAttemptFilter) *gorm.DB {
+	query := tx.Model(&entity.LoginAttempt{}).Scopes(TenantScope).
+		Where("(user_id = ? OR (user_id IS NULL AND lower(username) = lower(?)))", userID, username)
+
+	if filter.From != nil {
+		query = query.Where("attempted_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("attempted_at <= ?", *filter.To)
+	}
+	if filter.Success != nil {
+		query = query.Where("success = ?", *filter.Success)
+	}
+
+	return query
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// Interface for outbox event repository
+// This interface defines the methods that the outbox event repository should implement
+type OutboxEventRepository interface {
+	GetPendingOutboxEvents(tx *gorm.DB, limit int) ([]entity.OutboxEvent, error)
+	CreateOutboxEvent(tx *gorm.DB, event entity.OutboxEvent) (entity.OutboxEvent, error)
+}
+
+// This struct defines the OutboxEventRepository that contains methods for interacting with the database
+// It implements the OutboxEventRepository interface and provides methods for outbox event-related operations
+type outboxEventRepository struct{}
+
+// NewOutboxEventRepository creates a new instance of OutboxEventRepository.
+// It initializes the outboxEventRepository struct and returns it.
+func NewOutboxEventRepository() OutboxEventRepository {
+	return &outboxEventRepository{}
+}
+
+// GetPendingOutboxEvents retrieves the events not published yet, the oldest first.
+func (r *outboxEventRepository) GetPendingOutboxEvents(tx *gorm.DB, limit int) ([]entity.OutboxEvent, error) {
+	var events []entity.OutboxEvent
+	err := tx.Scopes(TenantScope).Where("published_at IS NULL").Order("id ASC").Limit(limit).Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// CreateOutboxEvent records a new event in the database, in the transaction of the change it describes.
+func (r *outboxEventRepository) CreateOutboxEvent(tx *gorm.DB, event entity.OutboxEvent) (entity.OutboxEvent, error) {
+	if err := tx.Create(&event).Error; err != nil {
+		return entity.OutboxEvent{}, fmt.Errorf("failed to create outbox event: %w", err)
+	}
+
+	return event, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// Interface for password history repository
+// This interface defines the methods that the password history repository should implement
+type PasswordHistoryRepository interface {
+	GetRecentPasswordHashes(tx *gorm.DB, userID int64, limit int) ([]string, error)
+	CreatePasswordHistory(tx *gorm.DB, history entity.PasswordHistory) error
+	PrunePasswordHistory(tx *gorm.DB, userID int64, keep int) (int64, error)
+}
+
+// This struct defines the PasswordHistoryRepository that contains methods for interacting with the database
+// It implements the PasswordHistoryRepository interface and provides methods for password history-related operations
+type passwordHistoryRepository struct{}
+
+// NewPasswordHistoryRepository creates a new instance of PasswordHistoryRepository.
+// It initializes the passwordHistoryRepository struct and returns it.
+func NewPasswordHistoryRepository() PasswordHistoryRepository {
+	return &passwordHistoryRepository{}
+}
+
+// GetRecentPasswordHashes retrieves the hashes of the most recent passwords of a user, the most recent first.
+func (r *passwordHistoryRepository) GetRecentPasswordHashes(tx *gorm.DB, userID int64, limit int) ([]string, error) {
+	// Select the most recent password hashes of the user
+	var hashes []string
+	err := tx.Model(&entity.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").Order("id DESC").
+		Limit(limit).
+		Pluck("password_hash", &hashes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// CreatePasswordHistory records a password of a user in the database.
+func (r *passwordHistoryRepository) CreatePasswordHistory(tx *gorm.DB, history entity.PasswordHistory) error {
+	// Insert the password history entry into the database
+	if err := tx.Create(&history).Error; err != nil {
+		return fmt.Errorf("failed to create password history: %w", err)
+	}
+
+	return nil
+}
+
+// PrunePasswordHistory removes the passwords of a user beyond the most recent ones.
+// It returns the number of removed entries.
+func (r *passwordHistoryRepository) PrunePasswordHistory(tx *gorm.DB, userID int64, keep int) (int64, error) {
+	// Keep the most recent entries of the user, remove the others
+	recent := tx.Model(&entity.PasswordHistory{}).
+		Select("id").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").Order("id DESC").
+		Limit(keep)
+
+	result := tx.Where("user_id = ? AND id NOT IN (?)", userID, recent).Delete(&entity.PasswordHistory{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to prune password history: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// Interface for permission repository
+// This interface defines the methods that the permission repository should implement
+type PermissionRepository interface {
+	GetPermissionsByUserID(tx *gorm.DB, userID int64) ([]entity.Permission, error)
+}
+
+// This struct defines the PermissionRepository that contains methods for interacting with the database
+type permissionRepository struct{}
+
+// NewPermissionRepository creates a new instance of PermissionRepository.
+// It initializes the permissionRepository struct and returns it.
+func NewPermissionRepository() PermissionRepository {
+	return &permissionRepository{}
+}
+
+// GetPermissionsByUserID retrieves the effective permissions of a user, those granted to any of the roles it holds
+// through an assignment that has not expired. A permission granted to several of its roles is only returned once.
+// The permissions are ordered by name.
+func (r *permissionRepository) GetPermissionsByUserID(tx *gorm.DB, userID int64) ([]entity.Permission, error) {
+	var permissions []entity.Permission
+	err := tx.Model(&entity.Permission{}).
+		Distinct("permissions.id", "permissions.name", "permissions.description").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Where(activeUserRolesCondition, time.Now().UTC()).
+		Order("permissions.name ASC").
+		Find(&permissions).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
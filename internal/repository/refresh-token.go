@@ -2,6 +2,7 @@ package repository
 
 import (
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -13,8 +14,13 @@ import (
 type RefreshTokenRepository interface {
 	GetRefreshTokenByUserID(tx *gorm.DB, userID int64) (entity.RefreshToken, error)
 	GetRefreshTokenByToken(tx *gorm.DB, token string) (entity.RefreshToken, error)
+	GetRefreshTokensByUserID(tx *gorm.DB, userID int64) ([]entity.RefreshToken, error)
+	GetActiveRefreshTokensByUserID(tx *gorm.DB, userID int64, now time.Time) ([]entity.RefreshToken, error)
 	CreateRefreshToken(tx *gorm.DB, token entity.RefreshToken) (entity.RefreshToken, error)
+	RemoveRefreshTokenByToken(tx *gorm.DB, token string) (bool, error)
+	RemoveRefreshTokenBySessionID(tx *gorm.DB, userID int64, sessionID string) (bool, error)
 	RemoveRefreshTokenByUserID(tx *gorm.DB, userID int64) (bool, error)
+	RemoveExpiredRefreshTokensByUserID(tx *gorm.DB, userID int64, now time.Time) (int64, error)
 }
 
 // This struct defines the RefreshTokenRepository that contains methods for interacting with the database
@@ -27,11 +33,11 @@ func NewRefreshTokenRepository() RefreshTokenRepository {
 	return &refreshTokenRepository{}
 }
 
-// GetRefreshTokenByUserID retrieves a refresh token by its user ID from the database.
+// GetRefreshTokenByUserID retrieves the most recent refresh token of a user from the database.
 func (r *refreshTokenRepository) GetRefreshTokenByUserID(tx *gorm.DB, userID int64) (entity.RefreshToken, error) {
 	// Select the refresh token with the given user ID from the database
 	var refreshToken entity.RefreshToken
-	err := tx.First(&refreshToken, "user_id = ?", userID).Error
+	err := tx.Order("created_at DESC").First(&refreshToken, "user_id = ?", userID).Error
 	if err != nil {
 		return entity.RefreshToken{}, err
 	}
@@ -51,6 +57,30 @@ func (r *refreshTokenRepository) GetRefreshTokenByToken(tx *gorm.DB, token strin
 	return refreshToken, nil
 }
 
+// GetRefreshTokensByUserID retrieves all refresh tokens of a user from the database, the oldest first.
+func (r *refreshTokenRepository) GetRefreshTokensByUserID(tx *gorm.DB, userID int64) ([]entity.RefreshToken, error) {
+	// Select the refresh tokens with the given user ID from the database
+	var refreshTokens []entity.RefreshToken
+	err := tx.Where("user_id = ?", userID).Order("created_at ASC").Order("token ASC").Find(&refreshTokens).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return refreshTokens, nil
+}
+
+// GetActiveRefreshTokensByUserID retrieves the refresh tokens of a user which did not expire before now, the most recent first.
+func (r *refreshTokenRepository) GetActiveRefreshTokensByUserID(tx *gorm.DB, userID int64, now time.Time) ([]entity.RefreshToken, error) {
+	// Select the unexpired refresh tokens with the given user ID from the database
+	var refreshTokens []entity.RefreshToken
+	err := tx.Where("user_id = ? AND expiry_date > ?", userID, now).Order("created_at DESC").Order("token ASC").Find(&refreshTokens).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return refreshTokens, nil
+}
+
 // CreateRefreshToken creates a new refresh token in the database.
 func (r *refreshTokenRepository) CreateRefreshToken(tx *gorm.DB, token entity.RefreshToken) (entity.RefreshToken, error) {
 	// Create a new refresh token in the database
@@ -61,7 +91,31 @@ func (r *refreshTokenRepository) CreateRefreshToken(tx *gorm.DB, token entity.Re
 	return token, nil
 }
 
-// RemoveRefreshTokenByUserID removes a refresh token by its user ID from the database.
+// RemoveRefreshTokenByToken removes a refresh token by its token string from the database.
+// It returns false if no refresh token was removed.
+func (r *refreshTokenRepository) RemoveRefreshTokenByToken(tx *gorm.DB, token string) (bool, error) {
+	// Delete the refresh token with the given token string from the database
+	result := tx.Where("token = ?", token).Delete(&entity.RefreshToken{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to remove refresh token: %w", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// RemoveRefreshTokenBySessionID removes the refresh token of a session of a user from the database.
+// It returns false if the user has no such session.
+func (r *refreshTokenRepository) RemoveRefreshTokenBySessionID(tx *gorm.DB, userID int64, sessionID string) (bool, error) {
+	// Delete the refresh token with the given user ID and session ID from the database
+	result := tx.Where("user_id = ? AND session_id = ?", userID, sessionID).Delete(&entity.RefreshToken{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to remove session %s: %w", sessionID, result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// RemoveRefreshTokenByUserID removes all refresh tokens of a user from the database.
 func (r *refreshTokenRepository) RemoveRefreshTokenByUserID(tx *gorm.DB, userID int64) (bool, error) {
 	// Delete the refresh token with the given user ID from the database
 	if err := tx.Where("user_id = ?", userID).Delete(&entity.RefreshToken{}).Error; err != nil {
@@ -70,3 +124,15 @@ func (r *refreshTokenRepository) RemoveRefreshTokenByUserID(tx *gorm.DB, userID
 
 	return true, nil
 }
+
+// RemoveExpiredRefreshTokensByUserID removes the refresh tokens of a user which expired before now.
+// It returns the number of removed refresh tokens.
+func (r *refreshTokenRepository) RemoveExpiredRefreshTokensByUserID(tx *gorm.DB, userID int64, now time.Time) (int64, error) {
+	// Delete the expired refresh tokens with the given user ID from the database
+	result := tx.Where("user_id = ? AND expiry_date <= ?", userID, now).Delete(&entity.RefreshToken{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to remove expired refresh tokens by user ID %d: %w", userID, result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// Interface for role reassignment repository
+// This interface defines the methods that the role reassignment repository should implement
+type RoleReassignmentRepository interface {
+	GetRunningRoleReassignment(tx *gorm.DB, fromRoleID uint, toRoleID uint) (entity.RoleReassignment, error)
+	GetRoleReassignmentByIDForUpdate(tx *gorm.DB, id int64) (entity.RoleReassignment, error)
+	CreateRoleReassignment(tx *gorm.DB, reassignment entity.RoleReassignment) (entity.RoleReassignment, error)
+	UpdateRoleReassignment(tx *gorm.DB, reassignment entity.RoleReassignment) (entity.RoleReassignment, error)
+}
+
+// This struct defines the RoleReassignmentRepository that contains methods for interacting with the database
+// It implements the RoleReassignmentRepository interface and provides methods for role reassignment-related operations
+type roleReassignmentRepository struct{}
+
+// NewRoleReassignmentRepository creates a new instance of RoleReassignmentRepository.
+// It initializes the roleReassignmentRepository struct and returns it.
+func NewRoleReassignmentRepository() RoleReassignmentRepository {
+	return &roleReassignmentRepository{}
+}
+
+// GetRunningRoleReassignment retrieves the reassignment in progress between the two roles in the tenant of the context,
+// the one an interrupted request left behind. It returns gorm.ErrRecordNotFound if there is none.
+func (r *roleReassignmentRepository) GetRunningRoleReassignment(tx *gorm.DB, fromRoleID uint, toRoleID uint) (entity.RoleReassignment, error) {
+	var reassignment entity.RoleReassignment
+	err := tx.Scopes(TenantScope).
+		Where("from_role_id = ? AND to_role_id = ? AND status = ?", fromRoleID, toRoleID, entity.RoleReassignmentRunning).
+		Order("id ASC").
+		First(&reassignment).Error
+	if err != nil {
+		return entity.RoleReassignment{}, err
+	}
+
+	return reassignment, nil
+}
+
+// GetRoleReassignmentByIDForUpdate retrieves a reassignment and locks it until the end of the transaction,
+// so two requests resuming the same reassignment process its batches one after the other.
+func (r *roleReassignmentRepository) GetRoleReassignmentByIDForUpdate(tx *gorm.DB, id int64) (entity.RoleReassignment, error) {
+	var reassignment entity.RoleReassignment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(TenantScope).First(&reassignment, "id = ?", id).Error
+	if err != nil {
+		return entity.RoleReassignment{}, err
+	}
+
+	return reassignment, nil
+}
+
+// CreateRoleReassignment inserts a new reassignment into the database.
+func (r *roleReassignmentRepository) CreateRoleReassignment(tx *gorm.DB, reassignment entity.RoleReassignment) (entity.RoleReassignment, error) {
+	if err := tx.Create(&reassignment).Error; err != nil {
+		return entity.RoleReassignment{}, fmt.Errorf("failed to create role reassignment: %w", err)
+	}
+
+	return reassignment, nil
+}
+
+// UpdateRoleReassignment saves the progress of a reassignment.
+func (r *roleReassignmentRepository) UpdateRoleReassignment(tx *gorm.DB, reassignment entity.RoleReassignment) (entity.RoleReassignment, error) {
+	if err := tx.Save(&reassignment).Error; err != nil {
+		return entity.RoleReassignment{}, fmt.Errorf("failed to update role reassignment: %w", err)
+	}
+
+	return reassignment, nil
+}
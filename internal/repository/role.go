@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"strings"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
 )
@@ -11,6 +14,9 @@ import (
 type RoleRepository interface {
 	GetRoleByID(tx *gorm.DB, id uint) (entity.Role, error)
 	GetRoleByName(tx *gorm.DB, name string) (entity.Role, error)
+	GetRoleByNameForUpdate(tx *gorm.DB, name string) (entity.Role, error)
+	GetRolesByNames(tx *gorm.DB, names []string) ([]entity.Role, error)
+	GetDefaultRoles(tx *gorm.DB) ([]entity.Role, error)
 }
 
 // This struct defines the RoleRepository that contains methods for interacting with the database
@@ -47,3 +53,51 @@ func (r *roleRepository) GetRoleByName(tx *gorm.DB, name string) (entity.Role, e
 
 	return role, nil
 }
+
+// GetRoleByNameForUpdate retrieves a role by its name and locks the row until the end of the transaction.
+// It is used to serialize the concurrent changes of the holders of the role.
+func (r *roleRepository) GetRoleByNameForUpdate(tx *gorm.DB, name string) (entity.Role, error) {
+	// Select the role with the given name from the database with a row lock
+	var role entity.Role
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&role, "upper(name) = ?", strings.ToUpper(name)).Error
+
+	if err != nil {
+		return entity.Role{}, err
+	}
+
+	return role, nil
+}
+
+// GetRolesByNames retrieves the roles with the given names from the database in a single query.
+// The names are compared case-insensitively and the names without a role are ignored.
+func (r *roleRepository) GetRolesByNames(tx *gorm.DB, names []string) ([]entity.Role, error) {
+	// Select the roles with the given names from the database
+	var roles []entity.Role
+	if len(names) == 0 {
+		return roles, nil
+	}
+
+	upper := make([]string, len(names))
+	for i, name := range names {
+		upper[i] = strings.ToUpper(name)
+	}
+
+	err := tx.Where("upper(name) IN ?", upper).Order("id ASC").Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// GetDefaultRoles retrieves the roles attached to the new users created without any role.
+func (r *roleRepository) GetDefaultRoles(tx *gorm.DB) ([]entity.Role, error) {
+	// Select the default roles from the database
+	var roles []entity.Role
+	err := tx.Where("is_default = ?", true).Order("id ASC").Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// Interface for scheduled deletion repository
+// This interface defines the methods that the scheduled deletion repository should implement
+type ScheduledDeletionRepository interface {
+	GetScheduledDeletionByUserID(tx *gorm.DB, userID int64) (entity.ScheduledDeletion, error)
+	GetScheduledDeletionByIDForUpdate(tx *gorm.DB, id int64) (entity.ScheduledDeletion, error)
+	GetScheduledDeletionsToRemind(tx *gorm.DB, now time.Time, before time.Time) ([]entity.ScheduledDeletion, error)
+	GetDueScheduledDeletions(tx *gorm.DB, now time.Time) ([]entity.ScheduledDeletion, error)
+	CreateScheduledDeletion(tx *gorm.DB, deletion entity.ScheduledDeletion) (entity.ScheduledDeletion, error)
+	UpdateScheduledDeletion(tx *gorm.DB, deletion entity.ScheduledDeletion) (entity.ScheduledDeletion, error)
+	RemoveScheduledDeletion(tx *gorm.DB, id int64) error
+}
+
+// This struct defines the ScheduledDeletionRepository that contains methods for interacting with the database
+// It implements the ScheduledDeletionRepository interface and provides methods for scheduled deletion-related operations
+type scheduledDeletionRepository struct{}
+
+// NewScheduledDeletionRepository creates a new instance of ScheduledDeletionRepository.
+// It initializes the scheduledDeletionRepository struct and returns it.
+func NewScheduledDeletionRepository() ScheduledDeletionRepository {
+	return &scheduledDeletionRepository{}
+}
+
+// GetScheduledDeletionByUserID retrieves the scheduled deletion of a user in the tenant of the context.
+func (r *scheduledDeletionRepository) GetScheduledDeletionByUserID(tx *gorm.DB, userID int64) (entity.ScheduledDeletion, error) {
+	var deletion entity.ScheduledDeletion
+	err := tx.Scopes(TenantScope).First(&deletion, "user_id = ?", userID).Error
+	if err != nil {
+		return entity.ScheduledDeletion{}, err
+	}
+
+	return deletion, nil
+}
+
+// GetScheduledDeletionByIDForUpdate retrieves a scheduled deletion and locks it until the end of the transaction,
+// so the janitor and a reactivation do not process it at the same time.
+func (r *scheduledDeletionRepository) GetScheduledDeletionByIDForUpdate(tx *gorm.DB, id int64) (entity.ScheduledDeletion, error) {
+	var deletion entity.ScheduledDeletion
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(TenantScope).First(&deletion, "id = ?", id).Error
+	if err != nil {
+		return entity.ScheduledDeletion{}, err
+	}
+
+	return deletion, nil
+}
+
+// GetScheduledDeletionsToRemind retrieves the scheduled deletions planned after now and before the given time
+// whose reminder has not been sent yet, the earliest first.
+func (r *scheduledDeletionRepository) GetScheduledDeletionsToRemind(tx *gorm.DB, now time.Time, before time.Time) ([]entity.ScheduledDeletion, error) {
+	var deletions []entity.ScheduledDeletion
+	err := tx.Scopes(TenantScope).
+		Where("reminder_sent_at IS NULL AND delete_at > ? AND delete_at <= ?", now, before).
+		Order("delete_at ASC").Order("id ASC").
+		Find(&deletions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return deletions, nil
+}
+
+// GetDueScheduledDeletions retrieves the scheduled deletions whose grace period is over, the earliest first.
+func (r *scheduledDeletionRepository) GetDueScheduledDeletions(tx *gorm.DB, now time.Time) ([]entity.ScheduledDeletion, error) {
+	var deletions []entity.ScheduledDeletion
+	err := tx.Scopes(TenantScope).
+		Where("delete_at <= ?", now).
+		Order("delete_at ASC").Order("id ASC").
+		Find(&deletions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return deletions, nil
+}
+
+// CreateScheduledDeletion creates a new scheduled deletion in the database.
+func (r *scheduledDeletionRepository) CreateScheduledDeletion(tx *gorm.DB, deletion entity.ScheduledDeletion) (entity.ScheduledDeletion, error) {
+	if err := tx.Create(&deletion).Error; err != nil {
+		return entity.ScheduledDeletion{}, fmt.Errorf("failed to create scheduled deletion: %w", err)
+	}
+
+	return deletion, nil
+}
+
+// UpdateScheduledDeletion updates an existing scheduled deletion in the database.
+func (r *scheduledDeletionRepository) UpdateScheduledDeletion(tx *gorm.DB, deletion entity.ScheduledDeletion) (entity.ScheduledDeletion, error) {
+	if err := tx.Save(&deletion).Error; err != nil {
+		return entity.ScheduledDeletion{}, fmt.Errorf("failed to update scheduled deletion: %w", err)
+	}
+
+	return deletion, nil
+}
+
+// RemoveScheduledDeletion removes a scheduled deletion by its ID from the database.
+func (r *scheduledDeletionRepository) RemoveScheduledDeletion(tx *gorm.DB, id int64) error {
+	if err := tx.Delete(&entity.ScheduledDeletion{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to remove scheduled deletion: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+// TenantScope restricts the query to the tenant carried by the context of the statement.
+// The query is left unrestricted when the context carries no tenant, e.g. in the background jobs.
+// A user of another tenant is therefore simply not found, which does not leak its existence.
+func TenantScope(tx *gorm.DB) *gorm.DB {
+	tenantID, ok := metacontext.ExtractTenantID(tx.Statement.Context)
+	if !ok {
+		return tx
+	}
+
+	return tx.Where("tenant_id = ?", tenantID)
+}
+
+// NotDeleted restricts the query to the users that are not soft-deleted.
+// The reads of the user repository apply it by default instead of relying on each query to filter the deleted users.
+func NotDeleted(tx *gorm.DB) *gorm.DB {
+	return tx.Where("deleted_at IS NULL")
+}
+
+// ReadOption changes the users returned by a read of the user repository.
+type ReadOption func(*readOptions)
+
+// readOptions holds the options of a read of the user repository.
+type readOptions struct {
+	withDeleted bool
+}
+
+// WithDeleted includes the soft-deleted users in the read, it is meant for the admin endpoints.
+// The lookups by username and email used by the login do not accept it.
+func WithDeleted() ReadOption {
+	return func(o *readOptions) {
+		o.withDeleted = true
+	}
+}
+
+// userReadScope restricts a read of the users to the tenant of the context and, unless WithDeleted is given,
+// to the users that are not soft-deleted. The deleted users are filtered by NotDeleted only, the implicit
+// soft-delete condition of GORM is replaced by it.
+func userReadScope(opts []ReadOption) func(tx *gorm.DB) *gorm.DB {
+	var o readOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(tx *gorm.DB) *gorm.DB {
+		tx = TenantScope(tx.Unscoped())
+		if o.withDeleted {
+			return tx
+		}
+		return NotDeleted(tx)
+	}
+}
+
+// activeUserRolesCondition matches the role assignments that have not expired at the given time,
+// a role no longer grants anything once its assignment has expired.
+const activeUserRolesCondition = "(user_roles.expires_at IS NULL OR user_roles.expires_at > ?)"
+
+// preloadRoles loads the roles of the users along with the expiry of their assignment, leaving out the expired ones.
+// The roles are loaded through the role assignments, which entity.User.AfterFind turns into the Roles of the users.
+func preloadRoles(tx *gorm.DB) *gorm.DB {
+	return tx.Preload("RoleAssignments", func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(activeUserRolesCondition, time.Now().UTC()).Order("user_roles.role_id ASC")
+	}).Preload("RoleAssignments.Role")
+}
+
+// roleHoldersCondition matches the users holding the role through an assignment that has not expired.
+// The name is compared case-insensitively.
+func roleHoldersCondition(roleName string) clause.Expr {
+	return gorm.Expr("id IN (SELECT user_roles.user_id FROM user_roles JOIN roles ON roles.id = user_roles.role_id"+
+		" WHERE upper(roles.name) = ? AND "+activeUserRolesCondition+")", strings.ToUpper(roleName), time.Now().UTC())
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// Interface for tenant repository
+// This interface defines the methods that the tenant repository should implement
+type TenantRepository interface {
+	GetTenantByID(tx *gorm.DB, id int64) (entity.Tenant, error)
+	IsMember(tx *gorm.DB, userID int64, tenantID int64) (bool, error)
+}
+
+// This struct defines the TenantRepository that contains methods for interacting with the database
+type tenantRepository struct{}
+
+// NewTenantRepository creates a new instance of TenantRepository.
+// It initializes the tenantRepository struct and returns it.
+func NewTenantRepository() TenantRepository {
+	return &tenantRepository{}
+}
+
+// GetTenantByID retrieves a tenant by its ID from the database.
+func (r *tenantRepository) GetTenantByID(tx *gorm.DB, id int64) (entity.Tenant, error) {
+	// Select the tenant with the given ID from the database
+	var tenant entity.Tenant
+	err := tx.First(&tenant, "id = ?", id).Error
+
+	if err != nil {
+		return entity.Tenant{}, err
+	}
+
+	return tenant, nil
+}
+
+// IsMember reports whether the user belongs to the tenant, either as its own tenant or through a membership.
+func (r *tenantRepository) IsMember(tx *gorm.DB, userID int64, tenantID int64) (bool, error) {
+	// Count the user in its own tenant and in the memberships
+	var count int64
+	err := tx.Model(&entity.User{}).
+		Where("id = ? AND (tenant_id = ? OR EXISTS (SELECT 1 FROM user_tenants WHERE user_tenants.user_id = users.id AND user_tenants.tenant_id = ?))", userID, tenantID, tenantID).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// Interface for user note repository
+// This interface defines the methods that the user note repository should implement
+type UserNoteRepository interface {
+	GetUserNotes(tx *gorm.DB, userID int64, page int, limit int) ([]entity.UserNote, error)
+	CountUserNotes(tx *gorm.DB, userID int64) (int64, error)
+	GetUserNoteByID(tx *gorm.DB, userID int64, noteID int64) (entity.UserNote, error)
+	CreateUserNote(tx *gorm.DB, note entity.UserNote) (entity.UserNote, error)
+	DeleteUserNote(tx *gorm.DB, note entity.UserNote) error
+}
+
+// This struct defines the UserNoteRepository that contains methods for interacting with the database
+// It implements the UserNoteRepository interface and provides methods for user note-related operations
+type userNoteRepository struct{}
+
+// NewUserNoteRepository creates a new instance of UserNoteRepository.
+// It initializes the userNoteRepository struct and returns it.
+func NewUserNoteRepository() UserNoteRepository {
+	return &userNoteRepository{}
+}
+
+// GetUserNotes retrieves the notes of a user from the database with pagination, the pinned notes first,
+// then the most recent first.
+func (r *userNoteRepository) GetUserNotes(tx *gorm.DB, userID int64, page int, limit int) ([]entity.UserNote, error) {
+	var notes []entity.UserNote
+	offset := (page - 1) * limit
+	err := tx.Where("user_id = ?", userID).
+		Order("pinned DESC").Order("created_at DESC").Order("id DESC").
+		Limit(limit).Offset(offset).
+		Find(&notes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// CountUserNotes counts the notes of a user.
+func (r *userNoteRepository) CountUserNotes(tx *gorm.DB, userID int64) (int64, error) {
+	var count int64
+	if err := tx.Model(&entity.UserNote{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetUserNoteByID retrieves a note of a user by its ID from the database.
+// It returns gorm.ErrRecordNotFound if the note does not exist or is about another user.
+func (r *userNoteRepository) GetUserNoteByID(tx *gorm.DB, userID int64, noteID int64) (entity.UserNote, error) {
+	var note entity.UserNote
+	if err := tx.First(&note, "id = ? AND user_id = ?", noteID, userID).Error; err != nil {
+		return entity.UserNote{}, err
+	}
+
+	return note, nil
+}
+
+// CreateUserNote creates a note of a user in the database.
+func (r *userNoteRepository) CreateUserNote(tx *gorm.DB, note entity.UserNote) (entity.UserNote, error) {
+	if err := tx.Create(&note).Error; err != nil {
+		return entity.UserNote{}, fmt.Errorf("failed to create user note: %w", err)
+	}
+
+	return note, nil
+}
+
+// DeleteUserNote deletes a note of a user from the database.
+func (r *userNoteRepository) DeleteUserNote(tx *gorm.DB, note entity.UserNote) error {
+	if err := tx.Delete(&entity.UserNote{}, "id = ?", note.ID).Error; err != nil {
+		return fmt.Errorf("failed to delete user note: %w", err)
+	}
+
+	return nil
+}
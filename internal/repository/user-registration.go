@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// Interface for user registration repository
+// This interface defines the methods that the user registration repository should implement
+type UserRegistrationRepository interface {
+	GetRegistrationsByStatus(tx *gorm.DB, status string, page int, limit int) ([]entity.UserRegistration, error)
+	CountRegistrationsByStatus(tx *gorm.DB, status string) (int64, error)
+	GetRegistrationByUserIDForUpdate(tx *gorm.DB, userID int64) (entity.UserRegistration, error)
+	CreateRegistration(tx *gorm.DB, registration entity.UserRegistration) (entity.UserRegistration, error)
+	UpdateRegistration(tx *gorm.DB, registration entity.UserRegistration) (entity.UserRegistration, error)
+}
+
+// This struct defines the UserRegistrationRepository that contains methods for interacting with the database
+// It implements the UserRegistrationRepository interface and provides methods for user registration-related operations
+type userRegistrationRepository struct{}
+
+// NewUserRegistrationRepository creates a new instance of UserRegistrationRepository.
+// It initializes the userRegistrationRepository struct and returns it.
+func NewUserRegistrationRepository() UserRegistrationRepository {
+	return &userRegistrationRepository{}
+}
+
+// registeredUsersCondition matches the registrations of the users that are not soft-deleted,
+// a deleted account is no longer waiting for any decision.
+const registeredUsersCondition = "user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)"
+
+// GetRegistrationsByStatus retrieves the registrations of the tenant of the context with the given status
+// with pagination, the oldest first so the admins decide them in order.
+func (r *userRegistrationRepository) GetRegistrationsByStatus(tx *gorm.DB, status string, page int, limit int) ([]entity.UserRegistration, error) {
+	var registrations []entity.UserRegistration
+	offset := (page - 1) * limit
+	err := tx.Scopes(TenantScope).Where("status = ?", status).Where(registeredUsersCondition).
+		Order("created_at ASC").Order("user_id ASC").
+		Limit(limit).Offset(offset).
+		Find(&registrations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return registrations, nil
+}
+
+// CountRegistrationsByStatus counts the registrations of the tenant of the context with the given status.
+// Like GetRegistrationsByStatus, the registrations of the deleted users are left out.
+func (r *userRegistrationRepository) CountRegistrationsByStatus(tx *gorm.DB, status string) (int64, error) {
+	var count int64
+	err := tx.Model(&entity.UserRegistration{}).Scopes(TenantScope).Where("status = ?", status).Where(registeredUsersCondition).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetRegistrationByUserIDForUpdate retrieves the registration of a user of the tenant of the context
+// and locks it until the end of the transaction, so two admins cannot decide it at the same time.
+// It returns gorm.ErrRecordNotFound if the account was not registered through POST /auth/register.
+func (r *userRegistrationRepository) GetRegistrationByUserIDForUpdate(tx *gorm.DB, userID int64) (entity.UserRegistration, error) {
+	var registration entity.UserRegistration
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(TenantScope).First(&registration, "user_id = ?", userID).Error
+	if err != nil {
+		return entity.UserRegistration{}, err
+	}
+
+	return registration, nil
+}
+
+// CreateRegistration creates the registration of a user in the database.
+func (r *userRegistrationRepository) CreateRegistration(tx *gorm.DB, registration entity.UserRegistration) (entity.UserRegistration, error) {
+	if err := tx.Create(&registration).Error; err != nil {
+		return entity.UserRegistration{}, fmt.Errorf("failed to create user registration: %w", err)
+	}
+
+	return registration, nil
+}
+
+// UpdateRegistration updates the registration of a user in the database.
+func (r *userRegistrationRepository) UpdateRegistration(tx *gorm.DB, registration entity.UserRegistration) (entity.UserRegistration, error) {
+	if err := tx.Save(&registration).Error; err != nil {
+		return entity.UserRegistration{}, fmt.Errorf("failed to update user registration: %w", err)
+	}
+
+	return registration, nil
+}
@@ -1,22 +1,64 @@
 package repository
 
 import (
+	"encoding/json"
 	"fmt"
+	"slices"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
 )
 
 // Interface for user repository
+// The lookups are restricted to the tenant carried by the context of the transaction, if any,
+// and exclude the soft-deleted users unless they are given the WithDeleted option
 // This interface defines the methods that the user repository should implement
 type UserRepository interface {
-	GetUserByID(tx *gorm.DB, id int64) (entity.User, error)
+	GetUserByID(tx *gorm.DB, id int64, opts ...ReadOption) (entity.User, error)
+	GetUserByIDForUpdate(tx *gorm.DB, id int64, opts ...ReadOption) (entity.User, error)
+	GetUsersByIDs(tx *gorm.DB, ids []int64, withRoles bool, opts ...ReadOption) ([]entity.User, []int64, error)
 	GetUserByUsername(tx *gorm.DB, username string) (entity.User, error)
 	GetUserByEmail(tx *gorm.DB, email string) (entity.User, error)
+	GetUsersByMetadata(tx *gorm.DB, key string, value string, opts ...ReadOption) ([]entity.User, error)
+	GetUsers(tx *gorm.DB, filter entity.UserFilter, page int, limit int, opts ...ReadOption) ([]entity.User, error)
+	CountUsers(tx *gorm.DB, filter entity.UserFilter, opts ...ReadOption) (int64, error)
+	GetUsersAfter(tx *gorm.DB, afterID int64, limit int, opts ...ReadOption) ([]entity.User, error)
+	GetUsersInBatches(tx *gorm.DB, updatedSince *time.Time, batchSize int, fn func([]entity.User) error, opts ...ReadOption) error
+	GetUsersWithRoleForUpdate(tx *gorm.DB, roleName string, afterID int64, limit int, opts ...ReadOption) ([]entity.User, error)
+	GetDeletedUsers(tx *gorm.DB, page int, limit int) ([]entity.User, error)
+	CountDeletedUsers(tx *gorm.DB) (int64, error)
+	CountEnabledUsersWithRole(tx *gorm.DB, roleName string, opts ...ReadOption) (int64, error)
+	CountUsersWithRoles(tx *gorm.DB, roleNames []string, opts ...ReadOption) (int64, error)
+	CountUsersByRole(tx *gorm.DB, opts ...ReadOption) (map[string]int64, error)
+	GetUserSummary(tx *gorm.DB) (entity.UserSummary, error)
+	GetDuplicateUserClusters(tx *gorm.DB, minSimilarity float64, page int, limit int, opts ...ReadOption) ([]entity.DuplicateUserCluster, error)
+	CountDuplicateUserClusters(tx *gorm.DB, minSimilarity float64, opts ...ReadOption) (int64, error)
+	GetDuplicateClusterUsers(tx *gorm.DB, cluster entity.DuplicateUserCluster, minSimilarity float64, opts ...ReadOption) ([]entity.User, error)
+	CreateUser(tx *gorm.DB, user entity.User) (entity.User, error)
 	UpdateUser(tx *gorm.DB, user entity.User) (entity.User, error)
+	UpdatePasswordHash(tx *gorm.DB, id int64, hash string) error
+	ReplaceUserRoles(tx *gorm.DB, user entity.User, roles []entity.Role) (entity.User, error)
+	PatchUserRoles(tx *gorm.DB, user entity.User, add []entity.Role, remove []entity.Role) (entity.User, error)
+	DeleteUser(tx *gorm.DB, user entity.User, deletedBy int64) error
+	MergeUser(tx *gorm.DB, source entity.User, targetID int64, deletedBy int64) error
+	AnonymizeUser(tx *gorm.DB, user entity.User) (entity.User, error)
+	PurgeUser(tx *gorm.DB, id int64) error
+	DeleteExpiredUserRoles(tx *gorm.DB, expiredBefore time.Time) (int64, error)
 }
 
+const (
+	// duplicateEmailConfidence is the confidence of the users sharing a normalized email address,
+	// reaching the same inbox is a strong sign of the same person
+	duplicateEmailConfidence = 0.9
+
+	// duplicateNameWeight scales the username similarity of the users sharing a full name into a confidence,
+	// so a name match never outranks an email match
+	duplicateNameWeight = 0.8
+)
+
 // This struct defines the UserRepository that contains methods for interacting with the database
 // It implements the UserRepository interface and provides methods for user-related operations
 type userRepository struct{}
@@ -28,10 +70,10 @@ func NewUserRepository() UserRepository {
 }
 
 // GetUserByID retrieves a user by its ID from the database.
-func (r *userRepository) GetUserByID(tx *gorm.DB, id int64) (entity.User, error) {
+func (r *userRepository) GetUserByID(tx *gorm.DB, id int64, opts ...ReadOption) (entity.User, error) {
 	// Select the user with the given ID from the database
 	var user entity.User
-	err := tx.Preload("Roles").First(&user, "id = ?", id).Error
+	err := tx.Scopes(userReadScope(opts), preloadRoles).First(&user, "id = ?", id).Error
 
 	if err != nil {
 		return entity.User{}, err
@@ -40,11 +82,70 @@ func (r *userRepository) GetUserByID(tx *gorm.DB, id int64) (entity.User, error)
 	return user, nil
 }
 
+// GetUserByIDForUpdate retrieves a user by its ID and locks the row until the end of the transaction.
+// It is used to serialize concurrent operations on the same user.
+func (r *userRepository) GetUserByIDForUpdate(tx *gorm.DB, id int64, opts ...ReadOption) (entity.User, error) {
+	// Select the user with the given ID from the database with a row lock
+	var user entity.User
+	err := tx.Scopes(userReadScope(opts)).Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", id).Error
+
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	return user, nil
+}
+
+// GetUsersByIDs retrieves the users with the given IDs from the database in a single query, in the order of the IDs.
+// The roles of all users are loaded with one additional query when withRoles is set.
+// The IDs without a user are returned in the missing slice instead, a repeated ID is only returned once.
+func (r *userRepository) GetUsersByIDs(tx *gorm.DB, ids []int64, withRoles bool, opts ...ReadOption) ([]entity.User, []int64, error) {
+	if len(ids) == 0 {
+		return []entity.User{}, nil, nil
+	}
+
+	// Select the users with the given IDs from the database
+	query := tx.Scopes(userReadScope(opts)).Where("id IN ?", ids)
+	if withRoles {
+		query = query.Scopes(preloadRoles)
+	}
+
+	var found []entity.User
+	if err := query.Find(&found).Error; err != nil {
+		return nil, nil, err
+	}
+
+	// Put the users back in the order of the IDs
+	usersByID := make(map[int64]entity.User, len(found))
+	for _, user := range found {
+		usersByID[user.ID] = user
+	}
+
+	users := make([]entity.User, 0, len(found))
+	var missing []int64
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if user, ok := usersByID[id]; ok {
+			users = append(users, user)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return users, missing, nil
+}
+
 // GetUserByUsername retrieves a user by their username from the database.
+// It is used by the login, so it never returns a soft-deleted user.
 func (r *userRepository) GetUserByUsername(tx *gorm.DB, username string) (entity.User, error) {
 	// Select the user with the given username from the database
 	var user entity.User
-	err := tx.Preload("Roles").First(&user, "lower(username) = lower(?)", username).Error
+	err := tx.Scopes(userReadScope(nil), preloadRoles).First(&user, "lower(username) = lower(?)", username).Error
 
 	if err != nil {
 		return entity.User{}, err
@@ -54,10 +155,11 @@ func (r *userRepository) GetUserByUsername(tx *gorm.DB, username string) (entity
 }
 
 // GetUserByEmail retrieves a user by their email from the database.
+// Like GetUserByUsername, it never returns a soft-deleted user.
 func (r *userRepository) GetUserByEmail(tx *gorm.DB, email string) (entity.User, error) {
 	// Select the user with the given email from the database
 	var user entity.User
-	err := tx.Preload("Roles").First(&user, "lower(email) = lower(?)", email).Error
+	err := tx.Scopes(userReadScope(nil), preloadRoles).First(&user, "lower(email) = lower(?)", email).Error
 
 	if err != nil {
 		return entity.User{}, err
@@ -66,6 +168,368 @@ func (r *userRepository) GetUserByEmail(tx *gorm.DB, email string) (entity.User,
 	return user, nil
 }
 
+// GetUsersByMetadata retrieves the users whose metadata holds the given key and value.
+// On PostgreSQL, the lookup uses the jsonb containment operator, so it is served by the GIN index on the metadata.
+// The other dialects extract the key with the JSON_EXTRACT function.
+func (r *userRepository) GetUsersByMetadata(tx *gorm.DB, key string, value string, opts ...ReadOption) ([]entity.User, error) {
+	condition, err := metadataCondition(tx, key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	// Select the users holding the key and value in their metadata
+	var users []entity.User
+	err = tx.Scopes(userReadScope(opts), preloadRoles).Where(condition).Order("id ASC").Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// metadataCondition returns the condition matching the users whose metadata holds the given key and value
+// in the dialect of the database.
+func metadataCondition(tx *gorm.DB, key string, value string) (clause.Expr, error) {
+	if tx.Dialector.Name() == "postgres" {
+		// Build the containment document {"key": "value"}
+		document, err := entity.UserMetadata{key: value}.Value()
+		if err != nil {
+			return clause.Expr{}, err
+		}
+		return gorm.Expr("metadata @> ?::jsonb", document), nil
+	}
+
+	// Quote the key in the JSON path, so a key holding a dot is not read as a nested path
+	quotedKey, err := json.Marshal(key)
+	if err != nil {
+		return clause.Expr{}, err
+	}
+	return gorm.Expr("JSON_EXTRACT(metadata, ?) = ?", "$."+string(quotedKey), value), nil
+}
+
+// GetDuplicateUserClusters retrieves a page of the clusters of users that are likely the same person,
+// the most confident first. The users sharing a normalized email address form a cluster, and so do the users
+// having the same first and last names along with a username at least minSimilarity similar to another one of them.
+// Only the key of each cluster is returned, see GetDuplicateClusterUsers for its users.
+func (r *userRepository) GetDuplicateUserClusters(tx *gorm.DB, minSimilarity float64, page int, limit int, opts ...ReadOption) ([]entity.DuplicateUserCluster, error) {
+	var clusters []entity.DuplicateUserCluster
+	err := duplicateClusters(tx, minSimilarity, opts).
+		Order("confidence DESC, tenant_id ASC, reason ASC, cluster_key ASC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&clusters).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return clusters, nil
+}
+
+// CountDuplicateUserClusters counts the clusters returned by GetDuplicateUserClusters over all pages.
+func (r *userRepository) CountDuplicateUserClusters(tx *gorm.DB, minSimilarity float64, opts ...ReadOption) (int64, error) {
+	var total int64
+	if err := duplicateClusters(tx, minSimilarity, opts).Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// GetDuplicateClusterUsers retrieves the users of a cluster returned by GetDuplicateUserClusters, ordered by ID.
+func (r *userRepository) GetDuplicateClusterUsers(tx *gorm.DB, cluster entity.DuplicateUserCluster, minSimilarity float64, opts ...ReadOption) ([]entity.User, error) {
+	query := tx.Scopes(userReadScope(opts), preloadRoles).Where("tenant_id = ?", cluster.TenantID)
+
+	switch cluster.Reason {
+	case entity.DuplicateReasonEmail:
+		query = query.Where("normalize_email(email) = ?", cluster.Key)
+	case entity.DuplicateReasonName:
+		// Leave out the namesakes whose username is not similar to any other one
+		namesakes := tx.Model(&entity.User{}).Scopes(userReadScope(opts)).
+			Select("id, username").
+			Where("tenant_id = ? AND "+fullNameKey("")+" = ?", cluster.TenantID, cluster.Key)
+		query = query.Where(fullNameKey("")+" = ?", cluster.Key).
+			Where("EXISTS (SELECT 1 FROM (?) AS b WHERE b.id <> users.id AND similarity(b.username, users.username) >= ?)", namesakes, minSimilarity)
+	default:
+		return nil, fmt.Errorf("unknown duplicate reason %q", cluster.Reason)
+	}
+
+	var users []entity.User
+	if err := query.Order("id ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// duplicateClusters returns the query of the clusters of duplicate users, see GetDuplicateUserClusters.
+// The users are only compared to the users having the same full name, so the trigram similarity
+// is computed for a few pairs, through the full name index of PostgreSQL.
+func duplicateClusters(tx *gorm.DB, minSimilarity float64, opts []ReadOption) *gorm.DB {
+	emails := tx.Model(&entity.User{}).Scopes(userReadScope(opts)).
+		Select(fmt.Sprintf("tenant_id, '%s' AS reason, normalize_email(email) AS cluster_key, %g AS confidence",
+			entity.DuplicateReasonEmail, duplicateEmailConfidence)).
+		Group("tenant_id, normalize_email(email)").
+		Having("COUNT(*) > 1")
+
+	active := tx.Model(&entity.User{}).Scopes(userReadScope(opts)).Select("id, tenant_id, username, firstname, lastname")
+	names := tx.Table("(?) AS a", active).
+		Joins("JOIN (?) AS b ON b.tenant_id = a.tenant_id AND b.id > a.id"+
+			" AND lower(b.firstname) = lower(a.firstname) AND lower(COALESCE(b.lastname, '')) = lower(COALESCE(a.lastname, ''))", active).
+		Where("similarity(a.username, b.username) >= ?", minSimilarity).
+		Select(fmt.Sprintf("a.tenant_id, '%s' AS reason, %s AS cluster_key, MAX(similarity(a.username, b.username)) * %g AS confidence",
+			entity.DuplicateReasonName, fullNameKey("a"), duplicateNameWeight)).
+		Group("a.tenant_id, " + fullNameKey("a"))
+
+	return tx.Table("(SELECT * FROM (?) AS emails UNION ALL SELECT * FROM (?) AS names) AS clusters", emails, names)
+}
+
+// fullNameKey returns the expression of the lowercased full name of a user, the key of the name clusters.
+func fullNameKey(alias string) string {
+	if alias != "" {
+		alias += "."
+	}
+	return fmt.Sprintf("lower(%[1]sfirstname) || ' ' || lower(COALESCE(%[1]slastname, ''))", alias)
+}
+
+// GetUsers retrieves a page of the users matching the filter from the database, ordered by ID.
+// With an update time in the filter, the users are ordered by update time and ID instead,
+// and with a creation time only, by creation time and ID, so the pages are read from the indexes of the times.
+// The delta synchronization passes WithDeleted, so the deletions can be mirrored.
+func (r *userRepository) GetUsers(tx *gorm.DB, filter entity.UserFilter, page int, limit int, opts ...ReadOption) ([]entity.User, error) {
+	// The changes are returned in the order they happened, the ID breaks the ties
+	order := "id ASC"
+	switch {
+	case filter.ModifiedSince != nil || filter.UpdatedSince != nil:
+		order = "updated_at ASC, id ASC"
+	case filter.CreatedSince != nil:
+		order = "created_at ASC, id ASC"
+	}
+
+	var users []entity.User
+	err := tx.Scopes(userReadScope(opts), userFilterScope(filter), preloadRoles).
+		Order(order).
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&users).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// CountUsers counts the users returned by GetUsers over all pages.
+func (r *userRepository) CountUsers(tx *gorm.DB, filter entity.UserFilter, opts ...ReadOption) (int64, error) {
+	var total int64
+	err := tx.Model(&entity.User{}).Scopes(userReadScope(opts), userFilterScope(filter)).Count(&total).Error
+
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// GetUsersAfter retrieves up to limit users whose ID is greater than afterID, ordered by ID.
+// Unlike the pages of GetUsers, the lists read this way do not skip or repeat users when users are created or deleted meanwhile.
+func (r *userRepository) GetUsersAfter(tx *gorm.DB, afterID int64, limit int, opts ...ReadOption) ([]entity.User, error) {
+	var users []entity.User
+	err := tx.Scopes(userReadScope(opts), preloadRoles).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&users).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetUsersInBatches retrieves the users ordered by ID and hands them over to fn a batch at a time,
+// so the whole table is read without holding more than a batch in memory.
+// Every batch is a query of its own, keyed on the last ID of the previous one, so neither a connection nor a transaction
+// is held while fn writes a batch out, and a table of millions of users is read at the same cost per batch.
+// With an updatedSince time, only the users updated strictly after it are retrieved.
+// The reading stops at the first error of fn or of the queries, which is returned.
+func (r *userRepository) GetUsersInBatches(tx *gorm.DB, updatedSince *time.Time, batchSize int, fn func([]entity.User) error, opts ...ReadOption) error {
+	var users []entity.User
+	return tx.Scopes(userReadScope(opts), modifiedSinceScope(updatedSince), preloadRoles).
+		FindInBatches(&users, batchSize, func(_ *gorm.DB, _ int) error {
+			return fn(users)
+		}).Error
+}
+
+// modifiedSinceScope restricts the query to the users updated strictly after the given time.
+// The soft delete updates the row, so a deletion is returned like any other change when the deleted users are included.
+func modifiedSinceScope(modifiedSince *time.Time) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if modifiedSince == nil {
+			return tx
+		}
+
+		return tx.Where("updated_at > ?", *modifiedSince)
+	}
+}
+
+// userFilterScope restricts the query to the users matching the filter, the conditions are combined with AND.
+// The times of UpdatedSince and CreatedSince are included, the intervals are half-open.
+func userFilterScope(filter entity.UserFilter) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		tx = tx.Scopes(modifiedSinceScope(filter.ModifiedSince))
+		if filter.UpdatedSince != nil {
+			tx = tx.Where("updated_at >= ?", *filter.UpdatedSince)
+		}
+		if filter.CreatedSince != nil {
+			tx = tx.Where("created_at >= ?", *filter.CreatedSince)
+		}
+		if filter.GroupID != nil {
+			tx = tx.Where("id IN (SELECT user_id FROM group_memberships WHERE group_id = ?)", *filter.GroupID)
+		}
+
+		return tx
+	}
+}
+
+// GetDeletedUsers retrieves a page of the soft-deleted users from the database, the oldest deletions first.
+func (r *userRepository) GetDeletedUsers(tx *gorm.DB, page int, limit int) ([]entity.User, error) {
+	var users []entity.User
+	err := tx.Scopes(userReadScope([]ReadOption{WithDeleted()}), preloadRoles).
+		Where("deleted_at IS NOT NULL").
+		Order("deleted_at ASC, id ASC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&users).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// CountDeletedUsers counts the soft-deleted users returned by GetDeletedUsers over all pages.
+func (r *userRepository) CountDeletedUsers(tx *gorm.DB) (int64, error) {
+	var total int64
+	err := tx.Model(&entity.User{}).Scopes(userReadScope([]ReadOption{WithDeleted()})).Where("deleted_at IS NOT NULL").Count(&total).Error
+
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// CountEnabledUsersWithRole counts the enabled users holding the role, the name is compared case-insensitively.
+func (r *userRepository) CountEnabledUsersWithRole(tx *gorm.DB, roleName string, opts ...ReadOption) (int64, error) {
+	var total int64
+	err := tx.Model(&entity.User{}).Scopes(userReadScope(opts)).
+		Where("is_enabled = ?", true).
+		Where(roleHoldersCondition(roleName)).
+		Count(&total).Error
+
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// CountUsersWithRoles counts the users holding every one of the roles, the names are compared case-insensitively.
+func (r *userRepository) CountUsersWithRoles(tx *gorm.DB, roleNames []string, opts ...ReadOption) (int64, error) {
+	query := tx.Model(&entity.User{}).Scopes(userReadScope(opts))
+	for _, roleName := range roleNames {
+		query = query.Where(roleHoldersCondition(roleName))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// CountUsersByRole counts the users holding each role, by role name, with a single grouped query.
+// The roles without any user are left out.
+func (r *userRepository) CountUsersByRole(tx *gorm.DB, opts ...ReadOption) (map[string]int64, error) {
+	var rows []struct {
+		Role  string
+		Users int64
+	}
+	err := tx.Model(&entity.User{}).Scopes(userReadScope(opts)).
+		Select("roles.name AS role, COUNT(*) AS users").
+		Joins("JOIN user_roles ON user_roles.user_id = users.id").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where(activeUserRolesCondition, time.Now().UTC()).
+		Group("roles.name").
+		Scan(&rows).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Role] = row.Users
+	}
+
+	return counts, nil
+}
+
+// GetUserSummary counts the users by state with a single aggregate query, see entity.UserSummary.
+// The soft-deleted users are only counted as deleted.
+func (r *userRepository) GetUserSummary(tx *gorm.DB) (entity.UserSummary, error) {
+	var summary entity.UserSummary
+	err := tx.Model(&entity.User{}).Scopes(userReadScope([]ReadOption{WithDeleted()})).
+		Select(`COUNT(CASE WHEN deleted_at IS NULL THEN 1 END) AS total,
+			COUNT(CASE WHEN deleted_at IS NULL AND is_enabled THEN 1 END) AS enabled,
+			COUNT(CASE WHEN deleted_at IS NULL AND NOT is_enabled THEN 1 END) AS disabled,
+			COUNT(CASE WHEN deleted_at IS NULL AND NOT is_account_non_locked THEN 1 END) AS locked,
+			COUNT(CASE WHEN deleted_at IS NOT NULL THEN 1 END) AS deleted`).
+		Scan(&summary).Error
+
+	if err != nil {
+		return entity.UserSummary{}, err
+	}
+
+	return summary, nil
+}
+
+// GetUsersWithRoleForUpdate retrieves up to limit users holding the role whose ID is greater than afterID, ordered by ID,
+// and locks their rows until the end of the transaction. The name is compared case-insensitively.
+// It walks through the holders of a role batch by batch, each batch in its own transaction.
+func (r *userRepository) GetUsersWithRoleForUpdate(tx *gorm.DB, roleName string, afterID int64, limit int, opts ...ReadOption) ([]entity.User, error) {
+	var users []entity.User
+	err := tx.Scopes(userReadScope(opts)).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(roleHoldersCondition(roleName)).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&users).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// CreateUser inserts a new user in the database along with its roles, and returns the created user.
+// The roles must already exist, only the user_roles rows are inserted for them.
+func (r *userRepository) CreateUser(tx *gorm.DB, user entity.User) (entity.User, error) {
+	// Insert the user and its user_roles rows, without touching the roles themselves
+	if err := tx.Omit("Roles.*").Create(&user).Error; err != nil {
+		return entity.User{}, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return user, nil
+}
+
 // UpdateUser updates an existing user in the database and returns the updated user.
 func (r *userRepository) UpdateUser(tx *gorm.DB, user entity.User) (entity.User, error) {
 	// Update the user in the database
@@ -75,3 +539,194 @@ func (r *userRepository) UpdateUser(tx *gorm.DB, user entity.User) (entity.User,
 
 	return user, nil
 }
+
+// UpdatePasswordHash replaces the stored hash of the password of a user, e.g. with the hash of the same password
+// by the current algorithm. The password does not change, so the user is not touched: its update time and actor are kept.
+func (r *userRepository) UpdatePasswordHash(tx *gorm.DB, id int64, hash string) error {
+	result := tx.Model(&entity.User{}).Where("id = ?", id).UpdateColumn("password", hash)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update password hash: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ReplaceUserRoles replaces the roles of a user with the given ones, and returns the user with its new roles.
+// The roles must already exist, only the user_roles rows of the user are rewritten.
+func (r *userRepository) ReplaceUserRoles(tx *gorm.DB, user entity.User, roles []entity.Role) (entity.User, error) {
+	// Remove the current user_roles rows of the user
+	if err := tx.Where("user_id = ?", user.ID).Delete(&entity.UserRole{}).Error; err != nil {
+		return entity.User{}, fmt.Errorf("failed to replace user roles: %w", err)
+	}
+
+	// Insert a user_roles row for each new role
+	if len(roles) > 0 {
+		userRoles := make([]entity.UserRole, len(roles))
+		for i, role := range roles {
+			userRoles[i] = entity.UserRole{UserID: user.ID, RoleID: role.ID, ExpiresAt: role.ExpiresAt}
+		}
+		if err := tx.Create(&userRoles).Error; err != nil {
+			return entity.User{}, fmt.Errorf("failed to replace user roles: %w", err)
+		}
+	}
+
+	// Touch the user, its roles are returned along with it so the lists of the changes must see them.
+	// The model carries the ID only, GORM would save the previous roles of the user otherwise.
+	now := tx.NowFunc()
+	if err := tx.Model(&entity.User{ID: user.ID}).Update("updated_at", now).Error; err != nil {
+		return entity.User{}, fmt.Errorf("failed to replace user roles: %w", err)
+	}
+	user.UpdatedAt = &now
+
+	user.Roles = roles
+	return user, nil
+}
+
+// PatchUserRoles adds and removes some roles of a user, the other roles are kept.
+// The added roles are assigned until their ExpiresAt, or permanently without one: the assignment of a role
+// the user already holds, or held until it expired, is replaced, so its expiry is updated.
+// The roles of the given user are its current ones, the user is returned with its new roles.
+func (r *userRepository) PatchUserRoles(tx *gorm.DB, user entity.User, add []entity.Role, remove []entity.Role) (entity.User, error) {
+	// The model carries the ID only, GORM would save the current roles of the user otherwise
+	model := &entity.User{ID: user.ID}
+
+	if len(remove) > 0 {
+		if err := tx.Model(model).Association("Roles").Delete(remove); err != nil {
+			return entity.User{}, fmt.Errorf("failed to patch user roles: %w", err)
+		}
+	}
+
+	// The roles exist already, only the user_roles rows are rewritten along with the expiry of the assignments
+	if len(add) > 0 {
+		roleIDs := make([]uint, len(add))
+		userRoles := make([]entity.UserRole, len(add))
+		for i, role := range add {
+			roleIDs[i] = role.ID
+			userRoles[i] = entity.UserRole{UserID: user.ID, RoleID: role.ID, ExpiresAt: role.ExpiresAt}
+		}
+		if err := tx.Where("user_id = ? AND role_id IN ?", user.ID, roleIDs).Delete(&entity.UserRole{}).Error; err != nil {
+			return entity.User{}, fmt.Errorf("failed to patch user roles: %w", err)
+		}
+		if err := tx.Create(&userRoles).Error; err != nil {
+			return entity.User{}, fmt.Errorf("failed to patch user roles: %w", err)
+		}
+	}
+
+	// Touch the user, like ReplaceUserRoles, so the lists of the changes see its new roles
+	now := tx.NowFunc()
+	if err := tx.Model(&entity.User{ID: user.ID}).Update("updated_at", now).Error; err != nil {
+		return entity.User{}, fmt.Errorf("failed to patch user roles: %w", err)
+	}
+	user.UpdatedAt = &now
+
+	roles := slices.DeleteFunc(slices.Clone(user.Roles), func(role entity.Role) bool {
+		return slices.ContainsFunc(remove, func(removed entity.Role) bool { return removed.ID == role.ID })
+	})
+	for _, role := range add {
+		if i := slices.IndexFunc(roles, func(current entity.Role) bool { return current.ID == role.ID }); i >= 0 {
+			roles[i] = role
+		} else {
+			roles = append(roles, role)
+		}
+	}
+
+	user.Roles = roles
+	return user, nil
+}
+
+// DeleteUser soft-deletes a user, the row is kept with its deletion timestamp and the actor.
+// The user is no longer returned by the other lookups, Unscoped still retrieves it.
+func (r *userRepository) DeleteUser(tx *gorm.DB, user entity.User, deletedBy int64) error {
+	// Flag the user as deleted along with the actor, the flag is kept in sync with the timestamp
+	if err := tx.Model(&user).Updates(map[string]any{"is_deleted": true, "deleted_by": deletedBy}).Error; err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	// Set the deletion timestamp, GORM turns the delete into an update of deleted_at
+	if err := tx.Delete(&user).Error; err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	return nil
+}
+
+// MergeUser soft-deletes the source user of a merge, along with the reference to the user it was merged into.
+func (r *userRepository) MergeUser(tx *gorm.DB, source entity.User, targetID int64, deletedBy int64) error {
+	if err := tx.Model(&source).Update("merged_into", targetID).Error; err != nil {
+		return fmt.Errorf("failed to merge user: %w", err)
+	}
+
+	return r.DeleteUser(tx, source, deletedBy)
+}
+
+// AnonymizeUser replaces the personal data of a user with placeholders derived from its ID, and removes
+// the records holding more of it: its sessions, password history and login history.
+// The row is kept, so the references to the user stay valid. The password is replaced by a value no hash matches.
+func (r *userRepository) AnonymizeUser(tx *gorm.DB, user entity.User) (entity.User, error) {
+	dependents := []any{
+		&entity.RefreshToken{},
+		&entity.PasswordHistory{},
+		&entity.LoginAttempt{},
+	}
+	for _, dependent := range dependents {
+		if err := tx.Where("user_id = ?", user.ID).Delete(dependent).Error; err != nil {
+			return entity.User{}, fmt.Errorf("failed to anonymize user: %w", err)
+		}
+	}
+
+	user.Username = fmt.Sprintf("deleted-%d", user.ID)
+	user.Email = fmt.Sprintf("deleted-%d@deleted.invalid", user.ID)
+	user.Firstname = "Deleted"
+	user.Lastname = nil
+	user.Password = "!"
+	user.Metadata = entity.UserMetadata{}
+	err := tx.Model(&user).Select("username", "email", "firstname", "lastname", "password", "metadata").Updates(&user).Error
+	if err != nil {
+		return entity.User{}, fmt.Errorf("failed to anonymize user: %w", err)
+	}
+
+	return user, nil
+}
+
+// PurgeUser permanently deletes a user along with the records referencing it:
+// its sessions, password history, roles, tenant memberships, login history, notes, registration and group memberships.
+func (r *userRepository) PurgeUser(tx *gorm.DB, id int64) error {
+	// Remove the dependent records first, the rows referencing the user would otherwise block its deletion
+	dependents := []any{
+		&entity.RefreshToken{},
+		&entity.PasswordHistory{},
+		&entity.UserRole{},
+		&entity.UserTenant{},
+		&entity.LoginAttempt{},
+		&entity.UserNote{},
+		&entity.UserRegistration{},
+		&entity.GroupMembership{},
+	}
+	for _, dependent := range dependents {
+		if err := tx.Where("user_id = ?", id).Delete(dependent).Error; err != nil {
+			return fmt.Errorf("failed to purge user: %w", err)
+		}
+	}
+
+	// Delete the row itself, Unscoped turns the soft delete into a real one
+	if err := tx.Unscoped().Delete(&entity.User{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to purge user: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredUserRoles removes the role assignments of every user that expired before the given time, and returns
+// how many were removed. The expired assignments are already ignored by the lookups, their rows are kept for a while
+// so the admins can tell a role that expired from one that was never granted.
+func (r *userRepository) DeleteExpiredUserRoles(tx *gorm.DB, expiredBefore time.Time) (int64, error) {
+	result := tx.Where("expires_at IS NOT NULL AND expires_at < ?", expiredBefore.UTC()).Delete(&entity.UserRole{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired user roles: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,510 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+)
+
+const (
+	// defaultAccountDeletionGraceDays is the default number of days a deactivated account can be reactivated
+	defaultAccountDeletionGraceDays = 30
+
+	// defaultRoleAssignmentRetentionDays is the default number of days the expired role assignments are kept
+	defaultRoleAssignmentRetentionDays = 30
+
+	// accountDeletionReminderLead is how long before the deletion of a deactivated account its user is reminded
+	accountDeletionReminderLead = 7 * 24 * time.Hour
+
+	// accountJanitorInterval is the interval of the janitor processing the scheduled deletions
+	accountJanitorInterval = time.Hour
+
+	// accountJanitorActor is the name of the system actor of the janitor, recorded in the audit log
+	accountJanitorActor = "account-janitor"
+)
+
+var (
+	// ErrAccountAlreadyDeactivated is returned when the account to deactivate is already scheduled for deletion.
+	ErrAccountAlreadyDeactivated = errors.New("account is already deactivated")
+
+	// ErrAccountDeactivated is returned at login when the account was deactivated by its user,
+	// it can be reactivated until its scheduled deletion.
+	ErrAccountDeactivated = errors.New("account is deactivated and scheduled for deletion")
+
+	// ErrAccountNotDeactivated is returned when the account to reactivate is not scheduled for deletion.
+	ErrAccountNotDeactivated = errors.New("account is not scheduled for deletion")
+
+	// ErrAccountGracePeriodOver is returned when the account to reactivate is past its scheduled deletion.
+	ErrAccountGracePeriodOver = errors.New("the grace period of the account is over")
+)
+
+// accountJanitor processes the scheduled deletions in the background, until it is stopped.
+type accountJanitor struct {
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+var janitor = &accountJanitor{}
+
+// Interface for account service
+// This interface defines the methods that the account service should implement
+type AccountService interface {
+	DeactivateAccount(ctx context.Context) (entity.ScheduledDeletion, error)
+	ReactivateAccount(ctx context.Context, userID int64) (entity.User, error)
+	ProcessScheduledDeletions(ctx context.Context, now time.Time) (entity.ScheduledDeletionRun, error)
+	PurgeExpiredRoleAssignments(ctx context.Context, now time.Time) (int64, error)
+}
+
+// This struct defines the AccountService that contains a repository field of type ScheduledDeletionRepository
+// It implements the AccountService interface and provides methods for the self-service account operations
+type accountService struct {
+	repo  repository.ScheduledDeletionRepository
+	clock clock.Clock
+}
+
+// NewAccountService creates a new instance of AccountService with the given repository.
+// The grace period of the deactivated accounts is measured with the given clock.
+func NewAccountService(repo repository.ScheduledDeletionRepository, clk clock.Clock) AccountService {
+	return &accountService{repo: repo, clock: clk}
+}
+
+// DeactivateAccount disables the account of the authenticated user, revokes its sessions and schedules
+// its anonymization at the end of the grace period, in a single transaction.
+// The user is sent an email through the outbox, and the last enabled admin cannot deactivate their account.
+func (s *accountService) DeactivateAccount(ctx context.Context) (entity.ScheduledDeletion, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.ScheduledDeletion{}, err
+	}
+
+	// The account to deactivate is the one of the authenticated user
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok || meta.IsSystem {
+		return entity.ScheduledDeletion{}, fmt.Errorf("missing user context")
+	}
+
+	var deletion entity.ScheduledDeletion
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		userRepo := repository.NewUserRepository()
+		user, err := userRepo.GetUserByIDForUpdate(tx, meta.UserID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.repo.GetScheduledDeletionByUserID(tx, user.ID); err == nil {
+			return ErrAccountAlreadyDeactivated
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// Deactivating the last enabled admin would leave nobody to administer the users
+		if err := (&userService{repo: userRepo}).ensureAdminRemains(tx, user); err != nil {
+			return err
+		}
+
+		// Disable the account and revoke its sessions, the user can no longer log in
+		disabled := false
+		user.IsEnabled = &disabled
+		if _, err := userRepo.UpdateUser(tx, user); err != nil {
+			return err
+		}
+		if _, err := repository.NewRefreshTokenRepository().RemoveRefreshTokenByUserID(tx, user.ID); err != nil {
+			return err
+		}
+
+		deletion, err = s.repo.CreateScheduledDeletion(tx, entity.ScheduledDeletion{
+			TenantID: user.TenantID,
+			UserID:   user.ID,
+			DeleteAt: s.clock.Now().UTC().Add(time.Duration(GetAccountDeletionGraceDays()) * 24 * time.Hour),
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := recordAccountEvent(tx, entity.OutboxEventAccountDeactivated, user, &deletion.DeleteAt); err != nil {
+			return err
+		}
+		return recordAccountAudit(tx, meta, user, "deactivate",
+			fmt.Sprintf("Deletion scheduled at %s", deletion.DeleteAt.Format(time.RFC3339)))
+	})
+
+	if err != nil {
+		return entity.ScheduledDeletion{}, err
+	}
+
+	logger.Info(fmt.Sprintf("User %d deactivated their account, deletion scheduled at %s", meta.UserID, deletion.DeleteAt.Format(time.RFC3339)), logrus.Fields{
+		"userID":  meta.UserID,
+		"actor":   meta.Actor(),
+		"tokenID": meta.TokenID,
+	})
+
+	return deletion, nil
+}
+
+// ReactivateAccount enables a deactivated account again and cancels its scheduled deletion, in a single transaction.
+// It returns ErrAccountNotDeactivated if the account is not scheduled for deletion, and ErrAccountGracePeriodOver
+// if its deletion is due. The credentials of the user must have been checked by the caller.
+func (s *accountService) ReactivateAccount(ctx context.Context, userID int64) (entity.User, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	// The reactivation is performed by the user themselves, who is recorded by the audit callbacks
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.User{}, fmt.Errorf("missing user context")
+	}
+
+	var user entity.User
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		deletion, err := s.repo.GetScheduledDeletionByUserID(tx, userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAccountNotDeactivated
+		}
+		if err != nil {
+			return err
+		}
+
+		// Lock the scheduled deletion, so the janitor does not anonymize the account while it is reactivated
+		deletion, err = s.repo.GetScheduledDeletionByIDForUpdate(tx, deletion.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAccountNotDeactivated
+		}
+		if err != nil {
+			return err
+		}
+		if !s.clock.Now().Before(deletion.DeleteAt) {
+			return ErrAccountGracePeriodOver
+		}
+
+		userRepo := repository.NewUserRepository()
+		user, err = userRepo.GetUserByIDForUpdate(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		enabled := true
+		user.IsEnabled = &enabled
+		if user, err = userRepo.UpdateUser(tx, user); err != nil {
+			return err
+		}
+		if err := s.repo.RemoveScheduledDeletion(tx, deletion.ID); err != nil {
+			return err
+		}
+
+		return recordAccountAudit(tx, meta, user, "reactivate", "Scheduled deletion cancelled")
+	})
+
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	logger.Info(fmt.Sprintf("User %d reactivated their account", userID), logrus.Fields{
+		"userID": userID,
+		"actor":  meta.Actor(),
+	})
+
+	return user, nil
+}
+
+// ProcessScheduledDeletions reminds the users whose account is deleted within the reminder lead, and anonymizes
+// the accounts whose grace period is over. Each account is processed in its own transaction, so a failure
+// only postpones that account to the next run. It is run by the janitor, across all tenants.
+func (s *accountService) ProcessScheduledDeletions(ctx context.Context, now time.Time) (entity.ScheduledDeletionRun, error) {
+	var run entity.ScheduledDeletionRun
+
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return run, err
+	}
+
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return run, fmt.Errorf("missing user context")
+	}
+
+	reminders, err := s.repo.GetScheduledDeletionsToRemind(db.WithContext(ctx), now, now.Add(accountDeletionReminderLead))
+	if err != nil {
+		return run, err
+	}
+	var errs []error
+	for _, deletion := range reminders {
+		if err := s.remindScheduledDeletion(ctx, db, meta, deletion.ID, now); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remind user %d: %w", deletion.UserID, err))
+			continue
+		}
+		run.Reminded++
+	}
+
+	due, err := s.repo.GetDueScheduledDeletions(db.WithContext(ctx), now)
+	if err != nil {
+		return run, errors.Join(append(errs, err)...)
+	}
+	for _, deletion := range due {
+		if err := s.completeScheduledDeletion(ctx, db, meta, deletion.ID, now); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete user %d: %w", deletion.UserID, err))
+			continue
+		}
+		run.Deleted++
+	}
+
+	return run, errors.Join(errs...)
+}
+
+// remindScheduledDeletion sends the reminder of a scheduled deletion through the outbox, once.
+func (s *accountService) remindScheduledDeletion(ctx context.Context, db *gorm.DB, meta metacontext.UserInformationMeta, id int64, now time.Time) error {
+	return database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// The account may have been reactivated since it was listed
+		deletion, err := s.repo.GetScheduledDeletionByIDForUpdate(tx, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if deletion.ReminderSentAt != nil {
+			return nil
+		}
+
+		user, err := repository.NewUserRepository().GetUserByID(tx, deletion.UserID)
+		if err != nil {
+			return err
+		}
+
+		sentAt := now.UTC()
+		deletion.ReminderSentAt = &sentAt
+		if _, err := s.repo.UpdateScheduledDeletion(tx, deletion); err != nil {
+			return err
+		}
+		if err := recordAccountEvent(tx, entity.OutboxEventAccountDeletionReminder, user, &deletion.DeleteAt); err != nil {
+			return err
+		}
+		return recordAccountAudit(tx, meta, user, "deletion_reminder",
+			fmt.Sprintf("Deletion scheduled at %s", deletion.DeleteAt.Format(time.RFC3339)))
+	})
+}
+
+// PurgeExpiredRoleAssignments removes the role assignments that expired more than the retention period before now,
+// and returns how many were removed. The expired assignments no longer grant their role, only their rows are left.
+func (s *accountService) PurgeExpiredRoleAssignments(ctx context.Context, now time.Time) (int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	expiredBefore := now.UTC().Add(-time.Duration(GetRoleAssignmentRetentionDays()) * 24 * time.Hour)
+	var deleted int64
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		deleted, err = repository.NewUserRepository().DeleteExpiredUserRoles(tx, expiredBefore)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// completeScheduledDeletion anonymizes and soft-deletes the account of a scheduled deletion, then removes it.
+// The deletion email is sent through the outbox to the address the account had before.
+func (s *accountService) completeScheduledDeletion(ctx context.Context, db *gorm.DB, meta metacontext.UserInformationMeta, id int64, now time.Time) error {
+	return database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// The account may have been reactivated since it was listed
+		deletion, err := s.repo.GetScheduledDeletionByIDForUpdate(tx, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if now.Before(deletion.DeleteAt) {
+			return nil
+		}
+
+		// A user deleted by an admin in the meantime is anonymized all the same
+		userRepo := repository.NewUserRepository()
+		user, err := userRepo.GetUserByIDForUpdate(tx, deletion.UserID, repository.WithDeleted())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return s.repo.RemoveScheduledDeletion(tx, deletion.ID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := recordAccountEvent(tx, entity.OutboxEventAccountDeleted, user, nil); err != nil {
+			return err
+		}
+
+		anonymized, err := userRepo.AnonymizeUser(tx, user)
+		if err != nil {
+			return err
+		}
+		if !anonymized.DeletedAt.Valid {
+			if err := userRepo.DeleteUser(tx, anonymized, meta.UserID); err != nil {
+				return err
+			}
+		}
+		if err := s.repo.RemoveScheduledDeletion(tx, deletion.ID); err != nil {
+			return err
+		}
+
+		return recordAccountAudit(tx, meta, anonymized, "anonymize", "Account deleted at the end of its grace period")
+	})
+}
+
+// recordAccountEvent records an account event for the user in the outbox, in the transaction of the change.
+func recordAccountEvent(tx *gorm.DB, eventType string, user entity.User, deleteAt *time.Time) error {
+	payload, err := json.Marshal(entity.AccountEventPayload{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Firstname: user.Firstname,
+		DeleteAt:  deleteAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	_, err = repository.NewOutboxEventRepository().CreateOutboxEvent(tx, entity.OutboxEvent{
+		TenantID:      user.TenantID,
+		Type:          eventType,
+		AggregateType: "user",
+		AggregateID:   strconv.FormatInt(user.ID, 10),
+		Payload:       string(payload),
+	})
+	return err
+}
+
+// recordAccountAudit records a state transition of the account of the user in the audit log.
+func recordAccountAudit(tx *gorm.DB, meta metacontext.UserInformationMeta, user entity.User, action string, details string) error {
+	actorID := meta.UserID
+	_, err := repository.NewAuditLogRepository().CreateAuditLog(tx, entity.AuditLog{
+		TenantID:   user.TenantID,
+		ActorID:    &actorID,
+		Actor:      meta.Actor(),
+		Action:     action,
+		EntityType: "user",
+		EntityID:   strconv.FormatInt(user.ID, 10),
+		Details:    &details,
+		CreatedAt:  time.Now().UTC(),
+	})
+	return err
+}
+
+// checkDeactivatedAccount returns ErrAccountDeactivated, along with the date of the deletion, if the disabled user
+// deactivated their account and the password matched theirs. It returns nil otherwise, the login then reports the
+// account as disabled, so the deactivation is only told to the caller knowing the password.
+func checkDeactivatedAccount(tx *gorm.DB, user entity.User, passwordMatches bool) error {
+	if !passwordMatches {
+		return nil
+	}
+	deletion, err := repository.NewScheduledDeletionRepository().GetScheduledDeletionByUserID(tx, user.ID)
+	if err != nil {
+		return nil
+	}
+
+	return fmt.Errorf("%w: it is deleted at %s unless it is reactivated", ErrAccountDeactivated, deletion.DeleteAt.UTC().Format(time.RFC3339))
+}
+
+// StartAccountJanitor starts the background janitor processing the scheduled deletions and purging the expired role
+// assignments, once at start and then at every interval of the given clock. It does nothing if it is already started.
+func StartAccountJanitor(clk clock.Clock) {
+	janitor.mu.Lock()
+	defer janitor.mu.Unlock()
+
+	if janitor.stop != nil {
+		return
+	}
+
+	janitor.stop = make(chan struct{})
+	janitor.done = make(chan struct{})
+	go runAccountJanitor(NewAccountService(repository.NewScheduledDeletionRepository(), clk), clk, janitor.stop, janitor.done)
+}
+
+// StopAccountJanitor stops the background janitor, after the run in progress if any.
+func StopAccountJanitor() {
+	janitor.mu.Lock()
+	defer janitor.mu.Unlock()
+
+	if janitor.stop == nil {
+		return
+	}
+
+	close(janitor.stop)
+	<-janitor.done
+	janitor.stop = nil
+}
+
+// runAccountJanitor processes the scheduled deletions and purges the expired role assignments at every interval,
+// until the stop channel is closed.
+func runAccountJanitor(s AccountService, clk clock.Clock, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ctx := metacontext.WithSystemActor(context.Background(), accountJanitorActor)
+	process := func(now time.Time) {
+		run, err := s.ProcessScheduledDeletions(ctx, now.UTC())
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to process the scheduled deletions: %v", err), nil)
+		}
+		if run.Reminded > 0 || run.Deleted > 0 {
+			logger.Info(fmt.Sprintf("Sent %d deletion reminders and deleted %d deactivated accounts", run.Reminded, run.Deleted), nil)
+		}
+
+		purged, err := s.PurgeExpiredRoleAssignments(ctx, now.UTC())
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to purge the expired role assignments: %v", err), nil)
+		}
+		if purged > 0 {
+			logger.Info(fmt.Sprintf("Purged %d expired role assignments", purged), nil)
+		}
+	}
+
+	process(clk.Now())
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-clk.After(accountJanitorInterval):
+			process(now)
+		}
+	}
+}
+
+// GetAccountDeletionGraceDays returns the number of days a deactivated account can be reactivated before it is deleted.
+// It retrieves the grace period from an environment variable.
+func GetAccountDeletionGraceDays() int {
+	days, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"))
+	if err != nil || days <= 0 {
+		return defaultAccountDeletionGraceDays // Default to 30 days if the environment variable is not set or invalid
+	}
+
+	return days
+}
+
+// GetRoleAssignmentRetentionDays returns the number of days the expired role assignments are kept before being purged.
+// It retrieves the retention period from an environment variable.
+func GetRoleAssignmentRetentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("ROLE_ASSIGNMENT_RETENTION_DAYS"))
+	if err != nil || days < 0 {
+		return defaultRoleAssignmentRetentionDays // Default to 30 days if the environment variable is not set or invalid
+	}
+
+	return days
+}
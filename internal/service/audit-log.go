@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+)
+
+// Interface for audit log service
+// This interface defines the methods that the audit log service should implement
+type AuditLogService interface {
+	GetAuditLogs(ctx context.Context, filter entity.AuditLogFilter) ([]entity.AuditLog, int64, error)
+}
+
+// This struct defines the AuditLogService that contains a repository field of type AuditLogRepository
+// It implements the AuditLogService interface and provides methods for audit log-related operations
+type auditLogService struct {
+	repo repository.AuditLogRepository
+}
+
+// NewAuditLogService creates a new instance of AuditLogService with the given repository.
+// It initializes the auditLogService struct and returns it.
+func NewAuditLogService(repo repository.AuditLogRepository) AuditLogService {
+	return &auditLogService{repo: repo}
+}
+
+// GetAuditLogs retrieves the audit log entries of the tenant of the context matching the filter,
+// along with their total number, the most recent first.
+func (s *auditLogService) GetAuditLogs(ctx context.Context, filter entity.AuditLogFilter) ([]entity.AuditLog, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	db = db.WithContext(ctx)
+
+	// Retrieve the audit log entries from the repository
+	auditLogs, err := s.repo.GetAuditLogs(db, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Count the audit log entries for the pagination metadata
+	total, err := s.repo.CountAuditLogs(db, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return auditLogs, total, nil
+}
@@ -1,19 +1,28 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 
 	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/hash"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
 	jwtutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/jwt-util"
 )
 
@@ -28,10 +37,38 @@ var (
 	AccessTokenTTL    time.Duration
 )
 
+// ErrPasswordChangeRequired is returned at login when the credentials of the user are expired,
+// e.g. an admin forced a password change. The user cannot log in until the password is changed,
+// by an admin or by the user with the current password, see AuthService.ChangePassword.
+var ErrPasswordChangeRequired = errors.New("password change required, the credentials of the user are expired")
+
+// ErrInvalidCredentials is returned when the password does not match the one of the user.
+// It is answered like an unknown username, so the answer does not tell whether the username exists.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrImpersonationForbidden is returned when the caller may not impersonate the user, see AuthService.Impersonate.
+var ErrImpersonationForbidden = errors.New("impersonation forbidden")
+
+// defaultImpersonationTokenTTL is the lifetime of the impersonation tokens when IMPERSONATION_TOKEN_TTL_MINUTES is not set.
+const defaultImpersonationTokenTTL = 15 * time.Minute
+
+// dummyPasswordHash is the bcrypt hash, at the default cost, of a password no user has.
+// The password of a login for an unknown username is compared with it, see compareDummyPassword.
+const dummyPasswordHash = "$2a$10$q7JUTbeTY9alF8gbZiW5Z.jhNfQ55BUv1gJMkiBuWg5aXICLtFHGO"
+
+// dummyHash holds the hash of the dummy password by the current hasher, computed again when the hashing settings change.
+var dummyHash = struct {
+	mu   sync.Mutex
+	hash string
+}{hash: dummyPasswordHash}
+
+// jwtSecretMu guards JWTSecret, which is replaced when the secret provider rotates it
+var jwtSecretMu sync.RWMutex
+
 // LoadEnv loads environment variables
 func LoadEnv() {
 	once.Do(func() {
-		JWTSecret = os.Getenv("JWT_SECRET")
+		SetJWTSecret(os.Getenv("JWT_SECRET"))
 		TokenType = os.Getenv("TOKEN_TYPE")
 		SigningMethod = os.Getenv("JWT_ALGORITHM")
 		JWTAudience = os.Getenv("JWT_AUDIENCE")
@@ -44,21 +81,43 @@ func LoadEnv() {
 	})
 }
 
+// SetJWTSecret replaces the HS256 secret, e.g. rotated by the secret provider.
+// The next tokens are signed with the new secret, the tokens signed with the previous one no longer verify.
+func SetJWTSecret(secret string) {
+	jwtSecretMu.Lock()
+	defer jwtSecretMu.Unlock()
+
+	JWTSecret = secret
+}
+
+// getJWTSecret returns the current HS256 secret.
+func getJWTSecret() string {
+	jwtSecretMu.RLock()
+	defer jwtSecretMu.RUnlock()
+
+	return JWTSecret
+}
+
 // Interface for auth service
 // This interface defines the methods that the auth service should implement
 type AuthService interface {
 	Login(loginReq entity.LoginRequest) (entity.LoginResponse, error)
 	RefreshToken(refreshTokenReq entity.RefreshTokenRequest) (entity.RefreshTokenResponse, error)
+	ReactivateAccount(loginReq entity.LoginRequest) (entity.LoginResponse, error)
+	ChangePassword(req entity.ChangePasswordRequest) error
+	Impersonate(ctx context.Context, userID int64) (entity.ImpersonationResponse, error)
 }
 
 // This struct defines the AuthService that contains a user repository and a role repository
 // It implements the AuthService interface and provides methods for authentication-related operations
-type authService struct{}
+type authService struct {
+	clock clock.Clock
+}
 
-// NewAuthService creates a new instance of AuthService with the given user and role repositories.
-// It initializes the authService struct and returns it.
-func NewAuthService() AuthService {
-	return &authService{}
+// NewAuthService creates a new instance of AuthService.
+// The access and refresh tokens are issued and checked at the time of the given clock.
+func NewAuthService(clk clock.Clock) AuthService {
+	return &authService{clock: clk}
 }
 
 // Login authenticates a user with the given username and password.
@@ -68,9 +127,9 @@ func (s *authService) Login(loginReq entity.LoginRequest) (entity.LoginResponse,
 	LoadEnv()
 
 	// Get the database connection from the context
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.LoginResponse{}, fmt.Errorf("database connection is nil")
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return entity.LoginResponse{}, err
 	}
 
 	// Validate the authentication parameters using the validation
@@ -81,20 +140,38 @@ func (s *authService) Login(loginReq entity.LoginRequest) (entity.LoginResponse,
 	var tokenStr string
 	var refreshTokenStr string
 	var expirationDateStr string
-	err := db.Transaction(func(tx *gorm.DB) error {
-		// Check if the user exists
+	var profile *entity.UserProfile
+	var loggedInUser entity.User
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Check if the user exists, the username is unique in its tenant only
+		tenantID := metacontext.DefaultTenantID
+		if loginReq.TenantID != nil {
+			tenantID = *loginReq.TenantID
+		}
 		userRepo := repository.NewUserRepository()
 		userService := NewUserService(userRepo)
-		existingUser, err := userService.GetUserByUsername(loginReq.Username)
+		existingUser, err := userRepo.GetUserByUsername(tx.WithContext(metacontext.InjectTenantID(context.Background(), tenantID)), loginReq.Username)
 		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				compareDummyPassword(loginReq.Password)
+			}
 			return err
 		}
 
 		// Check some conditions for the user
 		if existingUser.Equals(&entity.User{}) {
+			compareDummyPassword(loginReq.Password)
 			return fmt.Errorf("user with username %s not found", loginReq.Username)
 		}
+
+		// Compare the provided password with the stored hashed password once, before the state of the account is checked,
+		// so every refusal of an existing user takes a password comparison like a wrong password does
+		passwordMatches := checkPassword(existingUser.Password, loginReq.Password)
+
 		if !*existingUser.IsEnabled {
+			if err := checkDeactivatedAccount(tx, existingUser, passwordMatches); err != nil {
+				return err
+			}
 			return fmt.Errorf("user with username %s is not enabled", loginReq.Username)
 		}
 		if !*existingUser.IsAccountNonExpired {
@@ -103,26 +180,29 @@ func (s *authService) Login(loginReq entity.LoginRequest) (entity.LoginResponse,
 		if !*existingUser.IsAccountNonLocked {
 			return fmt.Errorf("user account is locked")
 		}
-		if !*existingUser.IsCredentialsNonExpired {
-			return fmt.Errorf("user credentials are expired")
-		}
 		if *existingUser.IsDeleted {
 			return fmt.Errorf("user with username %s is deleted", loginReq.Username)
 		}
 
-		// Compare the provided password with the stored hashed password
-		if err := bcrypt.CompareHashAndPassword([]byte(existingUser.Password), []byte(loginReq.Password)); err != nil {
-			return fmt.Errorf("invalid credentials for user %s", loginReq.Username)
+		if !passwordMatches {
+			return fmt.Errorf("%w for user %s", ErrInvalidCredentials, loginReq.Username)
+		}
+
+		// The expired credentials are only reported to the caller knowing the password
+		if !*existingUser.IsCredentialsNonExpired {
+			return ErrPasswordChangeRequired
 		}
 
-		// Generate an access token for the user
-		tokenStr, err = GenerateJWTToken(existingUser)
+		// Generate an access token for the user, along with its permissions and its groups
+		loadTokenPermissions(tx, &existingUser)
+		loadTokenGroups(tx, &existingUser)
+		tokenStr, err = GenerateJWTToken(existingUser, s.clock.Now())
 		if err != nil {
 			return fmt.Errorf("failed to generate JWT token: %w", err)
 		}
 
 		// Parse the JWT token
-		jwtToken, err := ParseJWTToken(tokenStr)
+		jwtToken, err := ParseJWTToken(tokenStr, jwt.WithTimeFunc(s.clock.Now))
 		if err != nil {
 			return fmt.Errorf("failed to parse JWT token: %w", err)
 		}
@@ -135,8 +215,8 @@ func (s *authService) Login(loginReq entity.LoginRequest) (entity.LoginResponse,
 
 		// Generate a refresh token for the user
 		refreshTokenRepo := repository.NewRefreshTokenRepository()
-		refreshTokenService := NewRefreshTokenService(refreshTokenRepo)
-		jwtRefreshToken, err := refreshTokenService.CreateRefreshToken(existingUser.ID)
+		refreshTokenService := NewRefreshTokenService(refreshTokenRepo, s.clock)
+		jwtRefreshToken, err := refreshTokenService.CreateRefreshToken(existingUser.ID, loginReq.IPAddress, loginReq.UserAgent)
 		if err != nil {
 			return fmt.Errorf("failed to create refresh token: %w", err)
 		}
@@ -146,12 +226,19 @@ func (s *authService) Login(loginReq entity.LoginRequest) (entity.LoginResponse,
 
 		refreshTokenStr = jwtRefreshToken.Token
 
+		// Return the profile along with the tokens, so the client does not need to request it
+		if IsLoginProfileEnabled() {
+			userProfile := existingUser.ToProfile()
+			profile = &userProfile
+		}
+
 		// Update the last login time for the user
-		_, err = userService.UpdateLastLogin(existingUser.ID, time.Now())
+		_, err = userService.UpdateLastLogin(existingUser.ID, s.clock.Now().UTC())
 		if err != nil {
 			return fmt.Errorf("failed to update last login time: %w", err)
 		}
 
+		loggedInUser = existingUser
 		return nil
 	})
 
@@ -159,14 +246,288 @@ func (s *authService) Login(loginReq entity.LoginRequest) (entity.LoginResponse,
 		return entity.LoginResponse{}, err
 	}
 
+	// Upgrade the hash of the password to the current algorithm and parameters, now that the password is known
+	rehashPassword(db, loggedInUser, loginReq.Password)
+
 	return entity.LoginResponse{
 		AccessToken:    tokenStr,
 		RefreshToken:   refreshTokenStr,
 		ExpirationDate: expirationDateStr,
 		TokenType:      TokenType,
+		User:           profile,
+	}, nil
+}
+
+// ReactivateAccount reactivates the account the user deactivated, given its credentials, and logs the user in.
+// The account must still be within its grace period, see AccountService.ReactivateAccount.
+func (s *authService) ReactivateAccount(loginReq entity.LoginRequest) (entity.LoginResponse, error) {
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return entity.LoginResponse{}, err
+	}
+
+	// Validate the authentication parameters using the validation
+	if err := loginReq.Validate(); err != nil {
+		return entity.LoginResponse{}, err
+	}
+
+	// Check the credentials, the account is disabled so the login cannot check them
+	tenantID := metacontext.DefaultTenantID
+	if loginReq.TenantID != nil {
+		tenantID = *loginReq.TenantID
+	}
+	ctx := metacontext.InjectTenantID(context.Background(), tenantID)
+	existingUser, err := repository.NewUserRepository().GetUserByUsername(db.WithContext(ctx), loginReq.Username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			compareDummyPassword(loginReq.Password)
+		}
+		return entity.LoginResponse{}, err
+	}
+	if !checkPassword(existingUser.Password, loginReq.Password) {
+		return entity.LoginResponse{}, fmt.Errorf("%w for user %s", ErrInvalidCredentials, loginReq.Username)
+	}
+
+	// The user reactivates their own account
+	ctx = metacontext.InjectUserInformationMeta(ctx, metacontext.UserInformationMeta{
+		UserID:   existingUser.ID,
+		Username: existingUser.Username,
+		Email:    existingUser.Email,
+		TenantID: existingUser.TenantID,
+	})
+	if _, err := NewAccountService(repository.NewScheduledDeletionRepository(), s.clock).ReactivateAccount(ctx, existingUser.ID); err != nil {
+		return entity.LoginResponse{}, err
+	}
+
+	return s.Login(loginReq)
+}
+
+// ChangePassword replaces the password of a user given the current one. It does not need a session,
+// so a user whose credentials are expired, e.g. by an admin after an incident, can change the password and log in again.
+// Like a reset by an admin, the new password lifts the expiration and revokes the sessions, see UserService.ResetUserPassword.
+func (s *authService) ChangePassword(req entity.ChangePasswordRequest) error {
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return err
+	}
+
+	// Validate the request parameters using the validation
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	// Check the current password, the account states are only told to the caller knowing it
+	tenantID := metacontext.DefaultTenantID
+	if req.TenantID != nil {
+		tenantID = *req.TenantID
+	}
+	ctx := metacontext.InjectTenantID(context.Background(), tenantID)
+	existingUser, err := repository.NewUserRepository().GetUserByUsername(db.WithContext(ctx), req.Username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			compareDummyPassword(req.OldPassword)
+		}
+		return err
+	}
+	if !checkPassword(existingUser.Password, req.OldPassword) {
+		return fmt.Errorf("%w for user %s", ErrInvalidCredentials, req.Username)
+	}
+
+	// The accounts the login refuses for another reason than the expired credentials stay refused
+	if !*existingUser.IsEnabled {
+		return fmt.Errorf("user with username %s is not enabled", req.Username)
+	}
+	if !*existingUser.IsAccountNonExpired {
+		return fmt.Errorf("user account is expired")
+	}
+	if !*existingUser.IsAccountNonLocked {
+		return fmt.Errorf("user account is locked")
+	}
+
+	// The user changes their own password
+	ctx = metacontext.InjectUserInformationMeta(ctx, metacontext.UserInformationMeta{
+		UserID:   existingUser.ID,
+		Username: existingUser.Username,
+		Email:    existingUser.Email,
+		TenantID: existingUser.TenantID,
+	})
+	_, err = NewUserService(repository.NewUserRepository()).ResetUserPassword(ctx, existingUser.ID, req.NewPassword)
+	return err
+}
+
+// compareDummyPassword compares the password with the dummy hash, when the username of a login is unknown.
+// The login of an unknown user then takes as long as the one of a known user with a wrong password,
+// so its response time does not tell which usernames exist. It does nothing if the constant-time login is disabled.
+// The dummy hash follows the hashing settings, like the hashes of the users upgraded at their login.
+func compareDummyPassword(password string) {
+	if !IsConstantTimeLoginEnabled() {
+		return
+	}
+
+	hasher := hash.FromEnv()
+	dummyHash.mu.Lock()
+	if hasher.NeedsRehash(dummyHash.hash) {
+		if rehashed, err := hasher.Hash(dummyPasswordHash); err == nil {
+			dummyHash.hash = rehashed
+		}
+	}
+	dummy := dummyHash.hash
+	dummyHash.mu.Unlock()
+
+	_ = checkPassword(dummy, password)
+}
+
+// checkPassword reports whether the password matches its stored hash, whatever the algorithm of the hash.
+func checkPassword(hashed string, password string) bool {
+	ok, err := hash.Verify(hashed, password)
+	return err == nil && ok
+}
+
+// rehashPassword replaces the stored hash of the password of the user, once logged in, if it is not a hash
+// by the current algorithm and parameters. A failure is only logged, the login succeeded and the next one tries again.
+func rehashPassword(db *gorm.DB, user entity.User, password string) {
+	hasher := hash.FromEnv()
+	if !hasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	rehashed, err := hasher.Hash(password)
+	if err == nil {
+		err = repository.NewUserRepository().UpdatePasswordHash(db, user.ID, rehashed)
+	}
+	if err != nil {
+		logger.Warn(fmt.Sprintf("Failed to rehash the password of user %d: %v", user.ID, err), nil)
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Rehashed the password of user %d with %s", user.ID, hash.AlgorithmOf(rehashed)), nil)
+}
+
+// Impersonate issues an access token for the user with the given ID to the admin of the context, who then acts as the user,
+// e.g. to reproduce an issue. The token carries the ID of the admin in its impersonator claim, expires after
+// GetImpersonationTokenTTL and comes without a refresh token, so the impersonation cannot be extended.
+// The admins cannot impersonate themselves, the users holding an admin-level role, the users who cannot log in,
+// nor impersonate again with an impersonation token (ErrImpersonationForbidden). The impersonation is recorded in the audit log.
+func (s *authService) Impersonate(ctx context.Context, userID int64) (entity.ImpersonationResponse, error) {
+	// Load environment variables
+	LoadEnv()
+
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.ImpersonationResponse{}, err
+	}
+
+	// Get the admin impersonating the user from the context
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.ImpersonationResponse{}, fmt.Errorf("missing user context")
+	}
+	if meta.ImpersonatorID != nil {
+		return entity.ImpersonationResponse{}, fmt.Errorf("%w: an impersonation token cannot impersonate another user", ErrImpersonationForbidden)
+	}
+	if meta.UserID == userID {
+		return entity.ImpersonationResponse{}, fmt.Errorf("%w: the admins cannot impersonate themselves", ErrImpersonationForbidden)
+	}
+
+	var tokenStr string
+	var expirationDateStr string
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Check if the user exists in the tenant of the request
+		user, err := repository.NewUserRepository().GetUserByID(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		// The admin-level users are not impersonated, the impersonation would grant their roles to the admin
+		if slices.ContainsFunc(user.Roles, func(role entity.Role) bool {
+			return role.Name == adminRole || role.Name == superAdminRole
+		}) {
+			return fmt.Errorf("%w: user %d holds an admin-level role", ErrImpersonationForbidden, userID)
+		}
+		if !*user.IsEnabled || !*user.IsAccountNonExpired || !*user.IsAccountNonLocked || *user.IsDeleted {
+			return fmt.Errorf("%w: user %d cannot log in", ErrImpersonationForbidden, userID)
+		}
+
+		// Generate an access token for the user, along with its permissions, its groups and its impersonator
+		loadTokenPermissions(tx, &user)
+		loadTokenGroups(tx, &user)
+		impersonatorID := meta.UserID
+		user.ImpersonatorID = &impersonatorID
+		tokenStr, err = GenerateJWTToken(user, s.clock.Now())
+		if err != nil {
+			return fmt.Errorf("failed to generate JWT token: %w", err)
+		}
+
+		// Parse the JWT token
+		jwtToken, err := ParseJWTToken(tokenStr, jwt.WithTimeFunc(s.clock.Now))
+		if err != nil {
+			return fmt.Errorf("failed to parse JWT token: %w", err)
+		}
+
+		// Get the expiration date from the token
+		expirationDateStr, err = GetExpirationDateFromToken(jwtToken)
+		if err != nil {
+			return fmt.Errorf("failed to get expiration date from token: %w", err)
+		}
+
+		// Record the token in the audit log, its ID tells the requests made with it in the logs
+		claims, _ := jwtToken.Claims.(jwt.MapClaims)
+		return recordAccountAudit(tx, meta, user, "impersonate",
+			fmt.Sprintf("Impersonation token %s issued, expiring at %s", jwtutil.GetStringClaim(claims, "jti"), expirationDateStr))
+	})
+
+	if err != nil {
+		return entity.ImpersonationResponse{}, err
+	}
+
+	logger.Info(fmt.Sprintf("User %d impersonated by %s", userID, meta.Actor()), logrus.Fields{
+		"userID":       userID,
+		"impersonator": meta.UserID,
+		"actor":        meta.Actor(),
+		"tokenID":      meta.TokenID,
+	})
+
+	return entity.ImpersonationResponse{
+		AccessToken:    tokenStr,
+		ExpirationDate: expirationDateStr,
+		TokenType:      TokenType,
+		ImpersonatorID: meta.UserID,
 	}, nil
 }
 
+// GetImpersonationTokenTTL returns the lifetime of the impersonation tokens.
+// It retrieves the number of minutes from an environment variable, 15 minutes if it is not a positive number.
+// The impersonation tokens never outlive the access tokens issued at login.
+func GetImpersonationTokenTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("IMPERSONATION_TOKEN_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		return defaultImpersonationTokenTTL
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// getTokenExpiration returns the expiration of an access token of the user issued at the given Unix time.
+// The impersonation tokens expire after GetImpersonationTokenTTL, the other ones after GetJWTExpiration.
+func getTokenExpiration(user entity.User, now int64) int64 {
+	exp := GetJWTExpiration(now)
+	if user.ImpersonatorID != nil {
+		exp = min(exp, now+int64(GetImpersonationTokenTTL()/time.Second))
+	}
+	return exp
+}
+
+// IsConstantTimeLoginEnabled reports whether the logins of the unknown users compare the password with a dummy hash.
+// It retrieves the toggle from an environment variable, the comparison is only skipped if it is FALSE.
+func IsConstantTimeLoginEnabled() bool {
+	return strings.ToUpper(os.Getenv("LOGIN_CONSTANT_TIME")) != "FALSE"
+}
+
+// IsLoginProfileEnabled reports whether the login response includes the profile of the user.
+// It retrieves the toggle from an environment variable, the profile is only omitted if it is FALSE.
+func IsLoginProfileEnabled() bool {
+	return strings.ToUpper(os.Getenv("LOGIN_RESPONSE_INCLUDE_PROFILE")) != "FALSE"
+}
+
 // RefreshToken refreshes the access token using the provided refresh token.
 // It retrieves the new access token and refresh token for the user.
 func (s *authService) RefreshToken(refreshTokenReq entity.RefreshTokenRequest) (entity.RefreshTokenResponse, error) {
@@ -174,9 +535,9 @@ func (s *authService) RefreshToken(refreshTokenReq entity.RefreshTokenRequest) (
 	LoadEnv()
 
 	// Get the database connection from the context
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.RefreshTokenResponse{}, fmt.Errorf("database connection is nil")
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return entity.RefreshTokenResponse{}, err
 	}
 
 	// Validate the refresh token request
@@ -187,10 +548,10 @@ func (s *authService) RefreshToken(refreshTokenReq entity.RefreshTokenRequest) (
 	var accessTokenStr string
 	var refreshTokenStr string
 	var expirationDateStr string
-	err := db.Transaction(func(tx *gorm.DB) error {
+	err = db.Transaction(func(tx *gorm.DB) error {
 		// Check if the refresh token exists
 		refreshTokenRepo := repository.NewRefreshTokenRepository()
-		refreshTokenService := NewRefreshTokenService(refreshTokenRepo)
+		refreshTokenService := NewRefreshTokenService(refreshTokenRepo, s.clock)
 		existingRefreshToken, err := refreshTokenService.GetRefreshTokenByToken(refreshTokenReq.RefreshToken)
 		if err != nil {
 			return err
@@ -216,14 +577,30 @@ func (s *authService) RefreshToken(refreshTokenReq entity.RefreshTokenRequest) (
 			return fmt.Errorf("user with ID %d not found", existingRefreshToken.UserID)
 		}
 
-		// Generate an access token for the user
-		accessTokenStr, err = GenerateJWTToken(userDetails)
+		// Check the same conditions as the login, the sessions may be kept when the account changes
+		if !*userDetails.IsEnabled {
+			return fmt.Errorf("user with username %s is not enabled", userDetails.Username)
+		}
+		if !*userDetails.IsAccountNonExpired {
+			return fmt.Errorf("user account is expired")
+		}
+		if !*userDetails.IsAccountNonLocked {
+			return fmt.Errorf("user account is locked")
+		}
+		if !*userDetails.IsCredentialsNonExpired {
+			return ErrPasswordChangeRequired
+		}
+
+		// Generate an access token for the user, along with its permissions and its groups
+		loadTokenPermissions(tx, &userDetails)
+		loadTokenGroups(tx, &userDetails)
+		accessTokenStr, err = GenerateJWTToken(userDetails, s.clock.Now())
 		if err != nil {
 			return fmt.Errorf("failed to generate JWT token: %w", err)
 		}
 
 		// Parse the JWT token
-		jwtToken, err := ParseJWTToken(accessTokenStr)
+		jwtToken, err := ParseJWTToken(accessTokenStr, jwt.WithTimeFunc(s.clock.Now))
 		if err != nil {
 			return fmt.Errorf("failed to parse JWT token: %w", err)
 		}
@@ -234,8 +611,8 @@ func (s *authService) RefreshToken(refreshTokenReq entity.RefreshTokenRequest) (
 			return fmt.Errorf("failed to get expiration date from token: %w", err)
 		}
 
-		// Regenerate a refresh token for the user
-		jwtRefreshToken, err := refreshTokenService.CreateRefreshToken(userDetails.ID)
+		// Rotate the refresh token of the session
+		jwtRefreshToken, err := refreshTokenService.RotateRefreshToken(existingRefreshToken.Token)
 		if err != nil {
 			return fmt.Errorf("failed to create refresh token: %w", err)
 		}
@@ -246,7 +623,7 @@ func (s *authService) RefreshToken(refreshTokenReq entity.RefreshTokenRequest) (
 		refreshTokenStr = jwtRefreshToken.Token
 
 		// Update the last login time for the user
-		_, err = userService.UpdateLastLogin(userDetails.ID, time.Now())
+		_, err = userService.UpdateLastLogin(userDetails.ID, s.clock.Now().UTC())
 		if err != nil {
 			return fmt.Errorf("failed to update last login time: %w", err)
 		}
@@ -266,17 +643,57 @@ func (s *authService) RefreshToken(refreshTokenReq entity.RefreshTokenRequest) (
 	}, nil
 }
 
+// loadTokenPermissions loads the effective permissions of the user to embed in its access token, unless the embedding
+// is disabled, see IsPermissionEmbeddingEnabled. A failure is only logged: the token is issued without the permissions,
+// which are then looked up by authorization.RequirePermission.
+func loadTokenPermissions(tx *gorm.DB, user *entity.User) {
+	if !IsPermissionEmbeddingEnabled() {
+		return
+	}
+
+	permissions, err := repository.NewPermissionRepository().GetPermissionsByUserID(tx, user.ID)
+	if err != nil {
+		logger.Warn(fmt.Sprintf("Failed to load the permissions of user %d for the token: %v", user.ID, err), nil)
+		return
+	}
+
+	user.Permissions = make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		user.Permissions = append(user.Permissions, permission.Name)
+	}
+}
+
+// loadTokenGroups loads the IDs of the groups of the user to embed in its access token, so the downstream services
+// authorize by group without a lookup. A failure is only logged: the token is issued without the groups.
+// The changes of the memberships are carried by the tokens issued afterwards, at the next login or refresh.
+func loadTokenGroups(tx *gorm.DB, user *entity.User) {
+	groupIDs, err := repository.NewGroupRepository().GetGroupIDsByUserID(tx, user.ID)
+	if err != nil {
+		logger.Warn(fmt.Sprintf("Failed to load the groups of user %d for the token: %v", user.ID, err), nil)
+		return
+	}
+
+	user.GroupIDs = groupIDs
+}
+
+// IsPermissionEmbeddingEnabled reports whether the effective permissions of the users are embedded in their access tokens.
+// It retrieves the toggle from an environment variable, the permissions are looked up on every check if it is FALSE.
+func IsPermissionEmbeddingEnabled() bool {
+	return strings.ToUpper(os.Getenv("JWT_EMBED_PERMISSIONS")) != "FALSE"
+}
+
 // GenerateJWTToken determines the function to use for generating a JWT token based on the signing method.
 // It checks the signing method from the environment variable and calls the appropriate function.
-func GenerateJWTToken(user entity.User) (string, error) {
+// The token is issued at the given time, it expires after the configured number of hours.
+func GenerateJWTToken(user entity.User, issuedAt time.Time) (string, error) {
 	// Load environment variables
 	// LoadEnv()
 
 	// Check the signing method from the environment variable
 	if SigningMethod == jwt.SigningMethodHS256.Alg() {
-		return GenerateJWTTokenWithHS256(user)
+		return GenerateJWTTokenWithHS256(user, issuedAt)
 	} else if SigningMethod == jwt.SigningMethodRS256.Alg() {
-		return GenerateJWTTokenWithRS256(user)
+		return GenerateJWTTokenWithRS256(user, issuedAt)
 	}
 
 	return "", fmt.Errorf("unsupported signing method: %s", SigningMethod)
@@ -284,13 +701,13 @@ func GenerateJWTToken(user entity.User) (string, error) {
 
 // GenerateJWTTokenWithHS256 generates a JWT token using the HS256 signing method.
 // It creates the claims for the token and signs it with the secret key from the environment variable.
-func GenerateJWTTokenWithHS256(user entity.User) (string, error) {
+func GenerateJWTTokenWithHS256(user entity.User, issuedAt time.Time) (string, error) {
 	// Load environment variables
 	// LoadEnv()
 
 	// Set the now time
 	// This is used to set the issued at (iat) and expiration (exp) claims
-	now := time.Now().Unix()
+	now := issuedAt.Unix()
 
 	// Create the claims for the JWT token
 	claims := jwt.MapClaims{
@@ -298,32 +715,48 @@ func GenerateJWTTokenWithHS256(user entity.User) (string, error) {
 		"aud":      JWTAudience,
 		"iss":      JWTIssuer,
 		"iat":      now,
-		"exp":      GetJWTExpiration(now),
+		"exp":      getTokenExpiration(user, now),
 		"email":    user.Email,
 		"userid":   user.ID,
+		"tenantid": user.TenantID,
+		"jti":      uuid.NewString(),
 		"username": user.Username,
 		"roles":    ExtractRoleNames(user.Roles),
+		"rolesexp": ExtractRoleExpiries(user.Roles),
+	}
+	if user.Permissions != nil {
+		claims["permissions"] = user.Permissions
+	}
+	if user.GroupIDs != nil {
+		claims["groups"] = user.GroupIDs
+	}
+	if user.ImpersonatorID != nil {
+		claims["impersonator"] = *user.ImpersonatorID
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(JWTSecret))
+	// Sign with the current key, stamping its ID
+	keys, err := jwtutil.LoadKeySet(jwt.SigningMethodHS256.Alg(), getJWTSecret())
+	if err != nil {
+		return "", err
+	}
+	return keys.Sign(claims)
 }
 
 // GenerateJWTTokenWithRS256 generates a JWT token using the RS256 signing method.
 // It creates the claims for the token and signs it with the private key loaded from the file.
-func GenerateJWTTokenWithRS256(user entity.User) (string, error) {
+func GenerateJWTTokenWithRS256(user entity.User, issuedAt time.Time) (string, error) {
 	// Load environment variables
 	// LoadEnv()
 
-	// Load the private key from the file
-	privateKey, err := jwtutil.LoadPrivateKey()
+	// Load the key pairs from the files
+	keys, err := jwtutil.LoadKeySet(jwt.SigningMethodRS256.Alg(), "")
 	if err != nil {
 		return "", err
 	}
 
 	// Set the now time
 	// This is used to set the issued at (iat) and expiration (exp) claims
-	now := time.Now().Unix()
+	now := issuedAt.Unix()
 
 	// Create the claims for the JWT token
 	claims := jwt.MapClaims{
@@ -331,28 +764,41 @@ func GenerateJWTTokenWithRS256(user entity.User) (string, error) {
 		"aud":      JWTAudience,
 		"iss":      JWTIssuer,
 		"iat":      now,
-		"exp":      GetJWTExpiration(now),
+		"exp":      getTokenExpiration(user, now),
 		"email":    user.Email,
 		"userid":   user.ID,
+		"tenantid": user.TenantID,
+		"jti":      uuid.NewString(),
 		"username": user.Username,
 		"roles":    ExtractRoleNames(user.Roles),
+		"rolesexp": ExtractRoleExpiries(user.Roles),
+	}
+	if user.Permissions != nil {
+		claims["permissions"] = user.Permissions
+	}
+	if user.GroupIDs != nil {
+		claims["groups"] = user.GroupIDs
+	}
+	if user.ImpersonatorID != nil {
+		claims["impersonator"] = *user.ImpersonatorID
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	return token.SignedString(privateKey)
+	// Sign with the current key, stamping its ID
+	return keys.Sign(claims)
 }
 
 // ParseJWTToken determines the function to use for parsing a JWT token based on the signing method.
 // It checks the signing method from the environment variable and calls the appropriate function.
-func ParseJWTToken(tokenStr string) (*jwt.Token, error) {
+// The options are passed to the parser, e.g. jwt.WithTimeFunc to check the expiry at the time of a clock.
+func ParseJWTToken(tokenStr string, opts ...jwt.ParserOption) (*jwt.Token, error) {
 	// Load environment variables
 	// LoadEnv()
 
 	// Check the signing method from the environment variable
 	if SigningMethod == jwt.SigningMethodHS256.Alg() {
-		return ParseJWTTokenWithHS256(tokenStr)
+		return ParseJWTTokenWithHS256(tokenStr, opts...)
 	} else if SigningMethod == jwt.SigningMethodRS256.Alg() {
-		return ParseJWTTokenWithRS256(tokenStr)
+		return ParseJWTTokenWithRS256(tokenStr, opts...)
 	}
 
 	return nil, fmt.Errorf("unsupported signing method: %s", SigningMethod)
@@ -360,16 +806,17 @@ func ParseJWTToken(tokenStr string) (*jwt.Token, error) {
 
 // ParseJWTTokenWithHS256 parses a JWT token using the HS256 signing method.
 // It validates the token and returns the parsed token object.
-func ParseJWTTokenWithHS256(tokenStr string) (*jwt.Token, error) {
+func ParseJWTTokenWithHS256(tokenStr string, opts ...jwt.ParserOption) (*jwt.Token, error) {
 	// Load environment variables
 	// LoadEnv()
 
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(JWTSecret), nil
-	})
+	keys, err := jwtutil.LoadKeySet(jwt.SigningMethodHS256.Alg(), getJWTSecret())
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify with the key matching the kid of the token
+	token, err := keys.Parse(tokenStr, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse JWT token: %v", err)
 	}
@@ -378,19 +825,15 @@ func ParseJWTTokenWithHS256(tokenStr string) (*jwt.Token, error) {
 
 // ParseJWTTokenWithRS256 parses a JWT token using the RS256 signing method.
 // It validates the token and returns the parsed token object.
-func ParseJWTTokenWithRS256(tokenStr string) (*jwt.Token, error) {
-	// Load the public key from the file
-	publicKey, err := jwtutil.LoadPublicKey()
+func ParseJWTTokenWithRS256(tokenStr string, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	// Load the key pairs from the files
+	keys, err := jwtutil.LoadKeySet(jwt.SigningMethodRS256.Alg(), "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to load public key: %v", err)
+		return nil, fmt.Errorf("failed to load the keys: %v", err)
 	}
 
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return publicKey, nil
-	})
+	// Verify with the key matching the kid of the token
+	token, err := keys.Parse(tokenStr, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse JWT token: %v", err)
 	}
@@ -422,6 +865,18 @@ func ExtractRoleNames(roles []entity.Role) []string {
 	return names
 }
 
+// ExtractRoleExpiries extracts the expiry of the time-limited roles from a slice of roles, as Unix timestamps by role name.
+// The token keeps a role only until its expiry, so the permissions are re-evaluated without issuing a new token.
+func ExtractRoleExpiries(roles []entity.Role) map[string]int64 {
+	expiries := make(map[string]int64)
+	for _, r := range roles {
+		if r.ExpiresAt != nil {
+			expiries[r.Name] = r.ExpiresAt.Unix()
+		}
+	}
+	return expiries
+}
+
 // GetExpirationDateFromToken extracts the expiration date from the JWT token claims.
 func GetExpirationDateFromToken(token *jwt.Token) (string, error) {
 	claims, ok := token.Claims.(jwt.MapClaims)
@@ -434,6 +889,6 @@ func GetExpirationDateFromToken(token *jwt.Token) (string, error) {
 		return "", fmt.Errorf("exp claim not found or not a float64")
 	}
 
-	expirationDate := time.Unix(int64(expFloat), 0).Format(time.RFC3339)
+	expirationDate := time.Unix(int64(expFloat), 0).UTC().Format(time.RFC3339)
 	return expirationDate, nil
 }
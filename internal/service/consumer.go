@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"regexp"
@@ -13,16 +14,19 @@ import (
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
 )
 
+// ErrConsumerAlreadyExists is returned when the username, the email or the phone is already taken by another consumer.
+var ErrConsumerAlreadyExists = errors.New("consumer already exists")
+
 // Interface for consumer service
 // This interface defines the methods that the consumer service should implement
 type ConsumerService interface {
-	GetAllConsumers(page int, limit int) ([]entity.Consumer, error)
-	GetConsumerByID(id string) (entity.Consumer, error)
-	GetActiveConsumers(page int, limit int) ([]entity.Consumer, error)
-	GetInactiveConsumers(page int, limit int) ([]entity.Consumer, error)
-	GetSuspendedConsumers(page int, limit int) ([]entity.Consumer, error)
-	CreateConsumer(c entity.Consumer) (entity.Consumer, error)
-	UpdateConsumerStatus(id string, status string) (entity.Consumer, error)
+	GetAllConsumers(ctx context.Context, page int, limit int) ([]entity.Consumer, int64, error)
+	GetConsumerByID(ctx context.Context, id string) (entity.Consumer, error)
+	GetActiveConsumers(ctx context.Context, page int, limit int) ([]entity.Consumer, int64, error)
+	GetInactiveConsumers(ctx context.Context, page int, limit int) ([]entity.Consumer, int64, error)
+	GetSuspendedConsumers(ctx context.Context, page int, limit int) ([]entity.Consumer, int64, error)
+	CreateConsumer(ctx context.Context, c entity.Consumer) (entity.Consumer, error)
+	UpdateConsumerStatus(ctx context.Context, id string, status string) (entity.Consumer, error)
 }
 
 // This struct defines the ConsumerService that contains a repository field of type ConsumerRepository
@@ -37,29 +41,41 @@ func NewConsumerService(repo repository.ConsumerRepository) ConsumerService {
 	return &consumerService{repo: repo}
 }
 
-// GetAllConsumers retrieves all consumers from the database.
-func (s *consumerService) GetAllConsumers(page int, limit int) ([]entity.Consumer, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return nil, fmt.Errorf("database connection is nil")
+// GetAllConsumers retrieves all consumers from the database along with the total number of consumers.
+func (s *consumerService) GetAllConsumers(ctx context.Context, page int, limit int) ([]entity.Consumer, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
 	}
 
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
 	// Retrieve all consumers from the repository
 	consumers, err := s.repo.GetAllConsumers(db, page, limit)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	// Count all consumers for the pagination metadata
+	total, err := s.repo.CountConsumers(db)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return consumers, nil
+	return consumers, total, nil
 }
 
 // GetConsumerByID retrieves a consumer by its ID from the database.
-func (s *consumerService) GetConsumerByID(id string) (entity.Consumer, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.Consumer{}, fmt.Errorf("database connection is nil")
+func (s *consumerService) GetConsumerByID(ctx context.Context, id string) (entity.Consumer, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.Consumer{}, err
 	}
 
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
 	// Retrieve the consumer by ID from the repository
 	consumer, err := s.repo.GetConsumerByID(db, id)
 	if err != nil {
@@ -69,69 +85,99 @@ func (s *consumerService) GetConsumerByID(id string) (entity.Consumer, error) {
 	return consumer, nil
 }
 
-// GetActiveConsumers retrieves all active consumers from the database.
-func (s *consumerService) GetActiveConsumers(page int, limit int) ([]entity.Consumer, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return nil, fmt.Errorf("database connection is nil")
+// GetActiveConsumers retrieves all active consumers from the database along with their total number.
+func (s *consumerService) GetActiveConsumers(ctx context.Context, page int, limit int) ([]entity.Consumer, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
 	}
 
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
 	// Retrieve all active consumers from the repository
 	activeConsumers, err := s.repo.GetConsumersByStatus(db, entity.ConsumerStatusActive, page, limit)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	// Count all active consumers for the pagination metadata
+	total, err := s.repo.CountConsumersByStatus(db, entity.ConsumerStatusActive)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return activeConsumers, nil
+	return activeConsumers, total, nil
 }
 
-// GetInactiveConsumers retrieves all inactive consumers from the database.
-func (s *consumerService) GetInactiveConsumers(page int, limit int) ([]entity.Consumer, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return nil, fmt.Errorf("database connection is nil")
+// GetInactiveConsumers retrieves all inactive consumers from the database along with their total number.
+func (s *consumerService) GetInactiveConsumers(ctx context.Context, page int, limit int) ([]entity.Consumer, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
 	}
 
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
 	// Retrieve all inactive consumers from the repository
 	inactiveConsumers, err := s.repo.GetConsumersByStatus(db, "inactive", page, limit)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return inactiveConsumers, nil
+	// Count all inactive consumers for the pagination metadata
+	total, err := s.repo.CountConsumersByStatus(db, "inactive")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return inactiveConsumers, total, nil
 }
 
-// GetSuspendedConsumers retrieves all suspended consumers from the database.
-func (s *consumerService) GetSuspendedConsumers(page int, limit int) ([]entity.Consumer, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return nil, fmt.Errorf("database connection is nil")
+// GetSuspendedConsumers retrieves all suspended consumers from the database along with their total number.
+func (s *consumerService) GetSuspendedConsumers(ctx context.Context, page int, limit int) ([]entity.Consumer, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
 	}
 
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
 	// Retrieve all suspended consumers from the repository
 	suspendedConsumers, err := s.repo.GetConsumersByStatus(db, "suspended", page, limit)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	// Count all suspended consumers for the pagination metadata
+	total, err := s.repo.CountConsumersByStatus(db, "suspended")
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return suspendedConsumers, nil
+	return suspendedConsumers, total, nil
 }
 
 // CreateConsumer creates a new consumer in the database.
 // It validates the consumer struct and checks if the ID already exists before creating a new consumer.
-func (s *consumerService) CreateConsumer(c entity.Consumer) (entity.Consumer, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.Consumer{}, fmt.Errorf("database connection is nil")
+func (s *consumerService) CreateConsumer(ctx context.Context, c entity.Consumer) (entity.Consumer, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.Consumer{}, err
 	}
 
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
 	// Validate the consumer struct using the validator
 	if err := c.Validate(); err != nil {
 		return entity.Consumer{}, err
 	}
 
 	createdConsumer := entity.Consumer{}
-	err := db.Transaction(func(tx *gorm.DB) error {
+	err = db.Transaction(func(tx *gorm.DB) error {
 		// Check if the username already exists
 		existingConsumer, err := s.repo.GetConsumerByUsername(db, c.Username)
 		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
@@ -140,7 +186,7 @@ func (s *consumerService) CreateConsumer(c entity.Consumer) (entity.Consumer, er
 
 		// If the consumer already exists, return an error
 		if (err == nil) || !(existingConsumer.Equals(&entity.Consumer{})) {
-			return fmt.Errorf("consumer with username %s already exists", c.Username)
+			return fmt.Errorf("%w: username %s", ErrConsumerAlreadyExists, c.Username)
 		}
 
 		// Check if the email already exists
@@ -151,7 +197,7 @@ func (s *consumerService) CreateConsumer(c entity.Consumer) (entity.Consumer, er
 
 		// If the consumer already exists, return an error
 		if (err == nil) || !(existingConsumer.Equals(&entity.Consumer{})) {
-			return fmt.Errorf("consumer with email %s already exists", c.Email)
+			return fmt.Errorf("%w: email %s", ErrConsumerAlreadyExists, c.Email)
 		}
 
 		// Check if the phone already exists
@@ -164,11 +210,15 @@ func (s *consumerService) CreateConsumer(c entity.Consumer) (entity.Consumer, er
 
 		// If the consumer already exists, return an error
 		if (err == nil) || !(existingConsumer.Equals(&entity.Consumer{})) {
-			return fmt.Errorf("consumer with phone %s already exists", c.Phone)
+			return fmt.Errorf("%w: phone %s", ErrConsumerAlreadyExists, c.Phone)
 		}
 
 		c.Status = "inactive" // Set default status to inactive
 		createdConsumer, err = s.repo.CreateConsumer(tx, c)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			// A concurrent request created the same consumer after the checks above
+			return fmt.Errorf("%w: %v", ErrConsumerAlreadyExists, err)
+		}
 		if err != nil {
 			return err
 		}
@@ -199,14 +249,17 @@ func NormalizePhoneNumber(phone string) string {
 
 // UpdateConsumerStatus updates the status of an existing consumer in the database.
 // It checks if the consumer exists and validates the status before updating it.
-func (s *consumerService) UpdateConsumerStatus(id string, status string) (entity.Consumer, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.Consumer{}, fmt.Errorf("database connection is nil")
+func (s *consumerService) UpdateConsumerStatus(ctx context.Context, id string, status string) (entity.Consumer, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.Consumer{}, err
 	}
 
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
 	updatedConsumer := entity.Consumer{}
-	err := db.Transaction(func(tx *gorm.DB) error {
+	err = db.Transaction(func(tx *gorm.DB) error {
 		// Check if the consumer exists
 		existingConsumer, err := s.repo.GetConsumerByID(db, id)
 		if err != nil {
@@ -0,0 +1,554 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+)
+
+var (
+	// ErrGroupAlreadyExists is returned when a group is created or renamed with the name of another group of the tenant.
+	ErrGroupAlreadyExists = errors.New("group already exists")
+
+	// ErrGroupHasMembers is returned when a group with members is deleted without forcing the removal of its members.
+	ErrGroupHasMembers = errors.New("group has members")
+
+	// ErrGroupMemberAlreadyExists is returned when the user added to a group is already one of its members.
+	ErrGroupMemberAlreadyExists = errors.New("user is already a member of the group")
+
+	// ErrGroupMemberNotFound is returned when the user of a membership is not a member of the group.
+	ErrGroupMemberNotFound = errors.New("user is not a member of the group")
+
+	// ErrGroupUserNotFound is returned when the user added to a group does not exist in the tenant of the group.
+	ErrGroupUserNotFound = errors.New("user not found")
+)
+
+// Interface for group service
+// This interface defines the methods that the group service should implement
+type GroupService interface {
+	GetGroups(ctx context.Context, page int, limit int) ([]entity.Group, int64, error)
+	GetGroupByID(ctx context.Context, id int64) (entity.Group, error)
+	CreateGroup(ctx context.Context, req entity.GroupRequest) (entity.Group, error)
+	UpdateGroup(ctx context.Context, id int64, req entity.GroupRequest) (entity.Group, error)
+	DeleteGroup(ctx context.Context, id int64, force bool) error
+	GetGroupMembers(ctx context.Context, id int64, page int, limit int) ([]entity.GroupMemberResponse, int64, error)
+	AddGroupMember(ctx context.Context, id int64, req entity.GroupMemberRequest) (entity.GroupMemberResponse, error)
+	UpdateGroupMember(ctx context.Context, id int64, userID int64, req entity.GroupMemberRoleRequest) (entity.GroupMemberResponse, error)
+	RemoveGroupMember(ctx context.Context, id int64, userID int64) error
+}
+
+// This struct defines the GroupService that contains a repository field of type GroupRepository
+// It implements the GroupService interface and provides methods for group-related operations
+type groupService struct {
+	repo repository.GroupRepository
+}
+
+// NewGroupService creates a new instance of GroupService with the given repository.
+// It initializes the groupService struct and returns it.
+func NewGroupService(repo repository.GroupRepository) GroupService {
+	return &groupService{repo: repo}
+}
+
+// GetGroups retrieves a page of the groups of the tenant of the context ordered by name, along with their total number.
+func (s *groupService) GetGroups(ctx context.Context, page int, limit int) ([]entity.Group, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
+	groups, err := s.repo.GetGroups(db, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Count the groups over all pages for the pagination metadata
+	total, err := s.repo.CountGroups(db)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return groups, total, nil
+}
+
+// GetGroupByID retrieves a group of the tenant of the context by its ID.
+// It returns gorm.ErrRecordNotFound if the group does not exist.
+func (s *groupService) GetGroupByID(ctx context.Context, id int64) (entity.Group, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.Group{}, err
+	}
+
+	return s.repo.GetGroupByID(db.WithContext(ctx), id)
+}
+
+// CreateGroup creates a group in the tenant of the context, its name must not be taken by another group of the tenant.
+// The creation is recorded in the audit log.
+func (s *groupService) CreateGroup(ctx context.Context, req entity.GroupRequest) (entity.Group, error) {
+	meta, db, err := s.groupContext(ctx)
+	if err != nil {
+		return entity.Group{}, err
+	}
+
+	// The group is created in the tenant of the request, the tenant of the user by default
+	tenantID, ok := metacontext.ExtractTenantID(ctx)
+	if !ok {
+		tenantID = metacontext.DefaultTenantID
+	}
+
+	var createdGroup entity.Group
+	err = database.TransactionWithRetry(metacontext.InjectTenantID(ctx, tenantID), db, func(tx *gorm.DB) error {
+		if err := s.checkGroupName(tx, req.Name, 0); err != nil {
+			return err
+		}
+
+		createdGroup, err = s.repo.CreateGroup(tx, entity.Group{
+			TenantID:    tenantID,
+			Name:        req.Name,
+			Description: req.Description,
+		})
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			// A concurrent request created a group with the same name after the check above
+			return fmt.Errorf("%w: %v", ErrGroupAlreadyExists, err)
+		}
+		if err != nil {
+			return err
+		}
+
+		return recordGroupAudit(tx, meta, createdGroup, "create_group", fmt.Sprintf("Group %s created", createdGroup.Name))
+	})
+	if err != nil {
+		return entity.Group{}, err
+	}
+
+	logger.Info(fmt.Sprintf("Group %d created by %s", createdGroup.ID, meta.Actor()), logrus.Fields{
+		"groupID": createdGroup.ID,
+		"actor":   meta.Actor(),
+		"tokenID": meta.TokenID,
+	})
+	return createdGroup, nil
+}
+
+// UpdateGroup replaces the name and the description of a group, the new name must not be taken by another group of the tenant.
+// The update is recorded in the audit log.
+func (s *groupService) UpdateGroup(ctx context.Context, id int64, req entity.GroupRequest) (entity.Group, error) {
+	meta, db, err := s.groupContext(ctx)
+	if err != nil {
+		return entity.Group{}, err
+	}
+
+	var updatedGroup entity.Group
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		group, err := s.repo.GetGroupByIDForUpdate(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := s.checkGroupName(tx, req.Name, group.ID); err != nil {
+			return err
+		}
+
+		previousName := group.Name
+		group.Name = req.Name
+		group.Description = req.Description
+		updatedGroup, err = s.repo.UpdateGroup(tx, group)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("%w: %v", ErrGroupAlreadyExists, err)
+		}
+		if err != nil {
+			return err
+		}
+
+		details := fmt.Sprintf("Group %s updated", updatedGroup.Name)
+		if previousName != updatedGroup.Name {
+			details = fmt.Sprintf("Group %s renamed to %s", previousName, updatedGroup.Name)
+		}
+		return recordGroupAudit(tx, meta, updatedGroup, "update_group", details)
+	})
+	if err != nil {
+		return entity.Group{}, err
+	}
+
+	logger.Info(fmt.Sprintf("Group %d updated by %s", id, meta.Actor()), logrus.Fields{
+		"groupID": id,
+		"actor":   meta.Actor(),
+		"tokenID": meta.TokenID,
+	})
+	return updatedGroup, nil
+}
+
+// DeleteGroup deletes a group of the tenant of the context. A group with members is only deleted with force,
+// its memberships are then removed along with it in the same transaction, otherwise ErrGroupHasMembers is returned.
+// The deletion is recorded in the audit log, and as a group.deleted event listing the removed members in the outbox.
+func (s *groupService) DeleteGroup(ctx context.Context, id int64, force bool) error {
+	meta, db, err := s.groupContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var removed []int64
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Lock the group, so no member is added while it is deleted
+		group, err := s.repo.GetGroupByIDForUpdate(tx, id)
+		if err != nil {
+			return err
+		}
+
+		removed, err = s.repo.GetGroupMemberIDs(tx, group.ID)
+		if err != nil {
+			return err
+		}
+		if len(removed) > 0 && !force {
+			return fmt.Errorf("%w: %d members, use force to remove them along with the group", ErrGroupHasMembers, len(removed))
+		}
+
+		if _, err := s.repo.DeleteGroupMemberships(tx, group.ID); err != nil {
+			return err
+		}
+		if err := s.repo.DeleteGroup(tx, group); err != nil {
+			return err
+		}
+
+		if err := recordGroupEvent(tx, entity.OutboxEventGroupDeleted, group, entity.GroupEventPayload{RemovedMembers: removed}); err != nil {
+			return err
+		}
+		return recordGroupAudit(tx, meta, group, "delete_group", fmt.Sprintf("Group %s deleted along with %d members", group.Name, len(removed)))
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info(fmt.Sprintf("Group %d deleted by %s", id, meta.Actor()), logrus.Fields{
+		"groupID":        id,
+		"removedMembers": len(removed),
+		"actor":          meta.Actor(),
+		"tokenID":        meta.TokenID,
+	})
+	return nil
+}
+
+// GetGroupMembers retrieves a page of the members of a group along with their role, the oldest members first,
+// and their total number. The soft-deleted users are left out. It returns gorm.ErrRecordNotFound if the group does not exist.
+func (s *groupService) GetGroupMembers(ctx context.Context, id int64, page int, limit int) ([]entity.GroupMemberResponse, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	db = db.WithContext(ctx)
+
+	// Check if the group exists in the tenant of the caller
+	if _, err := s.repo.GetGroupByID(db, id); err != nil {
+		return nil, 0, err
+	}
+
+	memberships, err := s.repo.GetGroupMembers(db, id, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Attach the users of the page, in a single query
+	ids := make([]int64, 0, len(memberships))
+	for _, membership := range memberships {
+		ids = append(ids, membership.UserID)
+	}
+	users, _, err := repository.NewUserRepository().GetUsersByIDs(db, ids, true)
+	if err != nil {
+		return nil, 0, err
+	}
+	usersByID := make(map[int64]entity.User, len(users))
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+
+	members := make([]entity.GroupMemberResponse, 0, len(memberships))
+	for _, membership := range memberships {
+		user, ok := usersByID[membership.UserID]
+		if !ok {
+			continue
+		}
+		members = append(members, toGroupMemberResponse(user, membership))
+	}
+
+	// Count the members over all pages for the pagination metadata
+	total, err := s.repo.CountGroupMembers(db, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return members, total, nil
+}
+
+// AddGroupMember adds a user of the tenant of the group to the group, as a member unless another role is given.
+// The addition is recorded in the audit log, and as a group.member_added event in the outbox.
+func (s *groupService) AddGroupMember(ctx context.Context, id int64, req entity.GroupMemberRequest) (entity.GroupMemberResponse, error) {
+	meta, db, err := s.groupContext(ctx)
+	if err != nil {
+		return entity.GroupMemberResponse{}, err
+	}
+
+	role := req.Role
+	if role == "" {
+		role = entity.GroupRoleMember
+	}
+
+	var member entity.GroupMemberResponse
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		group, user, err := s.getGroupAndUser(tx, id, req.UserID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.repo.GetGroupMembershipForUpdate(tx, group.ID, user.ID); err == nil {
+			return ErrGroupMemberAlreadyExists
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		membership, err := s.repo.CreateGroupMembership(tx, entity.GroupMembership{
+			GroupID:   group.ID,
+			UserID:    user.ID,
+			Role:      role,
+			CreatedAt: time.Now().UTC(),
+		})
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			// A concurrent request added the same user after the check above
+			return fmt.Errorf("%w: %v", ErrGroupMemberAlreadyExists, err)
+		}
+		if err != nil {
+			return err
+		}
+		member = toGroupMemberResponse(user, membership)
+
+		if err := recordGroupEvent(tx, entity.OutboxEventGroupMemberAdded, group, entity.GroupEventPayload{UserID: &user.ID, Role: role}); err != nil {
+			return err
+		}
+		return recordGroupAudit(tx, meta, group, "add_group_member", fmt.Sprintf("User %d added as %s", user.ID, role))
+	})
+	if err != nil {
+		return entity.GroupMemberResponse{}, err
+	}
+
+	logger.Info(fmt.Sprintf("User %d added to group %d by %s", req.UserID, id, meta.Actor()), logrus.Fields{
+		"groupID": id,
+		"userID":  req.UserID,
+		"role":    role,
+		"actor":   meta.Actor(),
+		"tokenID": meta.TokenID,
+	})
+	return member, nil
+}
+
+// UpdateGroupMember changes the role of a member within its group.
+// The change is recorded in the audit log, and as a group.member_updated event in the outbox.
+func (s *groupService) UpdateGroupMember(ctx context.Context, id int64, userID int64, req entity.GroupMemberRoleRequest) (entity.GroupMemberResponse, error) {
+	meta, db, err := s.groupContext(ctx)
+	if err != nil {
+		return entity.GroupMemberResponse{}, err
+	}
+
+	var member entity.GroupMemberResponse
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		group, membership, err := s.getMembership(tx, id, userID)
+		if err != nil {
+			return err
+		}
+
+		user, err := repository.NewUserRepository().GetUserByID(tx, userID, repository.WithDeleted())
+		if err != nil {
+			return err
+		}
+
+		previousRole := membership.Role
+		membership.Role = req.Role
+		if membership, err = s.repo.UpdateGroupMembership(tx, membership); err != nil {
+			return err
+		}
+		member = toGroupMemberResponse(user, membership)
+
+		// The role is unchanged, there is nothing to tell
+		if previousRole == membership.Role {
+			return nil
+		}
+
+		if err := recordGroupEvent(tx, entity.OutboxEventGroupMemberUpdated, group, entity.GroupEventPayload{UserID: &userID, Role: membership.Role}); err != nil {
+			return err
+		}
+		return recordGroupAudit(tx, meta, group, "update_group_member", fmt.Sprintf("User %d changed from %s to %s", userID, previousRole, membership.Role))
+	})
+	if err != nil {
+		return entity.GroupMemberResponse{}, err
+	}
+
+	logger.Info(fmt.Sprintf("Role of user %d in group %d updated by %s", userID, id, meta.Actor()), logrus.Fields{
+		"groupID": id,
+		"userID":  userID,
+		"role":    req.Role,
+		"actor":   meta.Actor(),
+		"tokenID": meta.TokenID,
+	})
+	return member, nil
+}
+
+// RemoveGroupMember removes a member from its group.
+// The removal is recorded in the audit log, and as a group.member_removed event in the outbox.
+func (s *groupService) RemoveGroupMember(ctx context.Context, id int64, userID int64) error {
+	meta, db, err := s.groupContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		group, membership, err := s.getMembership(tx, id, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.repo.DeleteGroupMembership(tx, membership); err != nil {
+			return err
+		}
+
+		if err := recordGroupEvent(tx, entity.OutboxEventGroupMemberRemoved, group, entity.GroupEventPayload{UserID: &userID, Role: membership.Role}); err != nil {
+			return err
+		}
+		return recordGroupAudit(tx, meta, group, "remove_group_member", fmt.Sprintf("User %d removed, was %s", userID, membership.Role))
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info(fmt.Sprintf("User %d removed from group %d by %s", userID, id, meta.Actor()), logrus.Fields{
+		"groupID": id,
+		"userID":  userID,
+		"actor":   meta.Actor(),
+		"tokenID": meta.TokenID,
+	})
+	return nil
+}
+
+// groupContext returns the user managing the groups and the database of the context.
+func (s *groupService) groupContext(ctx context.Context) (metacontext.UserInformationMeta, *gorm.DB, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return metacontext.UserInformationMeta{}, nil, err
+	}
+
+	// The changes must be performed by a user, who is recorded along with them
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return metacontext.UserInformationMeta{}, nil, fmt.Errorf("missing user context")
+	}
+
+	return meta, db, nil
+}
+
+// checkGroupName returns ErrGroupAlreadyExists if another group of the tenant than the given one has the name,
+// compared case-insensitively.
+func (s *groupService) checkGroupName(tx *gorm.DB, name string, groupID int64) error {
+	existing, err := s.repo.GetGroupByName(tx, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check existing group by name: %w", err)
+	}
+	if existing.ID != groupID {
+		return fmt.Errorf("%w: name %s", ErrGroupAlreadyExists, name)
+	}
+	return nil
+}
+
+// getGroupAndUser locks the group of the tenant of the context and retrieves the user to add to it,
+// which must be a user of the same tenant that is not deleted.
+func (s *groupService) getGroupAndUser(tx *gorm.DB, groupID int64, userID int64) (entity.Group, entity.User, error) {
+	group, err := s.repo.GetGroupByIDForUpdate(tx, groupID)
+	if err != nil {
+		return entity.Group{}, entity.User{}, err
+	}
+
+	user, err := repository.NewUserRepository().GetUserByID(tx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity.Group{}, entity.User{}, fmt.Errorf("%w: no user %d in the tenant of the group", ErrGroupUserNotFound, userID)
+	}
+	if err != nil {
+		return entity.Group{}, entity.User{}, err
+	}
+
+	return group, user, nil
+}
+
+// getMembership locks the group of the tenant of the context and the membership of the user in it.
+// It returns ErrGroupMemberNotFound if the user is not a member of the group.
+func (s *groupService) getMembership(tx *gorm.DB, groupID int64, userID int64) (entity.Group, entity.GroupMembership, error) {
+	group, err := s.repo.GetGroupByIDForUpdate(tx, groupID)
+	if err != nil {
+		return entity.Group{}, entity.GroupMembership{}, err
+	}
+
+	membership, err := s.repo.GetGroupMembershipForUpdate(tx, groupID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity.Group{}, entity.GroupMembership{}, ErrGroupMemberNotFound
+	}
+	if err != nil {
+		return entity.Group{}, entity.GroupMembership{}, err
+	}
+
+	return group, membership, nil
+}
+
+// toGroupMemberResponse converts a membership and its user into the member returned by the API.
+func toGroupMemberResponse(user entity.User, membership entity.GroupMembership) entity.GroupMemberResponse {
+	return entity.GroupMemberResponse{
+		User:     user.ToResponse(),
+		Role:     membership.Role,
+		JoinedAt: membership.CreatedAt,
+	}
+}
+
+// recordGroupEvent records a group event in the outbox, in the transaction of the change.
+func recordGroupEvent(tx *gorm.DB, eventType string, group entity.Group, payload entity.GroupEventPayload) error {
+	payload.GroupID = group.ID
+	payload.GroupName = group.Name
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	_, err = repository.NewOutboxEventRepository().CreateOutboxEvent(tx, entity.OutboxEvent{
+		TenantID:      group.TenantID,
+		Type:          eventType,
+		AggregateType: "group",
+		AggregateID:   strconv.FormatInt(group.ID, 10),
+		Payload:       string(encoded),
+	})
+	return err
+}
+
+// recordGroupAudit records a change of a group or of its members in the audit log.
+func recordGroupAudit(tx *gorm.DB, meta metacontext.UserInformationMeta, group entity.Group, action string, details string) error {
+	actorID := meta.UserID
+	_, err := repository.NewAuditLogRepository().CreateAuditLog(tx, entity.AuditLog{
+		TenantID:   group.TenantID,
+		ActorID:    &actorID,
+		Actor:      meta.Actor(),
+		Action:     action,
+		EntityType: "group",
+		EntityID:   strconv.FormatInt(group.ID, 10),
+		Details:    &details,
+		CreatedAt:  time.Now().UTC(),
+	})
+	return err
+}
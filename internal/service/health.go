@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+)
+
+const (
+	// readinessTimeout bounds the time the readiness check waits for the database
+	readinessTimeout = 2 * time.Second
+)
+
+// Interface for health service
+// This interface defines the methods that the health service should implement
+type HealthService interface {
+	CheckReadiness(ctx context.Context) (database.BreakerState, error)
+}
+
+// This struct defines the HealthService which checks whether the application can serve the requests
+type healthService struct{}
+
+// NewHealthService creates a new instance of HealthService.
+func NewHealthService() HealthService {
+	return &healthService{}
+}
+
+// CheckReadiness checks that the database answers, and returns the state of its circuit breaker.
+// The check is skipped while the breaker is open, once its cooldown has elapsed the check is the probe closing it.
+func (s *healthService) CheckReadiness(ctx context.Context) (database.BreakerState, error) {
+	breaker := database.GetCircuitBreaker()
+	if err := breaker.Allow(); err != nil {
+		return breaker.State(), err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	err := database.PingPostgres(ctx)
+	breaker.Record(err)
+
+	return breaker.State(), err
+}
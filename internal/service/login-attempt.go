@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+)
+
+const (
+	// loginAttemptBufferSize is the number of login attempts buffered before they are dropped
+	loginAttemptBufferSize = 1024
+
+	// loginAttemptBatchSize is the maximum number of login attempts written in one query
+	loginAttemptBatchSize = 100
+
+	// loginAttemptFlushInterval is the maximum delay before a buffered login attempt is written
+	loginAttemptFlushInterval = time.Second
+
+	// loginAttemptCleanupInterval is the interval of the cleanup job removing the expired login attempts
+	loginAttemptCleanupInterval = time.Hour
+
+	// defaultLoginHistoryRetentionDays is the default number of days the login attempts are kept
+	defaultLoginHistoryRetentionDays = 90
+)
+
+// loginAttemptRecorder writes the login attempts asynchronously through a buffered channel,
+// so recording an attempt never slows down the login itself.
+type loginAttemptRecorder struct {
+	mu       sync.RWMutex
+	attempts chan entity.LoginAttempt
+	done     chan struct{}
+}
+
+var recorder = &loginAttemptRecorder{}
+
+// Interface for login attempt service
+// This interface defines the methods that the login attempt service should implement
+type LoginAttemptService interface {
+	RecordLoginAttempt(attempt entity.LoginAttempt)
+	GetLoginHistory(ctx context.Context, userID int64, filter entity.LoginAttemptFilter) ([]entity.LoginAttempt, int64, error)
+	RemoveExpiredLoginAttempts(now time.Time) (int64, error)
+}
+
+// This struct defines the LoginAttemptService that contains a repository field of type LoginAttemptRepository
+// It implements the LoginAttemptService interface and provides methods for login attempt-related operations
+type loginAttemptService struct {
+	repo repository.LoginAttemptRepository
+}
+
+// NewLoginAttemptService creates a new instance of LoginAttemptService with the given repository.
+// It initializes the loginAttemptService struct and returns it.
+func NewLoginAttemptService(repo repository.LoginAttemptRepository) LoginAttemptService {
+	return &loginAttemptService{repo: repo}
+}
+
+// StartLoginAttemptRecorder starts the background writer of the login attempts and the cleanup job
+// removing the login attempts older than the retention period. It does nothing if it is already started.
+func StartLoginAttemptRecorder() {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	if recorder.attempts != nil {
+		return
+	}
+
+	recorder.attempts = make(chan entity.LoginAttempt, loginAttemptBufferSize)
+	recorder.done = make(chan struct{})
+	go runLoginAttemptRecorder(&loginAttemptService{repo: repository.NewLoginAttemptRepository()}, recorder.attempts, recorder.done)
+}
+
+// StopLoginAttemptRecorder stops the background writer after writing the buffered login attempts.
+func StopLoginAttemptRecorder() {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	if recorder.attempts == nil {
+		return
+	}
+
+	close(recorder.attempts)
+	<-recorder.done
+	recorder.attempts = nil
+}
+
+// RecordLoginAttempt queues the login attempt to be written by the background writer.
+// The attempt is dropped when the recorder is not started, and with a warning when its buffer is full.
+func (s *loginAttemptService) RecordLoginAttempt(attempt entity.LoginAttempt) {
+	recorder.mu.RLock()
+	defer recorder.mu.RUnlock()
+
+	if recorder.attempts == nil {
+		return
+	}
+
+	if attempt.AttemptedAt.IsZero() {
+		attempt.AttemptedAt = time.Now().UTC()
+	}
+
+	select {
+	case recorder.attempts <- attempt:
+	default:
+		logger.Warn(fmt.Sprintf("Login attempt buffer is full, dropping the attempt of user %s", attempt.Username), nil)
+	}
+}
+
+// GetLoginHistory retrieves the login attempts of a user along with their total number, the most recent first.
+// The user must belong to the tenant of the context.
+func (s *loginAttemptService) GetLoginHistory(ctx context.Context, userID int64, filter entity.LoginAttemptFilter) ([]entity.LoginAttempt, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	db = db.WithContext(ctx)
+
+	// Check if the user exists, its username matches the attempts recorded before its ID was known
+	userRepo := repository.NewUserRepository()
+	user, err := userRepo.GetUserByID(db, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Only the attempts of the tenant of the user match its username, even when the context carries no tenant
+	db = db.WithContext(metacontext.InjectTenantID(ctx, user.TenantID))
+
+	// Retrieve the login attempts of the user from the repository
+	attempts, err := s.repo.GetLoginAttemptsByUserID(db, userID, user.Username, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Count the login attempts of the user for the pagination metadata
+	total, err := s.repo.CountLoginAttemptsByUserID(db, userID, user.Username, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return attempts, total, nil
+}
+
+// RemoveExpiredLoginAttempts removes the login attempts older than the retention period.
+// It returns the number of removed login attempts.
+func (s *loginAttemptService) RemoveExpiredLoginAttempts(now time.Time) (int64, error) {
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return 0, err
+	}
+
+	before := now.AddDate(0, 0, -GetLoginHistoryRetentionDays())
+	return s.repo.RemoveLoginAttemptsBefore(db, before)
+}
+
+// writeLoginAttempts writes a batch of login attempts in the database.
+// The user ID of each attempt is resolved from its username when it matches an existing user,
+// in the tenant of the attempt when it is known.
+func (s *loginAttemptService) writeLoginAttempts(attempts []entity.LoginAttempt) error {
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return err
+	}
+
+	userRepo := repository.NewUserRepository()
+	userIDs := make(map[string]*int64)
+	for i := range attempts {
+		if attempts[i].UserID != nil {
+			continue
+		}
+
+		key := fmt.Sprintf("%d/%s", attempts[i].TenantID, attempts[i].Username)
+		userID, ok := userIDs[key]
+		if !ok {
+			tx := db
+			if attempts[i].TenantID > 0 {
+				tx = db.WithContext(metacontext.InjectTenantID(context.Background(), attempts[i].TenantID))
+			}
+			if user, err := userRepo.GetUserByUsername(tx, attempts[i].Username); err == nil {
+				userID = &user.ID
+			}
+			userIDs[key] = userID
+		}
+		attempts[i].UserID = userID
+	}
+
+	return s.repo.CreateLoginAttempts(db, attempts)
+}
+
+// runLoginAttemptRecorder writes the queued login attempts in batches and runs the cleanup job,
+// until the attempts channel is closed.
+func runLoginAttemptRecorder(s *loginAttemptService, attempts <-chan entity.LoginAttempt, done chan<- struct{}) {
+	defer close(done)
+
+	flushTicker := time.NewTicker(loginAttemptFlushInterval)
+	defer flushTicker.Stop()
+	cleanupTicker := time.NewTicker(loginAttemptCleanupInterval)
+	defer cleanupTicker.Stop()
+
+	batch := make([]entity.LoginAttempt, 0, loginAttemptBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.writeLoginAttempts(batch); err != nil {
+			logger.Error(fmt.Sprintf("Failed to write %d login attempts: %v", len(batch), err), nil)
+		}
+		batch = make([]entity.LoginAttempt, 0, loginAttemptBatchSize)
+	}
+
+	for {
+		select {
+		case attempt, ok := <-attempts:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, attempt)
+			if len(batch) >= loginAttemptBatchSize {
+				flush()
+			}
+		case <-flushTicker.C:
+			flush()
+		case now := <-cleanupTicker.C:
+			if removed, err := s.RemoveExpiredLoginAttempts(now); err != nil {
+				logger.Error(fmt.Sprintf("Failed to remove expired login attempts: %v", err), nil)
+			} else if removed > 0 {
+				logger.Info(fmt.Sprintf("Removed %d expired login attempts", removed), nil)
+			}
+		}
+	}
+}
+
+// GetLoginHistoryRetentionDays returns the number of days the login attempts are kept.
+// It retrieves the retention from an environment variable.
+func GetLoginHistoryRetentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("LOGIN_HISTORY_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		return defaultLoginHistoryRetentionDays // Default to 90 days if the environment variable is not set or invalid
+	}
+
+	return days
+}
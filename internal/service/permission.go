@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+)
+
+const (
+	// defaultPermissionCacheTTLSeconds is the time the effective permissions of a user are cached for, see GetPermissionCacheTTL
+	defaultPermissionCacheTTLSeconds = 300
+)
+
+// ErrPermissionAccessForbidden is returned when the caller reads the permissions of another user without being an admin.
+var ErrPermissionAccessForbidden = errors.New("not allowed to read the permissions of another user")
+
+// Interface for permission service
+// This interface defines the methods that the permission service should implement
+type PermissionService interface {
+	GetUserPermissions(ctx context.Context, userID int64) (entity.UserPermissionsResponse, error)
+}
+
+// This struct defines the PermissionService that contains a repository field of type PermissionRepository
+// It implements the PermissionService interface and provides methods for permission-related operations
+type permissionService struct {
+	repo repository.PermissionRepository
+}
+
+// NewPermissionService creates a new instance of PermissionService with the given repository.
+// It initializes the permissionService struct and returns it.
+func NewPermissionService(repo repository.PermissionRepository) PermissionService {
+	return &permissionService{repo: repo}
+}
+
+// GetUserPermissions retrieves the effective permissions of a user, the union of the permissions of its roles
+// without duplicates, ordered by name. The callers may only read their own permissions, unless they are admins.
+// The permissions are cached per user until its roles change, one of them expires, or GetPermissionCacheTTL has passed.
+func (s *permissionService) GetUserPermissions(ctx context.Context, userID int64) (entity.UserPermissionsResponse, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.UserPermissionsResponse{}, err
+	}
+	db = db.WithContext(ctx)
+
+	if err := checkUserAccess(ctx, userID, ErrPermissionAccessForbidden); err != nil {
+		return entity.UserPermissionsResponse{}, err
+	}
+
+	// Check if the user exists in the tenant of the caller, the cache is shared by all the tenants
+	user, err := repository.NewUserRepository().GetUserByID(db, userID)
+	if err != nil {
+		return entity.UserPermissionsResponse{}, err
+	}
+
+	now := time.Now()
+	if names, ok := userPermissions.get(userID, now); ok {
+		return entity.UserPermissionsResponse{UserID: userID, Permissions: names}, nil
+	}
+
+	// Changes of the roles made while the permissions are computed are not missed, see permissionCache.put
+	version := userPermissions.currentVersion()
+	permissions, err := s.repo.GetPermissionsByUserID(db, userID)
+	if err != nil {
+		return entity.UserPermissionsResponse{}, err
+	}
+
+	names := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		names = append(names, permission.Name)
+	}
+
+	// The permissions of a role are no longer granted once its assignment expires
+	expiresAt := now.Add(GetPermissionCacheTTL())
+	for _, role := range user.Roles {
+		if role.ExpiresAt != nil && role.ExpiresAt.Before(expiresAt) {
+			expiresAt = *role.ExpiresAt
+		}
+	}
+	userPermissions.put(userID, names, expiresAt, version)
+
+	return entity.UserPermissionsResponse{UserID: userID, Permissions: names}, nil
+}
+
+// ResolveUserPermissions returns the effective permission names of the user of the context, it is the lookup
+// of authorization.RequirePermission for the tokens that do not carry them. A user that no longer exists has none.
+func ResolveUserPermissions(ctx context.Context, userID int64) ([]string, error) {
+	permissions, err := NewPermissionService(repository.NewPermissionRepository()).GetUserPermissions(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	return permissions.Permissions, nil
+}
+
+// userPermissions caches the effective permissions of the users, by user ID.
+var userPermissions = newPermissionCache()
+
+// invalidateUserPermissions drops the cached permissions of the users, it is called once their roles have changed.
+func invalidateUserPermissions(userIDs ...int64) {
+	userPermissions.invalidate(userIDs...)
+}
+
+// permissionCacheEntry holds the cached permission names of a user until the given time.
+type permissionCacheEntry struct {
+	names     []string
+	expiresAt time.Time
+}
+
+// permissionCache is the in-memory cache of the effective permissions of the users.
+// Its version is incremented on every invalidation, so permissions computed before a change of the roles
+// but stored after its invalidation are discarded instead of being served until they expire.
+type permissionCache struct {
+	mu      sync.Mutex
+	entries map[int64]permissionCacheEntry
+	version uint64
+}
+
+// newPermissionCache returns an empty permission cache.
+func newPermissionCache() *permissionCache {
+	return &permissionCache{entries: make(map[int64]permissionCacheEntry)}
+}
+
+// get returns the cached permission names of the user, if they have not expired at the given time.
+func (c *permissionCache) get(userID int64, now time.Time) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[userID]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(entry.expiresAt) {
+		delete(c.entries, userID)
+		return nil, false
+	}
+
+	return entry.names, true
+}
+
+// currentVersion returns the version of the cache, to pass to put once the permissions are computed.
+func (c *permissionCache) currentVersion() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.version
+}
+
+// put caches the permission names of the user until the given time, unless the cache was invalidated
+// since the version was read.
+func (c *permissionCache) put(userID int64, names []string, expiresAt time.Time, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.version != version || !time.Now().Before(expiresAt) {
+		return
+	}
+	c.entries[userID] = permissionCacheEntry{names: names, expiresAt: expiresAt}
+}
+
+// invalidate drops the cached permissions of the users and increments the version of the cache.
+func (c *permissionCache) invalidate(userIDs ...int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	for _, userID := range userIDs {
+		delete(c.entries, userID)
+	}
+}
+
+// GetPermissionCacheTTL returns the time the effective permissions of a user are cached for at most.
+// It retrieves the time in seconds from an environment variable, 0 disables the cache.
+func GetPermissionCacheTTL() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("PERMISSION_CACHE_TTL_SECONDS"))
+	if err != nil || seconds < 0 {
+		seconds = defaultPermissionCacheTTLSeconds // Default to 5 minutes if the environment variable is not set or invalid
+	}
+
+	return time.Duration(seconds) * time.Second
+}
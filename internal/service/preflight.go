@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	jwtutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/jwt-util"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+const (
+	// preflightTimeout bounds the time the preflight waits for each of its checks
+	preflightTimeout = 5 * time.Second
+
+	// preflightDatabaseCheck is the name of the check of the database, the checks reading the database are skipped when it fails
+	preflightDatabaseCheck = "database"
+)
+
+// preflightRoles are the roles the routes authorize, which must exist along with the roles of the configuration,
+// see requiredRoles.
+var preflightRoles = []string{"ROLE_USER", adminRole, superAdminRole}
+
+// Interface for preflight service
+// This interface defines the methods that the preflight service should implement
+type PreflightService interface {
+	Run(ctx context.Context) entity.PreflightReport
+}
+
+// preflightCheck is a check of the preflight, it returns what it checked or what is wrong.
+// The checks reading the database are skipped when the database does not answer.
+type preflightCheck struct {
+	name          string
+	needsDatabase bool
+	run           func(ctx context.Context) (string, error)
+}
+
+// This struct defines the PreflightService which checks the configuration, the database and the keys of the application,
+// so a misconfiguration is found at startup instead of failing the requests after the deployment.
+type preflightService struct {
+	clock  clock.Clock
+	checks []preflightCheck
+}
+
+// NewPreflightService creates a new instance of PreflightService.
+// The checks are, in order: the database answers, the tables and the columns of the migration exist,
+// the JWT keys are loaded and sign tokens they verify, the system user exists, and the roles the application needs exist.
+func NewPreflightService(clk clock.Clock) PreflightService {
+	return &preflightService{
+		clock: clk,
+		checks: []preflightCheck{
+			{name: preflightDatabaseCheck, run: checkDatabase},
+			{name: "schema", needsDatabase: true, run: checkSchema},
+			{name: "jwt_keys", run: checkJWTKeys},
+			{name: "system_user", needsDatabase: true, run: checkSystemUser},
+			{name: "roles", needsDatabase: true, run: checkRequiredRoles},
+		},
+	}
+}
+
+// Run runs every check and returns their report, a check failing does not stop the next ones
+// so the report lists all the problems at once.
+func (s *preflightService) Run(ctx context.Context) entity.PreflightReport {
+	report := entity.PreflightReport{Passed: true, CheckedAt: s.clock.Now().UTC()}
+
+	databaseUp := true
+	for _, check := range s.checks {
+		result := entity.PreflightCheck{Name: check.name}
+		if check.needsDatabase && !databaseUp {
+			result.Status = entity.PreflightSkipped
+			result.Detail = "skipped, the database does not answer"
+		} else if detail, err := runPreflightCheck(ctx, check); err != nil {
+			result.Status = entity.PreflightFailed
+			result.Detail = err.Error()
+		} else {
+			result.Status = entity.PreflightPassed
+			result.Detail = detail
+		}
+
+		if check.name == preflightDatabaseCheck && result.Status != entity.PreflightPassed {
+			databaseUp = false
+		}
+		if result.Status != entity.PreflightPassed {
+			report.Passed = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report
+}
+
+// runPreflightCheck runs the check within preflightTimeout.
+func runPreflightCheck(ctx context.Context, check preflightCheck) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+
+	return check.run(ctx)
+}
+
+// checkDatabase checks that the database answers.
+func checkDatabase(ctx context.Context) (string, error) {
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return "", err
+	}
+
+	if err := database.PingPostgres(ctx); err != nil {
+		return "", fmt.Errorf("the %s database does not answer: %w", db.Dialector.Name(), err)
+	}
+	return fmt.Sprintf("the %s database answers", db.Dialector.Name()), nil
+}
+
+// checkSchema checks that the tables and the columns of the migration exist, see database.VerifySchema.
+func checkSchema(ctx context.Context) (string, error) {
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return "", err
+	}
+
+	models := database.MigratedModels()
+	fingerprint, err := database.VerifySchema(db.WithContext(ctx), models...)
+	if err != nil {
+		return "", fmt.Errorf("%w (expected schema %s)", err, fingerprint)
+	}
+	return fmt.Sprintf("the %d tables of the migration exist with their columns (schema %s)", len(models), fingerprint), nil
+}
+
+// checkJWTKeys checks that the keys of the signing algorithm are loaded, and that a token they sign verifies.
+// A private key and a public key that do not form a pair fail the check, see jwtutil.LoadKeyPair.
+func checkJWTKeys(ctx context.Context) (string, error) {
+	LoadEnv()
+
+	secret := getJWTSecret()
+	if SigningMethod == jwt.SigningMethodHS256.Alg() && secret == "" {
+		return "", errors.New("JWT_SECRET is not set")
+	}
+
+	keys, err := jwtutil.LoadKeySet(SigningMethod, secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to load the %s keys: %w", SigningMethod, err)
+	}
+
+	token, err := keys.Sign(jwt.MapClaims{"sub": "preflight"})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign a token with the %s key %s: %w", SigningMethod, keys.Current().ID, err)
+	}
+	if _, err := keys.Parse(token); err != nil {
+		return "", fmt.Errorf("a token signed with the %s key %s does not verify: %w", SigningMethod, keys.Current().ID, err)
+	}
+
+	return fmt.Sprintf("the %s key %s signs tokens it verifies", SigningMethod, keys.Current().ID), nil
+}
+
+// checkSystemUser checks that the reserved system user, seeded by the migration, exists.
+func checkSystemUser(ctx context.Context) (string, error) {
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return "", err
+	}
+
+	user, err := repository.NewUserRepository().GetUserByID(db.WithContext(ctx), metacontext.SystemUserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", fmt.Errorf("the system user (ID %d) does not exist, the database was not migrated", metacontext.SystemUserID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the system user: %w", err)
+	}
+	if user.Username != metacontext.SystemUsername {
+		return "", fmt.Errorf("the user with the ID %d is %q instead of the system user", metacontext.SystemUserID, user.Username)
+	}
+
+	return fmt.Sprintf("the system user (ID %d) exists", metacontext.SystemUserID), nil
+}
+
+// checkRequiredRoles checks that the roles the application needs exist, see requiredRoles.
+func checkRequiredRoles(ctx context.Context) (string, error) {
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return "", err
+	}
+
+	names := requiredRoles()
+	roles, err := repository.NewRoleRepository().GetRolesByNames(db.WithContext(ctx), names)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the roles: %w", err)
+	}
+
+	var missing []string
+	for _, name := range names {
+		if !slices.ContainsFunc(roles, func(role entity.Role) bool { return strings.EqualFold(role.Name, name) }) {
+			missing = append(missing, name)
+		}
+	}
+	switch len(missing) {
+	case 0:
+	case 1:
+		return "", fmt.Errorf("the role %s does not exist, the database was not seeded", missing[0])
+	default:
+		return "", fmt.Errorf("the roles %s do not exist, the database was not seeded", strings.Join(missing, ", "))
+	}
+
+	return fmt.Sprintf("the roles %s exist", strings.Join(names, ", ")), nil
+}
+
+// requiredRoles returns the roles the application needs: the roles the routes authorize, the role of the accounts
+// created through the self-registration when it is enabled, and the default role of the new users when it is set.
+func requiredRoles() []string {
+	names := slices.Clone(preflightRoles)
+	if IsSelfRegistrationEnabled() {
+		names = append(names, validation.NormalizeRoleName(GetSelfRegistrationRole()))
+	}
+	if role := GetDefaultUserRole(); role != "" {
+		names = append(names, role)
+	}
+
+	var unique []string
+	for _, name := range names {
+		if !slices.Contains(unique, name) {
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,34 +14,51 @@ import (
 	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
 )
 
+const (
+	// defaultMaxSessionsPerUser is the default maximum number of active sessions per user
+	defaultMaxSessionsPerUser = 3
+
+	// SessionLimitPolicyReject rejects the login when the session limit is reached
+	SessionLimitPolicyReject = "REJECT"
+
+	// SessionLimitPolicyEvictOldest revokes the oldest session when the session limit is reached
+	SessionLimitPolicyEvictOldest = "EVICT_OLDEST"
+)
+
+// ErrSessionLimitReached is returned when the user already has the maximum number of active sessions.
+var ErrSessionLimitReached = errors.New("maximum number of active sessions reached")
+
 // Interface for refresh token service
 // This interface defines the methods that the refresh token service should implement
 type RefreshTokenService interface {
 	GetRefreshTokenByUserID(userID int64) (entity.RefreshToken, error)
 	GetRefreshTokenByToken(token string) (entity.RefreshToken, error)
 	VerifyExpirationDate(exp time.Time) (bool, error)
-	CreateRefreshToken(userID int64) (entity.RefreshToken, error)
+	CreateRefreshToken(userID int64, ipAddress string, userAgent string) (entity.RefreshToken, error)
+	RotateRefreshToken(token string) (entity.RefreshToken, error)
 }
 
 // This struct defines the RefreshTokenService that contains a repository field of type RefreshTokenRepository
 // It implements the RefreshTokenService interface and provides methods for refresh token-related operations
 type refreshTokenService struct {
-	repo repository.RefreshTokenRepository
+	repo  repository.RefreshTokenRepository
+	clock clock.Clock
 }
 
 // NewRefreshTokenService creates a new instance of RefreshTokenService with the given repository.
-// It initializes the refreshTokenService struct and returns it.
-func NewRefreshTokenService(repo repository.RefreshTokenRepository) RefreshTokenService {
-	return &refreshTokenService{repo: repo}
+// The expiry of the refresh tokens is set and checked with the given clock.
+func NewRefreshTokenService(repo repository.RefreshTokenRepository, clk clock.Clock) RefreshTokenService {
+	return &refreshTokenService{repo: repo, clock: clk}
 }
 
-// GetRefreshTokenByUserID retrieves a refresh token by its user ID from the database.
+// GetRefreshTokenByUserID retrieves the most recent refresh token of a user from the database.
 func (s *refreshTokenService) GetRefreshTokenByUserID(userID int64) (entity.RefreshToken, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.RefreshToken{}, fmt.Errorf("database connection is nil")
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return entity.RefreshToken{}, err
 	}
 
 	// Retrieve the token by user ID from the repository
@@ -54,9 +72,9 @@ func (s *refreshTokenService) GetRefreshTokenByUserID(userID int64) (entity.Refr
 
 // GetRefreshTokenByToken retrieves a refresh token by its token string from the database.
 func (s *refreshTokenService) GetRefreshTokenByToken(token string) (entity.RefreshToken, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.RefreshToken{}, fmt.Errorf("database connection is nil")
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return entity.RefreshToken{}, err
 	}
 
 	// Retrieve the token by token string from the repository
@@ -76,43 +94,71 @@ func (s *refreshTokenService) VerifyExpirationDate(exp time.Time) (bool, error)
 	}
 
 	// Check if the expiration date is in the past
-	if time.Now().After(exp) {
+	if s.clock.Now().After(exp) {
 		return false, nil
 	}
 
 	return true, nil
 }
 
-// CreateRefreshToken creates a new refresh token for the user in the database.
-// If a refresh token already exists for the user, it will be removed before creating a new one,
-// ensuring that only one refresh token exists for each user at a time.
-func (s *refreshTokenService) CreateRefreshToken(userID int64) (entity.RefreshToken, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.RefreshToken{}, fmt.Errorf("database connection is nil")
+// CreateRefreshToken creates a new refresh token, i.e. a new session, for the user in the database.
+// The number of active sessions of the user is limited (see GetMaxSessionsPerUser): when the limit is reached,
+// the oldest sessions are evicted or ErrSessionLimitReached is returned, depending on the session limit policy.
+// The user row is locked while counting and inserting, so concurrent logins cannot exceed the limit.
+// The client address and user agent are recorded with the session, so the user can tell the sessions apart.
+func (s *refreshTokenService) CreateRefreshToken(userID int64, ipAddress string, userAgent string) (entity.RefreshToken, error) {
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return entity.RefreshToken{}, err
 	}
 
 	createdRefreshToken := entity.RefreshToken{}
-	err := db.Transaction(func(tx *gorm.DB) error {
-		// Check if the refresh token already exists for the user
-		existingRefreshToken, err := s.repo.GetRefreshTokenByUserID(tx, userID)
-		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Lock the user, so the sessions of the user are counted and created by one transaction at a time
+		userRepo := repository.NewUserRepository()
+		user, err := userRepo.GetUserByIDForUpdate(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		// Expired sessions do not count towards the limit
+		now := s.clock.Now().UTC()
+		if _, err := s.repo.RemoveExpiredRefreshTokensByUserID(tx, userID, now); err != nil {
+			return err
+		}
+
+		// Check the number of active sessions against the limit of the user
+		sessions, err := s.repo.GetRefreshTokensByUserID(tx, userID)
+		if err != nil {
 			return err
 		}
 
-		// If the refresh token already exists, remove it
-		if !existingRefreshToken.Equals(&entity.RefreshToken{}) {
-			if _, err := s.repo.RemoveRefreshTokenByUserID(tx, userID); err != nil {
-				return err
+		limit := GetMaxSessionsPerUser()
+		if user.MaxSessions != nil {
+			limit = *user.MaxSessions
+		}
+
+		if limit > 0 && len(sessions) >= limit {
+			if GetSessionLimitPolicy() != SessionLimitPolicyEvictOldest {
+				return ErrSessionLimitReached
+			}
+
+			// Evict the oldest sessions to make room for the new one
+			for _, session := range sessions[:len(sessions)-limit+1] {
+				if _, err := s.repo.RemoveRefreshTokenByToken(tx, session.Token); err != nil {
+					return err
+				}
 			}
 		}
 
 		// Create a new refresh token
-		tokenStr := uuid.New().String()
 		refreshToken := entity.RefreshToken{
-			Token:      tokenStr,
+			Token:      uuid.New().String(),
+			SessionID:  uuid.New().String(),
 			UserID:     userID,
-			ExpiryDate: GetRefreshTokenExpiration(time.Now()),
+			IPAddress:  ipAddress,
+			UserAgent:  userAgent,
+			ExpiryDate: GetRefreshTokenExpiration(now),
 		}
 
 		// Create the refresh token in the database
@@ -131,6 +177,57 @@ func (s *refreshTokenService) CreateRefreshToken(userID int64) (entity.RefreshTo
 	return createdRefreshToken, nil
 }
 
+// RotateRefreshToken replaces the given refresh token with a new one for the same session.
+// The session count of the user is unchanged, and a refresh token can only be rotated once.
+func (s *refreshTokenService) RotateRefreshToken(token string) (entity.RefreshToken, error) {
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return entity.RefreshToken{}, err
+	}
+
+	createdRefreshToken := entity.RefreshToken{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Check if the refresh token exists
+		existingRefreshToken, err := s.repo.GetRefreshTokenByToken(tx, token)
+		if err != nil {
+			return err
+		}
+
+		// Remove the refresh token, if it was already removed by a concurrent rotation, it is no longer valid
+		removed, err := s.repo.RemoveRefreshTokenByToken(tx, token)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return gorm.ErrRecordNotFound
+		}
+
+		// Create the new refresh token of the session, the session itself is unchanged
+		refreshToken := entity.RefreshToken{
+			Token:      uuid.New().String(),
+			SessionID:  existingRefreshToken.SessionID,
+			UserID:     existingRefreshToken.UserID,
+			IPAddress:  existingRefreshToken.IPAddress,
+			UserAgent:  existingRefreshToken.UserAgent,
+			ExpiryDate: GetRefreshTokenExpiration(s.clock.Now().UTC()),
+			CreatedAt:  existingRefreshToken.CreatedAt,
+		}
+
+		createdRefreshToken, err = s.repo.CreateRefreshToken(tx, refreshToken)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return entity.RefreshToken{}, err
+	}
+
+	return createdRefreshToken, nil
+}
+
 // GetRefreshTokenExpiration calculates the expiration date for the refresh token.
 // It retrieves the expiration hour from an environment variable and adds it to the current time.
 func GetRefreshTokenExpiration(now time.Time) time.Time {
@@ -144,3 +241,24 @@ func GetRefreshTokenExpiration(now time.Time) time.Time {
 
 	return now.Add(time.Hour * time.Duration(expHour))
 }
+
+// GetMaxSessionsPerUser returns the global maximum number of active sessions per user.
+// It retrieves the limit from an environment variable, 0 means unlimited.
+func GetMaxSessionsPerUser() int {
+	limit, err := strconv.Atoi(os.Getenv("MAX_SESSIONS_PER_USER"))
+	if err != nil || limit < 0 {
+		return defaultMaxSessionsPerUser // Default to 3 sessions if the environment variable is not set or invalid
+	}
+
+	return limit
+}
+
+// GetSessionLimitPolicy returns the policy applied when the session limit is reached.
+// It retrieves the policy from an environment variable and defaults to rejecting the login.
+func GetSessionLimitPolicy() string {
+	if strings.ToUpper(os.Getenv("SESSION_LIMIT_POLICY")) == SessionLimitPolicyEvictOldest {
+		return SessionLimitPolicyEvictOldest
+	}
+
+	return SessionLimitPolicyReject
+}
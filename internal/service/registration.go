@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+)
+
+const (
+	// defaultRegistrationRateLimit is the default number of registrations a client address may send per hour
+	defaultRegistrationRateLimit = 5
+)
+
+var (
+	// ErrCaptchaRejected is returned when an anonymous registration does not pass the captcha verification.
+	ErrCaptchaRejected = errors.New("captcha verification failed")
+
+	// ErrRegistrationNotPending is returned when the registration to approve or reject was already decided.
+	ErrRegistrationNotPending = errors.New("registration is not pending approval")
+
+	// ErrEmailAlreadyVerified is returned when the email of the registered account to verify is verified already.
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+)
+
+// CaptchaVerifier verifies the captcha solved by an anonymous client registering an account,
+// e.g. against the API of the captcha provider. It returns an error if the token is missing, wrong or expired.
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token string, remoteIP string) error
+}
+
+var (
+	captchaMu       sync.RWMutex
+	captchaVerifier CaptchaVerifier
+)
+
+// SetCaptchaVerifier sets the verifier of the captcha of the anonymous registrations, nil disables the verification.
+func SetCaptchaVerifier(verifier CaptchaVerifier) {
+	captchaMu.Lock()
+	defer captchaMu.Unlock()
+
+	captchaVerifier = verifier
+}
+
+// verifyCaptcha checks the captcha token of an anonymous registration with the verifier set with SetCaptchaVerifier.
+// Every token passes when no verifier is set.
+func verifyCaptcha(ctx context.Context, token string, remoteIP string) error {
+	captchaMu.RLock()
+	verifier := captchaVerifier
+	captchaMu.RUnlock()
+
+	if verifier == nil {
+		return nil
+	}
+	if err := verifier.Verify(ctx, token, remoteIP); err != nil {
+		return fmt.Errorf("%w: %v", ErrCaptchaRejected, err)
+	}
+	return nil
+}
+
+// Interface for registration service
+// This interface defines the methods that the registration service should implement
+type RegistrationService interface {
+	GetPendingRegistrations(ctx context.Context, page int, limit int) ([]entity.PendingRegistrationResponse, int64, error)
+	ApproveRegistration(ctx context.Context, userID int64) (entity.User, error)
+	RejectRegistration(ctx context.Context, userID int64) error
+	ForceEmailVerification(ctx context.Context, userID int64) (entity.UserRegistration, error)
+}
+
+// This struct defines the RegistrationService that contains a repository field of type UserRegistrationRepository
+// It implements the RegistrationService interface and provides methods for the approval of the registered accounts
+type registrationService struct {
+	repo repository.UserRegistrationRepository
+}
+
+// NewRegistrationService creates a new instance of RegistrationService with the given repository.
+// It initializes the registrationService struct and returns it.
+func NewRegistrationService(repo repository.UserRegistrationRepository) RegistrationService {
+	return &registrationService{repo: repo}
+}
+
+// GetPendingRegistrations retrieves a page of the accounts of the tenant of the context waiting for an approval,
+// the oldest first, along with their total number.
+func (s *registrationService) GetPendingRegistrations(ctx context.Context, page int, limit int) ([]entity.PendingRegistrationResponse, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
+	registrations, err := s.repo.GetRegistrationsByStatus(db, entity.RegistrationStatusPendingApproval, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Attach the users of the page, in a single query
+	ids := make([]int64, 0, len(registrations))
+	for _, registration := range registrations {
+		ids = append(ids, registration.UserID)
+	}
+	users, _, err := repository.NewUserRepository().GetUsersByIDs(db, ids, true)
+	if err != nil {
+		return nil, 0, err
+	}
+	usersByID := make(map[int64]entity.User, len(users))
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+
+	pending := make([]entity.PendingRegistrationResponse, 0, len(registrations))
+	for _, registration := range registrations {
+		user, ok := usersByID[registration.UserID]
+		if !ok {
+			continue
+		}
+		pending = append(pending, entity.PendingRegistrationResponse{
+			User:          user.ToResponse(),
+			EmailVerified: registration.EmailVerified,
+			RegisteredAt:  registration.CreatedAt,
+		})
+	}
+
+	total, err := s.repo.CountRegistrationsByStatus(db, entity.RegistrationStatusPendingApproval)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return pending, total, nil
+}
+
+// ApproveRegistration approves the registration of an account pending approval and enables the account.
+// The approval is recorded in the audit log.
+func (s *registrationService) ApproveRegistration(ctx context.Context, userID int64) (entity.User, error) {
+	meta, db, err := s.decisionContext(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	approvedUser := entity.User{}
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		user, registration, err := s.getPendingRegistration(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		enabled := true
+		user.IsEnabled = &enabled
+		approvedUser, err = repository.NewUserRepository().UpdateUser(tx, user)
+		if err != nil {
+			return err
+		}
+
+		if err := s.decide(tx, meta, registration, entity.RegistrationStatusApproved); err != nil {
+			return err
+		}
+
+		return recordAccountAudit(tx, meta, approvedUser, "approve_registration", "Registration approved, account enabled")
+	})
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	logger.Info(fmt.Sprintf("Registration of user %d approved by %s", userID, meta.Actor()), logrus.Fields{
+		"userID":  userID,
+		"actor":   meta.Actor(),
+		"tokenID": meta.TokenID,
+	})
+	return approvedUser, nil
+}
+
+// RejectRegistration rejects the registration of an account pending approval. The account stays disabled
+// and is anonymized rather than deleted, so its row and the references to it are kept while its personal data is not,
+// and its username and email can be registered again. The rejection is recorded in the audit log.
+func (s *registrationService) RejectRegistration(ctx context.Context, userID int64) error {
+	meta, db, err := s.decisionContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		user, registration, err := s.getPendingRegistration(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		anonymized, err := repository.NewUserRepository().AnonymizeUser(tx, user)
+		if err != nil {
+			return err
+		}
+
+		if err := s.decide(tx, meta, registration, entity.RegistrationStatusRejected); err != nil {
+			return err
+		}
+
+		return recordAccountAudit(tx, meta, anonymized, "reject_registration", "Registration rejected, account anonymized")
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info(fmt.Sprintf("Registration of user %d rejected by %s", userID, meta.Actor()), logrus.Fields{
+		"userID":  userID,
+		"actor":   meta.Actor(),
+		"tokenID": meta.TokenID,
+	})
+	return nil
+}
+
+// ForceEmailVerification marks the email of a registered account as verified by the admin of the context, e.g. when
+// the user cannot verify it. It returns gorm.ErrRecordNotFound if the user does not exist or was not registered
+// through POST /auth/register, and ErrEmailAlreadyVerified if the email is verified already.
+// The manual override is recorded in the audit log.
+func (s *registrationService) ForceEmailVerification(ctx context.Context, userID int64) (entity.UserRegistration, error) {
+	meta, db, err := s.decisionContext(ctx)
+	if err != nil {
+		return entity.UserRegistration{}, err
+	}
+
+	verified := entity.UserRegistration{}
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		user, err := repository.NewUserRepository().GetUserByIDForUpdate(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		registration, err := s.repo.GetRegistrationByUserIDForUpdate(tx, userID)
+		if err != nil {
+			return err
+		}
+		if registration.EmailVerified {
+			return ErrEmailAlreadyVerified
+		}
+
+		registration.EmailVerified = true
+		verified, err = s.repo.UpdateRegistration(tx, registration)
+		if err != nil {
+			return err
+		}
+
+		return recordAccountAudit(tx, meta, user, "force_email_verification",
+			fmt.Sprintf("Email %s marked as verified by an admin, without a verification by the user", user.Email))
+	})
+	if err != nil {
+		return entity.UserRegistration{}, err
+	}
+
+	logger.Info(fmt.Sprintf("Email of user %d verified by %s", userID, meta.Actor()), logrus.Fields{
+		"userID":  userID,
+		"actor":   meta.Actor(),
+		"tokenID": meta.TokenID,
+	})
+	return verified, nil
+}
+
+// decisionContext returns the admin deciding a registration and the database of the context.
+func (s *registrationService) decisionContext(ctx context.Context) (metacontext.UserInformationMeta, *gorm.DB, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return metacontext.UserInformationMeta{}, nil, err
+	}
+
+	// The decision must be performed by a user, who is recorded along with it
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return metacontext.UserInformationMeta{}, nil, fmt.Errorf("missing user context")
+	}
+
+	return meta, db, nil
+}
+
+// getPendingRegistration locks the user of the tenant of the context and its registration, which must be pending.
+// It returns gorm.ErrRecordNotFound if the user does not exist or was not registered through POST /auth/register.
+func (s *registrationService) getPendingRegistration(tx *gorm.DB, userID int64) (entity.User, entity.UserRegistration, error) {
+	user, err := repository.NewUserRepository().GetUserByIDForUpdate(tx, userID)
+	if err != nil {
+		return entity.User{}, entity.UserRegistration{}, err
+	}
+
+	registration, err := s.repo.GetRegistrationByUserIDForUpdate(tx, userID)
+	if err != nil {
+		return entity.User{}, entity.UserRegistration{}, err
+	}
+	if registration.Status != entity.RegistrationStatusPendingApproval {
+		return entity.User{}, entity.UserRegistration{}, fmt.Errorf("%w: the registration is %s", ErrRegistrationNotPending, registration.Status)
+	}
+
+	return user, registration, nil
+}
+
+// decide records the decision of the admin of the context on a registration.
+func (s *registrationService) decide(tx *gorm.DB, meta metacontext.UserInformationMeta, registration entity.UserRegistration, status string) error {
+	decidedBy := meta.UserID
+	decidedAt := time.Now().UTC()
+	registration.Status = status
+	registration.DecidedBy = &decidedBy
+	registration.DecidedAt = &decidedAt
+
+	_, err := s.repo.UpdateRegistration(tx, registration)
+	return err
+}
+
+// IsRegistrationApprovalRequired reports whether the registered accounts wait for the approval of an admin.
+// It retrieves the toggle from an environment variable, the accounts are only enabled at once if it is FALSE.
+func IsRegistrationApprovalRequired() bool {
+	return strings.ToUpper(os.Getenv("REGISTRATION_APPROVAL_REQUIRED")) != "FALSE"
+}
+
+// GetRegistrationRateLimit returns the number of registrations a client address may send per hour.
+// It retrieves the limit from an environment variable, 0 disables the limit.
+func GetRegistrationRateLimit() int {
+	limit, err := strconv.Atoi(os.Getenv("REGISTRATION_RATE_LIMIT"))
+	if err != nil || limit < 0 {
+		return defaultRegistrationRateLimit // Default to 5 registrations per hour if the environment variable is not set or invalid
+	}
+
+	return limit
+}
@@ -1,11 +1,10 @@
 package service
 
 import (
-	"fmt"
-
 	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
 )
 
 // Interface for role service
@@ -29,9 +28,9 @@ func NewRoleService(repo repository.RoleRepository) RoleService {
 
 // GetRoleByID retrieves a role by its ID from the database.
 func (s *roleService) GetRoleByID(id uint) (entity.Role, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.Role{}, fmt.Errorf("database connection is nil")
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return entity.Role{}, err
 	}
 
 	// Retrieve the role by ID from the repository
@@ -43,15 +42,15 @@ func (s *roleService) GetRoleByID(id uint) (entity.Role, error) {
 	return role, nil
 }
 
-// GetRoleByName retrieves a role by its name from the database.
+// GetRoleByName retrieves a role by its name from the database, the name is normalized to uppercase.
 func (s *roleService) GetRoleByName(name string) (entity.Role, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.Role{}, fmt.Errorf("database connection is nil")
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return entity.Role{}, err
 	}
 
 	// Retrieve the role by name from the repository
-	role, err := s.repo.GetRoleByName(db, name)
+	role, err := s.repo.GetRoleByName(db, validation.NormalizeRoleName(name))
 	if err != nil {
 		return entity.Role{}, err
 	}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+)
+
+// ErrSessionAccessForbidden is returned when the caller manages the sessions of another user without being an admin.
+var ErrSessionAccessForbidden = errors.New("not allowed to manage the sessions of another user")
+
+// Interface for session service
+// This interface defines the methods that the session service should implement
+type SessionService interface {
+	GetUserSessions(ctx context.Context, userID int64) ([]entity.SessionResponse, error)
+	RevokeUserSession(ctx context.Context, userID int64, sessionID string) error
+}
+
+// This struct defines the SessionService that contains a repository field of type RefreshTokenRepository
+// It implements the SessionService interface and provides methods for session-related operations
+type sessionService struct {
+	repo repository.RefreshTokenRepository
+}
+
+// NewSessionService creates a new instance of SessionService with the given repository.
+// It initializes the sessionService struct and returns it.
+func NewSessionService(repo repository.RefreshTokenRepository) SessionService {
+	return &sessionService{repo: repo}
+}
+
+// GetUserSessions retrieves the active sessions of a user, i.e. its refresh tokens that are neither revoked nor expired,
+// the most recent first. The callers may only list their own sessions, unless they are admins.
+func (s *sessionService) GetUserSessions(ctx context.Context, userID int64) ([]entity.SessionResponse, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	db = db.WithContext(ctx)
+
+	if err := checkSessionAccess(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	// Check if the user exists in the tenant of the caller
+	if _, err := repository.NewUserRepository().GetUserByID(db, userID); err != nil {
+		return nil, err
+	}
+
+	// Retrieve the unexpired refresh tokens of the user, the revoked ones are already removed
+	refreshTokens, err := s.repo.GetActiveRefreshTokensByUserID(db, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]entity.SessionResponse, 0, len(refreshTokens))
+	for _, refreshToken := range refreshTokens {
+		sessions = append(sessions, refreshToken.ToSession())
+	}
+
+	return sessions, nil
+}
+
+// RevokeUserSession revokes a session of a user by removing its refresh token, the session can no longer be refreshed.
+// The callers may only revoke their own sessions, unless they are admins.
+// It returns gorm.ErrRecordNotFound if the user does not exist or has no such session.
+func (s *sessionService) RevokeUserSession(ctx context.Context, userID int64, sessionID string) error {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return err
+	}
+	db = db.WithContext(ctx)
+
+	if err := checkSessionAccess(ctx, userID); err != nil {
+		return err
+	}
+	meta, _ := metacontext.ExtractUserInformationMeta(ctx)
+
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Check if the user exists in the tenant of the caller
+		if _, err := repository.NewUserRepository().GetUserByID(tx, userID); err != nil {
+			return err
+		}
+
+		removed, err := s.repo.RemoveRefreshTokenBySessionID(tx, userID, sessionID)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info(fmt.Sprintf("Session %s of user %d revoked by %s", sessionID, userID, meta.Actor()), logrus.Fields{
+		"userID":    userID,
+		"sessionID": sessionID,
+		"revokedBy": meta.UserID,
+		"actor":     meta.Actor(),
+		"tokenID":   meta.TokenID,
+	})
+	return nil
+}
+
+// checkSessionAccess returns ErrSessionAccessForbidden if the caller of the context may not manage the sessions of the user.
+// The users manage their own sessions, the admins and the system actors the sessions of anyone.
+func checkSessionAccess(ctx context.Context, userID int64) error {
+	return checkUserAccess(ctx, userID, ErrSessionAccessForbidden)
+}
+
+// checkUserAccess returns the forbidden error if the caller of the context is neither the user, an admin, nor a system actor.
+func checkUserAccess(ctx context.Context, userID int64, forbidden error) error {
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return fmt.Errorf("missing user context")
+	}
+
+	if meta.IsSystem || meta.UserID == userID ||
+		slices.Contains(meta.Roles, adminRole) || slices.Contains(meta.Roles, superAdminRole) {
+		return nil
+	}
+
+	return forbidden
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+)
+
+// Interface for tenant service
+// This interface defines the methods that the tenant service should implement
+type TenantService interface {
+	IsMember(ctx context.Context, userID int64, tenantID int64) (bool, error)
+}
+
+// This struct defines the TenantService that contains a repository field of type TenantRepository
+// It implements the TenantService interface and provides methods for tenant-related operations
+type tenantService struct {
+	repo repository.TenantRepository
+}
+
+// NewTenantService creates a new instance of TenantService with the given repository.
+// It initializes the tenantService struct and returns it.
+func NewTenantService(repo repository.TenantRepository) TenantService {
+	return &tenantService{repo: repo}
+}
+
+// IsMember reports whether the user belongs to the tenant, either as its own tenant or through a membership.
+func (s *tenantService) IsMember(ctx context.Context, userID int64, tenantID int64) (bool, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return s.repo.IsMember(db.WithContext(ctx), userID, tenantID)
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+)
+
+// ErrUserNoteDeleteForbidden is returned when the caller deletes a note it did not write without being a super admin.
+var ErrUserNoteDeleteForbidden = errors.New("only the author or a super admin may delete the note")
+
+// Interface for user note service
+// This interface defines the methods that the user note service should implement
+type UserNoteService interface {
+	GetUserNotes(ctx context.Context, userID int64, page int, limit int) ([]entity.UserNote, int64, error)
+	CreateUserNote(ctx context.Context, userID int64, req entity.UserNoteRequest) (entity.UserNote, error)
+	DeleteUserNote(ctx context.Context, userID int64, noteID int64) error
+}
+
+// This struct defines the UserNoteService that contains a repository field of type UserNoteRepository
+// It implements the UserNoteService interface and provides methods for user note-related operations
+type userNoteService struct {
+	repo repository.UserNoteRepository
+}
+
+// NewUserNoteService creates a new instance of UserNoteService with the given repository.
+// It initializes the userNoteService struct and returns it.
+func NewUserNoteService(repo repository.UserNoteRepository) UserNoteService {
+	return &userNoteService{repo: repo}
+}
+
+// GetUserNotes retrieves the notes of a user along with their total number, the pinned notes first, then the most recent first.
+// The user must belong to the tenant of the context, the deleted users keep their notes.
+func (s *userNoteService) GetUserNotes(ctx context.Context, userID int64, page int, limit int) ([]entity.UserNote, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	db = db.WithContext(ctx)
+
+	// Check if the user exists in the tenant of the caller
+	if _, err := repository.NewUserRepository().GetUserByID(db, userID, repository.WithDeleted()); err != nil {
+		return nil, 0, err
+	}
+
+	notes, err := s.repo.GetUserNotes(db, userID, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Count the notes of the user for the pagination metadata
+	total, err := s.repo.CountUserNotes(db, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return notes, total, nil
+}
+
+// CreateUserNote adds a note to the account of a user, written by the caller of the context.
+// The note is recorded in the audit log along with its body.
+func (s *userNoteService) CreateUserNote(ctx context.Context, userID int64, req entity.UserNoteRequest) (entity.UserNote, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.UserNote{}, err
+	}
+
+	// Get the author of the note from the context
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.UserNote{}, fmt.Errorf("missing user context")
+	}
+
+	var note entity.UserNote
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Check if the user exists in the tenant of the caller
+		user, err := repository.NewUserRepository().GetUserByID(tx, userID, repository.WithDeleted())
+		if err != nil {
+			return err
+		}
+
+		note, err = s.repo.CreateUserNote(tx, entity.UserNote{
+			UserID:    user.ID,
+			AuthorID:  meta.UserID,
+			Body:      req.Body,
+			Pinned:    req.Pinned,
+			CreatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+
+		return recordAccountAudit(tx, meta, user, "create_note", fmt.Sprintf("Note %d added: %s", note.ID, note.Body))
+	})
+	if err != nil {
+		return entity.UserNote{}, err
+	}
+
+	logger.Info(fmt.Sprintf("Note %d added to user %d by %s", note.ID, userID, meta.Actor()), logrus.Fields{
+		"userID":    userID,
+		"noteID":    note.ID,
+		"createdBy": meta.UserID,
+		"actor":     meta.Actor(),
+		"tokenID":   meta.TokenID,
+	})
+	return note, nil
+}
+
+// DeleteUserNote deletes a note of a user, only its author or a super admin may delete it.
+// It returns gorm.ErrRecordNotFound if the user does not exist or has no such note.
+// The deletion is recorded in the audit log along with the body of the note.
+func (s *userNoteService) DeleteUserNote(ctx context.Context, userID int64, noteID int64) error {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return err
+	}
+
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return fmt.Errorf("missing user context")
+	}
+
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Check if the user exists in the tenant of the caller
+		user, err := repository.NewUserRepository().GetUserByID(tx, userID, repository.WithDeleted())
+		if err != nil {
+			return err
+		}
+
+		note, err := s.repo.GetUserNoteByID(tx, userID, noteID)
+		if err != nil {
+			return err
+		}
+		if note.AuthorID != meta.UserID && !slices.Contains(meta.Roles, superAdminRole) {
+			return ErrUserNoteDeleteForbidden
+		}
+
+		if err := s.repo.DeleteUserNote(tx, note); err != nil {
+			return err
+		}
+
+		return recordAccountAudit(tx, meta, user, "delete_note", fmt.Sprintf("Note %d deleted: %s", note.ID, note.Body))
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info(fmt.Sprintf("Note %d of user %d deleted by %s", noteID, userID, meta.Actor()), logrus.Fields{
+		"userID":    userID,
+		"noteID":    noteID,
+		"deletedBy": meta.UserID,
+		"actor":     meta.Actor(),
+		"tokenID":   meta.TokenID,
+	})
+	return nil
+}
@@ -1,22 +1,138 @@
 package service
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/sirupsen/logrus"
+
 	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/featureflag"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/hash"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
 	"gorm.io/gorm"
 )
 
+const (
+	// defaultPasswordHistorySize is the default number of recent passwords a user cannot reuse
+	defaultPasswordHistorySize = 5
+
+	// defaultSelfRegistrationRole is the default role of the accounts created through the self-registration
+	defaultSelfRegistrationRole = "ROLE_USER"
+
+	// duplicateUsernameSimilarity is the minimum trigram similarity of the usernames of two namesakes
+	// for them to be reported as duplicates, it is the default similarity threshold of pg_trgm
+	duplicateUsernameSimilarity = 0.3
+
+	// defaultUserPurgeRetentionDays is the default number of days a deleted user is kept before it can be purged
+	defaultUserPurgeRetentionDays = 30
+
+	// userStreamBatchSize is the number of users fetched at once by a streamed listing
+	userStreamBatchSize = 100
+
+	// defaultUserExportBatchSize is the default number of users fetched at once by the export of the users
+	defaultUserExportBatchSize = 500
+
+	// userStreamDeadlineShare is the share of the time left before the request deadline a streamed listing
+	// spends fetching users, the rest is kept to write them and close the response
+	userStreamDeadlineShare = 0.8
+
+	// credentialExpiryBatchSize is the number of holders of a role whose credentials are expired in a single transaction
+	credentialExpiryBatchSize = 100
+
+	// roleReassignmentBatchSize is the number of holders of a role moved to another role in a single transaction
+	roleReassignmentBatchSize = 100
+
+	// adminRole and superAdminRole are the admin-level roles, only granted and removed by the admins
+	adminRole      = "ROLE_ADMIN"
+	superAdminRole = "ROLE_SUPER_ADMIN"
+)
+
+var (
+	// ErrUserAlreadyExists is returned when the username or the email is already taken by another user.
+	ErrUserAlreadyExists = errors.New("username or email already exists")
+
+	// ErrUnknownRole is returned when a requested role does not exist.
+	ErrUnknownRole = errors.New("unknown role")
+
+	// ErrInvalidRoleName is returned when a requested role name does not follow the ROLE_<NAME> convention.
+	ErrInvalidRoleName = errors.New("invalid role name")
+
+	// ErrPasswordReused is returned when the new password matches one of the recent passwords of the user.
+	ErrPasswordReused = errors.New("password was used recently")
+
+	// ErrUserNotDeleted is returned when a user to purge has not been deleted first.
+	ErrUserNotDeleted = errors.New("user is not deleted")
+
+	// ErrUserDeletedRecently is returned when a user to purge was deleted within the retention period.
+	ErrUserDeletedRecently = errors.New("user was deleted within the retention period")
+
+	// ErrRoleAssignmentForbidden is returned when the caller may not grant or remove an admin-level role.
+	ErrRoleAssignmentForbidden = errors.New("not allowed to assign the role")
+
+	// ErrSameRole is returned when the holders of a role are reassigned to the same role.
+	ErrSameRole = errors.New("a role cannot be reassigned to itself")
+
+	// ErrLastAdmin is returned when an operation would leave no enabled admin, e.g. demoting or disabling the last one.
+	ErrLastAdmin = errors.New("the last enabled admin cannot be demoted")
+
+	// ErrMergeIntoSelf is returned when a user is merged into itself
+	ErrMergeIntoSelf = errors.New("a user cannot be merged into itself")
+
+	// ErrMergeDeletedUser is returned when the source or the target of a merge is deleted
+	ErrMergeDeletedUser = errors.New("a deleted user cannot be merged")
+
+	// ErrInvalidCursor is returned when the cursor of a streamed listing was not issued by it
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrWeakPassword is returned when the strict password policy is enabled and the password does not meet it.
+	ErrWeakPassword = fmt.Errorf("password must be at least %d characters long and mix lowercase and uppercase letters, digits and symbols",
+		validation.MinStrongPasswordLength)
+)
+
 // Interface for user service
 // This interface defines the methods that the user service should implement
 type UserService interface {
 	GetUserByID(id int64) (entity.User, error)
+	GetUsersByIDs(ctx context.Context, ids []int64, withRoles bool) ([]entity.User, []int64, error)
 	GetUserByUsername(username string) (entity.User, error)
 	GetUserByEmail(email string) (entity.User, error)
+	CreateUser(ctx context.Context, req entity.UserCreateRequest) (entity.User, error)
+	RegisterUser(ctx context.Context, req entity.UserRegisterRequest) (entity.User, error)
 	UpdateLastLogin(id int64, lastLogin time.Time) (bool, error)
+	UpdateUserStatus(ctx context.Context, id int64, req entity.UserStatusRequest) (entity.User, error)
+	UpdateUserSessionLimit(ctx context.Context, id int64, maxSessions *int) (entity.User, error)
+	UpdateUserMetadata(ctx context.Context, id int64, req entity.UserMetadataRequest) (entity.User, error)
+	ResetUserPassword(ctx context.Context, id int64, password string) (entity.User, error)
+	ForcePasswordChange(ctx context.Context, id int64) (entity.User, error)
+	ExpireUserCredentials(ctx context.Context, id int64) (entity.User, error)
+	ExpireRoleCredentials(ctx context.Context, roleName string) (entity.CredentialExpiryResult, error)
+	ReassignRole(ctx context.Context, req entity.RoleReassignmentRequest) (entity.RoleReassignmentResult, error)
+	UpdateUserRoles(ctx context.Context, id int64, req entity.UserRolesRequest) (entity.User, error)
+	PatchUserRoles(ctx context.Context, id int64, req entity.UserRolesPatchRequest) (entity.User, error)
+	BulkDeleteUsers(ctx context.Context, ids []int64) ([]entity.UserBulkDeleteResult, error)
+	MergeUsers(ctx context.Context, targetID int64, req entity.UserMergeRequest) (entity.UserMergeResult, error)
+	GetUsersByMetadata(ctx context.Context, key string, value string) ([]entity.User, error)
+	GetUsers(ctx context.Context, filter entity.UserFilter, includeDeleted bool, page int, limit int) ([]entity.User, int64, error)
+	StreamUsers(ctx context.Context, includeDeleted bool, cursor string, limit int, emit func([]entity.User) error) (string, bool, error)
+	ExportUsers(ctx context.Context, updatedSince *time.Time, includeDeleted bool, emit func([]entity.User) error) error
+	GetDeletedUsers(ctx context.Context, page int, limit int) ([]entity.User, int64, error)
+	GetDuplicateUsers(ctx context.Context, page int, limit int) ([]entity.DuplicateUserCluster, int64, error)
+	GetUserCountsByRole(ctx context.Context) (map[string]int64, error)
+	GetUserSummary(ctx context.Context) (entity.UserSummary, error)
+	PurgeUser(ctx context.Context, id int64) error
 }
 
 // This struct defines the UserService that contains a repository field of type UserRepository
@@ -33,9 +149,9 @@ func NewUserService(repo repository.UserRepository) UserService {
 
 // GetUserByID retrieves a user by its ID from the database.
 func (s *userService) GetUserByID(id int64) (entity.User, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.User{}, fmt.Errorf("database connection is nil")
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return entity.User{}, err
 	}
 
 	// Retrieve the user by ID from the repository
@@ -47,11 +163,280 @@ func (s *userService) GetUserByID(id int64) (entity.User, error) {
 	return user, nil
 }
 
+// GetUsersByIDs retrieves the users with the given IDs from the database in the order of the IDs,
+// along with the IDs without a user. It is meant to resolve many IDs at once, e.g. the actors of a listing.
+func (s *userService) GetUsersByIDs(ctx context.Context, ids []int64, withRoles bool) ([]entity.User, []int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Retrieve all users in a single query
+	users, missing, err := s.repo.GetUsersByIDs(db.WithContext(ctx), ids, withRoles)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return users, missing, nil
+}
+
+// GetUsers retrieves a page of the users of the tenant of the context matching the filter, along with their total number.
+// The soft-deleted users are only included with includeDeleted, or with a ModifiedSince or UpdatedSince time: only the users
+// updated since it are then retrieved, so an integration can mirror the changes since its last synchronization.
+func (s *userService) GetUsers(ctx context.Context, filter entity.UserFilter, includeDeleted bool, page int, limit int) ([]entity.User, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
+	// The deletions are changes like any other for the delta synchronization
+	var opts []repository.ReadOption
+	if includeDeleted || filter.ModifiedSince != nil || filter.UpdatedSince != nil {
+		opts = append(opts, repository.WithDeleted())
+	}
+
+	// Retrieve the page of users from the repository
+	users, err := s.repo.GetUsers(db, filter, page, limit, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Count the users over all pages for the pagination metadata
+	total, err := s.repo.CountUsers(db, filter, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// StreamUsers retrieves up to limit users of the tenant of the context ordered by ID, after the cursor if one is given,
+// and hands them to emit batch by batch as soon as they are fetched.
+// When the context has a deadline, the listing stops fetching once most of the time left is spent,
+// or when a query is aborted by the deadline after some users were handed over, and reports itself as partial.
+// It returns the cursor resuming the listing after the last user handed over when more users may follow,
+// and whether the listing was cut short. A deadline reached before any user was handed over is returned as an error.
+func (s *userService) StreamUsers(ctx context.Context, includeDeleted bool, cursor string, limit int, emit func([]entity.User) error) (string, bool, error) {
+	afterID, err := decodeUserCursor(cursor)
+	if err != nil {
+		return "", false, err
+	}
+
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return "", false, err
+	}
+
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
+	var opts []repository.ReadOption
+	if includeDeleted {
+		opts = append(opts, repository.WithDeleted())
+	}
+
+	// Stop fetching early enough to hand the users over before the deadline
+	var stopAt time.Time
+	if deadline, ok := ctx.Deadline(); ok {
+		stopAt = time.Now().Add(time.Duration(float64(time.Until(deadline)) * userStreamDeadlineShare))
+	}
+
+	emitted := 0
+	for emitted < limit {
+		if emitted > 0 && !stopAt.IsZero() && time.Now().After(stopAt) {
+			return stopUserStream(afterID, emitted, "the deadline was near")
+		}
+
+		size := min(userStreamBatchSize, limit-emitted)
+		users, err := s.repo.GetUsersAfter(db, afterID, size, opts...)
+		if err != nil {
+			if emitted > 0 && errors.Is(err, context.DeadlineExceeded) {
+				return stopUserStream(afterID, emitted, "the deadline was reached")
+			}
+			return "", false, err
+		}
+		if len(users) == 0 {
+			return "", false, nil
+		}
+		if err := emit(users); err != nil {
+			return "", false, err
+		}
+		emitted += len(users)
+		afterID = users[len(users)-1].ID
+
+		// A short batch is the last one
+		if len(users) < size {
+			return "", false, nil
+		}
+	}
+
+	// The limit is reached, the next users may be listed with the cursor
+	return encodeUserCursor(afterID), false, nil
+}
+
+// ExportUsers retrieves every user of the tenant of the context ordered by ID, or only those updated strictly after updatedSince,
+// and hands them to emit batch by batch, so the memory used does not depend on the number of users.
+// Like the delta synchronization of GetUsers, the deleted users are included along with updatedSince.
+// It stops before the next batch once the context is done, e.g. when the client disconnects, and returns the error of the context.
+func (s *userService) ExportUsers(ctx context.Context, updatedSince *time.Time, includeDeleted bool, emit func([]entity.User) error) error {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
+	var opts []repository.ReadOption
+	if includeDeleted || updatedSince != nil {
+		opts = append(opts, repository.WithDeleted())
+	}
+
+	exported := 0
+	err = s.repo.GetUsersInBatches(db, updatedSince, GetUserExportBatchSize(), func(users []entity.User) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := emit(users); err != nil {
+			return err
+		}
+		exported += len(users)
+		return nil
+	}, opts...)
+	if err != nil {
+		logger.Warn(fmt.Sprintf("User export stopped: %v", err), logrus.Fields{
+			"exported": exported,
+		})
+		return err
+	}
+
+	logger.Info("Users exported", logrus.Fields{
+		"exported": exported,
+	})
+	return nil
+}
+
+// stopUserStream returns the cursor resuming a streamed listing cut short after the given user ID.
+func stopUserStream(afterID int64, emitted int, reason string) (string, bool, error) {
+	logger.Warn(fmt.Sprintf("User listing stopped early because %s", reason), logrus.Fields{
+		"emitted": emitted,
+		"afterId": afterID,
+	})
+	return encodeUserCursor(afterID), true, nil
+}
+
+// encodeUserCursor returns the opaque cursor of a streamed listing resuming after the given user ID.
+func encodeUserCursor(afterID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(afterID, 10)))
+}
+
+// decodeUserCursor returns the user ID a streamed listing resumes after, 0 for the empty cursor.
+func decodeUserCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	afterID, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || afterID < 1 {
+		return 0, ErrInvalidCursor
+	}
+
+	return afterID, nil
+}
+
+// GetDeletedUsers retrieves a page of the soft-deleted users of the tenant of the context, along with their total number.
+func (s *userService) GetDeletedUsers(ctx context.Context, page int, limit int) ([]entity.User, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
+	users, err := s.repo.GetDeletedUsers(db, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.repo.CountDeletedUsers(db)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// GetUserCountsByRole counts the users of the tenant of the context holding each role, by role name.
+// The soft-deleted users are not counted, and the roles without any user are left out.
+func (s *userService) GetUserCountsByRole(ctx context.Context) (map[string]int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.CountUsersByRole(db.WithContext(ctx))
+}
+
+// GetUserSummary counts the users of the tenant of the context by state, see entity.UserSummary.
+func (s *userService) GetUserSummary(ctx context.Context) (entity.UserSummary, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.UserSummary{}, err
+	}
+
+	return s.repo.GetUserSummary(db.WithContext(ctx))
+}
+
+// GetDuplicateUsers retrieves a page of the clusters of users of the tenant of the context that are likely
+// the same person, along with their total number. The soft-deleted users are never part of a cluster.
+func (s *userService) GetDuplicateUsers(ctx context.Context, page int, limit int) ([]entity.DuplicateUserCluster, int64, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Bind the queries to the request context, so they are aborted when the request is cancelled
+	db = db.WithContext(ctx)
+
+	clusters, err := s.repo.GetDuplicateUserClusters(db, duplicateUsernameSimilarity, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Attach the users of each cluster of the page
+	for i, cluster := range clusters {
+		users, err := s.repo.GetDuplicateClusterUsers(db, cluster, duplicateUsernameSimilarity)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		clusters[i].Users = make([]entity.UserResponse, 0, len(users))
+		for _, user := range users {
+			clusters[i].Users = append(clusters[i].Users, user.ToResponse())
+		}
+	}
+
+	total, err := s.repo.CountDuplicateUserClusters(db, duplicateUsernameSimilarity)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return clusters, total, nil
+}
+
 // GetUserByUsername retrieves a user by their username from the database.
 func (s *userService) GetUserByUsername(username string) (entity.User, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.User{}, fmt.Errorf("database connection is nil")
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return entity.User{}, err
 	}
 
 	// Retrieve the user by username from the repository
@@ -65,9 +450,9 @@ func (s *userService) GetUserByUsername(username string) (entity.User, error) {
 
 // GetUserByEmail retrieves a user by their email from the database.
 func (s *userService) GetUserByEmail(email string) (entity.User, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.User{}, fmt.Errorf("database connection is nil")
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return entity.User{}, err
 	}
 
 	// Retrieve the user by email from the repository
@@ -79,38 +464,1735 @@ func (s *userService) GetUserByEmail(email string) (entity.User, error) {
 	return user, nil
 }
 
-// UpdateLastLogin updates the last login time of a user in the database.
-func (s *userService) UpdateLastLogin(id int64, lastLogin time.Time) (bool, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return false, fmt.Errorf("database connection is nil")
+// CreateUser creates a new enabled user with a hashed password in a single transaction.
+// The user is created in the tenant of the context, where its username and email must be unique.
+// The default roles are attached when the request has no role, otherwise only the requested roles are.
+func (s *userService) CreateUser(ctx context.Context, req entity.UserCreateRequest) (entity.User, error) {
+	// Get the user performing the creation from the context
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.User{}, fmt.Errorf("missing user context")
 	}
 
-	err := db.Transaction(func(tx *gorm.DB) error {
-		// Check if the user exists
-		existingUser, err := s.repo.GetUserByID(db, id)
+	enabled := true
+	user := entity.User{
+		Username:  req.Username,
+		Email:     req.Email,
+		Firstname: req.Firstname,
+		Lastname:  req.Lastname,
+		IsEnabled: &enabled,
+		UserType:  req.UserType,
+	}
+
+	return s.createUser(ctx, user, req.Password, req.Roles, meta.Actor(), nil)
+}
+
+// RegisterUser creates a user account with the self-registration role in a single transaction, along with
+// its registration recording that its email is not verified yet. The caller is anonymous when the self-registration
+// is enabled, the account is then created in the default tenant and is its own creator, once it passed the captcha
+// if a verifier is set. When the approval of the registrations is required, the account stays disabled
+// and pending until an admin approves it, see RegistrationService.
+func (s *userService) RegisterUser(ctx context.Context, req entity.UserRegisterRequest) (entity.User, error) {
+	// An admin may register an account on behalf of someone when the self-registration is disabled,
+	// the admin is then recorded as the creator by the audit callbacks and needs no captcha
+	actor := "anonymous"
+	meta, authenticated := metacontext.ExtractUserInformationMeta(ctx)
+	if authenticated {
+		actor = meta.Actor()
+	} else if err := verifyCaptcha(ctx, req.CaptchaToken, req.IPAddress); err != nil {
+		return entity.User{}, err
+	}
+
+	// The account is enabled at once when no approval is required
+	status, enabled := entity.RegistrationStatusApproved, true
+	if IsRegistrationApprovalRequired() {
+		status, enabled = entity.RegistrationStatusPendingApproval, false
+	}
+
+	user := entity.User{
+		Username:  req.Username,
+		Email:     req.Email,
+		Firstname: req.Firstname,
+		Lastname:  req.Lastname,
+		IsEnabled: &enabled,
+		UserType:  "USER_ACCOUNT",
+	}
+
+	createdUser, err := s.createUser(ctx, user, req.Password, []string{GetSelfRegistrationRole()}, actor, func(tx *gorm.DB, created *entity.User) error {
+		// Nobody is authenticated to be recorded as the creator of an anonymous registration, the account is its own
+		if !authenticated {
+			created.CreatedBy = &created.ID
+			created.UpdatedBy = &created.ID
+			updated, err := s.repo.UpdateUser(tx, *created)
+			if err != nil {
+				return err
+			}
+			*created = updated
+		}
+
+		_, err := repository.NewUserRegistrationRepository().CreateRegistration(tx, entity.UserRegistration{
+			UserID:   created.ID,
+			TenantID: created.TenantID,
+			Status:   status,
+		})
+		return err
+	})
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	logger.Info(fmt.Sprintf("User %s registered", createdUser.Username), logrus.Fields{
+		"userID": createdUser.ID,
+		"actor":  actor,
+	})
+	return createdUser, nil
+}
+
+// createUser hashes the password and creates the user with the given roles in the tenant of the context.
+// The account flags other than IsEnabled are set, and the initial password is recorded in the password history.
+// The onCreated hook, if any, is called with the created user in the same transaction, e.g. to record its registration.
+func (s *userService) createUser(ctx context.Context, user entity.User, password string, roleNames []string, actor string, onCreated func(tx *gorm.DB, user *entity.User) error) (entity.User, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	// Enforce the strict password policy when its feature flag is enabled
+	if err := checkPasswordPolicy(ctx, password); err != nil {
+		return entity.User{}, err
+	}
+
+	// Hash the password before storing it, with the configured algorithm
+	hashedPassword, err := hash.FromEnv().Hash(password)
+	if err != nil {
+		return entity.User{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	// The user is created in the tenant of the request, where the username and the email must be unique
+	tenantID, ok := metacontext.ExtractTenantID(ctx)
+	if !ok {
+		tenantID = metacontext.DefaultTenantID
+	}
+
+	createdUser := entity.User{}
+	// The transaction is run again if it conflicts with a concurrent one, e.g. on the same username
+	err = database.TransactionWithRetry(metacontext.InjectTenantID(ctx, tenantID), db, func(tx *gorm.DB) error {
+		// Check if the username or the email is already taken
+		if _, err := s.repo.GetUserByUsername(tx, user.Username); err == nil {
+			return fmt.Errorf("%w: username %s", ErrUserAlreadyExists, user.Username)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if _, err := s.repo.GetUserByEmail(tx, user.Email); err == nil {
+			return fmt.Errorf("%w: email %s", ErrUserAlreadyExists, user.Email)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// Resolve the roles, falling back to the default roles
+		roles, err := resolveRoles(tx, roleNames)
 		if err != nil {
 			return err
 		}
+		if err := checkRoleAssignment(ctx, roles); err != nil {
+			return err
+		}
 
-		// Check if the existing user is empty
-		if (existingUser.Equals(&entity.User{})) {
-			return fmt.Errorf("user with ID %d not found", id)
+		active, deleted := true, false
+		user.TenantID = tenantID
+		user.Password = hashedPassword
+		user.IsAccountNonExpired = &active
+		user.IsAccountNonLocked = &active
+		user.IsCredentialsNonExpired = &active
+		user.IsDeleted = &deleted
+		user.Roles = roles
+
+		// The user must hold at least one role, e.g. when no default role is configured
+		if err := user.Validate(); err != nil {
+			return err
 		}
 
-		// Update the last login time
-		*existingUser.LastLogin = lastLogin
-		_, err = s.repo.UpdateUser(tx, existingUser)
+		createdUser, err = s.repo.CreateUser(tx, user)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			// A concurrent request created the same user after the checks above
+			return fmt.Errorf("%w: %v", ErrUserAlreadyExists, err)
+		}
 		if err != nil {
 			return err
 		}
 
+		// Record the initial password, so it cannot be reused later
+		if err := recordPassword(tx, createdUser.ID, createdUser.Password); err != nil {
+			return err
+		}
+
+		if onCreated != nil {
+			return onCreated(tx, &createdUser)
+		}
 		return nil
 	})
 
 	if err != nil {
-		return false, err
+		return entity.User{}, err
 	}
 
-	return true, nil
+	// The user is created anyway, but an email domain on the watchlist deserves a second look
+	if domain := emailDomain(createdUser.Email); slices.Contains(GetEmailDomainWatchlist(), domain) {
+		metacontext.AddWarning(ctx, fmt.Sprintf("the email domain %s is on the watchlist", domain))
+		logger.Warn(fmt.Sprintf("User created with a watchlisted email domain: %s", domain), logrus.Fields{
+			"userID": createdUser.ID,
+			"actor":  actor,
+		})
+	}
+
+	return createdUser, nil
+}
+
+// emailDomain returns the lowercase domain of an email address, or an empty string if there is none.
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
+
+// resolveRoles retrieves the roles with the given names, or the default roles if no name is given:
+// the configured default role if any, see GetDefaultUserRole, the roles flagged as default otherwise.
+// The names are resolved by resolveRoleNames, as the roles field of the request.
+func resolveRoles(tx *gorm.DB, names []string) ([]entity.Role, error) {
+	if len(names) > 0 {
+		return resolveRoleNames(tx, "roles", names)
+	}
+
+	roleRepo := repository.NewRoleRepository()
+	if name := GetDefaultUserRole(); name != "" {
+		// The role is checked at startup, it can only be missing if it was removed since
+		role, err := roleRepo.GetRoleByName(tx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the default role %s: %w", name, err)
+		}
+		return []entity.Role{role}, nil
+	}
+	return roleRepo.GetDefaultRoles(tx)
+}
+
+// resolveRoleNames retrieves the roles with the given names, listed by the given field of the request.
+// The names are normalized to uppercase. Every failing name is reported at once by a roleNamesError,
+// which wraps ErrInvalidRoleName for the names not following the convention, ErrUnknownRole for those not matching any role,
+// and the validation.FieldErrors of the names keyed by their path in the request, e.g. roles[1].
+func resolveRoleNames(tx *gorm.DB, field string, names []string) ([]entity.Role, error) {
+	roleRepo := repository.NewRoleRepository()
+
+	// The failure of every name by its index in the request
+	failures := make(map[int]string)
+
+	var invalid []string
+	normalized := make([]string, 0, len(names))
+	indexes := make(map[string]int, len(names))
+	for i, name := range names {
+		if !validation.IsValidRoleName(name) {
+			invalid = append(invalid, strconv.Quote(name))
+			failures[i] = "must be a role name such as ROLE_USER"
+			continue
+		}
+		if name = validation.NormalizeRoleName(name); !slices.Contains(normalized, name) {
+			normalized = append(normalized, name)
+			indexes[name] = i
+		}
+	}
+
+	// Retrieve all the roles in a single query, rather than one query per role
+	var found []entity.Role
+	if len(normalized) > 0 {
+		var err error
+		if found, err = roleRepo.GetRolesByNames(tx, normalized); err != nil {
+			return nil, err
+		}
+	}
+
+	byName := make(map[string]entity.Role, len(found))
+	for _, role := range found {
+		byName[strings.ToUpper(role.Name)] = role
+	}
+
+	// Keep the order of the request
+	roles := make([]entity.Role, 0, len(normalized))
+	missing := []string{}
+	for _, name := range normalized {
+		role, ok := byName[name]
+		if !ok {
+			missing = append(missing, name)
+			failures[indexes[name]] = fmt.Sprintf("must be an existing role, %s does not exist", name)
+			continue
+		}
+		roles = append(roles, role)
+	}
+
+	if len(failures) > 0 {
+		roleErr := &roleNamesError{}
+		if len(invalid) > 0 {
+			roleErr.errs = append(roleErr.errs, fmt.Errorf("%w: %s", ErrInvalidRoleName, strings.Join(invalid, ", ")))
+		}
+		if len(missing) > 0 {
+			roleErr.errs = append(roleErr.errs, fmt.Errorf("%w: %s", ErrUnknownRole, strings.Join(missing, ", ")))
+		}
+		for i := range names {
+			if message, ok := failures[i]; ok {
+				roleErr.fields = append(roleErr.fields, validation.FieldError{Path: fmt.Sprintf("%s[%d]", field, i), Message: message})
+			}
+		}
+		return nil, roleErr
+	}
+
+	return roles, nil
+}
+
+// roleNamesError reports every invalid and unknown role name of a request at once.
+type roleNamesError struct {
+	errs   []error
+	fields validation.FieldErrors
+}
+
+// Error lists the invalid and the unknown role names.
+func (e *roleNamesError) Error() string {
+	messages := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the errors of the invalid and the unknown names, and the errors of the fields.
+func (e *roleNamesError) Unwrap() []error {
+	return append(slices.Clone(e.errs), e.fields)
+}
+
+// mergeRoleNamesErrors merges the roleNamesErrors of several fields of a request into one, so they are all reported at once.
+// Any other error, e.g. of the database, is returned as is.
+func mergeRoleNamesErrors(errs ...error) error {
+	var merged *roleNamesError
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		var roleErr *roleNamesError
+		if !errors.As(err, &roleErr) {
+			return err
+		}
+		if merged == nil {
+			merged = &roleNamesError{}
+		}
+		merged.errs = append(merged.errs, roleErr.errs...)
+		merged.fields = append(merged.fields, roleErr.fields...)
+	}
+
+	if merged == nil {
+		return nil
+	}
+	return merged
+}
+
+// checkRoleAssignment returns ErrRoleAssignmentForbidden if the caller of the context may not grant or remove one of the roles.
+// ROLE_ADMIN requires the caller to be an admin or a super admin, and ROLE_SUPER_ADMIN to be a super admin,
+// so an admin cannot raise anyone, themselves included, above their own level. The other roles are not restricted.
+// The system actors (e.g. the CLI bootstrap) may assign any role, an anonymous caller none of the admin-level ones.
+func checkRoleAssignment(ctx context.Context, roles []entity.Role) error {
+	meta, _ := metacontext.ExtractUserInformationMeta(ctx)
+	if meta.IsSystem {
+		return nil
+	}
+
+	isSuperAdmin := slices.Contains(meta.Roles, superAdminRole)
+	isAdmin := isSuperAdmin || slices.Contains(meta.Roles, adminRole)
+	for _, role := range roles {
+		name := validation.NormalizeRoleName(role.Name)
+		if (name == superAdminRole && !isSuperAdmin) || (name == adminRole && !isAdmin) {
+			return fmt.Errorf("%w: %s", ErrRoleAssignmentForbidden, name)
+		}
+	}
+
+	return nil
+}
+
+// sameExpiry reports whether two role assignments expire at the same time, or are both permanent.
+func sameExpiry(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// changedRoles returns the roles granted or removed when the current roles are replaced by the new ones.
+func changedRoles(current []entity.Role, next []entity.Role) []entity.Role {
+	var changed []entity.Role
+	for _, role := range next {
+		if !slices.ContainsFunc(current, isRole(role.Name)) {
+			changed = append(changed, role)
+		}
+	}
+	for _, role := range current {
+		if !slices.ContainsFunc(next, isRole(role.Name)) {
+			changed = append(changed, role)
+		}
+	}
+	return changed
+}
+
+// isRole returns a predicate matching the role with the given name, compared case-insensitively.
+func isRole(name string) func(entity.Role) bool {
+	return func(role entity.Role) bool {
+		return strings.EqualFold(role.Name, name)
+	}
+}
+
+// ensureAdminRemains returns ErrLastAdmin if the user is the last enabled admin of the tenant.
+// The ROLE_ADMIN row is locked first, so two concurrent demotions of the last two admins cannot both succeed.
+func (s *userService) ensureAdminRemains(tx *gorm.DB, user entity.User) error {
+	// The roles are not loaded by the locking lookups
+	if user.Roles == nil {
+		withRoles, err := s.repo.GetUserByID(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		user.Roles = withRoles.Roles
+	}
+
+	if user.IsEnabled == nil || !*user.IsEnabled || !slices.ContainsFunc(user.Roles, isRole(adminRole)) {
+		return nil
+	}
+
+	roleRepo := repository.NewRoleRepository()
+	if _, err := roleRepo.GetRoleByNameForUpdate(tx, adminRole); err != nil {
+		return err
+	}
+
+	admins, err := s.repo.CountEnabledUsersWithRole(tx, adminRole)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+
+	return nil
+}
+
+// UpdateLastLogin updates the last login time of a user in the database.
+func (s *userService) UpdateLastLogin(id int64, lastLogin time.Time) (bool, error) {
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return false, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Check if the user exists
+		existingUser, err := s.repo.GetUserByID(db, id)
+		if err != nil {
+			return err
+		}
+
+		// Check if the existing user is empty
+		if (existingUser.Equals(&entity.User{})) {
+			return fmt.Errorf("user with ID %d not found", id)
+		}
+
+		// Update the last login time, a new user has none yet, stored in UTC whatever the zone of the caller
+		lastLogin = lastLogin.UTC()
+		existingUser.LastLogin = &lastLogin
+		_, err = s.repo.UpdateUser(tx, existingUser)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UpdateUserStatus updates the provided status flags of a user in a single transaction.
+// The flags omitted from the request are left unchanged, and the active sessions of the user
+// are revoked when the update prevents the user from logging in.
+func (s *userService) UpdateUserStatus(ctx context.Context, id int64, req entity.UserStatusRequest) (entity.User, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	// The update must be performed by a user, who is recorded by the audit callbacks
+	if _, ok := metacontext.ExtractUserInformationMeta(ctx); !ok {
+		return entity.User{}, fmt.Errorf("missing user context")
+	}
+
+	updatedUser := entity.User{}
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Check if the user exists
+		existingUser, err := s.repo.GetUserByID(tx, id)
+		if err != nil {
+			return err
+		}
+
+		// Disabling the last enabled admin would leave nobody to administer the users
+		if req.IsEnabled != nil && !*req.IsEnabled {
+			if err := s.ensureAdminRemains(tx, existingUser); err != nil {
+				return err
+			}
+		}
+
+		// Apply only the provided flags, the actor is recorded by the audit callbacks
+		req.ApplyTo(&existingUser)
+
+		updatedUser, err = s.repo.UpdateUser(tx, existingUser)
+		if err != nil {
+			return err
+		}
+
+		// Revoke the active sessions if the user can no longer log in
+		if req.RevokesSessions() {
+			refreshTokenRepo := repository.NewRefreshTokenRepository()
+			if _, err := refreshTokenRepo.RemoveRefreshTokenByUserID(tx, id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	return updatedUser, nil
+}
+
+// UpdateUserSessionLimit overrides the maximum number of active sessions of a user.
+// A nil limit removes the override, so the global limit applies again.
+// The existing sessions are kept, the new limit is enforced at the next login.
+func (s *userService) UpdateUserSessionLimit(ctx context.Context, id int64, maxSessions *int) (entity.User, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	// The update must be performed by a user, who is recorded by the audit callbacks
+	if _, ok := metacontext.ExtractUserInformationMeta(ctx); !ok {
+		return entity.User{}, fmt.Errorf("missing user context")
+	}
+
+	updatedUser := entity.User{}
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Check if the user exists
+		existingUser, err := s.repo.GetUserByID(tx, id)
+		if err != nil {
+			return err
+		}
+
+		// Apply the new limit, the actor is recorded by the audit callbacks
+		existingUser.MaxSessions = maxSessions
+
+		updatedUser, err = s.repo.UpdateUser(tx, existingUser)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	return updatedUser, nil
+}
+
+// UpdateUserMetadata sets or removes the provided metadata keys of a user in a single transaction.
+// The changed keys are logged with their old and new values along with the actor.
+func (s *userService) UpdateUserMetadata(ctx context.Context, id int64, req entity.UserMetadataRequest) (entity.User, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	// Get the user performing the update from the context
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.User{}, fmt.Errorf("missing user context")
+	}
+
+	updatedUser := entity.User{}
+	var changes []entity.UserMetadataChange
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Check if the user exists
+		existingUser, err := s.repo.GetUserByIDForUpdate(tx, id)
+		if err != nil {
+			return err
+		}
+
+		// Apply the provided keys, nothing is written if the metadata is unchanged
+		changes, err = req.ApplyTo(&existingUser)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			updatedUser = existingUser
+			return nil
+		}
+
+		updatedUser, err = s.repo.UpdateUser(tx, existingUser)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	if len(changes) > 0 {
+		logger.Info(fmt.Sprintf("Metadata of user %d updated by %s", id, meta.Actor()), logrus.Fields{
+			"userID":    id,
+			"updatedBy": meta.UserID,
+			"actor":     meta.Actor(),
+			"tokenID":   meta.TokenID,
+			"changes":   changes,
+		})
+	}
+
+	return updatedUser, nil
+}
+
+// GetUsersByMetadata retrieves the users whose metadata holds the given key and value.
+func (s *userService) GetUsersByMetadata(ctx context.Context, key string, value string) ([]entity.User, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retrieve the users from the repository
+	users, err := s.repo.GetUsersByMetadata(db.WithContext(ctx), key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// ResetUserPassword replaces the password of a user and revokes their active sessions.
+// The credentials of the user are no longer expired, so a forced password change is lifted.
+// It returns ErrPasswordReused if the password matches one of the recent passwords of the user.
+func (s *userService) ResetUserPassword(ctx context.Context, id int64, password string) (entity.User, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	// Get the user performing the reset from the context
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.User{}, fmt.Errorf("missing user context")
+	}
+
+	// Enforce the strict password policy when its feature flag is enabled
+	if err := checkPasswordPolicy(ctx, password); err != nil {
+		return entity.User{}, err
+	}
+
+	updatedUser := entity.User{}
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Lock the user, so concurrent resets do not both pass the reuse check
+		existingUser, err := s.repo.GetUserByIDForUpdate(tx, id)
+		if err != nil {
+			return err
+		}
+
+		// Reject the current password and the recent ones
+		historyRepo := repository.NewPasswordHistoryRepository()
+		hashes, err := historyRepo.GetRecentPasswordHashes(tx, id, GetPasswordHistorySize())
+		if err != nil {
+			return err
+		}
+		for _, hashed := range append([]string{existingUser.Password}, hashes...) {
+			if checkPassword(hashed, password) {
+				return ErrPasswordReused
+			}
+		}
+
+		hashedPassword, err := hash.FromEnv().Hash(password)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+
+		active := true
+		existingUser.Password = hashedPassword
+		existingUser.IsCredentialsNonExpired = &active
+		updatedUser, err = s.repo.UpdateUser(tx, existingUser)
+		if err != nil {
+			return err
+		}
+
+		if err := recordPassword(tx, id, updatedUser.Password); err != nil {
+			return err
+		}
+
+		// Revoke the active sessions, they were opened with the previous password
+		refreshTokenRepo := repository.NewRefreshTokenRepository()
+		if _, err := refreshTokenRepo.RemoveRefreshTokenByUserID(tx, id); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	logger.Info(fmt.Sprintf("Password of user %d reset by %s", id, meta.Actor()), logrus.Fields{
+		"userID":    id,
+		"updatedBy": meta.UserID,
+		"actor":     meta.Actor(),
+		"tokenID":   meta.TokenID,
+	})
+
+	return updatedUser, nil
+}
+
+// ForcePasswordChange expires the credentials of a user and revokes their active sessions.
+// The user cannot log in until the password is reset, the login is answered with ErrPasswordChangeRequired.
+func (s *userService) ForcePasswordChange(ctx context.Context, id int64) (entity.User, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	// Get the user forcing the change from the context, the actor is also recorded by the audit callbacks
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.User{}, fmt.Errorf("missing user context")
+	}
+
+	updatedUser := entity.User{}
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Check if the user exists
+		existingUser, err := s.repo.GetUserByIDForUpdate(tx, id)
+		if err != nil {
+			return err
+		}
+
+		expired := false
+		existingUser.IsCredentialsNonExpired = &expired
+		updatedUser, err = s.repo.UpdateUser(tx, existingUser)
+		if err != nil {
+			return err
+		}
+
+		// Revoke the active sessions, the refresh tokens would otherwise keep the user logged in
+		refreshTokenRepo := repository.NewRefreshTokenRepository()
+		if _, err := refreshTokenRepo.RemoveRefreshTokenByUserID(tx, id); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	logger.Info(fmt.Sprintf("Password change of user %d forced by %s", id, meta.Actor()), logrus.Fields{
+		"userID":    id,
+		"updatedBy": meta.UserID,
+		"actor":     meta.Actor(),
+		"tokenID":   meta.TokenID,
+	})
+
+	return updatedUser, nil
+}
+
+// ExpireUserCredentials expires the credentials of a user, who must change the password with the current one
+// before logging in again, see AuthService.ChangePassword. The active sessions of the user are revoked as well,
+// unless it is disabled with CREDENTIAL_EXPIRY_REVOKE_SESSIONS. The expiration is recorded in the audit log
+// and as a user.credentials_expired event in the outbox.
+func (s *userService) ExpireUserCredentials(ctx context.Context, id int64) (entity.User, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	// Get the user expiring the credentials from the context
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.User{}, fmt.Errorf("missing user context")
+	}
+
+	revokeSessions := IsCredentialExpirySessionRevocationEnabled()
+	updatedUser := entity.User{}
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		existingUser, err := s.repo.GetUserByIDForUpdate(tx, id)
+		if err != nil {
+			return err
+		}
+
+		updatedUser, err = s.expireCredentials(tx, meta, existingUser, revokeSessions, "Credentials expired by an admin")
+		return err
+	})
+
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	logger.Info(fmt.Sprintf("Credentials of user %d expired by %s", id, meta.Actor()), logrus.Fields{
+		"userID":          id,
+		"updatedBy":       meta.UserID,
+		"actor":           meta.Actor(),
+		"sessionsRevoked": revokeSessions,
+		"tokenID":         meta.TokenID,
+	})
+
+	return updatedUser, nil
+}
+
+// ExpireRoleCredentials expires the credentials of the users of the tenant holding the role, like ExpireUserCredentials.
+// The holders are processed in batches of credentialExpiryBatchSize, each in its own transaction, so a large cohort
+// is not locked at once. If a batch fails, the previous batches stay committed and running it again expires the rest.
+// The holders whose credentials are already expired are skipped, and so is the caller, who would lock themselves out.
+// It returns gorm.ErrRecordNotFound if the role does not exist.
+func (s *userService) ExpireRoleCredentials(ctx context.Context, roleName string) (entity.CredentialExpiryResult, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.CredentialExpiryResult{}, err
+	}
+
+	// Get the user expiring the credentials from the context
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.CredentialExpiryResult{}, fmt.Errorf("missing user context")
+	}
+
+	role, err := repository.NewRoleRepository().GetRoleByName(db.WithContext(ctx), roleName)
+	if err != nil {
+		return entity.CredentialExpiryResult{}, err
+	}
+
+	result := entity.CredentialExpiryResult{Role: role.Name, SessionsRevoked: IsCredentialExpirySessionRevocationEnabled()}
+	details := fmt.Sprintf("Credentials of the holders of %s expired by an admin", role.Name)
+	afterID := int64(0)
+	for {
+		var batch []entity.User
+		affected := 0
+		err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+			users, err := s.repo.GetUsersWithRoleForUpdate(tx, role.Name, afterID, credentialExpiryBatchSize)
+			if err != nil {
+				return err
+			}
+			batch = users
+
+			// Count the batch again on a retry, it was rolled back
+			affected = 0
+			for _, user := range users {
+				if user.ID == meta.UserID || (user.IsCredentialsNonExpired != nil && !*user.IsCredentialsNonExpired) {
+					continue
+				}
+				if _, err := s.expireCredentials(tx, meta, user, result.SessionsRevoked, details); err != nil {
+					return err
+				}
+				affected++
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to expire the credentials of the holders of %s after %d users: %v", role.Name, result.AffectedUsers, err), nil)
+			return result, err
+		}
+
+		result.AffectedUsers += affected
+		if len(batch) < credentialExpiryBatchSize {
+			break
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+
+	logger.Info(fmt.Sprintf("Credentials of %d holders of %s expired by %s", result.AffectedUsers, role.Name, meta.Actor()), logrus.Fields{
+		"role":            role.Name,
+		"affectedUsers":   result.AffectedUsers,
+		"updatedBy":       meta.UserID,
+		"actor":           meta.Actor(),
+		"sessionsRevoked": result.SessionsRevoked,
+		"tokenID":         meta.TokenID,
+	})
+
+	return result, nil
+}
+
+// ReassignRole moves the holders of a role to another one in the tenant, e.g. when a role is renamed or split.
+// The holders are processed by ID in batches of roleReassignmentBatchSize, each in its own transaction: every holder
+// loses the old role and gains the new one, with an audit entry, and the holders already holding the new role are skipped.
+// The progress is saved along with every batch, so a reassignment interrupted by a failure is resumed after the last
+// committed batch by the next request for the same roles. With DryRun, the holders are counted without any change.
+// The caller must be allowed to assign both roles, see checkRoleAssignment, and the last enabled admin cannot lose ROLE_ADMIN.
+func (s *userService) ReassignRole(ctx context.Context, req entity.RoleReassignmentRequest) (entity.RoleReassignmentResult, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.RoleReassignmentResult{}, err
+	}
+
+	// Get the user reassigning the role from the context
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.RoleReassignmentResult{}, fmt.Errorf("missing user context")
+	}
+
+	if validation.NormalizeRoleName(req.From) == validation.NormalizeRoleName(req.To) {
+		return entity.RoleReassignmentResult{}, ErrSameRole
+	}
+
+	from, to, err := resolveReassignmentRoles(db.WithContext(ctx), req)
+	if err != nil {
+		return entity.RoleReassignmentResult{}, err
+	}
+
+	// The caller must be allowed to remove the old role and to grant the new one
+	if err := checkRoleAssignment(ctx, []entity.Role{from, to}); err != nil {
+		return entity.RoleReassignmentResult{}, err
+	}
+
+	result := entity.RoleReassignmentResult{From: from.Name, To: to.Name, DryRun: req.DryRun}
+	if req.DryRun {
+		holders, err := s.repo.CountUsersWithRoles(db.WithContext(ctx), []string{from.Name})
+		if err != nil {
+			return entity.RoleReassignmentResult{}, err
+		}
+		skipped, err := s.repo.CountUsersWithRoles(db.WithContext(ctx), []string{from.Name, to.Name})
+		if err != nil {
+			return entity.RoleReassignmentResult{}, err
+		}
+
+		result.ReassignedUsers = int(holders - skipped)
+		result.SkippedUsers = int(skipped)
+		return result, nil
+	}
+
+	tenantID, ok := metacontext.ExtractTenantID(ctx)
+	if !ok {
+		tenantID = metacontext.DefaultTenantID
+	}
+
+	// Resume the reassignment an interrupted request left behind, or start a new one
+	reassignmentRepo := repository.NewRoleReassignmentRepository()
+	var reassignment entity.RoleReassignment
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Lock the old role, so two requests do not start the same reassignment twice
+		if _, err := repository.NewRoleRepository().GetRoleByNameForUpdate(tx, from.Name); err != nil {
+			return err
+		}
+
+		reassignment, err = reassignmentRepo.GetRunningRoleReassignment(tx, from.ID, to.ID)
+		if err == nil {
+			result.Resumed = true
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		result.Resumed = false
+		createdBy := meta.UserID
+		reassignment, err = reassignmentRepo.CreateRoleReassignment(tx, entity.RoleReassignment{
+			TenantID:   tenantID,
+			FromRoleID: from.ID,
+			ToRoleID:   to.ID,
+			Status:     entity.RoleReassignmentRunning,
+			CreatedBy:  &createdBy,
+		})
+		return err
+	})
+	if err != nil {
+		return entity.RoleReassignmentResult{}, err
+	}
+
+	details := fmt.Sprintf("%s reassigned to %s by an admin", from.Name, to.Name)
+	for reassignment.Status == entity.RoleReassignmentRunning {
+		var reassigned []int64
+		err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+			reassigned = reassigned[:0]
+
+			// Lock the progress, a concurrent request resuming the same reassignment waits for the batch
+			progress, err := reassignmentRepo.GetRoleReassignmentByIDForUpdate(tx, reassignment.ID)
+			if err != nil {
+				return err
+			}
+			if progress.Status != entity.RoleReassignmentRunning {
+				reassignment = progress
+				return nil
+			}
+
+			holders, err := s.repo.GetUsersWithRoleForUpdate(tx, from.Name, progress.LastUserID, roleReassignmentBatchSize)
+			if err != nil {
+				return err
+			}
+
+			// Load the roles of the locked holders, to skip those already holding the new role
+			ids := make([]int64, len(holders))
+			for i, holder := range holders {
+				ids[i] = holder.ID
+			}
+			users, _, err := s.repo.GetUsersByIDs(tx, ids, true)
+			if err != nil {
+				return err
+			}
+
+			for _, user := range users {
+				if slices.ContainsFunc(user.Roles, isRole(to.Name)) {
+					progress.Skipped++
+					continue
+				}
+
+				// Removing ROLE_ADMIN from the last enabled admin would leave nobody to administer the users
+				if from.Name == adminRole {
+					if err := s.ensureAdminRemains(tx, user); err != nil {
+						return err
+					}
+				}
+
+				// The new role is assigned until the old one would have expired
+				granted := to
+				if i := slices.IndexFunc(user.Roles, isRole(from.Name)); i >= 0 {
+					granted.ExpiresAt = user.Roles[i].ExpiresAt
+				}
+				if _, err := s.repo.PatchUserRoles(tx, user, []entity.Role{granted}, []entity.Role{from}); err != nil {
+					return err
+				}
+				if err := recordAccountAudit(tx, meta, user, "reassign_role", details); err != nil {
+					return err
+				}
+				progress.Reassigned++
+				reassigned = append(reassigned, user.ID)
+			}
+
+			if len(holders) > 0 {
+				progress.LastUserID = holders[len(holders)-1].ID
+			}
+			if len(holders) < roleReassignmentBatchSize {
+				completedAt := time.Now().UTC()
+				progress.Status = entity.RoleReassignmentCompleted
+				progress.CompletedAt = &completedAt
+			}
+
+			reassignment, err = reassignmentRepo.UpdateRoleReassignment(tx, progress)
+			return err
+		})
+
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to reassign %s to %s after user %d, the next request resumes it: %v",
+				from.Name, to.Name, reassignment.LastUserID, err), nil)
+			return entity.RoleReassignmentResult{}, err
+		}
+		invalidateUserPermissions(reassigned...)
+	}
+
+	result.ID = reassignment.ID
+	result.ReassignedUsers = reassignment.Reassigned
+	result.SkippedUsers = reassignment.Skipped
+
+	logger.Info(fmt.Sprintf("%d holders of %s reassigned to %s by %s", result.ReassignedUsers, from.Name, to.Name, meta.Actor()), logrus.Fields{
+		"from":            from.Name,
+		"to":              to.Name,
+		"reassignedUsers": result.ReassignedUsers,
+		"skippedUsers":    result.SkippedUsers,
+		"resumed":         result.Resumed,
+		"updatedBy":       meta.UserID,
+		"actor":           meta.Actor(),
+		"tokenID":         meta.TokenID,
+	})
+
+	return result, nil
+}
+
+// resolveReassignmentRoles retrieves the old and the new role of a reassignment.
+// The unknown roles are reported at once by a roleNamesError wrapping ErrUnknownRole, keyed by their field in the request.
+func resolveReassignmentRoles(tx *gorm.DB, req entity.RoleReassignmentRequest) (entity.Role, entity.Role, error) {
+	roleRepo := repository.NewRoleRepository()
+	fields := []string{"from", "to"}
+	names := []string{validation.NormalizeRoleName(req.From), validation.NormalizeRoleName(req.To)}
+
+	roles := make([]entity.Role, len(names))
+	roleErr := &roleNamesError{}
+	var missing []string
+	for i, name := range names {
+		role, err := roleRepo.GetRoleByName(tx, name)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			missing = append(missing, name)
+			roleErr.fields = append(roleErr.fields, validation.FieldError{Path: fields[i], Message: fmt.Sprintf("must be an existing role, %s does not exist", name)})
+			continue
+		}
+		if err != nil {
+			return entity.Role{}, entity.Role{}, err
+		}
+		roles[i] = role
+	}
+
+	if len(missing) > 0 {
+		roleErr.errs = append(roleErr.errs, fmt.Errorf("%w: %s", ErrUnknownRole, strings.Join(missing, ", ")))
+		return entity.Role{}, entity.Role{}, roleErr
+	}
+
+	return roles[0], roles[1], nil
+}
+
+// expireCredentials expires the credentials of the locked user and revokes their sessions if asked,
+// then records the expiration in the audit log and in the outbox, in the transaction of the change.
+func (s *userService) expireCredentials(tx *gorm.DB, meta metacontext.UserInformationMeta, user entity.User, revokeSessions bool, details string) (entity.User, error) {
+	expired := false
+	user.IsCredentialsNonExpired = &expired
+	updatedUser, err := s.repo.UpdateUser(tx, user)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	if revokeSessions {
+		if _, err := repository.NewRefreshTokenRepository().RemoveRefreshTokenByUserID(tx, user.ID); err != nil {
+			return entity.User{}, err
+		}
+	}
+
+	if err := recordAccountAudit(tx, meta, updatedUser, "expire_credentials", details); err != nil {
+		return entity.User{}, err
+	}
+	if err := recordAccountEvent(tx, entity.OutboxEventCredentialsExpired, updatedUser, nil); err != nil {
+		return entity.User{}, err
+	}
+
+	return updatedUser, nil
+}
+
+// UpdateUserRoles replaces the roles of a user in a single transaction.
+// Only the admins may grant or remove the admin-level roles, see checkRoleAssignment,
+// and the last enabled admin cannot lose ROLE_ADMIN, the update then fails with ErrLastAdmin.
+func (s *userService) UpdateUserRoles(ctx context.Context, id int64, req entity.UserRolesRequest) (entity.User, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	// Get the user performing the update from the context, the roles it may assign depend on its own
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.User{}, fmt.Errorf("missing user context")
+	}
+
+	updatedUser := entity.User{}
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Check if the user exists, along with its current roles
+		existingUser, err := s.repo.GetUserByID(tx, id)
+		if err != nil {
+			return err
+		}
+
+		roles, err := resolveRoles(tx, req.Roles)
+		if err != nil {
+			return err
+		}
+
+		// The roles the user keeps are kept until the expiry of their assignment
+		for i, role := range roles {
+			if j := slices.IndexFunc(existingUser.Roles, isRole(role.Name)); j >= 0 {
+				roles[i].ExpiresAt = existingUser.Roles[j].ExpiresAt
+			}
+		}
+
+		// The caller must be allowed to assign every granted or removed role
+		if err := checkRoleAssignment(ctx, changedRoles(existingUser.Roles, roles)); err != nil {
+			return err
+		}
+
+		// The user must keep at least one role, validated like at its creation
+		candidate := existingUser
+		candidate.Roles = roles
+		if err := candidate.Validate(); err != nil {
+			return err
+		}
+
+		// Removing ROLE_ADMIN from the last enabled admin would leave nobody to administer the users
+		if !slices.ContainsFunc(roles, isRole(adminRole)) {
+			if err := s.ensureAdminRemains(tx, existingUser); err != nil {
+				return err
+			}
+		}
+
+		updatedUser, err = s.repo.ReplaceUserRoles(tx, existingUser, roles)
+		return err
+	})
+
+	if err != nil {
+		return entity.User{}, err
+	}
+	invalidateUserPermissions(id)
+
+	logger.Info(fmt.Sprintf("Roles of user %d set to %s by %s", id, strings.Join(ExtractRoleNames(updatedUser.Roles), ", "), meta.Actor()), logrus.Fields{
+		"userID":    id,
+		"updatedBy": meta.UserID,
+		"actor":     meta.Actor(),
+		"tokenID":   meta.TokenID,
+	})
+
+	return updatedUser, nil
+}
+
+// PatchUserRoles adds and removes some roles of a user in a single transaction, the other roles are left unchanged.
+// The added roles are assigned until the expiry of the request, or permanently without one: adding a role the user holds
+// only updates the expiry of its assignment, and removing one it does not hold changes nothing. The names of both lists
+// must exist, every failing name is reported at once by its path, e.g. remove[0], and a role both added and removed is rejected.
+// Like UpdateUserRoles, only the admins may grant or remove the admin-level roles, the user must keep at least one role,
+// and the last enabled admin cannot lose ROLE_ADMIN, nor be given an expiring one.
+func (s *userService) PatchUserRoles(ctx context.Context, id int64, req entity.UserRolesPatchRequest) (entity.User, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	// Get the user performing the update from the context, the roles it may assign depend on its own
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.User{}, fmt.Errorf("missing user context")
+	}
+
+	// A role cannot be both added and removed, and the expiry applies to the added roles
+	var conflicts validation.FieldErrors
+	for i, name := range req.Remove {
+		if slices.ContainsFunc(req.Add, func(added string) bool {
+			return validation.NormalizeRoleName(added) == validation.NormalizeRoleName(name)
+		}) {
+			conflicts = append(conflicts, validation.FieldError{Path: fmt.Sprintf("remove[%d]", i), Message: "must not be added at the same time"})
+		}
+	}
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		switch {
+		case len(req.Add) == 0:
+			conflicts = append(conflicts, validation.FieldError{Path: "expiresAt", Message: "must be given along with roles to add"})
+		case !req.ExpiresAt.After(time.Now()):
+			conflicts = append(conflicts, validation.FieldError{Path: "expiresAt", Message: "must be in the future"})
+		default:
+			utc := req.ExpiresAt.UTC()
+			expiresAt = &utc
+		}
+	}
+	if len(conflicts) > 0 {
+		return entity.User{}, conflicts
+	}
+
+	updatedUser := entity.User{}
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		// Check if the user exists, along with its current roles
+		existingUser, err := s.repo.GetUserByID(tx, id)
+		if err != nil {
+			return err
+		}
+
+		added, addErr := resolveRoleNames(tx, "add", req.Add)
+		removed, removeErr := resolveRoleNames(tx, "remove", req.Remove)
+		if err := mergeRoleNamesErrors(addErr, removeErr); err != nil {
+			return err
+		}
+
+		// Only the roles actually granted, or whose expiry changes, and those actually removed are applied
+		for i := range added {
+			added[i].ExpiresAt = expiresAt
+		}
+		added = slices.DeleteFunc(added, func(role entity.Role) bool {
+			i := slices.IndexFunc(existingUser.Roles, isRole(role.Name))
+			return i >= 0 && sameExpiry(existingUser.Roles[i].ExpiresAt, role.ExpiresAt)
+		})
+		removed = slices.DeleteFunc(removed, func(role entity.Role) bool {
+			return !slices.ContainsFunc(existingUser.Roles, isRole(role.Name))
+		})
+
+		// The caller must be allowed to assign every granted or removed role
+		if err := checkRoleAssignment(ctx, append(slices.Clone(added), removed...)); err != nil {
+			return err
+		}
+
+		// The user must keep at least one role, validated like at its creation
+		candidate := existingUser
+		candidate.Roles = slices.DeleteFunc(slices.Clone(existingUser.Roles), func(role entity.Role) bool {
+			return slices.ContainsFunc(removed, isRole(role.Name)) || slices.ContainsFunc(added, isRole(role.Name))
+		})
+		candidate.Roles = append(candidate.Roles, added...)
+		if err := candidate.Validate(); err != nil {
+			return err
+		}
+
+		// Removing ROLE_ADMIN from the last enabled admin, or making it expire, would leave nobody to administer the users
+		if slices.ContainsFunc(removed, isRole(adminRole)) || (expiresAt != nil && slices.ContainsFunc(added, isRole(adminRole))) {
+			if err := s.ensureAdminRemains(tx, existingUser); err != nil {
+				return err
+			}
+		}
+
+		updatedUser, err = s.repo.PatchUserRoles(tx, existingUser, added, removed)
+		return err
+	})
+
+	if err != nil {
+		return entity.User{}, err
+	}
+	invalidateUserPermissions(id)
+
+	logger.Info(fmt.Sprintf("Roles of user %d set to %s by %s", id, strings.Join(ExtractRoleNames(updatedUser.Roles), ", "), meta.Actor()), logrus.Fields{
+		"userID":    id,
+		"updatedBy": meta.UserID,
+		"actor":     meta.Actor(),
+		"tokenID":   meta.TokenID,
+	})
+
+	return updatedUser, nil
+}
+
+// BulkDeleteUsers soft-deletes the users with the given IDs in a single transaction and revokes their sessions.
+// It returns the outcome for every ID, in the given order. The unknown users are reported as not found,
+// and the user performing the deletion is skipped. Any other error rolls back the whole operation.
+func (s *userService) BulkDeleteUsers(ctx context.Context, ids []int64) ([]entity.UserBulkDeleteResult, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the user performing the deletion from the context
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return nil, fmt.Errorf("missing user context")
+	}
+
+	var results []entity.UserBulkDeleteResult
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		results = make([]entity.UserBulkDeleteResult, 0, len(ids))
+		refreshTokenRepo := repository.NewRefreshTokenRepository()
+
+		for _, id := range ids {
+			// The user performing the deletion would lock themselves out
+			if id == meta.UserID {
+				reason := "a user cannot delete themselves"
+				results = append(results, entity.UserBulkDeleteResult{ID: id, Status: entity.UserBulkDeleteSkipped, Error: &reason})
+				continue
+			}
+
+			existingUser, err := s.repo.GetUserByIDForUpdate(tx, id)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				reason := "no user found with the given ID"
+				results = append(results, entity.UserBulkDeleteResult{ID: id, Status: entity.UserBulkDeleteNotFound, Error: &reason})
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			// Deleting the last enabled admin would leave nobody to administer the users
+			if err := s.ensureAdminRemains(tx, existingUser); errors.Is(err, ErrLastAdmin) {
+				reason := "the last enabled admin cannot be deleted"
+				results = append(results, entity.UserBulkDeleteResult{ID: id, Status: entity.UserBulkDeleteSkipped, Error: &reason})
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			if err := s.repo.DeleteUser(tx, existingUser, meta.UserID); err != nil {
+				return err
+			}
+			if _, err := refreshTokenRepo.RemoveRefreshTokenByUserID(tx, id); err != nil {
+				return err
+			}
+			results = append(results, entity.UserBulkDeleteResult{ID: id, Status: entity.UserBulkDeleteDeleted})
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info(fmt.Sprintf("Bulk delete of %d users performed by %s", len(ids), meta.Actor()), logrus.Fields{
+		"deletedBy": meta.UserID,
+		"actor":     meta.Actor(),
+		"tokenID":   meta.TokenID,
+		"results":   results,
+	})
+
+	return results, nil
+}
+
+// MergeUsers merges the source user of the request into the target user in a single transaction, both rows locked:
+// the roles of the source are added to the target, the metadata keys the target does not hold are copied to it,
+// the sessions of the source are revoked, and the source is soft-deleted with a reference to the target.
+// The audit history of the source is kept as is, the merge is recorded in the audit log of both users,
+// and a user.merged event is recorded in the outbox. With DryRun, the changes are reported but nothing is written.
+// It returns ErrMergeIntoSelf, ErrMergeDeletedUser, or ErrLastAdmin if the source is the last enabled admin
+// and the target is disabled.
+func (s *userService) MergeUsers(ctx context.Context, targetID int64, req entity.UserMergeRequest) (entity.UserMergeResult, error) {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return entity.UserMergeResult{}, err
+	}
+
+	// Get the user performing the merge from the context
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return entity.UserMergeResult{}, fmt.Errorf("missing user context")
+	}
+
+	if req.SourceID == targetID {
+		return entity.UserMergeResult{}, ErrMergeIntoSelf
+	}
+
+	var result entity.UserMergeResult
+	err = database.TransactionWithRetry(ctx, db, func(tx *gorm.DB) error {
+		result = entity.UserMergeResult{TargetID: targetID, SourceID: req.SourceID, DryRun: req.DryRun}
+
+		// Lock both users in the order of their IDs, so two crossed merges cannot deadlock
+		for _, id := range []int64{min(targetID, req.SourceID), max(targetID, req.SourceID)} {
+			user, err := s.repo.GetUserByIDForUpdate(tx, id, repository.WithDeleted())
+			if err != nil {
+				return err
+			}
+			if user.DeletedAt.Valid {
+				return fmt.Errorf("%w: user %d is deleted", ErrMergeDeletedUser, id)
+			}
+		}
+
+		// The locking lookups do not load the roles
+		target, err := s.repo.GetUserByID(tx, targetID)
+		if err != nil {
+			return err
+		}
+		source, err := s.repo.GetUserByID(tx, req.SourceID)
+		if err != nil {
+			return err
+		}
+
+		// The roles of the source missing from the target are granted to it
+		var addedRoles []entity.Role
+		for _, role := range source.Roles {
+			if !slices.ContainsFunc(target.Roles, isRole(role.Name)) {
+				addedRoles = append(addedRoles, role)
+			}
+		}
+		if err := checkRoleAssignment(ctx, addedRoles); err != nil {
+			return err
+		}
+		result.AddedRoles = ExtractRoleNames(addedRoles)
+
+		// A disabled target does not keep an admin enabled, the source must not be the last one
+		if target.IsEnabled == nil || !*target.IsEnabled {
+			if err := s.ensureAdminRemains(tx, source); err != nil {
+				return err
+			}
+		}
+
+		// The metadata keys of the source are copied to the target, the target keeps its own values
+		metadata := entity.UserMetadata{}
+		for key, value := range target.Metadata {
+			metadata[key] = value
+		}
+		result.AddedMetadataKeys = []string{}
+		result.ConflictingMetadataKeys = []string{}
+		for key, value := range source.Metadata {
+			if current, exists := metadata[key]; exists {
+				if current != value {
+					result.ConflictingMetadataKeys = append(result.ConflictingMetadataKeys, key)
+				}
+				continue
+			}
+			metadata[key] = value
+			result.AddedMetadataKeys = append(result.AddedMetadataKeys, key)
+		}
+		slices.Sort(result.AddedMetadataKeys)
+		slices.Sort(result.ConflictingMetadataKeys)
+		if len(metadata) > entity.MaxUserMetadataEntries {
+			return fmt.Errorf("%w: a user cannot hold more than %d", entity.ErrTooManyUserMetadataEntries, entity.MaxUserMetadataEntries)
+		}
+
+		refreshTokenRepo := repository.NewRefreshTokenRepository()
+		refreshTokens, err := refreshTokenRepo.GetRefreshTokensByUserID(tx, source.ID)
+		if err != nil {
+			return err
+		}
+		result.RevokedSessions = len(refreshTokens)
+
+		if req.DryRun {
+			result.Target = target.ToResponse()
+			return nil
+		}
+
+		if len(addedRoles) > 0 {
+			if target, err = s.repo.ReplaceUserRoles(tx, target, append(target.Roles, addedRoles...)); err != nil {
+				return err
+			}
+		}
+		if len(result.AddedMetadataKeys) > 0 {
+			target.Metadata = metadata
+			if target, err = s.repo.UpdateUser(tx, target); err != nil {
+				return err
+			}
+		}
+
+		// The source can no longer sign in, nor be deleted later by its deactivation
+		if _, err := refreshTokenRepo.RemoveRefreshTokenByUserID(tx, source.ID); err != nil {
+			return err
+		}
+		deletionRepo := repository.NewScheduledDeletionRepository()
+		if deletion, err := deletionRepo.GetScheduledDeletionByUserID(tx, source.ID); err == nil {
+			if err := deletionRepo.RemoveScheduledDeletion(tx, deletion.ID); err != nil {
+				return err
+			}
+		}
+		if err := s.repo.MergeUser(tx, source, target.ID, meta.UserID); err != nil {
+			return err
+		}
+
+		// The audit history of the source stays under its ID, it is linked to the target by the merge records
+		if err := recordAccountAudit(tx, meta, target, "merge",
+			fmt.Sprintf("User %d (%s) merged into this user", source.ID, source.Username)); err != nil {
+			return err
+		}
+		if err := recordAccountAudit(tx, meta, source, "merge",
+			fmt.Sprintf("Merged into user %d (%s)", target.ID, target.Username)); err != nil {
+			return err
+		}
+		if err := recordUserMergedEvent(tx, target, source, result.AddedRoles); err != nil {
+			return err
+		}
+
+		result.Target = target.ToResponse()
+		return nil
+	})
+
+	if err != nil {
+		return entity.UserMergeResult{}, err
+	}
+
+	if !req.DryRun {
+		invalidateUserPermissions(targetID, req.SourceID)
+		logger.Info(fmt.Sprintf("User %d merged into user %d by %s", req.SourceID, targetID, meta.Actor()), logrus.Fields{
+			"targetID":        targetID,
+			"sourceID":        req.SourceID,
+			"mergedBy":        meta.UserID,
+			"actor":           meta.Actor(),
+			"tokenID":         meta.TokenID,
+			"addedRoles":      result.AddedRoles,
+			"revokedSessions": result.RevokedSessions,
+		})
+	}
+
+	return result, nil
+}
+
+// recordUserMergedEvent records the merge of the source user into the target user in the outbox.
+func recordUserMergedEvent(tx *gorm.DB, target entity.User, source entity.User, addedRoles []string) error {
+	payload, err := json.Marshal(entity.UserMergedPayload{
+		TargetID:       target.ID,
+		SourceID:       source.ID,
+		SourceUsername: source.Username,
+		SourceEmail:    source.Email,
+		AddedRoles:     addedRoles,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", entity.OutboxEventUserMerged, err)
+	}
+
+	_, err = repository.NewOutboxEventRepository().CreateOutboxEvent(tx, entity.OutboxEvent{
+		TenantID:      target.TenantID,
+		Type:          entity.OutboxEventUserMerged,
+		AggregateType: "user",
+		AggregateID:   strconv.FormatInt(target.ID, 10),
+		Payload:       string(payload),
+	})
+	return err
+}
+
+// PurgeUser permanently deletes a soft-deleted user and its dependent records in a single transaction.
+// It returns ErrUserNotDeleted if the user is not deleted, and ErrUserDeletedRecently if it was deleted
+// within the retention period. The purge is logged with the numeric ID only, the personal data of the user is gone.
+func (s *userService) PurgeUser(ctx context.Context, id int64) error {
+	db, err := database.RequireDB(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Get the user performing the purge from the context
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	if !ok {
+		return fmt.Errorf("missing user context")
+	}
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the user, deleted or not, so it is not restored while it is purged
+		existingUser, err := s.repo.GetUserByIDForUpdate(tx, id, repository.WithDeleted())
+		if err != nil {
+			return err
+		}
+
+		// Only the users deleted for longer than the retention period can be purged
+		if !existingUser.DeletedAt.Valid {
+			return ErrUserNotDeleted
+		}
+		retention := time.Duration(GetUserPurgeRetentionDays()) * 24 * time.Hour
+		if purgeableAt := existingUser.DeletedAt.Time.Add(retention); time.Now().Before(purgeableAt) {
+			return fmt.Errorf("%w: it can be purged from %s", ErrUserDeletedRecently, purgeableAt.UTC().Format(time.RFC3339))
+		}
+
+		return s.repo.PurgeUser(tx, id)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	logger.Info(fmt.Sprintf("User %d purged by %s", id, meta.Actor()), logrus.Fields{
+		"userID":   id,
+		"purgedBy": meta.UserID,
+		"actor":    meta.Actor(),
+		"tokenID":  meta.TokenID,
+	})
+
+	return nil
+}
+
+// checkPasswordPolicy returns ErrWeakPassword if the strict password policy is enabled for the tenant of the request
+// and the password does not meet it.
+func checkPasswordPolicy(ctx context.Context, password string) error {
+	if featureflag.Enabled(ctx, featureflag.StrictPasswordPolicy) && !validation.IsStrongPassword(password) {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+// recordPassword adds the password hash to the history of the user, and prunes the entries beyond the history size.
+func recordPassword(tx *gorm.DB, userID int64, hash string) error {
+	historyRepo := repository.NewPasswordHistoryRepository()
+	if err := historyRepo.CreatePasswordHistory(tx, entity.PasswordHistory{UserID: userID, PasswordHash: hash}); err != nil {
+		return err
+	}
+
+	_, err := historyRepo.PrunePasswordHistory(tx, userID, GetPasswordHistorySize())
+	return err
+}
+
+// GetPasswordHistorySize returns the number of recent passwords a user cannot reuse.
+// It retrieves the size from an environment variable, 0 only rejects the current password.
+func GetPasswordHistorySize() int {
+	size, err := strconv.Atoi(os.Getenv("PASSWORD_HISTORY_SIZE"))
+	if err != nil || size < 0 {
+		return defaultPasswordHistorySize // Default to 5 passwords if the environment variable is not set or invalid
+	}
+
+	return size
+}
+
+// GetUserExportBatchSize returns the number of users fetched at once by the export of the users, and flushed together.
+// It retrieves the size from an environment variable and defaults to 500.
+func GetUserExportBatchSize() int {
+	size, err := strconv.Atoi(os.Getenv("USER_EXPORT_BATCH_SIZE"))
+	if err != nil || size < 1 {
+		return defaultUserExportBatchSize // Default to 500 users if the environment variable is not set or invalid
+	}
+
+	return size
+}
+
+// GetUserPurgeRetentionDays returns the number of days a deleted user is kept before it can be purged.
+// It retrieves the retention from an environment variable, 0 allows purging the deleted users at once.
+func GetUserPurgeRetentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("USER_PURGE_RETENTION_DAYS"))
+	if err != nil || days < 0 {
+		return defaultUserPurgeRetentionDays // Default to 30 days if the environment variable is not set or invalid
+	}
+
+	return days
+}
+
+// IsCredentialExpirySessionRevocationEnabled reports whether expiring the credentials of a user also revokes their sessions.
+// It retrieves the toggle from an environment variable, the sessions are only kept if it is FALSE.
+func IsCredentialExpirySessionRevocationEnabled() bool {
+	return strings.ToUpper(os.Getenv("CREDENTIAL_EXPIRY_REVOKE_SESSIONS")) != "FALSE"
+}
+
+// IsSelfRegistrationEnabled reports whether anyone can register an account without being authenticated.
+// It retrieves the toggle from an environment variable, the registration is restricted to the admins if it is not TRUE.
+func IsSelfRegistrationEnabled() bool {
+	return strings.ToUpper(os.Getenv("SELF_REGISTRATION_ENABLED")) == "TRUE"
+}
+
+// GetSelfRegistrationRole returns the only role attached to the accounts created through the self-registration.
+// It retrieves the role from an environment variable and defaults to ROLE_USER.
+func GetSelfRegistrationRole() string {
+	role := strings.TrimSpace(os.Getenv("SELF_REGISTRATION_ROLE"))
+	if role == "" {
+		return defaultSelfRegistrationRole
+	}
+
+	return role
+}
+
+// GetDefaultUserRole returns the only role attached to the new users created without any role, in place of the roles
+// flagged as default. It retrieves the role from an environment variable, it is empty if it is not set.
+func GetDefaultUserRole() string {
+	return validation.NormalizeRoleName(os.Getenv("DEFAULT_USER_ROLE"))
+}
+
+// ValidateDefaultUserRole checks that the configured default role of the new users exists, see GetDefaultUserRole.
+// It is called at startup, so a misconfigured role fails fast instead of failing the creation of the users.
+func ValidateDefaultUserRole() error {
+	name := GetDefaultUserRole()
+	if name == "" {
+		return nil
+	}
+
+	db, err := database.RequirePostgres()
+	if err != nil {
+		return err
+	}
+
+	if _, err := repository.NewRoleRepository().GetRoleByName(db, name); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: DEFAULT_USER_ROLE %s does not exist", ErrUnknownRole, name)
+		}
+		return fmt.Errorf("failed to check DEFAULT_USER_ROLE: %w", err)
+	}
+
+	return nil
+}
+
+// GetAllowedUserMetadataKeys returns the metadata keys the users can hold.
+// It retrieves the comma-separated keys from an environment variable, no key is allowed if it is not set.
+func GetAllowedUserMetadataKeys() []string {
+	var keys []string
+	for _, key := range strings.Split(os.Getenv("USER_METADATA_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// GetEmailDomainWatchlist returns the email domains the new users are warned about, in lowercase.
+// It retrieves the comma-separated domains from an environment variable, no domain is watched if it is not set.
+func GetEmailDomainWatchlist() []string {
+	var domains []string
+	for _, domain := range strings.Split(os.Getenv("USER_EMAIL_DOMAIN_WATCHLIST"), ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+
+	return domains
 }
@@ -0,0 +1,123 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+/**
+* The time-dependent logic of the services, e.g. the expiry of the tokens, the grace period of the deactivated accounts
+* or the windows of the rate limiter, reads the time from a Clock injected in their constructor instead of time.Now.
+* The application runs with the real clock returned by New, the tests inject a Fake and advance it past the boundaries
+* they check, instead of waiting for them or tampering with the stored dates.
+ */
+
+// Clock tells the current time and waits for durations to elapse.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the clock of the system, backed by the time package.
+type realClock struct{}
+
+// New returns the real clock of the system.
+func New() Clock {
+	return realClock{}
+}
+
+// Now returns the current time of the system.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// After waits for the duration to elapse on the system clock, see time.After.
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Fake is a clock whose time only moves when it is advanced, for the tests.
+// The channels returned by After receive the time once the clock is advanced to their deadline or past it.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+	changed chan struct{}
+}
+
+// waiter is a pending call to After, along with the time its channel is due.
+type waiter struct {
+	until time.Time
+	ch    chan time.Time
+}
+
+// NewFake returns a fake clock set at the given time.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, changed: make(chan struct{})}
+}
+
+// Now returns the time the fake clock is set at.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// After returns a channel receiving the time once the clock is advanced by the duration, at once if it is not positive.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+
+	f.waiters = append(f.waiters, waiter{until: f.now.Add(d), ch: ch})
+	f.notify()
+	return ch
+}
+
+// Advance moves the clock forward by the duration, and sends the new time to the waiters it is due for.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.until.After(f.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	f.waiters = pending
+	f.notify()
+}
+
+// BlockUntil waits until n calls to After are pending, e.g. until a background job waits for its next run
+// before the clock is advanced past it.
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		pending, changed := len(f.waiters), f.changed
+		f.mu.Unlock()
+
+		if pending >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+// notify wakes up the callers of BlockUntil, the lock must be held.
+func (f *Fake) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
@@ -0,0 +1,30 @@
+package metacontext
+
+import (
+	"context"
+)
+
+// This struct defines the LocaleMetaKeyType struct
+//
+//	It is used as a key for storing and retrieving the locale of the messages from the context
+type LocaleMetaKeyType struct{}
+
+// Define a key for storing the locale of the messages in the context
+var localeMetaKey = LocaleMetaKeyType{}
+
+// DefaultLocale is the locale of the messages when the request did not select a supported one
+const DefaultLocale = "en"
+
+// InjectLocale injects the locale the messages of the response are written in into the context.
+func InjectLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeMetaKey, locale)
+}
+
+// ExtractLocale retrieves the locale the messages of the response are written in from the context.
+// It returns DefaultLocale when the request did not select a locale.
+func ExtractLocale(ctx context.Context) string {
+	if locale, ok := ctx.Value(localeMetaKey).(string); ok && locale != "" {
+		return locale
+	}
+	return DefaultLocale
+}
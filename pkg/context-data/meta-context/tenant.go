@@ -0,0 +1,29 @@
+package metacontext
+
+import (
+	"context"
+)
+
+// DefaultTenantID is the tenant of the users created before the multi-tenancy,
+// and of the tokens issued without a tenant claim.
+const DefaultTenantID int64 = 1
+
+// This struct defines the TenantMetaKeyType struct
+//
+//	It is used as a key for storing and retrieving the tenant ID from the context
+type TenantMetaKeyType struct{}
+
+// Define a key for storing the tenant ID in the context
+var tenantMetaKey = TenantMetaKeyType{}
+
+// InjectTenantID injects the ID of the tenant the request operates on into the context.
+// The user queries run with this context are restricted to the tenant.
+func InjectTenantID(ctx context.Context, tenantID int64) context.Context {
+	return context.WithValue(ctx, tenantMetaKey, tenantID)
+}
+
+// ExtractTenantID retrieves the ID of the tenant the request operates on from the context.
+func ExtractTenantID(ctx context.Context) (int64, bool) {
+	tenantID, ok := ctx.Value(tenantMetaKey).(int64)
+	return tenantID, ok
+}
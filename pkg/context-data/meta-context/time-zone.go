@@ -0,0 +1,28 @@
+package metacontext
+
+import (
+	"context"
+	"time"
+)
+
+// This struct defines the TimeZoneMetaKeyType struct
+//
+//	It is used as a key for storing and retrieving the display time zone from the context
+type TimeZoneMetaKeyType struct{}
+
+// Define a key for storing the display time zone in the context
+var timeZoneMetaKey = TimeZoneMetaKeyType{}
+
+// InjectTimeZone injects the time zone the times of the response are displayed in into the context.
+func InjectTimeZone(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, timeZoneMetaKey, loc)
+}
+
+// ExtractTimeZone retrieves the time zone the times of the response are displayed in from the context.
+// It returns UTC when the request did not select a time zone.
+func ExtractTimeZone(ctx context.Context) *time.Location {
+	if loc, ok := ctx.Value(timeZoneMetaKey).(*time.Location); ok && loc != nil {
+		return loc
+	}
+	return time.UTC
+}
@@ -2,16 +2,38 @@ package metacontext
 
 import (
 	"context"
+	"strconv"
+)
+
+const (
+	// SystemUserID is the ID of the reserved system user, seeded by the migration.
+	// It is recorded as the actor of the operations performed without a human, e.g. by the CLI or the background jobs.
+	SystemUserID int64 = 0
+
+	// SystemUsername is the username of the reserved system user.
+	SystemUsername = "system"
 )
 
 // This struct defines the UserInformationMeta struct
 //
 //	It can be used to store metadata about the request
+//	It is populated once from the JWT claims, so the middlewares and the audit logs do not re-fetch the user
+//	TokenID is the jti claim of the access token, TenantID the tenant of the user
+//	IsSystem distinguishes the system actors from the authenticated users
+//	Permissions are the effective permissions of the user, nil when the token does not carry them
+//	GroupIDs are the IDs of the groups of the user when the token was issued, nil when the token does not carry them
+//	ImpersonatorID is the ID of the admin acting as the user with an impersonation token, nil otherwise
 type UserInformationMeta struct {
-	UserID   int64
-	Username string
-	Email    string
-	Roles    []string
+	UserID         int64
+	Username       string
+	Email          string
+	Roles          []string
+	Permissions    []string
+	GroupIDs       []int64
+	TokenID        string
+	TenantID       int64
+	IsSystem       bool
+	ImpersonatorID *int64
 }
 
 // This struct defines the UserInformationMetaKeyType struct
@@ -28,9 +50,43 @@ func InjectUserInformationMeta(ctx context.Context, meta UserInformationMeta) co
 	return context.WithValue(ctx, userInformationMetaKey, meta)
 }
 
+// WithSystemActor injects a system actor with the given name into the context.
+// It lets the callers without an HTTP request (e.g. the CLI, the seeders, the background jobs) use the services,
+// the operations are recorded as performed by the reserved system user.
+func WithSystemActor(ctx context.Context, name string) context.Context {
+	return InjectUserInformationMeta(ctx, UserInformationMeta{
+		UserID:   SystemUserID,
+		Username: name,
+		TenantID: DefaultTenantID,
+		IsSystem: true,
+	})
+}
+
+// Actor returns the label of the actor for the audit entries, e.g. user:admin or system:cli.
+// The user ID is used when the username is unknown, e.g. user:1.
+// The impersonated users are labelled along with their impersonator, e.g. user:bob (impersonated by user:1).
+func (m UserInformationMeta) Actor() string {
+	if m.IsSystem {
+		return "system:" + m.Username
+	}
+
+	actor := "user:" + m.Username
+	if m.Username == "" {
+		actor = "user:" + strconv.FormatInt(m.UserID, 10)
+	}
+	if m.ImpersonatorID != nil {
+		actor += " (impersonated by user:" + strconv.FormatInt(*m.ImpersonatorID, 10) + ")"
+	}
+	return actor
+}
+
 // ExtractUserInformationMeta retrieves the UserInformationMeta from the context.
 // This function is used to access the metadata stored in the context
+// The metadata injected with the user ID only, e.g. by older code, gets the default tenant.
 func ExtractUserInformationMeta(ctx context.Context) (UserInformationMeta, bool) {
 	meta, ok := ctx.Value(userInformationMetaKey).(UserInformationMeta)
+	if ok && meta.TenantID == 0 {
+		meta.TenantID = DefaultTenantID
+	}
 	return meta, ok
 }
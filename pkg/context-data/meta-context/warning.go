@@ -0,0 +1,54 @@
+package metacontext
+
+import (
+	"context"
+	"sync"
+)
+
+// This struct defines the WarningMetaKeyType struct
+//
+//	It is used as a key for storing and retrieving the warnings collector from the context
+type WarningMetaKeyType struct{}
+
+// Define a key for storing the warnings collector in the context
+var warningMetaKey = WarningMetaKeyType{}
+
+// warningCollector holds the non-fatal warnings raised while processing a request.
+type warningCollector struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+// InjectWarningCollector injects an empty warnings collector into the context.
+// The warnings added with the returned context, or any context derived from it, are collected together.
+func InjectWarningCollector(ctx context.Context) context.Context {
+	return context.WithValue(ctx, warningMetaKey, &warningCollector{})
+}
+
+// AddWarning adds a non-fatal warning to the collector of the context.
+// The warning is dropped when the context carries no collector.
+func AddWarning(ctx context.Context, warning string) {
+	collector, ok := ctx.Value(warningMetaKey).(*warningCollector)
+	if !ok {
+		return
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.warnings = append(collector.warnings, warning)
+}
+
+// ExtractWarnings retrieves a copy of the warnings collected in the context, in the order they were added.
+func ExtractWarnings(ctx context.Context) []string {
+	collector, ok := ctx.Value(warningMetaKey).(*warningCollector)
+	if !ok {
+		return nil
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if len(collector.warnings) == 0 {
+		return nil
+	}
+	return append([]string(nil), collector.warnings...)
+}
@@ -0,0 +1,178 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+)
+
+/**
+* The feature flags let the new behaviors ship disabled and be enabled per environment, or per tenant,
+* without a redeploy. The values come from a Provider: the static provider reads them from FEATURE_FLAGS,
+* another provider (e.g. a remote flag service) can replace it with SetProvider.
+* An unknown or undefined flag is disabled.
+ */
+const (
+	// Enforce2FA requires the users to log in with a second factor
+	Enforce2FA = "enforce_2fa"
+
+	// StrictPasswordPolicy requires the new passwords to mix lowercase, uppercase, digits and symbols
+	StrictPasswordPolicy = "strict_password_policy"
+
+	// CookieAuth issues the access token in an HttpOnly cookie at login and accepts it in place of the Authorization header
+	CookieAuth = "cookie_auth"
+)
+
+// Known lists the flags read by the application, in the order they are reported.
+var Known = []string{Enforce2FA, StrictPasswordPolicy, CookieAuth}
+
+// Provider supplies the values of the feature flags.
+type Provider interface {
+	// Lookup returns the value of the flag for the tenant, and false if the provider does not define it.
+	Lookup(tenantID int64, name string) (value bool, ok bool)
+}
+
+var (
+	mu       sync.RWMutex
+	provider Provider
+)
+
+// SetProvider replaces the provider of the feature flags, nil restores the static provider read from FEATURE_FLAGS.
+func SetProvider(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	provider = p
+}
+
+// GetProvider returns the provider of the feature flags.
+// The static provider is loaded from FEATURE_FLAGS at the first call if none was set.
+func GetProvider() Provider {
+	mu.RLock()
+	p := provider
+	mu.RUnlock()
+	if p != nil {
+		return p
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if provider == nil {
+		provider = LoadStaticProvider()
+	}
+	return provider
+}
+
+// Reload reloads the static provider from FEATURE_FLAGS, the next lookups use the new values.
+// A provider set with SetProvider that is not a static provider, e.g. a remote flag service, is kept.
+func Reload() {
+	p := LoadStaticProvider()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := provider.(*StaticProvider); ok || provider == nil {
+		provider = p
+	}
+}
+
+// Enabled reports whether the flag is enabled for the tenant the request operates on.
+// The default tenant is used when the context carries no tenant, e.g. before the authentication.
+func Enabled(ctx context.Context, name string) bool {
+	tenantID, ok := metacontext.ExtractTenantID(ctx)
+	if !ok {
+		tenantID = metacontext.DefaultTenantID
+	}
+
+	return EnabledForTenant(tenantID, name)
+}
+
+// EnabledForTenant reports whether the flag is enabled for the given tenant.
+func EnabledForTenant(tenantID int64, name string) bool {
+	value, ok := GetProvider().Lookup(tenantID, name)
+	return ok && value
+}
+
+// Effective returns the value of every known flag for the tenant the request operates on.
+func Effective(ctx context.Context) map[string]bool {
+	flags := make(map[string]bool, len(Known))
+	for _, name := range Known {
+		flags[name] = Enabled(ctx, name)
+	}
+	return flags
+}
+
+// StaticProvider is a Provider holding fixed values, global or per tenant.
+// A value set for a tenant overrides the global value of the flag.
+type StaticProvider struct {
+	global  map[string]bool
+	tenants map[int64]map[string]bool
+}
+
+// NewStaticProvider parses the comma-separated list of `name=bool` pairs, a pair prefixed with a tenant ID
+// and a colon applies to this tenant only, e.g. `enforce_2fa=false,2:enforce_2fa=true`.
+func NewStaticProvider(spec string) (*StaticProvider, error) {
+	p := &StaticProvider{
+		global:  make(map[string]bool),
+		tenants: make(map[int64]map[string]bool),
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid feature flag %q, expected name=bool", pair)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of the feature flag %q: %s", key, value)
+		}
+
+		key = strings.TrimSpace(key)
+		if tenant, name, ok := strings.Cut(key, ":"); ok {
+			tenantID, err := strconv.ParseInt(strings.TrimSpace(tenant), 10, 64)
+			if err != nil || tenantID < 1 {
+				return nil, fmt.Errorf("invalid tenant of the feature flag %q", key)
+			}
+			if p.tenants[tenantID] == nil {
+				p.tenants[tenantID] = make(map[string]bool)
+			}
+			p.tenants[tenantID][strings.ToLower(strings.TrimSpace(name))] = enabled
+			continue
+		}
+
+		p.global[strings.ToLower(key)] = enabled
+	}
+
+	return p, nil
+}
+
+// LoadStaticProvider loads the static provider from the FEATURE_FLAGS environment variable.
+// An invalid list is logged and every flag is disabled.
+func LoadStaticProvider() *StaticProvider {
+	p, err := NewStaticProvider(os.Getenv("FEATURE_FLAGS"))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Ignoring FEATURE_FLAGS: %v", err), nil)
+		p, _ = NewStaticProvider("")
+	}
+	return p
+}
+
+// Lookup returns the value of the flag for the tenant, falling back to its global value.
+func (p *StaticProvider) Lookup(tenantID int64, name string) (bool, bool) {
+	name = strings.ToLower(name)
+	if value, ok := p.tenants[tenantID][name]; ok {
+		return value, true
+	}
+
+	value, ok := p.global[name]
+	return value, ok
+}
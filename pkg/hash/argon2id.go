@@ -0,0 +1,115 @@
+package hash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// argon2SaltLength is the length in bytes of the random salt of the Argon2id hashes
+	argon2SaltLength = 16
+
+	// argon2KeyLength is the length in bytes of the key derived by Argon2id
+	argon2KeyLength = 32
+)
+
+// Argon2Params holds the cost parameters of Argon2id: the memory in KiB, the number of passes over the memory,
+// and the number of threads.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultArgon2Params are the parameters of Argon2id when they are not configured,
+// those of the first recommended option of RFC 9106 with less memory.
+var DefaultArgon2Params = Argon2Params{Memory: 64 * 1024, Iterations: 3, Parallelism: 2}
+
+// Argon2idHasher hashes the passwords with Argon2id and its parameters. The hashes are in the PHC string format,
+// e.g. `$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>`, with the salt and the key in unpadded base64.
+type Argon2idHasher struct {
+	Params Argon2Params
+}
+
+// NewArgon2idHasher returns the Argon2id hasher with the given parameters.
+func NewArgon2idHasher(params Argon2Params) *Argon2idHasher {
+	return &Argon2idHasher{Params: params}
+}
+
+// Hash returns the Argon2id hash of the password, with a new random salt.
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to hash password with argon2id: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, argon2KeyLength)
+	return encodeArgon2id(h.Params, salt, key), nil
+}
+
+// Verify reports whether the password matches the hash, of any supported algorithm.
+func (h *Argon2idHasher) Verify(hash string, password string) (bool, error) {
+	return Verify(hash, password)
+}
+
+// NeedsRehash reports whether the hash is not an Argon2id hash with the parameters of the hasher.
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, key, err := decodeArgon2id(hash)
+	return err != nil || params != h.Params || len(key) != argon2KeyLength
+}
+
+// verifyArgon2id reports whether the password matches the Argon2id hash, derived again with the parameters of the hash.
+func verifyArgon2id(hash string, password string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	derived := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(derived, key) == 1, nil
+}
+
+// encodeArgon2id encodes the parameters, the salt and the key of an Argon2id hash in the PHC string format.
+func encodeArgon2id(params Argon2Params, salt []byte, key []byte) string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", Argon2id, argon2.Version,
+		params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// decodeArgon2id decodes an Argon2id hash in the PHC string format into its parameters, salt and key.
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	// The hash starts with a $, so its first part is empty
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != Argon2id {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: unsupported version %s", ErrMalformedHash, parts[2])
+	}
+
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: invalid parameters %s", ErrMalformedHash, parts[3])
+	}
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: invalid parameters %s", ErrMalformedHash, parts[3])
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: invalid salt", ErrMalformedHash)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: invalid key", ErrMalformedHash)
+	}
+
+	return params, salt, key, nil
+}
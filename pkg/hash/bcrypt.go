@@ -0,0 +1,54 @@
+package hash
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptHasher hashes the passwords with bcrypt at its cost. The hashes are in the modular crypt format of bcrypt,
+// e.g. `$2a$10$...`, which encodes the cost along with the salt and the key.
+type BcryptHasher struct {
+	Cost int
+}
+
+// NewBcryptHasher returns the bcrypt hasher at the given cost.
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{Cost: cost}
+}
+
+// Hash returns the bcrypt hash of the password.
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password with bcrypt: %w", err)
+	}
+	return string(hashed), nil
+}
+
+// Verify reports whether the password matches the hash, of any supported algorithm.
+func (h *BcryptHasher) Verify(hash string, password string) (bool, error) {
+	return Verify(hash, password)
+}
+
+// NeedsRehash reports whether the hash is not a bcrypt hash at the cost of the hasher.
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	if AlgorithmOf(hash) != Bcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// verifyBcrypt reports whether the password matches the bcrypt hash.
+func verifyBcrypt(hash string, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+}
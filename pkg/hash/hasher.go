@@ -0,0 +1,130 @@
+package hash
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// Bcrypt is the name of the bcrypt algorithm, the default one
+	Bcrypt = "bcrypt"
+
+	// Argon2id is the name of the Argon2id algorithm
+	Argon2id = "argon2id"
+
+	// DefaultBcryptMaxCost is the maximum cost of bcrypt when BCRYPT_MAX_COST is not set,
+	// each step doubles the time of a hash, and a hash at this cost already takes about a second
+	DefaultBcryptMaxCost = 14
+)
+
+var (
+	// ErrUnknownAlgorithm is returned when a hash is not encoded by one of the supported algorithms.
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+
+	// ErrMalformedHash is returned when a hash names a supported algorithm but cannot be decoded.
+	ErrMalformedHash = errors.New("malformed password hash")
+)
+
+// Hasher hashes the passwords with an algorithm and its parameters, and verifies them against their hash.
+// The hashes encode their algorithm and parameters, so a hasher verifies the hashes of every supported algorithm,
+// and NeedsRehash tells the hashes to upgrade, i.e. of another algorithm or with other parameters than the current ones.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(hash string, password string) (bool, error)
+	NeedsRehash(hash string) bool
+}
+
+// Config holds the algorithm hashing the new passwords and the parameters of each algorithm.
+// BcryptCost is never above BcryptMaxCost.
+type Config struct {
+	Algorithm     string
+	BcryptCost    int
+	BcryptMaxCost int
+	Argon2        Argon2Params
+}
+
+// LoadConfig loads the hashing configuration from environment variables.
+// PASSWORD_HASH_ALGORITHM is the algorithm of the new hashes, `bcrypt` (default) or `argon2id`,
+// BCRYPT_COST the cost of bcrypt, capped at BCRYPT_MAX_COST, and ARGON2_MEMORY_KIB, ARGON2_ITERATIONS and ARGON2_PARALLELISM
+// the parameters of Argon2id. The unset or invalid values fall back to their defaults.
+func LoadConfig() Config {
+	cfg := Config{
+		Algorithm:     Bcrypt,
+		BcryptCost:    bcrypt.DefaultCost,
+		BcryptMaxCost: DefaultBcryptMaxCost,
+		Argon2:        DefaultArgon2Params,
+	}
+
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("PASSWORD_HASH_ALGORITHM")), Argon2id) {
+		cfg.Algorithm = Argon2id
+	}
+	if cost, ok := positiveEnv("BCRYPT_COST"); ok && cost >= bcrypt.MinCost && cost <= bcrypt.MaxCost {
+		cfg.BcryptCost = cost
+	}
+	if maxCost, ok := positiveEnv("BCRYPT_MAX_COST"); ok && maxCost >= bcrypt.MinCost && maxCost <= bcrypt.MaxCost {
+		cfg.BcryptMaxCost = maxCost
+	}
+	cfg.BcryptCost = min(cfg.BcryptCost, cfg.BcryptMaxCost)
+	if memory, ok := positiveEnv("ARGON2_MEMORY_KIB"); ok {
+		cfg.Argon2.Memory = uint32(memory)
+	}
+	if iterations, ok := positiveEnv("ARGON2_ITERATIONS"); ok {
+		cfg.Argon2.Iterations = uint32(iterations)
+	}
+	if parallelism, ok := positiveEnv("ARGON2_PARALLELISM"); ok && parallelism <= 255 {
+		cfg.Argon2.Parallelism = uint8(parallelism)
+	}
+
+	return cfg
+}
+
+// positiveEnv returns the value of an environment variable holding a positive integer.
+func positiveEnv(key string) (int, bool) {
+	value, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+	if err != nil || value <= 0 {
+		return 0, false
+	}
+	return value, true
+}
+
+// New returns the hasher of the algorithm of the configuration.
+func New(cfg Config) Hasher {
+	if cfg.Algorithm == Argon2id {
+		return NewArgon2idHasher(cfg.Argon2)
+	}
+	return NewBcryptHasher(cfg.BcryptCost)
+}
+
+// FromEnv returns the hasher configured from environment variables.
+func FromEnv() Hasher {
+	return New(LoadConfig())
+}
+
+// AlgorithmOf returns the name of the algorithm encoded in the hash, or an empty string if it is not supported.
+func AlgorithmOf(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$"+Argon2id+"$"):
+		return Argon2id
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return Bcrypt
+	default:
+		return ""
+	}
+}
+
+// Verify reports whether the password matches the hash, whatever its supported algorithm and parameters.
+// It returns an error if the hash cannot be decoded, not for a wrong password.
+func Verify(hash string, password string) (bool, error) {
+	switch AlgorithmOf(hash) {
+	case Bcrypt:
+		return verifyBcrypt(hash, password)
+	case Argon2id:
+		return verifyArgon2id(hash, password)
+	default:
+		return false, ErrUnknownAlgorithm
+	}
+}
@@ -0,0 +1,101 @@
+package hash
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+)
+
+const (
+	// DefaultMinHashLatency and DefaultMaxHashLatency are the bounds of the latency of a hash when they are not configured
+	DefaultMinHashLatency = 50 * time.Millisecond
+	DefaultMaxHashLatency = time.Second
+
+	// benchmarkPassword is the password hashed to measure the latency of the hasher
+	benchmarkPassword = "B3nchmark-P@ssw0rd"
+)
+
+var (
+	// ErrHashTooFast is returned when a hash takes less than the minimum latency, the hashes are cheap to brute force.
+	ErrHashTooFast = errors.New("password hashing is faster than the minimum latency")
+
+	// ErrHashTooSlow is returned when a hash takes more than the maximum latency, the logins and the creations
+	// of the users would be slowed down and would exhaust the CPU under load.
+	ErrHashTooSlow = errors.New("password hashing is slower than the maximum latency")
+)
+
+// LatencyGuard checks at startup that hashing a password takes a time within a band, e.g. after a change of BCRYPT_COST
+// or of the machine. A hash outside the band is warned about, or refuses the startup when Refuse is set.
+// The latency is measured on the injected clock.
+type LatencyGuard struct {
+	MinLatency time.Duration
+	MaxLatency time.Duration
+	Refuse     bool
+	Clock      clock.Clock
+}
+
+// LoadLatencyGuard loads the latency band from environment variables.
+// PASSWORD_HASH_MIN_LATENCY_MS and PASSWORD_HASH_MAX_LATENCY_MS are the bounds of the band in milliseconds,
+// they fall back to their defaults when they are unset, invalid or when the minimum is not below the maximum.
+// PASSWORD_HASH_LATENCY_POLICY is `WARN` (default) to warn about a hash outside the band, or `REFUSE` to refuse the startup.
+func LoadLatencyGuard(clk clock.Clock) LatencyGuard {
+	guard := LatencyGuard{
+		MinLatency: DefaultMinHashLatency,
+		MaxLatency: DefaultMaxHashLatency,
+		Refuse:     strings.EqualFold(strings.TrimSpace(os.Getenv("PASSWORD_HASH_LATENCY_POLICY")), "REFUSE"),
+		Clock:      clk,
+	}
+
+	minLatency, minOk := positiveEnv("PASSWORD_HASH_MIN_LATENCY_MS")
+	maxLatency, maxOk := positiveEnv("PASSWORD_HASH_MAX_LATENCY_MS")
+	if minOk {
+		guard.MinLatency = time.Duration(minLatency) * time.Millisecond
+	}
+	if maxOk {
+		guard.MaxLatency = time.Duration(maxLatency) * time.Millisecond
+	}
+	if guard.MinLatency >= guard.MaxLatency {
+		guard.MinLatency, guard.MaxLatency = DefaultMinHashLatency, DefaultMaxHashLatency
+	}
+
+	return guard
+}
+
+// Benchmark returns the time the hasher takes to hash a password.
+func (g LatencyGuard) Benchmark(h Hasher) (time.Duration, error) {
+	start := g.Clock.Now()
+	if _, err := h.Hash(benchmarkPassword); err != nil {
+		return 0, err
+	}
+	return g.Clock.Now().Sub(start), nil
+}
+
+// Check benchmarks the hasher and returns the latency of a hash.
+// It returns an error wrapping ErrHashTooFast or ErrHashTooSlow for a latency outside the band when the policy refuses it,
+// and passes the same message to warn when the policy only warns about it.
+func (g LatencyGuard) Check(h Hasher, warn func(message string)) (time.Duration, error) {
+	latency, err := g.Benchmark(h)
+	if err != nil {
+		return 0, fmt.Errorf("failed to benchmark password hashing: %w", err)
+	}
+
+	var outside error
+	switch {
+	case latency < g.MinLatency:
+		outside = fmt.Errorf("%w: a hash took %s, below the minimum of %s, raise the cost of the hashes", ErrHashTooFast, latency, g.MinLatency)
+	case latency > g.MaxLatency:
+		outside = fmt.Errorf("%w: a hash took %s, above the maximum of %s, lower the cost of the hashes", ErrHashTooSlow, latency, g.MaxLatency)
+	default:
+		return latency, nil
+	}
+
+	if g.Refuse {
+		return latency, outside
+	}
+	warn(outside.Error())
+	return latency, nil
+}
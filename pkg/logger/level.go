@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+/**
+* The level is the minimum level of the logged messages, the messages of a lower level are dropped.
+* Every message is logged by default, the level is set from LOG_LEVEL and can be changed at runtime,
+* e.g. when the configuration is reloaded, the next messages are filtered with the new level.
+ */
+
+// level holds the current logrus level, TraceLevel logs every message
+var level atomic.Uint32
+
+func init() {
+	level.Store(uint32(logrus.TraceLevel))
+}
+
+// ParseLevel parses a level name such as DEBUG, INFO, WARN or ERROR, case-insensitively.
+// An empty name is the default level, which logs every message.
+func ParseLevel(name string) (logrus.Level, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return logrus.TraceLevel, nil
+	}
+
+	lvl, err := logrus.ParseLevel(name)
+	if err != nil || lvl < logrus.ErrorLevel {
+		return 0, fmt.Errorf("invalid log level %q, expected TRACE, DEBUG, INFO, WARN or ERROR", name)
+	}
+	return lvl, nil
+}
+
+// GetLevel returns the minimum level of the logged messages.
+func GetLevel() logrus.Level {
+	return logrus.Level(level.Load())
+}
+
+// SetLevel sets the minimum level of the logged messages, it is safe to call while logging.
+// The request logs are Info messages, they are dropped from WARN on.
+func SetLevel(lvl logrus.Level) {
+	Init()
+	level.Store(uint32(lvl))
+
+	// Every logger only receives the messages of its own level, so it drops them below the minimum level
+	for _, l := range []*logrus.Logger{RequestLogger, InfoLogger, WarnLogger, ErrorLogger, FatalLogger, PanicLogger, TraceLogger, DebugLogger} {
+		if l != nil {
+			l.SetLevel(lvl)
+		}
+	}
+}
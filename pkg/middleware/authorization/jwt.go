@@ -3,12 +3,16 @@ package authorization
 import (
 	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 
 	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/featureflag"
 	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
 	jwtutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/jwt-util"
 )
@@ -20,23 +24,87 @@ import (
 * If the token is invalid or missing, it returns an unauthorized error response.
  */
 var (
-	TokenType string
-	JWTSecret string
+	TokenType    string
+	JWTSecret    string
+	JWTAlgorithm string
 )
 
+// AccessTokenCookie is the name of the cookie carrying the access token when the cookie_auth feature flag is enabled.
+const AccessTokenCookie = "access_token"
+
+// jwtSecretMu guards JWTSecret, which is replaced when the secret provider rotates it
+var jwtSecretMu sync.RWMutex
+
 // LoadEnv loads environment variables
 func LoadEnv() {
 	TokenType = os.Getenv("TOKEN_TYPE")
-	JWTSecret = os.Getenv("JWT_SECRET")
+	SetJWTSecret(os.Getenv("JWT_SECRET"))
+	JWTAlgorithm = os.Getenv("JWT_ALGORITHM")
+}
+
+// SetJWTSecret replaces the HS256 secret, e.g. rotated by the secret provider.
+// The middlewares load their keys again at the next request, the tokens signed with the previous secret are then rejected.
+func SetJWTSecret(secret string) {
+	jwtSecretMu.Lock()
+	defer jwtSecretMu.Unlock()
+
+	JWTSecret = secret
+}
+
+// getJWTSecret returns the current HS256 secret.
+func getJWTSecret() string {
+	jwtSecretMu.RLock()
+	defer jwtSecretMu.RUnlock()
+
+	return JWTSecret
+}
+
+// keySource holds the keys of a middleware, loaded again when the secret they were loaded from is replaced.
+type keySource struct {
+	mu     sync.RWMutex
+	secret string
+	keys   *jwtutil.KeySet
+	err    error
+}
+
+// get returns the keys of the current secret, a failure to load them is returned as is.
+func (s *keySource) get() (*jwtutil.KeySet, error) {
+	secret := getJWTSecret()
+
+	s.mu.RLock()
+	keys, err, current := s.keys, s.err, s.secret == secret
+	s.mu.RUnlock()
+	if current {
+		return keys, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys, s.err = jwtutil.LoadKeySet(JWTAlgorithm, secret)
+	s.secret = secret
+	return s.keys, s.err
 }
 
 func JwtValidation() gin.HandlerFunc {
 	// Load environment variables
 	LoadEnv()
 
+	// Load the keys of the configured algorithm once, and again when the secret is rotated
+	// The tokens are verified with the key matching their kid
+	// A failure is told by every request, like a token that cannot be verified
+	source := &keySource{}
+	source.keys, source.err = jwtutil.LoadKeySet(JWTAlgorithm, JWTSecret)
+	source.secret = JWTSecret
+
 	return func(c *gin.Context) {
 		// Get the token from the request header
+		// In cookie mode, a request without the header may carry the token in the access token cookie instead
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" && featureflag.Enabled(c.Request.Context(), featureflag.CookieAuth) {
+			if cookie, err := c.Cookie(AccessTokenCookie); err == nil && cookie != "" {
+				authHeader = TokenType + " " + cookie
+			}
+		}
 		if authHeader == "" {
 			httputil.Unauthorized(c, "No token provided", "Authorization header is missing")
 			c.Abort()
@@ -59,35 +127,16 @@ func JwtValidation() gin.HandlerFunc {
 			return
 		}
 
-		// Parse the token and validate it
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			// For HS256 signing method
-			if token.Method.Alg() == jwt.SigningMethodHS256.Alg() {
-				// Validate the token signing method
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-
-				// Return the secret key for validation
-				return []byte(JWTSecret), nil
-			}
-
-			// For RS256 signing method
-			// Load the public key from the environment variable
-			publicKey, err := jwtutil.LoadPublicKey()
-			if err != nil {
-				return nil, err
-			}
-
-			// Validate the token signing method
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
-			// Return the public key for validation
-			return publicKey, nil
-		})
+		keys, keysErr := source.get()
+		if keysErr != nil {
+			httputil.Unauthorized(c, "Invalid token", keysErr.Error())
+			c.Abort()
+			return
+		}
 
+		// Parse the token and validate it with the key matching its kid
+		// The algorithm of the token must be the one of the key, a token of another algorithm is rejected
+		token, err := keys.Parse(tokenStr)
 		if err != nil {
 			httputil.Unauthorized(c, "Invalid token", err.Error())
 			c.Abort()
@@ -106,15 +155,50 @@ func JwtValidation() gin.HandlerFunc {
 		// Convert the user ID to int64
 		userID, _ := jwtutil.GetInt64Claim(claims, "userid")
 
+		// Get the tenant of the user, the tokens issued before the multi-tenancy have none
+		tenantID, err := jwtutil.GetInt64Claim(claims, "tenantid")
+		if err != nil || tenantID < 1 {
+			tenantID = metacontext.DefaultTenantID
+		}
+
+		// The time-limited roles of the token are dropped once expired, the token stays valid for the other roles
+		// The permissions embedded in the token are those of all its roles, they are looked up again without the expired ones
+		roles := jwtutil.GetStringSliceClaim(claims, "roles")
+		permissions := jwtutil.GetStringSliceClaim(claims, "permissions")
+		if expiries := jwtutil.GetInt64MapClaim(claims, "rolesexp"); len(expiries) > 0 {
+			now := time.Now().Unix()
+			granted := len(roles)
+			roles = slices.DeleteFunc(roles, func(role string) bool {
+				expiresAt, ok := expiries[role]
+				return ok && expiresAt <= now
+			})
+			if len(roles) < granted {
+				permissions = nil
+			}
+		}
+
 		// Inject user information into the request context
+		// The tokens issued before the token ID was added have an empty TokenID
 		meta := metacontext.UserInformationMeta{
-			UserID:   userID,
-			Username: claims["username"].(string),
-			Email:    claims["email"].(string),
-			Roles:    jwtutil.GetStringSliceClaim(claims, "roles"),
+			UserID:      userID,
+			Username:    jwtutil.GetStringClaim(claims, "username"),
+			Email:       jwtutil.GetStringClaim(claims, "email"),
+			Roles:       roles,
+			Permissions: permissions,
+			GroupIDs:    jwtutil.GetInt64SliceClaim(claims, "groups"),
+			TokenID:     jwtutil.GetStringClaim(claims, "jti"),
+			TenantID:    tenantID,
+		}
+
+		// The impersonation tokens carry the ID of the admin acting as the user
+		if impersonatorID, err := jwtutil.GetInt64Claim(claims, "impersonator"); err == nil {
+			meta.ImpersonatorID = &impersonatorID
 		}
 		ctx := metacontext.InjectUserInformationMeta(c.Request.Context(), meta)
 
+		// Restrict the request to the tenant of the user, the tenant middleware may select another one
+		ctx = metacontext.InjectTenantID(ctx, tenantID)
+
 		// Set the new request context with user information
 		c.Request = c.Request.WithContext(ctx)
 
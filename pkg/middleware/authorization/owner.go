@@ -0,0 +1,66 @@
+package authorization
+
+import (
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+/**
+* OwnerOrRoles is a middleware function that restricts a resource of a user, identified by a path parameter, to its owner.
+* The callers holding one of the given roles, e.g. the admins, and the system actors access the resources of every user.
+* The other callers are denied with DenyAccess: a 403 Forbidden, or in strict mode the 404 Not Found of a missing resource,
+* so a caller probing the IDs of other users cannot tell an existing account from a missing one.
+* The handlers denying a caller on their own, e.g. after a lookup, go through DenyAccess as well for the same answer.
+ */
+func OwnerOrRoles(param string, resource string, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Retrieve the ID validated from the URL parameter
+		ownerID, ok := pathparam.Int64(c, param)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		// Extract user metadata from the context
+		meta, ok := metacontext.ExtractUserInformationMeta(c.Request.Context())
+		if !ok {
+			httputil.InternalServerError(c, "Failed to extract metadata", "Unable to extract user metadata from context")
+			c.Abort()
+			return
+		}
+
+		if meta.IsSystem || meta.UserID == ownerID || slices.ContainsFunc(meta.Roles, func(role string) bool {
+			return slices.Contains(roles, role)
+		}) {
+			c.Next()
+			return
+		}
+
+		DenyAccess(c, resource, "Only the owner may access the "+strings.ToLower(resource)+" resources of another user")
+	}
+}
+
+// DenyAccess answers a caller denied a resource of another user and aborts the request.
+// It answers a 403 Forbidden with the given detail, or in strict mode the same 404 Not Found as a missing resource,
+// e.g. "User not found" for the resource "User", so the answer does not tell whether the resource exists.
+func DenyAccess(c *gin.Context, resource string, detail string) {
+	if IsStrictModeEnabled() {
+		httputil.NotFound(c, resource+" not found", "No "+strings.ToLower(resource)+" found with the given ID")
+	} else {
+		httputil.Forbidden(c, "Forbidden", detail)
+	}
+	c.Abort()
+}
+
+// IsStrictModeEnabled reports whether the callers denied a resource of another user are answered as if it did not exist.
+// It retrieves the toggle from an environment variable, the callers are answered with a 403 Forbidden unless it is TRUE.
+func IsStrictModeEnabled() bool {
+	return strings.ToUpper(os.Getenv("AUTHORIZATION_STRICT_MODE")) == "TRUE"
+}
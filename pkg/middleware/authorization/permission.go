@@ -0,0 +1,89 @@
+package authorization
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+/**
+* RequirePermission is a middleware function that checks if the user holds a permission to access a resource.
+* It complements RoleBasedAccessControl for the resources guarded by a fine-grained permission, e.g. `consumers:read`.
+* The permissions embedded in the access token are used as they are, so most requests do not look them up.
+* The tokens without them, or whose roles have partly expired, fall back to the resolver set with SetPermissionResolver,
+* and the permissions it returns are kept in the request context for the next checks of the request.
+* If the user does not hold the permission, it returns a forbidden response and aborts the request.
+ */
+
+// PermissionResolver returns the effective permissions of a user, for the tokens that do not carry them.
+type PermissionResolver func(ctx context.Context, userID int64) ([]string, error)
+
+var (
+	resolverMu         sync.RWMutex
+	permissionResolver PermissionResolver
+)
+
+// SetPermissionResolver sets the resolver looking up the permissions missing from the tokens.
+func SetPermissionResolver(resolver PermissionResolver) {
+	resolverMu.Lock()
+	defer resolverMu.Unlock()
+
+	permissionResolver = resolver
+}
+
+// getPermissionResolver returns the resolver set with SetPermissionResolver, nil if none is set.
+func getPermissionResolver() PermissionResolver {
+	resolverMu.RLock()
+	defer resolverMu.RUnlock()
+
+	return permissionResolver
+}
+
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Extract user metadata from the context
+		meta, ok := metacontext.ExtractUserInformationMeta(c.Request.Context())
+		if !ok {
+			httputil.InternalServerError(c, "Failed to extract metadata", "Unable to extract user metadata from context")
+			c.Abort()
+			return
+		}
+
+		// Look up the permissions the token does not carry, once for the whole request
+		if meta.Permissions == nil {
+			resolver := getPermissionResolver()
+			if resolver == nil {
+				httputil.InternalServerError(c, "Failed to resolve permissions", "No permission resolver is configured")
+				c.Abort()
+				return
+			}
+
+			permissions, err := resolver(c.Request.Context(), meta.UserID)
+			if err != nil {
+				httputil.ServerError(c, "Failed to resolve permissions", fmt.Errorf("failed to resolve permissions of user %d: %w", meta.UserID, err))
+				c.Abort()
+				return
+			}
+			if permissions == nil {
+				permissions = []string{}
+			}
+
+			meta.Permissions = permissions
+			c.Request = c.Request.WithContext(metacontext.InjectUserInformationMeta(c.Request.Context(), meta))
+		}
+
+		if !slices.Contains(meta.Permissions, perm) {
+			httputil.Forbidden(c, "Access denied", "User does not have the required permission")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
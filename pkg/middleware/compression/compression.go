@@ -0,0 +1,297 @@
+package compression
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+)
+
+/**
+* Compression is a middleware function that compresses the responses with gzip or deflate,
+* picked from the Accept-Encoding header of the request (gzip is preferred when both are accepted).
+* The response is held back until it reaches the minimum size: a smaller response is sent as is,
+* since compressing a few bytes costs more than it saves.
+* A handler flushing its response (e.g. a streamed export) is compressed from the first flush,
+* and every flush reaches the client, so the streaming is not broken by the buffering.
+ */
+const (
+	defaultMinSize = 1024
+
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// compressibleTypes are the media types worth compressing, the images and archives are already compressed.
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/problem+json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/javascript",
+	"image/svg+xml",
+}
+
+// CompressionConfig holds the compression level and the minimum size of a compressed response.
+// A level of 0 disables the compression.
+type CompressionConfig struct {
+	Level   int
+	MinSize int
+}
+
+// LoadCompressionConfig loads the compression configuration from environment variables.
+// COMPRESSION_LEVEL is a level of 0 (disabled) to 9 (best compression), -1 being the default level of gzip,
+// and COMPRESSION_MIN_SIZE is the minimum size in bytes of a compressed response.
+func LoadCompressionConfig() CompressionConfig {
+	cfg := CompressionConfig{
+		Level:   gzip.DefaultCompression,
+		MinSize: defaultMinSize,
+	}
+
+	if value := os.Getenv("COMPRESSION_LEVEL"); value != "" {
+		level, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || level < gzip.DefaultCompression || level > gzip.BestCompression {
+			logger.Warn(fmt.Sprintf("Ignoring invalid compression level: %s", value), nil)
+		} else {
+			cfg.Level = level
+		}
+	}
+
+	if value := os.Getenv("COMPRESSION_MIN_SIZE"); value != "" {
+		size, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || size < 0 {
+			logger.Warn(fmt.Sprintf("Ignoring invalid compression minimum size: %s", value), nil)
+		} else {
+			cfg.MinSize = size
+		}
+	}
+
+	return cfg
+}
+
+// Compression returns the compression middleware configured from environment variables.
+func Compression() gin.HandlerFunc {
+	return CompressionWithConfig(LoadCompressionConfig())
+}
+
+// CompressionWithConfig returns the compression middleware using the given configuration.
+func CompressionWithConfig(cfg CompressionConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.Level == gzip.NoCompression {
+			c.Next()
+			return
+		}
+
+		// The response depends on the Accept-Encoding header, whether it is compressed or not
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		// A HEAD response has no body, and a range is an offset in the uncompressed content
+		encoding := NegotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead || c.GetHeader("Range") != "" {
+			c.Next()
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: c.Writer, cfg: cfg, encoding: encoding}
+		c.Writer = cw
+
+		// Hand the writer back to gin also when the handler panics, the buffered part of its response is dropped
+		// and the recovery middleware answers with its own response
+		defer func() {
+			c.Writer = cw.ResponseWriter
+		}()
+
+		c.Next()
+
+		cw.finish()
+	}
+}
+
+// NegotiateEncoding returns the encoding to compress the response with, gzip or deflate,
+// given the Accept-Encoding header of the request. It returns an empty string when neither is accepted.
+func NegotiateEncoding(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		qualities[name] = q
+	}
+
+	// An encoding that is not listed inherits the quality of the wildcard
+	quality := func(name string) float64 {
+		if q, ok := qualities[name]; ok {
+			return q
+		}
+		return qualities["*"]
+	}
+
+	gzipQ, deflateQ := quality(encodingGzip), quality(encodingDeflate)
+	switch {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return encodingGzip
+	case deflateQ > 0:
+		return encodingDeflate
+	default:
+		return ""
+	}
+}
+
+// compressWriter buffers the response until it reaches the minimum size, then compresses it.
+type compressWriter struct {
+	gin.ResponseWriter
+	cfg      CompressionConfig
+	encoding string
+
+	buf     []byte
+	decided bool
+	encoder io.WriteCloser
+	flusher interface{ Flush() error }
+}
+
+// Write buffers the data until the response is large enough to be compressed.
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		return w.write(data)
+	}
+
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.cfg.MinSize {
+		if err := w.decide(w.compressible()); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// WriteString buffers the string like Write.
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow sends the headers of a response without a body, which is never compressed.
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided && len(w.buf) == 0 {
+		w.decided = true
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Written reports whether the response has been written, including the part held back in the buffer.
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush sends what the handler wrote so far, a streamed response is compressed from its first flush.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(w.compressible()); err != nil {
+			return
+		}
+	}
+	if w.flusher != nil {
+		if err := w.flusher.Flush(); err != nil {
+			return
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+// finish writes the response that stayed below the minimum size, and completes the compressed stream.
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if len(w.buf) == 0 {
+			return
+		}
+		w.decide(false)
+	}
+	if w.encoder != nil {
+		if err := w.encoder.Close(); err != nil {
+			logger.Error(fmt.Sprintf("Failed to complete the compressed response: %v", err), nil)
+		}
+	}
+}
+
+// compressible reports whether the response may be compressed, given its status and headers.
+func (w *compressWriter) compressible() bool {
+	status := w.ResponseWriter.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+
+	header := w.ResponseWriter.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(w.buf)
+	}
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// decide sends the headers and the buffered data, either through the encoder or as is.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+
+	if compress {
+		header := w.ResponseWriter.Header()
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+		// The compressed representation is no longer byte-for-byte the one of a strong ETag
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+
+		if w.encoding == encodingGzip {
+			gz, _ := gzip.NewWriterLevel(w.ResponseWriter, w.cfg.Level)
+			w.encoder, w.flusher = gz, gz
+		} else {
+			fl, _ := flate.NewWriter(w.ResponseWriter, w.cfg.Level)
+			w.encoder, w.flusher = fl, fl
+		}
+	}
+
+	buf := w.buf
+	w.buf = nil
+	_, err := w.write(buf)
+	return err
+}
+
+// write sends the data through the encoder when the response is compressed.
+func (w *compressWriter) write(data []byte) (int, error) {
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	return w.ResponseWriter.Write(data)
+}
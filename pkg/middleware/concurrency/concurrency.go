@@ -0,0 +1,137 @@
+package concurrency
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+/**
+* ConcurrencyLimit is a middleware function that bounds the number of requests processed at the same time,
+* so a spike of requests cannot exhaust the connections of the database pool.
+* A request arriving when the limit is reached waits up to the queue timeout for a slot to free,
+* it is then shed with a 503 Service Unavailable and a Retry-After header, without reaching its handler.
+* The slot of a request is freed as soon as its handler returns.
+ */
+const (
+	// retryAfterSeconds is the delay suggested to the shed requests before they retry
+	retryAfterSeconds = 1
+)
+
+// ConcurrencyConfig holds the maximum number of requests in flight and how long a request waits for a slot.
+// A MaxInFlight of 0 disables the limit, and a QueueTimeout of 0 sheds the requests beyond the limit at once.
+// The requests whose path starts with one of the ExemptPrefixes are never limited, e.g. the health probes.
+type ConcurrencyConfig struct {
+	MaxInFlight    int
+	QueueTimeout   time.Duration
+	ExemptPrefixes []string
+}
+
+// LoadConcurrencyConfig loads the concurrency configuration from environment variables.
+// MAX_CONCURRENT_REQUESTS is the maximum number of requests in flight (0 or unset for no limit),
+// and REQUEST_QUEUE_TIMEOUT the time a request waits for a slot, in seconds or as a duration (e.g. `500ms`).
+// The health probes are exempted, a busy instance is still alive and must not be restarted.
+func LoadConcurrencyConfig() ConcurrencyConfig {
+	cfg := ConcurrencyConfig{
+		ExemptPrefixes: []string{"/health/"},
+	}
+
+	if value := os.Getenv("MAX_CONCURRENT_REQUESTS"); value != "" {
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit < 0 {
+			logger.Warn(fmt.Sprintf("Ignoring invalid maximum of concurrent requests: %s", value), nil)
+		} else {
+			cfg.MaxInFlight = limit
+		}
+	}
+
+	if value := os.Getenv("REQUEST_QUEUE_TIMEOUT"); value != "" {
+		d, err := parseDuration(value)
+		if err != nil || d < 0 {
+			logger.Warn(fmt.Sprintf("Ignoring invalid request queue timeout: %s", value), nil)
+		} else {
+			cfg.QueueTimeout = d
+		}
+	}
+
+	return cfg
+}
+
+// parseDuration parses a duration, plain numbers are interpreted as seconds.
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// ConcurrencyLimit returns the concurrency middleware configured from environment variables.
+func ConcurrencyLimit() gin.HandlerFunc {
+	return ConcurrencyLimitWithConfig(LoadConcurrencyConfig())
+}
+
+// ConcurrencyLimitWithConfig returns the concurrency middleware using the given configuration.
+// The slots are shared by every request going through the returned middleware.
+func ConcurrencyLimitWithConfig(cfg ConcurrencyConfig) gin.HandlerFunc {
+	if cfg.MaxInFlight <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	// A buffered channel is the semaphore, a request holds a slot while its value is in the channel
+	slots := make(chan struct{}, cfg.MaxInFlight)
+
+	return func(c *gin.Context) {
+		for _, prefix := range cfg.ExemptPrefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		if !acquire(c, slots, cfg.QueueTimeout) {
+			c.Abort()
+			return
+		}
+		defer func() { <-slots }()
+
+		c.Next()
+	}
+}
+
+// acquire takes a slot for the request, waiting up to the queue timeout for one to free.
+// It answers the request and returns false when no slot freed in time, or when the client went away while waiting.
+func acquire(c *gin.Context, slots chan struct{}, queueTimeout time.Duration) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if queueTimeout > 0 {
+		timer := time.NewTimer(queueTimeout)
+		defer timer.Stop()
+
+		select {
+		case slots <- struct{}{}:
+			return true
+		case <-c.Request.Context().Done():
+			httputil.ClientClosedRequest(c, "Request cancelled", "The request was cancelled by the client while waiting to be processed")
+			return false
+		case <-timer.C:
+		}
+	}
+
+	c.Header("Retry-After", strconv.Itoa(retryAfterSeconds))
+	httputil.ServiceUnavailable(c, "Service Unavailable", "Too many requests are being processed, retry later")
+	return false
+}
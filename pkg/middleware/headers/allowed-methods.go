@@ -0,0 +1,129 @@
+package headers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+* AllowedMethods is a middleware that answers the requests whose method has no route on a path served by other methods.
+* The methods of a path are derived from the routes registered on the router, so the list always matches the routes.
+* - HEAD is served by the GET route of the path, with the same status and headers and without the body.
+* - OPTIONS is answered with the Allow header, the preflight itself is answered by CorsHeaders.
+* - Any other method gets the Allow header on its 405 response.
+* HEAD is allowed wherever GET is, and OPTIONS everywhere. The router must have HandleMethodNotAllowed enabled,
+* and the middleware must be its first one, so the HEAD requests go through the other middlewares only once.
+ */
+const (
+	// allowHeader is the header listing the methods allowed on a path
+	allowHeader = "Allow"
+)
+
+func AllowedMethods(router *gin.Engine) gin.HandlerFunc {
+	// The routes are read at the first request, once they are all registered
+	var routes gin.RoutesInfo
+	var once sync.Once
+
+	return func(c *gin.Context) {
+		// The request matched a route
+		if c.FullPath() != "" {
+			c.Next()
+			return
+		}
+
+		once.Do(func() { routes = router.Routes() })
+		methods := allowedMethods(routes, c.Request.URL.Path)
+		if len(methods) == 0 {
+			// No route exists for the path at all
+			c.Writer.Header().Del(allowHeader)
+			c.Next()
+			return
+		}
+
+		if c.Request.Method == http.MethodHead && slices.Contains(methods, http.MethodGet) {
+			// Serve the request with the GET route, the body is discarded
+			get := c.Request.Clone(c.Request.Context())
+			get.Method = http.MethodGet
+			c.Writer.Header().Del(allowHeader)
+			router.ServeHTTP(&headResponseWriter{ResponseWriter: c.Writer}, get)
+			c.Abort()
+			return
+		}
+
+		c.Writer.Header().Set(allowHeader, strings.Join(methods, ", "))
+		c.Next()
+	}
+}
+
+// allowedMethods returns the methods having a route that matches the path, followed by HEAD and OPTIONS.
+// It returns nothing if no route matches the path.
+func allowedMethods(routes gin.RoutesInfo, path string) []string {
+	var methods []string
+	for _, route := range routes {
+		if !slices.Contains(methods, route.Method) && matchRoute(route.Path, path) {
+			methods = append(methods, route.Method)
+		}
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+
+	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+	if !slices.Contains(methods, http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
+	}
+	return methods
+}
+
+// matchRoute reports whether the route pattern matches the path.
+// A parameter matches any segment, and a wildcard the rest of the path.
+func matchRoute(pattern string, path string) bool {
+	patternSegments, pathSegments := strings.Split(pattern, "/"), strings.Split(path, "/")
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
+
+// headResponseWriter writes the status and the headers of a response, and discards its body.
+type headResponseWriter struct {
+	gin.ResponseWriter
+}
+
+// WriteHeader writes the status and the headers immediately, no body follows them.
+func (w *headResponseWriter) WriteHeader(code int) {
+	w.ResponseWriter.WriteHeader(code)
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Write discards the body, reporting it as written.
+func (w *headResponseWriter) Write(data []byte) (int, error) {
+	w.ResponseWriter.WriteHeaderNow()
+	return len(data), nil
+}
+
+// WriteString discards the body, reporting it as written.
+func (w *headResponseWriter) WriteString(s string) (int, error) {
+	w.ResponseWriter.WriteHeaderNow()
+	return len(s), nil
+}
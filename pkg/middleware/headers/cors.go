@@ -1,12 +1,20 @@
 package headers
 
-import "github.com/gin-gonic/gin"
+import (
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
 
 /**
 * CorsHeaders is a middleware that sets Cross-Origin Resource Sharing (CORS) headers
 * to allow cross-origin requests from the frontend (e.g., from a different domain or port).
 * It is typically used in web applications to enable communication between the frontend and backend
 * when they are hosted on different origins (domains, protocols, or ports).
+* The allowed origins are read from CORS_ALLOWED_ORIGINS and can be reloaded at runtime with ReloadCorsConfig.
  */
 const (
 	// CORS headers
@@ -26,9 +34,52 @@ const (
 	accessControlAllowCredentialsValue = "true"
 )
 
+// allowedOrigins holds the origins allowed by the CORS headers, loaded from CORS_ALLOWED_ORIGINS at the first request
+var allowedOrigins atomic.Pointer[[]string]
+
+// ParseAllowedOrigins parses the comma-separated list of the allowed origins, an empty list allows the default origin.
+func ParseAllowedOrigins(value string) []string {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		return []string{accessControlAllowOriginValue}
+	}
+	return origins
+}
+
+// ReloadCorsConfig reloads the allowed origins from CORS_ALLOWED_ORIGINS, the next requests use the new origins.
+func ReloadCorsConfig() {
+	origins := ParseAllowedOrigins(os.Getenv("CORS_ALLOWED_ORIGINS"))
+	allowedOrigins.Store(&origins)
+}
+
+// AllowedOrigins returns the origins allowed by the CORS headers.
+func AllowedOrigins() []string {
+	if origins := allowedOrigins.Load(); origins != nil {
+		return *origins
+	}
+
+	ReloadCorsConfig()
+	return *allowedOrigins.Load()
+}
+
 func CorsHeaders() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set(accessControlAllowOrigin, accessControlAllowOriginValue)
+		// The origin of the request is echoed when it is allowed, the first allowed origin is sent otherwise
+		origins := AllowedOrigins()
+		origin := origins[0]
+		if requestOrigin := c.GetHeader("Origin"); slices.Contains(origins, requestOrigin) {
+			origin = requestOrigin
+		}
+		if len(origins) > 1 {
+			c.Writer.Header().Add("Vary", "Origin")
+		}
+
+		c.Writer.Header().Set(accessControlAllowOrigin, origin)
 		c.Writer.Header().Set(accessControlMaxAge, accessControlMaxAgeValue)
 		c.Writer.Header().Set(accessControlAllowMethods, accessControlAllowMethodsValue)
 		c.Writer.Header().Set(accessControlAllowHeaders, accessControlAllowHeadersValue)
@@ -1,12 +1,18 @@
 package headers
 
-import "github.com/gin-gonic/gin"
+import (
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
 
 /**
 * SecurityHeaders is a middleware function that sets various security-related HTTP headers
 * to enhance the security of the web application.
 * These headers help protect against common web vulnerabilities such as clickjacking, MIME type sniffing,
 * cross-site scripting (XSS), and enforce secure connections.
+* Every header can be overridden through environment variables, or disabled by setting the variable to `DISABLED`.
  */
 const (
 	// Security headers
@@ -16,38 +22,132 @@ const (
 	strictTransportSecurity = "Strict-Transport-Security"
 	referrerPolicy          = "Referrer-Policy"
 	permissionsPolicy       = "Permissions-Policy"
+	contentSecurityPolicy   = "Content-Security-Policy"
 
 	// Default values for security headers
-	xFrameOptionsValue           = "DENY"
-	xContentTypeOptionsValue     = "nosniff"
-	xssProtectionValue           = "1; mode=block"
-	strictTransportSecurityValue = "max-age=31536000; includeSubDomains; preload"
-	referrerPolicyValue          = "no-referrer"
-	permissionsPolicyValue       = "geolocation=(self), microphone=()"
+	xFrameOptionsValue                = "DENY"
+	xContentTypeOptionsValue          = "nosniff"
+	xssProtectionValue                = "1; mode=block"
+	strictTransportSecurityValue      = "max-age=31536000; includeSubDomains; preload"
+	referrerPolicyValue               = "no-referrer"
+	permissionsPolicyValue            = "geolocation=(self), microphone=()"
+	contentSecurityPolicyValue        = "default-src 'none'; frame-ancestors 'none'"
+	swaggerContentSecurityPolicyValue = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:"
+	swaggerPathPrefixValue            = "/swagger"
+
+	// disabledHeaderValue is the value used in the environment variables to disable a header
+	disabledHeaderValue = "DISABLED"
 )
 
+// SecurityHeadersConfig holds the values of the security headers applied to every response.
+// An empty value means that the header is not set.
+type SecurityHeadersConfig struct {
+	XFrameOptions                string
+	XContentTypeOptions          string
+	XSSProtection                string
+	StrictTransportSecurity      string
+	ReferrerPolicy               string
+	PermissionsPolicy            string
+	ContentSecurityPolicy        string
+	SwaggerContentSecurityPolicy string
+	SwaggerPathPrefix            string
+	BehindTLSProxy               bool
+}
+
+// DefaultSecurityHeadersConfig returns the default security headers configuration.
+// The defaults are safe out of the box.
+func DefaultSecurityHeadersConfig() SecurityHeadersConfig {
+	return SecurityHeadersConfig{
+		XFrameOptions:                xFrameOptionsValue,
+		XContentTypeOptions:          xContentTypeOptionsValue,
+		XSSProtection:                xssProtectionValue,
+		StrictTransportSecurity:      strictTransportSecurityValue,
+		ReferrerPolicy:               referrerPolicyValue,
+		PermissionsPolicy:            permissionsPolicyValue,
+		ContentSecurityPolicy:        contentSecurityPolicyValue,
+		SwaggerContentSecurityPolicy: swaggerContentSecurityPolicyValue,
+		SwaggerPathPrefix:            swaggerPathPrefixValue,
+		BehindTLSProxy:               false,
+	}
+}
+
+// LoadSecurityHeadersConfig loads the security headers configuration from environment variables.
+// Unset variables keep their default value, and variables set to `DISABLED` disable the header.
+func LoadSecurityHeadersConfig() SecurityHeadersConfig {
+	cfg := DefaultSecurityHeadersConfig()
+
+	cfg.XFrameOptions = getHeaderEnv("SECURITY_HEADER_X_FRAME_OPTIONS", cfg.XFrameOptions)
+	cfg.XContentTypeOptions = getHeaderEnv("SECURITY_HEADER_X_CONTENT_TYPE_OPTIONS", cfg.XContentTypeOptions)
+	cfg.XSSProtection = getHeaderEnv("SECURITY_HEADER_X_XSS_PROTECTION", cfg.XSSProtection)
+	cfg.StrictTransportSecurity = getHeaderEnv("SECURITY_HEADER_HSTS", cfg.StrictTransportSecurity)
+	cfg.ReferrerPolicy = getHeaderEnv("SECURITY_HEADER_REFERRER_POLICY", cfg.ReferrerPolicy)
+	cfg.PermissionsPolicy = getHeaderEnv("SECURITY_HEADER_PERMISSIONS_POLICY", cfg.PermissionsPolicy)
+	cfg.ContentSecurityPolicy = getHeaderEnv("SECURITY_HEADER_CSP", cfg.ContentSecurityPolicy)
+	cfg.SwaggerContentSecurityPolicy = getHeaderEnv("SECURITY_HEADER_SWAGGER_CSP", cfg.SwaggerContentSecurityPolicy)
+	cfg.SwaggerPathPrefix = getHeaderEnv("SECURITY_HEADER_SWAGGER_PATH", cfg.SwaggerPathPrefix)
+	cfg.BehindTLSProxy = strings.ToUpper(os.Getenv("BEHIND_TLS_PROXY")) == "TRUE"
+
+	return cfg
+}
+
+// getHeaderEnv returns the value of the environment variable, or the default value if it is not set.
+// It returns an empty string if the variable is set to `DISABLED`.
+func getHeaderEnv(key string, defaultValue string) string {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	if strings.ToUpper(value) == disabledHeaderValue {
+		return ""
+	}
+	return value
+}
+
+// SecurityHeaders returns the security headers middleware configured from environment variables.
 func SecurityHeaders() gin.HandlerFunc {
+	return SecurityHeadersWithConfig(LoadSecurityHeadersConfig())
+}
+
+// SecurityHeadersWithConfig returns the security headers middleware using the given configuration.
+func SecurityHeadersWithConfig(cfg SecurityHeadersConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// to protect against clickjacking attacks
-		c.Writer.Header().Set(xFrameOptions, xFrameOptionsValue)
+		setHeader(c, xFrameOptions, cfg.XFrameOptions)
 
 		// to prevent MIME type sniffing
-		c.Writer.Header().Set(xContentTypeOptions, xContentTypeOptionsValue)
+		setHeader(c, xContentTypeOptions, cfg.XContentTypeOptions)
 
 		// to enable cross-site scripting (XSS) protection
-		c.Writer.Header().Set(xssProtection, xssProtectionValue)
+		setHeader(c, xssProtection, cfg.XSSProtection)
 
 		// to enforce secure connections and
 		// to ensure that browsers only connect to the server over HTTPS
-		// This header is particularly important for production environments.
-		c.Writer.Header().Set(strictTransportSecurity, strictTransportSecurityValue)
+		// Browsers ignore this header over plain HTTP, so it is only sent when the request
+		// arrived over TLS or when a TLS-terminating proxy sits in front of the application.
+		if c.Request.TLS != nil || cfg.BehindTLSProxy {
+			setHeader(c, strictTransportSecurity, cfg.StrictTransportSecurity)
+		}
 
 		// to control the referrer information sent with requests
-		c.Writer.Header().Set(referrerPolicy, referrerPolicyValue)
+		setHeader(c, referrerPolicy, cfg.ReferrerPolicy)
 
 		// to control which features can be used in the browser
-		c.Writer.Header().Set(permissionsPolicy, permissionsPolicyValue)
+		setHeader(c, permissionsPolicy, cfg.PermissionsPolicy)
+
+		// to restrict the sources of content, the Swagger UI needs inline scripts and styles
+		if cfg.SwaggerPathPrefix != "" && strings.HasPrefix(c.Request.URL.Path, cfg.SwaggerPathPrefix) {
+			setHeader(c, contentSecurityPolicy, cfg.SwaggerContentSecurityPolicy)
+		} else {
+			setHeader(c, contentSecurityPolicy, cfg.ContentSecurityPolicy)
+		}
 
 		c.Next()
 	}
 }
+
+// setHeader sets the response header if the value is not empty.
+func setHeader(c *gin.Context, key string, value string) {
+	if value != "" {
+		c.Writer.Header().Set(key, value)
+	}
+}
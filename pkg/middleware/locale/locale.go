@@ -0,0 +1,79 @@
+package locale
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+/**
+* Locale is a middleware function that selects the locale the validation messages of a response are written in.
+* The locale is the most preferred language of the Accept-Language header with validation messages
+* (e.g. "id-ID,id;q=0.9,en;q=0.8" selects "id"), the messages are written in English when the header is missing
+* or none of its languages is supported. An unsupported or malformed header is never refused.
+ */
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// The validation messages of the responses depend on the header, so do the cached responses
+		c.Writer.Header().Add("Vary", "Accept-Language")
+
+		if locale, ok := validation.MatchLocale(preferredLanguages(c.GetHeader("Accept-Language"))...); ok {
+			c.Request = c.Request.WithContext(metacontext.InjectLocale(c.Request.Context(), locale))
+		}
+
+		c.Next()
+	}
+}
+
+// preferredLanguages returns the languages of an Accept-Language header from the most to the least preferred,
+// without the wildcard and the languages of a zero quality value. The languages of equal quality keep their order.
+func preferredLanguages(header string) []string {
+	type weighted struct {
+		language string
+		quality  float64
+	}
+
+	var languages []weighted
+	for _, part := range strings.Split(header, ",") {
+		language, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		language = strings.TrimSpace(language)
+		if language == "" || language == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = q
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		languages = append(languages, weighted{language: language, quality: quality})
+	}
+
+	slices.SortStableFunc(languages, func(a, b weighted) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		}
+		return 0
+	})
+
+	preferred := make([]string, len(languages))
+	for i, l := range languages {
+		preferred[i] = l.language
+	}
+	return preferred
+}
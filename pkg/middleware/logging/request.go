@@ -52,8 +52,11 @@ func RequestLogger() gin.HandlerFunc {
 			"request_id":     c.Writer.Header().Get("X-Request-Id"),
 			"status":         c.Writer.Status(),
 			"user_agent":     c.Request.UserAgent(),
+			"user_id":        meta.UserID,
 			"username":       meta.Username,
 			"roles":          meta.Roles,
+			"tenant_id":      meta.TenantID,
+			"token_id":       meta.TokenID,
 		}).Info("Incoming request")
 	}
 }
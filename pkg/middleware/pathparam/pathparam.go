@@ -0,0 +1,59 @@
+package pathparam
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+/**
+* PathInt64 is a middleware function that validates a numeric path parameter, e.g. the :id of /users/:id, once for every handler.
+* A value that is not a positive integer, including a zero, a negative or an overflowing value,
+* is answered with a 400 Bad Request before the handler runs, so it never reaches the database.
+* The parsed value is stored in the context, the handlers retrieve it with Int64.
+ */
+
+// contextKey returns the key of the context under which the parsed value of the path parameter is stored.
+func contextKey(name string) string {
+	return "pathparam." + name
+}
+
+func PathInt64(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := parse(c, name)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		c.Set(contextKey(name), value)
+		c.Next()
+	}
+}
+
+// Int64 returns the value of the numeric path parameter validated by PathInt64.
+// If the middleware did not run, the parameter is validated now: an invalid value is answered with the same 400 Bad Request,
+// and false is returned for the handler to stop.
+func Int64(c *gin.Context, name string) (int64, bool) {
+	if value, ok := c.Get(contextKey(name)); ok {
+		return value.(int64), true
+	}
+	return parse(c, name)
+}
+
+// parse parses the path parameter as a positive int64, answering an invalid value with a 400 Bad Request.
+func parse(c *gin.Context, name string) (int64, bool) {
+	value, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || value < 1 {
+		label := name
+		if name == "id" {
+			label = "ID"
+		}
+		httputil.BadRequest(c, fmt.Sprintf("Invalid %s", label), fmt.Sprintf("%s must be a positive integer", label))
+		return 0, false
+	}
+	return value, true
+}
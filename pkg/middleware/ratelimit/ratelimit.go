@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+/**
+* RateLimit is a middleware function that bounds the number of requests a client address may send in a window of time,
+* e.g. to slow down the scripts creating accounts in bulk on an anonymous route.
+* The requests are counted in fixed windows starting at the first request of the address: once the limit is reached,
+* the next requests are rejected with a 429 Too Many Requests and a Retry-After header until the window ends.
+* The counts are held in memory, so each instance of the application limits the requests it receives.
+ */
+
+// RateLimitConfig holds the maximum number of requests of a client address in a window, a Limit of 0 disables the limit.
+// The windows are measured with the Clock, the real clock when it is nil.
+type RateLimitConfig struct {
+	Limit  int
+	Window time.Duration
+	Clock  clock.Clock
+}
+
+// window holds the number of requests of a client address since the start of its current window.
+type window struct {
+	start time.Time
+	count int
+}
+
+// RateLimit returns the rate limiting middleware using the given configuration.
+// The counts are shared by every request going through the returned middleware.
+func RateLimit(cfg RateLimitConfig) gin.HandlerFunc {
+	if cfg.Limit <= 0 || cfg.Window <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	clk := cfg.Clock
+	if clk == nil {
+		clk = clock.New()
+	}
+
+	var (
+		mu        sync.Mutex
+		windows   = make(map[string]*window)
+		nextSweep = clk.Now().Add(cfg.Window)
+	)
+
+	return func(c *gin.Context) {
+		now := clk.Now()
+		address := c.ClientIP()
+
+		mu.Lock()
+		// Drop the ended windows once per window, so the addresses seen once do not pile up
+		if now.After(nextSweep) {
+			for key, w := range windows {
+				if now.Sub(w.start) >= cfg.Window {
+					delete(windows, key)
+				}
+			}
+			nextSweep = now.Add(cfg.Window)
+		}
+
+		w, ok := windows[address]
+		if !ok || now.Sub(w.start) >= cfg.Window {
+			w = &window{start: now}
+			windows[address] = w
+		}
+		w.count++
+		allowed, retryAfter := w.count <= cfg.Limit, w.start.Add(cfg.Window).Sub(now)
+		mu.Unlock()
+
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(max(int(math.Ceil(retryAfter.Seconds())), 1)))
+			httputil.TooManyRequests(c, "Too Many Requests", "Too many requests from this address, retry later")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,74 @@
+package recovery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+/**
+* Recovery is a middleware function that recovers from the panics of the downstream middlewares and handlers.
+* The panic and its stack are logged along with the request ID, and the client gets the standard 500 response
+* without any detail of the panic, so the stack, the file paths or the values never leak in the response.
+* The request ID is returned in the X-Request-Id header, to find the stack in the logs from a client report.
+ */
+const (
+	// requestIDHeader is the header carrying the ID correlating a request with its logs
+	requestIDHeader = "X-Request-Id"
+)
+
+// Recovery returns the recovery middleware.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			// The server aborts the response on purpose with http.ErrAbortHandler, it is not a failure
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+
+			requestID := requestIDOf(c)
+			logger.Error(fmt.Sprintf("Recovered from panic: %v", recovered), logrus.Fields{
+				"request_id": requestID,
+				"method":     c.Request.Method,
+				"path":       c.Request.URL.Path,
+				"stack":      string(debug.Stack()),
+			})
+
+			// The handler may have started the response before panicking, it cannot be replaced anymore
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+
+			c.Header(requestIDHeader, requestID)
+			httputil.InternalServerError(c, "Internal Server Error", "An unexpected error occurred while processing the request")
+			c.Abort()
+		}()
+
+		c.Next()
+	}
+}
+
+// requestIDOf returns the ID of the request, the one sent by the client or a proxy if any, or a new one.
+func requestIDOf(c *gin.Context) string {
+	if id := c.Writer.Header().Get(requestIDHeader); id != "" {
+		return id
+	}
+	if id := c.GetHeader(requestIDHeader); id != "" {
+		return id
+	}
+	return uuid.NewString()
+}
@@ -0,0 +1,90 @@
+package tenant
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+/**
+* TenantResolution is a middleware function that selects the tenant the request operates on.
+* It must run after the JWT validation, which restricts the request to the tenant of the user.
+* A member of another tenant may operate on it by sending its ID in the X-Tenant-ID header,
+* and a super admin may operate on any tenant by passing its ID in the tenantId query parameter.
+* An unknown tenant or a tenant the user is not a member of is answered with a 404 Not Found,
+* so the response does not reveal whether the tenant exists.
+ */
+const (
+	TenantHeader    = "X-Tenant-ID"
+	TenantQuery     = "tenantId"
+	SuperAdminRole  = "ROLE_SUPER_ADMIN"
+	notFoundMessage = "Tenant not found"
+)
+
+// MembershipFunc reports whether the user belongs to the tenant.
+type MembershipFunc func(ctx context.Context, userID int64, tenantID int64) (bool, error)
+
+func TenantResolution(isMember MembershipFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		meta, ok := metacontext.ExtractUserInformationMeta(c.Request.Context())
+		if !ok {
+			httputil.Unauthorized(c, "Unauthorized", "Missing user context")
+			c.Abort()
+			return
+		}
+		homeTenantID, ok := metacontext.ExtractTenantID(c.Request.Context())
+		if !ok {
+			homeTenantID = metacontext.DefaultTenantID
+		}
+
+		// A super admin may select any tenant explicitly
+		if value := c.Query(TenantQuery); value != "" && slices.Contains(meta.Roles, SuperAdminRole) {
+			tenantID, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || tenantID < 1 {
+				httputil.BadRequest(c, "Invalid tenant ID", "Tenant ID must be a positive integer")
+				c.Abort()
+				return
+			}
+			c.Request = c.Request.WithContext(metacontext.InjectTenantID(c.Request.Context(), tenantID))
+			c.Next()
+			return
+		}
+
+		// Without the header, the request operates on the tenant of the user
+		value := c.GetHeader(TenantHeader)
+		if value == "" {
+			c.Next()
+			return
+		}
+
+		tenantID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || tenantID < 1 {
+			httputil.BadRequest(c, "Invalid tenant ID", "Tenant ID must be a positive integer")
+			c.Abort()
+			return
+		}
+
+		// Any other tenant requires a membership
+		if tenantID != homeTenantID {
+			member, err := isMember(c.Request.Context(), meta.UserID, tenantID)
+			if err != nil {
+				httputil.ServerError(c, "Failed to resolve tenant", err)
+				c.Abort()
+				return
+			}
+			if !member {
+				httputil.NotFound(c, notFoundMessage, "No tenant found with the given ID")
+				c.Abort()
+				return
+			}
+		}
+
+		c.Request = c.Request.WithContext(metacontext.InjectTenantID(c.Request.Context(), tenantID))
+		c.Next()
+	}
+}
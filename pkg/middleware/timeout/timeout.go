@@ -0,0 +1,281 @@
+package timeout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+/**
+* Timeout is a middleware function that bounds the processing time of every request.
+* It wraps the request context with a deadline, so the downstream database queries are aborted
+* when the deadline fires, and responds with a 504 Gateway Timeout if the handler has not written its response yet.
+* The response is written only once: whatever the handler writes after the deadline is discarded.
+ */
+const (
+	defaultTimeout = 30 * time.Second
+)
+
+// TimeoutConfig holds the default request timeout and the per-route overrides.
+// The overrides are keyed by route prefix (e.g. `/api/v1/consumers/export`), the longest matching prefix wins.
+type TimeoutConfig struct {
+	Default   time.Duration
+	Overrides map[string]time.Duration
+}
+
+// LoadTimeoutConfig loads the timeout configuration from environment variables.
+// REQUEST_TIMEOUT is a duration (e.g. `30s`), and REQUEST_TIMEOUT_OVERRIDES is a comma-separated
+// list of `prefix=duration` pairs (e.g. `/api/v1/consumers/export=2m`).
+func LoadTimeoutConfig() TimeoutConfig {
+	cfg := TimeoutConfig{
+		Default:   defaultTimeout,
+		Overrides: make(map[string]time.Duration),
+	}
+
+	if d, err := parseDuration(os.Getenv("REQUEST_TIMEOUT")); err == nil && d > 0 {
+		cfg.Default = d
+	}
+
+	for _, pair := range strings.Split(os.Getenv("REQUEST_TIMEOUT_OVERRIDES"), ",") {
+		prefix, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		d, err := parseDuration(value)
+		if err != nil || d <= 0 {
+			logger.Warn(fmt.Sprintf("Ignoring invalid request timeout override: %s", pair), nil)
+			continue
+		}
+		cfg.Overrides[strings.TrimSpace(prefix)] = d
+	}
+
+	return cfg
+}
+
+// parseDuration parses a duration, plain numbers are interpreted as seconds.
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// For returns the timeout that applies to the given route path.
+func (cfg TimeoutConfig) For(path string) time.Duration {
+	d := cfg.Default
+	longest := -1
+	for prefix, override := range cfg.Overrides {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			d = override
+			longest = len(prefix)
+		}
+	}
+	return d
+}
+
+// Timeout returns the timeout middleware configured from environment variables.
+func Timeout() gin.HandlerFunc {
+	return TimeoutWithConfig(LoadTimeoutConfig())
+}
+
+// TimeoutWithConfig returns the timeout middleware using the given configuration.
+func TimeoutWithConfig(cfg TimeoutConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Use the registered route to find the timeout, or the raw path for unmatched routes
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		d := cfg.For(path)
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		// Wrap the request context with the deadline, so the downstream queries are cancelled
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		// Replace the writer with a write-once wrapper
+		tw := newTimeoutWriter(c.Writer)
+		c.Writer = tw
+
+		// Respond with 504 as soon as the deadline fires, unless the handler already started writing
+		requestPath := c.Request.URL.Path
+		fired := make(chan struct{})
+		timer := time.AfterFunc(d, func() {
+			defer close(fired)
+			tw.timeout(requestPath, d)
+		})
+
+		// Make sure the timeout response is complete before handing the writer back to gin,
+		// also when the handler panics, so the recovery middleware writes to the original writer
+		defer func() {
+			if !timer.Stop() {
+				<-fired
+			}
+			c.Writer = tw.ResponseWriter
+		}()
+
+		c.Next()
+	}
+}
+
+// timeoutWriter is a gin.ResponseWriter which discards every write after the timeout response has been sent.
+// The headers set by the handler are buffered and only copied to the response when the handler writes,
+// so the timeout response and the handler never write to the same header map concurrently.
+type timeoutWriter struct {
+	gin.ResponseWriter
+	mu       sync.Mutex
+	header   http.Header
+	timedOut bool
+}
+
+// newTimeoutWriter creates a new timeoutWriter wrapping the given writer.
+func newTimeoutWriter(w gin.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{
+		ResponseWriter: w,
+		header:         w.Header().Clone(),
+	}
+}
+
+// Header returns the buffered header map of the handler.
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+// copyHeader copies the buffered headers to the underlying response, the caller must hold the lock.
+func (w *timeoutWriter) copyHeader() {
+	if w.ResponseWriter.Written() {
+		return
+	}
+	dst := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+}
+
+// WriteHeader writes the status code unless the request has timed out.
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return
+	}
+	w.copyHeader()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// WriteHeaderNow forces the status code to be written unless the request has timed out.
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return
+	}
+	w.copyHeader()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Write writes the data unless the request has timed out.
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.copyHeader()
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString writes the string unless the request has timed out.
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.copyHeader()
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Flush sends the buffered data to the client unless the request has timed out.
+// Nothing is flushed before the handler writes, so a flush alone does not commit a 200 in place of the timeout response.
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut || !w.ResponseWriter.Written() {
+		return
+	}
+	w.copyHeader()
+	w.ResponseWriter.Flush()
+}
+
+// Status returns the status code of the response.
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.Status()
+}
+
+// Size returns the number of bytes written in the response body.
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.Size()
+}
+
+// Written reports whether the response has been written.
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.timedOut || w.ResponseWriter.Written()
+}
+
+// timeout sends the 504 response if the handler has not started writing its own response.
+func (w *timeoutWriter) timeout(path string, d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.ResponseWriter.Written() {
+		return
+	}
+	w.timedOut = true
+
+	errMsg := fmt.Sprintf("Request exceeded the timeout of %s", d)
+	logger.Error(errMsg, nil)
+
+	body, _ := json.Marshal(httputil.ResponseBody(httputil.HttpResponse{
+		Message:   "Request timeout",
+		Error:     errMsg,
+		Path:      path,
+		Status:    http.StatusGatewayTimeout,
+		Data:      nil,
+		Timestamp: time.Now().UTC(),
+	}))
+
+	header := w.ResponseWriter.Header()
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	w.ResponseWriter.Write(body)
+	w.ResponseWriter.Flush()
+}
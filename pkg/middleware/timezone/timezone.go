@@ -0,0 +1,41 @@
+package timezone
+
+import (
+	"net/http"
+	"time"
+	_ "time/tzdata" // Embed the time zone database, the container images may not have one
+
+	"github.com/gin-gonic/gin"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+/**
+* TimeZone is a middleware function that selects the time zone the times of a read response are displayed in.
+* The times are stored and returned in UTC, a GET request may pass an IANA time zone name in the tz query parameter
+* (e.g. ?tz=Asia/Jakarta) to receive them with the offset of that zone instead.
+* An unknown time zone is answered with a 400 Bad Request. The parameter is ignored by the other methods.
+ */
+const TimeZoneQuery = "tz"
+
+func TimeZone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Query(TimeZoneQuery)
+		if name == "" || (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) {
+			c.Next()
+			return
+		}
+
+		// Local is the zone of the server, which is exactly what the parameter must not depend on
+		loc, err := time.LoadLocation(name)
+		if err != nil || name == "Local" {
+			httputil.BadRequest(c, "Invalid time zone", "Time zone must be an IANA time zone name (e.g. Asia/Jakarta)")
+			c.Abort()
+			return
+		}
+
+		c.Request = c.Request.WithContext(metacontext.InjectTimeZone(c.Request.Context(), loc))
+		c.Next()
+	}
+}
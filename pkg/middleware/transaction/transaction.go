@@ -0,0 +1,59 @@
+package transaction
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+/**
+* Transaction is an optional middleware function that runs the whole request in a single database transaction.
+* It opens the transaction and stores it in the request context, where the services pick it up through database.GetDB,
+* so the writes of a handler calling several services are atomic. The transactions opened by the services are
+* nested in the request transaction as savepoints.
+* The transaction is committed when the handler responds with a 2xx status, and rolled back on any other status,
+* on a handler error, or on a panic, which is then propagated to the recovery middleware.
+* The response is already written when the transaction commits, so a failed commit is only logged.
+ */
+func Transaction() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db, err := database.RequirePostgres()
+		if err != nil {
+			httputil.ServerError(c, "Failed to start transaction", err)
+			c.Abort()
+			return
+		}
+
+		tx := db.WithContext(c.Request.Context()).Begin()
+		if tx.Error != nil {
+			httputil.ServerError(c, "Failed to start transaction", tx.Error)
+			c.Abort()
+			return
+		}
+
+		// Roll back if the handler panics, and let the recovery middleware respond
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+				panic(r)
+			}
+		}()
+
+		c.Request = c.Request.WithContext(database.InjectTx(c.Request.Context(), tx))
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < 200 || status >= 300 || len(c.Errors) > 0 {
+			tx.Rollback()
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			logger.Error(fmt.Sprintf("Failed to commit the transaction of %s %s: %v", c.Request.Method, c.Request.URL.Path, err), nil)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package warning
+
+import (
+	"github.com/gin-gonic/gin"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+/**
+* Warnings is a middleware function that collects the non-fatal warnings raised while processing a request.
+* It injects a warnings collector into the request context, the services add their warnings to it
+* with metacontext.AddWarning, and the successful responses carry them in their `warnings` field.
+ */
+func Warnings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(metacontext.InjectWarningCollector(c.Request.Context()))
+		c.Next()
+	}
+}
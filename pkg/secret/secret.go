@@ -0,0 +1,252 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+)
+
+/**
+* The secrets of the application, e.g. JWT_SECRET and DB_PASS, are sourced from a SecretProvider selected with SECRET_PROVIDER.
+* The default provider reads them from the environment, the deployments keeping them in an external store
+* (HashiCorp Vault, AWS Secrets Manager...) register a provider of their own with Register before the startup.
+* At startup, the secrets found by the provider are set in the environment, so the settings are loaded as usual.
+* With SECRET_REFRESH_INTERVAL, the secrets are fetched again at every interval and the rotated ones are applied
+* by the handlers watching them, see Refresher.
+ */
+const (
+	// EnvProviderName is the name of the provider reading the secrets from the environment, the default one
+	EnvProviderName = "env"
+)
+
+var (
+	// ErrSecretNotFound is returned by a provider that has no value for the requested secret.
+	ErrSecretNotFound = errors.New("secret not found")
+
+	// ErrUnknownProvider is returned when SECRET_PROVIDER names a provider that was not registered.
+	ErrUnknownProvider = errors.New("unknown secret provider")
+)
+
+// SecretProvider supplies the secrets of the application by name, e.g. from the environment or from a vault.
+// It returns an error wrapping ErrSecretNotFound if it has no value for the secret.
+type SecretProvider interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// Factory creates a provider, e.g. a client of a vault configured from its own environment variables.
+type Factory func() (SecretProvider, error)
+
+// EnvProvider is the provider reading the secrets from the environment variables of the same name.
+type EnvProvider struct{}
+
+// GetSecret returns the value of the environment variable of the secret, an unset or empty variable is not found.
+func (EnvProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	return value, nil
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		EnvProviderName: func() (SecretProvider, error) { return EnvProvider{}, nil },
+	}
+)
+
+// Register makes a provider available under a name, to be selected with SECRET_PROVIDER.
+// A provider registered under the name of another replaces it.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[strings.ToLower(name)] = factory
+}
+
+// New creates the provider registered under the given name.
+// It returns ErrUnknownProvider if no provider was registered under that name.
+func New(name string) (SecretProvider, error) {
+	registryMu.RLock()
+	factory, ok := registry[strings.ToLower(name)]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+	}
+	return factory()
+}
+
+// GetProviderName returns the name of the configured provider.
+// It retrieves the name from an environment variable, the environment is read if it is not set.
+func GetProviderName() string {
+	name := strings.TrimSpace(os.Getenv("SECRET_PROVIDER"))
+	if name == "" {
+		return EnvProviderName // Default to the environment if the environment variable is not set
+	}
+	return name
+}
+
+// GetRefreshInterval returns the interval at which the secrets are fetched again from the provider.
+// It retrieves the interval from an environment variable, e.g. 5m, 0 disables the refresh.
+func GetRefreshInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("SECRET_REFRESH_INTERVAL"))
+	if err != nil || interval < 0 {
+		return 0 // Default to no refresh if the environment variable is not set or invalid
+	}
+	return interval
+}
+
+// watch holds a secret watched by a refresher, its last known value and the handler of its changes.
+type watch struct {
+	name     string
+	value    string
+	onChange func(value string)
+}
+
+// Refresher sources the watched secrets from a provider at startup and, once started,
+// fetches them again at every interval to apply the rotated ones.
+type Refresher struct {
+	provider SecretProvider
+	interval time.Duration
+
+	mu      sync.Mutex
+	watches []*watch
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewRefresher creates a refresher of the secrets of the given provider, fetched again at the given interval.
+func NewRefresher(provider SecretProvider, interval time.Duration) *Refresher {
+	return &Refresher{provider: provider, interval: interval}
+}
+
+// Watch adds a secret to the refresher. The handler is called with the new value when a refresh finds it rotated,
+// it must apply the value to what was built from the previous one, e.g. the signer of the tokens.
+func (r *Refresher) Watch(name string, onChange func(value string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.watches = append(r.watches, &watch{name: name, onChange: onChange})
+}
+
+// Load fetches the watched secrets and sets the found ones in the environment, over the variables of the same name,
+// so the settings read at startup use them. A secret the provider does not have keeps the value of its variable.
+func (r *Refresher) Load(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, w := range r.watches {
+		value, err := r.provider.GetSecret(ctx, w.name)
+		if errors.Is(err, ErrSecretNotFound) {
+			w.value = os.Getenv(w.name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load the secret %s: %w", w.name, err)
+		}
+
+		if err := os.Setenv(w.name, value); err != nil {
+			return fmt.Errorf("failed to set the secret %s: %w", w.name, err)
+		}
+		w.value = value
+	}
+
+	return nil
+}
+
+// Refresh fetches the watched secrets again and applies the changed ones, in the environment and with their handler.
+// It returns the names of the changed secrets. A secret that cannot be fetched keeps its value, the others are still refreshed.
+func (r *Refresher) Refresh(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		changed []string
+		errs    []error
+	)
+	for _, w := range r.watches {
+		value, err := r.provider.GetSecret(ctx, w.name)
+		if errors.Is(err, ErrSecretNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to refresh the secret %s: %w", w.name, err))
+			continue
+		}
+		if value == w.value {
+			continue
+		}
+
+		if err := os.Setenv(w.name, value); err != nil {
+			errs = append(errs, fmt.Errorf("failed to set the secret %s: %w", w.name, err))
+			continue
+		}
+		w.value = value
+		if w.onChange != nil {
+			w.onChange(value)
+		}
+		changed = append(changed, w.name)
+	}
+
+	return changed, errors.Join(errs...)
+}
+
+// Start refreshes the secrets in the background at every interval, until Stop is called.
+// It does nothing if the interval is 0 or the refresher is already started.
+func (r *Refresher) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.interval <= 0 || r.stop != nil {
+		return
+	}
+
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run(r.stop, r.done)
+}
+
+// Stop stops the background refresh, after the refresh in progress if any.
+func (r *Refresher) Stop() {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// run refreshes the secrets at every interval until the stop channel is closed.
+// The values of the secrets are never logged, only their names.
+func (r *Refresher) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			changed, err := r.Refresh(context.Background())
+			if err != nil {
+				logger.Error(fmt.Sprintf("Failed to refresh the secrets: %v", err), nil)
+			}
+			if len(changed) > 0 {
+				logger.Info(fmt.Sprintf("Secrets rotated: %s", strings.Join(changed, ", ")), nil)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package field_util
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+/**
+* The field naming is the convention of the JSON field names of the responses, set from JSON_FIELD_NAMING:
+* - camelCase (default): the names of the json tags, e.g. "createdAt".
+* - snake_case: the same names in snake case, e.g. "created_at".
+* Only the fields of the structs are renamed, the keys of the maps (e.g. the metadata of a user) are data and kept as they are.
+* The request bodies bound with http_util.BindJSON accept both conventions, whatever the field naming of the responses.
+ */
+
+// FieldNaming is the convention of the JSON field names of the responses.
+type FieldNaming string
+
+const (
+	CamelCase FieldNaming = "camelCase"
+	SnakeCase FieldNaming = "snake_case"
+
+	defaultFieldNaming = CamelCase
+)
+
+// fieldNaming holds the field naming of the responses, loaded from JSON_FIELD_NAMING at the first response
+var fieldNaming atomic.Pointer[FieldNaming]
+
+// ParseFieldNaming parses camelCase or snake_case, case-insensitively, an empty value is the default camelCase.
+func ParseFieldNaming(value string) (FieldNaming, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "camelcase":
+		return CamelCase, nil
+	case "snake_case":
+		return SnakeCase, nil
+	default:
+		return "", fmt.Errorf("invalid field naming %q, expected camelCase or snake_case", value)
+	}
+}
+
+// GetFieldNaming returns the field naming of the responses, an invalid JSON_FIELD_NAMING is the default camelCase.
+func GetFieldNaming() FieldNaming {
+	if naming := fieldNaming.Load(); naming != nil {
+		return *naming
+	}
+
+	naming, err := ParseFieldNaming(os.Getenv("JSON_FIELD_NAMING"))
+	if err != nil {
+		naming = defaultFieldNaming
+	}
+	fieldNaming.Store(&naming)
+	return naming
+}
+
+// SetFieldNaming sets the field naming of the responses, the next responses use it.
+func SetFieldNaming(naming FieldNaming) {
+	fieldNaming.Store(&naming)
+}
+
+// FieldName returns the name of a JSON field, given by its json tag, in the field naming of the responses.
+func FieldName(name string) string {
+	if GetFieldNaming() == SnakeCase {
+		return ToSnakeCase(name)
+	}
+	return name
+}
+
+// ToSnakeCase converts a camelCase or PascalCase name to snake case, e.g. "totalPages" to "total_pages"
+// and "userID" to "user_id". The acronyms are kept together.
+func ToSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	b.Grow(len(name) + 4)
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// A word starts at an upper case letter following a lower case letter or a digit,
+			// or at the last upper case letter of an acronym followed by a lower case letter, e.g. the "S" of "HTTPStatus"
+			if i > 0 && runes[i-1] != '_' &&
+				(!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// FieldKey returns the name without its underscores and in lower case, the same for every naming of a field,
+// e.g. "createdAt" and "created_at" both give "createdat".
+func FieldKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
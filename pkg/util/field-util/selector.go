@@ -0,0 +1,95 @@
+package field_util
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Selection is a JSON object holding the selected fields of an object.
+// Its keys are field names, renamed in the field naming of the responses like the fields of the structs.
+type Selection map[string]any
+
+// ParseFields parses a comma-separated list of field names (e.g. "id,username,email").
+// Each field is validated against the allow-list, unknown fields are returned as an error.
+// A field can be named in camel case or in snake case (e.g. "birthDate" or "birth_date"), it is returned as named in the allow-list.
+// An empty input returns a nil slice, meaning that all fields should be returned.
+func ParseFields(raw string, allowed []string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	allowedSet := make(map[string]string, len(allowed))
+	for _, f := range allowed {
+		allowedSet[FieldKey(f)] = f
+	}
+
+	var fields []string
+	var unknown []string
+	seen := make(map[string]struct{})
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		name, ok := allowedSet[FieldKey(f)]
+		if !ok {
+			unknown = append(unknown, f)
+			continue
+		}
+
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		fields = append(fields, name)
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown fields: %s (allowed: %s)", strings.Join(unknown, ", "), strings.Join(allowed, ", "))
+	}
+
+	return fields, nil
+}
+
+// SelectFields filters the JSON representation of data to the requested fields.
+// It supports a single object or a slice of objects. If no fields are requested, data is returned unchanged.
+func SelectFields(data any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return data, nil
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	// Try to decode the data as a list of objects first
+	var list []map[string]any
+	if err := json.Unmarshal(raw, &list); err == nil {
+		selected := make([]Selection, len(list))
+		for i, item := range list {
+			selected[i] = pick(item, fields)
+		}
+		return selected, nil
+	}
+
+	// Otherwise decode the data as a single object
+	var object map[string]any
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, fmt.Errorf("field selection is only supported on objects: %w", err)
+	}
+
+	return pick(object, fields), nil
+}
+
+// pick returns a new map containing only the requested keys of the given object.
+func pick(object map[string]any, fields []string) Selection {
+	selected := make(Selection, len(fields))
+	for _, f := range fields {
+		if v, ok := object[f]; ok {
+			selected[f] = v
+		}
+	}
+	return selected
+}
@@ -0,0 +1,361 @@
+package http_util
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	fieldutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/field-util"
+)
+
+/**
+* The responses are written with the field names of the field naming, see field_util.GetFieldNaming,
+* and the request bodies are bound whatever the naming of their fields.
+ */
+
+// ResponseBody returns the body of a response in the field naming of the responses.
+// The camelCase body is the data itself, the field names of the json tags are already in camel case.
+func ResponseBody(data any) any {
+	if data == nil || fieldutil.GetFieldNaming() == fieldutil.CamelCase {
+		return data
+	}
+	return renamed(reflect.ValueOf(data), fieldutil.ToSnakeCase)
+}
+
+// member is a field of an object, kept in the order of the struct.
+type member struct {
+	name  string
+	value any
+}
+
+// object is a struct encoded with renamed fields, in the order of the struct.
+type object []member
+
+// MarshalJSON encodes the members in their order.
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(m.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// renamed returns a value encoded like v, with the field names of its structs renamed by rename.
+// The values with their own JSON encoding, e.g. the times, are returned as they are.
+func renamed(v reflect.Value, rename func(string) string) any {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+	}
+
+	t := v.Type()
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) ||
+		(v.Kind() != reflect.Pointer && (reflect.PointerTo(t).Implements(marshalerType) || reflect.PointerTo(t).Implements(textMarshalerType))) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return renamed(v.Elem(), rename)
+	case reflect.Struct:
+		var o object
+		appendFields(&o, v, rename, make(map[string]bool))
+		if o == nil {
+			o = object{}
+		}
+		return o
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		// The keys of a selection, and those of the objects it holds, are the names of the fields of a struct
+		if selection, ok := v.Interface().(fieldutil.Selection); ok {
+			return renamedSelection(selection, rename)
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			// The keys are encoded like encoding/json does, only the values are renamed
+			key, err := json.Marshal(iter.Key().Interface())
+			if err != nil {
+				return v.Interface()
+			}
+			var name string
+			if err := json.Unmarshal(key, &name); err != nil {
+				name = string(key)
+			}
+			m[name] = renamed(iter.Value(), rename)
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		fallthrough
+	case reflect.Array:
+		s := make([]any, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s[i] = renamed(v.Index(i), rename)
+		}
+		return s
+	default:
+		return v.Interface()
+	}
+}
+
+// appendFields appends the encoded fields of the struct to the object, the fields of the embedded structs are promoted
+// like encoding/json does, a name already taken by an outer field is left out.
+func appendFields(o *object, v reflect.Value, rename func(string) string, taken map[string]bool) {
+	t := v.Type()
+	var embedded []reflect.Value
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		fv := v.Field(i)
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if fv.Kind() == reflect.Pointer {
+					if fv.IsNil() || !field.IsExported() {
+						continue
+					}
+					fv = fv.Elem()
+				}
+				embedded = append(embedded, fv)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && isEmptyValue(fv) {
+			continue
+		}
+
+		name = rename(name)
+		if taken[name] {
+			continue
+		}
+		taken[name] = true
+
+		var value any = renamed(fv, rename)
+		if strings.Contains(","+opts+",", ",string,") {
+			value = fv.Interface()
+			if b, err := json.Marshal(value); err == nil {
+				value = string(b)
+			}
+		}
+		*o = append(*o, member{name: name, value: value})
+	}
+
+	for _, ev := range embedded {
+		appendFields(o, ev, rename, taken)
+	}
+}
+
+// renamedSelection renames the keys of the selection and of the objects it holds.
+func renamedSelection(selection map[string]any, rename func(string) string) map[string]any {
+	m := make(map[string]any, len(selection))
+	for key, value := range selection {
+		m[rename(key)] = renamedJSON(value, rename)
+	}
+	return m
+}
+
+// renamedJSON renames the keys of the objects of a decoded JSON value.
+func renamedJSON(value any, rename func(string) string) any {
+	switch value := value.(type) {
+	case map[string]any:
+		return renamedSelection(value, rename)
+	case []any:
+		s := make([]any, len(value))
+		for i, item := range value {
+			s[i] = renamedJSON(item, rename)
+		}
+		return s
+	default:
+		return value
+	}
+}
+
+// isEmptyValue reports whether the value is omitted by the omitempty option of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	default:
+		return false
+	}
+}
+
+// BindJSON binds the JSON request body to obj and validates it, like gin.Context.ShouldBindJSON.
+// The field names of the body can be in camel case or in snake case, whatever the field naming of the responses,
+// e.g. "isEnabled" and "is_enabled" both bind the field tagged `json:"isEnabled"`.
+func BindJSON(c *gin.Context, obj any) error {
+	if c.Request == nil || c.Request.Body == nil {
+		return c.ShouldBindJSON(obj)
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	// A body that is not valid JSON is bound as it is, for the usual error
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err == nil {
+		if normalized, err := json.Marshal(normalizedJSON(value, reflect.TypeOf(obj))); err == nil {
+			body = normalized
+		}
+	}
+
+	return binding.JSON.BindBody(body, obj)
+}
+
+// normalizedJSON renames the keys of the decoded JSON value to the field names of the type it is bound to,
+// the keys matching a field once the underscores and the case are ignored are renamed to the name of its json tag.
+func normalizedJSON(value any, t reflect.Type) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return value
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := make(map[string]reflect.StructField)
+			indexFields(fields, t)
+
+			m := make(map[string]any, len(value))
+			for key, item := range value {
+				field, ok := fields[fieldutil.FieldKey(key)]
+				if !ok {
+					m[key] = item
+					continue
+				}
+				name := jsonName(field)
+				if _, exact := value[name]; exact && key != name {
+					// The field is also given under its own name, which takes precedence
+					continue
+				}
+				m[name] = normalizedJSON(item, field.Type)
+			}
+			return m
+		case reflect.Map:
+			m := make(map[string]any, len(value))
+			for key, item := range value {
+				m[key] = normalizedJSON(item, t.Elem())
+			}
+			return m
+		}
+	case []any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			s := make([]any, len(value))
+			for i, item := range value {
+				s[i] = normalizedJSON(item, t.Elem())
+			}
+			return s
+		}
+	}
+	return value
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// indexFields indexes the exported fields of the struct, and those promoted from its embedded structs, by fieldKey.
+func indexFields(fields map[string]reflect.StructField, t reflect.Type) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && strings.Split(tag, ",")[0] == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, ft)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if key := fieldutil.FieldKey(jsonName(field)); fields[key].Name == "" {
+			fields[key] = field
+		}
+	}
+
+	for _, et := range embedded {
+		indexFields(fields, et)
+	}
+}
+
+// jsonName returns the name of the field in JSON, the name of its json tag or its own name.
+func jsonName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
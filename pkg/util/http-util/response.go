@@ -1,277 +1,470 @@
 package http_util
 
 import (
+	"context"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
 	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
 )
 
 // ErrorResponse represents the structure of an error response.
 type HttpResponse struct {
-	Message   string    `json:"message"`   // A user-friendly error message
-	Error     any       `json:"error"`     // The actual error message (optional)
-	Path      string    `json:"path"`      // The request path that caused the error (optional)
-	Status    int       `json:"status"`    // HTTP status code (optional)
-	Data      any       `json:"data"`      // Additional data related to the error (optional)
-	Timestamp time.Time `json:"timestamp"` // The timestamp when the error occurred (optional)
+	Message    string      `json:"message"`              // A user-friendly error message
+	Error      any         `json:"error"`                // The actual error message (optional)
+	Path       string      `json:"path"`                 // The request path that caused the error (optional)
+	Status     int         `json:"status"`               // HTTP status code (optional)
+	Data       any         `json:"data"`                 // Additional data related to the error (optional)
+	Pagination *Pagination `json:"pagination,omitempty"` // Pagination metadata for list responses (optional)
+	Cursor     *Cursor     `json:"cursor,omitempty"`     // Continuation cursor of the streamed list responses (optional)
+	Warnings   []string    `json:"warnings,omitempty"`   // Non-fatal warnings raised by a successful operation (optional)
+	Timestamp  time.Time   `json:"timestamp"`            // The timestamp when the error occurred (optional)
+}
+
+// Pagination represents the pagination metadata returned by list endpoints.
+type Pagination struct {
+	Page       int   `json:"page"`       // The current page number
+	Limit      int   `json:"limit"`      // The number of items per page
+	Total      int64 `json:"total"`      // The total number of items
+	TotalPages int64 `json:"totalPages"` // The total number of pages
+}
+
+// NewPagination creates the pagination metadata for the given page, limit and total number of items.
+func NewPagination(page int, limit int, total int64) *Pagination {
+	totalPages := int64(0)
+	if limit > 0 {
+		totalPages = (total + int64(limit) - 1) / int64(limit)
+	}
+
+	return &Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
 }
 
 /***** Basic Responses *****/
-func Created(c *gin.Context, message string, data interface{}) {
-	c.JSON(http.StatusCreated, HttpResponse{
+// The successful responses carry the warnings collected in the request context, see metacontext.AddWarning.
+// Their times are displayed in UTC, or in the time zone selected by the request, see metacontext.InjectTimeZone.
+// The field names of every response follow the field naming of the responses, see GetFieldNaming.
+
+// Created returns a 201 response, the Location header points at the created resource when its path is given.
+func Created(c *gin.Context, message string, location string, data interface{}) {
+	if location != "" {
+		c.Header("Location", location)
+	}
+
+	c.JSON(http.StatusCreated, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     nil,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusCreated,
-		Data:      data,
-		Timestamp: time.Now(),
-	})
+		Data:      displayData(c, data),
+		Warnings:  metacontext.ExtractWarnings(c.Request.Context()),
+		Timestamp: displayTime(c),
+	}))
+}
+
+// Accepted returns a 202 response for a request processed asynchronously,
+// the Location header points at the resource reporting the progress of the job when its path is given.
+func Accepted(c *gin.Context, message string, location string, data interface{}) {
+	if location != "" {
+		c.Header("Location", location)
+	}
+
+	c.JSON(http.StatusAccepted, ResponseBody(HttpResponse{
+		Message:   message,
+		Error:     nil,
+		Path:      c.Request.URL.Path,
+		Status:    http.StatusAccepted,
+		Data:      displayData(c, data),
+		Warnings:  metacontext.ExtractWarnings(c.Request.Context()),
+		Timestamp: displayTime(c),
+	}))
 }
 
 func Success(c *gin.Context, message string, data interface{}) {
-	c.JSON(http.StatusOK, HttpResponse{
+	c.JSON(http.StatusOK, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     nil,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusOK,
-		Data:      data,
-		Timestamp: time.Now(),
-	})
+		Data:      displayData(c, data),
+		Warnings:  metacontext.ExtractWarnings(c.Request.Context()),
+		Timestamp: displayTime(c),
+	}))
+}
+
+// SuccessWithPagination returns a 200 list response along with its pagination metadata.
+// An empty list is a valid result and is returned as an empty array.
+func SuccessWithPagination(c *gin.Context, message string, data interface{}, pagination *Pagination) {
+	c.JSON(http.StatusOK, ResponseBody(HttpResponse{
+		Message:    message,
+		Error:      nil,
+		Path:       c.Request.URL.Path,
+		Status:     http.StatusOK,
+		Data:       displayData(c, data),
+		Pagination: pagination,
+		Warnings:   metacontext.ExtractWarnings(c.Request.Context()),
+		Timestamp:  displayTime(c),
+	}))
 }
 
 func BadRequest(c *gin.Context, message string, err string) {
 	logger.Error(err, nil)
 
-	c.JSON(http.StatusBadRequest, HttpResponse{
+	c.JSON(http.StatusBadRequest, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusBadRequest,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func NotFound(c *gin.Context, message string, err string) {
 	logger.Error(err, nil)
 
-	c.JSON(http.StatusNotFound, HttpResponse{
+	c.JSON(http.StatusNotFound, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusNotFound,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func InternalServerError(c *gin.Context, message string, err string) {
 	logger.Error(err, nil)
 
-	c.JSON(http.StatusInternalServerError, HttpResponse{
+	c.JSON(http.StatusInternalServerError, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusInternalServerError,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func Unauthorized(c *gin.Context, message string, err string) {
 	logger.Error(err, nil)
 
-	c.JSON(http.StatusUnauthorized, HttpResponse{
+	c.JSON(http.StatusUnauthorized, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusUnauthorized,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func Forbidden(c *gin.Context, message string, err string) {
 	logger.Error(err, nil)
 
-	c.JSON(http.StatusForbidden, HttpResponse{
+	c.JSON(http.StatusForbidden, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusForbidden,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func UnsupportedMediaType(c *gin.Context, message string, err string) {
 	logger.Error(err, nil)
 
-	c.JSON(http.StatusUnsupportedMediaType, HttpResponse{
+	c.JSON(http.StatusUnsupportedMediaType, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusUnsupportedMediaType,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func MethodNotAllowed(c *gin.Context, message string, err string) {
 	logger.Error(err, nil)
 
-	c.JSON(http.StatusMethodNotAllowed, HttpResponse{
+	c.JSON(http.StatusMethodNotAllowed, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusMethodNotAllowed,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func Conflict(c *gin.Context, message string, err string) {
 	logger.Error(err, nil)
 
-	c.JSON(http.StatusConflict, HttpResponse{
+	c.JSON(http.StatusConflict, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusConflict,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
+}
+
+// UnprocessableEntity returns a 422 response for a well-formed request whose content is rejected,
+// a malformed request body is answered with BadRequest.
+func UnprocessableEntity(c *gin.Context, message string, err string) {
+	logger.Error(err, nil)
+
+	c.JSON(http.StatusUnprocessableEntity, ResponseBody(HttpResponse{
+		Message:   message,
+		Error:     err,
+		Path:      c.Request.URL.Path,
+		Status:    http.StatusUnprocessableEntity,
+		Data:      nil,
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func TooManyRequests(c *gin.Context, message string, err string) {
 	logger.Error(err, nil)
 
-	c.JSON(http.StatusTooManyRequests, HttpResponse{
+	c.JSON(http.StatusTooManyRequests, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusTooManyRequests,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
+}
+
+func ServiceUnavailable(c *gin.Context, message string, err string) {
+	logger.Error(err, nil)
+
+	c.JSON(http.StatusServiceUnavailable, ResponseBody(HttpResponse{
+		Message:   message,
+		Error:     err,
+		Path:      c.Request.URL.Path,
+		Status:    http.StatusServiceUnavailable,
+		Data:      nil,
+		Timestamp: time.Now().UTC(),
+	}))
+}
+
+func GatewayTimeout(c *gin.Context, message string, err string) {
+	logger.Error(err, nil)
+
+	c.JSON(http.StatusGatewayTimeout, ResponseBody(HttpResponse{
+		Message:   message,
+		Error:     err,
+		Path:      c.Request.URL.Path,
+		Status:    http.StatusGatewayTimeout,
+		Data:      nil,
+		Timestamp: time.Now().UTC(),
+	}))
+}
+
+// StatusClientClosedRequest is the non-standard status used when the client closes the connection
+// before the response is written.
+const StatusClientClosedRequest = 499
+
+func ClientClosedRequest(c *gin.Context, message string, err string) {
+	logger.Warn(err, nil)
+
+	c.JSON(StatusClientClosedRequest, ResponseBody(HttpResponse{
+		Message:   message,
+		Error:     err,
+		Path:      c.Request.URL.Path,
+		Status:    StatusClientClosedRequest,
+		Data:      nil,
+		Timestamp: time.Now().UTC(),
+	}))
+}
+
+// ServerError writes the response of an unexpected error returned by a service.
+// An exceeded request deadline is answered with a 504 Gateway Timeout and a cancelled request
+// with a 499 Client Closed Request. A database connection that is not initialized, an open database circuit breaker
+// or a transaction that kept conflicting with concurrent ones is answered with a 503 Service Unavailable.
+// A statement breaking a foreign key is answered with a 409 Conflict and one breaking a not null or check constraint
+// with a 400 Bad Request, both with a sentence naming the column instead of the database error.
+// Any other error is answered with a 500 Internal Server Error.
+func ServerError(c *gin.Context, message string, err error) {
+	var constraintErr *database.ConstraintError
+	switch {
+	case errors.As(err, &constraintErr) && constraintErr.Kind == database.ErrForeignKeyViolation:
+		Conflict(c, message, constraintErr.Message())
+	case errors.As(err, &constraintErr):
+		BadRequest(c, message, constraintErr.Message())
+	case errors.Is(err, database.ErrDatabaseUnavailable):
+		ServiceUnavailable(c, message, "The database is unavailable, retry later")
+	case errors.Is(err, database.ErrCircuitOpen):
+		retryAfter := database.GetCircuitBreaker().RetryAfter()
+		c.Header("Retry-After", strconv.Itoa(max(int(math.Ceil(retryAfter.Seconds())), 1)))
+		ServiceUnavailable(c, message, "The database is temporarily unavailable, retry later")
+	case errors.Is(err, database.ErrContention):
+		c.Header("Retry-After", "1")
+		ServiceUnavailable(c, message, "The request conflicted with concurrent requests, retry later")
+	case errors.Is(err, context.DeadlineExceeded):
+		GatewayTimeout(c, message, "The request took too long to process")
+	case errors.Is(err, context.Canceled):
+		ClientClosedRequest(c, message, "The request was cancelled by the client")
+	default:
+		InternalServerError(c, message, err.Error())
+	}
 }
 
 /***** Map Responses *****/
 func BadRequestMap(c *gin.Context, message string, err []map[string]string) {
 	logger.Error("Bad Request Map Error", nil)
 
-	c.JSON(http.StatusBadRequest, HttpResponse{
+	c.JSON(http.StatusBadRequest, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusBadRequest,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func NotFoundMap(c *gin.Context, message string, err []map[string]string) {
 	logger.Error("Not Found Map Error", nil)
 
-	c.JSON(http.StatusNotFound, HttpResponse{
+	c.JSON(http.StatusNotFound, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusNotFound,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func InternalServerErrorMap(c *gin.Context, message string, err []map[string]string) {
 	logger.Error("Internal Server Error Map Error", nil)
 
-	c.JSON(http.StatusInternalServerError, HttpResponse{
+	c.JSON(http.StatusInternalServerError, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusInternalServerError,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func UnauthorizedMap(c *gin.Context, message string, err []map[string]string) {
 	logger.Error("Unauthorized Map Error", nil)
 
-	c.JSON(http.StatusUnauthorized, HttpResponse{
+	c.JSON(http.StatusUnauthorized, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusUnauthorized,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func ForbiddenMap(c *gin.Context, message string, err []map[string]string) {
 	logger.Error("Forbidden Map Error", nil)
 
-	c.JSON(http.StatusForbidden, HttpResponse{
+	c.JSON(http.StatusForbidden, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusForbidden,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func UnsupportedMediaTypeMap(c *gin.Context, message string, err []map[string]string) {
 	logger.Error("Unsupported Media Type Map Error", nil)
 
-	c.JSON(http.StatusUnsupportedMediaType, HttpResponse{
+	c.JSON(http.StatusUnsupportedMediaType, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusUnsupportedMediaType,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func MethodNotAllowedMap(c *gin.Context, message string, err []map[string]string) {
 	logger.Error("Method Not Allowed Map Error", nil)
 
-	c.JSON(http.StatusMethodNotAllowed, HttpResponse{
+	c.JSON(http.StatusMethodNotAllowed, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusMethodNotAllowed,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func ConflictMap(c *gin.Context, message string, err []map[string]string) {
 	logger.Error("Conflict Map Error", nil)
 
-	c.JSON(http.StatusConflict, HttpResponse{
+	c.JSON(http.StatusConflict, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusConflict,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
+}
+
+func UnprocessableEntityMap(c *gin.Context, message string, err []map[string]string) {
+	logger.Error("Unprocessable Entity Map Error", nil)
+
+	c.JSON(http.StatusUnprocessableEntity, ResponseBody(HttpResponse{
+		Message:   message,
+		Error:     err,
+		Path:      c.Request.URL.Path,
+		Status:    http.StatusUnprocessableEntity,
+		Data:      nil,
+		Timestamp: time.Now().UTC(),
+	}))
 }
 
 func TooManyRequestsMap(c *gin.Context, message string, err []map[string]string) {
 	logger.Error("Too Many Requests Map Error", nil)
 
-	c.JSON(http.StatusTooManyRequests, HttpResponse{
+	c.JSON(http.StatusTooManyRequests, ResponseBody(HttpResponse{
 		Message:   message,
 		Error:     err,
 		Path:      c.Request.URL.Path,
 		Status:    http.StatusTooManyRequests,
 		Data:      nil,
-		Timestamp: time.Now(),
-	})
+		Timestamp: time.Now().UTC(),
+	}))
+}
+
+func ServiceUnavailableMap(c *gin.Context, message string, err []map[string]string) {
+	logger.Error("Service Unavailable Map Error", nil)
+
+	c.JSON(http.StatusServiceUnavailable, ResponseBody(HttpResponse{
+		Message:   message,
+		Error:     err,
+		Path:      c.Request.URL.Path,
+		Status:    http.StatusServiceUnavailable,
+		Data:      nil,
+		Timestamp: time.Now().UTC(),
+	}))
 }
@@ -0,0 +1,254 @@
+package http_util
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	fieldutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/field-util"
+)
+
+// Cursor represents the continuation of a streamed list response.
+// Next is set when more items may follow, it is passed back to resume the list after the last item received.
+// Partial tells the list was cut short, e.g. before the request timed out, rather than by its limit.
+type Cursor struct {
+	Next    string `json:"next,omitempty"` // The opaque cursor of the next items (optional)
+	Partial bool   `json:"partial"`        // Whether the list was cut short
+}
+
+// ListStream writes a 200 list response item by item, flushing the items as soon as they are written,
+// so a client receives the items already fetched even if the request is cut short later on.
+// The body has the shape of the other responses, the cursor takes the place of the pagination metadata.
+// Once the first items are written the status can no longer change, a failure is told by a truncated body.
+type ListStream struct {
+	c       *gin.Context
+	message string
+	started bool
+	count   int
+}
+
+// StreamList starts a streamed list response, nothing is written until the first items or the end.
+func StreamList(c *gin.Context, message string) *ListStream {
+	return &ListStream{c: c, message: message}
+}
+
+// Started reports whether the response has been started.
+func (s *ListStream) Started() bool {
+	return s.started
+}
+
+// begin writes the status, the headers and the fields preceding the items.
+func (s *ListStream) begin() error {
+	head, err := json.Marshal(ResponseBody(struct {
+		Message string `json:"message"`
+		Error   any    `json:"error"`
+		Path    string `json:"path"`
+		Status  int    `json:"status"`
+	}{s.message, nil, s.c.Request.URL.Path, http.StatusOK}))
+	if err != nil {
+		return err
+	}
+
+	s.c.Header("Content-Type", "application/json; charset=utf-8")
+	s.c.Status(http.StatusOK)
+	s.started = true
+
+	// Reopen the object to append the items
+	_, err = s.c.Writer.Write(append(head[:len(head)-1], `,"data":[`...))
+	return err
+}
+
+// Write appends the items, a slice, to the response and flushes them.
+// Their times are displayed in the time zone of the request and their fields named in the field naming, like the other responses.
+func (s *ListStream) Write(items any) error {
+	if !s.started {
+		if err := s.begin(); err != nil {
+			return err
+		}
+	}
+
+	v := reflect.ValueOf(displayData(s.c, items))
+	for i := 0; i < v.Len(); i++ {
+		item, err := json.Marshal(ResponseBody(v.Index(i).Interface()))
+		if err != nil {
+			return err
+		}
+		if s.count > 0 {
+			item = append([]byte{','}, item...)
+		}
+		if _, err := s.c.Writer.Write(item); err != nil {
+			return err
+		}
+		s.count++
+	}
+
+	s.c.Writer.Flush()
+	return nil
+}
+
+// End closes the list with the cursor, the warnings collected in the request context and the timestamp.
+func (s *ListStream) End(cursor *Cursor) error {
+	if !s.started {
+		if err := s.begin(); err != nil {
+			return err
+		}
+	}
+
+	tail, err := json.Marshal(ResponseBody(struct {
+		Cursor    *Cursor   `json:"cursor,omitempty"`
+		Warnings  []string  `json:"warnings,omitempty"`
+		Timestamp time.Time `json:"timestamp"`
+	}{cursor, metacontext.ExtractWarnings(s.c.Request.Context()), displayTime(s.c)}))
+	if err != nil {
+		return err
+	}
+
+	// Close the items and append the remaining fields of the object
+	if _, err := s.c.Writer.Write(append([]byte("],"), tail[1:]...)); err != nil {
+		return err
+	}
+
+	s.c.Writer.Flush()
+	return nil
+}
+
+// NDJSONStream writes a 200 response of newline-delimited JSON, one item per line, flushing the items as soon as they are written.
+// Unlike ListStream there is no enclosing object, so a client processes every line on its own while the response goes on.
+// Once the first items are written the status can no longer change, a failure is told by a truncated body.
+type NDJSONStream struct {
+	c       *gin.Context
+	encoder *json.Encoder
+}
+
+// StreamNDJSON starts a newline-delimited JSON response, nothing is written until the first items.
+func StreamNDJSON(c *gin.Context) *NDJSONStream {
+	return &NDJSONStream{c: c}
+}
+
+// Started reports whether the response has been started.
+func (s *NDJSONStream) Started() bool {
+	return s.encoder != nil
+}
+
+// Write appends the items, a slice, to the response one per line and flushes them.
+// Their times are displayed in the time zone of the request and their fields named in the field naming, like the other responses.
+func (s *NDJSONStream) Write(items any) error {
+	if s.encoder == nil {
+		s.c.Header("Content-Type", "application/x-ndjson")
+		s.c.Status(http.StatusOK)
+		s.encoder = json.NewEncoder(s.c.Writer)
+	}
+
+	v := reflect.ValueOf(displayData(s.c, items))
+	for i := 0; i < v.Len(); i++ {
+		// The encoder ends every item with a newline
+		if err := s.encoder.Encode(ResponseBody(v.Index(i).Interface())); err != nil {
+			return err
+		}
+	}
+
+	s.c.Writer.Flush()
+	return nil
+}
+
+// End completes the response, an empty one is sent with the status and the headers of a list.
+func (s *NDJSONStream) End() {
+	if s.encoder == nil {
+		s.c.Header("Content-Type", "application/x-ndjson")
+		s.c.Status(http.StatusOK)
+		s.c.Writer.WriteHeaderNow()
+	}
+}
+
+// CSVStream writes a 200 CSV response, a header row then one row per record, flushing the records as soon as they are written.
+// The columns are named in the field naming of the responses, and the times of the records are formatted with FormatTime.
+// Once the first records are written the status can no longer change, a failure is told by a truncated body.
+type CSVStream struct {
+	c        *gin.Context
+	filename string
+	header   []string
+	writer   *csv.Writer
+}
+
+// StreamCSV starts a CSV response downloaded as the file with the given name, nothing is written until the first records.
+// The header holds the camelCase names of the columns, like the json tags.
+func StreamCSV(c *gin.Context, filename string, header []string) *CSVStream {
+	return &CSVStream{c: c, filename: filename, header: header}
+}
+
+// Started reports whether the response has been started.
+func (s *CSVStream) Started() bool {
+	return s.writer != nil
+}
+
+// begin writes the status, the headers and the header row.
+func (s *CSVStream) begin() error {
+	s.c.Header("Content-Type", "text/csv; charset=utf-8")
+	s.c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": s.filename}))
+	s.c.Status(http.StatusOK)
+	s.writer = csv.NewWriter(s.c.Writer)
+
+	header := make([]string, 0, len(s.header))
+	for _, name := range s.header {
+		if fieldutil.GetFieldNaming() == fieldutil.SnakeCase {
+			name = fieldutil.ToSnakeCase(name)
+		}
+		header = append(header, name)
+	}
+	return s.writer.Write(header)
+}
+
+// Write appends the records to the response and flushes them.
+// A cell starting like a formula, e.g. =SUM(A1), is prefixed with a quote so a spreadsheet opening the file does not evaluate it.
+func (s *CSVStream) Write(records [][]string) error {
+	if s.writer == nil {
+		if err := s.begin(); err != nil {
+			return err
+		}
+	}
+
+	for _, record := range records {
+		for i, cell := range record {
+			if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+				record[i] = "'" + cell
+			}
+		}
+		if err := s.writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	s.writer.Flush()
+	if err := s.writer.Error(); err != nil {
+		return err
+	}
+	s.c.Writer.Flush()
+	return nil
+}
+
+// End completes the response, an empty one is sent with the status, the headers and the header row.
+func (s *CSVStream) End() error {
+	if s.writer == nil {
+		if err := s.begin(); err != nil {
+			return err
+		}
+	}
+
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+// FormatTime formats a time of a record as RFC 3339 in the display time zone of the request, a nil time is an empty cell.
+func (s *CSVStream) FormatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(metacontext.ExtractTimeZone(s.c.Request.Context())).Format(time.RFC3339)
+}
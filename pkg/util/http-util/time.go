@@ -0,0 +1,100 @@
+package http_util
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// displayTime returns the current time in the display time zone of the request.
+func displayTime(c *gin.Context) time.Time {
+	return time.Now().In(metacontext.ExtractTimeZone(c.Request.Context()))
+}
+
+// displayData returns a copy of the data of a response with its times in the display time zone of the request,
+// UTC unless the request selected another one. The data itself is left unchanged.
+func displayData(c *gin.Context, data any) any {
+	if data == nil {
+		return nil
+	}
+
+	return inLocation(reflect.ValueOf(data), metacontext.ExtractTimeZone(c.Request.Context())).Interface()
+}
+
+// inLocation copies the value, converting the times it holds to the location.
+// The values with their own JSON encoding, e.g. the calendar dates, are copied as they are.
+func inLocation(v reflect.Value, loc *time.Location) reflect.Value {
+	t := v.Type()
+	if t == timeType {
+		return reflect.ValueOf(v.Interface().(time.Time).In(loc))
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(inLocation(v.Elem(), loc))
+		return p
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		i := reflect.New(t).Elem()
+		i.Set(inLocation(v.Elem(), loc))
+		return i
+	}
+
+	if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		s := reflect.New(t).Elem()
+		s.Set(v)
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				s.Field(i).Set(inLocation(v.Field(i), loc))
+			}
+		}
+		return s
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		s := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(inLocation(v.Index(i), loc))
+		}
+		return s
+	case reflect.Array:
+		a := reflect.New(t).Elem()
+		for i := 0; i < v.Len(); i++ {
+			a.Index(i).Set(inLocation(v.Index(i), loc))
+		}
+		return a
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		m := reflect.MakeMapWithSize(t, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), inLocation(iter.Value(), loc))
+		}
+		return m
+	default:
+		return v
+	}
+}
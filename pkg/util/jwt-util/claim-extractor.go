@@ -19,7 +19,18 @@ func GetInt64Claim(claims jwt.MapClaims, key string) (int64, error) {
 }
 
 // GetStringClaim retrieves a string claim from the JWT claims.
-// It checks if the claim exists and is of type string.
+// It returns an empty string if the claim does not exist or is not of type string.
+func GetStringClaim(claims jwt.MapClaims, key string) string {
+	if val, ok := claims[key]; ok {
+		if str, ok := val.(string); ok {
+			return str
+		}
+	}
+	return ""
+}
+
+// GetStringSliceClaim retrieves a string slice claim from the JWT claims.
+// It checks if the claim exists and is of type []interface{} holding strings.
 func GetStringSliceClaim(claims jwt.MapClaims, key string) []string {
 	if val, ok := claims[key]; ok {
 		if slice, ok := val.([]interface{}); ok {
@@ -34,3 +45,37 @@ func GetStringSliceClaim(claims jwt.MapClaims, key string) []string {
 	}
 	return nil
 }
+
+// GetInt64SliceClaim retrieves an int64 slice claim from the JWT claims.
+// It returns nil if the claim does not exist, and skips the values that are not numbers.
+func GetInt64SliceClaim(claims jwt.MapClaims, key string) []int64 {
+	if val, ok := claims[key]; ok {
+		if slice, ok := val.([]interface{}); ok {
+			intSlice := make([]int64, 0, len(slice))
+			for _, v := range slice {
+				if f, ok := v.(float64); ok {
+					intSlice = append(intSlice, int64(f))
+				}
+			}
+			return intSlice
+		}
+	}
+	return nil
+}
+
+// GetInt64MapClaim retrieves a map of int64 claims by name from the JWT claims.
+// It returns nil if the claim does not exist, and skips the values that are not numbers.
+func GetInt64MapClaim(claims jwt.MapClaims, key string) map[string]int64 {
+	if val, ok := claims[key]; ok {
+		if m, ok := val.(map[string]interface{}); ok {
+			intMap := make(map[string]int64, len(m))
+			for k, v := range m {
+				if f, ok := v.(float64); ok {
+					intMap[k] = int64(f)
+				}
+			}
+			return intMap
+		}
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package jwt_util
 
 import (
 	"crypto/rsa"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -37,3 +39,96 @@ func LoadPrivateKey() (*rsa.PrivateKey, error) {
 	}
 	return jwt.ParseRSAPrivateKeyFromPEM(keyData)
 }
+
+// ErrKeyPairMismatch is returned when the public key of JWT_PUBLIC_KEY_PATH is not the one of the private key,
+// the tokens signed with the private key would not verify.
+var ErrKeyPairMismatch = errors.New("the public key does not match the private key")
+
+// LoadKeyPair loads the private and the public keys of the environment variables and checks that they form a pair.
+func LoadKeyPair() (*rsa.PrivateKey, *rsa.PublicKey, error) {
+	privateKey, err := LoadPrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	publicKey, err := LoadPublicKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !privateKey.PublicKey.Equal(publicKey) {
+		return nil, nil, ErrKeyPairMismatch
+	}
+	return privateKey, publicKey, nil
+}
+
+// defaultKeyID is the ID of the current key when JWT_KEY_ID is not set.
+const defaultKeyID = "default"
+
+// GetKeyID returns the ID of the current key, stamped in the kid header of the issued tokens.
+// It retrieves the ID from an environment variable and defaults to `default`.
+func GetKeyID() string {
+	id := strings.TrimSpace(os.Getenv("JWT_KEY_ID"))
+	if id == "" {
+		return defaultKeyID
+	}
+	return id
+}
+
+// LoadKeySet returns the key set of the signing algorithm, HS256 or RS256.
+// The current key is the secret for HS256, or the key pair of JWT_PRIVATE_KEY_PATH and JWT_PUBLIC_KEY_PATH for RS256,
+// identified by JWT_KEY_ID. The previous keys of JWT_PREVIOUS_KEYS keep verifying the tokens they signed.
+// The RS256 keys must form a pair, see LoadKeyPair.
+func LoadKeySet(algorithm string, secret string) (*KeySet, error) {
+	var current Key
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		current = HMACKey(GetKeyID(), []byte(secret))
+	case jwt.SigningMethodRS256.Alg():
+		privateKey, publicKey, err := LoadKeyPair()
+		if err != nil {
+			return nil, err
+		}
+		current = RSAKey(GetKeyID(), privateKey, publicKey)
+	default:
+		return nil, fmt.Errorf("unsupported signing method: %s", algorithm)
+	}
+
+	previous, err := ParsePreviousKeys(algorithm, os.Getenv("JWT_PREVIOUS_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(current, previous...)
+}
+
+// ParsePreviousKeys loads the keys replaced by a rotation, which only verify the tokens they signed.
+// The value of JWT_PREVIOUS_KEYS is a comma-separated list of `kid=key` pairs, the key being the secret for HS256
+// or the path of the public key for RS256 (e.g. `2025-01=/keys/publicKey-2025-01.pem`).
+func ParsePreviousKeys(algorithm string, value string) ([]Key, error) {
+	var keys []Key
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		id, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || strings.TrimSpace(id) == "" || value == "" {
+			return nil, fmt.Errorf("JWT_PREVIOUS_KEYS must be a comma-separated list of kid=key pairs")
+		}
+		id = strings.TrimSpace(id)
+
+		if algorithm == jwt.SigningMethodHS256.Alg() {
+			keys = append(keys, HMACKey(id, []byte(value)))
+			continue
+		}
+
+		keyData, err := os.ReadFile(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the public key %s: %v", id, err)
+		}
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(keyData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the public key %s: %v", id, err)
+		}
+		keys = append(keys, RSAKey(id, nil, publicKey))
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,161 @@
+package jwt_util
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrUnknownKeyID is returned when a token names a key that is not in the key set, e.g. a key removed after a rotation.
+	ErrUnknownKeyID = errors.New("unknown key ID")
+
+	// ErrCurrentKeyRemoved is returned when the current key is removed from the key set, it must be replaced first.
+	ErrCurrentKeyRemoved = errors.New("the current key cannot be removed")
+
+	// ErrNoSigningKey is returned when the key asked to sign the tokens has no signing key, e.g. a public key only.
+	ErrNoSigningKey = errors.New("the key cannot sign tokens")
+)
+
+// Key is a key of a KeySet, identified by the kid header of the tokens it signs.
+// The signing key is the secret for HMAC, or the private key for RSA, and can be left out of a key that only verifies.
+// The verification key is the secret for HMAC, or the public key for RSA.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   any
+	VerifyKey any
+}
+
+// HMACKey returns the HS256 key of the secret, which both signs and verifies.
+func HMACKey(id string, secret []byte) Key {
+	return Key{ID: id, Method: jwt.SigningMethodHS256, SignKey: secret, VerifyKey: secret}
+}
+
+// RSAKey returns the RS256 key of the key pair, the private key can be nil for a key that only verifies.
+func RSAKey(id string, privateKey *rsa.PrivateKey, publicKey *rsa.PublicKey) Key {
+	key := Key{ID: id, Method: jwt.SigningMethodRS256, VerifyKey: publicKey}
+	if privateKey != nil {
+		key.SignKey = privateKey
+	}
+	return key
+}
+
+// KeySet holds the keys of the tokens by ID, so the signing key can be rotated without invalidating the tokens already issued.
+// The tokens are signed with the current key and stamped with its ID in their kid header, and verified with the key
+// matching their kid. A previous key keeps verifying its tokens until it is removed, e.g. once they are all expired.
+// A token without kid, issued before the keys had IDs, is verified with the current key.
+// It is safe for concurrent use.
+type KeySet struct {
+	mu      sync.RWMutex
+	keys    map[string]Key
+	current string
+}
+
+// NewKeySet returns a key set signing with the current key, and verifying with it and the previous keys.
+func NewKeySet(current Key, previous ...Key) (*KeySet, error) {
+	s := &KeySet{keys: make(map[string]Key)}
+	for _, key := range previous {
+		if err := s.Add(key); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.Rotate(current); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add adds a key verifying the tokens stamped with its ID, replacing the key with the same ID if any.
+func (s *KeySet) Add(key Key) error {
+	if key.ID == "" {
+		return errors.New("the key ID is empty")
+	}
+	if key.Method == nil || key.VerifyKey == nil {
+		return fmt.Errorf("the key %s has no signing method or verification key", key.ID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = key
+	return nil
+}
+
+// Rotate adds the key and signs the next tokens with it, the previous current key keeps verifying its tokens.
+func (s *KeySet) Rotate(key Key) error {
+	if key.SignKey == nil {
+		return fmt.Errorf("%w: %s", ErrNoSigningKey, key.ID)
+	}
+	if err := s.Add(key); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = key.ID
+	return nil
+}
+
+// Remove removes the key, the tokens stamped with its ID no longer verify.
+func (s *KeySet) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id == s.current {
+		return ErrCurrentKeyRemoved
+	}
+	if _, ok := s.keys[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKeyID, id)
+	}
+	delete(s.keys, id)
+	return nil
+}
+
+// Current returns the key signing the tokens.
+func (s *KeySet) Current() Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys[s.current]
+}
+
+// Sign returns the token of the claims signed with the current key, stamped with its ID.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key := s.Current()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.SignKey)
+}
+
+// Keyfunc returns the verification key of the token, the key matching its kid.
+// The algorithm of the token must be the one of the key, so a token cannot be verified with a key meant for another one,
+// e.g. an HMAC token with a public key.
+func (s *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id := s.current
+	if kid, ok := token.Header["kid"]; ok {
+		id, ok = kid.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", ErrUnknownKeyID, kid)
+		}
+	}
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyID, id)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.VerifyKey, nil
+}
+
+// Parse parses the token and verifies it with the key matching its kid.
+func (s *KeySet) Parse(tokenStr string, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	return jwt.Parse(tokenStr, s.Keyfunc, opts...)
+}
@@ -0,0 +1,25 @@
+package text_util
+
+import (
+	"slices"
+	"strings"
+)
+
+// GmailStyleDomains are the domains ignoring the dots and the plus-addressing in the local part of an address,
+// e.g. john.doe+news@gmail.com and johndoe@googlemail.com reach the same inbox.
+var GmailStyleDomains = []string{"gmail.com", "googlemail.com"}
+
+// NormalizeEmail returns the canonical form of an email address, so the addresses reaching the same inbox are equal.
+// The address is lowercased, and for the gmail-style domains the dots and the plus suffix of the local part
+// are removed and the domain is gmail.com. The other addresses are only lowercased.
+func NormalizeEmail(email string) string {
+	email = strings.ToLower(email)
+
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || !slices.Contains(GmailStyleDomains, domain) {
+		return email
+	}
+
+	local, _, _ = strings.Cut(local, "+")
+	return strings.ReplaceAll(local, ".", "") + "@" + GmailStyleDomains[0]
+}
@@ -0,0 +1,41 @@
+package text_util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Similarity returns the trigram similarity of two strings, from 0 (no trigram in common) to 1 (the same trigrams).
+// It follows the similarity function of the pg_trgm extension of PostgreSQL: the strings are lowercased and split
+// into words of letters and digits, each word is padded with two spaces before and one after, and the similarity is
+// the number of trigrams in common divided by the number of distinct trigrams of both strings.
+func Similarity(a string, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	common := 0
+	for trigram := range ta {
+		if _, ok := tb[trigram]; ok {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+// trigrams returns the distinct trigrams of the words of the string.
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
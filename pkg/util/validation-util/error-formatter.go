@@ -1,39 +1,115 @@
 package validation_util
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"gopkg.in/go-playground/validator.v9"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	fieldutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/field-util"
 )
 
-// FormatValidationErrors formats validation errors into a slice of maps.
-// Each map contains the field name and the corresponding error message.
+// FieldError is the validation error of a field found outside of the validator, e.g. a role that does not exist.
+// Path is the JSON path of the field, e.g. "roles[1]", with the field names of the json tags.
+// Message follows the path in the formatted message, e.g. "must be an existing role".
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+// FieldErrors are the validation errors of several fields, reported at once rather than failing on the first one.
+type FieldErrors []FieldError
+
+// Error lists the paths and the messages of the errors.
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fmt.Sprintf("%s: %s", fe.Path, fe.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// FormatValidationErrors formats validation errors into a slice of maps, with the messages in English.
+// Each map contains the JSON path of the field, in the field naming of the responses, and the corresponding error message.
+// The path locates the elements of the lists and the fields of the nested structs, e.g. "roles[1].roleName".
+// The errors of the validator and the FieldErrors found in err are both formatted, in this order.
 func FormatValidationErrors(err error) []map[string]string {
-	var errors []map[string]string
+	return formatValidationErrors(metacontext.DefaultLocale, err)
+}
+
+// FormatLocalizedValidationErrors formats validation errors like FormatValidationErrors, with the messages of the validator
+// in the locale selected by the request, e.g. "id" for an Accept-Language of id-ID, and in English for the other locales.
+// The messages of the FieldErrors are given by the services and are left as they are.
+func FormatLocalizedValidationErrors(ctx context.Context, err error) []map[string]string {
+	return formatValidationErrors(metacontext.ExtractLocale(ctx), err)
+}
 
-	if ve, ok := err.(validator.ValidationErrors); ok {
+// formatValidationErrors formats validation errors with the messages of the validator in the given locale.
+func formatValidationErrors(locale string, err error) []map[string]string {
+	var formatted []map[string]string
+
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
 		for _, fe := range ve {
-			// Customize the message based on tag
-			var message string
-			switch fe.Tag() {
-			case "required":
-				message = fmt.Sprintf("%s is required", fe.Field())
-			case "email":
-				message = fmt.Sprintf("%s must be a valid email address", fe.Field())
-			case "min":
-				message = fmt.Sprintf("%s must be at least %s characters", fe.Field(), fe.Param())
-			case "max":
-				message = fmt.Sprintf("%s must be at most %s characters", fe.Field(), fe.Param())
-			default:
-				message = fmt.Sprintf("%s is not valid", fe.Field())
+			field := fieldPath(fe.Namespace())
+
+			// The parameter of required_without is the name of another field, written as its path
+			param := fe.Param()
+			if fe.Tag() == "required_without" {
+				param = namedPath(lowerFirst(param))
 			}
 
-			errors = append(errors, map[string]string{
-				"field":   fe.Field(),
-				"message": message,
+			formatted = append(formatted, map[string]string{
+				"field":   field,
+				"message": translate(locale, fe.Tag(), field, param),
+			})
+		}
+	}
+
+	var fieldErrs FieldErrors
+	if errors.As(err, &fieldErrs) {
+		for _, fe := range fieldErrs {
+			field := namedPath(fe.Path)
+			formatted = append(formatted, map[string]string{
+				"field":   field,
+				"message": fmt.Sprintf("%s %s", field, fe.Message),
 			})
 		}
 	}
 
-	return errors
+	return formatted
+}
+
+// fieldPath returns the JSON path of the field from its namespace in the validator, e.g. "roles[1].roleName"
+// for "User.roles[1].roleName": the names of the json tags without the name of the validated struct.
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return namedPath(path)
+	}
+	return namedPath(namespace)
+}
+
+// namedPath returns the JSON path with its field names in the field naming of the responses,
+// the indexes and the keys between brackets are kept as they are.
+func namedPath(path string) string {
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		name, index, _ := strings.Cut(segment, "[")
+		segments[i] = fieldutil.FieldName(name)
+		if index != "" {
+			segments[i] += "[" + index
+		}
+	}
+	return strings.Join(segments, ".")
+}
+
+// lowerFirst returns the name of a struct field with a lowercase first letter, the json tag name of the single-word fields.
+func lowerFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
 }
@@ -0,0 +1,32 @@
+package validation_util
+
+import (
+	"unicode"
+)
+
+const (
+	// MinStrongPasswordLength is the minimum length of a password under the strict password policy
+	MinStrongPasswordLength = 12
+)
+
+// IsStrongPassword reports whether the password meets the strict password policy:
+// at least MinStrongPasswordLength characters mixing lowercase and uppercase letters, digits and symbols.
+func IsStrongPassword(password string) bool {
+	var length int
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		length++
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	return length >= MinStrongPasswordLength && lower && upper && digit && symbol
+}
@@ -0,0 +1,96 @@
+package validation_util
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"gopkg.in/go-playground/validator.v9"
+)
+
+const (
+	// MaxPasswordBytes is the maximum length of a password in bytes, bcrypt ignores the bytes beyond it
+	MaxPasswordBytes = 72
+
+	// UserTypeServiceAccount and UserTypeUserAccount are the types of the users
+	UserTypeServiceAccount = "SERVICE_ACCOUNT"
+	UserTypeUserAccount    = "USER_ACCOUNT"
+)
+
+// customValidation is a custom validation tag, the messages of its validation errors are those of the tag in messages.
+type customValidation struct {
+	tag string
+	fn  validator.Func
+}
+
+// customValidations are the custom validation tags registered by RegisterValidators, e.g. `validate:"username"`.
+var customValidations = []customValidation{
+	{tag: "rolename", fn: validateRoleName},
+	{tag: "username", fn: validateUsername},
+	{tag: "password", fn: validatePassword},
+	{tag: "usertype", fn: validateUserType},
+}
+
+// RegisterValidators registers on the validator the custom validation tags and the JSON field names of their errors.
+// It is called once by Init on the validator shared by the Validate methods of the entities and the binding of the handlers,
+// and the messages of the errors of the custom tags are those formatted by FormatValidationErrors.
+func RegisterValidators(v *validator.Validate) error {
+	// Register tag name function to use JSON field names if available
+	// instead of struct field names
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		tag := fld.Tag.Get("json")
+		if tag == "-" || tag == "" {
+			return fld.Name // fallback ke nama field struct
+		}
+		return strings.Split(tag, ",")[0]
+	})
+
+	for _, cv := range customValidations {
+		if err := v.RegisterValidation(cv.tag, cv.fn); err != nil {
+			return fmt.Errorf("failed to register the %q validation: %w", cv.tag, err)
+		}
+	}
+
+	return nil
+}
+
+// validateUsername is the "username" validation tag, it refuses the usernames holding spaces or control characters.
+// The length of the usernames is left to the min and max tags.
+func validateUsername(fl validator.FieldLevel) bool {
+	return !strings.ContainsFunc(fl.Field().String(), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	})
+}
+
+// validatePassword is the "password" validation tag, it refuses the passwords longer than MaxPasswordBytes bytes,
+// which the max tag counting the characters accepts when they are not ASCII.
+func validatePassword(fl validator.FieldLevel) bool {
+	return len(fl.Field().String()) <= MaxPasswordBytes
+}
+
+// validateUserType is the "usertype" validation tag, it accepts the types of the users.
+func validateUserType(fl validator.FieldLevel) bool {
+	switch fl.Field().String() {
+	case UserTypeServiceAccount, UserTypeUserAccount:
+		return true
+	}
+	return false
+}
+
+// bindingValidator is the validator of the gin binding, backed by the shared validator.
+// The request bodies are validated by their Validate methods once bound, so their validation errors are answered
+// with 422 Unprocessable Entity by the handlers rather than the 400 Bad Request of a body that cannot be bound.
+type bindingValidator struct {
+	validate *validator.Validate
+}
+
+// ValidateStruct accepts every bound value, they are validated by their Validate methods.
+func (b bindingValidator) ValidateStruct(any) error {
+	return nil
+}
+
+// Engine returns the shared validator, so the validations registered through the gin binding are shared as well.
+func (b bindingValidator) Engine() any {
+	return b.validate
+}
@@ -0,0 +1,39 @@
+package validation_util
+
+import (
+	"regexp"
+	"strings"
+
+	"gopkg.in/go-playground/validator.v9"
+)
+
+const (
+	// RoleNamePrefix is the prefix every role name starts with
+	RoleNamePrefix = "ROLE_"
+
+	// MaxRoleNameLength is the maximum length of a role name, as stored in the roles table
+	MaxRoleNameLength = 20
+
+	// AtLeastOneRoleTag is the tag of the validation error reported for a user without any role
+	AtLeastOneRoleTag = "atleastonerole"
+)
+
+// roleNamePattern matches the role names once normalized: the prefix followed by uppercase letters,
+// digits and single underscores, e.g. ROLE_ADMIN or ROLE_SUPER_ADMIN.
+var roleNamePattern = regexp.MustCompile(`^ROLE_[A-Z0-9]+(_[A-Z0-9]+)*$`)
+
+// NormalizeRoleName trims the surrounding spaces of a role name and converts it to uppercase.
+func NormalizeRoleName(name string) string {
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+// IsValidRoleName reports whether the role name follows the naming convention once normalized.
+func IsValidRoleName(name string) bool {
+	name = NormalizeRoleName(name)
+	return len(name) <= MaxRoleNameLength && roleNamePattern.MatchString(name)
+}
+
+// validateRoleName is the "rolename" validation tag, it accepts the role names following the naming convention.
+func validateRoleName(fl validator.FieldLevel) bool {
+	return IsValidRoleName(fl.Field().String())
+}
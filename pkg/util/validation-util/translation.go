@@ -0,0 +1,99 @@
+package validation_util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
+)
+
+// invalidKey is the key of the message of a validation error whose tag has no message of its own
+const invalidKey = "invalid"
+
+// messages are the messages of the validation errors by locale and by tag, in the format of the universal translator:
+// {0} is the JSON path of the field and {1} the parameter of the tag, e.g. the minimum length of the min tag.
+// A tag missing from a locale is written with the message of the fallback locale, English.
+var messages = map[string]map[string]string{
+	"en": {
+		"required":         "{0} is required",
+		"required_without": "{0} is required when {1} is empty",
+		"email":            "{0} must be a valid email address",
+		"min":              "{0} must be at least {1} characters",
+		"max":              "{0} must be at most {1} characters",
+		AtLeastOneRoleTag:  "{0} must contain at least one role",
+		"rolename":         "{0} must be a role name such as ROLE_USER",
+		"username":         "{0} must not contain spaces nor control characters",
+		"password":         fmt.Sprintf("{0} must be at most %d bytes", MaxPasswordBytes),
+		"usertype":         fmt.Sprintf("{0} must be %s or %s", UserTypeServiceAccount, UserTypeUserAccount),
+		invalidKey:         "{0} is not valid",
+	},
+	"id": {
+		"required":         "{0} wajib diisi",
+		"required_without": "{0} wajib diisi jika {1} kosong",
+		"email":            "{0} harus berupa alamat email yang valid",
+		"min":              "{0} minimal {1} karakter",
+		"max":              "{0} maksimal {1} karakter",
+		AtLeastOneRoleTag:  "{0} harus berisi minimal satu peran",
+		"rolename":         "{0} harus berupa nama peran seperti ROLE_USER",
+		"username":         "{0} tidak boleh berisi spasi maupun karakter kontrol",
+		"password":         fmt.Sprintf("{0} maksimal %d byte", MaxPasswordBytes),
+		"usertype":         fmt.Sprintf("{0} harus %s atau %s", UserTypeServiceAccount, UserTypeUserAccount),
+		invalidKey:         "{0} tidak valid",
+	},
+}
+
+// translator holds the messages of the validation errors of the supported locales, English being the fallback.
+var translator = newUniversalTranslator()
+
+// newUniversalTranslator returns the universal translator of the supported locales with their messages added.
+// It panics when a message cannot be added, which is a mistake in the messages above rather than an error at runtime.
+func newUniversalTranslator() *ut.UniversalTranslator {
+	fallback := en.New()
+	uni := ut.New(fallback, fallback, id.New())
+
+	for locale, catalog := range messages {
+		trans, found := uni.GetTranslator(locale)
+		if !found {
+			panic(fmt.Sprintf("no translator for the locale %q of the validation messages", locale))
+		}
+		for key, text := range catalog {
+			if err := trans.Add(key, text, false); err != nil {
+				panic(fmt.Sprintf("failed to add the %q validation message of the locale %q: %v", key, locale, err))
+			}
+		}
+	}
+
+	return uni
+}
+
+// MatchLocale returns the first of the given locales, e.g. the languages of an Accept-Language header by preference,
+// the validation messages are written in, and false when none is supported.
+// A regional locale matches its language when it is not supported itself, e.g. "id-ID" matches "id".
+func MatchLocale(locales ...string) (string, bool) {
+	for _, locale := range locales {
+		locale = strings.ReplaceAll(strings.TrimSpace(locale), "-", "_")
+		language, _, _ := strings.Cut(locale, "_")
+		if trans, found := translator.FindTranslator(locale, language); found {
+			return trans.Locale(), true
+		}
+	}
+	return "", false
+}
+
+// translate returns the message of the key in the locale, or in the fallback locale when the locale has no such message.
+// The message of a key missing from every locale is the invalidKey one.
+func translate(locale string, key string, params ...string) string {
+	trans, _ := translator.GetTranslator(locale)
+	if message, err := trans.T(key, params...); err == nil {
+		return message
+	}
+	if message, err := translator.GetFallback().T(key, params...); err == nil {
+		return message
+	}
+	if key != invalidKey {
+		return translate(locale, invalidKey, params...)
+	}
+	return params[0]
+}
@@ -1,33 +1,60 @@
 package validation_util
 
 import (
-	"reflect"
-	"strings"
 	"sync"
 
+	"github.com/gin-gonic/gin/binding"
 	"gopkg.in/go-playground/validator.v9"
 )
 
 var (
 	once     sync.Once
 	validate *validator.Validate
+
+	// structValidations are the struct-level validations registered by the packages of the validated types,
+	// kept so they are registered again when the validator is re-initialized
+	structMu          sync.Mutex
+	structValidations []structValidation
 )
 
+// structValidation is a struct-level validation along with the types it validates.
+type structValidation struct {
+	fn    validator.StructLevelFunc
+	types []interface{}
+}
+
+// RegisterStructValidation registers a struct-level validation of the given types, run by Struct after their field validations.
+// It is typically called from the init function of the package declaring the types, e.g. the rules across several fields.
+func RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{}) {
+	structMu.Lock()
+	defer structMu.Unlock()
+
+	structValidations = append(structValidations, structValidation{fn: fn, types: types})
+	if validate != nil {
+		validate.RegisterStructValidation(fn, types...)
+	}
+}
+
 // Init initializes the validator and registers custom validations.
 func Init() bool {
 	isSuccess := true
 	once.Do(func() {
 		validate = validator.New()
 
-		// Register tag name function to use JSON field names if available
-		// instead of struct field names
-		validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
-			tag := fld.Tag.Get("json")
-			if tag == "-" || tag == "" {
-				return fld.Name // fallback ke nama field struct
-			}
-			return strings.Split(tag, ",")[0]
-		})
+		// Register the custom validations, e.g. `validate:"rolename"`
+		if err := RegisterValidators(validate); err != nil {
+			isSuccess = false
+		}
+
+		// Register the struct-level validations of the types
+		structMu.Lock()
+		for _, sv := range structValidations {
+			validate.RegisterStructValidation(sv.fn, sv.types...)
+		}
+		structMu.Unlock()
+
+		// The handlers bind the request bodies with the same validator
+		binding.Validator = bindingValidator{validate: validate}
 	})
 
 	return isSuccess
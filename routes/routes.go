@@ -1,77 +1,127 @@
 package routes
 
 import (
-	"github.com/gin-contrib/gzip"
+	"os"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
 
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
 	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
 	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/compression"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/concurrency"
 	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/headers"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/locale"
 	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/logging"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/ratelimit"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/recovery"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/tenant"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/timeout"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/timezone"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/warning"
 	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
 )
 
+const (
+	// defaultAPIBasePath is the prefix of the versioned API routes when API_BASE_PATH is not set
+	defaultAPIBasePath = "/api"
+)
+
+// apiVersion registers the routes of one version of the API on its route group.
+type apiVersion struct {
+	name     string
+	register func(group *gin.RouterGroup)
+}
+
+// apiVersions lists the versions of the API served side by side.
+// A new version is added here with its own registration function, e.g. {name: "v2", register: registerV2Routes}.
+var apiVersions = []apiVersion{
+	{name: "v1", register: registerV1Routes},
+}
+
 // SetupRouter initializes the router and sets up the routes for the application.
 func SetupRouter() *gin.Engine {
-	// Create a new Gin router instance
-	r := gin.Default()
+	// Create a new Gin router instance, the panics are recovered by the recovery middleware instead of the default one of Gin
+	// A request with a method the path does not serve is answered with 405 and the Allow header, instead of 404
+	r := gin.New()
+	r.Use(gin.Logger(), recovery.Recovery())
+	r.HandleMethodNotAllowed = true
 
 	// Set up middleware for the router
 	// Middleware is used to handle cross-cutting concerns such as logging, security, and request ID generation
 	r.Use(
+		headers.AllowedMethods(r),
 		headers.SecurityHeaders(),
 		headers.CorsHeaders(),
 		headers.ContentType(),
 		logging.RequestLogger(),
-		gzip.Gzip(gzip.DefaultCompression),
+		warning.Warnings(),
+		timezone.TimeZone(),
+		locale.Locale(),
+		concurrency.ConcurrencyLimit(),
+		timeout.Timeout(),
+		compression.Compression(),
 	)
 
+	// Set up the health routes, answered without authentication to the liveness and readiness probes
+	healthGroup := r.Group("/health")
+	{
+		h := handler.NewHealthHandler(service.NewHealthService())
+		healthGroup.GET("/live", h.GetLiveness)
+		healthGroup.GET("/ready", h.GetReadiness)
+	}
+
+	// The tenant service resolves the tenant of the authenticated requests
+	ts := service.NewTenantService(repository.NewTenantRepository())
+
+	// The permission checks look up the permissions the access tokens do not carry
+	authorization.SetPermissionResolver(service.ResolveUserPermissions)
+
 	// Set up the authentication routes
 	// These routes handle user login and authentication
 	authGroup := r.Group("/auth")
 	{
 		// Routes for authentication
 		// These routes handle user login
-		s := service.NewAuthService()
-		h := handler.NewAuthHandler(s)
+		s := service.NewAuthService(clock.New())
+		ls := service.NewLoginAttemptService(repository.NewLoginAttemptRepository())
+		h := handler.NewAuthHandler(s, ls)
 
 		// Define the routes for authentication
 		// These routes handle user login
 		authGroup.POST("/login", h.Login)
 		authGroup.POST("/refresh-token", h.RefreshToken)
-	}
+		authGroup.POST("/reactivate", h.ReactivateAccount)
+		authGroup.POST("/change-password", h.ChangePassword)
 
-	// Set up the API version 1 routes
-	v1 := r.Group("/api/v1", authorization.JwtValidation())
-	{
-		// Routes for consumer management
-		// These routes handle CRUD operations for consumers
-		consumerGroup := v1.Group("/consumers")
-		{
-			// Initialize the transaction repository and service
-			// This is where the actual implementation of the repository and service would be used
-			r := repository.NewConsumerRepository()
-			s := service.NewConsumerService(r)
-
-			// Initialize the transaction handler with the service
-			// This handler handles the HTTP requests and responses for transaction-related operations
-			h := handler.NewConsumerHandler(s)
-
-			// Define the routes for transaction management
-			// These routes handle CRUD operations for transactions
-			// The GET methods are accessible to both admin and user roles
-			consumerGroup.GET("", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), h.GetAllConsumers)
-			consumerGroup.GET("/:id", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), h.GetConsumerByID)
-			consumerGroup.GET("/active", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), h.GetActiveConsumers)
-			consumerGroup.GET("/inactive", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), h.GetInactiveConsumers)
-			consumerGroup.GET("/suspended", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), h.GetSuspendedConsumers)
-
-			// The POST and PUT methods are restricted to admin users only
-			consumerGroup.POST("", authorization.RoleBasedAccessControl("ROLE_ADMIN"), h.CreateConsumer)
-			consumerGroup.PATCH("/:id", authorization.RoleBasedAccessControl("ROLE_ADMIN"), h.UpdateConsumerStatus)
+		// The registration is anonymous when the self-registration is enabled, each client address may then only
+		// register a few accounts per hour, otherwise it requires an authenticated admin like the other user management routes
+		uh := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository()))
+		registration := []gin.HandlerFunc{uh.RegisterUser}
+		if service.IsSelfRegistrationEnabled() {
+			registration = append([]gin.HandlerFunc{
+				ratelimit.RateLimit(ratelimit.RateLimitConfig{Limit: service.GetRegistrationRateLimit(), Window: time.Hour}),
+			}, registration...)
+		} else {
+			registration = append([]gin.HandlerFunc{
+				authorization.JwtValidation(),
+				tenant.TenantResolution(ts.IsMember),
+				authorization.RoleBasedAccessControl("ROLE_ADMIN"),
+			}, registration...)
 		}
+		authGroup.POST("/register", registration...)
+	}
+
+	// Set up the versioned API routes under the configured base path (e.g. /api/v1)
+	// Every version is protected by the JWT validation, and restricted to the tenant selected for the request
+	basePath := GetAPIBasePath()
+	for _, version := range apiVersions {
+		version.register(r.Group(basePath+"/"+version.name, authorization.JwtValidation(), tenant.TenantResolution(ts.IsMember)))
 	}
 
 	// NoRoute handler for undefined routes
@@ -82,9 +132,197 @@ func SetupRouter() *gin.Engine {
 
 	// NoMethod handler for unsupported HTTP methods
 	// This handler will be called when a request method is not allowed for the requested resource
+	// The Allow header is set by the AllowedMethods middleware, a path without any route is not found
 	r.NoMethod(func(c *gin.Context) {
+		if c.Writer.Header().Get("Allow") == "" {
+			httputil.NotFound(c, "Not Found", "The requested resource was not found")
+			return
+		}
 		httputil.MethodNotAllowed(c, "Method Not Allowed", "The requested method is not allowed for this resource")
 	})
 
 	return r
 }
+
+// registerV1Routes sets up the routes of the version 1 of the API.
+func registerV1Routes(v1 *gin.RouterGroup) {
+	// Routes for consumer management
+	// These routes handle CRUD operations for consumers
+	consumerGroup := v1.Group("/consumers")
+	{
+		// Initialize the transaction repository and service
+		// This is where the actual implementation of the repository and service would be used
+		r := repository.NewConsumerRepository()
+		s := service.NewConsumerService(r)
+
+		// Initialize the transaction handler with the service
+		// This handler handles the HTTP requests and responses for transaction-related operations
+		h := handler.NewConsumerHandler(s)
+
+		// Define the routes for transaction management
+		// These routes handle CRUD operations for transactions
+		// The GET methods are accessible to both admin and user roles
+		consumerGroup.GET("", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), h.GetAllConsumers)
+		consumerGroup.GET("/:id", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), h.GetConsumerByID)
+		consumerGroup.GET("/active", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), h.GetActiveConsumers)
+		consumerGroup.GET("/inactive", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), h.GetInactiveConsumers)
+		consumerGroup.GET("/suspended", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), h.GetSuspendedConsumers)
+
+		// The POST and PUT methods are restricted to admin users only
+		consumerGroup.POST("", authorization.RoleBasedAccessControl("ROLE_ADMIN"), h.CreateConsumer)
+		consumerGroup.PATCH("/:id", authorization.RoleBasedAccessControl("ROLE_ADMIN"), h.UpdateConsumerStatus)
+	}
+
+	// Routes for user management
+	// These routes handle the administration of the user accounts
+	userGroup := v1.Group("/users")
+	{
+		// Initialize the user repository, service and handler
+		r := repository.NewUserRepository()
+		s := service.NewUserService(r)
+		h := handler.NewUserHandler(s)
+
+		// The :id of the user is validated once, after the access control, an invalid one never reaches the handlers
+		userID := pathparam.PathInt64("id")
+
+		// The user management routes are restricted to admin users only
+		userGroup.GET("", authorization.RoleBasedAccessControl("ROLE_ADMIN"), h.GetUsers)
+		userGroup.POST("", authorization.RoleBasedAccessControl("ROLE_ADMIN"), h.CreateUser)
+		userGroup.POST("/batch-get", authorization.RoleBasedAccessControl("ROLE_ADMIN"), h.BatchGetUsers)
+		userGroup.POST("/bulk-delete", authorization.RoleBasedAccessControl("ROLE_ADMIN"), h.BulkDeleteUsers)
+		userGroup.GET("/by-metadata", authorization.RoleBasedAccessControl("ROLE_ADMIN"), h.GetUsersByMetadata)
+		userGroup.GET("/deleted", authorization.RoleBasedAccessControl("ROLE_ADMIN"), h.GetDeletedUsers)
+		userGroup.GET("/stream", authorization.RoleBasedAccessControl("ROLE_ADMIN"), h.ExportUsers)
+		userGroup.PATCH("/:id/status", authorization.RoleBasedAccessControl("ROLE_ADMIN"), userID, h.UpdateUserStatus)
+		userGroup.PATCH("/:id/session-limit", authorization.RoleBasedAccessControl("ROLE_ADMIN"), userID, h.UpdateUserSessionLimit)
+		userGroup.PATCH("/:id/metadata", authorization.RoleBasedAccessControl("ROLE_ADMIN"), userID, h.UpdateUserMetadata)
+		userGroup.PUT("/:id/roles", authorization.RoleBasedAccessControl("ROLE_ADMIN"), userID, h.UpdateUserRoles)
+		userGroup.PATCH("/:id/roles", authorization.RoleBasedAccessControl("ROLE_ADMIN"), userID, h.PatchUserRoles)
+		userGroup.PATCH("/:id/password", authorization.RoleBasedAccessControl("ROLE_ADMIN"), userID, h.ResetUserPassword)
+		userGroup.POST("/:id/force-password-change", authorization.RoleBasedAccessControl("ROLE_ADMIN"), userID, h.ForcePasswordChange)
+		userGroup.DELETE("/:id/purge", authorization.RoleBasedAccessControl("ROLE_ADMIN"), userID, h.PurgeUser)
+
+		// The admins holding the users:impersonate permission can act as a user with a short-lived token, e.g. to reproduce an issue
+		ih := handler.NewAuthHandler(service.NewAuthService(clock.New()), nil)
+		userGroup.POST("/:id/impersonate", authorization.RoleBasedAccessControl("ROLE_ADMIN"), authorization.RequirePermission("users:impersonate"), userID, ih.Impersonate)
+
+		// The login history of any user is restricted to admin users, every user can read their own
+		lh := handler.NewLoginAttemptHandler(service.NewLoginAttemptService(repository.NewLoginAttemptRepository()))
+		userGroup.GET("/me/login-history", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), lh.GetMyLoginHistory)
+		userGroup.GET("/:id/login-history", authorization.RoleBasedAccessControl("ROLE_ADMIN"), userID, lh.GetLoginHistory)
+
+		// Every user can deactivate their own account, it is deleted at the end of the grace period
+		ah := handler.NewAccountHandler(service.NewAccountService(repository.NewScheduledDeletionRepository(), clock.New()))
+		userGroup.POST("/me/deactivate", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), ah.DeactivateAccount)
+
+		// The resources of a user are restricted to the user and the admins, the other callers are denied before any lookup,
+		// with a 404 Not Found in strict mode so they cannot tell whether the user exists
+		ownerOrAdmin := authorization.OwnerOrRoles("id", "User", "ROLE_ADMIN", "ROLE_SUPER_ADMIN")
+
+		// Every user can list and revoke their own sessions, the admins those of any user
+		sh := handler.NewSessionHandler(service.NewSessionService(repository.NewRefreshTokenRepository()))
+		userGroup.GET("/:id/sessions", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), userID, ownerOrAdmin, sh.GetUserSessions)
+		userGroup.DELETE("/:id/sessions/:sessionId", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), userID, ownerOrAdmin, sh.RevokeUserSession)
+
+		// Every user can read their own effective permissions, the admins those of any user
+		ph := handler.NewPermissionHandler(service.NewPermissionService(repository.NewPermissionRepository()))
+		userGroup.GET("/:id/permissions", authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER"), userID, ownerOrAdmin, ph.GetUserPermissions)
+	}
+
+	// Routes for the groups of users of the tenant and their members
+	// These routes are restricted to admin users only
+	groupGroup := v1.Group("/groups", authorization.RoleBasedAccessControl("ROLE_ADMIN"))
+	{
+		// Initialize the group repository, service and handler
+		r := repository.NewGroupRepository()
+		s := service.NewGroupService(r)
+		h := handler.NewGroupHandler(s)
+
+		groupID := pathparam.PathInt64("id")
+		memberID := pathparam.PathInt64("userId")
+
+		groupGroup.GET("", h.GetGroups)
+		groupGroup.POST("", h.CreateGroup)
+		groupGroup.GET("/:id", groupID, h.GetGroupByID)
+		groupGroup.PUT("/:id", groupID, h.UpdateGroup)
+		groupGroup.DELETE("/:id", groupID, h.DeleteGroup)
+		groupGroup.GET("/:id/members", groupID, h.GetGroupMembers)
+		groupGroup.POST("/:id/members", groupID, h.AddGroupMember)
+		groupGroup.PATCH("/:id/members/:userId", groupID, memberID, h.UpdateGroupMember)
+		groupGroup.DELETE("/:id/members/:userId", groupID, memberID, h.RemoveGroupMember)
+	}
+
+	// Routes for the audit log of the administrative actions
+	// These routes are restricted to admin users only
+	auditGroup := v1.Group("/audit")
+	{
+		// Initialize the audit log repository, service and handler
+		r := repository.NewAuditLogRepository()
+		s := service.NewAuditLogService(r)
+		h := handler.NewAuditLogHandler(s)
+
+		auditGroup.GET("", authorization.RoleBasedAccessControl("ROLE_ADMIN"), h.GetAuditLogs)
+	}
+
+	// Routes for the aggregate statistics of the admin dashboards
+	// These routes are restricted to admin users only
+	statsGroup := v1.Group("/stats", authorization.RoleBasedAccessControl("ROLE_ADMIN"))
+	{
+		h := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository()))
+		statsGroup.GET("/users-by-role", h.GetUserCountsByRole)
+		statsGroup.GET("/users-summary", h.GetUserSummary)
+	}
+
+	// Routes for the administration of the application
+	// These routes are restricted to admin users only
+	adminGroup := v1.Group("/admin", authorization.RoleBasedAccessControl("ROLE_ADMIN"))
+	{
+		// The checks of the startup preflight run again, to verify a deployment
+		ph := handler.NewPreflightHandler(service.NewPreflightService(clock.New()))
+		adminGroup.GET("/selfcheck", ph.GetSelfCheck)
+
+		// The effective feature flags of the tenant of the request, for debugging
+		fh := handler.NewFeatureFlagHandler()
+		adminGroup.GET("/flags", fh.GetFlags)
+
+		// The likely duplicate accounts, reviewed before merging them
+		uh := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository()))
+		adminGroup.GET("/users/duplicates", uh.GetDuplicateUsers)
+		userID := pathparam.PathInt64("id")
+		adminGroup.POST("/users/:id/merge", userID, uh.MergeUsers)
+
+		// The forced expiration of the credentials of a user, or of every holder of a role, e.g. after a phishing incident
+		adminGroup.POST("/users/:id/expire-credentials", userID, uh.ExpireUserCredentials)
+		adminGroup.POST("/roles/:name/expire-credentials", uh.ExpireRoleCredentials)
+		adminGroup.POST("/roles/reassign", uh.ReassignRole)
+
+		// The internal notes of the support on the accounts, the users never see them
+		nh := handler.NewUserNoteHandler(service.NewUserNoteService(repository.NewUserNoteRepository()))
+		adminGroup.GET("/users/:id/notes", userID, nh.GetUserNotes)
+		adminGroup.POST("/users/:id/notes", userID, nh.CreateUserNote)
+		adminGroup.DELETE("/users/:id/notes/:noteId", userID, pathparam.PathInt64("noteId"), nh.DeleteUserNote)
+
+		// The accounts registered through POST /auth/register waiting for an approval
+		rh := handler.NewRegistrationHandler(service.NewRegistrationService(repository.NewUserRegistrationRepository()))
+		adminGroup.GET("/users/pending", rh.GetPendingRegistrations)
+		adminGroup.POST("/users/:id/approve", userID, rh.ApproveRegistration)
+		adminGroup.POST("/users/:id/reject", userID, rh.RejectRegistration)
+
+		// The email of a registered account the user cannot verify, marked as verified by an admin
+		adminGroup.POST("/users/:id/verification/force", userID, rh.ForceEmailVerification)
+	}
+}
+
+// GetAPIBasePath returns the prefix of the versioned API routes, without a trailing slash.
+// It retrieves the prefix from an environment variable and defaults to /api, `/` serves the versions at the root.
+func GetAPIBasePath() string {
+	basePath := strings.TrimSpace(os.Getenv("API_BASE_PATH"))
+	if basePath == "" {
+		return defaultAPIBasePath
+	}
+	if !strings.HasPrefix(basePath, "/") {
+		basePath = "/" + basePath
+	}
+
+	return strings.TrimRight(basePath, "/")
+}
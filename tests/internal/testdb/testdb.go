@@ -0,0 +1,248 @@
+package testdb
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+)
+
+/**
+* Package testdb opens the SQLite databases the tests run the services against instead of PostgreSQL.
+* The tables are created from the models of the migration, see database.MigratedModels, with the unique
+* and the change indexes of the users, so the tests do not drift from the schema of the application.
+* The tests only insert the rows they need.
+ */
+
+// Options adjusts the database opened by New.
+type Options struct {
+	// Config is the GORM configuration, a silent default when nil
+	Config *gorm.Config
+
+	// Dialector wraps the SQLite dialector when set, e.g. database.WithConstraintErrors
+	Dialector func(gorm.Dialector) gorm.Dialector
+
+	// ForeignKeys enforces the foreign keys of the models, which SQLite ignores by default
+	ForeignKeys bool
+
+	// Immediate starts the transactions with BEGIN IMMEDIATE. SQLite has no row-level lock, the immediate transactions
+	// are serialized like the row locks serialize them in PostgreSQL, but a write from another connection waits for them
+	Immediate bool
+}
+
+// Open opens a database with the tables of the migration, executes the seed statements,
+// and makes the services use it instead of PostgreSQL until the end of the test.
+func Open(tb testing.TB, seeds ...string) *gorm.DB {
+	return Use(tb, New(tb, Options{}, seeds...))
+}
+
+// Use makes the services use the database instead of PostgreSQL until the end of the test.
+func Use(tb testing.TB, db *gorm.DB) *gorm.DB {
+	database.SetPostgres(db)
+	tb.Cleanup(func() {
+		database.SetPostgres(nil)
+	})
+
+	return db
+}
+
+// New opens a database with the tables of the migration and executes the seed statements, without handing it to the services.
+// The database is a file in the temporary directory of the test with a busy timeout, so the concurrent requests of the tests
+// wait for each other. The WAL journal lets a connection write while a transaction of another one is open, like PostgreSQL does.
+func New(tb testing.TB, opts Options, seeds ...string) *gorm.DB {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)", filepath.Join(tb.TempDir(), "test.db"))
+	if opts.Immediate {
+		dsn += "&_txlock=immediate"
+	}
+	if opts.ForeignKeys {
+		dsn += "&_pragma=foreign_keys(1)"
+	}
+
+	var dialector gorm.Dialector = sqlite.Open(dsn)
+	if opts.Dialector != nil {
+		dialector = opts.Dialector(dialector)
+	}
+
+	config := opts.Config
+	if config == nil {
+		config = &gorm.Config{Logger: gormLogger.Default.LogMode(gormLogger.Silent)}
+	}
+
+	db, err := gorm.Open(dialector, config)
+	if err != nil {
+		tb.Fatalf("failed to open SQLite database: %v", err)
+	}
+	tb.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := Migrate(db); err != nil {
+		tb.Fatalf("failed to migrate SQLite database: %v", err)
+	}
+
+	for _, stmt := range seeds {
+		if err := db.Exec(stmt).Error; err != nil {
+			tb.Fatalf("failed to execute statement: %v\n%s", err, stmt)
+		}
+	}
+
+	return db
+}
+
+// Migrate creates the tables of the migration and the indexes of the users.
+func Migrate(db *gorm.DB) error {
+	for _, model := range database.MigratedModels() {
+		if err := createTable(db, model); err != nil {
+			return err
+		}
+	}
+
+	if err := database.MigrateUserUniqueIndexes(db); err != nil {
+		return fmt.Errorf("failed to migrate user unique indexes: %v", err)
+	}
+	if err := database.MigrateUserChangeIndexes(db); err != nil {
+		return fmt.Errorf("failed to migrate user change indexes: %v", err)
+	}
+
+	return nil
+}
+
+// createTable creates the table of the model with its indexes.
+// The models use PostgreSQL column types and defaults, which SQLite does not know, so the columns are declared with the
+// SQLite types of their Go types, and the PostgreSQL functions of the defaults replaced by their SQLite equivalent.
+// The check constraints are left out, so the tests may use other roles than the ones of the seed file.
+// The GIN indexes are left out as well, SQLite has no index type.
+func createTable(db *gorm.DB, model any) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return fmt.Errorf("failed to parse the model %T: %v", model, err)
+	}
+	s := stmt.Schema
+
+	// A single integer primary key is declared inline, so it is the rowid and assigned on insert
+	inlinePrimaryKey := len(s.PrimaryFields) == 1 && columnType(s.PrimaryFields[0]) == "INTEGER"
+
+	var definitions []string
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+
+		definition := field.DBName + " " + columnType(field)
+		if field.PrimaryKey && inlinePrimaryKey {
+			definition += " PRIMARY KEY"
+			if field.AutoIncrement {
+				definition += " AUTOINCREMENT"
+			}
+		}
+		if field.NotNull {
+			definition += " NOT NULL"
+		}
+		if field.Unique {
+			definition += " UNIQUE"
+		}
+		if value := defaultValue(field); value != "" {
+			definition += " DEFAULT " + value
+		}
+		definitions = append(definitions, definition)
+	}
+
+	if len(s.PrimaryFields) > 0 && !inlinePrimaryKey {
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", columnNames(s.PrimaryFields)))
+	}
+
+	for _, rel := range s.Relationships.Relations {
+		constraint := rel.ParseConstraint()
+		if constraint == nil || constraint.Schema != s {
+			continue
+		}
+		definition := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)",
+			columnNames(constraint.ForeignKeys), constraint.ReferenceSchema.Table, columnNames(constraint.References))
+		if constraint.OnDelete != "" {
+			definition += " ON DELETE " + constraint.OnDelete
+		}
+		if constraint.OnUpdate != "" {
+			definition += " ON UPDATE " + constraint.OnUpdate
+		}
+		definitions = append(definitions, definition)
+	}
+
+	if err := db.Exec(fmt.Sprintf("CREATE TABLE %s (\n\t%s\n)", s.Table, strings.Join(definitions, ",\n\t"))).Error; err != nil {
+		return fmt.Errorf("failed to create the table %s: %v", s.Table, err)
+	}
+
+	for _, index := range s.ParseIndexes() {
+		if index.Type != "" {
+			continue
+		}
+
+		var columns []string
+		for _, option := range index.Fields {
+			columns = append(columns, option.DBName)
+		}
+		sql := fmt.Sprintf("CREATE %s INDEX %s ON %s (%s)", index.Class, index.Name, s.Table, strings.Join(columns, ", "))
+		if index.Where != "" {
+			sql += " WHERE " + index.Where
+		}
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("failed to create the index %s: %v", index.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// columnType returns the SQLite type of the field. The times and the booleans must be declared DATETIME and BOOLEAN,
+// the SQLite driver relies on the declared type to scan them.
+func columnType(field *schema.Field) string {
+	switch field.GORMDataType {
+	case schema.Bool:
+		return "BOOLEAN"
+	case schema.Int, schema.Uint:
+		return "INTEGER"
+	case schema.Float:
+		return "REAL"
+	case schema.Time:
+		return "DATETIME"
+	case schema.Bytes:
+		return "BLOB"
+	default:
+		return "TEXT"
+	}
+}
+
+// defaultValue returns the SQLite default of the field, if any.
+func defaultValue(field *schema.Field) string {
+	switch value := strings.TrimSpace(field.DefaultValue); strings.ToLower(value) {
+	case "":
+		return ""
+	case "now()", "current_timestamp":
+		return "(strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))"
+	case "gen_random_uuid()":
+		return "(lower(hex(randomblob(16))))"
+	default:
+		// The quotes of the text defaults may have been trimmed by GORM
+		if columnType(field) == "TEXT" && !strings.HasPrefix(value, "'") {
+			return "'" + value + "'"
+		}
+		return value
+	}
+}
+
+// columnNames returns the comma-separated column names of the fields.
+func columnNames(fields []*schema.Field) string {
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		names = append(names, field.DBName)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,192 @@
+package test_access_policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+)
+
+const (
+	adminID   int64 = 1
+	bobID     int64 = 2
+	carolID   int64 = 3
+	missingID int64 = 99
+)
+
+// callers are the authenticated users of the requests, by their ID.
+var callers = map[int64]metacontext.UserInformationMeta{
+	adminID: {UserID: adminID, Username: "admin", Roles: []string{"ROLE_ADMIN"}, TenantID: metacontext.DefaultTenantID},
+	bobID:   {UserID: bobID, Username: "bob", Roles: []string{"ROLE_USER"}, TenantID: metacontext.DefaultTenantID},
+	carolID: {UserID: carolID, Username: "carol", Roles: []string{"ROLE_USER"}, TenantID: metacontext.DefaultTenantID},
+}
+
+// setupRouter registers the resources of the users like the application does, authenticated as the caller
+// given by the X-Caller header.
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		var callerID int64
+		fmt.Sscan(c.GetHeader("X-Caller"), &callerID)
+		ctx := metacontext.InjectUserInformationMeta(c.Request.Context(), callers[callerID])
+		c.Request = c.Request.WithContext(metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID))
+		c.Next()
+	})
+
+	rbac := authorization.RoleBasedAccessControl("ROLE_ADMIN", "ROLE_USER")
+	userID := pathparam.PathInt64("id")
+	ownerOrAdmin := authorization.OwnerOrRoles("id", "User", "ROLE_ADMIN", "ROLE_SUPER_ADMIN")
+	sh := handler.NewSessionHandler(service.NewSessionService(repository.NewRefreshTokenRepository()))
+	ph := handler.NewPermissionHandler(service.NewPermissionService(repository.NewPermissionRepository()))
+	router.GET("/api/v1/users/:id/sessions", rbac, userID, ownerOrAdmin, sh.GetUserSessions)
+	router.DELETE("/api/v1/users/:id/sessions/:sessionId", rbac, userID, ownerOrAdmin, sh.RevokeUserSession)
+	router.GET("/api/v1/users/:id/permissions", rbac, userID, ownerOrAdmin, ph.GetUserPermissions)
+
+	return router
+}
+
+// response is the part of a response body telling the outcome, without the path and the timestamp.
+type response struct {
+	Message string `json:"message"`
+	Error   any    `json:"error"`
+	Status  int    `json:"status"`
+}
+
+// serve sends the request as the caller and returns the status code and the outcome of the body.
+func serve(t *testing.T, router *gin.Engine, method string, path string, callerID int64) (int, response) {
+	req, _ := http.NewRequest(method, path, nil)
+	req.Header.Set("X-Caller", fmt.Sprint(callerID))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var body response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	return w.Code, body
+}
+
+// endpoints are the resources of a user, by the path of the user with the given ID.
+var endpoints = []struct {
+	name   string
+	method string
+	path   func(userID int64) string
+}{
+	{"list sessions", "GET", func(id int64) string { return fmt.Sprintf("/api/v1/users/%d/sessions", id) }},
+	{"revoke session", "DELETE", func(id int64) string { return fmt.Sprintf("/api/v1/users/%d/sessions/carol-session", id) }},
+	{"read permissions", "GET", func(id int64) string { return fmt.Sprintf("/api/v1/users/%d/permissions", id) }},
+}
+
+func TestAccessPolicy_CallerByExistence(t *testing.T) {
+	tests := []struct {
+		name     string
+		callerID int64
+		userID   int64
+		strict   bool
+		// want is the status of the list of sessions, the revocation and the permissions
+		want [3]int
+	}{
+		// The owners access their own resources, carol's session is only revoked by the last request of the matrix
+		{"owner", carolID, carolID, false, [3]int{http.StatusOK, http.StatusOK, http.StatusOK}},
+		{"owner in strict mode", bobID, bobID, true, [3]int{http.StatusOK, http.StatusNotFound, http.StatusOK}},
+
+		// The other users are denied the resources of an existing user and of a missing one alike
+		{"other user, existing user", bobID, carolID, false, [3]int{http.StatusForbidden, http.StatusForbidden, http.StatusForbidden}},
+		{"other user, missing user", bobID, missingID, false, [3]int{http.StatusForbidden, http.StatusForbidden, http.StatusForbidden}},
+		{"other user, existing user in strict mode", bobID, carolID, true, [3]int{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound}},
+		{"other user, missing user in strict mode", bobID, missingID, true, [3]int{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound}},
+
+		// The admins tell an existing user from a missing one, in strict mode as well
+		{"admin, missing user", adminID, missingID, false, [3]int{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound}},
+		{"admin, missing user in strict mode", adminID, missingID, true, [3]int{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound}},
+		{"admin, existing user in strict mode", adminID, carolID, true, [3]int{http.StatusOK, http.StatusOK, http.StatusOK}},
+	}
+
+	setupDatabase(t)
+	router := setupRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.strict {
+				t.Setenv("AUTHORIZATION_STRICT_MODE", "TRUE")
+			}
+
+			for i, endpoint := range endpoints {
+				// Revoking carol's only session twice would tell the second request apart, the owner revokes it last
+				if endpoint.method == "DELETE" && tt.want[i] == http.StatusOK && tt.callerID == carolID && tt.userID == carolID {
+					continue
+				}
+
+				status, body := serve(t, router, endpoint.method, endpoint.path(tt.userID), tt.callerID)
+				assert.Equal(t, tt.want[i], status, "%s: %+v", endpoint.name, body)
+
+				// The status of the body always matches the status of the response
+				assert.Equal(t, status, body.Status, endpoint.name)
+			}
+		})
+	}
+
+	status, _ := serve(t, router, "DELETE", endpoints[1].path(carolID), carolID)
+	assert.Equal(t, http.StatusNotFound, status, "the session was revoked by the admin")
+}
+
+func TestAccessPolicy_StrictModeHidesExistence(t *testing.T) {
+	setupDatabase(t)
+	router := setupRouter()
+	t.Setenv("AUTHORIZATION_STRICT_MODE", "TRUE")
+
+	// A caller probing the IDs of other users receives the same answer whether the user exists or not,
+	// the same answer the admins receive for a missing user
+	for _, endpoint := range endpoints[:1] {
+		_, existing := serve(t, router, endpoint.method, endpoint.path(carolID), bobID)
+		_, missing := serve(t, router, endpoint.method, endpoint.path(missingID), bobID)
+		_, admin := serve(t, router, endpoint.method, endpoint.path(missingID), adminID)
+		assert.Equal(t, missing, existing, endpoint.name)
+		assert.Equal(t, admin, existing, endpoint.name)
+		assert.Equal(t, "User not found", existing.Message)
+	}
+	for _, endpoint := range endpoints {
+		_, existing := serve(t, router, endpoint.method, endpoint.path(carolID), bobID)
+		_, missing := serve(t, router, endpoint.method, endpoint.path(missingID), bobID)
+		assert.Equal(t, missing, existing, endpoint.name)
+	}
+
+	// Without strict mode, the callers are told they are forbidden
+	t.Setenv("AUTHORIZATION_STRICT_MODE", "FALSE")
+	status, body := serve(t, router, "GET", endpoints[2].path(carolID), bobID)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "Forbidden", body.Message)
+}
+
+func TestAccessPolicy_HandlerWithoutMiddleware(t *testing.T) {
+	setupDatabase(t)
+	t.Setenv("AUTHORIZATION_STRICT_MODE", "TRUE")
+
+	// A route registered without the middleware is still answered by the policy, the services deny the caller
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		ctx := metacontext.InjectUserInformationMeta(c.Request.Context(), callers[bobID])
+		c.Request = c.Request.WithContext(metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID))
+		c.Next()
+	})
+	sh := handler.NewSessionHandler(service.NewSessionService(repository.NewRefreshTokenRepository()))
+	router.GET("/api/v1/users/:id/sessions", pathparam.PathInt64("id"), sh.GetUserSessions)
+
+	for _, userID := range []int64{carolID, missingID} {
+		status, body := serve(t, router, "GET", fmt.Sprintf("/api/v1/users/%d/sessions", userID), bobID)
+		assert.Equal(t, http.StatusNotFound, status)
+		assert.Equal(t, http.StatusNotFound, body.Status)
+		assert.Equal(t, "User not found", body.Message)
+	}
+}
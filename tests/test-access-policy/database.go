@@ -0,0 +1,27 @@
+package test_access_policy
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with the tables of the migration and makes the services use it
+// instead of PostgreSQL. It holds the admin (ID 1), bob (ID 2) and carol (ID 3) with the ROLE_USER role, carol having a session.
+func setupDatabase(t *testing.T) *gorm.DB {
+	return testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_ADMIN', false)`,
+		`INSERT INTO permissions (name) VALUES ('consumers:read'), ('users:write')`,
+		`INSERT INTO role_permissions (role_id, permission_id) VALUES (1, 1), (2, 1), (2, 2)`,
+		`INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired,
+			is_account_non_locked, is_credentials_non_expired, user_type) VALUES
+			('admin', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'admin@mygmail.com', 'Admin', true, true, true, true, 'USER_ACCOUNT'),
+			('bob', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'bob@mygmail.com', 'Bob', true, true, true, true, 'USER_ACCOUNT'),
+			('carol', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'carol@mygmail.com', 'Carol', true, true, true, true, 'USER_ACCOUNT')`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES (1, 2), (2, 1), (3, 1)`,
+		`INSERT INTO refresh_token (token, session_id, user_id, expiry_date, created_at) VALUES
+			('carol-token', 'carol-session', 3, '2999-01-01 00:00:00+00:00', '2025-01-01 00:00:00+00:00')`,
+	)
+}
@@ -0,0 +1,274 @@
+package test_account_deletion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+// setupRouter registers the login, the reactivation and the deactivation of the account of alice, at the time of the clock.
+func setupRouter(t *testing.T, clk clock.Clock) *gin.Engine {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_EXPIRATION_HOUR", "1")
+	t.Setenv("JWT_REFRESH_TOKEN_EXPIRATION_HOUR", "24")
+	t.Setenv("MAX_SESSIONS_PER_USER", "0")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	auth := handler.NewAuthHandler(service.NewAuthService(clk), nil)
+	router.POST("/auth/login", auth.Login)
+	router.POST("/auth/reactivate", auth.ReactivateAccount)
+
+	account := handler.NewAccountHandler(service.NewAccountService(repository.NewScheduledDeletionRepository(), clk))
+	router.POST("/api/v1/users/me/deactivate", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(aliceContext())
+		account.DeactivateAccount(c)
+	})
+
+	return router
+}
+
+// aliceContext returns a context authenticated as alice.
+func aliceContext() context.Context {
+	return metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: 2, Username: "alice", Roles: []string{"ROLE_USER"},
+	})
+}
+
+// post posts the credentials of alice, or no body, to the given path.
+func post(router *gin.Engine, path string, password string) *httptest.ResponseRecorder {
+	var body []byte
+	if password != "" {
+		body, _ = json.Marshal(map[string]string{"username": "alice", "password": password})
+	}
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// outboxEvents returns the types of the events recorded in the outbox, the oldest first.
+func outboxEvents(t *testing.T, db *gorm.DB) []entity.OutboxEvent {
+	events, err := repository.NewOutboxEventRepository().GetPendingOutboxEvents(db, 100)
+	require.NoError(t, err)
+	return events
+}
+
+// auditActions returns the actions recorded in the audit log for alice, the oldest first.
+func auditActions(t *testing.T, db *gorm.DB) []string {
+	var actions []string
+	err := db.Model(&entity.AuditLog{}).Where("entity_type = ? AND entity_id = ?", "user", "2").Order("id ASC").Pluck("action", &actions).Error
+	require.NoError(t, err)
+	return actions
+}
+
+func TestDeactivateAccount_ReactivatedDuringGracePeriod(t *testing.T) {
+	t.Setenv("ACCOUNT_DELETION_GRACE_DAYS", "14")
+	db := setupDatabase(t)
+	router := setupRouter(t, clock.New())
+
+	// Alice has an active session before deactivating her account
+	require.Equal(t, http.StatusOK, post(router, "/auth/login", testPassword).Code)
+
+	w := post(router, "/api/v1/users/me/deactivate", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Data entity.ScheduledDeletion `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.WithinDuration(t, time.Now().Add(14*24*time.Hour), resp.Data.DeleteAt, time.Minute)
+
+	// The account is disabled and its sessions are revoked
+	user, err := repository.NewUserRepository().GetUserByID(db, 2)
+	require.NoError(t, err)
+	assert.False(t, *user.IsEnabled)
+	var sessions int64
+	require.NoError(t, db.Model(&entity.RefreshToken{}).Where("user_id = ?", 2).Count(&sessions).Error)
+	assert.Zero(t, sessions)
+
+	// The deactivation email is queued in the outbox
+	events := outboxEvents(t, db)
+	require.Len(t, events, 1)
+	assert.Equal(t, entity.OutboxEventAccountDeactivated, events[0].Type)
+	assert.Contains(t, events[0].Payload, "alice@mygmail.com")
+
+	// A second deactivation is refused
+	w = post(router, "/api/v1/users/me/deactivate", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// The login offers the reactivation, only to the caller knowing the password
+	w = post(router, "/auth/login", testPassword)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "/auth/reactivate")
+
+	w = post(router, "/auth/login", "WrongP@ssw0rd")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotContains(t, w.Body.String(), "reactivate")
+
+	w = post(router, "/auth/reactivate", "WrongP@ssw0rd")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// The reactivation cancels the deletion and logs alice in
+	w = post(router, "/auth/reactivate", testPassword)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "accessToken")
+
+	user, err = repository.NewUserRepository().GetUserByID(db, 2)
+	require.NoError(t, err)
+	assert.True(t, *user.IsEnabled)
+	_, err = repository.NewScheduledDeletionRepository().GetScheduledDeletionByUserID(db, 2)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// An account that is not deactivated cannot be reactivated
+	w = post(router, "/auth/reactivate", testPassword)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	assert.Equal(t, []string{"deactivate", "reactivate"}, auditActions(t, db))
+}
+
+func TestProcessScheduledDeletions_RemindsThenAnonymizes(t *testing.T) {
+	db := setupDatabase(t)
+	clk := clock.NewFake(time.Now().UTC())
+	router := setupRouter(t, clk)
+	s := service.NewAccountService(repository.NewScheduledDeletionRepository(), clk)
+	janitor := metacontext.WithSystemActor(context.Background(), "account-janitor")
+
+	deletion, err := s.DeactivateAccount(aliceContext())
+	require.NoError(t, err)
+	assert.WithinDuration(t, clk.Now().Add(30*24*time.Hour), deletion.DeleteAt, time.Millisecond)
+
+	// Nothing is due until 7 days before the deletion
+	run, err := s.ProcessScheduledDeletions(janitor, deletion.DeleteAt.Add(-8*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, entity.ScheduledDeletionRun{}, run)
+
+	// The reminder is sent once
+	run, err = s.ProcessScheduledDeletions(janitor, deletion.DeleteAt.Add(-6*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, entity.ScheduledDeletionRun{Reminded: 1}, run)
+	run, err = s.ProcessScheduledDeletions(janitor, deletion.DeleteAt.Add(-5*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, entity.ScheduledDeletionRun{}, run)
+
+	// The account can no longer be reactivated once the grace period is over, even before the janitor runs
+	clk.Advance(30 * 24 * time.Hour)
+	w := post(router, "/auth/reactivate", testPassword)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	run, err = s.ProcessScheduledDeletions(janitor, clk.Now().UTC())
+	require.NoError(t, err)
+	assert.Equal(t, entity.ScheduledDeletionRun{Deleted: 1}, run)
+
+	// The personal data of alice is gone, the row is kept soft-deleted
+	user, err := repository.NewUserRepository().GetUserByID(db, 2, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, "deleted-2", user.Username)
+	assert.Equal(t, "deleted-2@deleted.invalid", user.Email)
+	assert.Equal(t, "Deleted", user.Firstname)
+	assert.True(t, user.DeletedAt.Valid)
+	for _, table := range []any{&entity.PasswordHistory{}, &entity.LoginAttempt{}, &entity.ScheduledDeletion{}} {
+		var count int64
+		require.NoError(t, db.Model(table).Where("user_id = ?", 2).Count(&count).Error)
+		assert.Zero(t, count)
+	}
+
+	// The deletion email goes to the address alice had
+	events := outboxEvents(t, db)
+	require.Len(t, events, 3)
+	assert.Equal(t, entity.OutboxEventAccountDeletionReminder, events[1].Type)
+	assert.Equal(t, entity.OutboxEventAccountDeleted, events[2].Type)
+	assert.Contains(t, events[2].Payload, "alice@mygmail.com")
+
+	assert.Equal(t, []string{"deactivate", "deletion_reminder", "anonymize"}, auditActions(t, db))
+	var actor string
+	require.NoError(t, db.Model(&entity.AuditLog{}).Where("action = ?", "anonymize").Pluck("actor", &actor).Error)
+	assert.Equal(t, "system:account-janitor", actor)
+
+	// Alice can no longer log in
+	w = post(router, "/auth/login", testPassword)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestDeactivateAccount_LastAdmin(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewAccountService(repository.NewScheduledDeletionRepository(), clock.New())
+
+	admin := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: 1, Username: "admin", Roles: []string{"ROLE_ADMIN"},
+	})
+	_, err := s.DeactivateAccount(admin)
+	assert.ErrorIs(t, err, service.ErrLastAdmin)
+
+	// Nothing is scheduled nor sent
+	assert.Empty(t, outboxEvents(t, db))
+	_, err = repository.NewScheduledDeletionRepository().GetScheduledDeletionByUserID(db, 1)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestReactivateAccount_GracePeriodBoundary(t *testing.T) {
+	db := setupDatabase(t)
+	clk := clock.NewFake(time.Now().UTC())
+	router := setupRouter(t, clk)
+
+	// The account is reactivated up to the last second of its grace period
+	require.Equal(t, http.StatusOK, post(router, "/api/v1/users/me/deactivate", "").Code)
+	clk.Advance(30*24*time.Hour - time.Second)
+	w := post(router, "/auth/reactivate", testPassword)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Then no longer, the account is left to the janitor
+	require.Equal(t, http.StatusOK, post(router, "/api/v1/users/me/deactivate", "").Code)
+	clk.Advance(30 * 24 * time.Hour)
+	w = post(router, "/auth/reactivate", testPassword)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "can no longer be reactivated")
+
+	assert.Equal(t, []string{"deactivate", "reactivate", "deactivate"}, auditActions(t, db))
+}
+
+func TestAccountJanitor_RunsAtEveryInterval(t *testing.T) {
+	db := setupDatabase(t)
+	clk := clock.NewFake(time.Now().UTC())
+	deletion, err := service.NewAccountService(repository.NewScheduledDeletionRepository(), clk).DeactivateAccount(aliceContext())
+	require.NoError(t, err)
+
+	service.StartAccountJanitor(clk)
+	t.Cleanup(service.StopAccountJanitor)
+
+	// The janitor runs at start, nothing is due yet, then waits for the next interval
+	clk.BlockUntil(1)
+	assert.Equal(t, []string{"deactivate"}, auditActions(t, db))
+
+	// It reminds alice at its first run within the reminder lead
+	clk.Advance(deletion.DeleteAt.Sub(clk.Now()) - 7*24*time.Hour + time.Hour)
+	assert.Eventually(t, func() bool { return len(auditActions(t, db)) == 2 }, 5*time.Second, 10*time.Millisecond)
+	clk.BlockUntil(1)
+
+	// And anonymizes the account at its first run past the grace period
+	clk.Advance(7 * 24 * time.Hour)
+	assert.Eventually(t, func() bool { return len(auditActions(t, db)) == 3 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"deactivate", "deletion_reminder", "anonymize"}, auditActions(t, db))
+
+	user, err := repository.NewUserRepository().GetUserByID(db, 2, repository.WithDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, "deleted-2", user.Username)
+}
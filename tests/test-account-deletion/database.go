@@ -0,0 +1,43 @@
+package test_account_deletion
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// testPassword is the password of the users of the test database.
+const testPassword = "P@ssw0rd123"
+
+// setupDatabase opens an SQLite database with the tables of the migration,
+// an enabled admin (ID 1) with the ROLE_ADMIN and ROLE_USER roles and an enabled user alice (ID 2) with the ROLE_USER role,
+// and makes the services use it instead of PostgreSQL.
+func setupDatabase(t *testing.T) *gorm.DB {
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash the password: %v", err)
+	}
+
+	db := testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_ADMIN', false)`,
+		fmt.Sprintf(`INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired,
+			is_account_non_locked, is_credentials_non_expired, user_type) VALUES
+			('admin', '%[1]s', 'admin@mygmail.com', 'Admin', true, true, true, true, 'USER_ACCOUNT'),
+			('alice', '%[1]s', 'alice@mygmail.com', 'Alice', true, true, true, true, 'USER_ACCOUNT')`, hash),
+		`INSERT INTO user_roles (user_id, role_id) VALUES (1, 1), (1, 2), (2, 1)`,
+		fmt.Sprintf(`INSERT INTO password_history (user_id, password_hash, created_at) VALUES (2, '%s', CURRENT_TIMESTAMP)`, hash),
+		`INSERT INTO login_attempts (user_id, username, success, attempted_at) VALUES (2, 'alice', true, CURRENT_TIMESTAMP)`,
+	)
+
+	// Record the actor of the writes like the PostgreSQL connection does
+	if err := database.RegisterAuditCallbacks(db); err != nil {
+		t.Fatalf("failed to register the audit callbacks: %v", err)
+	}
+
+	return db
+}
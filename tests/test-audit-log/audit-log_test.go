@@ -0,0 +1,154 @@
+package test_audit_log
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+// base is the time of the first seeded entry, the others follow one day apart
+var base = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// seedAuditLogs creates 12 entries over 12 days: the admin updates users on the even days and deletes them on the odd days,
+// the auditor creates a consumer on each day. The last entry belongs to another tenant.
+func seedAuditLogs(t *testing.T, db *gorm.DB) {
+	repo := repository.NewAuditLogRepository()
+	adminID, auditorID := int64(1), int64(3)
+
+	for day := 0; day < 12; day++ {
+		entry := entity.AuditLog{TenantID: 1, ActorID: &adminID, Actor: "admin", Action: "update", EntityType: "user", EntityID: "2"}
+		if day%2 == 1 {
+			entry.Action = "delete"
+		}
+		if day%3 == 2 {
+			entry = entity.AuditLog{TenantID: 1, ActorID: &auditorID, Actor: "auditor", Action: "create", EntityType: "consumer", EntityID: "7"}
+		}
+		if day == 11 {
+			entry.TenantID = 2
+		}
+		entry.CreatedAt = base.AddDate(0, 0, day)
+
+		_, err := repo.CreateAuditLog(db, entry)
+		require.NoError(t, err)
+	}
+}
+
+func TestAuditLog_Filters(t *testing.T) {
+	db := setupDatabase(t)
+	seedAuditLogs(t, db)
+	s := service.NewAuditLogService(repository.NewAuditLogRepository())
+	ctx := metacontext.InjectTenantID(context.Background(), 1)
+
+	at := func(day int) *time.Time {
+		t := base.AddDate(0, 0, day)
+		return &t
+	}
+
+	tests := []struct {
+		name   string
+		filter entity.AuditLogFilter
+		total  int64
+	}{
+		{"no filter", entity.AuditLogFilter{}, 11},
+		{"by actor", entity.AuditLogFilter{Actor: "admin"}, 8},
+		{"by other actor", entity.AuditLogFilter{Actor: "auditor"}, 3},
+		{"by entity", entity.AuditLogFilter{EntityType: "user", EntityID: "2"}, 8},
+		{"by action", entity.AuditLogFilter{Action: "delete"}, 4},
+		{"from", entity.AuditLogFilter{From: at(6)}, 5},
+		{"to", entity.AuditLogFilter{To: at(3)}, 4},
+		{"date range", entity.AuditLogFilter{From: at(2), To: at(5)}, 4},
+		{"actor and date range", entity.AuditLogFilter{Actor: "admin", From: at(2), To: at(5)}, 2},
+		{"actor and action", entity.AuditLogFilter{Actor: "admin", Action: "update"}, 4},
+		{"unknown actor", entity.AuditLogFilter{Actor: "nobody"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Page, tt.filter.Limit = 1, 2
+			entries, total, err := s.GetAuditLogs(ctx, tt.filter)
+			require.NoError(t, err)
+
+			// The total counts every matching entry, not only the page
+			assert.Equal(t, tt.total, total)
+			assert.Len(t, entries, int(min(tt.total, 2)))
+			for _, entry := range entries {
+				assert.Equal(t, int64(1), entry.TenantID)
+			}
+		})
+	}
+
+	// The entries are ordered from the most recent, page by page
+	entries, total, err := s.GetAuditLogs(ctx, entity.AuditLogFilter{Actor: "admin", Page: 2, Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), total)
+	require.Len(t, entries, 3)
+	assert.True(t, entries[0].CreatedAt.Equal(base.AddDate(0, 0, 6)))
+	assert.True(t, entries[2].CreatedAt.Equal(base.AddDate(0, 0, 3)))
+}
+
+func TestAuditLog_Handler(t *testing.T) {
+	db := setupDatabase(t)
+	seedAuditLogs(t, db)
+	h := handler.NewAuditLogHandler(service.NewAuditLogService(repository.NewAuditLogRepository()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(metacontext.InjectTenantID(c.Request.Context(), 1))
+		c.Next()
+	})
+	router.GET("/api/v1/audit", h.GetAuditLogs)
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		total  int64
+	}{
+		{"all entries", "/api/v1/audit", http.StatusOK, 11},
+		{"by actor", "/api/v1/audit?actor=auditor", http.StatusOK, 3},
+		{"by date range", "/api/v1/audit?from=2025-01-03T00:00:00Z&to=2025-01-06T00:00:00Z", http.StatusOK, 3},
+		{"by entity", "/api/v1/audit?entityType=consumer&entityId=7&action=create", http.StatusOK, 3},
+		{"invalid page", "/api/v1/audit?page=0", http.StatusBadRequest, 0},
+		{"invalid date", "/api/v1/audit?from=yesterday", http.StatusBadRequest, 0},
+		{"inverted range", "/api/v1/audit?from=2025-02-01T00:00:00Z&to=2025-01-01T00:00:00Z", http.StatusBadRequest, 0},
+		{"entity ID without type", "/api/v1/audit?entityId=7", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data       []entity.AuditLog `json:"data"`
+				Pagination struct {
+					Total int64 `json:"total"`
+				} `json:"pagination"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.total, response.Pagination.Total)
+			assert.Len(t, response.Data, int(min(tt.total, 10)))
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package test_audit_log
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with the tables of the migration, and makes the services use it instead of PostgreSQL.
+func setupDatabase(t *testing.T) *gorm.DB {
+	return testdb.Open(t)
+}
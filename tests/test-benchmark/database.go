@@ -0,0 +1,22 @@
+package test_benchmark
+
+import (
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database holding the given number of users, and makes the services use it instead of PostgreSQL.
+// Every user has the ROLE_USER role, so the reads preload the roles as they do in production.
+func setupDatabase(tb testing.TB, users int) *gorm.DB {
+	return testdb.Open(tb,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_MODERATOR', false), ('ROLE_ADMIN', false)`,
+		fmt.Sprintf(`WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < %d)
+			INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired, is_account_non_locked, is_credentials_non_expired, user_type)
+			SELECT 'user' || n, 'hash', 'user' || n || '@mygmail.com', 'User', true, true, true, true, 'USER_ACCOUNT' FROM seq`, users),
+		`INSERT INTO user_roles (user_id, role_id) SELECT id, 1 FROM users`,
+	)
+}
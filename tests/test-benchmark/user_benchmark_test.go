@@ -0,0 +1,69 @@
+package test_benchmark
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+)
+
+const (
+	// benchmarkUsers is the number of users in the benchmark database
+	benchmarkUsers = 1000
+
+	// benchmarkPageSize is the number of users read together, as a page of a list endpoint would
+	benchmarkPageSize = 10
+)
+
+// The benchmarks measure the hot read path of the users through the service, so a cache in front of the
+// repository can be compared against these numbers, e.g.
+//
+//	go test ./tests/test-benchmark/ -run '^$' -bench . -benchmem -count 5 > before.txt
+//	benchstat before.txt after.txt
+
+func BenchmarkGetUserByID(b *testing.B) {
+	setupDatabase(b, benchmarkUsers)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.GetUserByID(int64(i%benchmarkUsers) + 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetUserByID_Parallel(b *testing.B) {
+	setupDatabase(b, benchmarkUsers)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := s.GetUserByID(int64(i%benchmarkUsers) + 1); err != nil {
+				b.Error(err)
+				return
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkGetUsersByIDs_Page(b *testing.B) {
+	setupDatabase(b, benchmarkUsers)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	ids := make([]int64, benchmarkPageSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		first := int64(i*benchmarkPageSize%benchmarkUsers) + 1
+		for j := range ids {
+			ids[j] = first + int64(j)
+		}
+		if _, _, err := s.GetUsersByIDs(context.Background(), ids, true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
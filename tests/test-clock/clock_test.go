@@ -0,0 +1,101 @@
+package test_clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+)
+
+// received returns the time sent on the channel, and false if none was sent.
+func received(ch <-chan time.Time) (time.Time, bool) {
+	select {
+	case now := <-ch:
+		return now, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	clk := clock.New()
+	assert.WithinDuration(t, time.Now(), clk.Now(), time.Second)
+
+	select {
+	case <-clk.After(time.Millisecond):
+	case <-time.After(5 * time.Second):
+		t.Fatal("the real clock did not fire")
+	}
+}
+
+func TestFakeClock_OnlyMovesWhenAdvanced(t *testing.T) {
+	start := time.Date(2031, time.January, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	assert.Equal(t, start, clk.Now())
+
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, start, clk.Now())
+
+	clk.Advance(90 * time.Minute)
+	assert.Equal(t, start.Add(90*time.Minute), clk.Now())
+}
+
+func TestFakeClock_AfterFiresAtItsDeadline(t *testing.T) {
+	start := time.Date(2031, time.January, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+
+	hour := clk.After(time.Hour)
+	day := clk.After(24 * time.Hour)
+
+	// Not before the deadline
+	clk.Advance(time.Hour - time.Second)
+	_, ok := received(hour)
+	assert.False(t, ok)
+
+	// At the deadline, with the time of the clock
+	clk.Advance(time.Second)
+	now, ok := received(hour)
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(time.Hour), now)
+	_, ok = received(day)
+	assert.False(t, ok)
+
+	// Past the deadline, once
+	clk.Advance(48 * time.Hour)
+	now, ok = received(day)
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(49*time.Hour), now)
+	clk.Advance(48 * time.Hour)
+	_, ok = received(day)
+	assert.False(t, ok)
+
+	// At once without a duration
+	now, ok = received(clk.After(0))
+	assert.True(t, ok)
+	assert.Equal(t, clk.Now(), now)
+}
+
+func TestFakeClock_BlockUntil(t *testing.T) {
+	clk := clock.NewFake(time.Date(2031, time.January, 1, 9, 0, 0, 0, time.UTC))
+
+	// A background job waits for its next run, the clock is advanced once it waits
+	runs := make(chan time.Time)
+	go func() {
+		for range 2 {
+			runs <- <-clk.After(time.Hour)
+		}
+	}()
+
+	for i := 1; i <= 2; i++ {
+		clk.BlockUntil(1)
+		clk.Advance(time.Hour)
+		select {
+		case now := <-runs:
+			assert.Equal(t, time.Date(2031, time.January, 1, 9+i, 0, 0, 0, time.UTC), now)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("run %d did not happen", i)
+		}
+	}
+}
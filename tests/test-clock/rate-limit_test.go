@@ -0,0 +1,57 @@
+package test_clock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/ratelimit"
+)
+
+// send sends a request from the client address and returns the response.
+func send(router *gin.Engine, address string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/auth/register", nil)
+	req.RemoteAddr = address + ":1234"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit_WindowOfTheClock(t *testing.T) {
+	clk := clock.NewFake(time.Date(2031, time.January, 1, 9, 0, 0, 0, time.UTC))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/register", ratelimit.RateLimit(ratelimit.RateLimitConfig{Limit: 2, Window: time.Hour, Clock: clk}), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	assert.Equal(t, http.StatusCreated, send(router, "10.0.0.1").Code)
+	clk.Advance(10 * time.Minute)
+	assert.Equal(t, http.StatusCreated, send(router, "10.0.0.1").Code)
+
+	// The limit is reached until the end of the window started by the first request
+	clk.Advance(20 * time.Minute)
+	w := send(router, "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1800", w.Header().Get("Retry-After"))
+
+	// Another address has a window of its own
+	assert.Equal(t, http.StatusCreated, send(router, "10.0.0.2").Code)
+
+	clk.Advance(30*time.Minute - time.Second)
+	w = send(router, "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// A new window starts once it is over
+	clk.Advance(time.Second)
+	assert.Equal(t, http.StatusCreated, send(router, "10.0.0.1").Code)
+	assert.Equal(t, http.StatusCreated, send(router, "10.0.0.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(router, "10.0.0.1").Code)
+}
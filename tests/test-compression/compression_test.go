@@ -0,0 +1,196 @@
+package test_compression
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/compression"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// setupRouter registers a large and a small JSON route, and a streamed CSV export, behind the compression middleware.
+// The export writes a line, flushes it, and waits for the next signal before writing the following one.
+func setupRouter(cfg compression.CompressionConfig, next <-chan struct{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(compression.CompressionWithConfig(cfg))
+
+	router.GET("/api/v1/large", func(c *gin.Context) {
+		items := make([]map[string]any, 200)
+		for i := range items {
+			items[i] = map[string]any{"id": i, "username": fmt.Sprintf("user%d", i), "email": fmt.Sprintf("user%d@mygmail.com", i)}
+		}
+		httputil.Success(c, "Users retrieved successfully", items)
+	})
+	router.GET("/api/v1/small", func(c *gin.Context) {
+		httputil.Success(c, "All good", nil)
+	})
+	router.GET("/api/v1/export", func(c *gin.Context) {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(c.Writer, "%d,user%d\n", i, i)
+			c.Writer.Flush()
+			<-next
+		}
+	})
+	router.GET("/api/v1/empty", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	return router
+}
+
+func get(router http.Handler, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompression_LargeResponse(t *testing.T) {
+	router := setupRouter(compression.CompressionConfig{Level: gzip.DefaultCompression, MinSize: 1024}, nil)
+
+	plain := get(router, "/api/v1/large", "")
+	require.Equal(t, http.StatusOK, plain.Code)
+	assert.Empty(t, plain.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", plain.Header().Get("Vary"))
+
+	w := get(router, "/api/v1/large", "gzip, deflate, br")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Less(t, w.Body.Len(), plain.Body.Len())
+
+	gz, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+
+	var resp httputil.HttpResponse
+	require.NoError(t, json.Unmarshal(body, &resp))
+	assert.Equal(t, "Users retrieved successfully", resp.Message)
+	assert.Len(t, resp.Data, 200)
+
+	// Deflate is used when gzip is not accepted
+	w = get(router, "/api/v1/large", "gzip;q=0, deflate")
+	require.Equal(t, "deflate", w.Header().Get("Content-Encoding"))
+	body, err = io.ReadAll(flate.NewReader(w.Body))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, &resp))
+	assert.Len(t, resp.Data, 200)
+}
+
+func TestCompression_BelowThreshold(t *testing.T) {
+	router := setupRouter(compression.CompressionConfig{Level: gzip.DefaultCompression, MinSize: 1024}, nil)
+
+	w := get(router, "/api/v1/small", "gzip")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Less(t, w.Body.Len(), 1024)
+
+	var resp httputil.HttpResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "All good", resp.Message)
+
+	// A response without a body is left alone
+	w = get(router, "/api/v1/empty", "gzip")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Zero(t, w.Body.Len())
+
+	// A level of 0 disables the compression of the large responses as well
+	router = setupRouter(compression.CompressionConfig{Level: gzip.NoCompression, MinSize: 1024}, nil)
+	w = get(router, "/api/v1/large", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+}
+
+func TestCompression_StreamedExport(t *testing.T) {
+	next := make(chan struct{})
+	router := setupRouter(compression.CompressionConfig{Level: gzip.DefaultCompression, MinSize: 1024}, next)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// Ask for gzip explicitly, so the client leaves the body compressed
+	req, _ := http.NewRequest("GET", server.URL+"/api/v1/export", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	// Every line reaches the client while the handler is still writing, despite the minimum size
+	gz, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	lines := bufio.NewReader(gz)
+	for i := 1; i <= 3; i++ {
+		line, err := lines.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%d,user%d\n", i, i), line)
+		next <- struct{}{}
+	}
+
+	rest, err := io.ReadAll(lines)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"br", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"GZIP", "gzip"},
+		{"gzip;q=0", ""},
+		{"*", "gzip"},
+		{"*, gzip;q=0", "deflate"},
+		{"*;q=0", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.ReplaceAll(tt.acceptEncoding, "*", "any"), func(t *testing.T) {
+			assert.Equal(t, tt.encoding, compression.NegotiateEncoding(tt.acceptEncoding))
+		})
+	}
+}
+
+func TestLoadCompressionConfig(t *testing.T) {
+	cfg := compression.LoadCompressionConfig()
+	assert.Equal(t, compression.CompressionConfig{Level: gzip.DefaultCompression, MinSize: 1024}, cfg)
+
+	t.Setenv("COMPRESSION_LEVEL", "9")
+	t.Setenv("COMPRESSION_MIN_SIZE", "256")
+	cfg = compression.LoadCompressionConfig()
+	assert.Equal(t, compression.CompressionConfig{Level: gzip.BestCompression, MinSize: 256}, cfg)
+
+	// The invalid values keep the defaults
+	t.Setenv("COMPRESSION_LEVEL", "12")
+	t.Setenv("COMPRESSION_MIN_SIZE", "-1")
+	cfg = compression.LoadCompressionConfig()
+	assert.Equal(t, compression.CompressionConfig{Level: gzip.DefaultCompression, MinSize: 1024}, cfg)
+}
@@ -0,0 +1,187 @@
+package test_concurrency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/concurrency"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// blockingRouter registers a route behind the concurrency middleware whose requests signal when they start
+// and block until they are released, and a health route.
+func blockingRouter(cfg concurrency.ConcurrencyConfig, started chan<- struct{}, release <-chan struct{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(concurrency.ConcurrencyLimitWithConfig(cfg))
+	router.GET("/api/v1/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		httputil.Success(c, "Done", nil)
+	})
+	router.GET("/health/live", func(c *gin.Context) {
+		httputil.Success(c, "Alive", nil)
+	})
+	return router
+}
+
+// serve sends a request to the router in the background, its response is received on the returned channel.
+func serve(router *gin.Engine, path string) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		done <- w
+	}()
+	return done
+}
+
+// waitStarted waits for a request to reach its handler.
+func waitStarted(t *testing.T, started <-chan struct{}) {
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("the request did not reach its handler")
+	}
+}
+
+// waitResponse waits for the response of a request.
+func waitResponse(t *testing.T, done <-chan *httptest.ResponseRecorder) *httptest.ResponseRecorder {
+	select {
+	case w := <-done:
+		return w
+	case <-time.After(2 * time.Second):
+		t.Fatal("the request was not answered")
+		return nil
+	}
+}
+
+// assertShed asserts the request was answered with a 503 Service Unavailable and a Retry-After header.
+func assertShed(t *testing.T, w *httptest.ResponseRecorder) {
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+}
+
+func TestConcurrencyLimit_ShedsBeyondLimit(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	router := blockingRouter(concurrency.ConcurrencyConfig{MaxInFlight: 2, ExemptPrefixes: []string{"/health/"}}, started, release)
+
+	first := serve(router, "/api/v1/slow")
+	second := serve(router, "/api/v1/slow")
+	waitStarted(t, started)
+	waitStarted(t, started)
+
+	// The third request is shed at once, without reaching its handler
+	shed := waitResponse(t, serve(router, "/api/v1/slow"))
+	assertShed(t, shed)
+	assert.Empty(t, started)
+
+	// The health probes are never limited
+	health := waitResponse(t, serve(router, "/health/live"))
+	assert.Equal(t, http.StatusOK, health.Code)
+
+	// A completed request frees its slot for the next one
+	release <- struct{}{}
+	var remaining <-chan *httptest.ResponseRecorder
+	select {
+	case w := <-first:
+		assert.Equal(t, http.StatusOK, w.Code)
+		remaining = second
+	case w := <-second:
+		assert.Equal(t, http.StatusOK, w.Code)
+		remaining = first
+	case <-time.After(2 * time.Second):
+		t.Fatal("the released request was not answered")
+	}
+
+	third := serve(router, "/api/v1/slow")
+	waitStarted(t, started)
+	close(release)
+	assert.Equal(t, http.StatusOK, waitResponse(t, remaining).Code)
+	assert.Equal(t, http.StatusOK, waitResponse(t, third).Code)
+}
+
+func TestConcurrencyLimit_QueueTimeout(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	router := blockingRouter(concurrency.ConcurrencyConfig{MaxInFlight: 1, QueueTimeout: time.Second}, started, release)
+
+	first := serve(router, "/api/v1/slow")
+	waitStarted(t, started)
+
+	// The second request waits in the queue, and is processed once the first one completes
+	second := serve(router, "/api/v1/slow")
+	select {
+	case <-second:
+		t.Fatal("the request beyond the limit was answered instead of waiting")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release <- struct{}{}
+	assert.Equal(t, http.StatusOK, waitResponse(t, first).Code)
+	waitStarted(t, started)
+	release <- struct{}{}
+	assert.Equal(t, http.StatusOK, waitResponse(t, second).Code)
+}
+
+func TestConcurrencyLimit_QueueTimeoutExpires(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	defer close(release)
+	router := blockingRouter(concurrency.ConcurrencyConfig{MaxInFlight: 1, QueueTimeout: 50 * time.Millisecond}, started, release)
+
+	serve(router, "/api/v1/slow")
+	waitStarted(t, started)
+
+	// No slot frees within the queue timeout, the request is shed
+	begin := time.Now()
+	w := waitResponse(t, serve(router, "/api/v1/slow"))
+	assertShed(t, w)
+	assert.GreaterOrEqual(t, time.Since(begin), 50*time.Millisecond)
+	assert.Empty(t, started)
+}
+
+func TestConcurrencyLimit_Disabled(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	router := blockingRouter(concurrency.ConcurrencyConfig{}, started, release)
+
+	responses := make([]<-chan *httptest.ResponseRecorder, 5)
+	for i := range responses {
+		responses[i] = serve(router, "/api/v1/slow")
+	}
+	for range responses {
+		waitStarted(t, started)
+	}
+
+	close(release)
+	for _, done := range responses {
+		require.Equal(t, http.StatusOK, waitResponse(t, done).Code)
+	}
+}
+
+func TestLoadConcurrencyConfig(t *testing.T) {
+	t.Setenv("MAX_CONCURRENT_REQUESTS", "50")
+	t.Setenv("REQUEST_QUEUE_TIMEOUT", "250ms")
+	cfg := concurrency.LoadConcurrencyConfig()
+	assert.Equal(t, 50, cfg.MaxInFlight)
+	assert.Equal(t, 250*time.Millisecond, cfg.QueueTimeout)
+
+	t.Setenv("REQUEST_QUEUE_TIMEOUT", "2")
+	assert.Equal(t, 2*time.Second, concurrency.LoadConcurrencyConfig().QueueTimeout)
+
+	// The invalid values are ignored
+	t.Setenv("MAX_CONCURRENT_REQUESTS", "-1")
+	t.Setenv("REQUEST_QUEUE_TIMEOUT", "soon")
+	cfg = concurrency.LoadConcurrencyConfig()
+	assert.Equal(t, 0, cfg.MaxInFlight)
+	assert.Equal(t, time.Duration(0), cfg.QueueTimeout)
+}
@@ -0,0 +1,191 @@
+package test_config_reload
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/featureflag"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/headers"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/logging"
+)
+
+// setupReloader writes the configuration file and applies it, the environment and the reloaded settings
+// are restored at the end of the test.
+func setupReloader(t *testing.T, content string) (*config.Reloader, string) {
+	for _, key := range []string{"LOG_LEVEL", "FEATURE_FLAGS", "CORS_ALLOWED_ORIGINS", "DB_HOST", "JWT_SECRET"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("DB_HOST", "localhost")
+	t.Cleanup(func() {
+		logger.SetLevel(logrus.TraceLevel)
+		featureflag.SetProvider(nil)
+		os.Unsetenv("CORS_ALLOWED_ORIGINS")
+		headers.ReloadCorsConfig()
+	})
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, content)
+
+	reloader, err := config.NewReloader(path)
+	require.NoError(t, err)
+	return reloader, path
+}
+
+// writeFile replaces the content of the configuration file.
+func writeFile(t *testing.T, path string, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+// setupRouter returns a router logging its requests and answering the CORS headers.
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(headers.CorsHeaders(), logging.RequestLogger())
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+// get sends a request from the origin and returns the response.
+func get(router *gin.Engine, origin string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/ping", nil)
+	req.Header.Set("Origin", origin)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestReload_LogLevel(t *testing.T) {
+	reloader, path := setupReloader(t, "LOG_LEVEL: info\n")
+	assert.Equal(t, logrus.InfoLevel, logger.GetLevel())
+
+	logger.Init()
+	hook := test.NewLocal(logger.RequestLogger)
+	router := setupRouter()
+
+	// The requests are logged at INFO
+	get(router, "")
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, "Incoming request", hook.LastEntry().Message)
+
+	// The level is raised at runtime, the next requests are no longer logged
+	writeFile(t, path, "LOG_LEVEL: warn\n")
+	changes, err := reloader.Reload()
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, config.ConfigChange{Key: "LOG_LEVEL", Old: "info", New: "warn", Applied: true}, changes[0])
+	assert.Equal(t, logrus.WarnLevel, logger.GetLevel())
+
+	get(router, "")
+	assert.Len(t, hook.AllEntries(), 1)
+
+	// And lowered back
+	writeFile(t, path, "LOG_LEVEL: debug\n")
+	_, err = reloader.Reload()
+	require.NoError(t, err)
+
+	get(router, "")
+	assert.Len(t, hook.AllEntries(), 2)
+}
+
+func TestReload_SIGHUP(t *testing.T) {
+	reloader, path := setupReloader(t, "FEATURE_FLAGS: cookie_auth=false\n")
+	assert.False(t, featureflag.EnabledForTenant(1, featureflag.CookieAuth))
+
+	reloaded := make(chan []config.ConfigChange, 1)
+	reloader.WatchSIGHUP(func(changes []config.ConfigChange, err error) {
+		assert.NoError(t, err)
+		reloaded <- changes
+	})
+
+	writeFile(t, path, "FEATURE_FLAGS: cookie_auth=true\n")
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	select {
+	case changes := <-reloaded:
+		require.Len(t, changes, 1)
+		assert.Equal(t, "FEATURE_FLAGS", changes[0].Key)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the configuration was not reloaded on SIGHUP")
+	}
+	assert.True(t, featureflag.EnabledForTenant(1, featureflag.CookieAuth))
+}
+
+func TestReload_CorsOrigins(t *testing.T) {
+	reloader, path := setupReloader(t, "CORS_ALLOWED_ORIGINS: https://app.example.com\n")
+	router := setupRouter()
+
+	w := get(router, "https://admin.example.com")
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	writeFile(t, path, "CORS_ALLOWED_ORIGINS: https://app.example.com, https://admin.example.com/\n")
+	_, err := reloader.Reload()
+	require.NoError(t, err)
+
+	w = get(router, "https://admin.example.com")
+	assert.Equal(t, "https://admin.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	// A setting removed from the file gets back its value of the environment, the default origin
+	writeFile(t, path, "")
+	changes, err := reloader.Reload()
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "CORS_ALLOWED_ORIGINS", changes[0].Key)
+
+	w = get(router, "https://admin.example.com")
+	assert.Equal(t, "http://localhost", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestReload_StartupOnlySettings(t *testing.T) {
+	reloader, path := setupReloader(t, "DB_HOST: db-1\nJWT_SECRET: first-secret\nLOG_LEVEL: info\n")
+	assert.Equal(t, "db-1", os.Getenv("DB_HOST"))
+
+	writeFile(t, path, "DB_HOST: db-2\nJWT_SECRET: second-secret\nLOG_LEVEL: error\n")
+	changes, err := reloader.Reload()
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	// The changes come sorted by key, only the reloadable one is applied
+	assert.Equal(t, "DB_HOST", changes[0].Key)
+	assert.False(t, changes[0].Applied)
+	assert.Equal(t, "JWT_SECRET", changes[1].Key)
+	assert.False(t, changes[1].Applied)
+	assert.Equal(t, "LOG_LEVEL", changes[2].Key)
+	assert.True(t, changes[2].Applied)
+
+	assert.Equal(t, "db-1", os.Getenv("DB_HOST"))
+	assert.Equal(t, "first-secret", os.Getenv("JWT_SECRET"))
+	assert.Equal(t, logrus.ErrorLevel, logger.GetLevel())
+
+	// The secrets are redacted from the description of the changes
+	assert.Equal(t, `DB_HOST: "db-1" -> "db-2"`, changes[0].String())
+	assert.NotContains(t, changes[1].String(), "secret")
+}
+
+func TestReload_InvalidValue(t *testing.T) {
+	reloader, path := setupReloader(t, "LOG_LEVEL: info\nFEATURE_FLAGS: cookie_auth=false\n")
+
+	// The reload is all or nothing, the valid flags are not applied along with the invalid level
+	writeFile(t, path, "LOG_LEVEL: loud\nFEATURE_FLAGS: cookie_auth=true\n")
+	_, err := reloader.Reload()
+	assert.ErrorContains(t, err, "LOG_LEVEL")
+	assert.Equal(t, logrus.InfoLevel, logger.GetLevel())
+	assert.False(t, featureflag.EnabledForTenant(1, featureflag.CookieAuth))
+
+	writeFile(t, path, "LOG_LEVEL:\n  nested: true\n")
+	_, err = reloader.Reload()
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+package test_config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config"
+)
+
+// setValidEnv sets a complete and valid HS256 configuration.
+func setValidEnv(t *testing.T) {
+	t.Setenv("ENV", "DEVELOPMENT")
+	t.Setenv("API_VERSION", "1.0")
+	t.Setenv("PORT", "1000")
+	t.Setenv("IS_SSL", "FALSE")
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_PORT", "5432")
+	t.Setenv("DB_USER", "appuser")
+	t.Setenv("DB_PASS", "app@123")
+	t.Setenv("DB_NAME", "consumer_service")
+	t.Setenv("DB_SCHEMA", "public")
+	t.Setenv("DB_SSL_MODE", "disable")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("JWT_SECRET", "a-string-secret-at-least-256-bits-long")
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_ISSUER", "your_jwt_issuer")
+	t.Setenv("JWT_AUDIENCE", "your_jwt_audience")
+	t.Setenv("JWT_EXPIRATION_HOUR", "48")
+	t.Setenv("JWT_REFRESH_TOKEN_EXPIRATION_HOUR", "720")
+	t.Setenv("ACCESS_TOKEN_TTL_MINUTES", "")
+}
+
+func TestConfig_Valid(t *testing.T) {
+	setValidEnv(t)
+
+	cfg := config.Load()
+
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, "DEVELOPMENT", cfg.Env)
+	assert.Equal(t, "1000", cfg.Server.Port)
+	assert.Equal(t, "HS256", cfg.JWT.Algorithm)
+}
+
+func TestConfig_AggregatesMissingSettings(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("DB_HOST", "")
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_EXPIRATION_HOUR", "")
+
+	err := config.Load().Validate()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "3 errors")
+	assert.Contains(t, err.Error(), "DB_HOST is not set")
+	assert.Contains(t, err.Error(), "JWT_SECRET is not set")
+	assert.Contains(t, err.Error(), "JWT_EXPIRATION_HOUR is not set")
+}
+
+func TestConfig_InvalidValues(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("DB_PORT", "not-a-port")
+	t.Setenv("DB_SSL_MODE", "sometimes")
+	t.Setenv("JWT_SECRET", "too-short")
+	t.Setenv("JWT_REFRESH_TOKEN_EXPIRATION_HOUR", "-1")
+
+	err := config.Load().Validate()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "4 errors")
+	assert.Contains(t, err.Error(), `DB_PORT must be a valid port number, got "not-a-port"`)
+	assert.Contains(t, err.Error(), "DB_SSL_MODE must be one of")
+	assert.Contains(t, err.Error(), "JWT_SECRET must be at least 32 characters long")
+	assert.Contains(t, err.Error(), `JWT_REFRESH_TOKEN_EXPIRATION_HOUR must be a positive integer, got "-1"`)
+}
+
+func TestConfig_SQLiteOnlyNeedsDatabaseName(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("DB_DIALECT", "SQLite")
+	t.Setenv("DB_NAME", "consumer_service.db")
+	for _, key := range []string{"DB_HOST", "DB_PORT", "DB_USER", "DB_PASS", "DB_SCHEMA", "DB_SSL_MODE"} {
+		t.Setenv(key, "")
+	}
+
+	assert.NoError(t, config.Load().Validate())
+
+	t.Setenv("DB_NAME", "")
+	err := config.Load().Validate()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "1 errors")
+	assert.Contains(t, err.Error(), "DB_NAME is not set")
+}
+
+func TestConfig_InvalidDialect(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("DB_DIALECT", "mysql")
+
+	err := config.Load().Validate()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `DB_DIALECT must be one of postgres, sqlite, got "mysql"`)
+}
+
+func TestConfig_InvalidDefaultUserRole(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("DEFAULT_USER_ROLE", "role_user")
+	assert.NoError(t, config.Load().Validate())
+
+	t.Setenv("DEFAULT_USER_ROLE", "USER")
+	err := config.Load().Validate()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `DEFAULT_USER_ROLE must be a role name like ROLE_USER, got "USER"`)
+}
+
+func TestConfig_RS256RequiresKeyFiles(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("JWT_ALGORITHM", "RS256")
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_PRIVATE_KEY_PATH", "")
+	t.Setenv("JWT_PUBLIC_KEY_PATH", filepath.Join(t.TempDir(), "missing.pem"))
+
+	err := config.Load().Validate()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT_PRIVATE_KEY_PATH is not set")
+	assert.Contains(t, err.Error(), "JWT_PUBLIC_KEY_PATH does not point to a readable file")
+	assert.NotContains(t, err.Error(), "JWT_SECRET")
+
+	// Existing key files make the configuration valid
+	dir := t.TempDir()
+	privateKey := filepath.Join(dir, "privateKey.pem")
+	publicKey := filepath.Join(dir, "publicKey.pem")
+	assert.NoError(t, os.WriteFile(privateKey, []byte("private"), 0600))
+	assert.NoError(t, os.WriteFile(publicKey, []byte("public"), 0600))
+	t.Setenv("JWT_PRIVATE_KEY_PATH", privateKey)
+	t.Setenv("JWT_PUBLIC_KEY_PATH", publicKey)
+
+	assert.NoError(t, config.Load().Validate())
+}
+
+func TestConfig_InvalidPreviousKeys(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("JWT_PREVIOUS_KEYS", "2025-01")
+
+	err := config.Load().Validate()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT_PREVIOUS_KEYS is invalid")
+
+	t.Setenv("JWT_PREVIOUS_KEYS", "2025-01=old-secret")
+	assert.NoError(t, config.Load().Validate())
+}
@@ -0,0 +1,78 @@
+package test_consumer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+func TestConsumerHandler_ContextErrors(t *testing.T) {
+	h := handler.NewConsumerHandler(NewConsumerMockedService(getDummyConsumers()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/consumers", h.GetAllConsumers)
+	router.GET("/api/v1/consumers/:id", h.GetConsumerByID)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		path   string
+		status int
+	}{
+		{"cancelled list", cancelled, "/api/v1/consumers", httputil.StatusClientClosedRequest},
+		{"cancelled get", cancelled, "/api/v1/consumers/dummy-id", httputil.StatusClientClosedRequest},
+		{"expired list", expired, "/api/v1/consumers", http.StatusGatewayTimeout},
+		{"expired get", expired, "/api/v1/consumers/dummy-id", http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(tt.ctx, "GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestServerError_Mapping(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"deadline exceeded", context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{"wrapped cancellation", errors.Join(errors.New("query failed"), context.Canceled), httputil.StatusClientClosedRequest},
+		{"other error", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("GET", "/api/v1/consumers", nil)
+
+			httputil.ServerError(c, "Failed to retrieve consumers", tt.err)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package test_consumer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+func TestListConsumers_EmptyReturns200(t *testing.T) {
+	h := handler.NewConsumerHandler(NewConsumerMockedService(nil))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/consumers", h.GetAllConsumers)
+	router.GET("/api/v1/consumers/active", h.GetActiveConsumers)
+	router.GET("/api/v1/consumers/inactive", h.GetInactiveConsumers)
+	router.GET("/api/v1/consumers/suspended", h.GetSuspendedConsumers)
+
+	for _, path := range []string{
+		"/api/v1/consumers",
+		"/api/v1/consumers/active",
+		"/api/v1/consumers/inactive",
+		"/api/v1/consumers/suspended",
+	} {
+		t.Run(path, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", path+"?page=1&limit=10", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			// The data must be an empty array, not null
+			var raw map[string]json.RawMessage
+			err := json.Unmarshal(w.Body.Bytes(), &raw)
+			assert.NoError(t, err)
+			assert.JSONEq(t, "[]", string(raw["data"]))
+
+			var httpResponse httputil.HttpResponse
+			err = json.Unmarshal(w.Body.Bytes(), &httpResponse)
+			assert.NoError(t, err)
+			if assert.NotNil(t, httpResponse.Pagination) {
+				assert.Equal(t, int64(0), httpResponse.Pagination.Total)
+				assert.Equal(t, 1, httpResponse.Pagination.Page)
+				assert.Equal(t, 10, httpResponse.Pagination.Limit)
+			}
+		})
+	}
+}
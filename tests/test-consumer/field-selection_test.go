@@ -0,0 +1,74 @@
+package test_consumer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+// setupFieldSelectionRouter registers the consumer read routes backed by the mocked service.
+func setupFieldSelectionRouter() *gin.Engine {
+	h := handler.NewConsumerHandler(NewConsumerMockedService(getDummyConsumers()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/consumers", h.GetAllConsumers)
+	router.GET("/api/v1/consumers/:id", h.GetConsumerByID)
+	return router
+}
+
+func TestGetAllConsumers_SelectFields(t *testing.T) {
+	router := setupFieldSelectionRouter()
+
+	req, _ := http.NewRequest("GET", "/api/v1/consumers?fields=id,username,email", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data []map[string]any `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Len(t, body.Data, len(getDummyConsumers()))
+	for _, item := range body.Data {
+		assert.Len(t, item, 3)
+		assert.Contains(t, item, "id")
+		assert.Contains(t, item, "username")
+		assert.Contains(t, item, "email")
+	}
+}
+
+func TestGetConsumerByID_SelectFields(t *testing.T) {
+	router := setupFieldSelectionRouter()
+
+	req, _ := http.NewRequest("GET", "/api/v1/consumers/dummy-id-1?fields=username", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"username": "dummyuser1"}, body.Data)
+}
+
+func TestGetAllConsumers_InvalidField(t *testing.T) {
+	router := setupFieldSelectionRouter()
+
+	req, _ := http.NewRequest("GET", "/api/v1/consumers?fields=id,password", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
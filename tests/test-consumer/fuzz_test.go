@@ -0,0 +1,77 @@
+package test_consumer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+// FuzzGetAllConsumers_Pagination sends arbitrary page and limit query strings.
+// The handler must never panic, and only respond with 200 OK or 400 Bad Request.
+func FuzzGetAllConsumers_Pagination(f *testing.F) {
+	seeds := [][2]string{
+		{"1", "10"},
+		{"0", "0"},
+		{"-1", "-10"},
+		{"9223372036854775807", "9223372036854775807"},
+		{"1234567890123456789", "1000000000"},
+		{"1.5", "1e3"},
+		{"%", "_"},
+		{"", ""},
+	}
+	for _, seed := range seeds {
+		f.Add(seed[0], seed[1])
+	}
+
+	gin.SetMode(gin.TestMode)
+	f.Fuzz(func(t *testing.T, page string, limit string) {
+		h := handler.NewConsumerHandler(NewConsumerMockedService(getDummyConsumers()))
+		router := gin.New()
+		router.GET("/api/v1/consumers", h.GetAllConsumers)
+
+		query := url.Values{"page": {page}, "limit": {limit}}
+		req, _ := http.NewRequest("GET", "/api/v1/consumers?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK && w.Code != http.StatusBadRequest {
+			t.Fatalf("unexpected status %d for page %q and limit %q: %s", w.Code, page, limit, w.Body.String())
+		}
+	})
+}
+
+// FuzzGetConsumerByID_PathParameter sends arbitrary consumer IDs in the path.
+// The handler must never panic, and only respond with 200 OK, 404 Not Found or 400 Bad Request.
+func FuzzGetConsumerByID_PathParameter(f *testing.F) {
+	for _, seed := range []string{getDummyConsumer().ID, "1234567890123456789", "-1", "ユーザー", "%", "_", "..", "%00"} {
+		f.Add(seed)
+	}
+
+	gin.SetMode(gin.TestMode)
+	f.Fuzz(func(t *testing.T, id string) {
+		// A path parameter cannot be empty nor hold a slash, the router redirects or does not match these paths
+		if id == "" || strings.Contains(id, "/") {
+			return
+		}
+
+		h := handler.NewConsumerHandler(NewConsumerMockedService(getDummyConsumers()))
+		router := gin.New()
+		router.GET("/api/v1/consumers/:id", h.GetConsumerByID)
+
+		req, _ := http.NewRequest("GET", "/api/v1/consumers/"+url.PathEscape(id), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		switch w.Code {
+		case http.StatusOK, http.StatusNotFound, http.StatusBadRequest:
+		default:
+			t.Fatalf("unexpected status %d for ID %q: %s", w.Code, id, w.Body.String())
+		}
+	})
+}
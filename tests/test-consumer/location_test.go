@@ -0,0 +1,87 @@
+package test_consumer
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+func TestCreateConsumer_LocationHeader(t *testing.T) {
+	h := handler.NewConsumerHandler(NewConsumerMockedService(nil))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/consumers", h.CreateConsumer)
+	router.Group("/prefix/api/v2").POST("/consumers", h.CreateConsumer)
+
+	body := `{
+		"fullname": "New Consumer",
+		"username": "newconsumer",
+		"email": "new-consumer@example.com",
+		"phone": "6281234567890",
+		"address": "Jl. Sudirman No. 1",
+		"birthDate": "1990-01-01",
+		"status": "active"
+	}`
+
+	tests := []struct {
+		path     string
+		location string
+	}{
+		{"/api/v1/consumers", "/api/v1/consumers/new-dummy-id"},
+		{"/prefix/api/v2/consumers", "/prefix/api/v2/consumers/new-dummy-id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", tt.path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestCreateConsumer_StatusMapping(t *testing.T) {
+	h := handler.NewConsumerHandler(NewConsumerMockedService([]entity.Consumer{getDummyConsumer()}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/consumers", h.CreateConsumer)
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		location string
+	}{
+		{"created", `{"fullname": "New Consumer", "username": "newconsumer", "email": "new-consumer@example.com", "phone": "6281234567890", "address": "Jl. Sudirman No. 1", "birthDate": "1990-01-01"}`, http.StatusCreated, "/api/v1/consumers/new-dummy-id"},
+		{"duplicate username", `{"fullname": "New Consumer", "username": "dummyuser", "email": "new-consumer@example.com", "phone": "6281234567890", "address": "Jl. Sudirman No. 1", "birthDate": "1990-01-01"}`, http.StatusConflict, ""},
+		{"invalid email", `{"fullname": "New Consumer", "username": "newconsumer", "email": "not-an-email", "phone": "6281234567890", "address": "Jl. Sudirman No. 1", "birthDate": "1990-01-01"}`, http.StatusUnprocessableEntity, ""},
+		{"missing fields", `{"username": "newconsumer"}`, http.StatusUnprocessableEntity, ""},
+		{"malformed JSON", `{"fullname": "New Consumer",`, http.StatusBadRequest, ""},
+		{"wrong field type", `{"fullname": 42}`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/v1/consumers", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+		})
+	}
+}
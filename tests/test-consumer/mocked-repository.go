@@ -17,6 +17,8 @@ type ConsumerMockedRepository interface {
 	GetConsumerByEmail(tx *gorm.DB, email string) (entity.Consumer, error)
 	GetConsumerByPhone(tx *gorm.DB, phone string) (entity.Consumer, error)
 	GetConsumersByStatus(tx *gorm.DB, status string, page int, limit int) ([]entity.Consumer, error)
+	CountConsumers(tx *gorm.DB) (int64, error)
+	CountConsumersByStatus(tx *gorm.DB, status string) (int64, error)
 	CreateConsumer(tx *gorm.DB, d entity.Consumer) (entity.Consumer, error)
 	UpdateConsumer(tx *gorm.DB, d entity.Consumer) (entity.Consumer, error)
 }
@@ -112,6 +114,17 @@ func (r *consumerMockedRepository) GetConsumersByStatus(tx *gorm.DB, status stri
 	return filteredConsumers, nil
 }
 
+// CountConsumers counts all consumers in the dummy data.
+func (r *consumerMockedRepository) CountConsumers(tx *gorm.DB) (int64, error) {
+	return int64(len(getDummyConsumers())), nil
+}
+
+// CountConsumersByStatus counts the consumers with the given status in the dummy data.
+func (r *consumerMockedRepository) CountConsumersByStatus(tx *gorm.DB, status string) (int64, error) {
+	consumers, _ := r.GetConsumersByStatus(tx, status, 1, len(getDummyConsumers()))
+	return int64(len(consumers)), nil
+}
+
 // CreateConsumer creates a new consumer in the dummy data.
 // It simulates the creation of a consumer in a database by returning a predefined consumer object
 func (r *consumerMockedRepository) CreateConsumer(tx *gorm.DB, t entity.Consumer) (entity.Consumer, error) {
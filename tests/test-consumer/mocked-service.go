@@ -0,0 +1,108 @@
+package test_consumer
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+)
+
+// consumerMockedService is a struct that implements the ConsumerService interface.
+// It serves the dummy data directly, so the handlers can be tested without a database connection.
+type consumerMockedService struct {
+	consumers []entity.Consumer
+}
+
+// NewConsumerMockedService creates a new instance of ConsumerService backed by the given consumers.
+// It initializes the consumerMockedService struct and returns it.
+func NewConsumerMockedService(consumers []entity.Consumer) service.ConsumerService {
+	return &consumerMockedService{consumers: consumers}
+}
+
+// GetAllConsumers returns all dummy consumers.
+// Like a database query, it fails when the context is cancelled or its deadline is exceeded.
+func (s *consumerMockedService) GetAllConsumers(ctx context.Context, page int, limit int) ([]entity.Consumer, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return s.consumers, int64(len(s.consumers)), nil
+}
+
+// GetConsumerByID returns the dummy consumer with the given ID.
+func (s *consumerMockedService) GetConsumerByID(ctx context.Context, id string) (entity.Consumer, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.Consumer{}, err
+	}
+
+	for _, consumer := range s.consumers {
+		if consumer.ID == id {
+			return consumer, nil
+		}
+	}
+
+	return entity.Consumer{}, gorm.ErrRecordNotFound
+}
+
+// GetActiveConsumers returns the active dummy consumers.
+func (s *consumerMockedService) GetActiveConsumers(ctx context.Context, page int, limit int) ([]entity.Consumer, int64, error) {
+	consumers := s.filterByStatus(entity.ConsumerStatusActive)
+	return consumers, int64(len(consumers)), nil
+}
+
+// GetInactiveConsumers returns the inactive dummy consumers.
+func (s *consumerMockedService) GetInactiveConsumers(ctx context.Context, page int, limit int) ([]entity.Consumer, int64, error) {
+	consumers := s.filterByStatus(entity.ConsumerStatusInactive)
+	return consumers, int64(len(consumers)), nil
+}
+
+// GetSuspendedConsumers returns the suspended dummy consumers.
+func (s *consumerMockedService) GetSuspendedConsumers(ctx context.Context, page int, limit int) ([]entity.Consumer, int64, error) {
+	consumers := s.filterByStatus(entity.ConsumerStatusSuspended)
+	return consumers, int64(len(consumers)), nil
+}
+
+// CreateConsumer validates the given consumer and returns it with a generated ID,
+// a consumer with the username of a dummy consumer already exists.
+func (s *consumerMockedService) CreateConsumer(ctx context.Context, c entity.Consumer) (entity.Consumer, error) {
+	if err := c.Validate(); err != nil {
+		return entity.Consumer{}, err
+	}
+	for _, consumer := range s.consumers {
+		if consumer.Username == c.Username {
+			return entity.Consumer{}, fmt.Errorf("%w: username %s", service.ErrConsumerAlreadyExists, c.Username)
+		}
+	}
+
+	if c.ID == "" {
+		c.ID = "new-dummy-id"
+	}
+
+	return c, nil
+}
+
+// UpdateConsumerStatus updates the status of the dummy consumer with the given ID.
+func (s *consumerMockedService) UpdateConsumerStatus(ctx context.Context, id string, status string) (entity.Consumer, error) {
+	consumer, err := s.GetConsumerByID(ctx, id)
+	if err != nil {
+		return entity.Consumer{}, err
+	}
+
+	consumer.Status = status
+	return consumer, nil
+}
+
+// filterByStatus returns the dummy consumers with the given status.
+func (s *consumerMockedService) filterByStatus(status string) []entity.Consumer {
+	var filtered []entity.Consumer
+	for _, consumer := range s.consumers {
+		if consumer.Status == status {
+			filtered = append(filtered, consumer)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,228 @@
+package test_credential_expiry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+// adminContext returns a context authenticated as the admin of the default tenant.
+func adminContext() context.Context {
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: 1, Username: "admin", Roles: []string{"ROLE_ADMIN"}, TenantID: metacontext.DefaultTenantID,
+	})
+	return metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID)
+}
+
+// count returns the number of rows of the table matching the condition.
+func count(t *testing.T, db *gorm.DB, table string, query string, args ...any) int64 {
+	var n int64
+	require.NoError(t, db.Table(table).Where(query, args...).Count(&n).Error)
+	return n
+}
+
+// credentialsExpired reports whether the credentials of the user are expired.
+func credentialsExpired(t *testing.T, db *gorm.DB, id int64) bool {
+	var user entity.User
+	require.NoError(t, db.Unscoped().First(&user, id).Error)
+	return !*user.IsCredentialsNonExpired
+}
+
+// setupAuthRouter registers the login, the refresh of the token and the change of the password.
+func setupAuthRouter(t *testing.T) *gin.Engine {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_EXPIRATION_HOUR", "1")
+	t.Setenv("JWT_REFRESH_TOKEN_EXPIRATION_HOUR", "24")
+	t.Setenv("MAX_SESSIONS_PER_USER", "0")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := handler.NewAuthHandler(service.NewAuthService(clock.New()), nil)
+	router.POST("/auth/login", h.Login)
+	router.POST("/auth/refresh-token", h.RefreshToken)
+	router.POST("/auth/change-password", h.ChangePassword)
+	return router
+}
+
+// post posts the body as JSON to the path.
+func post(router *gin.Engine, path string, body any) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestExpireUserCredentials_ChangePasswordBeforeLogin(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+	router := setupAuthRouter(t)
+
+	user, err := s.ExpireUserCredentials(adminContext(), 2)
+	require.NoError(t, err)
+	assert.False(t, *user.IsCredentialsNonExpired)
+
+	// The sessions are revoked, the expiration is audited and emitted for the email to the user
+	assert.Zero(t, count(t, db, "refresh_token", "user_id = ?", 2))
+	assert.Equal(t, int64(1), count(t, db, "refresh_token", "user_id = ?", 3))
+	assert.Equal(t, int64(1), count(t, db, "audit_logs", "action = ? AND entity_id = ? AND actor_id = ?", "expire_credentials", "2", 1))
+	var event entity.OutboxEvent
+	require.NoError(t, db.First(&event, "type = ?", entity.OutboxEventCredentialsExpired).Error)
+	assert.Equal(t, "2", event.AggregateID)
+	var payload entity.AccountEventPayload
+	require.NoError(t, json.Unmarshal([]byte(event.Payload), &payload))
+	assert.Equal(t, "alice@mygmail.com", payload.Email)
+
+	// The login points at the change of the password, only for the caller knowing the password
+	w := post(router, "/auth/login", map[string]string{"username": "alice", "password": testPassword})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Password change required")
+	assert.Contains(t, w.Body.String(), "/auth/change-password")
+
+	// The change of the password needs the current one, and a new one
+	change := map[string]string{"username": "alice", "oldPassword": "WrongP@ssw0rd", "newPassword": "N3wP@ssw0rd456"}
+	assert.Equal(t, http.StatusUnauthorized, post(router, "/auth/change-password", change).Code)
+	change["oldPassword"] = testPassword
+	change["newPassword"] = testPassword
+	assert.Equal(t, http.StatusUnprocessableEntity, post(router, "/auth/change-password", change).Code)
+	change["newPassword"] = "short"
+	assert.Equal(t, http.StatusUnprocessableEntity, post(router, "/auth/change-password", change).Code)
+	change["newPassword"] = "N3wP@ssw0rd456"
+	change["username"] = "nobody"
+	assert.Equal(t, http.StatusUnauthorized, post(router, "/auth/change-password", change).Code)
+	assert.True(t, credentialsExpired(t, db, 2))
+
+	change = map[string]string{"username": "alice", "oldPassword": testPassword, "newPassword": "N3wP@ssw0rd456"}
+	w = post(router, "/auth/change-password", change)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.False(t, credentialsExpired(t, db, 2))
+
+	// The user is the author of the change
+	var updated entity.User
+	require.NoError(t, db.First(&updated, 2).Error)
+	assert.Equal(t, int64(2), *updated.UpdatedBy)
+
+	w = post(router, "/auth/login", map[string]string{"username": "alice", "password": testPassword})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = post(router, "/auth/login", map[string]string{"username": "alice", "password": "N3wP@ssw0rd456"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestExpireUserCredentials_SessionsKeptWhenConfigured(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("CREDENTIAL_EXPIRY_REVOKE_SESSIONS", "false")
+	s := service.NewUserService(repository.NewUserRepository())
+
+	_, err := s.ExpireUserCredentials(adminContext(), 2)
+	require.NoError(t, err)
+	assert.True(t, credentialsExpired(t, db, 2))
+	assert.Equal(t, int64(1), count(t, db, "refresh_token", "user_id = ?", 2))
+
+	_, err = s.ExpireUserCredentials(adminContext(), 999)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = s.ExpireUserCredentials(context.Background(), 2)
+	assert.ErrorContains(t, err, "missing user context")
+}
+
+func TestExpireUserCredentials_RefreshRefusedWhenSessionsKept(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("CREDENTIAL_EXPIRY_REVOKE_SESSIONS", "false")
+	s := service.NewUserService(repository.NewUserRepository())
+	router := setupAuthRouter(t)
+
+	// The session of the other user is still refreshed
+	w := post(router, "/auth/refresh-token", map[string]string{"refreshToken": "bob"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	_, err := s.ExpireUserCredentials(adminContext(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count(t, db, "refresh_token", "user_id = ?", 2))
+
+	// The kept session no longer issues access tokens until the password is changed
+	w = post(router, "/auth/refresh-token", map[string]string{"refreshToken": "alice"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Password change required")
+
+	_, err = service.NewAuthService(clock.New()).RefreshToken(entity.RefreshTokenRequest{RefreshToken: "alice"})
+	assert.ErrorIs(t, err, service.ErrPasswordChangeRequired)
+	assert.Equal(t, int64(1), count(t, db, "refresh_token", "user_id = ? AND token = ?", 2, "alice"))
+}
+
+func TestExpireRoleCredentials_Cohort(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	// alice and the clerks over two batches, the caller, the already expired carol and the deleted dave are left out
+	result, err := s.ExpireRoleCredentials(adminContext(), "role_finance")
+	require.NoError(t, err)
+	assert.Equal(t, entity.CredentialExpiryResult{Role: "ROLE_FINANCE", AffectedUsers: 1 + financeClerks, SessionsRevoked: true}, result)
+
+	assert.True(t, credentialsExpired(t, db, 2))
+	assert.True(t, credentialsExpired(t, db, 5+financeClerks))
+	assert.False(t, credentialsExpired(t, db, 1))
+	assert.False(t, credentialsExpired(t, db, 3))
+	assert.False(t, credentialsExpired(t, db, 5))
+	assert.Zero(t, count(t, db, "refresh_token", "user_id IN ?", []int64{2, 5 + financeClerks}))
+	assert.Equal(t, int64(1), count(t, db, "refresh_token", "user_id = ?", 3))
+
+	// Every affected user has its own audit entry and outbox event
+	assert.Equal(t, int64(1+financeClerks), count(t, db, "audit_logs", "action = ?", "expire_credentials"))
+	assert.Equal(t, int64(1+financeClerks), count(t, db, "outbox_events", "type = ?", entity.OutboxEventCredentialsExpired))
+	assert.Zero(t, count(t, db, "audit_logs", "entity_id IN ?", []string{"1", "3", "4", "5"}))
+
+	// Running it again touches no one
+	result, err = s.ExpireRoleCredentials(adminContext(), "ROLE_FINANCE")
+	require.NoError(t, err)
+	assert.Zero(t, result.AffectedUsers)
+
+	_, err = s.ExpireRoleCredentials(adminContext(), "ROLE_UNKNOWN")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestExpireCredentials_Handlers(t *testing.T) {
+	setupDatabase(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository()))
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(adminContext())
+		c.Next()
+	})
+	router.POST("/api/v1/admin/users/:id/expire-credentials", h.ExpireUserCredentials)
+	router.POST("/api/v1/admin/roles/:name/expire-credentials", h.ExpireRoleCredentials)
+
+	assert.Equal(t, http.StatusBadRequest, post(router, "/api/v1/admin/users/abc/expire-credentials", nil).Code)
+	assert.Equal(t, http.StatusNotFound, post(router, "/api/v1/admin/users/999/expire-credentials", nil).Code)
+	assert.Equal(t, http.StatusNotFound, post(router, "/api/v1/admin/roles/ROLE_UNKNOWN/expire-credentials", nil).Code)
+
+	w := post(router, "/api/v1/admin/users/3/expire-credentials", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "password")
+
+	w = post(router, "/api/v1/admin/roles/ROLE_FINANCE/expire-credentials", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Data entity.CredentialExpiryResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1+financeClerks, resp.Data.AffectedUsers)
+	assert.True(t, resp.Data.SessionsRevoked)
+}
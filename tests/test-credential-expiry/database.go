@@ -0,0 +1,62 @@
+package test_credential_expiry
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+const (
+	// testPassword is the password of the users of the test database.
+	testPassword = "P@ssw0rd123"
+
+	// financeClerks is the number of the generated holders of ROLE_FINANCE, more than a batch of the expiration.
+	financeClerks = 120
+)
+
+// setupDatabase opens an SQLite database with the tables of the migration and makes
+// the services use it instead of PostgreSQL. It holds the admin (ID 1) with the ROLE_ADMIN and ROLE_FINANCE roles,
+// alice (ID 2) with the ROLE_FINANCE role and a session, bob (ID 3) with the ROLE_USER role and a session,
+// carol (ID 4) with the ROLE_FINANCE role and expired credentials, the deleted dave (ID 5) with the ROLE_FINANCE role,
+// and the financeClerks clerks (IDs 6 and up) with the ROLE_FINANCE role.
+func setupDatabase(t *testing.T) *gorm.DB {
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash the password: %v", err)
+	}
+
+	db := testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_ADMIN', false), ('ROLE_FINANCE', false)`,
+		fmt.Sprintf(`INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired,
+			is_account_non_locked, is_credentials_non_expired, user_type) VALUES
+			('admin', '%[1]s', 'admin@mygmail.com', 'Admin', true, true, true, true, 'USER_ACCOUNT'),
+			('alice', '%[1]s', 'alice@mygmail.com', 'Alice', true, true, true, true, 'USER_ACCOUNT'),
+			('bob', '%[1]s', 'bob@mygmail.com', 'Bob', true, true, true, true, 'USER_ACCOUNT'),
+			('carol', '%[1]s', 'carol@mygmail.com', 'Carol', true, true, true, true, 'USER_ACCOUNT'),
+			('dave', '%[1]s', 'dave@mygmail.com', 'Dave', true, true, true, true, 'USER_ACCOUNT')`, hash),
+		fmt.Sprintf(`WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < %d)
+			INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired, is_account_non_locked,
+				is_credentials_non_expired, user_type)
+			SELECT 'clerk' || n, '!', 'clerk' || n || '@mygmail.com', 'Clerk', true, true, true, true, 'USER_ACCOUNT' FROM seq`, financeClerks),
+		`UPDATE users SET is_credentials_non_expired = false WHERE id = 4`,
+		`UPDATE users SET is_deleted = true, deleted_by = 1, deleted_at = CURRENT_TIMESTAMP WHERE id = 5`,
+		`INSERT INTO user_roles (user_id, role_id) SELECT id, 3 FROM users WHERE id <> 3`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES (1, 2), (3, 1)`,
+		`INSERT INTO refresh_token (token, session_id, user_id, expiry_date, created_at) VALUES
+			('alice', 'alice-session', 2, datetime('now', '+1 day'), CURRENT_TIMESTAMP),
+			('bob', 'bob-session', 3, datetime('now', '+1 day'), CURRENT_TIMESTAMP),
+			('clerk', 'clerk-session', 125, datetime('now', '+1 day'), CURRENT_TIMESTAMP)`,
+	)
+
+	// Record the actor of the writes like the PostgreSQL connection does
+	if err := database.RegisterAuditCallbacks(db); err != nil {
+		t.Fatalf("failed to register the audit callbacks: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,96 @@
+package test_database
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+// auditedRecord is a model carrying the audit fields, like the users.
+type auditedRecord struct {
+	ID        int64 `gorm:"primaryKey"`
+	Name      string
+	CreatedBy *int64
+	UpdatedBy *int64
+}
+
+// openAuditedDatabase opens an SQLite database with the audit callbacks and the table of the audited records.
+func openAuditedDatabase(t *testing.T) *gorm.DB {
+	dsn := fmt.Sprintf("file:%s", filepath.Join(t.TempDir(), "audit.db"))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, database.RegisterAuditCallbacks(db))
+	require.NoError(t, db.AutoMigrate(&auditedRecord{}))
+	return db
+}
+
+// userContext returns a context carrying the user with the given ID.
+func userContext(userID int64) context.Context {
+	return metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{UserID: userID, Username: "admin"})
+}
+
+func TestAuditCallbacks_Create(t *testing.T) {
+	db := openAuditedDatabase(t)
+
+	record := auditedRecord{Name: "single"}
+	require.NoError(t, db.WithContext(userContext(7)).Create(&record).Error)
+	require.NotNil(t, record.CreatedBy)
+	assert.Equal(t, int64(7), *record.CreatedBy)
+	assert.Equal(t, int64(7), *record.UpdatedBy)
+
+	// The fields are written to the row, not only to the model
+	var stored auditedRecord
+	require.NoError(t, db.First(&stored, record.ID).Error)
+	assert.Equal(t, int64(7), *stored.CreatedBy)
+	assert.Equal(t, int64(7), *stored.UpdatedBy)
+
+	// Every record of a batch is filled, an author set by the caller is kept
+	creator := int64(3)
+	batch := []auditedRecord{{Name: "first"}, {Name: "second", CreatedBy: &creator}}
+	require.NoError(t, db.WithContext(userContext(7)).Create(&batch).Error)
+	assert.Equal(t, int64(7), *batch[0].CreatedBy)
+	assert.Equal(t, int64(3), *batch[1].CreatedBy)
+	assert.Equal(t, int64(7), *batch[1].UpdatedBy)
+
+	// Without a user in the context, nothing is filled
+	anonymous := auditedRecord{Name: "anonymous"}
+	require.NoError(t, db.WithContext(context.Background()).Create(&anonymous).Error)
+	assert.Nil(t, anonymous.CreatedBy)
+	assert.Nil(t, anonymous.UpdatedBy)
+}
+
+func TestAuditCallbacks_Update(t *testing.T) {
+	db := openAuditedDatabase(t)
+
+	record := auditedRecord{Name: "record"}
+	require.NoError(t, db.WithContext(userContext(7)).Create(&record).Error)
+
+	// Saving the model records the user performing the update, the creator is kept
+	record.Name = "saved"
+	require.NoError(t, db.WithContext(userContext(8)).Save(&record).Error)
+	var stored auditedRecord
+	require.NoError(t, db.First(&stored, record.ID).Error)
+	assert.Equal(t, int64(7), *stored.CreatedBy)
+	assert.Equal(t, int64(8), *stored.UpdatedBy)
+
+	// An update of some columns records the user too
+	require.NoError(t, db.WithContext(userContext(9)).Model(&record).Updates(map[string]any{"name": "updated"}).Error)
+	require.NoError(t, db.First(&stored, record.ID).Error)
+	assert.Equal(t, "updated", stored.Name)
+	assert.Equal(t, int64(9), *stored.UpdatedBy)
+
+	// Without a user in the context, the last author is kept
+	require.NoError(t, db.Model(&record).Update("name", "background").Error)
+	require.NoError(t, db.First(&stored, record.ID).Error)
+	assert.Equal(t, int64(9), *stored.UpdatedBy)
+}
@@ -0,0 +1,153 @@
+package test_database
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// connectionRefused is the error returned by the driver when the database does not accept connections.
+var connectionRefused = &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+// fakeClock is a clock advanced by the tests.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newBreaker(threshold int, cooldown time.Duration) (*database.CircuitBreaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := database.NewCircuitBreaker(threshold, cooldown)
+	b.Now = clock.Now
+	return b, clock
+}
+
+func TestCircuitBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	b, _ := newBreaker(3, 30*time.Second)
+
+	// A success resets the count, and the errors of a reachable database are successes
+	b.Record(connectionRefused)
+	b.Record(connectionRefused)
+	b.Record(nil)
+	b.Record(connectionRefused)
+	b.Record(gorm.ErrRecordNotFound)
+	b.Record(connectionRefused)
+	b.Record(connectionRefused)
+	assert.Equal(t, database.BreakerClosed, b.State())
+	assert.NoError(t, b.Allow())
+
+	b.Record(connectionRefused)
+	assert.Equal(t, database.BreakerOpen, b.State())
+}
+
+func TestCircuitBreaker_FastFailsWhileOpen(t *testing.T) {
+	b, clock := newBreaker(1, 30*time.Second)
+	b.Record(connectionRefused)
+
+	openedAt := clock.now
+	for _, elapsed := range []time.Duration{0, 10 * time.Second, 29 * time.Second} {
+		clock.now = openedAt.Add(elapsed)
+		assert.ErrorIs(t, b.Allow(), database.ErrCircuitOpen)
+		assert.Equal(t, 30*time.Second-elapsed, b.RetryAfter())
+	}
+	assert.Equal(t, database.BreakerOpen, b.State())
+}
+
+func TestCircuitBreaker_ProbeAfterCooldown(t *testing.T) {
+	b, clock := newBreaker(1, 30*time.Second)
+	b.Record(connectionRefused)
+
+	// After the cooldown a single probe is allowed
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.Equal(t, database.BreakerHalfOpen, b.State())
+	require.NoError(t, b.Allow())
+	assert.ErrorIs(t, b.Allow(), database.ErrCircuitOpen)
+
+	// A failed probe opens the breaker for another cooldown
+	b.Record(connectionRefused)
+	assert.Equal(t, database.BreakerOpen, b.State())
+	assert.ErrorIs(t, b.Allow(), database.ErrCircuitOpen)
+
+	// A successful probe closes it
+	clock.now = clock.now.Add(30 * time.Second)
+	require.NoError(t, b.Allow())
+	b.Record(nil)
+	assert.Equal(t, database.BreakerClosed, b.State())
+	assert.NoError(t, b.Allow())
+	assert.NoError(t, b.Allow())
+}
+
+func TestCircuitBreaker_GormStatements(t *testing.T) {
+	dsn := fmt.Sprintf("file:%s", filepath.Join(t.TempDir(), "breaker.db"))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, username TEXT NOT NULL, deleted_at DATETIME)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO users (id, username) VALUES (1, 'admin')`).Error)
+
+	b, clock := newBreaker(3, 30*time.Second)
+	require.NoError(t, b.Register(db))
+
+	// Simulate an unreachable database: the statements reaching the driver fail to connect
+	failing, reached := true, 0
+	require.NoError(t, db.Callback().Query().After("breaker:before_query").Before("gorm:query").Register("test:unreachable", func(tx *gorm.DB) {
+		if tx.Error != nil {
+			return
+		}
+		reached++
+		if failing {
+			_ = tx.AddError(connectionRefused)
+		}
+	}))
+
+	find := func() error {
+		var user entity.User
+		return db.Table("users").First(&user, 1).Error
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, find(), syscall.ECONNREFUSED)
+	}
+	assert.Equal(t, database.BreakerOpen, b.State())
+
+	// While open, the statements fail fast without reaching the driver
+	assert.ErrorIs(t, find(), database.ErrCircuitOpen)
+	assert.ErrorIs(t, db.Exec("UPDATE users SET username = 'root'").Error, database.ErrCircuitOpen)
+	assert.Equal(t, 3, reached)
+
+	// The database is back, the probe after the cooldown closes the breaker
+	failing = false
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.NoError(t, find())
+	assert.Equal(t, database.BreakerClosed, b.State())
+	assert.NoError(t, find())
+}
+
+func TestServerError_CircuitOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/users", func(c *gin.Context) {
+		httputil.ServerError(c, "Failed to retrieve users", fmt.Errorf("query users: %w", database.ErrCircuitOpen))
+	})
+
+	req, _ := http.NewRequest("GET", "/users", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
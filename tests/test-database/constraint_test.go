@@ -0,0 +1,228 @@
+package test_database
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// openConstrainedDatabase opens an SQLite database enforcing the foreign keys, with the GORM configuration
+// of the application, and creates the users and roles tables with the constraints of the PostgreSQL schema.
+func openConstrainedDatabase(t *testing.T) *gorm.DB {
+	database.DBSchema = ""
+	database.DBLog = "SILENT"
+
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)", filepath.Join(t.TempDir(), "constraint.db"))
+	db, err := gorm.Open(database.WithConstraintErrors(sqlite.Open(dsn)), database.NewGormConfig())
+	require.NoError(t, err)
+
+	for _, stmt := range []string{
+		`CREATE TABLE users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			tenant_id INTEGER NOT NULL DEFAULT 1,
+			username TEXT NOT NULL,
+			password TEXT NOT NULL,
+			email TEXT NOT NULL,
+			firstname TEXT NOT NULL,
+			lastname TEXT,
+			is_enabled BOOLEAN NOT NULL DEFAULT false,
+			is_account_non_expired BOOLEAN NOT NULL DEFAULT false,
+			is_account_non_locked BOOLEAN NOT NULL DEFAULT false,
+			is_credentials_non_expired BOOLEAN NOT NULL DEFAULT false,
+			is_deleted BOOLEAN NOT NULL DEFAULT false,
+			account_expiration_date DATETIME,
+			credentials_expiration_date DATETIME,
+			user_type TEXT NOT NULL CONSTRAINT chk_users_user_type CHECK (user_type IN ('SERVICE_ACCOUNT','USER_ACCOUNT')),
+			last_login DATETIME,
+			max_sessions INTEGER,
+			metadata TEXT NOT NULL DEFAULT '{}',
+			created_by INTEGER CONSTRAINT fk_users_created_by REFERENCES users(id),
+			created_at DATETIME,
+			updated_by INTEGER,
+			updated_at DATETIME,
+			deleted_by INTEGER,
+			merged_into INTEGER,
+			deleted_at DATETIME
+		)`,
+		`CREATE TABLE roles (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`,
+		`CREATE TABLE user_roles (user_id INTEGER REFERENCES users(id), role_id INTEGER REFERENCES roles(id), expires_at DATETIME)`,
+		`INSERT INTO users (id, username, password, email, firstname, is_enabled, user_type)
+			VALUES (1, 'admin', 'secret', 'admin@mygmail.com', 'Admin', true, 'USER_ACCOUNT')`,
+		`INSERT INTO users (id, username, password, email, firstname, is_enabled, user_type, created_by)
+			VALUES (2, 'user', 'secret', 'user@mygmail.com', 'User', true, 'USER_ACCOUNT', 1)`,
+	} {
+		require.NoError(t, db.Exec(stmt).Error)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+func TestConstraintErrors_UpdateUser(t *testing.T) {
+	db := openConstrainedDatabase(t)
+	repo := repository.NewUserRepository()
+
+	tests := []struct {
+		name    string
+		change  func(user *entity.User)
+		kind    error
+		message string
+	}{
+		{
+			name:    "foreign key",
+			change:  func(user *entity.User) { missing := int64(42); user.CreatedBy = &missing },
+			kind:    database.ErrForeignKeyViolation,
+			message: "The record refers to a missing record, or is still referenced by another one",
+		},
+		{
+			name:    "not null",
+			change:  func(user *entity.User) { user.IsEnabled = nil },
+			kind:    database.ErrNotNullViolation,
+			message: "The value of is_enabled is required",
+		},
+		{
+			name:    "check",
+			change:  func(user *entity.User) { user.UserType = "ROBOT_ACCOUNT" },
+			kind:    database.ErrCheckViolation,
+			message: "A value is rejected by the chk_users_user_type constraint",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := repo.GetUserByID(db, 2)
+			require.NoError(t, err)
+			tt.change(&user)
+
+			_, err = repo.UpdateUser(db, user)
+			var constraintErr *database.ConstraintError
+			require.ErrorAs(t, err, &constraintErr)
+			assert.ErrorIs(t, err, tt.kind)
+			assert.Equal(t, tt.message, constraintErr.Message())
+		})
+	}
+}
+
+func TestConstraintErrors_DeleteReferencedUser(t *testing.T) {
+	db := openConstrainedDatabase(t)
+
+	// The admin is still the creator of the other user
+	err := db.Unscoped().Delete(&entity.User{}, "id = ?", 1).Error
+	assert.ErrorIs(t, err, database.ErrForeignKeyViolation)
+
+	// The unique violations are still translated by the dialector
+	err = db.Exec(`CREATE UNIQUE INDEX idx_users_username ON users (username)`).Error
+	require.NoError(t, err)
+	err = db.Create(&entity.User{Username: "admin", Password: "secret", Email: "other@mygmail.com", Firstname: "Other", UserType: "USER_ACCOUNT"}).Error
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+}
+
+func TestTranslateConstraintError_Postgres(t *testing.T) {
+	tests := []struct {
+		name    string
+		pgErr   *pgconn.PgError
+		kind    error
+		column  string
+		message string
+	}{
+		{
+			name: "missing referenced row",
+			pgErr: &pgconn.PgError{Code: "23503", TableName: "users", ConstraintName: "fk_users_created_by",
+				Detail: `Key (created_by)=(42) is not present in table "users".`},
+			kind:    database.ErrForeignKeyViolation,
+			column:  "created_by",
+			message: "The value of created_by does not refer to an existing record",
+		},
+		{
+			name: "row still referenced",
+			pgErr: &pgconn.PgError{Code: "23503", TableName: "user_roles", ConstraintName: "fk_user_roles_role",
+				Detail: `Key (id)=(3) is still referenced from table "user_roles".`},
+			kind:    database.ErrForeignKeyViolation,
+			column:  "id",
+			message: "The record is still referenced by user_roles",
+		},
+		{
+			name:    "not null",
+			pgErr:   &pgconn.PgError{Code: "23502", TableName: "users", ColumnName: "email"},
+			kind:    database.ErrNotNullViolation,
+			column:  "email",
+			message: "The value of email is required",
+		},
+		{
+			name:    "check",
+			pgErr:   &pgconn.PgError{Code: "23514", TableName: "users", ConstraintName: "chk_users_user_type"},
+			kind:    database.ErrCheckViolation,
+			message: "A value is rejected by the chk_users_user_type constraint",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := database.TranslateConstraintError(tt.pgErr)
+
+			var constraintErr *database.ConstraintError
+			require.ErrorAs(t, err, &constraintErr)
+			assert.ErrorIs(t, err, tt.kind)
+			assert.Equal(t, tt.column, constraintErr.Column)
+			assert.Equal(t, tt.message, constraintErr.Message())
+		})
+	}
+
+	// The other errors are left unchanged
+	uniqueErr := &pgconn.PgError{Code: "23505"}
+	assert.Same(t, uniqueErr, database.TranslateConstraintError(uniqueErr))
+}
+
+func TestServerError_ConstraintViolation(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"foreign key", &pgconn.PgError{Code: "23503", Detail: `Key (created_by)=(42) is not present in table "users".`}, http.StatusConflict},
+		{"not null", &pgconn.PgError{Code: "23502", ColumnName: "email"}, http.StatusBadRequest},
+		{"check", &pgconn.PgError{Code: "23514", ConstraintName: "chk_users_user_type"}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("failed to update user: %w", database.TranslateConstraintError(tt.err))
+			var constraintErr *database.ConstraintError
+			require.True(t, errors.As(err, &constraintErr))
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.PATCH("/users/2", func(c *gin.Context) {
+				httputil.ServerError(c, "Failed to update user", err)
+			})
+
+			req, _ := http.NewRequest("PATCH", "/users/2", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), constraintErr.Message())
+			assert.NotContains(t, w.Body.String(), "SQLSTATE")
+		})
+	}
+}
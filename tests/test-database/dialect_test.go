@@ -0,0 +1,138 @@
+package test_database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+)
+
+func TestOpenDialector(t *testing.T) {
+	defer func() { database.DBDialect = "" }()
+
+	tests := []struct {
+		dialect string
+		name    string
+		wantErr bool
+	}{
+		{"", "postgres", false},
+		{"postgres", "postgres", false},
+		{"SQLite", "sqlite", false},
+		{"mysql", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			database.DBDialect = tt.dialect
+			dialector, err := database.OpenDialector()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "unsupported database dialect")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.name, dialector.Name())
+		})
+	}
+}
+
+// openSQLiteDialect opens an SQLite database through the dialect selection of the application,
+// and creates the users, roles and user_roles tables.
+func openSQLiteDialect(t *testing.T) *gorm.DB {
+	database.DBDialect = database.DialectSQLite
+	database.DBName = filepath.Join(t.TempDir(), "dialect.db")
+	database.DBSchema = ""
+	database.DBLog = "SILENT"
+	t.Cleanup(func() { database.DBDialect = "" })
+
+	dialector, err := database.OpenDialector()
+	require.NoError(t, err)
+	db, err := gorm.Open(dialector, database.NewGormConfig())
+	require.NoError(t, err)
+
+	for _, stmt := range []string{
+		`CREATE TABLE users (
+			id INTEGER PRIMARY KEY, tenant_id INTEGER NOT NULL DEFAULT 1, username TEXT NOT NULL, email TEXT NOT NULL,
+			metadata TEXT NOT NULL DEFAULT '{}', deleted_at DATETIME)`,
+		`CREATE TABLE roles (id INTEGER PRIMARY KEY, name TEXT NOT NULL, is_default BOOLEAN NOT NULL DEFAULT false)`,
+		`CREATE TABLE user_roles (user_id INTEGER, role_id INTEGER, expires_at DATETIME)`,
+		`INSERT INTO users (id, username, email, metadata) VALUES
+			(1, 'admin', 'admin@mygmail.com', '{"crmId":"C-1"}'),
+			(2, 'user', 'user@mygmail.com', '{"crmId":"C-2","hr.id":"E-7"}')`,
+		`INSERT INTO roles (id, name, is_default) VALUES (1, 'ROLE_USER', true), (2, 'ROLE_ADMIN', false)`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES (1, 2), (2, 1)`,
+	} {
+		require.NoError(t, db.Exec(stmt).Error)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+// TestRepositories_SQLite runs the repository queries on SQLite, to check that they stay portable across dialects.
+func TestRepositories_SQLite(t *testing.T) {
+	db := openSQLiteDialect(t)
+	userRepo := repository.NewUserRepository()
+	roleRepo := repository.NewRoleRepository()
+
+	t.Run("user by ID for update", func(t *testing.T) {
+		user, err := userRepo.GetUserByIDForUpdate(db, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "admin", user.Username)
+	})
+
+	t.Run("user by username", func(t *testing.T) {
+		user, err := userRepo.GetUserByUsername(db, "ADMIN")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), user.ID)
+	})
+
+	t.Run("users by IDs", func(t *testing.T) {
+		users, missing, err := userRepo.GetUsersByIDs(db, []int64{2, 3, 1}, true)
+		require.NoError(t, err)
+		require.Len(t, users, 2)
+		assert.Equal(t, []int64{2, 1}, []int64{users[0].ID, users[1].ID})
+		assert.Equal(t, []int64{3}, missing)
+	})
+
+	t.Run("users by metadata", func(t *testing.T) {
+		users, err := userRepo.GetUsersByMetadata(db, "crmId", "C-2")
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, "user", users[0].Username)
+		assert.Equal(t, entity.UserMetadata{"crmId": "C-2", "hr.id": "E-7"}, users[0].Metadata)
+
+		// A key holding a dot is not read as a nested path
+		users, err = userRepo.GetUsersByMetadata(db, "hr.id", "E-7")
+		require.NoError(t, err)
+		assert.Len(t, users, 1)
+
+		users, err = userRepo.GetUsersByMetadata(db, "crmId", "C-3")
+		require.NoError(t, err)
+		assert.Empty(t, users)
+	})
+
+	t.Run("roles by names", func(t *testing.T) {
+		roles, err := roleRepo.GetRolesByNames(db, []string{"role_admin", "ROLE_AUDITOR"})
+		require.NoError(t, err)
+		require.Len(t, roles, 1)
+		assert.Equal(t, "ROLE_ADMIN", roles[0].Name)
+	})
+
+	t.Run("default roles", func(t *testing.T) {
+		roles, err := roleRepo.GetDefaultRoles(db)
+		require.NoError(t, err)
+		require.Len(t, roles, 1)
+		assert.Equal(t, "ROLE_USER", roles[0].Name)
+	})
+}
@@ -0,0 +1,85 @@
+package test_database
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+)
+
+// openDatabase opens an SQLite database with the GORM configuration of the application,
+// and creates the users and roles tables.
+func openDatabase(t *testing.T, prepareStmt string) *gorm.DB {
+	database.DBSchema = ""
+	database.DBLog = "SILENT"
+	database.DBPrepareStmt = prepareStmt
+
+	dsn := fmt.Sprintf("file:%s", filepath.Join(t.TempDir(), "prepare-stmt.db"))
+	db, err := gorm.Open(sqlite.Open(dsn), database.NewGormConfig())
+	require.NoError(t, err)
+
+	for _, stmt := range []string{
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, username TEXT NOT NULL, deleted_at DATETIME)`,
+		`CREATE TABLE roles (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`,
+		`CREATE TABLE user_roles (user_id INTEGER, role_id INTEGER, expires_at DATETIME)`,
+		`INSERT INTO users (id, username) VALUES (1, 'admin'), (2, 'user')`,
+	} {
+		require.NoError(t, db.Exec(stmt).Error)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+func TestPrepareStmt_ReusesStatements(t *testing.T) {
+	db := openDatabase(t, "")
+	repo := repository.NewUserRepository()
+
+	preparedDB, ok := db.ConnPool.(*gorm.PreparedStmtDB)
+	require.True(t, ok, "the prepared statement cache must be enabled by default")
+
+	// The first lookups prepare the statements
+	_, err := repo.GetUserByID(db, 1)
+	require.NoError(t, err)
+	_, err = repo.GetUserByUsername(db, "admin")
+	require.NoError(t, err)
+	prepared := len(preparedDB.Stmts.Keys())
+	assert.NotZero(t, prepared)
+
+	// The identical lookups, with other arguments and inside a transaction, reuse them
+	for i := 0; i < 10; i++ {
+		_, err = repo.GetUserByID(db, 2)
+		require.NoError(t, err)
+		_, err = repo.GetUserByUsername(db, "user")
+		require.NoError(t, err)
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		_, err := repo.GetUserByID(tx, 1)
+		return err
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, prepared, len(preparedDB.Stmts.Keys()))
+}
+
+func TestPrepareStmt_Disabled(t *testing.T) {
+	db := openDatabase(t, "FALSE")
+
+	_, ok := db.ConnPool.(*gorm.PreparedStmtDB)
+	assert.False(t, ok)
+
+	_, err := repository.NewUserRepository().GetUserByID(db, 1)
+	assert.NoError(t, err)
+}
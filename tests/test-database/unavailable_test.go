@@ -0,0 +1,92 @@
+package test_database
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/transaction"
+)
+
+func TestRequireDB_NotInitialized(t *testing.T) {
+	database.SetPostgres(nil)
+
+	_, err := database.RequireDB(context.Background())
+	assert.ErrorIs(t, err, database.ErrDatabaseUnavailable)
+	_, err = database.RequirePostgres()
+	assert.ErrorIs(t, err, database.ErrDatabaseUnavailable)
+
+	// The services return the error unchanged, so the handlers can recognize it
+	_, _, err = service.NewConsumerService(repository.NewConsumerRepository()).GetAllConsumers(context.Background(), 1, 10)
+	assert.ErrorIs(t, err, database.ErrDatabaseUnavailable)
+}
+
+func TestDatabaseUnavailable_Endpoints(t *testing.T) {
+	database.SetPostgres(nil)
+
+	users := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository()))
+	consumers := handler.NewConsumerHandler(service.NewConsumerService(repository.NewConsumerRepository()))
+	auth := handler.NewAuthHandler(service.NewAuthService(clock.New()), nil)
+	audit := handler.NewAuditLogHandler(service.NewAuditLogService(repository.NewAuditLogRepository()))
+	history := handler.NewLoginAttemptHandler(service.NewLoginAttemptService(repository.NewLoginAttemptRepository()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		ctx := metacontext.InjectUserInformationMeta(c.Request.Context(), metacontext.UserInformationMeta{UserID: 1, Username: "admin"})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	})
+	router.POST("/auth/login", auth.Login)
+	router.POST("/auth/refresh-token", auth.RefreshToken)
+	router.GET("/consumers", consumers.GetAllConsumers)
+	router.GET("/consumers/:id", consumers.GetConsumerByID)
+	router.GET("/users", users.GetUsers)
+	router.PATCH("/users/:id/status", users.UpdateUserStatus)
+	router.DELETE("/users/:id/purge", users.PurgeUser)
+	router.GET("/users/:id/login-history", history.GetLoginHistory)
+	router.GET("/audit", audit.GetAuditLogs)
+	router.POST("/users/batch-get", transaction.Transaction(), users.BatchGetUsers)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"POST", "/auth/login", `{"username":"admin","password":"P@ssw0rd"}`},
+		{"POST", "/auth/refresh-token", `{"refreshToken":"d3b07384-d9a7-4f3b-8a1e-2c6f6b1c9a10"}`},
+		{"GET", "/consumers", ""},
+		{"GET", "/consumers/1", ""},
+		{"GET", "/users", ""},
+		{"PATCH", "/users/2/status", `{"isEnabled":false}`},
+		{"DELETE", "/users/2/purge", ""},
+		{"GET", "/users/2/login-history", ""},
+		{"GET", "/audit", ""},
+		{"POST", "/users/batch-get", `{"ids":[1,2]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), "The database is unavailable, retry later")
+			assert.NotContains(t, w.Body.String(), database.ErrDatabaseUnavailable.Error())
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package test_database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+)
+
+// newUser returns a user of the default tenant with the given username and email, ready to be created.
+func newUser(username string, email string) entity.User {
+	deleted := false
+	return entity.User{TenantID: 1, Username: username, Password: "secret", Email: email, Firstname: "User", UserType: "USER_ACCOUNT", IsDeleted: &deleted}
+}
+
+func TestUserUniqueIndexes_SoftDeletedUserReleasesUsernameAndEmail(t *testing.T) {
+	db := openConstrainedDatabase(t)
+	require.NoError(t, database.MigrateUserUniqueIndexes(db))
+	repo := repository.NewUserRepository()
+
+	// The username and the email of a user that is not deleted are taken
+	_, err := repo.CreateUser(db, newUser("user", "other@mygmail.com"))
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+	_, err = repo.CreateUser(db, newUser("other", "user@mygmail.com"))
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+
+	// Once the user is soft-deleted, a new user may reuse them
+	user, err := repo.GetUserByID(db, 2)
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteUser(db, user, 1))
+
+	created, err := repo.CreateUser(db, newUser("user", "user@mygmail.com"))
+	require.NoError(t, err)
+	assert.NotEqual(t, int64(2), created.ID)
+
+	// The new user takes them in turn
+	_, err = repo.CreateUser(db, newUser("user", "another@mygmail.com"))
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+
+	// The migration can run again on the same table
+	assert.NoError(t, database.MigrateUserUniqueIndexes(db))
+}
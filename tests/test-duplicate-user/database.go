@@ -0,0 +1,34 @@
+package test_duplicate_user
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with the users of two tenants, and makes the services use it instead of PostgreSQL.
+// In the tenant 1, alice (2) and asmyth (3) share a gmail inbox, bob.marley (4) and bobmarley (5) share a full name
+// with a similar username, while their namesake zz_top99 (6) does not. The deleted cking (7) would be a duplicate
+// of c.king (8), and dave.lee (9) and davelee (10) have distinct inboxes outside of gmail.
+// The tenant 2 has another alice (11) sharing the inbox of the alice of the tenant 1.
+func setupDatabase(t *testing.T) *gorm.DB {
+	return testdb.Open(t,
+		`INSERT INTO roles (name) VALUES ('ROLE_USER'), ('ROLE_ADMIN')`,
+		`INSERT INTO users (tenant_id, username, email, firstname, lastname, password, is_enabled, user_type) VALUES
+			(1, 'admin', 'admin@mygmail.com', 'Admin', NULL, '!', true, 'USER_ACCOUNT'),
+			(1, 'alice', 'Alice.Smith@gmail.com', 'Alice', 'Smith', '!', true, 'USER_ACCOUNT'),
+			(1, 'asmyth', 'alicesmith+promo@googlemail.com', 'Alicia', 'Smyth', '!', true, 'USER_ACCOUNT'),
+			(1, 'bob.marley', 'bob@example.com', 'Bob', 'Marley', '!', true, 'USER_ACCOUNT'),
+			(1, 'bobmarley', 'bmarley@example.org', 'bob', 'MARLEY', '!', true, 'USER_ACCOUNT'),
+			(1, 'zz_top99', 'zz@example.net', 'Bob', 'Marley', '!', true, 'USER_ACCOUNT'),
+			(1, 'cking', 'carol@gmail.com', 'Carol', 'King', '!', true, 'USER_ACCOUNT'),
+			(1, 'c.king', 'c.a.r.o.l@gmail.com', 'Carol', 'King', '!', true, 'USER_ACCOUNT'),
+			(1, 'dave.lee', 'dave.lee@corp.example', 'Dave', 'Lee', '!', true, 'USER_ACCOUNT'),
+			(1, 'davelee', 'davelee@corp.example', 'David', 'Lee', '!', true, 'USER_ACCOUNT'),
+			(2, 'alice', 'alicesmith@gmail.com', 'Alice', 'Smith', '!', true, 'USER_ACCOUNT')`,
+		`UPDATE users SET is_deleted = true, deleted_by = 1, deleted_at = CURRENT_TIMESTAMP WHERE id = 7`,
+		`INSERT INTO user_roles (user_id, role_id) SELECT id, 1 FROM users`,
+	)
+}
@@ -0,0 +1,141 @@
+package test_duplicate_user
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	textutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/text-util"
+)
+
+// adminContext returns a context authenticated as the admin of the tenant.
+func adminContext(tenantID int64) context.Context {
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: 1, Username: "admin", Roles: []string{"ROLE_ADMIN"}, TenantID: tenantID,
+	})
+	return metacontext.InjectTenantID(ctx, tenantID)
+}
+
+// userIDs returns the IDs of the users of the cluster.
+func userIDs(cluster entity.DuplicateUserCluster) []int64 {
+	var ids []int64
+	for _, user := range cluster.Users {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+func TestGetDuplicateUsers_Clusters(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	clusters, total, err := s.GetDuplicateUsers(adminContext(1), 1, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+	require.Len(t, clusters, 2)
+
+	// The same gmail inbox, whatever the dots, the plus suffix and the domain alias
+	assert.Equal(t, entity.DuplicateReasonEmail, clusters[0].Reason)
+	assert.Equal(t, "alicesmith@gmail.com", clusters[0].Key)
+	assert.Equal(t, int64(1), clusters[0].TenantID)
+	assert.InDelta(t, 0.9, clusters[0].Confidence, 0.001)
+	assert.Equal(t, []int64{2, 3}, userIDs(clusters[0]))
+
+	// The same full name, whatever the case, with similar usernames, the namesake zz_top99 is left out
+	assert.Equal(t, entity.DuplicateReasonName, clusters[1].Reason)
+	assert.Equal(t, "bob marley", clusters[1].Key)
+	assert.InDelta(t, textutil.Similarity("bob.marley", "bobmarley")*0.8, clusters[1].Confidence, 0.001)
+	assert.Less(t, clusters[1].Confidence, clusters[0].Confidence)
+	assert.Equal(t, []int64{4, 5}, userIDs(clusters[1]))
+
+	// The pages hold the clusters in the same order
+	clusters, total, err = s.GetDuplicateUsers(adminContext(1), 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, clusters, 1)
+	assert.Equal(t, "bob marley", clusters[0].Key)
+
+	// The alice of the tenant 2 is not a duplicate of the one of the tenant 1
+	clusters, total, err = s.GetDuplicateUsers(adminContext(2), 1, 10)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Empty(t, clusters)
+}
+
+func TestGetDuplicateUsers_ExcludesDeletedUsers(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	// The deleted cking shares both the inbox and the name of c.king, yet no cluster holds them
+	clusters, _, err := s.GetDuplicateUsers(adminContext(1), 1, 10)
+	require.NoError(t, err)
+	for _, cluster := range clusters {
+		assert.NotContains(t, userIDs(cluster), int64(7))
+		assert.NotContains(t, userIDs(cluster), int64(8))
+	}
+
+	// Once deleted, a user leaves its cluster, and a cluster of one user is no longer reported
+	require.NoError(t, db.Exec(`UPDATE users SET is_deleted = true, deleted_by = 1, deleted_at = CURRENT_TIMESTAMP WHERE id = 3`).Error)
+	clusters, total, err := s.GetDuplicateUsers(adminContext(1), 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, clusters, 1)
+	assert.Equal(t, "bob marley", clusters[0].Key)
+}
+
+func TestGetDuplicateUsers_Handler(t *testing.T) {
+	setupDatabase(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository()))
+	router.GET("/api/v1/admin/users/duplicates", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(adminContext(1))
+		h.GetDuplicateUsers(c)
+	})
+
+	req, _ := http.NewRequest("GET", "/api/v1/admin/users/duplicates?limit=1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Data       []entity.DuplicateUserCluster `json:"data"`
+		Pagination struct {
+			Total int64 `json:"total"`
+		} `json:"pagination"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, int64(2), resp.Pagination.Total)
+	require.Len(t, resp.Data, 1)
+	assert.Equal(t, "alicesmith@gmail.com", resp.Data[0].Key)
+	assert.Equal(t, []int64{2, 3}, userIDs(resp.Data[0]))
+	assert.NotContains(t, w.Body.String(), "password")
+
+	req, _ = http.NewRequest("GET", "/api/v1/admin/users/duplicates?page=0", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNormalizeEmailAndSimilarity(t *testing.T) {
+	assert.Equal(t, "johndoe@gmail.com", textutil.NormalizeEmail("John.Doe+news@GoogleMail.com"))
+	assert.Equal(t, "john.doe+news@example.com", textutil.NormalizeEmail("John.Doe+news@Example.com"))
+	assert.Equal(t, "not-an-email", textutil.NormalizeEmail("not-an-email"))
+
+	// The values of the similarity function of pg_trgm
+	assert.InDelta(t, 1.0, textutil.Similarity("word", "WORD"), 0.0001)
+	assert.InDelta(t, 0.363636, textutil.Similarity("word", "two words"), 0.0001)
+	assert.Zero(t, textutil.Similarity("abc", "xyz"))
+	assert.Zero(t, textutil.Similarity("", "abc"))
+}
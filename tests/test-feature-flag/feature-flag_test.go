@@ -0,0 +1,136 @@
+package test_feature_flag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/featureflag"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+)
+
+// useFlags makes the application read the feature flags from the given list until the end of the test.
+func useFlags(t *testing.T, spec string) {
+	p, err := featureflag.NewStaticProvider(spec)
+	require.NoError(t, err)
+	featureflag.SetProvider(p)
+	t.Cleanup(func() { featureflag.SetProvider(nil) })
+}
+
+func TestStaticProvider(t *testing.T) {
+	p, err := featureflag.NewStaticProvider("enforce_2fa=false, 2:enforce_2fa=true, Cookie_Auth=1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		tenantID int64
+		flag     string
+		value    bool
+		defined  bool
+	}{
+		{"global value", 1, featureflag.Enforce2FA, false, true},
+		{"tenant override", 2, featureflag.Enforce2FA, true, true},
+		{"global value of another tenant", 3, featureflag.Enforce2FA, false, true},
+		{"case-insensitive name", 1, featureflag.CookieAuth, true, true},
+		{"undefined flag", 1, featureflag.StrictPasswordPolicy, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, defined := p.Lookup(tt.tenantID, tt.flag)
+			assert.Equal(t, tt.value, value)
+			assert.Equal(t, tt.defined, defined)
+		})
+	}
+
+	for _, spec := range []string{"enforce_2fa", "enforce_2fa=maybe", "x:enforce_2fa=true", "0:enforce_2fa=true"} {
+		_, err := featureflag.NewStaticProvider(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestEnabled_TenantOfTheRequest(t *testing.T) {
+	useFlags(t, "strict_password_policy=true,2:strict_password_policy=false")
+
+	// Without a tenant the default tenant is used
+	assert.True(t, featureflag.Enabled(context.Background(), featureflag.StrictPasswordPolicy))
+	assert.True(t, featureflag.Enabled(metacontext.InjectTenantID(context.Background(), 1), featureflag.StrictPasswordPolicy))
+	assert.False(t, featureflag.Enabled(metacontext.InjectTenantID(context.Background(), 2), featureflag.StrictPasswordPolicy))
+	assert.False(t, featureflag.Enabled(context.Background(), "unknown_flag"))
+}
+
+func TestGetFlags_Handler(t *testing.T) {
+	useFlags(t, "cookie_auth=true,2:enforce_2fa=true")
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(metacontext.InjectTenantID(c.Request.Context(), 2))
+		c.Next()
+	})
+	router.GET("/admin/flags", handler.NewFeatureFlagHandler().GetFlags)
+
+	req, _ := http.NewRequest("GET", "/admin/flags", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data handler.FeatureFlagsResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, int64(2), body.Data.TenantID)
+	assert.Equal(t, map[string]bool{
+		featureflag.Enforce2FA:           true,
+		featureflag.StrictPasswordPolicy: false,
+		featureflag.CookieAuth:           true,
+	}, body.Data.Flags)
+}
+
+func TestJwtValidation_CookieMode(t *testing.T) {
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	service.JWTSecret = "test-secret"
+	gin.SetMode(gin.TestMode)
+
+	token, err := service.GenerateJWTTokenWithHS256(entity.User{ID: 7, Username: "admin", Roles: []entity.Role{{Name: "ROLE_ADMIN"}}}, time.Now())
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(authorization.JwtValidation())
+	router.GET("/me", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name   string
+		flags  string
+		status int
+	}{
+		{"cookie accepted in cookie mode", "cookie_auth=true", http.StatusOK},
+		{"cookie ignored by default", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFlags(t, tt.flags)
+
+			req, _ := http.NewRequest("GET", "/me", nil)
+			req.AddCookie(&http.Cookie{Name: authorization.AccessTokenCookie, Value: token})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package test_field_naming
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/customtype"
+	fieldutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/field-util"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// useFieldNaming sets the field naming of the responses for the test.
+func useFieldNaming(t *testing.T, naming fieldutil.FieldNaming) {
+	fieldutil.SetFieldNaming(naming)
+	t.Cleanup(func() { fieldutil.SetFieldNaming(fieldutil.CamelCase) })
+}
+
+func getDummyConsumer() entity.Consumer {
+	birthDate := customtype.Date{Time: time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)}
+	return entity.Consumer{
+		ID:        "dummy-id-1",
+		Fullname:  "Dummy Consumer",
+		Username:  "dummyuser1",
+		Email:     "dummy1@example.com",
+		Phone:     "081234567890",
+		Address:   "Jakarta",
+		BirthDate: &birthDate,
+		Status:    "active",
+		CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		UpdatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+// setupRouter registers routes answering a consumer, a page of consumers, the selected fields of a consumer,
+// a metadata change and a user to create, bound from the request body.
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/consumer", func(c *gin.Context) {
+		httputil.Success(c, "Consumer retrieved successfully", getDummyConsumer())
+	})
+	router.GET("/consumers", func(c *gin.Context) {
+		httputil.SuccessWithPagination(c, "All consumers retrieved successfully", []entity.Consumer{getDummyConsumer()}, httputil.NewPagination(1, 10, 1))
+	})
+	router.GET("/consumer/fields", func(c *gin.Context) {
+		fields, err := fieldutil.ParseFields(c.Query("fields"), entity.ConsumerFields)
+		if err != nil {
+			httputil.BadRequest(c, "Invalid fields", err.Error())
+			return
+		}
+		data, _ := fieldutil.SelectFields(getDummyConsumer(), fields)
+		httputil.Success(c, "Consumer retrieved successfully", data)
+	})
+	router.GET("/metadata", func(c *gin.Context) {
+		value := "CRM-1"
+		httputil.Success(c, "Metadata retrieved successfully", map[string]any{
+			"metadata": entity.UserMetadata{"crmId": "CRM-1", "hr_number": "HR-1"},
+			"changes":  []entity.UserMetadataChange{{Key: "crmId", New: &value}},
+		})
+	})
+	router.POST("/users", func(c *gin.Context) {
+		var req entity.UserCreateRequest
+		if err := httputil.BindJSON(c, &req); err != nil {
+			httputil.BadRequest(c, "Invalid request body", err.Error())
+			return
+		}
+		if err := req.Validate(); err != nil {
+			httputil.UnprocessableEntityMap(c, "Failed to create user", validation.FormatValidationErrors(err))
+			return
+		}
+		httputil.Created(c, "User created successfully", "", req)
+	})
+	return router
+}
+
+// request sends the request and decodes the JSON body of the response.
+func request(t *testing.T, router *gin.Engine, method string, path string, body string) (int, map[string]any) {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &decoded), w.Body.String())
+	return w.Code, decoded
+}
+
+func TestFieldNaming_CamelCase(t *testing.T) {
+	useFieldNaming(t, fieldutil.CamelCase)
+	router := setupRouter()
+
+	code, body := request(t, router, "GET", "/consumers", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{"page": 1.0, "limit": 10.0, "total": 1.0, "totalPages": 1.0}, body["pagination"])
+
+	consumer := body["data"].([]any)[0].(map[string]any)
+	assert.Equal(t, "1990-05-17", consumer["birthDate"])
+	assert.Equal(t, "2025-01-02T03:04:05Z", consumer["createdAt"])
+	assert.Contains(t, consumer, "updatedAt")
+}
+
+func TestFieldNaming_SnakeCase(t *testing.T) {
+	useFieldNaming(t, fieldutil.SnakeCase)
+	router := setupRouter()
+
+	code, body := request(t, router, "GET", "/consumers", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{"page": 1.0, "limit": 10.0, "total": 1.0, "total_pages": 1.0}, body["pagination"])
+
+	// The values are encoded the same, e.g. the dates and the times keep their own format
+	consumer := body["data"].([]any)[0].(map[string]any)
+	assert.Equal(t, "1990-05-17", consumer["birth_date"])
+	assert.Equal(t, "2025-01-02T03:04:05Z", consumer["created_at"])
+	assert.Contains(t, consumer, "updated_at")
+	assert.NotContains(t, consumer, "birthDate")
+	assert.NotContains(t, consumer, "createdAt")
+	assert.Len(t, consumer, len(entity.ConsumerFields))
+}
+
+func TestFieldNaming_SameResponse(t *testing.T) {
+	router := setupRouter()
+
+	useFieldNaming(t, fieldutil.CamelCase)
+	_, camel := request(t, router, "GET", "/consumer", "")
+
+	useFieldNaming(t, fieldutil.SnakeCase)
+	_, snake := request(t, router, "GET", "/consumer", "")
+
+	// Every field is there under its snake case name, with the same value
+	camelData := camel["data"].(map[string]any)
+	snakeData := snake["data"].(map[string]any)
+	require.Len(t, snakeData, len(camelData))
+	for name, value := range camelData {
+		assert.Equal(t, value, snakeData[fieldutil.ToSnakeCase(name)], name)
+	}
+	assert.Equal(t, camel["message"], snake["message"])
+}
+
+func TestFieldNaming_MapKeysKept(t *testing.T) {
+	useFieldNaming(t, fieldutil.SnakeCase)
+	router := setupRouter()
+
+	// The keys of the metadata are data, only the fields of the structs are renamed
+	_, body := request(t, router, "GET", "/metadata", "")
+	data := body["data"].(map[string]any)
+	assert.Equal(t, map[string]any{"crmId": "CRM-1", "hr_number": "HR-1"}, data["metadata"])
+	assert.Equal(t, []any{map[string]any{"key": "crmId", "old": nil, "new": "CRM-1"}}, data["changes"])
+}
+
+func TestFieldNaming_SelectFields(t *testing.T) {
+	useFieldNaming(t, fieldutil.SnakeCase)
+	router := setupRouter()
+
+	// The fields are requested with either naming, and returned in the field naming of the responses
+	code, body := request(t, router, "GET", "/consumer/fields?fields=birth_date,createdAt,username", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{"birth_date": "1990-05-17", "created_at": "2025-01-02T03:04:05Z", "username": "dummyuser1"}, body["data"])
+
+	code, _ = request(t, router, "GET", "/consumer/fields?fields=birth_day", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestBindJSON_BothNamings(t *testing.T) {
+	router := setupRouter()
+
+	for _, naming := range []fieldutil.FieldNaming{fieldutil.CamelCase, fieldutil.SnakeCase} {
+		useFieldNaming(t, naming)
+
+		// The request bodies are bound whatever the naming of their fields
+		for _, body := range []string{
+			`{"username":"jane","password":"P@ssw0rd!","email":"jane@example.com","firstName":"Jane","lastName":"Doe","userType":"USER_ACCOUNT"}`,
+			`{"username":"jane","password":"P@ssw0rd!","email":"jane@example.com","first_name":"Jane","last_name":"Doe","user_type":"USER_ACCOUNT"}`,
+		} {
+			code, resp := request(t, router, "POST", "/users", body)
+			require.Equal(t, http.StatusCreated, code, resp)
+
+			data := resp["data"].(map[string]any)
+			assert.Equal(t, "Jane", data[fieldutil.FieldName("firstName")], naming)
+			assert.Equal(t, "Doe", data[fieldutil.FieldName("lastName")], naming)
+			assert.Equal(t, "USER_ACCOUNT", data[fieldutil.FieldName("userType")], naming)
+		}
+	}
+}
+
+func TestBindJSON_ValidationErrors(t *testing.T) {
+	useFieldNaming(t, fieldutil.SnakeCase)
+	router := setupRouter()
+
+	// The fields of the validation errors are named in the field naming of the responses
+	code, body := request(t, router, "POST", "/users", `{"username":"jane","password":"P@ssw0rd!","email":"jane@example.com","user_type":"USER_ACCOUNT"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, []any{map[string]any{"field": "first_name", "message": "first_name is required"}}, body["error"])
+
+	// A malformed body is still rejected
+	code, _ = request(t, router, "POST", "/users", `{"username":`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestToSnakeCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"id":            "id",
+		"totalPages":    "total_pages",
+		"createdAt":     "created_at",
+		"ID":            "id",
+		"userID":        "user_id",
+		"HTTPStatus":    "http_status",
+		"already_snake": "already_snake",
+	} {
+		assert.Equal(t, expected, fieldutil.ToSnakeCase(name), name)
+	}
+}
+
+func TestParseFieldNaming(t *testing.T) {
+	naming, err := fieldutil.ParseFieldNaming("")
+	assert.NoError(t, err)
+	assert.Equal(t, fieldutil.CamelCase, naming)
+
+	naming, err = fieldutil.ParseFieldNaming("SNAKE_CASE")
+	assert.NoError(t, err)
+	assert.Equal(t, fieldutil.SnakeCase, naming)
+
+	_, err = fieldutil.ParseFieldNaming("kebab-case")
+	assert.Error(t, err)
+}
@@ -0,0 +1,34 @@
+package test_group
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with the tables of the migration and makes the services use it
+// instead of PostgreSQL. It holds the admin alice (ID 1), bob (ID 2) and carol (ID 3) with the ROLE_USER role,
+// dave (ID 4) of the other tenant and the deleted erin (ID 5), without any group.
+func setupDatabase(t *testing.T) *gorm.DB {
+	db := testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_ADMIN', false)`,
+		`INSERT INTO users (tenant_id, username, password, email, firstname, is_deleted, deleted_at, is_enabled, is_account_non_expired,
+			is_account_non_locked, is_credentials_non_expired, user_type) VALUES
+			(1, 'alice', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'alice@mygmail.com', 'Alice', false, NULL, true, true, true, true, 'USER_ACCOUNT'),
+			(1, 'bob', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'bob@mygmail.com', 'Bob', false, NULL, true, true, true, true, 'USER_ACCOUNT'),
+			(1, 'carol', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'carol@mygmail.com', 'Carol', false, NULL, true, true, true, true, 'USER_ACCOUNT'),
+			(2, 'dave', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'dave@mygmail.com', 'Dave', false, NULL, true, true, true, true, 'USER_ACCOUNT'),
+			(1, 'erin', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'erin@mygmail.com', 'Erin', true, CURRENT_TIMESTAMP, true, true, true, true, 'USER_ACCOUNT')`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES (1, 2), (2, 1), (3, 1), (4, 1), (5, 1)`,
+	)
+
+	// Record the actor of the writes like the PostgreSQL connection does
+	if err := database.RegisterAuditCallbacks(db); err != nil {
+		t.Fatalf("failed to register the audit callbacks: %v", err)
+	}
+
+	return db
+}
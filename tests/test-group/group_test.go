@@ -0,0 +1,312 @@
+package test_group
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+)
+
+const (
+	aliceID int64 = 1
+	bobID   int64 = 2
+	carolID int64 = 3
+	daveID  int64 = 4
+	erinID  int64 = 5
+)
+
+// adminContext returns a context authenticated as the admin alice of the given tenant.
+func adminContext(tenantID int64) context.Context {
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: aliceID, Username: "alice", Roles: []string{"ROLE_ADMIN"}, TenantID: tenantID,
+	})
+	return metacontext.InjectTenantID(ctx, tenantID)
+}
+
+func aliceContext() context.Context {
+	return adminContext(metacontext.DefaultTenantID)
+}
+
+func description(value string) *string {
+	return &value
+}
+
+func TestGroups_CRUD(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewGroupService(repository.NewGroupRepository())
+
+	support, err := s.CreateGroup(aliceContext(), entity.GroupRequest{Name: "Support EMEA", Description: description("First line")})
+	require.NoError(t, err)
+	assert.Equal(t, metacontext.DefaultTenantID, support.TenantID)
+	require.NotNil(t, support.CreatedBy)
+	assert.Equal(t, aliceID, *support.CreatedBy)
+	_, err = s.CreateGroup(aliceContext(), entity.GroupRequest{Name: "billing"})
+	require.NoError(t, err)
+
+	// The names are unique within a tenant, compared case-insensitively
+	_, err = s.CreateGroup(aliceContext(), entity.GroupRequest{Name: "SUPPORT emea"})
+	assert.ErrorIs(t, err, service.ErrGroupAlreadyExists)
+	_, err = s.UpdateGroup(aliceContext(), support.ID, entity.GroupRequest{Name: "Billing"})
+	assert.ErrorIs(t, err, service.ErrGroupAlreadyExists)
+
+	// Another tenant may reuse the name, and does not see the groups of the default tenant
+	other, err := s.CreateGroup(adminContext(2), entity.GroupRequest{Name: "Support EMEA"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), other.TenantID)
+	_, err = s.GetGroupByID(adminContext(2), support.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// A group keeps its name when only its description changes
+	updated, err := s.UpdateGroup(aliceContext(), support.ID, entity.GroupRequest{Name: "Support EMEA"})
+	require.NoError(t, err)
+	assert.Nil(t, updated.Description)
+	updated, err = s.UpdateGroup(aliceContext(), support.ID, entity.GroupRequest{Name: "Support Europe", Description: description("First line")})
+	require.NoError(t, err)
+	assert.Equal(t, "Support Europe", updated.Name)
+
+	groups, total, err := s.GetGroups(aliceContext(), 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, groups, 2)
+	assert.Equal(t, "billing", groups[0].Name)
+	assert.Equal(t, "Support Europe", groups[1].Name)
+
+	// An empty group is deleted without force
+	require.NoError(t, s.DeleteGroup(aliceContext(), groups[0].ID, false))
+	assert.ErrorIs(t, s.DeleteGroup(aliceContext(), groups[0].ID, false), gorm.ErrRecordNotFound)
+
+	var logs []entity.AuditLog
+	require.NoError(t, db.Where("entity_type = ? AND entity_id = ?", "group", "1").Order("id ASC").Find(&logs).Error)
+	actions := make([]string, 0, len(logs))
+	for _, log := range logs {
+		actions = append(actions, log.Action)
+	}
+	assert.Equal(t, []string{"create_group", "update_group", "update_group"}, actions)
+	require.NotNil(t, logs[2].Details)
+	assert.Equal(t, "Group Support EMEA renamed to Support Europe", *logs[2].Details)
+}
+
+func TestGroups_Members(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewGroupService(repository.NewGroupRepository())
+
+	group, err := s.CreateGroup(aliceContext(), entity.GroupRequest{Name: "Support EMEA"})
+	require.NoError(t, err)
+
+	member, err := s.AddGroupMember(aliceContext(), group.ID, entity.GroupMemberRequest{UserID: bobID, Role: entity.GroupRoleOwner})
+	require.NoError(t, err)
+	assert.Equal(t, "bob", member.User.Username)
+	assert.Equal(t, entity.GroupRoleOwner, member.Role)
+	member, err = s.AddGroupMember(aliceContext(), group.ID, entity.GroupMemberRequest{UserID: carolID})
+	require.NoError(t, err)
+	assert.Equal(t, entity.GroupRoleMember, member.Role)
+
+	// A user is a member once, and only the users of the tenant of the group can be added
+	_, err = s.AddGroupMember(aliceContext(), group.ID, entity.GroupMemberRequest{UserID: bobID})
+	assert.ErrorIs(t, err, service.ErrGroupMemberAlreadyExists)
+	_, err = s.AddGroupMember(aliceContext(), group.ID, entity.GroupMemberRequest{UserID: daveID})
+	assert.ErrorIs(t, err, service.ErrGroupUserNotFound)
+	_, err = s.AddGroupMember(aliceContext(), group.ID, entity.GroupMemberRequest{UserID: erinID})
+	assert.ErrorIs(t, err, service.ErrGroupUserNotFound)
+	_, err = s.AddGroupMember(adminContext(2), group.ID, entity.GroupMemberRequest{UserID: daveID})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	member, err = s.UpdateGroupMember(aliceContext(), group.ID, carolID, entity.GroupMemberRoleRequest{Role: entity.GroupRoleManager})
+	require.NoError(t, err)
+	assert.Equal(t, entity.GroupRoleManager, member.Role)
+	_, err = s.UpdateGroupMember(aliceContext(), group.ID, aliceID, entity.GroupMemberRoleRequest{Role: entity.GroupRoleManager})
+	assert.ErrorIs(t, err, service.ErrGroupMemberNotFound)
+
+	members, total, err := s.GetGroupMembers(aliceContext(), group.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, members, 2)
+	assert.Equal(t, bobID, members[0].User.ID)
+	assert.Equal(t, carolID, members[1].User.ID)
+	assert.Equal(t, entity.GroupRoleManager, members[1].Role)
+
+	// The groups of a user are carried by its next access tokens
+	groupIDs, err := repository.NewGroupRepository().GetGroupIDsByUserID(db, carolID)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{group.ID}, groupIDs)
+
+	require.NoError(t, s.RemoveGroupMember(aliceContext(), group.ID, carolID))
+	assert.ErrorIs(t, s.RemoveGroupMember(aliceContext(), group.ID, carolID), service.ErrGroupMemberNotFound)
+
+	// Every change of the members is told to the downstream services
+	var events []entity.OutboxEvent
+	require.NoError(t, db.Where("aggregate_type = ?", "group").Order("id ASC").Find(&events).Error)
+	types := make([]string, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{entity.OutboxEventGroupMemberAdded, entity.OutboxEventGroupMemberAdded,
+		entity.OutboxEventGroupMemberUpdated, entity.OutboxEventGroupMemberRemoved}, types)
+	var payload entity.GroupEventPayload
+	require.NoError(t, json.Unmarshal([]byte(events[2].Payload), &payload))
+	assert.Equal(t, group.ID, payload.GroupID)
+	require.NotNil(t, payload.UserID)
+	assert.Equal(t, carolID, *payload.UserID)
+	assert.Equal(t, entity.GroupRoleManager, payload.Role)
+}
+
+func TestGroups_DeleteWithMembers(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewGroupService(repository.NewGroupRepository())
+
+	group, err := s.CreateGroup(aliceContext(), entity.GroupRequest{Name: "Support EMEA"})
+	require.NoError(t, err)
+	for _, userID := range []int64{bobID, carolID} {
+		_, err := s.AddGroupMember(aliceContext(), group.ID, entity.GroupMemberRequest{UserID: userID})
+		require.NoError(t, err)
+	}
+
+	// A group with members is only deleted with force
+	assert.ErrorIs(t, s.DeleteGroup(aliceContext(), group.ID, false), service.ErrGroupHasMembers)
+	_, err = s.GetGroupByID(aliceContext(), group.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteGroup(aliceContext(), group.ID, true))
+	var memberships int64
+	require.NoError(t, db.Model(&entity.GroupMembership{}).Count(&memberships).Error)
+	assert.Zero(t, memberships)
+
+	var event entity.OutboxEvent
+	require.NoError(t, db.Where("type = ?", entity.OutboxEventGroupDeleted).First(&event).Error)
+	var payload entity.GroupEventPayload
+	require.NoError(t, json.Unmarshal([]byte(event.Payload), &payload))
+	assert.Equal(t, "Support EMEA", payload.GroupName)
+	assert.Equal(t, []int64{bobID, carolID}, payload.RemovedMembers)
+}
+
+func TestGroups_Handler(t *testing.T) {
+	setupDatabase(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(aliceContext())
+		c.Next()
+	})
+	h := handler.NewGroupHandler(service.NewGroupService(repository.NewGroupRepository()))
+	router.GET("/groups", h.GetGroups)
+	router.POST("/groups", h.CreateGroup)
+	router.DELETE("/groups/:id", pathparam.PathInt64("id"), h.DeleteGroup)
+	router.GET("/groups/:id/members", pathparam.PathInt64("id"), h.GetGroupMembers)
+	router.POST("/groups/:id/members", pathparam.PathInt64("id"), h.AddGroupMember)
+	router.PATCH("/groups/:id/members/:userId", pathparam.PathInt64("id"), pathparam.PathInt64("userId"), h.UpdateGroupMember)
+	uh := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository()))
+	router.GET("/users", uh.GetUsers)
+
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("POST", "/groups", `{"name": "Support EMEA", "description": "First line"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "/groups/1", w.Header().Get("Location"))
+	assert.Equal(t, http.StatusConflict, request("POST", "/groups", `{"name": "support emea"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, request("POST", "/groups", `{"name": ""}`).Code)
+
+	w = request("POST", "/groups/1/members", `{"userId": 2, "role": "manager"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "/groups/1/members/2", w.Header().Get("Location"))
+	assert.Equal(t, http.StatusConflict, request("POST", "/groups/1/members", `{"userId": 2}`).Code)
+	assert.Equal(t, http.StatusNotFound, request("POST", "/groups/1/members", `{"userId": 4}`).Code)
+	assert.Equal(t, http.StatusNotFound, request("POST", "/groups/99/members", `{"userId": 3}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, request("POST", "/groups/1/members", `{"userId": 3, "role": "admin"}`).Code)
+	assert.Equal(t, http.StatusNotFound, request("PATCH", "/groups/1/members/3", `{"role": "owner"}`).Code)
+	assert.Equal(t, http.StatusOK, request("PATCH", "/groups/1/members/2", `{"role": "owner"}`).Code)
+
+	w = request("GET", "/groups/1/members", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var members struct {
+		Data []entity.GroupMemberResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &members))
+	require.Len(t, members.Data, 1)
+	assert.Equal(t, "bob", members.Data[0].User.Username)
+	assert.Equal(t, entity.GroupRoleOwner, members.Data[0].Role)
+
+	// The users are filtered by group
+	w = request("GET", "/users?group=1", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var users struct {
+		Data []entity.UserResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	require.Len(t, users.Data, 1)
+	assert.Equal(t, bobID, users.Data[0].ID)
+	assert.Equal(t, http.StatusBadRequest, request("GET", "/users?group=support", "").Code)
+	assert.Equal(t, http.StatusBadRequest, request("GET", "/users?group=1&partial=true", "").Code)
+
+	// The group with members is only deleted with force=true
+	assert.Equal(t, http.StatusConflict, request("DELETE", "/groups/1", "").Code)
+	assert.Equal(t, http.StatusBadRequest, request("DELETE", "/groups/1?force=maybe", "").Code)
+	assert.Equal(t, http.StatusOK, request("DELETE", "/groups/1?force=true", "").Code)
+	assert.Equal(t, http.StatusNotFound, request("GET", "/groups/1/members", "").Code)
+}
+
+func TestGroups_TokenClaims(t *testing.T) {
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("JWT_SECRET", "a-secret-of-the-groups-at-least-256-bits-long")
+	service.SetJWTSecret("a-secret-of-the-groups-at-least-256-bits-long")
+	authorization.SetJWTSecret("a-secret-of-the-groups-at-least-256-bits-long")
+	t.Cleanup(func() { service.SetJWTSecret("") })
+
+	token, err := service.GenerateJWTTokenWithHS256(entity.User{ID: bobID, Username: "bob", TenantID: 1, GroupIDs: []int64{1, 3}}, time.Now())
+	require.NoError(t, err)
+	withoutGroups, err := service.GenerateJWTTokenWithHS256(entity.User{ID: carolID, Username: "carol", TenantID: 1}, time.Now())
+	require.NoError(t, err)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(withoutGroups, jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.NotContains(t, parsed.Claims, "groups")
+
+	// The middleware exposes the groups of the token to the downstream authorization
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(authorization.JwtValidation())
+	var groupIDs []int64
+	router.GET("/me", func(c *gin.Context) {
+		meta, _ := metacontext.ExtractUserInformationMeta(c.Request.Context())
+		groupIDs = meta.GroupIDs
+		c.Status(http.StatusOK)
+	})
+
+	for _, tt := range []struct {
+		token string
+		want  []int64
+	}{
+		{token, []int64{1, 3}},
+		{withoutGroups, nil},
+	} {
+		req, _ := http.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, tt.want, groupIDs)
+	}
+}
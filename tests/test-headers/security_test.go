@@ -0,0 +1,86 @@
+package test_headers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/headers"
+)
+
+// setupRouter registers a dummy route behind the security headers middleware.
+func setupRouter(cfg headers.SecurityHeadersConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(headers.SecurityHeadersWithConfig(cfg))
+	router.GET("/api/v1/consumers", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/swagger/index.html", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func TestSecurityHeaders_Defaults(t *testing.T) {
+	router := setupRouter(headers.DefaultSecurityHeadersConfig())
+
+	req, _ := http.NewRequest("GET", "/api/v1/consumers", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+	assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", w.Header().Get("Content-Security-Policy"))
+
+	// HSTS must not be sent over plain HTTP
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+}
+
+func TestSecurityHeaders_HSTSOverTLS(t *testing.T) {
+	router := setupRouter(headers.DefaultSecurityHeadersConfig())
+
+	req, _ := http.NewRequest("GET", "/api/v1/consumers", nil)
+	req.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.NotEmpty(t, w.Header().Get("Strict-Transport-Security"))
+}
+
+func TestSecurityHeaders_HSTSBehindProxy(t *testing.T) {
+	cfg := headers.DefaultSecurityHeadersConfig()
+	cfg.BehindTLSProxy = true
+	router := setupRouter(cfg)
+
+	req, _ := http.NewRequest("GET", "/api/v1/consumers", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.NotEmpty(t, w.Header().Get("Strict-Transport-Security"))
+}
+
+func TestSecurityHeaders_SwaggerCSP(t *testing.T) {
+	router := setupRouter(headers.DefaultSecurityHeadersConfig())
+
+	req, _ := http.NewRequest("GET", "/swagger/index.html", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Contains(t, w.Header().Get("Content-Security-Policy"), "'unsafe-inline'")
+}
+
+func TestSecurityHeaders_EnvOverrideAndDisable(t *testing.T) {
+	t.Setenv("SECURITY_HEADER_X_FRAME_OPTIONS", "SAMEORIGIN")
+	t.Setenv("SECURITY_HEADER_REFERRER_POLICY", "DISABLED")
+	router := setupRouter(headers.LoadSecurityHeadersConfig())
+
+	req, _ := http.NewRequest("GET", "/api/v1/consumers", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "SAMEORIGIN", w.Header().Get("X-Frame-Options"))
+	assert.Empty(t, w.Header().Get("Referrer-Policy"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+}
@@ -0,0 +1,45 @@
+package test_impersonation
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+const (
+	adminID   int64 = 1
+	bobID     int64 = 2
+	carolID   int64 = 3
+	daveID    int64 = 4
+	missingID int64 = 99
+)
+
+// setupDatabase opens an SQLite database with the tables of the migration and makes the services use it
+// instead of PostgreSQL. It holds the admin (ID 1) with the ROLE_ADMIN role, bob (ID 2) with the ROLE_USER role,
+// carol (ID 3) with the ROLE_USER role but disabled, and dave (ID 4) with the ROLE_ADMIN role.
+// ROLE_USER grants consumers:read, ROLE_ADMIN consumers:read and users:impersonate.
+func setupDatabase(t *testing.T) *gorm.DB {
+	db := testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_ADMIN', false)`,
+		`INSERT INTO permissions (name) VALUES ('consumers:read'), ('users:impersonate')`,
+		`INSERT INTO role_permissions (role_id, permission_id) VALUES (1, 1), (2, 1), (2, 2)`,
+		`INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired,
+			is_account_non_locked, is_credentials_non_expired, user_type) VALUES
+			('admin', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'admin@mygmail.com', 'Admin', true, true, true, true, 'USER_ACCOUNT'),
+			('bob', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'bob@mygmail.com', 'Bob', true, true, true, true, 'USER_ACCOUNT'),
+			('carol', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'carol@mygmail.com', 'Carol', false, true, true, true, 'USER_ACCOUNT'),
+			('dave', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'dave@mygmail.com', 'Dave', true, true, true, true, 'USER_ACCOUNT')`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES (1, 2), (2, 1), (3, 1), (4, 2)`,
+		`INSERT INTO group_memberships (group_id, user_id, role, created_at) VALUES (7, 2, 'MEMBER', CURRENT_TIMESTAMP)`,
+	)
+
+	// Record the actor of the writes like the PostgreSQL connection does
+	if err := database.RegisterAuditCallbacks(db); err != nil {
+		t.Fatalf("failed to register the audit callbacks: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,260 @@
+package test_impersonation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+	jwtutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/jwt-util"
+)
+
+// setTokenEnv sets the signing of the tokens, the access tokens issued at login expire after an hour.
+func setTokenEnv(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_EXPIRATION_HOUR", "1")
+}
+
+// asAdmin returns a context authenticated as the admin, impersonating a user when impersonatorID is not nil.
+func asAdmin(impersonatorID *int64) context.Context {
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: adminID, Username: "admin", Roles: []string{"ROLE_ADMIN"}, TenantID: metacontext.DefaultTenantID,
+		ImpersonatorID: impersonatorID,
+	})
+	return metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID)
+}
+
+// claimsOf parses the token at the time of the clock and returns its claims.
+func claimsOf(t *testing.T, token string, clk clock.Clock) jwt.MapClaims {
+	parsed, err := service.ParseJWTToken(token, jwt.WithTimeFunc(clk.Now))
+	require.NoError(t, err)
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+	return claims
+}
+
+// auditLogs returns the impersonations recorded in the audit log.
+func auditLogs(t *testing.T, db *gorm.DB) []entity.AuditLog {
+	var logs []entity.AuditLog
+	require.NoError(t, db.Where("action = ?", "impersonate").Order("id ASC").Find(&logs).Error)
+	return logs
+}
+
+func TestImpersonate_TokenCarriesImpersonator(t *testing.T) {
+	setupDatabase(t)
+	setTokenEnv(t)
+	start := time.Date(2031, time.January, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+
+	resp, err := service.NewAuthService(clk).Impersonate(asAdmin(nil), bobID)
+	require.NoError(t, err)
+	assert.Equal(t, adminID, resp.ImpersonatorID)
+	assert.Equal(t, "Bearer", resp.TokenType)
+
+	// The token is issued for the user, with its roles, permissions and groups, and tells the admin acting as the user
+	claims := claimsOf(t, resp.AccessToken, clk)
+	impersonator, err := jwtutil.GetInt64Claim(claims, "impersonator")
+	require.NoError(t, err)
+	assert.Equal(t, adminID, impersonator)
+	userID, err := jwtutil.GetInt64Claim(claims, "userid")
+	require.NoError(t, err)
+	assert.Equal(t, bobID, userID)
+	assert.Equal(t, "bob", jwtutil.GetStringClaim(claims, "username"))
+	assert.Equal(t, []string{"ROLE_USER"}, jwtutil.GetStringSliceClaim(claims, "roles"))
+	assert.Equal(t, []string{"consumers:read"}, jwtutil.GetStringSliceClaim(claims, "permissions"))
+	assert.Equal(t, []int64{7}, jwtutil.GetInt64SliceClaim(claims, "groups"))
+
+	// The token expires after the impersonation TTL, far sooner than the tokens issued at login
+	expiresAt := start.Add(service.GetImpersonationTokenTTL())
+	assert.Equal(t, 15*time.Minute, service.GetImpersonationTokenTTL())
+	assert.Equal(t, expiresAt.Format(time.RFC3339), resp.ExpirationDate)
+
+	clk.Advance(service.GetImpersonationTokenTTL() - time.Second)
+	_, err = service.ParseJWTToken(resp.AccessToken, jwt.WithTimeFunc(clk.Now))
+	assert.NoError(t, err)
+	clk.Advance(2 * time.Second)
+	_, err = service.ParseJWTToken(resp.AccessToken, jwt.WithTimeFunc(clk.Now))
+	assert.ErrorContains(t, err, jwt.ErrTokenExpired.Error())
+}
+
+func TestImpersonate_TokenTTL(t *testing.T) {
+	setupDatabase(t)
+	setTokenEnv(t)
+	start := time.Date(2031, time.January, 1, 9, 0, 0, 0, time.UTC)
+	s := service.NewAuthService(clock.NewFake(start))
+
+	tests := []struct {
+		ttl  string
+		want time.Duration
+	}{
+		{"5", 5 * time.Minute},
+		{"0", 15 * time.Minute},
+		{"invalid", 15 * time.Minute},
+		// The impersonation tokens never outlive the tokens issued at login
+		{"600", time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ttl, func(t *testing.T) {
+			t.Setenv("IMPERSONATION_TOKEN_TTL_MINUTES", tt.ttl)
+
+			resp, err := s.Impersonate(asAdmin(nil), bobID)
+			require.NoError(t, err)
+			assert.Equal(t, start.Add(tt.want).Format(time.RFC3339), resp.ExpirationDate)
+		})
+	}
+}
+
+func TestImpersonate_Audited(t *testing.T) {
+	db := setupDatabase(t)
+	setTokenEnv(t)
+	clk := clock.NewFake(time.Date(2031, time.January, 1, 9, 0, 0, 0, time.UTC))
+
+	resp, err := service.NewAuthService(clk).Impersonate(asAdmin(nil), bobID)
+	require.NoError(t, err)
+
+	logs := auditLogs(t, db)
+	require.Len(t, logs, 1)
+	require.NotNil(t, logs[0].ActorID)
+	assert.Equal(t, adminID, *logs[0].ActorID)
+	assert.Equal(t, "user:admin", logs[0].Actor)
+	assert.Equal(t, "user", logs[0].EntityType)
+	assert.Equal(t, "2", logs[0].EntityID)
+
+	// The entry tells the token by its ID and its expiry
+	require.NotNil(t, logs[0].Details)
+	jti := jwtutil.GetStringClaim(claimsOf(t, resp.AccessToken, clk), "jti")
+	assert.Contains(t, *logs[0].Details, jti)
+	assert.Contains(t, *logs[0].Details, resp.ExpirationDate)
+}
+
+func TestImpersonate_Forbidden(t *testing.T) {
+	db := setupDatabase(t)
+	setTokenEnv(t)
+	s := service.NewAuthService(clock.New())
+	impersonator := adminID
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		userID int64
+		want   error
+	}{
+		{"themselves", asAdmin(nil), adminID, service.ErrImpersonationForbidden},
+		{"admin-level user", asAdmin(nil), daveID, service.ErrImpersonationForbidden},
+		{"disabled user", asAdmin(nil), carolID, service.ErrImpersonationForbidden},
+		{"with an impersonation token", asAdmin(&impersonator), carolID, service.ErrImpersonationForbidden},
+		{"missing user", asAdmin(nil), missingID, gorm.ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Impersonate(tt.ctx, tt.userID)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+
+	// The denied impersonations issue no token and are not recorded
+	assert.Empty(t, auditLogs(t, db))
+}
+
+// setupRouter registers the impersonation like the application does, behind the JWT validation,
+// along with a route answering the actor of the request.
+func setupRouter(t *testing.T) *gin.Engine {
+	authorization.SetPermissionResolver(service.ResolveUserPermissions)
+	t.Cleanup(func() { authorization.SetPermissionResolver(nil) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(authorization.JwtValidation())
+
+	h := handler.NewAuthHandler(service.NewAuthService(clock.New()), nil)
+	router.POST("/api/v1/users/:id/impersonate", authorization.RoleBasedAccessControl("ROLE_ADMIN"),
+		authorization.RequirePermission("users:impersonate"), pathparam.PathInt64("id"), h.Impersonate)
+	router.GET("/whoami", func(c *gin.Context) {
+		meta, _ := metacontext.ExtractUserInformationMeta(c.Request.Context())
+		c.JSON(http.StatusOK, gin.H{"userId": meta.UserID, "actor": meta.Actor()})
+	})
+	return router
+}
+
+// tokenOf issues an access token to the user, with the given embedded permissions.
+func tokenOf(t *testing.T, userID int64, permissions []string) string {
+	user, err := service.NewUserService(repository.NewUserRepository()).GetUserByID(userID)
+	require.NoError(t, err)
+	user.Permissions = permissions
+
+	token, err := service.GenerateJWTTokenWithHS256(user, time.Now())
+	require.NoError(t, err)
+	return token
+}
+
+// serve sends the request with the token and returns the recorded response.
+func serve(router *gin.Engine, method string, path string, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestImpersonate_Handler(t *testing.T) {
+	db := setupDatabase(t)
+	setTokenEnv(t)
+	router := setupRouter(t)
+	adminToken := tokenOf(t, adminID, []string{"consumers:read", "users:impersonate"})
+
+	w := serve(router, "POST", fmt.Sprintf("/api/v1/users/%d/impersonate", bobID), adminToken)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Data entity.ImpersonationResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, adminID, body.Data.ImpersonatorID)
+	assert.Len(t, auditLogs(t, db), 1)
+
+	// The requests made with the token are made as the user, and attributed to the admin as well
+	w = serve(router, "GET", "/whoami", body.Data.AccessToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"userId": 2, "actor": "user:bob (impersonated by user:1)"}`, w.Body.String())
+
+	// The admins without the permission and the other users are denied, so is the impersonation token itself
+	tests := []struct {
+		name   string
+		token  string
+		userID int64
+		status int
+	}{
+		{"admin without the permission", tokenOf(t, adminID, []string{"consumers:read"}), carolID, http.StatusForbidden},
+		{"user", tokenOf(t, bobID, []string{"consumers:read"}), carolID, http.StatusForbidden},
+		{"impersonation token", body.Data.AccessToken, carolID, http.StatusForbidden},
+		{"admin-level user", adminToken, daveID, http.StatusForbidden},
+		{"missing user", adminToken, missingID, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, "POST", fmt.Sprintf("/api/v1/users/%d/impersonate", tt.userID), tt.token)
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+	assert.Len(t, auditLogs(t, db), 1)
+}
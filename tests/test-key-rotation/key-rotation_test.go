@@ -0,0 +1,228 @@
+package test_key_rotation
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+	jwtutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/jwt-util"
+)
+
+// claims returns valid claims of the admin.
+func claims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":      "admin",
+		"userid":   1,
+		"username": "admin",
+		"roles":    []string{"ROLE_ADMIN"},
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// sign signs the claims with the key, stamping the kid unless it is empty.
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string) string {
+	token := jwt.NewWithClaims(method, claims())
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+// authenticate runs the JWT validation configured by the environment on a request carrying the token.
+func authenticate(token string) int {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(authorization.JwtValidation())
+	router.GET("/me", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req, _ := http.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+// writeKeyPair generates an RSA key pair and writes it as PEM files, returning their paths and the private key.
+func writeKeyPair(t *testing.T, name string) (string, string, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	privatePath := filepath.Join(dir, name+"-private.pem")
+	publicPath := filepath.Join(dir, name+"-public.pem")
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	require.NoError(t, os.WriteFile(privatePath, privatePEM, 0600))
+	require.NoError(t, os.WriteFile(publicPath, publicPEM, 0600))
+	return privatePath, publicPath, key
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	keys, err := jwtutil.NewKeySet(jwtutil.HMACKey("2025-01", []byte("old-secret")))
+	require.NoError(t, err)
+	old, err := keys.Sign(claims())
+	require.NoError(t, err)
+
+	// The token is stamped with the ID of the key signing it
+	parsed, err := keys.Parse(old)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01", parsed.Header["kid"])
+
+	// After the rotation, the new tokens are signed with the new key and the old ones still verify
+	require.NoError(t, keys.Rotate(jwtutil.HMACKey("2025-06", []byte("new-secret"))))
+	assert.Equal(t, "2025-06", keys.Current().ID)
+	current, err := keys.Sign(claims())
+	require.NoError(t, err)
+	parsed, err = keys.Parse(current)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-06", parsed.Header["kid"])
+	_, err = keys.Parse(old)
+	assert.NoError(t, err)
+
+	// Once the old key is removed, its tokens no longer verify, the current key cannot be removed
+	require.NoError(t, keys.Remove("2025-01"))
+	_, err = keys.Parse(old)
+	assert.ErrorIs(t, err, jwtutil.ErrUnknownKeyID)
+	assert.ErrorIs(t, keys.Remove("2025-06"), jwtutil.ErrCurrentKeyRemoved)
+	assert.ErrorIs(t, keys.Remove("2025-01"), jwtutil.ErrUnknownKeyID)
+}
+
+func TestKeySet_Verification(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keys, err := jwtutil.NewKeySet(jwtutil.RSAKey("rsa", privateKey, &privateKey.PublicKey), jwtutil.HMACKey("hmac", []byte("secret")))
+	require.NoError(t, err)
+
+	// A token verifies with the key of its kid, a token without kid with the current key
+	_, err = keys.Parse(sign(t, jwt.SigningMethodRS256, privateKey, "rsa"))
+	assert.NoError(t, err)
+	_, err = keys.Parse(sign(t, jwt.SigningMethodRS256, privateKey, ""))
+	assert.NoError(t, err)
+	_, err = keys.Parse(sign(t, jwt.SigningMethodHS256, []byte("secret"), "hmac"))
+	assert.NoError(t, err)
+
+	// An unknown kid fails, even when the token is signed with a key of the set
+	_, err = keys.Parse(sign(t, jwt.SigningMethodRS256, privateKey, "unknown"))
+	assert.ErrorIs(t, err, jwtutil.ErrUnknownKeyID)
+
+	// A token of another algorithm than its key fails, e.g. an HMAC token claiming the RSA key
+	_, err = keys.Parse(sign(t, jwt.SigningMethodHS256, []byte("secret"), "rsa"))
+	assert.ErrorContains(t, err, "unexpected signing method")
+
+	// A key that only verifies cannot become the current key
+	err = keys.Rotate(jwtutil.RSAKey("public-only", nil, &privateKey.PublicKey))
+	assert.ErrorIs(t, err, jwtutil.ErrNoSigningKey)
+	assert.Equal(t, "rsa", keys.Current().ID)
+}
+
+func TestJwtValidation_HS256Rotation(t *testing.T) {
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("JWT_SECRET", "new-secret")
+	t.Setenv("JWT_KEY_ID", "2025-06")
+	t.Setenv("JWT_PREVIOUS_KEYS", "2025-01=old-secret")
+	service.JWTSecret = "new-secret"
+
+	// The issued tokens are stamped with the current key ID
+	issued, err := service.GenerateJWTTokenWithHS256(entity.User{ID: 1, Username: "admin", Roles: []entity.Role{{Name: "ROLE_ADMIN"}}}, time.Now())
+	require.NoError(t, err)
+	parsed, err := service.ParseJWTTokenWithHS256(issued)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-06", parsed.Header["kid"])
+	assert.Equal(t, http.StatusOK, authenticate(issued))
+
+	// A token signed with the old but present key still verifies, an unknown kid fails
+	assert.Equal(t, http.StatusOK, authenticate(sign(t, jwt.SigningMethodHS256, []byte("old-secret"), "2025-01")))
+	assert.Equal(t, http.StatusUnauthorized, authenticate(sign(t, jwt.SigningMethodHS256, []byte("old-secret"), "2024-06")))
+	assert.Equal(t, http.StatusUnauthorized, authenticate(sign(t, jwt.SigningMethodHS256, []byte("old-secret"), "2025-06")))
+
+	// The tokens issued before the key IDs have no kid and verify with the current key
+	assert.Equal(t, http.StatusOK, authenticate(sign(t, jwt.SigningMethodHS256, []byte("new-secret"), "")))
+
+	// Once the old key is removed from the configuration, its tokens are rejected
+	t.Setenv("JWT_PREVIOUS_KEYS", "")
+	assert.Equal(t, http.StatusUnauthorized, authenticate(sign(t, jwt.SigningMethodHS256, []byte("old-secret"), "2025-01")))
+}
+
+func TestJwtValidation_RS256Rotation(t *testing.T) {
+	_, oldPublic, oldKey := writeKeyPair(t, "old")
+	newPrivate, newPublic, newKey := writeKeyPair(t, "new")
+
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_ALGORITHM", "RS256")
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_PRIVATE_KEY_PATH", newPrivate)
+	t.Setenv("JWT_PUBLIC_KEY_PATH", newPublic)
+	t.Setenv("JWT_KEY_ID", "2025-06")
+	t.Setenv("JWT_PREVIOUS_KEYS", "2025-01="+oldPublic)
+
+	issued, err := service.GenerateJWTTokenWithRS256(entity.User{ID: 1, Username: "admin", Roles: []entity.Role{{Name: "ROLE_ADMIN"}}}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, authenticate(issued))
+	assert.Equal(t, http.StatusOK, authenticate(sign(t, jwt.SigningMethodRS256, newKey, "2025-06")))
+
+	// The old public key verifies the tokens of the old private key, and those only
+	assert.Equal(t, http.StatusOK, authenticate(sign(t, jwt.SigningMethodRS256, oldKey, "2025-01")))
+	assert.Equal(t, http.StatusUnauthorized, authenticate(sign(t, jwt.SigningMethodRS256, oldKey, "2025-06")))
+	assert.Equal(t, http.StatusUnauthorized, authenticate(sign(t, jwt.SigningMethodRS256, oldKey, "unknown")))
+
+	// An HMAC token is never accepted when the tokens are signed with RSA, whatever the secret
+	assert.Equal(t, http.StatusUnauthorized, authenticate(sign(t, jwt.SigningMethodHS256, []byte(""), "")))
+}
+
+func TestLoadKeySet_InvalidPreviousKeys(t *testing.T) {
+	for _, previous := range []string{"no-separator", "=secret", "2025-01="} {
+		t.Setenv("JWT_PREVIOUS_KEYS", previous)
+		_, err := jwtutil.LoadKeySet("HS256", "secret")
+		assert.Error(t, err, previous)
+	}
+
+	// The current key pair is valid, the previous public key is missing
+	privatePath, publicPath, _ := writeKeyPair(t, "current")
+	t.Setenv("JWT_PRIVATE_KEY_PATH", privatePath)
+	t.Setenv("JWT_PUBLIC_KEY_PATH", publicPath)
+	t.Setenv("JWT_PREVIOUS_KEYS", "2025-01="+filepath.Join(t.TempDir(), "missing.pem"))
+	_, err := jwtutil.LoadKeySet("RS256", "")
+	assert.ErrorContains(t, err, "failed to read the public key 2025-01")
+	_, err = jwtutil.LoadKeySet("none", "")
+	assert.ErrorContains(t, err, "unsupported signing method")
+}
+
+func TestLoadKeySet_KeyPairMismatch(t *testing.T) {
+	privatePath, publicPath, key := writeKeyPair(t, "current")
+	_, otherPublicPath, _ := writeKeyPair(t, "other")
+	t.Setenv("JWT_PREVIOUS_KEYS", "")
+	t.Setenv("JWT_PRIVATE_KEY_PATH", privatePath)
+
+	// The public key of another pair would not verify the signed tokens
+	t.Setenv("JWT_PUBLIC_KEY_PATH", otherPublicPath)
+	_, _, err := jwtutil.LoadKeyPair()
+	assert.ErrorIs(t, err, jwtutil.ErrKeyPairMismatch)
+	_, err = jwtutil.LoadKeySet("RS256", "")
+	assert.ErrorIs(t, err, jwtutil.ErrKeyPairMismatch)
+
+	t.Setenv("JWT_PUBLIC_KEY_PATH", publicPath)
+	privateKey, publicKey, err := jwtutil.LoadKeyPair()
+	require.NoError(t, err)
+	assert.True(t, key.Equal(privateKey))
+	assert.True(t, key.PublicKey.Equal(publicKey))
+}
@@ -0,0 +1,146 @@
+package test_locale
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/locale"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// invalidBody is a user failing the username and the user type validations.
+const invalidBody = `{"username": "new user", "password": "P@ssw0rd123", "email": "newuser@mygmail.com", "firstName": "New", "userType": "SYSTEM"}`
+
+// english and indonesian are the validation errors of invalidBody in English and in Indonesian.
+var (
+	english = []map[string]string{
+		{"field": "username", "message": "username must not contain spaces nor control characters"},
+		{"field": "userType", "message": "userType must be SERVICE_ACCOUNT or USER_ACCOUNT"},
+	}
+	indonesian = []map[string]string{
+		{"field": "username", "message": "username tidak boleh berisi spasi maupun karakter kontrol"},
+		{"field": "userType", "message": "userType harus SERVICE_ACCOUNT atau USER_ACCOUNT"},
+	}
+)
+
+// newRouter serves the creation of a user behind the locale middleware, answering its validation errors like the handlers do.
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(locale.Locale())
+	router.POST("/api/v1/users", func(c *gin.Context) {
+		var req entity.UserCreateRequest
+		if err := httputil.BindJSON(c, &req); err != nil {
+			httputil.BadRequest(c, "Invalid request body", err.Error())
+			return
+		}
+		if err := req.Validate(); err != nil {
+			httputil.UnprocessableEntityMap(c, "Failed to create user", validation.FormatLocalizedValidationErrors(c.Request.Context(), err))
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+	return router
+}
+
+// createUser sends the invalid user with the Accept-Language header, when given, and returns the response.
+func createUser(t *testing.T, router *gin.Engine, acceptLanguage string) (*httptest.ResponseRecorder, []map[string]string) {
+	req, _ := http.NewRequest("POST", "/api/v1/users", bytes.NewBufferString(invalidBody))
+	req.Header.Set("Content-Type", "application/json")
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+	var body struct {
+		Error []map[string]string `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w, body.Error
+}
+
+func TestLocale_AcceptLanguage(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           []map[string]string
+	}{
+		{"no header", "", english},
+		{"supported language", "id", indonesian},
+		{"regional locale", "id-ID", indonesian},
+		{"case insensitive", "ID-id", indonesian},
+		{"preferred by quality", "en;q=0.5, id;q=0.8", indonesian},
+		{"first supported language", "fr-FR,fr;q=0.9,id;q=0.5,en;q=0.1", indonesian},
+		{"unsupported language falls back to English", "fr-FR,fr;q=0.9", english},
+		{"refused language", "id;q=0", english},
+		{"wildcard", "*", english},
+		{"malformed quality", "id;q=high", english},
+	}
+
+	router := newRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, errs := createUser(t, router, tt.acceptLanguage)
+			assert.ElementsMatch(t, tt.want, errs)
+
+			// The messages depend on the header, the caches are told so
+			assert.Equal(t, "Accept-Language", w.Header().Get("Vary"))
+		})
+	}
+}
+
+func TestFormatLocalizedValidationErrors(t *testing.T) {
+	ctx := metacontext.InjectLocale(context.Background(), "id")
+
+	// The parameters of the tags are kept, the other field of required_without is written as its path
+	patch := entity.UserRolesPatchRequest{}
+	assert.ElementsMatch(t, []map[string]string{
+		{"field": "add", "message": "add wajib diisi jika remove kosong"},
+		{"field": "remove", "message": "remove wajib diisi jika add kosong"},
+	}, validation.FormatLocalizedValidationErrors(ctx, patch.Validate()))
+
+	user := entity.UserCreateRequest{Username: "ab", Password: "P@ssw0rd123", Email: "not an email", UserType: "USER_ACCOUNT"}
+	assert.ElementsMatch(t, []map[string]string{
+		{"field": "username", "message": "username minimal 3 karakter"},
+		{"field": "email", "message": "email harus berupa alamat email yang valid"},
+		{"field": "firstName", "message": "firstName wajib diisi"},
+	}, validation.FormatLocalizedValidationErrors(ctx, user.Validate()))
+
+	// A tag without a message of its own is reported as not valid
+	member := entity.GroupMemberRoleRequest{Role: "guest"}
+	assert.Equal(t, []map[string]string{
+		{"field": "role", "message": "role tidak valid"},
+	}, validation.FormatLocalizedValidationErrors(ctx, member.Validate()))
+
+	// The messages are in English without a locale, and for an unsupported one
+	for _, ctx := range []context.Context{context.Background(), metacontext.InjectLocale(context.Background(), "fr")} {
+		assert.Equal(t, validation.FormatValidationErrors(user.Validate()), validation.FormatLocalizedValidationErrors(ctx, user.Validate()))
+	}
+	assert.Contains(t, validation.FormatValidationErrors(member.Validate()), map[string]string{"field": "role", "message": "role is not valid"})
+}
+
+func TestMatchLocale(t *testing.T) {
+	for _, locales := range [][]string{{"id"}, {"id-ID"}, {"id_ID"}, {"de", "ID"}} {
+		locale, ok := validation.MatchLocale(locales...)
+		assert.True(t, ok, locales)
+		assert.Equal(t, "id", locale, locales)
+	}
+
+	_, ok := validation.MatchLocale("fr", "de-DE")
+	assert.False(t, ok)
+	_, ok = validation.MatchLocale()
+	assert.False(t, ok)
+}
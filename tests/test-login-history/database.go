@@ -0,0 +1,19 @@
+package test_login_history
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with the tables of the migration,
+// and makes the services use it instead of PostgreSQL.
+func setupDatabase(t *testing.T) *gorm.DB {
+	return testdb.Open(t,
+		`INSERT INTO users (id, username, password, email, firstname, user_type) VALUES
+			(1, 'admin', '!', 'admin@mygmail.com', 'Admin', 'USER_ACCOUNT'),
+			(2, 'user', '!', 'user@mygmail.com', 'User', 'USER_ACCOUNT')`,
+	)
+}
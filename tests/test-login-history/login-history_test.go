@@ -0,0 +1,192 @@
+package test_login_history
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+func TestLoginHistory_RecordedAsynchronously(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewLoginAttemptService(repository.NewLoginAttemptRepository())
+
+	service.StartLoginAttemptRecorder()
+	reason := "invalid credentials for user user"
+	s.RecordLoginAttempt(entity.LoginAttempt{Username: "user", Success: false, FailureReason: &reason, IPAddress: "10.0.0.1"})
+	s.RecordLoginAttempt(entity.LoginAttempt{Username: "USER", Success: true, IPAddress: "10.0.0.1"})
+	s.RecordLoginAttempt(entity.LoginAttempt{Username: "admin", Success: true, IPAddress: "10.0.0.2"})
+	s.RecordLoginAttempt(entity.LoginAttempt{Username: "unknown", Success: false, FailureReason: &reason})
+
+	// Stopping the recorder writes the buffered attempts
+	service.StopLoginAttemptRecorder()
+
+	attempts, total, err := s.GetLoginHistory(context.Background(), 2, entity.LoginAttemptFilter{Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	for _, attempt := range attempts {
+		assert.NotNil(t, attempt.UserID)
+		assert.Equal(t, int64(2), *attempt.UserID)
+	}
+
+	// Filter by outcome
+	failure := false
+	attempts, total, err = s.GetLoginHistory(context.Background(), 2, entity.LoginAttemptFilter{Success: &failure, Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, reason, *attempts[0].FailureReason)
+
+	// Filter by date range
+	from := time.Now().Add(time.Hour)
+	_, total, err = s.GetLoginHistory(context.Background(), 2, entity.LoginAttemptFilter{From: &from, Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func TestLoginHistory_RetentionCleanup(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("LOGIN_HISTORY_RETENTION_DAYS", "30")
+	s := service.NewLoginAttemptService(repository.NewLoginAttemptRepository())
+
+	userID := int64(1)
+	now := time.Now()
+	assert.NoError(t, db.Create(&[]entity.LoginAttempt{
+		{UserID: &userID, Username: "admin", Success: true, AttemptedAt: now.AddDate(0, 0, -31)},
+		{UserID: &userID, Username: "admin", Success: true, AttemptedAt: now.AddDate(0, 0, -29)},
+	}).Error)
+
+	removed, err := s.RemoveExpiredLoginAttempts(now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+
+	_, total, err := s.GetLoginHistory(context.Background(), 1, entity.LoginAttemptFilter{Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+}
+
+func TestLoginHistory_SameUsernameInTwoTenants(t *testing.T) {
+	db := setupDatabase(t)
+	assert.NoError(t, db.Exec(`INSERT INTO tenants (id, name) VALUES (1, 'Default'), (2, 'Acme')`).Error)
+	assert.NoError(t, db.Exec(`INSERT INTO users (id, username, password, email, firstname, user_type, tenant_id) VALUES
+		(3, 'user', '!', 'user@acme.com', 'User', 'USER_ACCOUNT', 2)`).Error)
+	s := service.NewLoginAttemptService(repository.NewLoginAttemptRepository())
+
+	service.StartLoginAttemptRecorder()
+	s.RecordLoginAttempt(entity.LoginAttempt{TenantID: 1, Username: "user", Success: true, AttemptedAt: time.Now()})
+	s.RecordLoginAttempt(entity.LoginAttempt{TenantID: 2, Username: "user", Success: true, AttemptedAt: time.Now()})
+	s.RecordLoginAttempt(entity.LoginAttempt{TenantID: 2, Username: "USER", Success: false, AttemptedAt: time.Now()})
+	service.StopLoginAttemptRecorder()
+
+	// The attempts recorded before the ID of the user was known only match its username in its tenant
+	assert.NoError(t, db.Create(&[]entity.LoginAttempt{
+		{TenantID: 1, Username: "user", Success: false, AttemptedAt: time.Now().AddDate(0, 0, -2)},
+		{TenantID: 2, Username: "user", Success: false, AttemptedAt: time.Now().AddDate(0, 0, -2)},
+	}).Error)
+
+	var stored []entity.LoginAttempt
+	assert.NoError(t, db.Where("user_id IS NOT NULL").Order("id").Find(&stored).Error)
+	assert.Len(t, stored, 3)
+	assert.Equal(t, []int64{1, 2, 2}, []int64{stored[0].TenantID, stored[1].TenantID, stored[2].TenantID})
+	assert.Equal(t, []int64{2, 3, 3}, []int64{*stored[0].UserID, *stored[1].UserID, *stored[2].UserID})
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		userID int64
+		tenant int64
+		total  int64
+	}{
+		{"first tenant", metacontext.InjectTenantID(context.Background(), 1), 2, 1, 2},
+		{"second tenant", metacontext.InjectTenantID(context.Background(), 2), 3, 2, 3},
+		{"no tenant in the context", context.Background(), 2, 1, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts, total, err := s.GetLoginHistory(tt.ctx, tt.userID, entity.LoginAttemptFilter{Page: 1, Limit: 10})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.total, total)
+			for _, attempt := range attempts {
+				assert.Equal(t, tt.tenant, attempt.TenantID)
+			}
+		})
+	}
+
+	// The cleanup in the context of a tenant leaves the attempts of the other tenants
+	removed, err := repository.NewLoginAttemptRepository().RemoveLoginAttemptsBefore(
+		db.WithContext(metacontext.InjectTenantID(context.Background(), 1)), time.Now().AddDate(0, 0, -1))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	_, total, err := s.GetLoginHistory(context.Background(), 3, entity.LoginAttemptFilter{Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+}
+
+func TestLoginHistory_Handler(t *testing.T) {
+	db := setupDatabase(t)
+	userID := int64(2)
+	assert.NoError(t, db.Create(&[]entity.LoginAttempt{
+		{UserID: &userID, Username: "user", Success: true, AttemptedAt: time.Now()},
+	}).Error)
+
+	h := handler.NewLoginAttemptHandler(service.NewLoginAttemptService(repository.NewLoginAttemptRepository()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		ctx := metacontext.InjectUserInformationMeta(c.Request.Context(), metacontext.UserInformationMeta{UserID: 2, Username: "user"})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	})
+	router.GET("/api/v1/users/me/login-history", h.GetMyLoginHistory)
+	router.GET("/api/v1/users/:id/login-history", h.GetLoginHistory)
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		total  int64
+	}{
+		{"own history", "/api/v1/users/me/login-history", http.StatusOK, 1},
+		{"user history", "/api/v1/users/2/login-history?outcome=success", http.StatusOK, 1},
+		{"only failures", "/api/v1/users/2/login-history?outcome=failure", http.StatusOK, 0},
+		{"unknown user", "/api/v1/users/99/login-history", http.StatusNotFound, 0},
+		{"invalid outcome", "/api/v1/users/2/login-history?outcome=maybe", http.StatusBadRequest, 0},
+		{"invalid date", "/api/v1/users/2/login-history?from=yesterday", http.StatusBadRequest, 0},
+		{"inverted range", "/api/v1/users/2/login-history?from=2025-02-01T00:00:00Z&to=2025-01-01T00:00:00Z", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data       []entity.LoginAttempt `json:"data"`
+				Pagination struct {
+					Total int64 `json:"total"`
+				} `json:"pagination"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.total, response.Pagination.Total)
+			assert.Len(t, response.Data, int(tt.total))
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package test_login
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+)
+
+// fastestLogin posts the credentials to the login endpoint a few times, and returns the last response
+// along with the fastest duration, which is the least disturbed by the other processes of the machine.
+func fastestLogin(t *testing.T, username string, password string) (*httptest.ResponseRecorder, time.Duration) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("TOKEN_TYPE", "Bearer")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/login", handler.NewAuthHandler(service.NewAuthService(clock.New()), nil).Login)
+
+	var w *httptest.ResponseRecorder
+	fastest := time.Duration(-1)
+	for range 3 {
+		body, _ := json.Marshal(map[string]string{"username": username, "password": password})
+		req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+
+		start := time.Now()
+		router.ServeHTTP(w, req)
+		if elapsed := time.Since(start); fastest < 0 || elapsed < fastest {
+			fastest = elapsed
+		}
+	}
+	return w, fastest
+}
+
+// compareDuration returns the duration of a password comparison at the cost of the stored passwords.
+func compareDuration(t *testing.T) time.Duration {
+	hash, err := bcrypt.GenerateFromPassword([]byte("Str0ngP@ssword"), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+
+	fastest := time.Duration(-1)
+	for range 3 {
+		start := time.Now()
+		_ = bcrypt.CompareHashAndPassword(hash, []byte("WrongP@ssw0rd"))
+		if elapsed := time.Since(start); fastest < 0 || elapsed < fastest {
+			fastest = elapsed
+		}
+	}
+	return fastest
+}
+
+// responseBody returns the JSON body of the response without its timestamp.
+func responseBody(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	var body map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	delete(body, "timestamp")
+	return body
+}
+
+func TestLogin_UnknownUserComparesPassword(t *testing.T) {
+	setupDatabase(t)
+	compare := compareDuration(t)
+
+	// The login of an unknown user runs a password comparison, like the one of a known user with a wrong password.
+	// Half of a comparison leaves room for the variance of the machine, the lookup alone takes far less.
+	w, elapsed := fastestLogin(t, "nobody", "WrongP@ssw0rd")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Username or password is incorrect")
+	assert.GreaterOrEqual(t, elapsed, compare/2, "the login of an unknown user took %v, a password comparison takes %v", elapsed, compare)
+
+	// Both are refused with the same answer, which does not tell whether the username exists
+	known, _ := fastestLogin(t, "admin", "WrongP@ssw0rd")
+	assert.Equal(t, http.StatusUnauthorized, known.Code)
+	assert.Equal(t, responseBody(t, w), responseBody(t, known))
+}
+
+func TestLogin_LockedUserComparesPassword(t *testing.T) {
+	db := setupDatabase(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Exec(`UPDATE users SET password = ?, is_account_non_locked = false WHERE username = 'admin'`, string(hash)).Error)
+	compare := compareDuration(t)
+
+	// The state of the account is checked after the password comparison, a locked account is refused as slowly
+	w, elapsed := fastestLogin(t, "admin", "WrongP@ssw0rd")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.GreaterOrEqual(t, elapsed, compare/2, "the login of a locked user took %v, a password comparison takes %v", elapsed, compare)
+}
+
+func TestLogin_UnknownUserComparisonDisabled(t *testing.T) {
+	setupDatabase(t)
+	t.Setenv("LOGIN_CONSTANT_TIME", "FALSE")
+	compare := compareDuration(t)
+
+	w, elapsed := fastestLogin(t, "nobody", "WrongP@ssw0rd")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Less(t, elapsed, compare/2, "the login of an unknown user took %v, a password comparison takes %v", elapsed, compare)
+}
@@ -0,0 +1,39 @@
+package test_login
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// testPassword is the password of the admin user of the test database.
+const testPassword = "P@ssw0rd123"
+
+// setupDatabase opens an SQLite database with the tables of the migration and an enabled admin user with the ROLE_ADMIN
+// and ROLE_USER roles, and makes the services use it instead of PostgreSQL.
+func setupDatabase(t *testing.T) *gorm.DB {
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash the password: %v", err)
+	}
+
+	db := testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_ADMIN', false)`,
+		fmt.Sprintf(`INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired,
+			is_account_non_locked, is_credentials_non_expired, user_type)
+			VALUES ('admin', '%s', 'admin@mygmail.com', 'Admin', true, true, true, true, 'USER_ACCOUNT')`, hash),
+		`INSERT INTO user_roles (user_id, role_id) VALUES (1, 1), (1, 2)`,
+	)
+
+	// Record the actor of the writes like the PostgreSQL connection does
+	if err := database.RegisterAuditCallbacks(db); err != nil {
+		t.Fatalf("failed to register the audit callbacks: %v", err)
+	}
+
+	return db
+}
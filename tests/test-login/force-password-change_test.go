@@ -0,0 +1,60 @@
+package test_login
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+func TestForcePasswordChange_BlocksLoginUntilReset(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{UserID: 2, Username: "security"})
+
+	// The active session is revoked along with the credentials
+	login(t)
+	user, err := s.ForcePasswordChange(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, *user.IsCredentialsNonExpired)
+	assert.Equal(t, int64(2), *user.UpdatedBy)
+
+	var sessions int64
+	require.NoError(t, db.Model(&entity.RefreshToken{}).Where("user_id = ?", 1).Count(&sessions).Error)
+	assert.Zero(t, sessions)
+
+	// The login is blocked with the reason, which is only given to the caller knowing the password
+	w := postLogin(t, testPassword)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Password change required")
+
+	w = postLogin(t, "WrongP@ssw0rd")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotContains(t, w.Body.String(), "Password change required")
+
+	// The reset of the password lifts the forced change
+	_, err = s.ResetUserPassword(ctx, 1, "N3wP@ssw0rd456")
+	require.NoError(t, err)
+
+	w = postLogin(t, "N3wP@ssw0rd456")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestForcePasswordChange_UnknownUser(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{UserID: 1, Username: "admin"})
+
+	_, err := s.ForcePasswordChange(ctx, 99)
+	assert.Error(t, err)
+
+	_, err = s.ForcePasswordChange(context.Background(), 1)
+	assert.ErrorContains(t, err, "missing user context")
+}
@@ -0,0 +1,78 @@
+package test_login
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+)
+
+// postLogin posts the username and the password of the admin user to the login endpoint.
+func postLogin(t *testing.T, password string) *httptest.ResponseRecorder {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_EXPIRATION_HOUR", "1")
+	t.Setenv("JWT_REFRESH_TOKEN_EXPIRATION_HOUR", "24")
+	t.Setenv("MAX_SESSIONS_PER_USER", "0")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/login", handler.NewAuthHandler(service.NewAuthService(clock.New()), nil).Login)
+
+	body, _ := json.Marshal(map[string]string{"username": "admin", "password": password})
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// login posts the credentials of the admin user to the login endpoint and returns the data of the response.
+func login(t *testing.T) map[string]any {
+	w := postLogin(t, testPassword)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// The password, even hashed, never appears in the response
+	assert.NotContains(t, w.Body.String(), "password")
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.NotEmpty(t, body.Data["accessToken"])
+	assert.NotEmpty(t, body.Data["refreshToken"])
+	return body.Data
+}
+
+func TestLogin_IncludesProfileByDefault(t *testing.T) {
+	setupDatabase(t)
+
+	data := login(t)
+
+	profile, ok := data["user"].(map[string]any)
+	require.True(t, ok, "the login response has no user profile: %v", data)
+	assert.Equal(t, float64(1), profile["id"])
+	assert.Equal(t, "admin", profile["username"])
+	assert.Equal(t, "admin@mygmail.com", profile["email"])
+	assert.ElementsMatch(t, []any{"ROLE_USER", "ROLE_ADMIN"}, profile["roles"])
+	assert.Len(t, profile, 4)
+}
+
+func TestLogin_ProfileOmittedWhenDisabled(t *testing.T) {
+	setupDatabase(t)
+	t.Setenv("LOGIN_RESPONSE_INCLUDE_PROFILE", "false")
+
+	data := login(t)
+
+	assert.NotContains(t, data, "user")
+}
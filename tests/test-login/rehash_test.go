@@ -0,0 +1,59 @@
+package test_login
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// storedHash returns the stored hash of the password of the admin user.
+func storedHash(t *testing.T, db *gorm.DB) string {
+	var hash string
+	require.NoError(t, db.Table("users").Where("username = ?", "admin").Pluck("password", &hash).Error)
+	return hash
+}
+
+// useArgon2id configures the hashing of the passwords with Argon2id, with parameters cheap enough for the tests.
+func useArgon2id(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	t.Setenv("ARGON2_MEMORY_KIB", "1024")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+}
+
+func TestLogin_RehashesWithCurrentAlgorithm(t *testing.T) {
+	db := setupDatabase(t)
+	useArgon2id(t)
+
+	// A wrong password does not upgrade the hash
+	bcryptHash := storedHash(t, db)
+	require.Equal(t, http.StatusUnauthorized, postLogin(t, "WrongP@ssw0rd").Code)
+	assert.Equal(t, bcryptHash, storedHash(t, db))
+
+	// The bcrypt hash verifies, and is replaced at the login with the Argon2id hash of the same password
+	require.Equal(t, http.StatusOK, postLogin(t, testPassword).Code)
+	argon2Hash := storedHash(t, db)
+	assert.True(t, strings.HasPrefix(argon2Hash, "$argon2id$v=19$m=1024,t=1,p=1$"), argon2Hash)
+
+	// The upgraded hash verifies and is kept as long as the settings do not change
+	require.Equal(t, http.StatusOK, postLogin(t, testPassword).Code)
+	assert.Equal(t, argon2Hash, storedHash(t, db))
+
+	// New parameters upgrade the hash again
+	t.Setenv("ARGON2_ITERATIONS", "2")
+	require.Equal(t, http.StatusOK, postLogin(t, testPassword).Code)
+	assert.True(t, strings.HasPrefix(storedHash(t, db), "$argon2id$v=19$m=1024,t=2,p=1$"))
+
+	// Going back to bcrypt, the Argon2id hash still verifies and is replaced with a bcrypt hash
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "bcrypt")
+	t.Setenv("BCRYPT_COST", "5")
+	require.Equal(t, http.StatusOK, postLogin(t, testPassword).Code)
+	cost, err := bcrypt.Cost([]byte(storedHash(t, db)))
+	require.NoError(t, err)
+	assert.Equal(t, 5, cost)
+}
@@ -0,0 +1,83 @@
+package test_login
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+)
+
+// setTokenEnv sets the signing of the tokens, the refresh tokens expire after a day.
+func setTokenEnv(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_EXPIRATION_HOUR", "1")
+	t.Setenv("JWT_REFRESH_TOKEN_EXPIRATION_HOUR", "24")
+	t.Setenv("MAX_SESSIONS_PER_USER", "0")
+}
+
+func TestAccessToken_ExpiresAtTheClock(t *testing.T) {
+	setupDatabase(t)
+	setTokenEnv(t)
+	start := time.Date(2031, time.January, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	s := service.NewAuthService(clk)
+
+	login, err := s.Login(entity.LoginRequest{Username: "admin", Password: testPassword})
+	require.NoError(t, err)
+	expiresAt := time.Unix(service.GetJWTExpiration(start.Unix()), 0).UTC()
+	assert.Equal(t, expiresAt.Format(time.RFC3339), login.ExpirationDate)
+
+	// The access token is valid up to its expiry
+	clk.Advance(expiresAt.Sub(start) - time.Second)
+	_, err = service.ParseJWTToken(login.AccessToken, jwt.WithTimeFunc(clk.Now))
+	assert.NoError(t, err)
+
+	// The access token refreshed then is issued at the time of the refresh
+	refreshed, err := s.RefreshToken(entity.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(service.GetJWTExpiration(clk.Now().Unix()), 0).UTC().Format(time.RFC3339), refreshed.ExpirationDate)
+
+	// Past the expiry, only the refreshed access token is valid
+	clk.Advance(2 * time.Second)
+	_, err = service.ParseJWTToken(login.AccessToken, jwt.WithTimeFunc(clk.Now))
+	assert.ErrorContains(t, err, jwt.ErrTokenExpired.Error())
+	_, err = service.ParseJWTToken(refreshed.AccessToken, jwt.WithTimeFunc(clk.Now))
+	assert.NoError(t, err)
+}
+
+func TestRefreshToken_ExpiresAtTheClock(t *testing.T) {
+	setupDatabase(t)
+	setTokenEnv(t)
+	clk := clock.NewFake(time.Date(2031, time.January, 1, 9, 0, 0, 0, time.UTC))
+	s := service.NewAuthService(clk)
+
+	login, err := s.Login(entity.LoginRequest{Username: "admin", Password: testPassword})
+	require.NoError(t, err)
+
+	// The refresh token is accepted up to its expiry, a day after the login, and rotated
+	clk.Advance(24 * time.Hour)
+	refreshed, err := s.RefreshToken(entity.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	require.NoError(t, err)
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+
+	// The rotated refresh token expires a day after the refresh
+	clk.Advance(24*time.Hour + time.Second)
+	_, err = s.RefreshToken(entity.RefreshTokenRequest{RefreshToken: refreshed.RefreshToken})
+	assert.EqualError(t, err, "refresh token is expired")
+
+	// The expired sessions are removed at the next login, which starts a session of a day again
+	login, err = s.Login(entity.LoginRequest{Username: "admin", Password: testPassword})
+	require.NoError(t, err)
+	_, err = s.RefreshToken(entity.RefreshTokenRequest{RefreshToken: refreshed.RefreshToken})
+	assert.Error(t, err)
+	_, err = s.RefreshToken(entity.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,104 @@
+package test_meta_context
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+)
+
+// authenticate runs the JWT validation on a request carrying the token,
+// and returns the user information injected into the request context.
+func authenticate(t *testing.T, token string) metacontext.UserInformationMeta {
+	gin.SetMode(gin.TestMode)
+
+	var meta metacontext.UserInformationMeta
+	router := gin.New()
+	router.Use(authorization.JwtValidation())
+	router.GET("/me", func(c *gin.Context) {
+		var ok bool
+		meta, ok = metacontext.ExtractUserInformationMeta(c.Request.Context())
+		require.True(t, ok)
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	return meta
+}
+
+func TestJwtValidation_PopulatesMeta(t *testing.T) {
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	service.JWTSecret = "test-secret"
+
+	user := entity.User{
+		ID:       7,
+		TenantID: 2,
+		Username: "admin",
+		Email:    "admin@mygmail.com",
+		Roles:    []entity.Role{{Name: "ROLE_ADMIN"}},
+	}
+	first, err := service.GenerateJWTTokenWithHS256(user, time.Now())
+	require.NoError(t, err)
+	second, err := service.GenerateJWTTokenWithHS256(user, time.Now())
+	require.NoError(t, err)
+
+	meta := authenticate(t, first)
+	assert.Equal(t, int64(7), meta.UserID)
+	assert.Equal(t, "admin", meta.Username)
+	assert.Equal(t, "admin@mygmail.com", meta.Email)
+	assert.Equal(t, []string{"ROLE_ADMIN"}, meta.Roles)
+	assert.Equal(t, int64(2), meta.TenantID)
+	assert.False(t, meta.IsSystem)
+	assert.NotEmpty(t, meta.TokenID)
+
+	// Every token has its own ID
+	assert.NotEqual(t, meta.TokenID, authenticate(t, second).TokenID)
+}
+
+func TestExtractUserInformationMeta_UserIDOnly(t *testing.T) {
+	// The metadata injected by older code carries the user ID only
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{UserID: 7})
+
+	meta, ok := metacontext.ExtractUserInformationMeta(ctx)
+	require.True(t, ok)
+	assert.Equal(t, int64(7), meta.UserID)
+	assert.Equal(t, metacontext.DefaultTenantID, meta.TenantID)
+	assert.Empty(t, meta.TokenID)
+	assert.Equal(t, "user:7", meta.Actor())
+
+	_, ok = metacontext.ExtractUserInformationMeta(context.Background())
+	assert.False(t, ok)
+}
+
+func TestWarnings_Collector(t *testing.T) {
+	// Without a collector the warnings are dropped
+	metacontext.AddWarning(context.Background(), "dropped")
+	assert.Nil(t, metacontext.ExtractWarnings(context.Background()))
+
+	// The warnings added through a derived context are collected in order
+	ctx := metacontext.InjectWarningCollector(context.Background())
+	assert.Nil(t, metacontext.ExtractWarnings(ctx))
+
+	derived, cancel := context.WithCancel(ctx)
+	defer cancel()
+	metacontext.AddWarning(derived, "first")
+	metacontext.AddWarning(ctx, "second")
+	assert.Equal(t, []string{"first", "second"}, metacontext.ExtractWarnings(ctx))
+}
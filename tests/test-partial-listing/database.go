@@ -0,0 +1,47 @@
+package test_partial_listing
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// userCount is the number of users of the test database.
+const userCount = 250
+
+// setupDatabase opens an SQLite database holding userCount users with the ROLE_USER role, the user 7 being deleted,
+// and makes the services use it instead of PostgreSQL. Every query of the users table waits for the returned delay,
+// so a listing can be made deliberately slow, it waits for nothing until the delay is set.
+func setupDatabase(t *testing.T) (*gorm.DB, *atomic.Int64) {
+	db := testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true)`,
+		fmt.Sprintf(`WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < %d)
+			INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired, is_account_non_locked, is_credentials_non_expired, user_type)
+			SELECT 'user' || n, '!', 'user' || n || '@mygmail.com', 'User', true, true, true, true, 'USER_ACCOUNT' FROM seq`, userCount),
+		`INSERT INTO user_roles (user_id, role_id) SELECT id, 1 FROM users`,
+		`UPDATE users SET is_deleted = true, deleted_by = 1, deleted_at = CURRENT_TIMESTAMP WHERE id = 7`,
+	)
+
+	// Slow the queries of the users down, the wait is aborted with the query when the request is cancelled
+	delay := &atomic.Int64{}
+	err := db.Callback().Query().Before("gorm:query").Register("test:slow_users", func(tx *gorm.DB) {
+		if tx.Statement.Table != "users" || delay.Load() == 0 {
+			return
+		}
+		select {
+		case <-time.After(time.Duration(delay.Load())):
+		case <-tx.Statement.Context.Done():
+			tx.AddError(tx.Statement.Context.Err())
+		}
+	})
+	if err != nil {
+		t.Fatalf("failed to register the slow query callback: %v", err)
+	}
+
+	return db, delay
+}
@@ -0,0 +1,200 @@
+package test_partial_listing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/timeout"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// listResponse is the body of a streamed list of users.
+type listResponse struct {
+	Message string                `json:"message"`
+	Status  int                   `json:"status"`
+	Data    []entity.UserResponse `json:"data"`
+	Cursor  *httputil.Cursor      `json:"cursor"`
+}
+
+// setupRouter registers the listing of the users behind the timeout middleware, authenticated as the admin.
+func setupRouter(requestTimeout time.Duration) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		ctx := metacontext.InjectUserInformationMeta(c.Request.Context(), metacontext.UserInformationMeta{
+			UserID: 1, Username: "admin", Roles: []string{"ROLE_ADMIN"}, TenantID: metacontext.DefaultTenantID,
+		})
+		c.Request = c.Request.WithContext(metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID))
+		c.Next()
+	})
+	router.Use(timeout.TimeoutWithConfig(timeout.TimeoutConfig{Default: requestTimeout}))
+
+	h := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository()))
+	router.GET("/api/v1/users", h.GetUsers)
+	return router
+}
+
+// list requests the users with the query and decodes the response.
+func list(t *testing.T, router *gin.Engine, query url.Values) (*httptest.ResponseRecorder, listResponse) {
+	req, _ := http.NewRequest("GET", "/api/v1/users?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp listResponse
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	}
+	return w, resp
+}
+
+// userIDs returns the IDs of the users.
+func userIDs(users []entity.UserResponse) []int64 {
+	ids := make([]int64, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+func TestStreamUsers_SlowListingReturnsPartialSet(t *testing.T) {
+	_, delay := setupDatabase(t)
+	router := setupRouter(250 * time.Millisecond)
+
+	// Every batch of 100 users takes 100ms, the 250 users cannot be listed within the timeout
+	delay.Store(int64(100 * time.Millisecond))
+	w, resp := list(t, router, url.Values{"partial": {"true"}, "limit": {"1000"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NotNil(t, resp.Cursor)
+	assert.True(t, resp.Cursor.Partial)
+	assert.NotEmpty(t, resp.Cursor.Next)
+	require.NotEmpty(t, resp.Data)
+	assert.Less(t, len(resp.Data), userCount-1)
+	assert.Equal(t, "Users retrieved successfully", resp.Message)
+	assert.NotContains(t, w.Body.String(), "password")
+
+	// Resuming from the cursor lists the remaining users, without repeating or skipping any
+	delay.Store(0)
+	first := userIDs(resp.Data)
+	w, resp = list(t, router, url.Values{"partial": {"true"}, "limit": {"1000"}, "cursor": {resp.Cursor.Next}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NotNil(t, resp.Cursor)
+	assert.False(t, resp.Cursor.Partial)
+	assert.Empty(t, resp.Cursor.Next)
+
+	ids := append(first, userIDs(resp.Data)...)
+	require.Len(t, ids, userCount-1)
+	for i, id := range ids {
+		assert.Less(t, int64(0), id)
+		if i > 0 {
+			assert.Less(t, ids[i-1], id)
+		}
+	}
+	assert.NotContains(t, ids, int64(7))
+}
+
+func TestStreamUsers_NothingFetchedBeforeTimeout(t *testing.T) {
+	_, delay := setupDatabase(t)
+	router := setupRouter(100 * time.Millisecond)
+
+	// Not even the first batch is fetched in time, there is nothing to return
+	delay.Store(int64(time.Second))
+	w, _ := list(t, router, url.Values{"partial": {"true"}})
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
+func TestStreamUsers_Limit(t *testing.T) {
+	setupDatabase(t)
+	router := setupRouter(5 * time.Second)
+
+	// The limit cuts the list without making it partial, the cursor resumes it
+	w, resp := list(t, router, url.Values{"partial": {"true"}, "limit": {"150"}, "includeDeleted": {"true"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, resp.Data, 150)
+	assert.Contains(t, userIDs(resp.Data), int64(7))
+	require.NotNil(t, resp.Cursor)
+	assert.False(t, resp.Cursor.Partial)
+	require.NotEmpty(t, resp.Cursor.Next)
+
+	w, resp = list(t, router, url.Values{"partial": {"true"}, "limit": {"150"}, "includeDeleted": {"true"}, "cursor": {resp.Cursor.Next}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, resp.Data, userCount-150)
+	assert.Equal(t, int64(151), resp.Data[0].ID)
+	assert.Empty(t, resp.Cursor.Next)
+
+	// An empty list is still a valid list
+	w, resp = list(t, router, url.Values{"partial": {"true"}, "cursor": {"MjUw"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotNil(t, resp.Data)
+	assert.Empty(t, resp.Data)
+	assert.Equal(t, &httputil.Cursor{}, resp.Cursor)
+}
+
+func TestStreamUsers_InvalidRequests(t *testing.T) {
+	setupDatabase(t)
+	router := setupRouter(5 * time.Second)
+
+	for _, query := range []url.Values{
+		{"partial": {"maybe"}},
+		{"partial": {"true"}, "cursor": {"not a cursor"}},
+		{"partial": {"true"}, "cursor": {"LTE"}},
+		{"partial": {"true"}, "modifiedSince": {"2025-01-31T23:59:59Z"}},
+		{"cursor": {"MjUw"}},
+	} {
+		w, _ := list(t, router, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query.Encode())
+	}
+}
+
+func TestStreamUsers_Service(t *testing.T) {
+	_, delay := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+	ctx := metacontext.InjectTenantID(context.Background(), metacontext.DefaultTenantID)
+
+	// Without a deadline the listing goes to its end
+	batches := 0
+	next, partial, err := s.StreamUsers(ctx, false, "", 1000, func(users []entity.User) error {
+		batches++
+		return nil
+	})
+	require.NoError(t, err)
+	assert.False(t, partial)
+	assert.Empty(t, next)
+	assert.Equal(t, 3, batches)
+
+	// Once most of the time left before the deadline is spent, the listing stops before the next batch
+	delay.Store(int64(10 * time.Millisecond))
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	emitted := 0
+	next, partial, err = s.StreamUsers(ctx, false, "", 1000, func(users []entity.User) error {
+		emitted += len(users)
+		if emitted == 100 {
+			time.Sleep(time.Until(deadlineOf(ctx)) - 30*time.Millisecond)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, partial)
+	assert.Equal(t, 100, emitted)
+	assert.NotEmpty(t, next)
+}
+
+// deadlineOf returns the deadline of the context.
+func deadlineOf(ctx context.Context) time.Time {
+	deadline, _ := ctx.Deadline()
+	return deadline
+}
@@ -0,0 +1,112 @@
+package test_password_hash
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/hash"
+)
+
+// timedHasher is a hasher whose hashes take the given latency on the fake clock, instead of the time of a real hash.
+type timedHasher struct {
+	clock   *clock.Fake
+	latency time.Duration
+	err     error
+}
+
+func (h timedHasher) Hash(password string) (string, error) {
+	h.clock.Advance(h.latency)
+	return "$2a$10$" + password, h.err
+}
+
+func (h timedHasher) Verify(hash string, password string) (bool, error) {
+	return false, nil
+}
+
+func (h timedHasher) NeedsRehash(hash string) bool {
+	return false
+}
+
+func TestLoadLatencyGuard(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+
+	guard := hash.LoadLatencyGuard(clk)
+	assert.Equal(t, hash.DefaultMinHashLatency, guard.MinLatency)
+	assert.Equal(t, hash.DefaultMaxHashLatency, guard.MaxLatency)
+	assert.False(t, guard.Refuse)
+
+	t.Setenv("PASSWORD_HASH_MIN_LATENCY_MS", "100")
+	t.Setenv("PASSWORD_HASH_MAX_LATENCY_MS", "500")
+	t.Setenv("PASSWORD_HASH_LATENCY_POLICY", "refuse")
+	guard = hash.LoadLatencyGuard(clk)
+	assert.Equal(t, 100*time.Millisecond, guard.MinLatency)
+	assert.Equal(t, 500*time.Millisecond, guard.MaxLatency)
+	assert.True(t, guard.Refuse)
+
+	// A band whose minimum is not below its maximum falls back to the default band
+	t.Setenv("PASSWORD_HASH_MIN_LATENCY_MS", "2000")
+	guard = hash.LoadLatencyGuard(clk)
+	assert.Equal(t, hash.DefaultMinHashLatency, guard.MinLatency)
+	assert.Equal(t, hash.DefaultMaxHashLatency, guard.MaxLatency)
+}
+
+func TestLatencyGuard_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		latency time.Duration
+		refuse  bool
+		wantErr error
+		warned  bool
+	}{
+		{"within the band", 250 * time.Millisecond, true, nil, false},
+		{"at the bounds", 50 * time.Millisecond, true, nil, false},
+		{"too fast, warned", 5 * time.Millisecond, false, nil, true},
+		{"too slow, warned", 3 * time.Second, false, nil, true},
+		{"too fast, refused", 5 * time.Millisecond, true, hash.ErrHashTooFast, false},
+		{"too slow, refused", 3 * time.Second, true, hash.ErrHashTooSlow, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := clock.NewFake(time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC))
+			guard := hash.LatencyGuard{MinLatency: 50 * time.Millisecond, MaxLatency: time.Second, Refuse: tt.refuse, Clock: clk}
+
+			var warnings []string
+			latency, err := guard.Check(timedHasher{clock: clk, latency: tt.latency}, func(message string) {
+				warnings = append(warnings, message)
+			})
+			assert.Equal(t, tt.latency, latency)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.warned, len(warnings) == 1, warnings)
+		})
+	}
+
+	// The message tells the latency, the bound and how to fix it
+	clk := clock.NewFake(time.Now())
+	guard := hash.LatencyGuard{MinLatency: 50 * time.Millisecond, MaxLatency: time.Second, Clock: clk}
+	var warning string
+	_, err := guard.Check(timedHasher{clock: clk, latency: 2 * time.Second}, func(message string) { warning = message })
+	require.NoError(t, err)
+	assert.Equal(t, "password hashing is slower than the maximum latency: a hash took 2s, above the maximum of 1s, lower the cost of the hashes", warning)
+
+	// A hasher failing is an error whatever the policy
+	_, err = guard.Check(timedHasher{clock: clk, err: errors.New("boom")}, func(string) {})
+	assert.ErrorContains(t, err, "failed to benchmark password hashing: boom")
+}
+
+func TestLatencyGuard_BcryptCost(t *testing.T) {
+	// A real hash at the minimum cost of bcrypt takes a few milliseconds, below a band of a second or more
+	guard := hash.LatencyGuard{MinLatency: time.Second, MaxLatency: time.Minute, Refuse: true, Clock: clock.New()}
+	_, err := guard.Check(hash.NewBcryptHasher(bcrypt.MinCost), func(string) {})
+	assert.ErrorIs(t, err, hash.ErrHashTooFast)
+}
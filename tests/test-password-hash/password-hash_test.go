@@ -0,0 +1,166 @@
+package test_password_hash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/hash"
+)
+
+// testArgon2Params are Argon2id parameters cheap enough for the tests.
+var testArgon2Params = hash.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func TestHasher_HashAndVerify(t *testing.T) {
+	hashers := map[string]hash.Hasher{
+		hash.Bcrypt:   hash.NewBcryptHasher(bcrypt.MinCost),
+		hash.Argon2id: hash.NewArgon2idHasher(testArgon2Params),
+	}
+
+	for name, hasher := range hashers {
+		t.Run(name, func(t *testing.T) {
+			hashed, err := hasher.Hash("P@ssw0rd123")
+			require.NoError(t, err)
+			assert.Equal(t, name, hash.AlgorithmOf(hashed))
+			assert.False(t, hasher.NeedsRehash(hashed))
+
+			ok, err := hasher.Verify(hashed, "P@ssw0rd123")
+			require.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = hasher.Verify(hashed, "WrongP@ssw0rd")
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			// Every hash has its own salt
+			again, err := hasher.Hash("P@ssw0rd123")
+			require.NoError(t, err)
+			assert.NotEqual(t, hashed, again)
+		})
+	}
+}
+
+func TestHasher_CrossAlgorithm(t *testing.T) {
+	bcryptHasher := hash.NewBcryptHasher(bcrypt.MinCost)
+	argon2Hasher := hash.NewArgon2idHasher(testArgon2Params)
+
+	bcryptHash, err := bcryptHasher.Hash("P@ssw0rd123")
+	require.NoError(t, err)
+	argon2Hash, err := argon2Hasher.Hash("P@ssw0rd123")
+	require.NoError(t, err)
+
+	// Each hasher verifies the hashes of the other algorithm, and reports them to upgrade
+	ok, err := argon2Hasher.Verify(bcryptHash, "P@ssw0rd123")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, argon2Hasher.NeedsRehash(bcryptHash))
+
+	ok, err = bcryptHasher.Verify(argon2Hash, "P@ssw0rd123")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, bcryptHasher.NeedsRehash(argon2Hash))
+
+	// The hashes with other parameters verify with their own parameters, and are reported to upgrade
+	stronger := hash.NewArgon2idHasher(hash.Argon2Params{Memory: 2048, Iterations: 2, Parallelism: 1})
+	ok, err = stronger.Verify(argon2Hash, "P@ssw0rd123")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, stronger.NeedsRehash(argon2Hash))
+	assert.True(t, hash.NewBcryptHasher(bcrypt.MinCost+1).NeedsRehash(bcryptHash))
+}
+
+func TestHasher_Argon2idFormat(t *testing.T) {
+	hashed, err := hash.NewArgon2idHasher(testArgon2Params).Hash("P@ssw0rd123")
+	require.NoError(t, err)
+
+	// The PHC string holds the algorithm, the version, the parameters, the salt and the key
+	parts := strings.Split(hashed, "$")
+	require.Len(t, parts, 6)
+	assert.Equal(t, []string{"", "argon2id", "v=19", "m=1024,t=1,p=1"}, parts[:4])
+	assert.Len(t, parts[4], 22)
+	assert.Len(t, parts[5], 43)
+}
+
+func TestVerify_MalformedHash(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+		err  error
+	}{
+		{"unknown algorithm", "$scrypt$ln=15,r=8,p=1$c2FsdA$a2V5", hash.ErrUnknownAlgorithm},
+		{"plain text", "P@ssw0rd123", hash.ErrUnknownAlgorithm},
+		{"truncated bcrypt", "$2a$10$short", hash.ErrMalformedHash},
+		{"missing argon2id key", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA", hash.ErrMalformedHash},
+		{"unsupported argon2id version", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5", hash.ErrMalformedHash},
+		{"invalid argon2id parameters", "$argon2id$v=19$m=0,t=1,p=1$c2FsdA$a2V5", hash.ErrMalformedHash},
+		{"invalid argon2id salt", "$argon2id$v=19$m=1024,t=1,p=1$!!$a2V5", hash.ErrMalformedHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := hash.Verify(tt.hash, "P@ssw0rd123")
+			assert.ErrorIs(t, err, tt.err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	// Without configuration, the passwords are hashed with bcrypt at its default cost
+	cfg := hash.LoadConfig()
+	assert.Equal(t, hash.Bcrypt, cfg.Algorithm)
+	assert.Equal(t, bcrypt.DefaultCost, cfg.BcryptCost)
+	assert.Equal(t, hash.DefaultArgon2Params, cfg.Argon2)
+	assert.IsType(t, &hash.BcryptHasher{}, hash.New(cfg))
+
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "Argon2id")
+	t.Setenv("BCRYPT_COST", "12")
+	t.Setenv("ARGON2_MEMORY_KIB", "19456")
+	t.Setenv("ARGON2_ITERATIONS", "2")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+	cfg = hash.LoadConfig()
+	assert.Equal(t, hash.Argon2id, cfg.Algorithm)
+	assert.Equal(t, 12, cfg.BcryptCost)
+	assert.Equal(t, hash.Argon2Params{Memory: 19456, Iterations: 2, Parallelism: 1}, cfg.Argon2)
+	assert.Equal(t, &hash.Argon2idHasher{Params: cfg.Argon2}, hash.FromEnv())
+
+	// The invalid values fall back to their defaults
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "md5")
+	t.Setenv("BCRYPT_COST", "64")
+	t.Setenv("ARGON2_MEMORY_KIB", "-1")
+	t.Setenv("ARGON2_PARALLELISM", "300")
+	cfg = hash.LoadConfig()
+	assert.Equal(t, hash.Bcrypt, cfg.Algorithm)
+	assert.Equal(t, bcrypt.DefaultCost, cfg.BcryptCost)
+	assert.Equal(t, hash.Argon2Params{Memory: 64 * 1024, Iterations: 2, Parallelism: 2}, cfg.Argon2)
+}
+
+func TestLoadConfig_BcryptMaxCost(t *testing.T) {
+	cfg := hash.LoadConfig()
+	assert.Equal(t, hash.DefaultBcryptMaxCost, cfg.BcryptMaxCost)
+
+	// A cost above the maximum is capped
+	t.Setenv("BCRYPT_COST", "16")
+	cfg = hash.LoadConfig()
+	assert.Equal(t, hash.DefaultBcryptMaxCost, cfg.BcryptCost)
+	assert.Equal(t, &hash.BcryptHasher{Cost: hash.DefaultBcryptMaxCost}, hash.FromEnv())
+
+	t.Setenv("BCRYPT_MAX_COST", "16")
+	assert.Equal(t, 16, hash.LoadConfig().BcryptCost)
+
+	// The default cost is capped as well
+	t.Setenv("BCRYPT_COST", "")
+	t.Setenv("BCRYPT_MAX_COST", "8")
+	assert.Equal(t, 8, hash.LoadConfig().BcryptCost)
+
+	// An invalid maximum falls back to its default
+	t.Setenv("BCRYPT_COST", "12")
+	for _, value := range []string{"2", "32", "high"} {
+		t.Setenv("BCRYPT_MAX_COST", value)
+		cfg = hash.LoadConfig()
+		assert.Equal(t, hash.DefaultBcryptMaxCost, cfg.BcryptMaxCost, value)
+		assert.Equal(t, 12, cfg.BcryptCost, value)
+	}
+}
@@ -0,0 +1,18 @@
+package test_password_history
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with the tables of the migration,
+// and makes the services use it instead of PostgreSQL.
+// ROLE_USER is the only default role.
+func setupDatabase(t *testing.T) *gorm.DB {
+	return testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_MODERATOR', false), ('ROLE_ADMIN', false)`,
+	)
+}
@@ -0,0 +1,93 @@
+package test_password_history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+// adminContext returns a context carrying the admin performing the requests.
+func adminContext() context.Context {
+	return metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{UserID: 1, Username: "admin"})
+}
+
+// createUser creates a user with the given password and returns its ID.
+func createUser(t *testing.T, s service.UserService, password string) int64 {
+	created, err := s.CreateUser(adminContext(), entity.UserCreateRequest{
+		Username:  "newuser",
+		Password:  password,
+		Email:     "newuser@mygmail.com",
+		Firstname: "New",
+		UserType:  "USER_ACCOUNT",
+	})
+	require.NoError(t, err)
+	return created.ID
+}
+
+func TestResetUserPassword_RecentPasswordRejected(t *testing.T) {
+	setupDatabase(t)
+	t.Setenv("PASSWORD_HISTORY_SIZE", "3")
+	s := service.NewUserService(repository.NewUserRepository())
+
+	// The history holds password-1 (initial), password-2 and password-3 (current)
+	id := createUser(t, s, "password-1")
+	for _, password := range []string{"password-2", "password-3"} {
+		_, err := s.ResetUserPassword(adminContext(), id, password)
+		require.NoError(t, err)
+	}
+
+	for _, password := range []string{"password-1", "password-2", "password-3"} {
+		t.Run(password, func(t *testing.T) {
+			_, err := s.ResetUserPassword(adminContext(), id, password)
+			assert.True(t, errors.Is(err, service.ErrPasswordReused))
+		})
+	}
+
+	// The password is left unchanged by the rejected resets
+	user, err := s.GetUserByID(id)
+	require.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("password-3")))
+}
+
+func TestResetUserPassword_OlderPasswordAllowed(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("PASSWORD_HISTORY_SIZE", "2")
+	s := service.NewUserService(repository.NewUserRepository())
+
+	id := createUser(t, s, "password-1")
+	for i := 2; i <= 4; i++ {
+		_, err := s.ResetUserPassword(adminContext(), id, fmt.Sprintf("password-%d", i))
+		require.NoError(t, err)
+	}
+
+	// The entries beyond the history size are pruned
+	var count int64
+	require.NoError(t, db.Model(&entity.PasswordHistory{}).Where("user_id = ?", id).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+
+	// password-2 is older than the last 2 passwords, it can be reused
+	_, err := s.ResetUserPassword(adminContext(), id, "password-2")
+	require.NoError(t, err)
+
+	_, err = s.ResetUserPassword(adminContext(), id, "password-4")
+	assert.True(t, errors.Is(err, service.ErrPasswordReused))
+}
+
+func TestResetUserPassword_UnknownUser(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	_, err := s.ResetUserPassword(adminContext(), 99, "password-1")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+}
@@ -0,0 +1,95 @@
+package test_path_param
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+)
+
+// invalidIDs are the path parameters rejected as IDs: not a number, zero, negative and overflowing an int64.
+var invalidIDs = []string{"abc", "0", "-1", "9223372036854775808"}
+
+// setupRouter registers a route validating its :id, whose handler records the ID it retrieved.
+func setupRouter(reached *[]int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/users/:id", pathparam.PathInt64("id"), func(c *gin.Context) {
+		id, ok := pathparam.Int64(c, "id")
+		if !ok {
+			return
+		}
+		*reached = append(*reached, id)
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+// assertBadRequest asserts the response is the 400 Bad Request of an invalid ID.
+func assertBadRequest(t *testing.T, w *httptest.ResponseRecorder) {
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var body struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "Invalid ID", body.Message)
+	assert.Equal(t, "ID must be a positive integer", body.Error)
+}
+
+func TestPathInt64_Valid(t *testing.T) {
+	var reached []int64
+	router := setupRouter(&reached)
+
+	req, _ := http.NewRequest("GET", "/users/42", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []int64{42}, reached)
+}
+
+func TestPathInt64_Invalid(t *testing.T) {
+	for _, id := range invalidIDs {
+		t.Run(id, func(t *testing.T) {
+			var reached []int64
+			router := setupRouter(&reached)
+
+			req, _ := http.NewRequest("GET", "/users/"+id, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// The request is answered before the handler
+			assertBadRequest(t, w)
+			assert.Empty(t, reached)
+		})
+	}
+}
+
+func TestPathInt64_WithoutMiddleware(t *testing.T) {
+	// A handler registered without the middleware validates the ID itself, with the same response,
+	// so an invalid ID never reaches the service (none is given here)
+	h := handler.NewUserHandler(nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.DELETE("/users/:id/purge", h.PurgeUser)
+
+	for _, id := range invalidIDs {
+		t.Run(id, func(t *testing.T) {
+			req, _ := http.NewRequest("DELETE", "/users/"+id+"/purge", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assertBadRequest(t, w)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package test_permission
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with the tables of the migration and makes
+// the services use it instead of PostgreSQL. It holds the admin (ID 1) with the ROLE_ADMIN role, bob (ID 2)
+// with the ROLE_USER and ROLE_MODERATOR roles, and carol (ID 3) with the ROLE_USER role. ROLE_USER grants
+// consumers:read, ROLE_MODERATOR consumers:read and consumers:moderate, and ROLE_ADMIN every permission.
+func setupDatabase(t *testing.T) *gorm.DB {
+	db := testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_MODERATOR', false), ('ROLE_ADMIN', false)`,
+		`INSERT INTO permissions (name) VALUES ('consumers:read'), ('consumers:moderate'), ('users:write')`,
+		`INSERT INTO role_permissions (role_id, permission_id) VALUES (1, 1), (2, 1), (2, 2), (3, 1), (3, 2), (3, 3)`,
+		`INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired,
+			is_account_non_locked, is_credentials_non_expired, user_type) VALUES
+			('admin', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'admin@mygmail.com', 'Admin', true, true, true, true, 'USER_ACCOUNT'),
+			('bob', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'bob@mygmail.com', 'Bob', true, true, true, true, 'USER_ACCOUNT'),
+			('carol', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'carol@mygmail.com', 'Carol', true, true, true, true, 'USER_ACCOUNT')`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES (1, 3), (2, 1), (2, 2), (3, 1)`,
+	)
+
+	// Record the actor of the writes like the PostgreSQL connection does
+	if err := database.RegisterAuditCallbacks(db); err != nil {
+		t.Fatalf("failed to register the audit callbacks: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,143 @@
+package test_permission
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+const (
+	adminID int64 = 1
+	bobID   int64 = 2
+	carolID int64 = 3
+)
+
+// userContext returns a context authenticated as the user of the default tenant with the given roles.
+func userContext(userID int64, roles ...string) context.Context {
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: userID, Username: "user-" + strconv.FormatInt(userID, 10), Roles: roles, TenantID: metacontext.DefaultTenantID,
+	})
+	return metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID)
+}
+
+func TestGetUserPermissions_OverlappingRoles(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewPermissionService(repository.NewPermissionRepository())
+
+	// consumers:read is granted by both ROLE_USER and ROLE_MODERATOR, it is only returned once
+	permissions, err := s.GetUserPermissions(userContext(bobID, "ROLE_USER", "ROLE_MODERATOR"), bobID)
+	require.NoError(t, err)
+	assert.Equal(t, bobID, permissions.UserID)
+	assert.Equal(t, []string{"consumers:moderate", "consumers:read"}, permissions.Permissions)
+
+	permissions, err = s.GetUserPermissions(userContext(adminID, "ROLE_ADMIN"), carolID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"consumers:read"}, permissions.Permissions)
+}
+
+func TestGetUserPermissions_Access(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewPermissionService(repository.NewPermissionRepository())
+
+	// The users can only read their own permissions, the admins those of anyone
+	_, err := s.GetUserPermissions(userContext(carolID, "ROLE_USER"), bobID)
+	assert.ErrorIs(t, err, service.ErrPermissionAccessForbidden)
+
+	_, err = s.GetUserPermissions(userContext(adminID, "ROLE_ADMIN"), 99)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestGetUserPermissions_InvalidatedOnRoleChange(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewPermissionService(repository.NewPermissionRepository())
+	users := service.NewUserService(repository.NewUserRepository())
+	admin := userContext(adminID, "ROLE_ADMIN")
+
+	permissions, err := s.GetUserPermissions(admin, carolID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"consumers:read"}, permissions.Permissions)
+
+	// The permissions are cached, a change bypassing the services is not seen
+	require.NoError(t, db.Exec(`DELETE FROM user_roles WHERE user_id = ?`, carolID).Error)
+	permissions, err = s.GetUserPermissions(admin, carolID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"consumers:read"}, permissions.Permissions)
+	require.NoError(t, db.Exec(`INSERT INTO user_roles (user_id, role_id) VALUES (?, 1)`, carolID).Error)
+
+	// Changing the roles drops the cached permissions
+	_, err = users.PatchUserRoles(admin, carolID, entity.UserRolesPatchRequest{Add: []string{"ROLE_MODERATOR"}})
+	require.NoError(t, err)
+	permissions, err = s.GetUserPermissions(admin, carolID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"consumers:moderate", "consumers:read"}, permissions.Permissions)
+
+	_, err = users.UpdateUserRoles(admin, carolID, entity.UserRolesRequest{Roles: []string{"ROLE_USER"}})
+	require.NoError(t, err)
+	permissions, err = s.GetUserPermissions(admin, carolID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"consumers:read"}, permissions.Permissions)
+}
+
+func TestGetUserPermissions_ExpiringRole(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewPermissionService(repository.NewPermissionRepository())
+	admin := userContext(adminID, "ROLE_ADMIN")
+
+	expiresAt := time.Now().Add(time.Second)
+	_, err := service.NewUserService(repository.NewUserRepository()).PatchUserRoles(admin, carolID,
+		entity.UserRolesPatchRequest{Add: []string{"ROLE_MODERATOR"}, ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+
+	permissions, err := s.GetUserPermissions(admin, carolID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"consumers:moderate", "consumers:read"}, permissions.Permissions)
+
+	// The cached permissions are dropped once the role granting them expires
+	time.Sleep(time.Until(expiresAt))
+	permissions, err = s.GetUserPermissions(admin, carolID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"consumers:read"}, permissions.Permissions)
+}
+
+func TestGetUserPermissions_Handler(t *testing.T) {
+	setupDatabase(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := handler.NewPermissionHandler(service.NewPermissionService(repository.NewPermissionRepository()))
+	router.GET("/users/:id/permissions", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(userContext(bobID, "ROLE_USER", "ROLE_MODERATOR"))
+		c.Next()
+	}, h.GetUserPermissions)
+
+	request := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/users/2/permissions")
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data entity.UserPermissionsResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, []string{"consumers:moderate", "consumers:read"}, body.Data.Permissions)
+
+	assert.Equal(t, http.StatusForbidden, request("/users/3/permissions").Code)
+}
@@ -0,0 +1,121 @@
+package test_permission
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+)
+
+// setupPermissionRouter returns a router guarding /read with consumers:read and /moderate with consumers:moderate,
+// behind the JWT validation. The lookups of the permissions are counted.
+func setupPermissionRouter(t *testing.T) (*gin.Engine, *int) {
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("JWT_SECRET", "secret")
+	service.JWTSecret = "secret"
+
+	lookups := 0
+	authorization.SetPermissionResolver(func(ctx context.Context, userID int64) ([]string, error) {
+		lookups++
+		return service.ResolveUserPermissions(ctx, userID)
+	})
+	t.Cleanup(func() { authorization.SetPermissionResolver(nil) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(authorization.JwtValidation())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/read", authorization.RequirePermission("consumers:read"), ok)
+	router.GET("/moderate", authorization.RequirePermission("consumers:moderate"), ok)
+	router.GET("/both", authorization.RequirePermission("consumers:read"), authorization.RequirePermission("consumers:moderate"), ok)
+	return router, &lookups
+}
+
+// requestWith sends a GET request to the path with the token and returns the status code.
+func requestWith(router *gin.Engine, path string, token string) int {
+	req, _ := http.NewRequest("GET", path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+// tokenOf issues an access token to the user, with the given embedded permissions, none if nil.
+func tokenOf(t *testing.T, userID int64, permissions []string) string {
+	user, err := service.NewUserService(repository.NewUserRepository()).GetUserByID(userID)
+	require.NoError(t, err)
+	user.Permissions = permissions
+
+	token, err := service.GenerateJWTTokenWithHS256(user, time.Now())
+	require.NoError(t, err)
+	return token
+}
+
+func TestRequirePermission_FromClaims(t *testing.T) {
+	setupDatabase(t)
+	router, lookups := setupPermissionRouter(t)
+
+	// The embedded permissions are trusted as they are, even if the roles changed since
+	token := tokenOf(t, carolID, []string{"consumers:read"})
+	assert.Equal(t, http.StatusOK, requestWith(router, "/read", token))
+	assert.Equal(t, http.StatusForbidden, requestWith(router, "/moderate", token))
+
+	// A token embedding no permission is denied without a lookup
+	assert.Equal(t, http.StatusForbidden, requestWith(router, "/read", tokenOf(t, carolID, []string{})))
+	assert.Zero(t, *lookups)
+}
+
+func TestRequirePermission_FromLookup(t *testing.T) {
+	setupDatabase(t)
+	router, lookups := setupPermissionRouter(t)
+
+	// A token without permissions falls back to the lookup, once per request
+	token := tokenOf(t, bobID, nil)
+	assert.Equal(t, http.StatusOK, requestWith(router, "/both", token))
+	assert.Equal(t, 1, *lookups)
+
+	token = tokenOf(t, carolID, nil)
+	assert.Equal(t, http.StatusOK, requestWith(router, "/read", token))
+	assert.Equal(t, http.StatusForbidden, requestWith(router, "/moderate", token))
+	assert.Equal(t, 3, *lookups)
+}
+
+func TestRequirePermission_ExpiredRoleFallsBackToLookup(t *testing.T) {
+	setupDatabase(t)
+	router, lookups := setupPermissionRouter(t)
+
+	expiresAt := time.Now().Add(1500 * time.Millisecond)
+	_, err := service.NewUserService(repository.NewUserRepository()).PatchUserRoles(userContext(adminID, "ROLE_ADMIN"), carolID,
+		entity.UserRolesPatchRequest{Add: []string{"ROLE_MODERATOR"}, ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+
+	token := tokenOf(t, carolID, []string{"consumers:moderate", "consumers:read"})
+	assert.Equal(t, http.StatusOK, requestWith(router, "/moderate", token))
+	assert.Zero(t, *lookups)
+
+	// Once a role of the token expired, its embedded permissions are no longer trusted
+	time.Sleep(time.Until(expiresAt))
+	assert.Equal(t, http.StatusForbidden, requestWith(router, "/moderate", token))
+	assert.Equal(t, http.StatusOK, requestWith(router, "/read", token))
+	assert.Equal(t, 2, *lookups)
+}
+
+func TestRequirePermission_NoResolver(t *testing.T) {
+	setupDatabase(t)
+	router, _ := setupPermissionRouter(t)
+	authorization.SetPermissionResolver(nil)
+
+	assert.Equal(t, http.StatusInternalServerError, requestWith(router, "/read", tokenOf(t, carolID, nil)))
+	assert.Equal(t, http.StatusOK, requestWith(router, "/read", tokenOf(t, carolID, []string{"consumers:read"})))
+}
@@ -0,0 +1,24 @@
+package test_preflight
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with the tables of the migration and makes the services use it instead of PostgreSQL.
+// It holds the system user (ID 0) and the roles of the seed file.
+func setupDatabase(t *testing.T) *gorm.DB {
+	return testdb.Open(t,
+		`INSERT INTO tenants (id, name) VALUES (1, 'Default')`,
+		`INSERT INTO users (id, tenant_id, username, password, email, firstname, is_enabled, is_deleted, user_type, metadata)
+			VALUES (0, 1, 'system', '!', 'system@localhost', 'System', false, false, 'SERVICE_ACCOUNT', '{}')`,
+		`INSERT INTO roles (id, name, is_default) VALUES
+			(1, 'ROLE_USER', true),
+			(2, 'ROLE_MODERATOR', false),
+			(3, 'ROLE_ADMIN', false),
+			(4, 'ROLE_SUPER_ADMIN', false)`,
+	)
+}
@@ -0,0 +1,233 @@
+package test_preflight
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+)
+
+// now is the time of the preflights of the tests.
+var now = time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+
+// setupJWT configures the HS256 signing of the tokens, the configuration of the auth service is loaded once.
+func setupJWT(t *testing.T) {
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("JWT_SECRET", "a-secret-of-at-least-thirty-two-characters")
+	t.Setenv("JWT_KEY_ID", "2025-06")
+	t.Setenv("JWT_PREVIOUS_KEYS", "")
+	service.LoadEnv()
+}
+
+// checks returns the status of the checks of the report by name.
+func checks(report entity.PreflightReport) map[string]string {
+	statuses := make(map[string]string)
+	for _, check := range report.Checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+// detail returns the detail of the check of the report with the given name.
+func detail(report entity.PreflightReport, name string) string {
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check.Detail
+		}
+	}
+	return ""
+}
+
+// writeKey writes the PEM block of a key to a file and returns its path.
+func writeKey(t *testing.T, name string, block *pem.Block) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0600))
+	return path
+}
+
+func TestPreflight_Passed(t *testing.T) {
+	setupJWT(t)
+	setupDatabase(t)
+
+	report := service.NewPreflightService(clock.NewFake(now)).Run(context.Background())
+	assert.True(t, report.Passed, report.String())
+	assert.Equal(t, now, report.CheckedAt)
+	assert.Equal(t, []string{"database", "schema", "jwt_keys", "system_user", "roles"}, func() []string {
+		var names []string
+		for _, check := range report.Checks {
+			names = append(names, check.Name)
+		}
+		return names
+	}())
+	assert.Empty(t, report.Failed())
+
+	assert.Contains(t, detail(report, "jwt_keys"), "the HS256 key 2025-06 signs tokens it verifies")
+	assert.Equal(t, "the roles ROLE_USER, ROLE_ADMIN, ROLE_SUPER_ADMIN exist", detail(report, "roles"))
+
+	// The report printed at startup has a line per check
+	lines := strings.Split(report.String(), "\n")
+	require.Len(t, lines, 6)
+	assert.Equal(t, "Preflight PASSED (5 checks)", lines[0])
+	assert.Equal(t, "  [PASSED]  database: the sqlite database answers", lines[1])
+}
+
+func TestPreflight_ReportsEveryFailure(t *testing.T) {
+	setupJWT(t)
+	db := setupDatabase(t)
+
+	// The roles of the configuration must exist as well
+	t.Setenv("DEFAULT_USER_ROLE", "role_auditor")
+
+	require.NoError(t, db.Exec(`DROP TABLE user_notes`).Error)
+	require.NoError(t, db.Exec(`ALTER TABLE users DROP COLUMN max_sessions`).Error)
+	require.NoError(t, db.Exec(`DELETE FROM roles WHERE name = 'ROLE_SUPER_ADMIN'`).Error)
+	require.NoError(t, db.Exec(`UPDATE users SET username = 'robot' WHERE id = 0`).Error)
+
+	report := service.NewPreflightService(clock.NewFake(now)).Run(context.Background())
+	assert.False(t, report.Passed)
+	assert.Equal(t, map[string]string{
+		"database":    entity.PreflightPassed,
+		"schema":      entity.PreflightFailed,
+		"jwt_keys":    entity.PreflightPassed,
+		"system_user": entity.PreflightFailed,
+		"roles":       entity.PreflightFailed,
+	}, checks(report))
+	assert.Len(t, report.Failed(), 3)
+
+	// Every problem is listed, not only the first one
+	assert.Contains(t, detail(report, "schema"), "missing column users.max_sessions, table user_notes")
+	assert.Contains(t, detail(report, "system_user"), `the user with the ID 0 is "robot" instead of the system user`)
+	assert.Equal(t, "the roles ROLE_SUPER_ADMIN, ROLE_AUDITOR do not exist, the database was not seeded", detail(report, "roles"))
+
+	lines := strings.Split(report.String(), "\n")
+	assert.Equal(t, "Preflight FAILED (3 of 5 checks did not pass)", lines[0])
+	assert.True(t, strings.HasPrefix(lines[2], "  [FAILED]  schema: the database schema does not match the migration"), lines[2])
+
+	// A database without the system user was not migrated
+	require.NoError(t, db.Exec(`DELETE FROM users WHERE id = 0`).Error)
+	report = service.NewPreflightService(clock.NewFake(now)).Run(context.Background())
+	assert.Equal(t, "the system user (ID 0) does not exist, the database was not migrated", detail(report, "system_user"))
+}
+
+func TestPreflight_DatabaseUnavailable(t *testing.T) {
+	setupJWT(t)
+	database.SetPostgres(nil)
+
+	// The checks reading the database are skipped, the others still run
+	report := service.NewPreflightService(clock.NewFake(now)).Run(context.Background())
+	assert.False(t, report.Passed)
+	assert.Equal(t, map[string]string{
+		"database":    entity.PreflightFailed,
+		"schema":      entity.PreflightSkipped,
+		"jwt_keys":    entity.PreflightPassed,
+		"system_user": entity.PreflightSkipped,
+		"roles":       entity.PreflightSkipped,
+	}, checks(report))
+	assert.Equal(t, "skipped, the database does not answer", detail(report, "schema"))
+}
+
+func TestPreflight_KeyPairMismatch(t *testing.T) {
+	setupJWT(t)
+	setupDatabase(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherDER, err := x509.MarshalPKIXPublicKey(&other.PublicKey)
+	require.NoError(t, err)
+
+	// The algorithm is loaded once, it is switched to RS256 for this test only
+	service.SigningMethod = "RS256"
+	t.Cleanup(func() { service.SigningMethod = "HS256" })
+	t.Setenv("JWT_PRIVATE_KEY_PATH", writeKey(t, "private.pem", &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	t.Setenv("JWT_PUBLIC_KEY_PATH", writeKey(t, "public.pem", &pem.Block{Type: "PUBLIC KEY", Bytes: otherDER}))
+
+	report := service.NewPreflightService(clock.NewFake(now)).Run(context.Background())
+	assert.False(t, report.Passed)
+	assert.Equal(t, entity.PreflightFailed, checks(report)["jwt_keys"])
+	assert.Equal(t, "failed to load the RS256 keys: the public key does not match the private key", detail(report, "jwt_keys"))
+
+	// The public key of the pair passes
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	t.Setenv("JWT_PUBLIC_KEY_PATH", writeKey(t, "public.pem", &pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	report = service.NewPreflightService(clock.NewFake(now)).Run(context.Background())
+	assert.True(t, report.Passed, report.String())
+}
+
+func TestVerifySchema_Fingerprint(t *testing.T) {
+	db := setupDatabase(t)
+
+	fingerprint, err := database.VerifySchema(db, database.MigratedModels()...)
+	require.NoError(t, err)
+	assert.Len(t, fingerprint, 12)
+
+	// The fingerprint is the one of the expected schema, whatever the database holds
+	require.NoError(t, db.Exec(`DROP TABLE consumers`).Error)
+	missing, err := database.VerifySchema(db, database.MigratedModels()...)
+	assert.ErrorIs(t, err, database.ErrSchemaMismatch)
+	assert.Equal(t, fingerprint, missing)
+
+	// It changes with the tables of the migration
+	fewer, err := database.VerifySchema(db, database.MigratedModels()[:3]...)
+	require.NoError(t, err)
+	assert.NotEqual(t, fingerprint, fewer)
+}
+
+func TestSelfCheck_Handler(t *testing.T) {
+	setupJWT(t)
+	db := setupDatabase(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := handler.NewPreflightHandler(service.NewPreflightService(clock.NewFake(now)))
+	router.GET("/api/v1/admin/selfcheck", h.GetSelfCheck)
+
+	selfCheck := func() (int, map[string]any) {
+		req, _ := http.NewRequest("GET", "/api/v1/admin/selfcheck", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+		return w.Code, body
+	}
+
+	status, body := selfCheck()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Self-check passed", body["message"])
+	data := body["data"].(map[string]any)
+	assert.Equal(t, true, data["passed"])
+	assert.Len(t, data["checks"], 5)
+
+	// The checks that did not pass are listed in the error of a 503
+	require.NoError(t, db.Exec(`DELETE FROM roles WHERE name = 'ROLE_ADMIN'`).Error)
+	status, body = selfCheck()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "Self-check failed", body["message"])
+	assert.Equal(t, []any{map[string]any{
+		"name":    "roles",
+		"status":  entity.PreflightFailed,
+		"message": "the role ROLE_ADMIN does not exist, the database was not seeded",
+	}}, body["error"])
+}
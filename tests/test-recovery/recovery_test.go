@@ -0,0 +1,122 @@
+package test_recovery
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/logger"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/compression"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/headers"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/recovery"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/timeout"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// setupRouter registers routes behind the recovery middleware and the middlewares wrapping the response writer,
+// one of them dereferencing the unset last login of a user.
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(
+		recovery.Recovery(),
+		headers.SecurityHeaders(),
+		timeout.TimeoutWithConfig(timeout.TimeoutConfig{Default: time.Second}),
+		compression.CompressionWithConfig(compression.CompressionConfig{Level: gzip.DefaultCompression, MinSize: 1}),
+	)
+	router.GET("/api/v1/panic", func(c *gin.Context) {
+		var user entity.User
+		httputil.Success(c, "Last login", user.LastLogin.UTC())
+	})
+	router.GET("/api/v1/ok", func(c *gin.Context) {
+		httputil.Success(c, "Done", nil)
+	})
+	return router
+}
+
+// get sends a GET request to the router, with the headers given as name and value pairs.
+func get(router *gin.Engine, path string, headers ...string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// panicEntry returns the logged entry of the recovered panic.
+func panicEntry(t *testing.T, hook *test.Hook) *logrus.Entry {
+	for _, entry := range hook.AllEntries() {
+		if strings.HasPrefix(entry.Message, "Recovered from panic") {
+			return entry
+		}
+	}
+	t.Fatal("the panic was not logged")
+	return nil
+}
+
+func TestRecovery_HandlerPanic(t *testing.T) {
+	logger.Init()
+	hook := test.NewLocal(logger.ErrorLogger)
+	router := setupRouter()
+
+	w := get(router, "/api/v1/panic")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	requestID := w.Header().Get("X-Request-Id")
+	assert.NotEmpty(t, requestID)
+
+	// The standard envelope, without any detail of the panic
+	var body httputil.HttpResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "Internal Server Error", body.Message)
+	assert.Equal(t, "An unexpected error occurred while processing the request", body.Error)
+	assert.Equal(t, "/api/v1/panic", body.Path)
+	assert.Equal(t, http.StatusInternalServerError, body.Status)
+	assert.Nil(t, body.Data)
+	assert.False(t, body.Timestamp.IsZero())
+	for _, leak := range []string{"goroutine", "nil pointer", "recovery_test.go", "runtime"} {
+		assert.NotContains(t, w.Body.String(), leak)
+	}
+
+	// The stack is logged along with the request ID
+	entry := panicEntry(t, hook)
+	assert.Contains(t, entry.Message, "nil pointer dereference")
+	assert.Equal(t, requestID, entry.Data["request_id"])
+	assert.Equal(t, "/api/v1/panic", entry.Data["path"])
+	assert.Contains(t, entry.Data["stack"], "recovery_test.go")
+
+	// The router keeps serving the other requests
+	assert.Equal(t, http.StatusOK, get(router, "/api/v1/ok").Code)
+}
+
+func TestRecovery_RequestID(t *testing.T) {
+	logger.Init()
+	hook := test.NewLocal(logger.ErrorLogger)
+	router := setupRouter()
+
+	// The ID of the client or the proxy is kept, and the compressed response is complete
+	w := get(router, "/api/v1/panic", "X-Request-Id", "req-123", "Accept-Encoding", "gzip")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "req-123", w.Header().Get("X-Request-Id"))
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Body.String(), "An unexpected error occurred")
+	assert.Equal(t, "req-123", panicEntry(t, hook).Data["request_id"])
+
+	// The requests which do not panic are left unchanged
+	w = get(router, "/api/v1/ok")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-Request-Id"))
+}
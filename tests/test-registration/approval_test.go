@@ -0,0 +1,214 @@
+package test_registration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+)
+
+// adminID is the ID of the admin deciding the registrations, it is not a user of the database.
+const adminID int64 = 100
+
+// adminContext returns a context authenticated as an admin of the default tenant.
+func adminContext() context.Context {
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: adminID, Username: "admin", Roles: []string{"ROLE_ADMIN"}, TenantID: metacontext.DefaultTenantID,
+	})
+	return metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID)
+}
+
+// registerAnonymously registers an account without any user context, as the anonymous route does.
+func registerAnonymously(t *testing.T, username string) entity.User {
+	user, err := service.NewUserService(repository.NewUserRepository()).RegisterUser(context.Background(), entity.UserRegisterRequest{
+		Username: username, Password: "P@ssw0rd123", Email: username + "@mygmail.com", Firstname: "New",
+	})
+	require.NoError(t, err)
+	return user
+}
+
+// auditActions returns the actions recorded in the audit log of the user, the oldest first.
+func auditActions(t *testing.T, db *gorm.DB, userID int64) []string {
+	var actions []string
+	require.NoError(t, db.Model(&entity.AuditLog{}).Where("entity_type = ? AND entity_id = ?", "user", userID).
+		Order("id ASC").Pluck("action", &actions).Error)
+	return actions
+}
+
+func TestRegistrations_ApproveAndReject(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewRegistrationService(repository.NewUserRegistrationRepository())
+	erin := registerAnonymously(t, "erin")
+	frank := registerAnonymously(t, "frank")
+
+	// The registered accounts wait for an approval, the oldest first
+	pending, total, err := s.GetPendingRegistrations(adminContext(), 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "erin", pending[0].User.Username)
+	assert.Equal(t, "frank", pending[1].User.Username)
+	assert.False(t, pending[0].EmailVerified)
+
+	// The approved account is enabled and no longer pending
+	approved, err := s.ApproveRegistration(adminContext(), erin.ID)
+	require.NoError(t, err)
+	assert.True(t, *approved.IsEnabled)
+	registration := findRegistration(t, db, erin.ID)
+	assert.Equal(t, entity.RegistrationStatusApproved, registration.Status)
+	require.NotNil(t, registration.DecidedBy)
+	assert.Equal(t, adminID, *registration.DecidedBy)
+	assert.NotNil(t, registration.DecidedAt)
+
+	_, err = s.ApproveRegistration(adminContext(), erin.ID)
+	assert.ErrorIs(t, err, service.ErrRegistrationNotPending)
+	assert.ErrorIs(t, s.RejectRegistration(adminContext(), erin.ID), service.ErrRegistrationNotPending)
+
+	// The rejected account is anonymized and stays disabled, it is not deleted
+	require.NoError(t, s.RejectRegistration(adminContext(), frank.ID))
+	var rejected entity.User
+	require.NoError(t, db.First(&rejected, frank.ID).Error)
+	assert.Equal(t, "deleted-2", rejected.Username)
+	assert.Equal(t, "deleted-2@deleted.invalid", rejected.Email)
+	assert.False(t, *rejected.IsEnabled)
+	assert.False(t, rejected.DeletedAt.Valid)
+	assert.Equal(t, entity.RegistrationStatusRejected, findRegistration(t, db, frank.ID).Status)
+
+	pending, total, err = s.GetPendingRegistrations(adminContext(), 1, 10)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Empty(t, pending)
+
+	// The username and the email of the rejected account can be registered again
+	registerAnonymously(t, "frank")
+
+	assert.Equal(t, []string{"approve_registration"}, auditActions(t, db, erin.ID))
+	assert.Equal(t, []string{"reject_registration"}, auditActions(t, db, frank.ID))
+}
+
+func TestRegistrations_NotRegistered(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewRegistrationService(repository.NewUserRegistrationRepository())
+
+	// An account created by an admin has no registration to decide
+	created, err := service.NewUserService(repository.NewUserRepository()).CreateUser(adminContext(), entity.UserCreateRequest{
+		Username: "created", Password: "P@ssw0rd123", Email: "created@mygmail.com", Firstname: "Created", UserType: "USER_ACCOUNT",
+	})
+	require.NoError(t, err)
+	_, err = s.ApproveRegistration(adminContext(), created.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, s.RejectRegistration(adminContext(), 99), gorm.ErrRecordNotFound)
+
+	// The registration of a deleted account is no longer pending
+	user := registerAnonymously(t, "erin")
+	require.NoError(t, db.Exec(`UPDATE users SET is_deleted = true, deleted_at = datetime('now') WHERE id = ?`, user.ID).Error)
+	_, total, err := s.GetPendingRegistrations(adminContext(), 1, 10)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func TestRegistrations_Handler(t *testing.T) {
+	setupDatabase(t)
+	erin := registerAnonymously(t, "erin")
+	registerAnonymously(t, "frank")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(adminContext())
+		c.Next()
+	})
+	h := handler.NewRegistrationHandler(service.NewRegistrationService(repository.NewUserRegistrationRepository()))
+	router.GET("/admin/users/pending", h.GetPendingRegistrations)
+	router.POST("/admin/users/:id/approve", pathparam.PathInt64("id"), h.ApproveRegistration)
+	router.POST("/admin/users/:id/reject", pathparam.PathInt64("id"), h.RejectRegistration)
+
+	request := func(method string, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("GET", "/admin/users/pending?limit=1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Data []entity.PendingRegistrationResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, erin.ID, body.Data[0].User.ID)
+
+	assert.Equal(t, http.StatusBadRequest, request("GET", "/admin/users/pending?page=0").Code)
+	assert.Equal(t, http.StatusOK, request("POST", "/admin/users/1/approve").Code)
+	assert.Equal(t, http.StatusConflict, request("POST", "/admin/users/1/reject").Code)
+	assert.Equal(t, http.StatusNotFound, request("POST", "/admin/users/99/approve").Code)
+	assert.Equal(t, http.StatusOK, request("POST", "/admin/users/2/reject").Code)
+}
+
+func TestRegistrations_ForceEmailVerification(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewRegistrationService(repository.NewUserRegistrationRepository())
+	erin := registerAnonymously(t, "erin")
+
+	// The email of the registered account is verified by the admin, along with the reason in the audit log
+	registration, err := s.ForceEmailVerification(adminContext(), erin.ID)
+	require.NoError(t, err)
+	assert.True(t, registration.EmailVerified)
+	assert.True(t, findRegistration(t, db, erin.ID).EmailVerified)
+	assert.Equal(t, entity.RegistrationStatusPendingApproval, registration.Status)
+	assert.Equal(t, []string{"force_email_verification"}, auditActions(t, db, erin.ID))
+
+	var details string
+	require.NoError(t, db.Model(&entity.AuditLog{}).Where("action = ?", "force_email_verification").Pluck("details", &details).Error)
+	assert.Contains(t, details, "erin@mygmail.com marked as verified by an admin")
+
+	// A verified email is not verified again
+	_, err = s.ForceEmailVerification(adminContext(), erin.ID)
+	assert.ErrorIs(t, err, service.ErrEmailAlreadyVerified)
+	assert.Len(t, auditActions(t, db, erin.ID), 1)
+
+	// An account created by an admin has no registration to verify
+	created, err := service.NewUserService(repository.NewUserRepository()).CreateUser(adminContext(), entity.UserCreateRequest{
+		Username: "created", Password: "P@ssw0rd123", Email: "created@mygmail.com", Firstname: "Created", UserType: "USER_ACCOUNT",
+	})
+	require.NoError(t, err)
+	_, err = s.ForceEmailVerification(adminContext(), created.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// The handler answers the email verified already with a conflict
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(adminContext())
+		c.Next()
+	})
+	h := handler.NewRegistrationHandler(s)
+	router.POST("/admin/users/:id/verification/force", pathparam.PathInt64("id"), h.ForceEmailVerification)
+
+	request := func(userID int64) int {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/admin/users/%d/verification/force", userID), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	frank := registerAnonymously(t, "frank")
+	assert.Equal(t, http.StatusOK, request(frank.ID))
+	assert.Equal(t, http.StatusConflict, request(frank.ID))
+	assert.Equal(t, http.StatusNotFound, request(99))
+}
@@ -0,0 +1,234 @@
+package test_registration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/routes"
+)
+
+const registrationBody = `{"username": "newuser", "password": "P@ssw0rd123", "email": "newuser@mygmail.com", "firstName": "New"}`
+
+// register sends an anonymous registration request to a router set up with the given self-registration toggle.
+func register(t *testing.T, enabled string, body string) *httptest.ResponseRecorder {
+	t.Setenv("SELF_REGISTRATION_ENABLED", enabled)
+	gin.SetMode(gin.TestMode)
+	router := routes.SetupRouter()
+
+	req, _ := http.NewRequest("POST", "/auth/register", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// findUser returns the user with the given username and its roles.
+func findUser(t *testing.T, db *gorm.DB, username string) (entity.User, bool) {
+	var users []entity.User
+	require.NoError(t, db.Preload("Roles").Where("username = ?", username).Find(&users).Error)
+	if len(users) == 0 {
+		return entity.User{}, false
+	}
+	return users[0], true
+}
+
+// findRegistration returns the registration of the user with the given ID.
+func findRegistration(t *testing.T, db *gorm.DB, userID int64) entity.UserRegistration {
+	var registration entity.UserRegistration
+	require.NoError(t, db.First(&registration, "user_id = ?", userID).Error)
+	return registration
+}
+
+// captchaVerifier accepts the token "solved" only, and records the address of the last client it verified.
+type captchaVerifier struct {
+	remoteIP string
+}
+
+func (v *captchaVerifier) Verify(ctx context.Context, token string, remoteIP string) error {
+	v.remoteIP = remoteIP
+	if token != "solved" {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+func TestRegister_SelfRegistrationEnabled(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("SELF_REGISTRATION_ROLE", "")
+
+	w := register(t, "TRUE", registrationBody)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// The account is created disabled, with the restricted role only, and is its own creator
+	user, ok := findUser(t, db, "newuser")
+	require.True(t, ok)
+	assert.False(t, *user.IsEnabled)
+	assert.Equal(t, "USER_ACCOUNT", user.UserType)
+	require.NotNil(t, user.CreatedBy)
+	assert.Equal(t, user.ID, *user.CreatedBy)
+	require.NotNil(t, user.UpdatedBy)
+	assert.Equal(t, user.ID, *user.UpdatedBy)
+	require.Len(t, user.Roles, 1)
+	assert.Equal(t, "ROLE_USER", user.Roles[0].Name)
+
+	// The account waits for an approval, its email is not verified
+	registration := findRegistration(t, db, user.ID)
+	assert.Equal(t, entity.RegistrationStatusPendingApproval, registration.Status)
+	assert.False(t, registration.EmailVerified)
+
+	// The username is still unique
+	w = register(t, "TRUE", registrationBody)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestRegister_ApprovalNotRequired(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("REGISTRATION_APPROVAL_REQUIRED", "FALSE")
+
+	w := register(t, "TRUE", registrationBody)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// The account is enabled at once, its email is still not verified
+	user, ok := findUser(t, db, "newuser")
+	require.True(t, ok)
+	assert.True(t, *user.IsEnabled)
+	registration := findRegistration(t, db, user.ID)
+	assert.Equal(t, entity.RegistrationStatusApproved, registration.Status)
+	assert.False(t, registration.EmailVerified)
+	assert.Nil(t, registration.DecidedBy)
+}
+
+func TestRegister_Captcha(t *testing.T) {
+	db := setupDatabase(t)
+	verifier := &captchaVerifier{}
+	service.SetCaptchaVerifier(verifier)
+	t.Cleanup(func() { service.SetCaptchaVerifier(nil) })
+
+	// A missing or wrong token is rejected before creating anything
+	w := register(t, "TRUE", registrationBody)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	w = register(t, "TRUE", `{"username": "newuser", "password": "P@ssw0rd123", "email": "newuser@mygmail.com", "firstName": "New", "captchaToken": "wrong"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	_, ok := findUser(t, db, "newuser")
+	assert.False(t, ok)
+
+	// The solved captcha is verified along with the address of the client
+	w = register(t, "TRUE", `{"username": "newuser", "password": "P@ssw0rd123", "email": "newuser@mygmail.com", "firstName": "New", "captchaToken": "solved"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "192.0.2.1", verifier.remoteIP)
+}
+
+func TestRegister_RateLimited(t *testing.T) {
+	setupDatabase(t)
+	t.Setenv("SELF_REGISTRATION_ENABLED", "TRUE")
+	t.Setenv("REGISTRATION_RATE_LIMIT", "2")
+	gin.SetMode(gin.TestMode)
+	router := routes.SetupRouter()
+
+	request := func(username string, remoteAddr string) *httptest.ResponseRecorder {
+		body := `{"username": "` + username + `", "password": "P@ssw0rd123", "email": "` + username + `@mygmail.com", "firstName": "New"}`
+		req, _ := http.NewRequest("POST", "/auth/register", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The failed registrations count as well
+	assert.Equal(t, http.StatusCreated, request("first", "192.0.2.1:1234").Code)
+	assert.Equal(t, http.StatusConflict, request("first", "192.0.2.1:1234").Code)
+	w := request("third", "192.0.2.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// The other addresses have their own limit
+	assert.Equal(t, http.StatusCreated, request("other", "192.0.2.2:1234").Code)
+}
+
+func TestRegister_RolesCannotBeRequested(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("SELF_REGISTRATION_ROLE", "ROLE_MODERATOR")
+
+	w := register(t, "TRUE", `{"username": "newuser", "password": "P@ssw0rd123", "email": "newuser@mygmail.com", "firstName": "New", "roles": ["ROLE_ADMIN"], "userType": "SERVICE_ACCOUNT"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	user, ok := findUser(t, db, "newuser")
+	require.True(t, ok)
+	assert.Equal(t, "USER_ACCOUNT", user.UserType)
+	require.Len(t, user.Roles, 1)
+	assert.Equal(t, "ROLE_MODERATOR", user.Roles[0].Name)
+}
+
+func TestRegister_SelfRegistrationDisabled(t *testing.T) {
+	db := setupDatabase(t)
+
+	for _, enabled := range []string{"", "FALSE"} {
+		t.Run("SELF_REGISTRATION_ENABLED="+enabled, func(t *testing.T) {
+			// The anonymous registration is rejected before reaching the handler
+			w := register(t, enabled, registrationBody)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+			_, ok := findUser(t, db, "newuser")
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestRegister_SelfRegistrationDisabled_AdminOnly(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("SELF_REGISTRATION_ENABLED", "FALSE")
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	service.JWTSecret = "test-secret"
+	gin.SetMode(gin.TestMode)
+	router := routes.SetupRouter()
+
+	tests := []struct {
+		name     string
+		role     string
+		username string
+		status   int
+	}{
+		{"user rejected", "ROLE_USER", "byuser", http.StatusForbidden},
+		{"admin allowed", "ROLE_ADMIN", "byadmin", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := service.GenerateJWTTokenWithHS256(entity.User{ID: 1, Username: "caller", Roles: []entity.Role{{Name: tt.role}}}, time.Now())
+			require.NoError(t, err)
+
+			body := `{"username": "` + tt.username + `", "password": "P@ssw0rd123", "email": "` + tt.username + `@mygmail.com", "firstName": "New"}`
+			req, _ := http.NewRequest("POST", "/auth/register", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+
+			// The account registered by an admin is still disabled and records its creator
+			user, ok := findUser(t, db, tt.username)
+			assert.Equal(t, tt.status == http.StatusCreated, ok)
+			if ok {
+				assert.False(t, *user.IsEnabled)
+				require.NotNil(t, user.CreatedBy)
+				assert.Equal(t, int64(1), *user.CreatedBy)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package test_repository
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// newSQLiteUserRepository opens an SQLite database with the tables of the migration, enforcing their foreign keys,
+// the tenants 1 and 2 and the roles ROLE_USER and ROLE_ADMIN, with the GORM configuration of the application.
+// It returns the GORM user repository along with it, see conformance.UserRepositoryFactory.
+func newSQLiteUserRepository(t *testing.T) (repository.UserRepository, *gorm.DB) {
+	database.DBSchema = ""
+	database.DBLog = "SILENT"
+
+	db := testdb.New(t, testdb.Options{
+		Config:      database.NewGormConfig(),
+		Dialector:   database.WithConstraintErrors,
+		ForeignKeys: true,
+	},
+		`INSERT INTO tenants (id, name) VALUES (1, 'Default'), (2, 'Other')`,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_ADMIN', false)`,
+	)
+
+	return repository.NewUserRepository(), db
+}
@@ -0,0 +1,11 @@
+package test_repository
+
+import (
+	"testing"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository/conformance"
+)
+
+func TestUserRepository_SQLite(t *testing.T) {
+	conformance.RunUserRepositoryTests(t, newSQLiteUserRepository)
+}
@@ -0,0 +1,31 @@
+package test_role_expiry
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with the tables of the migration and makes
+// the services use it instead of PostgreSQL. It holds the admin (ID 1) with the ROLE_ADMIN role,
+// bob (ID 2) with the ROLE_USER role, and the ROLE_FINANCE role held by nobody.
+func setupDatabase(t *testing.T) *gorm.DB {
+	db := testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_ADMIN', false), ('ROLE_FINANCE', false)`,
+		`INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired,
+			is_account_non_locked, is_credentials_non_expired, user_type) VALUES
+			('admin', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'admin@mygmail.com', 'Admin', true, true, true, true, 'USER_ACCOUNT'),
+			('bob', '$2a$10$8K1p/a0dL3LXMIgoEDFrwOfMQbLgtnOoKsWc.6U6H0llP3puzeY6.', 'bob@mygmail.com', 'Bob', true, true, true, true, 'USER_ACCOUNT')`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES (1, 2), (2, 1)`,
+	)
+
+	// Record the actor of the writes like the PostgreSQL connection does
+	if err := database.RegisterAuditCallbacks(db); err != nil {
+		t.Fatalf("failed to register the audit callbacks: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,222 @@
+package test_role_expiry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+const (
+	adminID int64 = 1
+	bobID   int64 = 2
+)
+
+// adminContext returns a context authenticated as the admin of the default tenant.
+func adminContext() context.Context {
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: adminID, Username: "admin", Roles: []string{"ROLE_ADMIN"}, TenantID: metacontext.DefaultTenantID,
+	})
+	return metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID)
+}
+
+// roleOf returns the role of the user with the given name, or nil if the user does not hold it.
+func roleOf(user entity.User, name string) *entity.Role {
+	i := slices.IndexFunc(user.Roles, func(role entity.Role) bool { return role.Name == name })
+	if i < 0 {
+		return nil
+	}
+	return &user.Roles[i]
+}
+
+// expireRole sets the expiry of the assignment of the role to the user directly in the database.
+func expireRole(t *testing.T, db *gorm.DB, userID int64, roleName string, expiresAt time.Time) {
+	require.NoError(t, db.Exec(`UPDATE user_roles SET expires_at = ? WHERE user_id = ? AND role_id = (SELECT id FROM roles WHERE name = ?)`,
+		expiresAt.UTC(), userID, roleName).Error)
+}
+
+func TestPatchUserRoles_Expiry(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+	expiresAt := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	updated, err := s.PatchUserRoles(adminContext(), bobID, entity.UserRolesPatchRequest{Add: []string{"ROLE_FINANCE"}, ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+	require.NotNil(t, roleOf(updated, "ROLE_FINANCE"))
+	assert.True(t, expiresAt.Equal(*roleOf(updated, "ROLE_FINANCE").ExpiresAt))
+
+	// The expiry is returned along with the role, the permanent roles have none
+	user, err := s.GetUserByID(bobID)
+	require.NoError(t, err)
+	require.NotNil(t, roleOf(user, "ROLE_FINANCE"))
+	assert.True(t, expiresAt.Equal(*roleOf(user, "ROLE_FINANCE").ExpiresAt))
+	assert.Nil(t, roleOf(user, "ROLE_USER").ExpiresAt)
+
+	body, err := json.Marshal(user.ToResponse())
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"roleName":"ROLE_FINANCE","description":null,"isDefault":false,"expiresAt":"`+expiresAt.UTC().Format(time.RFC3339)+`"`)
+
+	// Adding the role again extends its assignment, and without expiry makes it permanent
+	extended := expiresAt.Add(24 * time.Hour)
+	_, err = s.PatchUserRoles(adminContext(), bobID, entity.UserRolesPatchRequest{Add: []string{"ROLE_FINANCE"}, ExpiresAt: &extended})
+	require.NoError(t, err)
+	user, err = s.GetUserByID(bobID)
+	require.NoError(t, err)
+	assert.True(t, extended.Equal(*roleOf(user, "ROLE_FINANCE").ExpiresAt))
+
+	_, err = s.PatchUserRoles(adminContext(), bobID, entity.UserRolesPatchRequest{Add: []string{"ROLE_FINANCE"}})
+	require.NoError(t, err)
+	user, err = s.GetUserByID(bobID)
+	require.NoError(t, err)
+	assert.Nil(t, roleOf(user, "ROLE_FINANCE").ExpiresAt)
+}
+
+func TestPatchUserRoles_ExpiryRejected(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name string
+		req  entity.UserRolesPatchRequest
+	}{
+		{"past expiry", entity.UserRolesPatchRequest{Add: []string{"ROLE_FINANCE"}, ExpiresAt: &past}},
+		{"expiry without added roles", entity.UserRolesPatchRequest{Remove: []string{"ROLE_USER"}, ExpiresAt: &future}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.PatchUserRoles(adminContext(), bobID, tt.req)
+			var fieldErrs validation.FieldErrors
+			require.True(t, errors.As(err, &fieldErrs), "unexpected error: %v", err)
+			require.Len(t, fieldErrs, 1)
+			assert.Equal(t, "expiresAt", fieldErrs[0].Path)
+		})
+	}
+
+	// The last admin cannot be given an expiring ROLE_ADMIN
+	_, err := s.PatchUserRoles(adminContext(), adminID, entity.UserRolesPatchRequest{Add: []string{"ROLE_ADMIN"}, ExpiresAt: &future})
+	assert.ErrorIs(t, err, service.ErrLastAdmin)
+
+	user, err := s.GetUserByID(bobID)
+	require.NoError(t, err)
+	assert.Nil(t, roleOf(user, "ROLE_FINANCE"))
+}
+
+func TestUpdateUserRoles_KeepsExpiry(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	_, err := s.PatchUserRoles(adminContext(), bobID, entity.UserRolesPatchRequest{Add: []string{"ROLE_FINANCE"}, ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+
+	// Replacing the roles keeps the expiry of the roles kept
+	_, err = s.UpdateUserRoles(adminContext(), bobID, entity.UserRolesRequest{Roles: []string{"ROLE_FINANCE", "ROLE_ADMIN"}})
+	require.NoError(t, err)
+	user, err := s.GetUserByID(bobID)
+	require.NoError(t, err)
+	require.NotNil(t, roleOf(user, "ROLE_FINANCE"))
+	assert.True(t, expiresAt.Equal(*roleOf(user, "ROLE_FINANCE").ExpiresAt))
+	assert.Nil(t, roleOf(user, "ROLE_ADMIN").ExpiresAt)
+	assert.Nil(t, roleOf(user, "ROLE_USER"))
+}
+
+func TestExpiredRoles_Ignored(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+	expireRole(t, db, bobID, "ROLE_USER", time.Now().Add(-time.Minute))
+
+	// The expired assignment no longer grants its role, its row is kept until purged
+	user, err := s.GetUserByID(bobID)
+	require.NoError(t, err)
+	assert.Empty(t, user.Roles)
+
+	var n int64
+	require.NoError(t, db.Table("user_roles").Where("user_id = ?", bobID).Count(&n).Error)
+	assert.Equal(t, int64(1), n)
+
+	// The role can be granted again
+	_, err = s.PatchUserRoles(adminContext(), bobID, entity.UserRolesPatchRequest{Add: []string{"ROLE_USER"}})
+	require.NoError(t, err)
+	user, err = s.GetUserByID(bobID)
+	require.NoError(t, err)
+	require.NotNil(t, roleOf(user, "ROLE_USER"))
+	assert.Nil(t, roleOf(user, "ROLE_USER").ExpiresAt)
+}
+
+func TestJwtValidation_RoleExpiresAfterIssuance(t *testing.T) {
+	setupDatabase(t)
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("JWT_SECRET", "secret")
+	service.JWTSecret = "secret"
+
+	s := service.NewUserService(repository.NewUserRepository())
+	expiresAt := time.Now().Add(1500 * time.Millisecond)
+	_, err := s.PatchUserRoles(adminContext(), bobID, entity.UserRolesPatchRequest{Add: []string{"ROLE_FINANCE"}, ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+
+	// The token is issued while the role is still assigned
+	user, err := s.GetUserByID(bobID)
+	require.NoError(t, err)
+	token, err := service.GenerateJWTTokenWithHS256(user, time.Now())
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(authorization.JwtValidation())
+	router.GET("/finance", authorization.RoleBasedAccessControl("ROLE_FINANCE"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/me", authorization.RoleBasedAccessControl("ROLE_USER"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	request := func(path string) int {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("/finance"))
+
+	// Once the role expired, the same token no longer grants it, the other roles are kept
+	time.Sleep(time.Until(expiresAt))
+	assert.Equal(t, http.StatusForbidden, request("/finance"))
+	assert.Equal(t, http.StatusOK, request("/me"))
+}
+
+func TestPurgeExpiredRoleAssignments(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("ROLE_ASSIGNMENT_RETENTION_DAYS", "30")
+	require.NoError(t, db.Exec(`INSERT INTO user_roles (user_id, role_id) VALUES (2, 3)`).Error)
+	now := time.Now().UTC()
+	expireRole(t, db, bobID, "ROLE_FINANCE", now.Add(-31*24*time.Hour))
+	expireRole(t, db, bobID, "ROLE_USER", now.Add(-24*time.Hour))
+
+	// Only the assignments expired for longer than the retention period are removed
+	janitor := metacontext.WithSystemActor(context.Background(), "account-janitor")
+	purged, err := service.NewAccountService(repository.NewScheduledDeletionRepository(), clock.New()).PurgeExpiredRoleAssignments(janitor, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	var roleIDs []int64
+	require.NoError(t, db.Table("user_roles").Where("user_id = ?", bobID).Pluck("role_id", &roleIDs).Error)
+	assert.Equal(t, []int64{1}, roleIDs)
+}
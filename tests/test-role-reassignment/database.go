@@ -0,0 +1,44 @@
+package test_role_reassignment
+
+import (
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// financeClerks is the number of the generated holders of ROLE_FINANCE, more than a batch of the reassignment.
+const financeClerks = 120
+
+// setupDatabase opens an SQLite database with the tables of the migration and makes
+// the services use it instead of PostgreSQL. It holds the admin (ID 1) with the ROLE_ADMIN role,
+// alice (ID 2) with the ROLE_FINANCE and ROLE_ACCOUNTING roles, bob (ID 3) with the ROLE_USER role,
+// the deleted dave (ID 4) with the ROLE_FINANCE role, and the financeClerks clerks (IDs 5 and up) with the ROLE_FINANCE role.
+func setupDatabase(t *testing.T) *gorm.DB {
+	db := testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_ADMIN', false), ('ROLE_FINANCE', false), ('ROLE_ACCOUNTING', false)`,
+		`INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired,
+			is_account_non_locked, is_credentials_non_expired, user_type) VALUES
+			('admin', '!', 'admin@mygmail.com', 'Admin', true, true, true, true, 'USER_ACCOUNT'),
+			('alice', '!', 'alice@mygmail.com', 'Alice', true, true, true, true, 'USER_ACCOUNT'),
+			('bob', '!', 'bob@mygmail.com', 'Bob', true, true, true, true, 'USER_ACCOUNT'),
+			('dave', '!', 'dave@mygmail.com', 'Dave', true, true, true, true, 'USER_ACCOUNT')`,
+		fmt.Sprintf(`WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < %d)
+			INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired, is_account_non_locked,
+				is_credentials_non_expired, user_type)
+			SELECT 'clerk' || n, '!', 'clerk' || n || '@mygmail.com', 'Clerk', true, true, true, true, 'USER_ACCOUNT' FROM seq`, financeClerks),
+		`UPDATE users SET is_deleted = true, deleted_by = 1, deleted_at = CURRENT_TIMESTAMP WHERE id = 4`,
+		`INSERT INTO user_roles (user_id, role_id) SELECT id, 3 FROM users WHERE id NOT IN (1, 3)`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES (1, 2), (2, 4), (3, 1)`,
+	)
+
+	// Record the actor of the writes like the PostgreSQL connection does
+	if err := database.RegisterAuditCallbacks(db); err != nil {
+		t.Fatalf("failed to register the audit callbacks: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,231 @@
+package test_role_reassignment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// adminContext returns a context authenticated as the admin of the default tenant.
+func adminContext() context.Context {
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: 1, Username: "admin", Roles: []string{"ROLE_ADMIN"}, TenantID: metacontext.DefaultTenantID,
+	})
+	return metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID)
+}
+
+// holders returns the number of users, deleted included, holding the role.
+func holders(t *testing.T, db *gorm.DB, roleName string) int64 {
+	var n int64
+	require.NoError(t, db.Table("user_roles").Joins("JOIN roles ON roles.id = user_roles.role_id").Where("roles.name = ?", roleName).Count(&n).Error)
+	return n
+}
+
+// audits returns the number of audit entries of the reassignments.
+func audits(t *testing.T, db *gorm.DB) int64 {
+	var n int64
+	require.NoError(t, db.Table("audit_logs").Where("action = ?", "reassign_role").Count(&n).Error)
+	return n
+}
+
+// errorFields returns the fields of the validation errors of a response, in order.
+func errorFields(errs []map[string]string) []string {
+	fields := make([]string, len(errs))
+	for i, e := range errs {
+		fields[i] = e["field"]
+	}
+	return fields
+}
+
+func TestReassignRole_Batches(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	result, err := s.ReassignRole(adminContext(), entity.RoleReassignmentRequest{From: "role_finance", To: "ROLE_ACCOUNTING"})
+	require.NoError(t, err)
+	assert.Equal(t, "ROLE_FINANCE", result.From)
+	assert.Equal(t, "ROLE_ACCOUNTING", result.To)
+	assert.False(t, result.Resumed)
+	assert.NotZero(t, result.ID)
+
+	// Every clerk is reassigned across the batches, alice already holds the new role and is skipped
+	assert.Equal(t, financeClerks, result.ReassignedUsers)
+	assert.Equal(t, 1, result.SkippedUsers)
+	assert.Equal(t, int64(financeClerks), audits(t, db))
+
+	// Alice keeps both roles and the deleted dave is left unchanged
+	assert.Equal(t, int64(2), holders(t, db, "ROLE_FINANCE"))
+	assert.Equal(t, int64(financeClerks+1), holders(t, db, "ROLE_ACCOUNTING"))
+
+	var clerk entity.User
+	require.NoError(t, db.Preload("Roles").First(&clerk, "username = ?", "clerk120").Error)
+	require.Len(t, clerk.Roles, 1)
+	assert.Equal(t, "ROLE_ACCOUNTING", clerk.Roles[0].Name)
+	assert.Equal(t, int64(1), *clerk.UpdatedBy)
+
+	var audit entity.AuditLog
+	require.NoError(t, db.First(&audit, "action = ? AND entity_id = ?", "reassign_role", "124").Error)
+	assert.Equal(t, int64(1), *audit.ActorID)
+	assert.Contains(t, *audit.Details, "ROLE_FINANCE reassigned to ROLE_ACCOUNTING")
+
+	var reassignment entity.RoleReassignment
+	require.NoError(t, db.First(&reassignment, result.ID).Error)
+	assert.Equal(t, entity.RoleReassignmentCompleted, reassignment.Status)
+	assert.Equal(t, int64(4+financeClerks), reassignment.LastUserID)
+	assert.NotNil(t, reassignment.CompletedAt)
+
+	// Nobody holds the old role anymore but alice, a new reassignment skips her
+	result, err = s.ReassignRole(adminContext(), entity.RoleReassignmentRequest{From: "ROLE_FINANCE", To: "ROLE_ACCOUNTING"})
+	require.NoError(t, err)
+	assert.Zero(t, result.ReassignedUsers)
+	assert.Equal(t, 1, result.SkippedUsers)
+}
+
+func TestReassignRole_DryRun(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	result, err := s.ReassignRole(adminContext(), entity.RoleReassignmentRequest{From: "ROLE_FINANCE", To: "ROLE_ACCOUNTING", DryRun: true})
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Zero(t, result.ID)
+	assert.Equal(t, financeClerks, result.ReassignedUsers)
+	assert.Equal(t, 1, result.SkippedUsers)
+
+	// Nothing is written
+	assert.Equal(t, int64(financeClerks+2), holders(t, db, "ROLE_FINANCE"))
+	assert.Equal(t, int64(1), holders(t, db, "ROLE_ACCOUNTING"))
+	assert.Zero(t, audits(t, db))
+	var n int64
+	require.NoError(t, db.Model(&entity.RoleReassignment{}).Count(&n).Error)
+	assert.Zero(t, n)
+}
+
+func TestReassignRole_Resume(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	// An interrupted reassignment moved the clerks up to ID 60 before failing
+	for _, stmt := range []string{
+		`UPDATE user_roles SET role_id = 4 WHERE role_id = 3 AND user_id BETWEEN 5 AND 60`,
+		`INSERT INTO role_reassignments (from_role_id, to_role_id, status, last_user_id, reassigned, skipped, created_by, created_at, updated_at)
+			VALUES (3, 4, 'RUNNING', 60, 56, 1, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+	} {
+		require.NoError(t, db.Exec(stmt).Error)
+	}
+
+	result, err := s.ReassignRole(adminContext(), entity.RoleReassignmentRequest{From: "ROLE_FINANCE", To: "ROLE_ACCOUNTING"})
+	require.NoError(t, err)
+	assert.True(t, result.Resumed)
+	assert.Equal(t, int64(1), result.ID)
+
+	// The counts include the users processed before the interruption, only the others are audited
+	assert.Equal(t, financeClerks, result.ReassignedUsers)
+	assert.Equal(t, 1, result.SkippedUsers)
+	assert.Equal(t, int64(financeClerks-56), audits(t, db))
+	assert.Equal(t, int64(financeClerks+1), holders(t, db, "ROLE_ACCOUNTING"))
+
+	var reassignment entity.RoleReassignment
+	require.NoError(t, db.First(&reassignment, result.ID).Error)
+	assert.Equal(t, entity.RoleReassignmentCompleted, reassignment.Status)
+}
+
+func TestReassignRole_Rejected(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	_, err := s.ReassignRole(adminContext(), entity.RoleReassignmentRequest{From: "ROLE_FINANCE", To: "role_finance"})
+	assert.ErrorIs(t, err, service.ErrSameRole)
+
+	_, err = s.ReassignRole(adminContext(), entity.RoleReassignmentRequest{From: "ROLE_BILLING", To: "ROLE_AUDITOR"})
+	assert.ErrorIs(t, err, service.ErrUnknownRole)
+	assert.ErrorContains(t, err, "ROLE_BILLING, ROLE_AUDITOR")
+	assert.Equal(t, []string{"from", "to"}, errorFields(validation.FormatValidationErrors(err)))
+
+	// The last admin cannot lose ROLE_ADMIN, the batch is rolled back
+	_, err = s.ReassignRole(adminContext(), entity.RoleReassignmentRequest{From: "ROLE_ADMIN", To: "ROLE_USER"})
+	assert.ErrorIs(t, err, service.ErrLastAdmin)
+	assert.Equal(t, int64(1), holders(t, db, "ROLE_ADMIN"))
+
+	// Only an admin may take ROLE_ADMIN away
+	moderator := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: 3, Username: "bob", Roles: []string{"ROLE_MODERATOR"},
+	})
+	_, err = s.ReassignRole(moderator, entity.RoleReassignmentRequest{From: "ROLE_ADMIN", To: "ROLE_USER"})
+	assert.ErrorIs(t, err, service.ErrRoleAssignmentForbidden)
+
+	_, err = s.ReassignRole(context.Background(), entity.RoleReassignmentRequest{From: "ROLE_FINANCE", To: "ROLE_ACCOUNTING"})
+	assert.ErrorContains(t, err, "missing user context")
+	assert.Zero(t, audits(t, db))
+}
+
+func TestReassignRole_Handler(t *testing.T) {
+	setupDatabase(t)
+	h := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(adminContext())
+		c.Next()
+	})
+	router.POST("/api/v1/admin/roles/reassign", h.ReassignRole)
+
+	tests := []struct {
+		name    string
+		body    string
+		code    int
+		message string
+		fields  []string
+	}{
+		{"dry run", `{"from":"ROLE_FINANCE","to":"ROLE_ACCOUNTING","dryRun":true}`, http.StatusOK, "Role reassignment previewed successfully", nil},
+		{"reassign", `{"from":"ROLE_FINANCE","to":"ROLE_ACCOUNTING"}`, http.StatusOK, "Role reassigned successfully", nil},
+		{"unknown roles", `{"from":"ROLE_BILLING","to":"ROLE_USER"}`, http.StatusUnprocessableEntity, "", []string{"from"}},
+		{"invalid names", `{"from":"finance","to":""}`, http.StatusUnprocessableEntity, "", []string{"from", "to"}},
+		{"same role", `{"from":"ROLE_USER","to":"ROLE_USER"}`, http.StatusUnprocessableEntity, "", nil},
+		{"last admin", `{"from":"ROLE_ADMIN","to":"ROLE_USER"}`, http.StatusConflict, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/v1/admin/roles/reassign", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.message != "" {
+				var body struct {
+					Message string                        `json:"message"`
+					Data    entity.RoleReassignmentResult `json:"data"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, tt.message, body.Message)
+				assert.Equal(t, financeClerks, body.Data.ReassignedUsers)
+				assert.Equal(t, 1, body.Data.SkippedUsers)
+			}
+			if tt.fields != nil {
+				var body struct {
+					Error []map[string]string `json:"error"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, tt.fields, errorFields(body.Error))
+			}
+		})
+	}
+}
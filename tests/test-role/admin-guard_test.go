@@ -0,0 +1,196 @@
+package test_role
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+// setupAdminGuard creates exactly one admin, a moderator and a user through the CLI bootstrap,
+// and adds ROLE_SUPER_ADMIN, which is only held by the super admins.
+func setupAdminGuard(t *testing.T) (*gorm.DB, service.UserService, map[string]int64) {
+	db := setupDatabase(t)
+	require.NoError(t, db.Exec(`INSERT INTO roles (name, is_default) VALUES ('ROLE_SUPER_ADMIN', false)`).Error)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	ids := make(map[string]int64)
+	cli := metacontext.WithSystemActor(context.Background(), "cli")
+	for username, role := range map[string]string{"admin": "ROLE_ADMIN", "moderator": "ROLE_MODERATOR", "user": "ROLE_USER"} {
+		created, err := s.CreateUser(cli, entity.UserCreateRequest{
+			Username:  username,
+			Password:  "P@ssw0rd123",
+			Email:     username + "@mygmail.com",
+			Firstname: username,
+			UserType:  "USER_ACCOUNT",
+			Roles:     []string{role},
+		})
+		require.NoError(t, err)
+		ids[username] = created.ID
+	}
+
+	return db, s, ids
+}
+
+// asUser returns a context authenticated as the user with the given roles.
+func asUser(id int64, username string, roles ...string) context.Context {
+	return metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: id, Username: username, Roles: roles,
+	})
+}
+
+func TestRoleAssignment_OnlyAdminsGrantAdminRoles(t *testing.T) {
+	_, s, ids := setupAdminGuard(t)
+	admin := asUser(ids["admin"], "admin", "ROLE_ADMIN")
+	moderator := asUser(ids["moderator"], "moderator", "ROLE_MODERATOR")
+
+	newUser := func(username string, roles ...string) entity.UserCreateRequest {
+		return entity.UserCreateRequest{Username: username, Password: "P@ssw0rd123", Email: username + "@mygmail.com",
+			Firstname: username, UserType: "USER_ACCOUNT", Roles: roles}
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+		err  error
+	}{
+		{"moderator creates an admin", func() error {
+			_, err := s.CreateUser(moderator, newUser("other", "role_admin"))
+			return err
+		}, service.ErrRoleAssignmentForbidden},
+		{"moderator promotes themselves", func() error {
+			_, err := s.UpdateUserRoles(moderator, ids["moderator"], entity.UserRolesRequest{Roles: []string{"ROLE_MODERATOR", "ROLE_ADMIN"}})
+			return err
+		}, service.ErrRoleAssignmentForbidden},
+		{"moderator demotes the admin", func() error {
+			_, err := s.UpdateUserRoles(moderator, ids["admin"], entity.UserRolesRequest{Roles: []string{"ROLE_USER"}})
+			return err
+		}, service.ErrRoleAssignmentForbidden},
+		{"admin grants the super admin role", func() error {
+			_, err := s.UpdateUserRoles(admin, ids["user"], entity.UserRolesRequest{Roles: []string{"ROLE_ADMIN", "ROLE_SUPER_ADMIN"}})
+			return err
+		}, service.ErrRoleAssignmentForbidden},
+		{"moderator assigns a non-admin role", func() error {
+			_, err := s.UpdateUserRoles(moderator, ids["user"], entity.UserRolesRequest{Roles: []string{"ROLE_MODERATOR"}})
+			return err
+		}, nil},
+		{"admin promotes a user", func() error {
+			_, err := s.UpdateUserRoles(admin, ids["user"], entity.UserRolesRequest{Roles: []string{"ROLE_USER", "ROLE_ADMIN"}})
+			return err
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if tt.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	// The promoted user holds the new roles
+	user, err := s.GetUserByID(ids["user"])
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ROLE_USER", "ROLE_ADMIN"}, service.ExtractRoleNames(user.Roles))
+
+	// The anonymous self-registration cannot be configured to hand out an admin role
+	t.Setenv("SELF_REGISTRATION_ROLE", "ROLE_ADMIN")
+	_, err = s.RegisterUser(context.Background(), entity.UserRegisterRequest{
+		Username: "anonymous", Password: "P@ssw0rd123", Email: "anonymous@mygmail.com", Firstname: "Anonymous",
+	})
+	assert.ErrorIs(t, err, service.ErrRoleAssignmentForbidden)
+}
+
+func TestLastAdmin_CannotBeDemoted(t *testing.T) {
+	_, s, ids := setupAdminGuard(t)
+	admin := asUser(ids["admin"], "admin", "ROLE_ADMIN")
+	cli := metacontext.WithSystemActor(context.Background(), "cli")
+	disabled := false
+
+	// The only admin cannot lose the role, be disabled or be deleted, even by the system
+	_, err := s.UpdateUserRoles(admin, ids["admin"], entity.UserRolesRequest{Roles: []string{"ROLE_USER"}})
+	assert.ErrorIs(t, err, service.ErrLastAdmin)
+	_, err = s.UpdateUserStatus(cli, ids["admin"], entity.UserStatusRequest{IsEnabled: &disabled})
+	assert.ErrorIs(t, err, service.ErrLastAdmin)
+	results, err := s.BulkDeleteUsers(cli, []int64{ids["admin"], ids["user"]})
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserBulkDeleteSkipped, results[0].Status)
+	assert.Equal(t, entity.UserBulkDeleteDeleted, results[1].Status)
+
+	// Keeping ROLE_ADMIN while changing the other roles is allowed
+	_, err = s.UpdateUserRoles(admin, ids["admin"], entity.UserRolesRequest{Roles: []string{"ROLE_ADMIN", "ROLE_MODERATOR"}})
+	assert.NoError(t, err)
+
+	// A second admin that is disabled does not count
+	_, err = s.UpdateUserRoles(admin, ids["moderator"], entity.UserRolesRequest{Roles: []string{"ROLE_ADMIN"}})
+	require.NoError(t, err)
+	_, err = s.UpdateUserStatus(admin, ids["moderator"], entity.UserStatusRequest{IsEnabled: &disabled})
+	require.NoError(t, err)
+	_, err = s.UpdateUserRoles(admin, ids["admin"], entity.UserRolesRequest{Roles: []string{"ROLE_USER"}})
+	assert.ErrorIs(t, err, service.ErrLastAdmin)
+
+	// Once another admin is enabled, the first one can be demoted
+	enabled := true
+	_, err = s.UpdateUserStatus(admin, ids["moderator"], entity.UserStatusRequest{IsEnabled: &enabled})
+	require.NoError(t, err)
+	_, err = s.UpdateUserRoles(admin, ids["admin"], entity.UserRolesRequest{Roles: []string{"ROLE_USER"}})
+	assert.NoError(t, err)
+}
+
+func TestUpdateUserRoles_Handler(t *testing.T) {
+	_, s, ids := setupAdminGuard(t)
+	h := handler.NewUserHandler(s)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		roles := strings.Split(c.GetHeader("X-Test-Roles"), ",")
+		c.Request = c.Request.WithContext(metacontext.InjectUserInformationMeta(c.Request.Context(),
+			metacontext.UserInformationMeta{UserID: ids["admin"], Username: "admin", Roles: roles}))
+		c.Next()
+	})
+	router.PUT("/api/v1/users/:id/roles", h.UpdateUserRoles)
+
+	tests := []struct {
+		name   string
+		roles  string
+		path   string
+		body   string
+		status int
+	}{
+		{"last admin", "ROLE_ADMIN", fmt.Sprintf("/api/v1/users/%d/roles", ids["admin"]), `{"roles":["ROLE_USER"]}`, http.StatusConflict},
+		{"super admin role", "ROLE_ADMIN", fmt.Sprintf("/api/v1/users/%d/roles", ids["user"]), `{"roles":["ROLE_SUPER_ADMIN"]}`, http.StatusForbidden},
+		{"unknown role", "ROLE_ADMIN", fmt.Sprintf("/api/v1/users/%d/roles", ids["user"]), `{"roles":["ROLE_AUDITOR"]}`, http.StatusUnprocessableEntity},
+		{"no role", "ROLE_ADMIN", fmt.Sprintf("/api/v1/users/%d/roles", ids["user"]), `{"roles":[]}`, http.StatusUnprocessableEntity},
+		{"unknown user", "ROLE_ADMIN", "/api/v1/users/99/roles", `{"roles":["ROLE_USER"]}`, http.StatusNotFound},
+		{"super admin grants it", "ROLE_ADMIN,ROLE_SUPER_ADMIN", fmt.Sprintf("/api/v1/users/%d/roles", ids["user"]), `{"roles":["ROLE_ADMIN","ROLE_SUPER_ADMIN"]}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("PUT", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Test-Roles", tt.roles)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+}
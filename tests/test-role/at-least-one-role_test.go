@@ -0,0 +1,126 @@
+package test_role
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/go-playground/validator.v9"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// validUser returns a user passing the field validations, with the given roles.
+func validUser(roles ...entity.Role) entity.User {
+	return entity.User{
+		Username:  "newuser",
+		Password:  "P@ssw0rd123",
+		Email:     "newuser@mygmail.com",
+		Firstname: "New",
+		UserType:  "USER_ACCOUNT",
+		Roles:     roles,
+	}
+}
+
+// assertNoRoleError asserts the error is the validation error of a user without any role.
+func assertNoRoleError(t *testing.T, err error) {
+	var ve validator.ValidationErrors
+	require.ErrorAs(t, err, &ve)
+	require.Len(t, ve, 1)
+	assert.Equal(t, "roles", ve[0].Field())
+	assert.Equal(t, validation.AtLeastOneRoleTag, ve[0].Tag())
+}
+
+func TestUserValidate_AtLeastOneRole(t *testing.T) {
+	user := validUser()
+	assertNoRoleError(t, user.Validate())
+
+	user.Roles = []entity.Role{}
+	assertNoRoleError(t, user.Validate())
+
+	user.Roles = []entity.Role{{ID: 1, Name: "ROLE_USER"}}
+	assert.NoError(t, user.Validate())
+
+	// The rule survives a re-initialization of the validator
+	validation.ClearValidator()
+	user.Roles = nil
+	assertNoRoleError(t, user.Validate())
+}
+
+func TestAtLeastOneRole_Service(t *testing.T) {
+	db, s, ids := setupAdminGuard(t)
+	admin := asUser(ids["admin"], "admin", "ROLE_ADMIN")
+
+	// Without any default role, a user created or updated without a role would hold none
+	require.NoError(t, db.Exec(`UPDATE roles SET is_default = false`).Error)
+
+	_, err := s.CreateUser(admin, entity.UserCreateRequest{
+		Username:  "newuser",
+		Password:  "P@ssw0rd123",
+		Email:     "newuser@mygmail.com",
+		Firstname: "New",
+		UserType:  "USER_ACCOUNT",
+	})
+	assertNoRoleError(t, err)
+
+	_, err = s.UpdateUserRoles(admin, ids["user"], entity.UserRolesRequest{})
+	assertNoRoleError(t, err)
+
+	// The roles of the user are left unchanged
+	user, err := s.GetUserByID(ids["user"])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_USER"}, roleNames(user.Roles))
+}
+
+func TestAtLeastOneRole_Handlers(t *testing.T) {
+	db, s, ids := setupAdminGuard(t)
+	require.NoError(t, db.Exec(`UPDATE roles SET is_default = false`).Error)
+	h := handler.NewUserHandler(s)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(metacontext.InjectUserInformationMeta(c.Request.Context(),
+			metacontext.UserInformationMeta{UserID: ids["admin"], Username: "admin", Roles: []string{"ROLE_ADMIN"}}))
+		c.Next()
+	})
+	router.POST("/api/v1/users", h.CreateUser)
+	router.PUT("/api/v1/users/:id/roles", h.UpdateUserRoles)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"create", "POST", "/api/v1/users", `{"username":"newuser","password":"P@ssw0rd123","email":"newuser@mygmail.com","firstName":"New","userType":"USER_ACCOUNT"}`},
+		{"update", "PUT", fmt.Sprintf("/api/v1/users/%d/roles", ids["user"]), `{"roles":[]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+			var body struct {
+				Error []map[string]string `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			require.Len(t, body.Error, 1)
+			assert.Equal(t, "roles", body.Error[0]["field"])
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package test_role
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+// adminContext returns a context carrying the admin performing the requests.
+func adminContext() context.Context {
+	return metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{UserID: 1, Username: "admin"})
+}
+
+// roleNames returns the names of the roles.
+func roleNames(roles []entity.Role) []string {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.Name
+	}
+	return names
+}
+
+func TestCreateUser_DefaultRoles(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	tests := []struct {
+		name     string
+		username string
+		roles    []string
+		expected []string
+	}{
+		{"no role gets the default roles", "newuser", nil, []string{"ROLE_USER"}},
+		{"requested roles only", "newmoderator", []string{"ROLE_MODERATOR"}, []string{"ROLE_MODERATOR"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created, err := s.CreateUser(adminContext(), entity.UserCreateRequest{
+				Username:  tt.username,
+				Password:  "P@ssw0rd123",
+				Email:     tt.username + "@mygmail.com",
+				Firstname: "New",
+				UserType:  "USER_ACCOUNT",
+				Roles:     tt.roles,
+			})
+			require.NoError(t, err)
+			assert.NotEqual(t, "P@ssw0rd123", created.Password)
+			assert.Equal(t, int64(1), *created.CreatedBy)
+
+			// Read the user back to check the stored roles
+			user, err := s.GetUserByID(created.ID)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.expected, roleNames(user.Roles))
+		})
+	}
+}
+
+func TestCreateUser_ConfiguredDefaultRole(t *testing.T) {
+	setupDatabase(t)
+	t.Setenv("DEFAULT_USER_ROLE", "role_moderator")
+	s := service.NewUserService(repository.NewUserRepository())
+
+	tests := []struct {
+		name     string
+		username string
+		roles    []string
+		expected []string
+	}{
+		{"no role gets the configured role only", "newuser", nil, []string{"ROLE_MODERATOR"}},
+		{"requested roles override it", "newadmin", []string{"ROLE_USER"}, []string{"ROLE_USER"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created, err := s.CreateUser(adminContext(), entity.UserCreateRequest{
+				Username:  tt.username,
+				Password:  "P@ssw0rd123",
+				Email:     tt.username + "@mygmail.com",
+				Firstname: "New",
+				UserType:  "USER_ACCOUNT",
+				Roles:     tt.roles,
+			})
+			require.NoError(t, err)
+
+			user, err := s.GetUserByID(created.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, roleNames(user.Roles))
+		})
+	}
+}
+
+func TestValidateDefaultUserRole(t *testing.T) {
+	setupDatabase(t)
+
+	// Without a configured role, the roles flagged as default are used
+	assert.NoError(t, service.ValidateDefaultUserRole())
+
+	t.Setenv("DEFAULT_USER_ROLE", "role_moderator")
+	assert.NoError(t, service.ValidateDefaultUserRole())
+
+	t.Setenv("DEFAULT_USER_ROLE", "ROLE_AUDITOR")
+	err := service.ValidateDefaultUserRole()
+	assert.ErrorIs(t, err, service.ErrUnknownRole)
+	assert.ErrorContains(t, err, "DEFAULT_USER_ROLE ROLE_AUDITOR does not exist")
+}
+
+func TestCreateUser_Conflicts(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	req := entity.UserCreateRequest{
+		Username:  "newuser",
+		Password:  "P@ssw0rd123",
+		Email:     "newuser@mygmail.com",
+		Firstname: "New",
+		UserType:  "USER_ACCOUNT",
+	}
+	_, err := s.CreateUser(adminContext(), req)
+	require.NoError(t, err)
+
+	// The username is case-insensitive
+	req.Username = "NEWUSER"
+	req.Email = "other@mygmail.com"
+	_, err = s.CreateUser(adminContext(), req)
+	assert.ErrorIs(t, err, service.ErrUserAlreadyExists)
+
+	// The roles must exist
+	req.Username = "otheruser"
+	req.Roles = []string{"ROLE_USER", "ROLE_AUDITOR"}
+	_, err = s.CreateUser(adminContext(), req)
+	assert.ErrorIs(t, err, service.ErrUnknownRole)
+
+	// Every unknown role is reported at once
+	req.Roles = []string{"role_auditor", "ROLE_USER", "ROLE_BILLING"}
+	_, err = s.CreateUser(adminContext(), req)
+	assert.ErrorIs(t, err, service.ErrUnknownRole)
+	assert.ErrorContains(t, err, "ROLE_AUDITOR, ROLE_BILLING")
+}
+
+func TestRoleRepository_GetRolesByNames(t *testing.T) {
+	db := setupDatabase(t)
+
+	// The names are matched case-insensitively and the unknown names are ignored
+	roles, err := repository.NewRoleRepository().GetRolesByNames(db, []string{"role_moderator", "ROLE_USER", "ROLE_AUDITOR"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_USER", "ROLE_MODERATOR"}, roleNames(roles))
+}
+
+func TestRoleRepository_GetDefaultRoles(t *testing.T) {
+	db := setupDatabase(t)
+	assert.NoError(t, db.Exec(`UPDATE roles SET is_default = true WHERE name = 'ROLE_MODERATOR'`).Error)
+
+	roles, err := repository.NewRoleRepository().GetDefaultRoles(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_USER", "ROLE_MODERATOR"}, roleNames(roles))
+	for _, role := range roles {
+		assert.True(t, role.IsDefault)
+	}
+}
@@ -0,0 +1,78 @@
+package test_role
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/warning"
+)
+
+func TestCreateUser_EmailDomainWatchlist(t *testing.T) {
+	setupDatabase(t)
+	t.Setenv("USER_EMAIL_DOMAIN_WATCHLIST", "mailinator.com, TempMail.com")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(warning.Warnings(), func(c *gin.Context) {
+		meta := metacontext.UserInformationMeta{UserID: 1, Username: "admin"}
+		c.Request = c.Request.WithContext(metacontext.InjectUserInformationMeta(c.Request.Context(), meta))
+		c.Next()
+	})
+	h := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository()))
+	router.POST("/api/v1/users", h.CreateUser)
+
+	tests := []struct {
+		name     string
+		username string
+		email    string
+		warnings []string
+	}{
+		{"watchlisted domain", "watched", "watched@mailinator.com", []string{"the email domain mailinator.com is on the watchlist"}},
+		{"watchlisted domain in another case", "watchedcase", "watched@TEMPMAIL.com", []string{"the email domain tempmail.com is on the watchlist"}},
+		{"domain not watchlisted", "regular", "regular@mygmail.com", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]any{
+				"username":  tt.username,
+				"password":  "P@ssw0rd123",
+				"email":     tt.email,
+				"firstName": "New",
+				"userType":  "USER_ACCOUNT",
+			})
+			req, _ := http.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// The user is created anyway, the warnings are only present when there is one
+			require.Equal(t, http.StatusCreated, w.Code)
+			var response map[string]any
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.username, response["data"].(map[string]any)["username"])
+
+			warnings, ok := response["warnings"]
+			if tt.warnings == nil {
+				assert.False(t, ok)
+				return
+			}
+			var got []string
+			for _, w := range warnings.([]any) {
+				got = append(got, w.(string))
+			}
+			assert.Equal(t, tt.warnings, got)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package test_role
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// sortedRoleNames returns the names of the roles in alphabetical order.
+func sortedRoleNames(roles []entity.Role) []string {
+	names := roleNames(roles)
+	slices.Sort(names)
+	return names
+}
+
+func TestPatchUserRoles_AddAndRemove(t *testing.T) {
+	_, s, ids := setupAdminGuard(t)
+	admin := asUser(ids["admin"], "admin", "ROLE_ADMIN")
+
+	// The moderator gains ROLE_USER and ROLE_ADMIN and loses ROLE_MODERATOR
+	updated, err := s.PatchUserRoles(admin, ids["moderator"], entity.UserRolesPatchRequest{
+		Add:    []string{"role_user", "ROLE_ADMIN"},
+		Remove: []string{"ROLE_MODERATOR"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, sortedRoleNames(updated.Roles))
+
+	user, err := s.GetUserByID(ids["moderator"])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, sortedRoleNames(user.Roles))
+
+	// Adding a held role and removing one not held change nothing, the unspecified roles are kept
+	updated, err = s.PatchUserRoles(admin, ids["moderator"], entity.UserRolesPatchRequest{
+		Add:    []string{"ROLE_USER"},
+		Remove: []string{"ROLE_MODERATOR"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_USER"}, sortedRoleNames(updated.Roles))
+
+	// The roles of the other users are untouched
+	user, err = s.GetUserByID(ids["user"])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_USER"}, roleNames(user.Roles))
+}
+
+func TestPatchUserRoles_Rejected(t *testing.T) {
+	_, s, ids := setupAdminGuard(t)
+	admin := asUser(ids["admin"], "admin", "ROLE_ADMIN")
+	moderator := asUser(ids["moderator"], "moderator", "ROLE_MODERATOR")
+
+	tests := []struct {
+		name string
+		run  func() error
+		err  error
+	}{
+		{"unknown roles", func() error {
+			_, err := s.PatchUserRoles(admin, ids["user"], entity.UserRolesPatchRequest{Add: []string{"ROLE_AUDITOR"}, Remove: []string{"ROLE_BILLING"}})
+			return err
+		}, service.ErrUnknownRole},
+		{"moderator grants an admin role", func() error {
+			_, err := s.PatchUserRoles(moderator, ids["user"], entity.UserRolesPatchRequest{Add: []string{"ROLE_ADMIN"}})
+			return err
+		}, service.ErrRoleAssignmentForbidden},
+		{"last admin demoted", func() error {
+			_, err := s.PatchUserRoles(admin, ids["admin"], entity.UserRolesPatchRequest{Add: []string{"ROLE_USER"}, Remove: []string{"ROLE_ADMIN"}})
+			return err
+		}, service.ErrLastAdmin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.run(), tt.err)
+		})
+	}
+
+	// The last role of a user cannot be removed
+	_, err := s.PatchUserRoles(admin, ids["user"], entity.UserRolesPatchRequest{Remove: []string{"ROLE_USER"}})
+	assertNoRoleError(t, err)
+
+	// The unknown names of both lists are reported at once, by their path
+	_, err = s.PatchUserRoles(admin, ids["user"], entity.UserRolesPatchRequest{
+		Add:    []string{"ROLE_MODERATOR", "ROLE_AUDITOR"},
+		Remove: []string{"ROLE_BILLING"},
+	})
+	assert.ErrorContains(t, err, "ROLE_AUDITOR")
+	assert.ErrorContains(t, err, "ROLE_BILLING")
+	assert.Equal(t, []string{"add[1]", "remove[0]"}, errorFields(validation.FormatValidationErrors(err)))
+
+	// Nothing was applied
+	user, err := s.GetUserByID(ids["user"])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_USER"}, roleNames(user.Roles))
+}
+
+func TestPatchUserRoles_Handler(t *testing.T) {
+	_, s, ids := setupAdminGuard(t)
+	h := handler.NewUserHandler(s)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(metacontext.InjectUserInformationMeta(c.Request.Context(),
+			metacontext.UserInformationMeta{UserID: ids["admin"], Username: "admin", Roles: []string{"ROLE_ADMIN"}}))
+		c.Next()
+	})
+	router.PATCH("/api/v1/users/:id/roles", h.PatchUserRoles)
+
+	tests := []struct {
+		name   string
+		body   string
+		code   int
+		fields []string
+	}{
+		{"add and remove", `{"add":["ROLE_MODERATOR"],"remove":["ROLE_USER"]}`, http.StatusOK, nil},
+		{"unknown roles", `{"add":["ROLE_AUDITOR"],"remove":["ROLE_USER","ROLE_BILLING"]}`, http.StatusUnprocessableEntity, []string{"add[0]", "remove[1]"}},
+		{"added and removed", `{"add":["ROLE_ADMIN"],"remove":["role_admin"]}`, http.StatusUnprocessableEntity, []string{"remove[0]"}},
+		{"invalid names", `{"add":["admin"],"remove":["ROLE_USER","ROLE_"]}`, http.StatusUnprocessableEntity, []string{"add[0]", "remove[1]"}},
+		{"no roles", `{}`, http.StatusUnprocessableEntity, []string{"add", "remove"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("PATCH", fmt.Sprintf("/api/v1/users/%d/roles", ids["user"]), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code != http.StatusUnprocessableEntity {
+				return
+			}
+
+			var body struct {
+				Error []map[string]string `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.fields, errorFields(body.Error))
+		})
+	}
+
+	user, err := s.GetUserByID(ids["user"])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROLE_MODERATOR"}, roleNames(user.Roles))
+}
@@ -0,0 +1,119 @@
+package test_role
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// errorFields returns the fields of the formatted validation errors, in their order.
+func errorFields(errs []map[string]string) []string {
+	fields := make([]string, len(errs))
+	for i, err := range errs {
+		fields[i] = err["field"]
+	}
+	return fields
+}
+
+func TestUserValidate_NestedRoles(t *testing.T) {
+	user := validUser(entity.Role{Name: "ROLE_USER"}, entity.Role{Name: "admin"}, entity.Role{Name: "ROLE_MODERATOR"}, entity.Role{Name: "ROLE_SUPER-ADMIN"})
+	user.Email = "not-an-email"
+
+	// The user and each of its roles are validated at once, the roles by their index
+	errs := validation.FormatValidationErrors(user.Validate())
+	assert.Equal(t, []string{"email", "roles[1].roleName", "roles[3].roleName"}, errorFields(errs))
+	assert.Equal(t, "roles[1].roleName must be a role name such as ROLE_USER", errs[1]["message"])
+}
+
+func TestCreateUser_RoleErrors(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	// Every invalid and unknown name is reported, by its index in the request
+	_, err := s.CreateUser(adminContext(), entity.UserCreateRequest{
+		Username:  "newuser",
+		Password:  "P@ssw0rd123",
+		Email:     "newuser@mygmail.com",
+		Firstname: "New",
+		UserType:  "USER_ACCOUNT",
+		Roles:     []string{"ROLE_USER", "admin", "ROLE_AUDITOR", "ROLE_BAD ROLE", "role_billing", "ROLE_AUDITOR"},
+	})
+	assert.ErrorIs(t, err, service.ErrInvalidRoleName)
+	assert.ErrorIs(t, err, service.ErrUnknownRole)
+	assert.ErrorContains(t, err, "ROLE_AUDITOR, ROLE_BILLING")
+
+	errs := validation.FormatValidationErrors(err)
+	assert.Equal(t, []string{"roles[1]", "roles[2]", "roles[3]", "roles[4]"}, errorFields(errs))
+	assert.Equal(t, "roles[1] must be a role name such as ROLE_USER", errs[0]["message"])
+	assert.Equal(t, "roles[4] must be an existing role, ROLE_BILLING does not exist", errs[3]["message"])
+}
+
+func TestRoleErrors_Handlers(t *testing.T) {
+	_, s, ids := setupAdminGuard(t)
+	h := handler.NewUserHandler(s)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(metacontext.InjectUserInformationMeta(c.Request.Context(),
+			metacontext.UserInformationMeta{UserID: ids["admin"], Username: "admin", Roles: []string{"ROLE_ADMIN"}}))
+		c.Next()
+	})
+	router.POST("/api/v1/users", h.CreateUser)
+	router.PUT("/api/v1/users/:id/roles", h.UpdateUserRoles)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		fields []string
+	}{
+		{
+			"invalid fields and role names", "POST", "/api/v1/users",
+			`{"username":"newuser","password":"P@ssw0rd123","email":"not-an-email","firstName":"New","userType":"USER_ACCOUNT","roles":["ROLE_USER","admin","ROLE_MODERATOR","ROLE_"]}`,
+			[]string{"email", "roles[1]", "roles[3]"},
+		},
+		{
+			"unknown roles", "POST", "/api/v1/users",
+			`{"username":"newuser","password":"P@ssw0rd123","email":"newuser@mygmail.com","firstName":"New","userType":"USER_ACCOUNT","roles":["ROLE_AUDITOR","ROLE_USER","ROLE_BILLING"]}`,
+			[]string{"roles[0]", "roles[2]"},
+		},
+		{
+			"unknown roles on update", "PUT", fmt.Sprintf("/api/v1/users/%d/roles", ids["user"]),
+			`{"roles":["ROLE_USER","ROLE_AUDITOR","ROLE_BILLING"]}`,
+			[]string{"roles[1]", "roles[2]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+			var body struct {
+				Error []map[string]string `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.fields, errorFields(body.Error))
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package test_role
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+func TestRoleName_Validation(t *testing.T) {
+	tests := []struct {
+		name       string
+		roleName   string
+		valid      bool
+		normalized string
+	}{
+		{"conventional", "ROLE_ADMIN", true, "ROLE_ADMIN"},
+		{"many words", "ROLE_SUPER_ADMIN", true, "ROLE_SUPER_ADMIN"},
+		{"digits", "ROLE_LEVEL2", true, "ROLE_LEVEL2"},
+		{"lowercase", "role_admin", true, "ROLE_ADMIN"},
+		{"surrounding spaces", " Role_Admin ", true, "ROLE_ADMIN"},
+		{"no prefix", "admin", false, "ADMIN"},
+		{"no prefix with spaces", " Admin ", false, "ADMIN"},
+		{"prefix only", "ROLE_", false, "ROLE_"},
+		{"inner space", "ROLE_SUPER ADMIN", false, "ROLE_SUPER ADMIN"},
+		{"dash", "ROLE_SUPER-ADMIN", false, "ROLE_SUPER-ADMIN"},
+		{"double underscore", "ROLE__ADMIN", false, "ROLE__ADMIN"},
+		{"trailing underscore", "ROLE_ADMIN_", false, "ROLE_ADMIN_"},
+		{"too long", "ROLE_ABCDEFGHIJKLMNOP", false, "ROLE_ABCDEFGHIJKLMNOP"},
+		{"empty", "", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.valid, validation.IsValidRoleName(tt.roleName))
+			assert.Equal(t, tt.normalized, validation.NormalizeRoleName(tt.roleName))
+
+			// The role entity is normalized before being validated
+			role := entity.Role{Name: tt.roleName}
+			err := role.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.normalized, role.Name)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestCreateUser_RoleNames(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	req := entity.UserCreateRequest{
+		Username:  "newuser",
+		Password:  "P@ssw0rd123",
+		Email:     "newuser@mygmail.com",
+		Firstname: "New",
+		UserType:  "USER_ACCOUNT",
+	}
+
+	// The names are rejected when they do not follow the convention
+	req.Roles = []string{"ROLE_USER", " Admin "}
+	_, err := s.CreateUser(adminContext(), req)
+	assert.ErrorIs(t, err, service.ErrInvalidRoleName)
+
+	// The names are normalized before checking the roles exist, the duplicates are attached once
+	req.Roles = []string{" role_moderator", "ROLE_MODERATOR", "Role_User"}
+	created, err := s.CreateUser(adminContext(), req)
+	require.NoError(t, err)
+
+	user, err := s.GetUserByID(created.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ROLE_MODERATOR", "ROLE_USER"}, roleNames(user.Roles))
+}
+
+func TestGetRoleByName_Normalized(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewRoleService(repository.NewRoleRepository())
+
+	role, err := s.GetRoleByName(" role_admin ")
+	require.NoError(t, err)
+	assert.Equal(t, "ROLE_ADMIN", role.Name)
+}
@@ -0,0 +1,72 @@
+package test_role
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+func TestUserResponse_RolesSortedByName(t *testing.T) {
+	db := setupDatabase(t)
+
+	// The roles are assigned in the reverse order of their names and of their IDs
+	require.NoError(t, db.Exec(`INSERT INTO users (id, username, password, email, firstname, is_enabled, is_account_non_expired,
+		is_account_non_locked, is_credentials_non_expired, user_type) VALUES (1, 'bob', 'hash', 'bob@mygmail.com', 'Bob', true, true, true, true, 'USER_ACCOUNT')`).Error)
+	for _, roleID := range []int{3, 2, 1} {
+		require.NoError(t, db.Exec(`INSERT INTO user_roles (user_id, role_id) VALUES (1, ?)`, roleID).Error)
+	}
+
+	h := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository()))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(metacontext.InjectUserInformationMeta(c.Request.Context(),
+			metacontext.UserInformationMeta{UserID: 1, Username: "bob", Roles: []string{"ROLE_ADMIN"}}))
+		c.Next()
+	})
+	router.GET("/api/v1/users", h.GetUsers)
+
+	// get returns the data of the list of the users, without the timestamp of the response
+	get := func() json.RawMessage {
+		req, _ := http.NewRequest("GET", "/api/v1/users", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var body struct {
+			Data json.RawMessage `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Data
+	}
+
+	first := get()
+	var users []entity.UserResponse
+	require.NoError(t, json.Unmarshal(first, &users))
+	require.Len(t, users, 1)
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_MODERATOR", "ROLE_USER"}, roleNames(users[0].Roles))
+
+	// Two consecutive reads of the same user serialize to the same bytes
+	assert.Equal(t, string(first), string(get()))
+}
+
+func TestUserProfile_RolesSortedByName(t *testing.T) {
+	user := entity.User{Roles: []entity.Role{{ID: 1, Name: "ROLE_USER"}, {ID: 3, Name: "ROLE_ADMIN"}, {ID: 2, Name: "ROLE_MODERATOR"}}}
+
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_MODERATOR", "ROLE_USER"}, user.ToProfile().Roles)
+	assert.Equal(t, []string{"ROLE_ADMIN", "ROLE_MODERATOR", "ROLE_USER"}, roleNames(user.ToResponse().Roles))
+
+	// The roles of the user itself are left in their order
+	assert.Equal(t, []string{"ROLE_USER", "ROLE_ADMIN", "ROLE_MODERATOR"}, roleNames(user.Roles))
+}
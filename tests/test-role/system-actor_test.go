@@ -0,0 +1,56 @@
+package test_role
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+func TestWithSystemActor(t *testing.T) {
+	meta, ok := metacontext.ExtractUserInformationMeta(metacontext.WithSystemActor(context.Background(), "cli"))
+	require.True(t, ok)
+	assert.True(t, meta.IsSystem)
+	assert.Equal(t, metacontext.SystemUserID, meta.UserID)
+	assert.Equal(t, "system:cli", meta.Actor())
+
+	meta, ok = metacontext.ExtractUserInformationMeta(adminContext())
+	require.True(t, ok)
+	assert.False(t, meta.IsSystem)
+	assert.Equal(t, "user:admin", meta.Actor())
+}
+
+func TestCreateUser_SystemActor(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	// The CLI bootstrap has no HTTP request, the user is created by the system user
+	created, err := s.CreateUser(metacontext.WithSystemActor(context.Background(), "cli"), entity.UserCreateRequest{
+		Username:  "bootstrap",
+		Password:  "P@ssw0rd123",
+		Email:     "bootstrap@mygmail.com",
+		Firstname: "Bootstrap",
+		UserType:  "USER_ACCOUNT",
+		Roles:     []string{"ROLE_ADMIN"},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, created.CreatedBy)
+	assert.Equal(t, metacontext.SystemUserID, *created.CreatedBy)
+	assert.Equal(t, metacontext.SystemUserID, *created.UpdatedBy)
+
+	// Without any actor, the creation is still refused
+	_, err = s.CreateUser(context.Background(), entity.UserCreateRequest{
+		Username:  "anonymous",
+		Password:  "P@ssw0rd123",
+		Email:     "anonymous@mygmail.com",
+		Firstname: "Anonymous",
+		UserType:  "USER_ACCOUNT",
+	})
+	assert.EqualError(t, err, "missing user context")
+}
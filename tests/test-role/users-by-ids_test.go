@@ -0,0 +1,56 @@
+package test_role
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+)
+
+func TestGetUsersByIDs(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	var ids []int64
+	for i := 1; i <= 3; i++ {
+		created, err := s.CreateUser(adminContext(), entity.UserCreateRequest{
+			Username:  fmt.Sprintf("user%d", i),
+			Password:  "P@ssw0rd123",
+			Email:     fmt.Sprintf("user%d@mygmail.com", i),
+			Firstname: "User",
+			UserType:  "USER_ACCOUNT",
+			Roles:     []string{"ROLE_MODERATOR"},
+		})
+		require.NoError(t, err)
+		ids = append(ids, created.ID)
+	}
+
+	// The users come back in the order of the IDs, the unknown and repeated IDs are tolerated
+	users, missing, err := s.GetUsersByIDs(adminContext(), []int64{ids[2], 404, ids[0], ids[2], 405}, true)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, "user3", users[0].Username)
+	assert.Equal(t, "user1", users[1].Username)
+	assert.Equal(t, []int64{404, 405}, missing)
+	require.Len(t, users[0].Roles, 1)
+	assert.Equal(t, "ROLE_MODERATOR", users[0].Roles[0].Name)
+
+	// The roles are only loaded on demand
+	users, missing, err = s.GetUsersByIDs(adminContext(), ids, false)
+	require.NoError(t, err)
+	require.Len(t, users, 3)
+	assert.Empty(t, missing)
+	for _, user := range users {
+		assert.Empty(t, user.Roles)
+	}
+
+	users, missing, err = s.GetUsersByIDs(adminContext(), nil, true)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+	assert.Empty(t, missing)
+}
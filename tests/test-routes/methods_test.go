@@ -0,0 +1,151 @@
+package test_routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/routes"
+)
+
+// routeMethods returns, for the path of every route of the router with its parameters replaced by a value,
+// the methods having a route that matches this path. The tests iterate over it, so every new route is covered.
+func routeMethods(router *gin.Engine) map[string][]string {
+	paths := make(map[string]bool)
+	for _, route := range router.Routes() {
+		segments := strings.Split(route.Path, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+				segments[i] = "1"
+			}
+		}
+		paths[strings.Join(segments, "/")] = true
+	}
+
+	methods := make(map[string][]string)
+	for path := range paths {
+		for _, route := range router.Routes() {
+			if matchRoute(route.Path, path) && !slices.Contains(methods[path], route.Method) {
+				methods[path] = append(methods[path], route.Method)
+			}
+		}
+	}
+	return methods
+}
+
+// matchRoute reports whether the route pattern matches the path, a parameter matches any segment.
+func matchRoute(pattern string, path string) bool {
+	patternSegments, pathSegments := strings.Split(pattern, "/"), strings.Split(path, "/")
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if i >= len(pathSegments) || (segment != pathSegments[i] && !strings.HasPrefix(segment, ":")) {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
+
+// expectedAllow returns the methods of the Allow header for a path served by the given methods.
+func expectedAllow(methods []string) []string {
+	allow := slices.Clone(methods)
+	if slices.Contains(allow, http.MethodGet) {
+		allow = append(allow, http.MethodHead)
+	}
+	allow = append(allow, http.MethodOptions)
+	slices.Sort(allow)
+	return allow
+}
+
+// allowedMethods returns the sorted methods of the Allow header of the response.
+func allowedMethods(w *httptest.ResponseRecorder) []string {
+	allow := strings.Split(w.Header().Get("Allow"), ", ")
+	slices.Sort(allow)
+	return allow
+}
+
+func serve(router *gin.Engine, method string, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRoutes_HeadServedLikeGet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := routes.SetupRouter()
+
+	for path, methods := range routeMethods(router) {
+		if !slices.Contains(methods, http.MethodGet) {
+			continue
+		}
+
+		t.Run(path, func(t *testing.T) {
+			get := serve(router, http.MethodGet, path)
+			head := serve(router, http.MethodHead, path)
+
+			// Same status and headers, no body
+			assert.Equal(t, get.Code, head.Code)
+			assert.Empty(t, head.Body.String())
+			for name := range get.Header() {
+				if name == "Date" {
+					continue
+				}
+				assert.Equal(t, get.Header().Values(name), head.Header().Values(name), name)
+			}
+		})
+	}
+}
+
+func TestRoutes_OptionsListsAllowedMethods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := routes.SetupRouter()
+
+	for path, methods := range routeMethods(router) {
+		t.Run(path, func(t *testing.T) {
+			w := serve(router, http.MethodOptions, path)
+
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, expectedAllow(methods), allowedMethods(w))
+		})
+	}
+}
+
+func TestRoutes_MethodNotAllowed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := routes.SetupRouter()
+
+	for path, methods := range routeMethods(router) {
+		// A method the path does not serve, which needs no body
+		method := http.MethodDelete
+		if slices.Contains(methods, method) {
+			method = http.MethodTrace
+		}
+		require.NotContains(t, methods, method)
+
+		t.Run(path, func(t *testing.T) {
+			w := serve(router, method, path)
+
+			assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+			assert.Equal(t, expectedAllow(methods), allowedMethods(w))
+		})
+	}
+}
+
+func TestRoutes_UnknownPath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := routes.SetupRouter()
+
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodDelete} {
+		w := serve(router, method, "/api/v1/unknown")
+		assert.Equal(t, http.StatusNotFound, w.Code, method)
+		assert.Empty(t, w.Header().Get("Allow"), method)
+	}
+}
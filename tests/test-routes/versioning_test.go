@@ -0,0 +1,45 @@
+package test_routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/routes"
+)
+
+func TestSetupRouter_ConfiguredBasePath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		basePath  string
+		reachable string
+		missing   string
+	}{
+		{"default base path", "", "/api/v1/consumers", "/v1/consumers"},
+		{"custom base path", "/consumer-api/", "/consumer-api/v1/consumers", "/api/v1/consumers"},
+		{"root base path", "/", "/v1/users/me/login-history", "/api/v1/users/me/login-history"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("API_BASE_PATH", tt.basePath)
+			router := routes.SetupRouter()
+
+			// The route exists, the request is only rejected for its missing token
+			req, _ := http.NewRequest("GET", tt.reachable, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+			req, _ = http.NewRequest("GET", tt.missing, nil)
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package test_secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/authorization"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/secret"
+)
+
+const (
+	firstSecret  = "a-first-secret-at-least-256-bits-long"
+	secondSecret = "a-second-secret-at-least-256-bits-long"
+)
+
+// fakeProvider is a secret provider holding its secrets in memory, rotated by the tests.
+type fakeProvider struct {
+	mu     sync.Mutex
+	values map[string]string
+	err    error
+}
+
+// GetSecret returns the value of the secret, or the error of the provider if it is set.
+func (p *fakeProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return "", p.err
+	}
+	value, ok := p.values[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", secret.ErrSecretNotFound, name)
+	}
+	return value, nil
+}
+
+// rotate replaces the value of a secret.
+func (p *fakeProvider) rotate(name string, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.values[name] = value
+}
+
+// fail makes the provider fail until it is called again with nil.
+func (p *fakeProvider) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.err = err
+}
+
+// setupJWT configures the HS256 tokens from the environment and returns a refresher watching the JWT secret
+// of the given provider, applied as the application does.
+func setupJWT(t *testing.T, provider secret.SecretProvider) *secret.Refresher {
+	t.Setenv("TOKEN_TYPE", "Bearer")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("JWT_SECRET", "the-secret-of-the-environment-variable")
+	t.Setenv("JWT_KEY_ID", "")
+	t.Setenv("JWT_PREVIOUS_KEYS", "")
+
+	refresher := secret.NewRefresher(provider, 0)
+	refresher.Watch("JWT_KEY_ID", nil)
+	refresher.Watch("JWT_PREVIOUS_KEYS", nil)
+	refresher.Watch("JWT_SECRET", func(value string) {
+		service.SetJWTSecret(value)
+		authorization.SetJWTSecret(value)
+	})
+	require.NoError(t, refresher.Load(context.Background()))
+
+	// The secret is read from the environment at startup
+	service.SetJWTSecret(os.Getenv("JWT_SECRET"))
+	t.Cleanup(func() { service.SetJWTSecret("") })
+	return refresher
+}
+
+// generateToken signs a token of the admin with the current secret.
+func generateToken(t *testing.T) string {
+	token, err := service.GenerateJWTTokenWithHS256(entity.User{ID: 1, Username: "admin", Email: "admin@mygmail.com", TenantID: 1}, time.Now())
+	require.NoError(t, err)
+	return token
+}
+
+// verifies reports whether the token is signed with the given secret.
+func verifies(token string, key string) bool {
+	_, err := jwt.Parse(token, func(*jwt.Token) (any, error) { return []byte(key), nil })
+	return err == nil
+}
+
+// newRouter returns a router authenticating its requests with the JWT validation middleware.
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(authorization.JwtValidation())
+	router.GET("/me", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+// authenticate sends a request carrying the token through the router and returns the status code.
+func authenticate(router *gin.Engine, token string) int {
+	req, _ := http.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRefresher_RotatesJWTSecret(t *testing.T) {
+	provider := &fakeProvider{values: map[string]string{"JWT_SECRET": firstSecret}}
+	refresher := setupJWT(t, provider)
+	assert.Equal(t, firstSecret, os.Getenv("JWT_SECRET"))
+
+	router := newRouter()
+	first := generateToken(t)
+	assert.True(t, verifies(first, firstSecret))
+	assert.Equal(t, http.StatusOK, authenticate(router, first))
+
+	// Nothing changes until the secret is rotated
+	changed, err := refresher.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+
+	// The signer and the middleware pick up the rotated secret without a restart
+	provider.rotate("JWT_SECRET", secondSecret)
+	changed, err = refresher.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"JWT_SECRET"}, changed)
+	assert.Equal(t, secondSecret, os.Getenv("JWT_SECRET"))
+
+	second := generateToken(t)
+	assert.True(t, verifies(second, secondSecret))
+	assert.False(t, verifies(second, firstSecret))
+	assert.Equal(t, http.StatusOK, authenticate(router, second))
+
+	// The tokens signed with the previous secret no longer verify
+	_, err = service.ParseJWTTokenWithHS256(first)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, authenticate(router, first))
+}
+
+func TestRefresher_RotatesJWTSecretWithPreviousKeys(t *testing.T) {
+	provider := &fakeProvider{values: map[string]string{"JWT_KEY_ID": "2025-01", "JWT_SECRET": firstSecret}}
+	refresher := setupJWT(t, provider)
+
+	router := newRouter()
+	first := generateToken(t)
+
+	// The secret rotated along with a new key ID and the previous key keeps the issued tokens valid
+	provider.rotate("JWT_KEY_ID", "2025-02")
+	provider.rotate("JWT_PREVIOUS_KEYS", "2025-01="+firstSecret)
+	provider.rotate("JWT_SECRET", secondSecret)
+	changed, err := refresher.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"JWT_KEY_ID", "JWT_PREVIOUS_KEYS", "JWT_SECRET"}, changed)
+
+	second := generateToken(t)
+	assert.True(t, verifies(second, secondSecret))
+	assert.Equal(t, http.StatusOK, authenticate(router, first))
+	assert.Equal(t, http.StatusOK, authenticate(router, second))
+
+	_, err = service.ParseJWTTokenWithHS256(first)
+	assert.NoError(t, err)
+}
+
+func TestRefresher_MissingAndFailingSecrets(t *testing.T) {
+	t.Setenv("DB_USER", "appuser")
+	t.Setenv("DB_PASS", "app@123")
+
+	var users, passwords []string
+	provider := &fakeProvider{values: map[string]string{"DB_PASS": "first-password"}}
+	refresher := secret.NewRefresher(provider, 0)
+	refresher.Watch("DB_USER", func(value string) { users = append(users, value) })
+	refresher.Watch("DB_PASS", func(value string) { passwords = append(passwords, value) })
+
+	// A secret the provider does not have keeps the value of its environment variable
+	require.NoError(t, refresher.Load(context.Background()))
+	assert.Equal(t, "appuser", os.Getenv("DB_USER"))
+	assert.Equal(t, "first-password", os.Getenv("DB_PASS"))
+
+	// A failing provider keeps the current values
+	provider.fail(errors.New("vault sealed"))
+	changed, err := refresher.Refresh(context.Background())
+	assert.ErrorContains(t, err, "vault sealed")
+	assert.Empty(t, changed)
+	assert.Equal(t, "first-password", os.Getenv("DB_PASS"))
+
+	provider.fail(nil)
+	provider.rotate("DB_USER", "rotated-user")
+	provider.rotate("DB_PASS", "second-password")
+	changed, err = refresher.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DB_USER", "DB_PASS"}, changed)
+	assert.Equal(t, []string{"rotated-user"}, users)
+	assert.Equal(t, []string{"second-password"}, passwords)
+
+	// The startup fails when the provider cannot be reached
+	provider.fail(errors.New("vault sealed"))
+	startup := secret.NewRefresher(provider, 0)
+	startup.Watch("DB_PASS", nil)
+	assert.ErrorContains(t, startup.Load(context.Background()), "vault sealed")
+}
+
+func TestRefresher_Start(t *testing.T) {
+	t.Setenv("JWT_SECRET", "")
+
+	rotated := make(chan string, 1)
+	provider := &fakeProvider{values: map[string]string{"JWT_SECRET": firstSecret}}
+	refresher := secret.NewRefresher(provider, 10*time.Millisecond)
+	refresher.Watch("JWT_SECRET", func(value string) { rotated <- value })
+	require.NoError(t, refresher.Load(context.Background()))
+
+	refresher.Start()
+	defer refresher.Stop()
+	provider.rotate("JWT_SECRET", secondSecret)
+
+	select {
+	case value := <-rotated:
+		assert.Equal(t, secondSecret, value)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the rotated secret was not refreshed")
+	}
+}
+
+func TestProviders(t *testing.T) {
+	// The environment is the default provider
+	t.Setenv("SECRET_PROVIDER", "")
+	assert.Equal(t, secret.EnvProviderName, secret.GetProviderName())
+	provider, err := secret.New(secret.GetProviderName())
+	require.NoError(t, err)
+
+	t.Setenv("JWT_SECRET", firstSecret)
+	value, err := provider.GetSecret(context.Background(), "JWT_SECRET")
+	require.NoError(t, err)
+	assert.Equal(t, firstSecret, value)
+
+	t.Setenv("JWT_SECRET", "")
+	_, err = provider.GetSecret(context.Background(), "JWT_SECRET")
+	assert.ErrorIs(t, err, secret.ErrSecretNotFound)
+
+	// An external provider is selected by the name it was registered under
+	fake := &fakeProvider{values: map[string]string{}}
+	secret.Register("fake", func() (secret.SecretProvider, error) { return fake, nil })
+	t.Setenv("SECRET_PROVIDER", "FAKE")
+	provider, err = secret.New(secret.GetProviderName())
+	require.NoError(t, err)
+	assert.Same(t, fake, provider)
+
+	_, err = secret.New("vault")
+	assert.ErrorIs(t, err, secret.ErrUnknownProvider)
+}
+
+func TestGetRefreshInterval(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"5m", 5 * time.Minute},
+		{"0", 0},
+		{"-1m", 0},
+		{"often", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("SECRET_REFRESH_INTERVAL", tt.value)
+			assert.Equal(t, tt.want, secret.GetRefreshInterval())
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package test_server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/server"
+)
+
+// writeSelfSignedCert writes a self-signed certificate with the given serial number to the given files.
+func writeSelfSignedCert(t *testing.T, certFile string, keyFile string, serial int64) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+}
+
+// servedSerial returns the serial number of the certificate currently served by the reloader.
+func servedSerial(t *testing.T, r *server.CertReloader) int64 {
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	writeSelfSignedCert(t, certFile, keyFile, 1)
+	reloader, err := server.NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), servedSerial(t, reloader))
+
+	// Rotate the certificate files and reload
+	writeSelfSignedCert(t, certFile, keyFile, 2)
+	require.NoError(t, reloader.Reload())
+	assert.Equal(t, int64(2), servedSerial(t, reloader))
+
+	// A broken file keeps the previous certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0600))
+	assert.Error(t, reloader.Reload())
+	assert.Equal(t, int64(2), servedSerial(t, reloader))
+}
+
+func TestNewServer_TLSWithHTTP2AndRedirect(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeSelfSignedCert(t, certFile, keyFile, 1)
+
+	srv, err := server.NewServer(server.ServerConfig{
+		Port:             "8443",
+		IsSSL:            true,
+		CertFile:         certFile,
+		KeyFile:          keyFile,
+		HTTPRedirectPort: "8080",
+	}, http.NotFoundHandler())
+	require.NoError(t, err)
+
+	assert.NotNil(t, srv.CertReloader())
+	assert.Contains(t, srv.HTTPServer().TLSConfig.NextProtos, "h2")
+	assert.NotNil(t, srv.RedirectServer())
+}
+
+func TestServerConfig_Validate(t *testing.T) {
+	assert.Error(t, server.ServerConfig{}.Validate())
+	assert.NoError(t, server.ServerConfig{Port: "1000"}.Validate())
+	assert.Error(t, server.ServerConfig{Port: "1000", IsSSL: true}.Validate())
+	assert.Error(t, server.ServerConfig{Port: "443", IsSSL: true, AutocertCacheDir: "/tmp/certs"}.Validate())
+	assert.NoError(t, server.ServerConfig{Port: "443", IsSSL: true, AutocertCacheDir: "/tmp/certs", AutocertDomains: []string{"api.example.com"}}.Validate())
+}
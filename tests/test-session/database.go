@@ -0,0 +1,31 @@
+package test_session
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with an admin (ID 1) and a user (ID 2) of the default tenant,
+// and makes the services use it instead of PostgreSQL.
+// The transactions are started with BEGIN IMMEDIATE, which serializes them like the row lock on the user does in PostgreSQL.
+func setupDatabase(t *testing.T) *gorm.DB {
+	return testdb.Use(t, testdb.New(t, testdb.Options{Immediate: true},
+		`INSERT INTO users (id, username, password, email, firstname, user_type) VALUES
+			(1, 'admin', '!', 'admin@mygmail.com', 'Admin', 'USER_ACCOUNT'),
+			(2, 'user', '!', 'user@mygmail.com', 'User', 'USER_ACCOUNT')`,
+		`INSERT INTO roles (id, name) VALUES (1, 'ROLE_ADMIN'), (2, 'ROLE_USER')`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES (1, 1), (2, 2)`,
+	))
+}
+
+// countSessions returns the number of refresh tokens of the user.
+func countSessions(t *testing.T, db *gorm.DB, userID int64) int64 {
+	var count int64
+	if err := db.Table("refresh_token").Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		t.Fatalf("failed to count sessions: %v", err)
+	}
+	return count
+}
@@ -0,0 +1,138 @@
+package test_session
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+)
+
+const concurrentLogins = 20
+
+// createSessionsConcurrently creates the sessions of the user from concurrent goroutines,
+// and returns the number of created sessions and the number of rejected ones.
+func createSessionsConcurrently(userID int64, n int) (int, int, []error) {
+	s := service.NewRefreshTokenService(repository.NewRefreshTokenRepository(), clock.New())
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var created, rejected int
+	var errs []error
+
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			_, err := s.CreateRefreshToken(userID, "", "")
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				created++
+			case errors.Is(err, service.ErrSessionLimitReached):
+				rejected++
+			default:
+				errs = append(errs, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	return created, rejected, errs
+}
+
+func TestSessionLimit_RejectConcurrentLogins(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("MAX_SESSIONS_PER_USER", "3")
+	t.Setenv("SESSION_LIMIT_POLICY", "REJECT")
+
+	created, rejected, errs := createSessionsConcurrently(1, concurrentLogins)
+
+	assert.Empty(t, errs)
+	assert.Equal(t, 3, created)
+	assert.Equal(t, concurrentLogins-3, rejected)
+	assert.Equal(t, int64(3), countSessions(t, db, 1))
+}
+
+func TestSessionLimit_EvictOldestConcurrentLogins(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("MAX_SESSIONS_PER_USER", "3")
+	t.Setenv("SESSION_LIMIT_POLICY", "EVICT_OLDEST")
+
+	created, rejected, errs := createSessionsConcurrently(1, concurrentLogins)
+
+	assert.Empty(t, errs)
+	assert.Equal(t, concurrentLogins, created)
+	assert.Zero(t, rejected)
+	assert.Equal(t, int64(3), countSessions(t, db, 1))
+}
+
+func TestSessionLimit_EvictsOldestSession(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("MAX_SESSIONS_PER_USER", "2")
+	t.Setenv("SESSION_LIMIT_POLICY", "EVICT_OLDEST")
+	s := service.NewRefreshTokenService(repository.NewRefreshTokenRepository(), clock.New())
+
+	first, err := s.CreateRefreshToken(1, "", "")
+	assert.NoError(t, err)
+	second, err := s.CreateRefreshToken(1, "", "")
+	assert.NoError(t, err)
+	third, err := s.CreateRefreshToken(1, "", "")
+	assert.NoError(t, err)
+
+	_, err = s.GetRefreshTokenByToken(first.Token)
+	assert.Error(t, err)
+	_, err = s.GetRefreshTokenByToken(second.Token)
+	assert.NoError(t, err)
+	_, err = s.GetRefreshTokenByToken(third.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), countSessions(t, db, 1))
+}
+
+func TestSessionLimit_PerUserOverride(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("MAX_SESSIONS_PER_USER", "3")
+	t.Setenv("SESSION_LIMIT_POLICY", "REJECT")
+	assert.NoError(t, db.Exec("UPDATE users SET max_sessions = 1 WHERE id = 2").Error)
+
+	created, rejected, errs := createSessionsConcurrently(2, concurrentLogins)
+
+	assert.Empty(t, errs)
+	assert.Equal(t, 1, created)
+	assert.Equal(t, concurrentLogins-1, rejected)
+
+	// The other users keep the global limit
+	created, _, errs = createSessionsConcurrently(1, concurrentLogins)
+	assert.Empty(t, errs)
+	assert.Equal(t, 3, created)
+	assert.Equal(t, int64(1), countSessions(t, db, 2))
+}
+
+func TestSessionLimit_RotationKeepsSessionCount(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("MAX_SESSIONS_PER_USER", "1")
+	t.Setenv("SESSION_LIMIT_POLICY", "REJECT")
+	s := service.NewRefreshTokenService(repository.NewRefreshTokenRepository(), clock.New())
+
+	session, err := s.CreateRefreshToken(1, "", "")
+	assert.NoError(t, err)
+
+	rotated, err := s.RotateRefreshToken(session.Token)
+	assert.NoError(t, err)
+	assert.NotEqual(t, session.Token, rotated.Token)
+	assert.Equal(t, int64(1), countSessions(t, db, 1))
+
+	// A refresh token can only be rotated once
+	_, err = s.RotateRefreshToken(session.Token)
+	assert.Error(t, err)
+}
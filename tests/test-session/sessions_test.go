@@ -0,0 +1,158 @@
+package test_session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/clock"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+// callers are the authenticated users of the requests, by their ID.
+var callers = map[int64]metacontext.UserInformationMeta{
+	1: {UserID: 1, Username: "admin", Roles: []string{"ROLE_ADMIN"}, TenantID: metacontext.DefaultTenantID},
+	2: {UserID: 2, Username: "user", Roles: []string{"ROLE_USER"}, TenantID: metacontext.DefaultTenantID},
+}
+
+// setupSessionRouter registers the session routes, authenticated as the user given by the X-Caller header.
+func setupSessionRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		var callerID int64
+		fmt.Sscan(c.GetHeader("X-Caller"), &callerID)
+		ctx := metacontext.InjectUserInformationMeta(c.Request.Context(), callers[callerID])
+		c.Request = c.Request.WithContext(metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID))
+		c.Next()
+	})
+
+	h := handler.NewSessionHandler(service.NewSessionService(repository.NewRefreshTokenRepository()))
+	router.GET("/api/v1/users/:id/sessions", h.GetUserSessions)
+	router.DELETE("/api/v1/users/:id/sessions/:sessionId", h.RevokeUserSession)
+
+	return router
+}
+
+func serve(router *gin.Engine, method string, path string, callerID int64) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	req.Header.Set("X-Caller", fmt.Sprint(callerID))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// listSessions lists the sessions of the user as the caller, and fails the test unless it succeeds.
+func listSessions(t *testing.T, router *gin.Engine, userID int64, callerID int64) []entity.SessionResponse {
+	w := serve(router, "GET", fmt.Sprintf("/api/v1/users/%d/sessions", userID), callerID)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Data []entity.SessionResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+func TestUserSessions_ListActiveSessions(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("MAX_SESSIONS_PER_USER", "0")
+	router := setupSessionRouter()
+	s := service.NewRefreshTokenService(repository.NewRefreshTokenRepository(), clock.New())
+
+	laptop, err := s.CreateRefreshToken(2, "10.0.0.1", "Firefox")
+	require.NoError(t, err)
+	phone, err := s.CreateRefreshToken(2, "10.0.0.2", "Mobile Safari")
+	require.NoError(t, err)
+	_, err = s.CreateRefreshToken(1, "10.0.0.3", "curl")
+	require.NoError(t, err)
+
+	// An expired session is not listed
+	require.NoError(t, db.Exec(`INSERT INTO refresh_token (token, session_id, user_id, expiry_date, created_at) VALUES ('expired', 'expired-session', 2, ?, ?)`,
+		time.Now().UTC().Add(-time.Hour), time.Now().UTC().Add(-48*time.Hour)).Error)
+
+	// A rotated refresh token stays the same session
+	rotated, err := s.RotateRefreshToken(laptop.Token)
+	require.NoError(t, err)
+	assert.Equal(t, laptop.SessionID, rotated.SessionID)
+
+	sessions := listSessions(t, router, 2, 2)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, phone.SessionID, sessions[0].ID)
+	assert.Equal(t, "10.0.0.2", sessions[0].IPAddress)
+	assert.Equal(t, "Mobile Safari", sessions[0].UserAgent)
+	assert.Equal(t, laptop.SessionID, sessions[1].ID)
+	assert.Equal(t, "10.0.0.1", sessions[1].IPAddress)
+	assert.Equal(t, "Firefox", sessions[1].UserAgent)
+	assert.WithinDuration(t, laptop.CreatedAt, sessions[1].CreatedAt, time.Second)
+	assert.WithinDuration(t, rotated.ExpiryDate, sessions[1].ExpiresAt, time.Second)
+
+	// The refresh tokens are never exposed
+	w := serve(router, "GET", "/api/v1/users/2/sessions", 2)
+	assert.NotContains(t, w.Body.String(), rotated.Token)
+	assert.NotContains(t, w.Body.String(), phone.Token)
+
+	// The admin lists the sessions of any user, a user only their own
+	assert.Len(t, listSessions(t, router, 2, 1), 2)
+	assert.Len(t, listSessions(t, router, 1, 1), 1)
+	assert.Equal(t, http.StatusForbidden, serve(router, "GET", "/api/v1/users/1/sessions", 2).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/api/v1/users/99/sessions", 1).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(router, "GET", "/api/v1/users/abc/sessions", 1).Code)
+}
+
+func TestUserSessions_RevokeSession(t *testing.T) {
+	setupDatabase(t)
+	t.Setenv("MAX_SESSIONS_PER_USER", "0")
+	router := setupSessionRouter()
+	s := service.NewRefreshTokenService(repository.NewRefreshTokenRepository(), clock.New())
+
+	laptop, err := s.CreateRefreshToken(2, "10.0.0.1", "Firefox")
+	require.NoError(t, err)
+	phone, err := s.CreateRefreshToken(2, "10.0.0.2", "Mobile Safari")
+	require.NoError(t, err)
+	admin, err := s.CreateRefreshToken(1, "10.0.0.3", "curl")
+	require.NoError(t, err)
+
+	// A user cannot revoke the session of another user, nor a session of someone else through their own ID
+	assert.Equal(t, http.StatusForbidden, serve(router, "DELETE", "/api/v1/users/1/sessions/"+admin.SessionID, 2).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "DELETE", "/api/v1/users/2/sessions/"+admin.SessionID, 2).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "DELETE", "/api/v1/users/2/sessions/unknown", 2).Code)
+
+	// The revoked session can no longer be refreshed, the other one is untouched
+	w := serve(router, "DELETE", "/api/v1/users/2/sessions/"+laptop.SessionID, 2)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	_, err = s.RotateRefreshToken(laptop.Token)
+	assert.Error(t, err)
+	sessions := listSessions(t, router, 2, 2)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, phone.SessionID, sessions[0].ID)
+
+	// A session is only revoked once
+	assert.Equal(t, http.StatusNotFound, serve(router, "DELETE", "/api/v1/users/2/sessions/"+laptop.SessionID, 2).Code)
+
+	// The admin revokes the sessions of any user
+	w = serve(router, "DELETE", "/api/v1/users/2/sessions/"+phone.SessionID, 1)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, listSessions(t, router, 2, 2))
+	assert.Len(t, listSessions(t, router, 1, 1), 1)
+}
+
+func TestUserSessions_MissingUserContext(t *testing.T) {
+	setupDatabase(t)
+
+	_, err := service.NewSessionService(repository.NewRefreshTokenRepository()).GetUserSessions(context.Background(), 2)
+	assert.Error(t, err)
+}
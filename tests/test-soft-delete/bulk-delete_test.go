@@ -0,0 +1,75 @@
+package test_soft_delete
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+// adminContext returns a context carrying the admin performing the requests.
+func adminContext() context.Context {
+	return metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{UserID: 1, Username: "admin"})
+}
+
+func TestBulkDeleteUsers_Success(t *testing.T) {
+	db := setupDatabase(t)
+	require.NoError(t, db.Exec(`INSERT INTO refresh_token (token, session_id, user_id, expiry_date, created_at) VALUES ('token-2', 'session-2', 2, ?, ?)`,
+		time.Now().Add(time.Hour), time.Now()).Error)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	results, err := s.BulkDeleteUsers(adminContext(), []int64{2, 42, 1, 3})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	// The results follow the order of the IDs
+	assert.Equal(t, entity.UserBulkDeleteResult{ID: 2, Status: entity.UserBulkDeleteDeleted}, results[0])
+	assert.Equal(t, entity.UserBulkDeleteNotFound, results[1].Status)
+	assert.Equal(t, entity.UserBulkDeleteSkipped, results[2].Status)
+	assert.NotNil(t, results[2].Error)
+	assert.Equal(t, entity.UserBulkDeleteResult{ID: 3, Status: entity.UserBulkDeleteDeleted}, results[3])
+
+	// The deleted users are soft-deleted by the admin, the admin is kept
+	var users []entity.User
+	require.NoError(t, db.Unscoped().Order("id").Find(&users).Error)
+	require.Len(t, users, 3)
+	assert.False(t, users[0].DeletedAt.Valid)
+	for _, user := range users[1:] {
+		assert.True(t, user.DeletedAt.Valid)
+		assert.True(t, *user.IsDeleted)
+		assert.Equal(t, int64(1), *user.DeletedBy)
+	}
+
+	// The sessions of the deleted users are revoked
+	var sessions int64
+	require.NoError(t, db.Table("refresh_token").Count(&sessions).Error)
+	assert.Equal(t, int64(0), sessions)
+
+	// Deleting the same users again reports them as not found
+	results, err = s.BulkDeleteUsers(adminContext(), []int64{2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserBulkDeleteNotFound, results[0].Status)
+	assert.Equal(t, entity.UserBulkDeleteNotFound, results[1].Status)
+}
+
+func TestBulkDeleteUsers_RollbackOnError(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	// Revoking the sessions fails, no user is deleted
+	require.NoError(t, db.Exec(`DROP TABLE refresh_token`).Error)
+
+	_, err := s.BulkDeleteUsers(adminContext(), []int64{2, 3})
+	require.Error(t, err)
+
+	var count int64
+	require.NoError(t, db.Model(&entity.User{}).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+}
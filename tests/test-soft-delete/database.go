@@ -0,0 +1,20 @@
+package test_soft_delete
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with the tables of the migration and three users, admin, user and other,
+// and makes the services use it instead of PostgreSQL.
+func setupDatabase(t *testing.T) *gorm.DB {
+	return testdb.Open(t,
+		`INSERT INTO users (id, username, password, email, firstname, is_enabled, user_type) VALUES
+			(1, 'admin', '!', 'admin@mygmail.com', 'Admin', true, 'USER_ACCOUNT'),
+			(2, 'user', '!', 'user@mygmail.com', 'User', true, 'USER_ACCOUNT'),
+			(3, 'other', '!', 'other@mygmail.com', 'Other', true, 'USER_ACCOUNT')`,
+	)
+}
@@ -0,0 +1,122 @@
+package test_soft_delete
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+)
+
+func TestGetUsers_ModifiedSince(t *testing.T) {
+	db := setupDatabase(t)
+	repo := repository.NewUserRepository()
+	s := service.NewUserService(repo)
+
+	// admin was last updated before the cutoff, other after it
+	cutoff := time.Now().UTC().Add(-time.Hour)
+	require.NoError(t, db.Exec("UPDATE users SET updated_at = ? WHERE id = 1", cutoff.Add(-time.Minute)).Error)
+	require.NoError(t, db.Exec("UPDATE users SET updated_at = ? WHERE id = 2", cutoff.Add(-time.Minute)).Error)
+	require.NoError(t, db.Exec("UPDATE users SET updated_at = ? WHERE id = 3", cutoff.Add(time.Minute)).Error)
+
+	// user is deleted after the cutoff, the deletion updates the row
+	user, err := repo.GetUserByID(db, 2)
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteUser(db, user, 1))
+
+	users, total, err := s.GetUsers(context.Background(), entity.UserFilter{ModifiedSince: &cutoff}, false, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	// Only the rows changed after the cutoff are returned, in the order they changed
+	require.Len(t, users, 2)
+	assert.Equal(t, int64(3), users[0].ID)
+	assert.Equal(t, int64(2), users[1].ID)
+
+	// The deletion is represented with its flag and timestamp
+	deleted := users[1].ToResponse()
+	require.NotNil(t, deleted.IsDeleted)
+	assert.True(t, *deleted.IsDeleted)
+	assert.NotNil(t, deleted.DeletedAt)
+	assert.Nil(t, users[0].ToResponse().DeletedAt)
+}
+
+func TestGetUsers_ModifiedSinceOrderAndPages(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	// other and user changed at the same time, the ID breaks the tie
+	cutoff := time.Now().UTC().Add(-time.Hour)
+	require.NoError(t, db.Exec("UPDATE users SET updated_at = ? WHERE id = 1", cutoff.Add(2*time.Minute)).Error)
+	require.NoError(t, db.Exec("UPDATE users SET updated_at = ? WHERE id IN (2, 3)", cutoff.Add(time.Minute)).Error)
+
+	var ids []int64
+	for page := 1; page <= 3; page++ {
+		users, total, err := s.GetUsers(context.Background(), entity.UserFilter{ModifiedSince: &cutoff}, false, page, 1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		require.Len(t, users, 1)
+		ids = append(ids, users[0].ID)
+	}
+	assert.Equal(t, []int64{2, 3, 1}, ids)
+
+	// A cutoff equal to the last change returns nothing, the comparison is strict
+	last := cutoff.Add(2 * time.Minute)
+	users, total, err := s.GetUsers(context.Background(), entity.UserFilter{ModifiedSince: &last}, false, 1, 10)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+	assert.Equal(t, int64(0), total)
+}
+
+func TestGetUsers_WithoutModifiedSince(t *testing.T) {
+	db := setupDatabase(t)
+	repo := repository.NewUserRepository()
+	s := service.NewUserService(repo)
+
+	user, err := repo.GetUserByID(db, 2)
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteUser(db, user, 1))
+
+	// The plain listing excludes the soft-deleted users
+	users, total, err := s.GetUsers(context.Background(), entity.UserFilter{}, false, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []int64{1, 3}, []int64{users[0].ID, users[1].ID})
+	for _, u := range users {
+		assert.Nil(t, u.ToResponse().DeletedAt)
+	}
+}
+
+func TestGetUsers_UpdatedSince(t *testing.T) {
+	db := setupDatabase(t)
+	repo := repository.NewUserRepository()
+	s := service.NewUserService(repo)
+
+	// Every user was last updated before the cutoff
+	cutoff := time.Now().UTC().Add(-time.Hour)
+	require.NoError(t, db.Exec("UPDATE users SET updated_at = ?", cutoff.Add(-time.Minute)).Error)
+	require.NoError(t, db.Exec("UPDATE users SET updated_at = ? WHERE id = 1", cutoff).Error)
+
+	// The cutoff itself is included
+	users, total, err := s.GetUsers(context.Background(), entity.UserFilter{UpdatedSince: &cutoff}, false, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, users, 1)
+	assert.Equal(t, int64(1), users[0].ID)
+
+	// A deletion is a change like any other
+	user, err := repo.GetUserByID(db, 2)
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteUser(db, user, 1))
+
+	users, total, err = s.GetUsers(context.Background(), entity.UserFilter{UpdatedSince: &cutoff}, false, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, users, 2)
+	assert.Equal(t, []int64{1, 2}, []int64{users[0].ID, users[1].ID})
+}
@@ -0,0 +1,106 @@
+package test_soft_delete
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+)
+
+// deleteUserAt soft-deletes the user by the admin at the given time, with its sessions, history, roles and memberships.
+func deleteUserAt(t *testing.T, db *gorm.DB, id int64, deletedAt time.Time) {
+	statements := []string{
+		`UPDATE users SET is_deleted = true, deleted_by = 1, deleted_at = ? WHERE id = ?`,
+		`INSERT INTO refresh_token (token, session_id, user_id, expiry_date, created_at)
+			VALUES ('token-' || ?, ?, ?, datetime('now', '+1 hour'), datetime('now'))`,
+		`INSERT INTO password_history (user_id, password_hash, created_at) VALUES (?, 'hash', datetime('now'))`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES (?, 1)`,
+		`INSERT INTO user_tenants (user_id, tenant_id) VALUES (?, 2)`,
+		`INSERT INTO login_attempts (user_id, username, success, attempted_at) VALUES (?, 'user', true, datetime('now'))`,
+	}
+	require.NoError(t, db.Exec(statements[0], deletedAt, id).Error)
+	require.NoError(t, db.Exec(statements[1], id, id, id).Error)
+	for _, stmt := range statements[2:] {
+		require.NoError(t, db.Exec(stmt, id).Error)
+	}
+}
+
+// countRows returns the number of rows of the table referencing the user.
+func countRows(t *testing.T, db *gorm.DB, table string, id int64) int64 {
+	var count int64
+	require.NoError(t, db.Table(table).Where("user_id = ?", id).Count(&count).Error)
+	return count
+}
+
+func TestGetDeletedUsers(t *testing.T) {
+	db := setupDatabase(t)
+	deleteUserAt(t, db, 3, time.Now().Add(-48*time.Hour))
+	deleteUserAt(t, db, 2, time.Now().Add(-72*time.Hour))
+	s := service.NewUserService(repository.NewUserRepository())
+
+	// The oldest deletions come first, with who deleted them and when
+	users, total, err := s.GetDeletedUsers(adminContext(), 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, users, 2)
+	assert.Equal(t, []int64{2, 3}, []int64{users[0].ID, users[1].ID})
+	for _, user := range users {
+		response := user.ToResponse()
+		assert.True(t, *response.IsDeleted)
+		assert.Equal(t, int64(1), *response.DeletedBy)
+		assert.NotNil(t, response.DeletedAt)
+	}
+
+	users, total, err = s.GetDeletedUsers(adminContext(), 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, users, 1)
+	assert.Equal(t, int64(3), users[0].ID)
+}
+
+func TestPurgeUser_Success(t *testing.T) {
+	t.Setenv("USER_PURGE_RETENTION_DAYS", "30")
+	db := setupDatabase(t)
+	deleteUserAt(t, db, 2, time.Now().Add(-31*24*time.Hour))
+	deleteUserAt(t, db, 3, time.Now().Add(-31*24*time.Hour))
+	s := service.NewUserService(repository.NewUserRepository())
+
+	require.NoError(t, s.PurgeUser(adminContext(), 2))
+
+	// The row is gone for good, along with every record referencing it
+	var count int64
+	require.NoError(t, db.Unscoped().Model(&entity.User{}).Where("id = ?", 2).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+	for _, table := range []string{"refresh_token", "password_history", "user_roles", "user_tenants", "login_attempts"} {
+		assert.Equal(t, int64(0), countRows(t, db, table, 2), table)
+		assert.Equal(t, int64(1), countRows(t, db, table, 3), table)
+	}
+
+	// Purging it again reports it as not found
+	assert.True(t, errors.Is(s.PurgeUser(adminContext(), 2), gorm.ErrRecordNotFound))
+}
+
+func TestPurgeUser_Conflicts(t *testing.T) {
+	t.Setenv("USER_PURGE_RETENTION_DAYS", "30")
+	db := setupDatabase(t)
+	deleteUserAt(t, db, 2, time.Now().Add(-29*24*time.Hour))
+	s := service.NewUserService(repository.NewUserRepository())
+
+	// A user deleted within the retention period is kept
+	assert.True(t, errors.Is(s.PurgeUser(adminContext(), 2), service.ErrUserDeletedRecently))
+	assert.Equal(t, int64(1), countRows(t, db, "refresh_token", 2))
+
+	// A user not deleted cannot be purged
+	assert.True(t, errors.Is(s.PurgeUser(adminContext(), 3), service.ErrUserNotDeleted))
+
+	// Without a retention, the deleted users can be purged at once
+	t.Setenv("USER_PURGE_RETENTION_DAYS", "0")
+	require.NoError(t, s.PurgeUser(adminContext(), 2))
+}
@@ -0,0 +1,192 @@
+package test_soft_delete
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+)
+
+// userRead runs a read of the user repository and reports whether it returned the deleted user 2.
+type userRead func(db *gorm.DB, opts ...repository.ReadOption) (bool, error)
+
+// found reports whether the lookup found a user.
+func found(_ entity.User, err error) (bool, error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// containsDeleted reports whether the users contain the deleted user 2.
+func containsDeleted(users []entity.User, err error) (bool, error) {
+	return slices.ContainsFunc(users, func(user entity.User) bool { return user.ID == 2 }), err
+}
+
+// sameInbox gives the admin and the deleted user 2 two addresses of the same inbox, so they are duplicates.
+func sameInbox(db *gorm.DB) error {
+	return db.Exec(`UPDATE users SET email = CASE id WHEN 1 THEN 'j.doe@gmail.com' ELSE 'jdoe+old@gmail.com' END`).Error
+}
+
+// TestUserRepository_ReadConformance checks that every read of the user repository leaves out the soft-deleted users
+// by default, and that only the reads accepting WithDeleted return them.
+func TestUserRepository_ReadConformance(t *testing.T) {
+	repo := repository.NewUserRepository()
+	since := time.Now().Add(-time.Hour)
+
+	// The reads accepting the options, the deleted user 2 must only be returned with WithDeleted
+	reads := map[string]userRead{
+		"GetUserByID": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			return found(repo.GetUserByID(db, 2, opts...))
+		},
+		"GetUserByIDForUpdate": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			return found(repo.GetUserByIDForUpdate(db, 2, opts...))
+		},
+		"GetUsersByIDs": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			users, _, err := repo.GetUsersByIDs(db, []int64{1, 2}, true, opts...)
+			return containsDeleted(users, err)
+		},
+		"GetUsersByMetadata": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			return containsDeleted(repo.GetUsersByMetadata(db, "crmId", "C-1", opts...))
+		},
+		"GetUsers": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			return containsDeleted(repo.GetUsers(db, entity.UserFilter{}, 1, 10, opts...))
+		},
+		"GetUsers modified since": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			return containsDeleted(repo.GetUsers(db, entity.UserFilter{ModifiedSince: &since}, 1, 10, opts...))
+		},
+		"GetUsers updated since": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			return containsDeleted(repo.GetUsers(db, entity.UserFilter{UpdatedSince: &since}, 1, 10, opts...))
+		},
+		"GetUsersAfter": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			return containsDeleted(repo.GetUsersAfter(db, 1, 10, opts...))
+		},
+		"GetUsersInBatches": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			var users []entity.User
+			err := repo.GetUsersInBatches(db, nil, 1, func(batch []entity.User) error {
+				users = append(users, batch...)
+				return nil
+			}, opts...)
+			return containsDeleted(users, err)
+		},
+		"CountUsers": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			total, err := repo.CountUsers(db, entity.UserFilter{}, opts...)
+			return total == 2, err
+		},
+		"CountEnabledUsersWithRole": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			total, err := repo.CountEnabledUsersWithRole(db, "role_admin", opts...)
+			return total == 2, err
+		},
+		"CountUsersWithRoles": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			total, err := repo.CountUsersWithRoles(db, []string{"role_admin"}, opts...)
+			return total == 2, err
+		},
+		"CountUsersByRole": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			counts, err := repo.CountUsersByRole(db, opts...)
+			return counts["ROLE_ADMIN"] == 2, err
+		},
+		"GetUsersWithRoleForUpdate": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			return containsDeleted(repo.GetUsersWithRoleForUpdate(db, "role_admin", 0, 10, opts...))
+		},
+		"GetDuplicateUserClusters": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			if err := sameInbox(db); err != nil {
+				return false, err
+			}
+			clusters, err := repo.GetDuplicateUserClusters(db, 0.3, 1, 10, opts...)
+			return len(clusters) == 1, err
+		},
+		"CountDuplicateUserClusters": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			if err := sameInbox(db); err != nil {
+				return false, err
+			}
+			total, err := repo.CountDuplicateUserClusters(db, 0.3, opts...)
+			return total == 1, err
+		},
+		"GetDuplicateClusterUsers": func(db *gorm.DB, opts ...repository.ReadOption) (bool, error) {
+			if err := sameInbox(db); err != nil {
+				return false, err
+			}
+			cluster := entity.DuplicateUserCluster{TenantID: 1, Reason: entity.DuplicateReasonEmail, Key: "jdoe@gmail.com"}
+			return containsDeleted(repo.GetDuplicateClusterUsers(db, cluster, 0.3, opts...))
+		},
+	}
+
+	// The lookups of the login, which never return a deleted user
+	loginReads := map[string]userRead{
+		"GetUserByUsername": func(db *gorm.DB, _ ...repository.ReadOption) (bool, error) {
+			return found(repo.GetUserByUsername(db, "user"))
+		},
+		"GetUserByEmail": func(db *gorm.DB, _ ...repository.ReadOption) (bool, error) {
+			return found(repo.GetUserByEmail(db, "user@mygmail.com"))
+		},
+	}
+
+	// The listings of the deleted users, which only return them, and the summary counting them apart
+	deletedReads := []string{"GetDeletedUsers", "CountDeletedUsers", "GetUserSummary"}
+
+	// Every read of the interface must be covered, so a new one cannot forget the deleted users
+	readOptionType := reflect.TypeOf(repository.ReadOption(nil))
+	repoType := reflect.TypeOf((*repository.UserRepository)(nil)).Elem()
+	for i := 0; i < repoType.NumMethod(); i++ {
+		method := repoType.Method(i)
+		if !strings.HasPrefix(method.Name, "Get") && !strings.HasPrefix(method.Name, "Count") {
+			continue
+		}
+
+		acceptsOptions := method.Type.IsVariadic() && method.Type.In(method.Type.NumIn()-1).Elem() == readOptionType
+		_, isRead := reads[method.Name]
+		_, isLoginRead := loginReads[method.Name]
+		switch {
+		case isRead:
+			assert.True(t, acceptsOptions, "%s must accept the read options", method.Name)
+		case isLoginRead:
+			assert.False(t, acceptsOptions, "%s is used by the login and must not accept WithDeleted", method.Name)
+		default:
+			assert.Contains(t, deletedReads, method.Name, "%s is not covered by the conformance test", method.Name)
+		}
+	}
+
+	for name, read := range reads {
+		t.Run(name, func(t *testing.T) {
+			db := setupConformanceDatabase(t)
+
+			visible, err := read(db)
+			require.NoError(t, err)
+			assert.False(t, visible, "the deleted user must not be returned by default")
+
+			visible, err = read(db, repository.WithDeleted())
+			require.NoError(t, err)
+			assert.True(t, visible, "the deleted user must be returned with WithDeleted")
+		})
+	}
+
+	for name, read := range loginReads {
+		t.Run(name, func(t *testing.T) {
+			db := setupConformanceDatabase(t)
+
+			visible, err := read(db)
+			require.NoError(t, err)
+			assert.False(t, visible, "the deleted user must never be returned to the login")
+		})
+	}
+}
+
+// setupConformanceDatabase prepares the active admin and the deleted user 2 so that they both match every read.
+func setupConformanceDatabase(t *testing.T) *gorm.DB {
+	db := setupDatabase(t)
+	require.NoError(t, db.Exec(`DELETE FROM users WHERE id = 3`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO roles (id, name) VALUES (1, 'ROLE_ADMIN')`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO user_roles (user_id, role_id) VALUES (1, 1), (2, 1)`).Error)
+	require.NoError(t, db.Exec(`UPDATE users SET updated_at = datetime('now'), email = username || '@mygmail.com', metadata = '{"crmId":"C-1"}'`).Error)
+	require.NoError(t, db.Exec(`UPDATE users SET is_deleted = true, deleted_by = 1, deleted_at = datetime('now') WHERE id = 2`).Error)
+	return db
+}
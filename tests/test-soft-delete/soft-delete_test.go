@@ -0,0 +1,62 @@
+package test_soft_delete
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+)
+
+func TestDeleteUser_ExcludedFromQueries(t *testing.T) {
+	db := setupDatabase(t)
+	repo := repository.NewUserRepository()
+
+	user, err := repo.GetUserByID(db, 2)
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteUser(db, user, 1))
+
+	// The soft-deleted user is excluded from the lookups
+	_, err = repo.GetUserByID(db, 2)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	_, err = repo.GetUserByUsername(db, "user")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	users, missing, err := repo.GetUsersByIDs(db, []int64{1, 2}, false)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, int64(1), users[0].ID)
+	assert.Equal(t, []int64{2}, missing)
+
+	var count int64
+	require.NoError(t, db.Model(&entity.User{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestDeleteUser_FoundWhenUnscoped(t *testing.T) {
+	db := setupDatabase(t)
+	repo := repository.NewUserRepository()
+
+	user, err := repo.GetUserByID(db, 2)
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteUser(db, user, 1))
+
+	// The row is kept with its deletion timestamp, the flag and the actor
+	var deleted entity.User
+	require.NoError(t, db.Unscoped().First(&deleted, "id = ?", 2).Error)
+	assert.True(t, deleted.DeletedAt.Valid)
+	require.NotNil(t, deleted.IsDeleted)
+	assert.True(t, *deleted.IsDeleted)
+	require.NotNil(t, deleted.DeletedBy)
+	assert.Equal(t, int64(1), *deleted.DeletedBy)
+
+	// The other users are untouched
+	var active entity.User
+	require.NoError(t, db.Unscoped().First(&active, "id = ?", 1).Error)
+	assert.False(t, active.DeletedAt.Valid)
+	assert.False(t, *active.IsDeleted)
+}
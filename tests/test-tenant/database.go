@@ -0,0 +1,23 @@
+package test_tenant
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with two tenants and their users,
+// and makes the services use it instead of PostgreSQL.
+// Both tenants have a user named alice, and the alice of the default tenant is also a member of the second tenant.
+func setupDatabase(t *testing.T) *gorm.DB {
+	return testdb.Open(t,
+		`INSERT INTO tenants (id, name) VALUES (1, 'Default'), (2, 'Acme')`,
+		`INSERT INTO users (id, tenant_id, username, password, email, firstname, user_type) VALUES
+			(1, 1, 'alice', '!', 'alice@example.com', 'Alice', 'USER_ACCOUNT'),
+			(2, 2, 'alice', '!', 'alice@example.com', 'Alice', 'USER_ACCOUNT'),
+			(3, 2, 'bob', '!', 'bob@example.com', 'Bob', 'USER_ACCOUNT')`,
+		`INSERT INTO user_tenants (user_id, tenant_id) VALUES (1, 2)`,
+	)
+}
@@ -0,0 +1,77 @@
+package test_tenant
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/tenant"
+)
+
+// setupRouter returns a router that authenticates every request as the given user of the default tenant,
+// and answers with the tenant selected for the request.
+func setupRouter(roles ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	// Only the user 1 is a member of the tenant 2
+	isMember := func(ctx context.Context, userID int64, tenantID int64) (bool, error) {
+		return userID == 1 && tenantID == 2, nil
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		ctx := metacontext.InjectUserInformationMeta(c.Request.Context(), metacontext.UserInformationMeta{UserID: 1, Username: "alice", Roles: roles})
+		ctx = metacontext.InjectTenantID(ctx, 1)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}, tenant.TenantResolution(isMember))
+	r.GET("/tenant", func(c *gin.Context) {
+		tenantID, _ := metacontext.ExtractTenantID(c.Request.Context())
+		c.String(http.StatusOK, strconv.FormatInt(tenantID, 10))
+	})
+
+	return r
+}
+
+func TestTenantResolution(t *testing.T) {
+	tests := []struct {
+		name     string
+		roles    []string
+		target   string
+		header   string
+		code     int
+		tenantID string
+	}{
+		{"own tenant", []string{"ROLE_ADMIN"}, "/tenant", "", http.StatusOK, "1"},
+		{"member of the tenant", []string{"ROLE_ADMIN"}, "/tenant", "2", http.StatusOK, "2"},
+		{"not a member of the tenant", []string{"ROLE_ADMIN"}, "/tenant", "3", http.StatusNotFound, ""},
+		{"invalid tenant", []string{"ROLE_ADMIN"}, "/tenant", "abc", http.StatusBadRequest, ""},
+		{"query ignored for an admin", []string{"ROLE_ADMIN"}, "/tenant?tenantId=3", "", http.StatusOK, "1"},
+		{"super admin selects any tenant", []string{"ROLE_SUPER_ADMIN"}, "/tenant?tenantId=3", "", http.StatusOK, "3"},
+		{"super admin with an invalid tenant", []string{"ROLE_SUPER_ADMIN"}, "/tenant?tenantId=0", "", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter(tt.roles...)
+
+			req, _ := http.NewRequest("GET", tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(tenant.TenantHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusOK {
+				assert.Equal(t, tt.tenantID, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package test_tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+func TestUserRepository_ScopedByTenant(t *testing.T) {
+	db := setupDatabase(t)
+	repo := repository.NewUserRepository()
+
+	// The same username resolves to the user of the tenant
+	user, err := repo.GetUserByUsername(db.WithContext(metacontext.InjectTenantID(context.Background(), 1)), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), user.ID)
+
+	user, err = repo.GetUserByUsername(db.WithContext(metacontext.InjectTenantID(context.Background(), 2)), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), user.ID)
+
+	// A user of another tenant is not found
+	_, err = repo.GetUserByID(db.WithContext(metacontext.InjectTenantID(context.Background(), 1)), 3)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	users, missing, err := repo.GetUsersByIDs(db.WithContext(metacontext.InjectTenantID(context.Background(), 1)), []int64{1, 2, 3}, false)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, int64(1), users[0].ID)
+	assert.Equal(t, []int64{2, 3}, missing)
+
+	// Without a tenant, e.g. in the background jobs, the lookup is not restricted
+	user, err = repo.GetUserByID(db, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", user.Username)
+}
+
+func TestTenantService_IsMember(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewTenantService(repository.NewTenantRepository())
+
+	tests := []struct {
+		name     string
+		userID   int64
+		tenantID int64
+		expected bool
+	}{
+		{"own tenant", 1, 1, true},
+		{"membership", 1, 2, true},
+		{"other tenant", 3, 1, false},
+		{"unknown tenant", 1, 99, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			member, err := s.IsMember(context.Background(), tt.userID, tt.tenantID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, member)
+		})
+	}
+}
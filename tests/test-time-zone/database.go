@@ -0,0 +1,25 @@
+package test_time_zone
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// setupDatabase opens an SQLite database with the GORM configuration and the UTC callbacks of the application,
+// and makes the services use it instead of PostgreSQL.
+// SQLite stores the times as text, so a time written with another offset would not compare as the same instant.
+func setupDatabase(t *testing.T) *gorm.DB {
+	database.DBSchema = ""
+	database.DBLog = "SILENT"
+
+	db := testdb.New(t, testdb.Options{Config: database.NewGormConfig()})
+	if err := database.RegisterUTCCallbacks(db); err != nil {
+		t.Fatalf("failed to register the UTC callbacks: %v", err)
+	}
+
+	return testdb.Use(t, db)
+}
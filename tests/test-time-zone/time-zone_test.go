@@ -0,0 +1,201 @@
+package test_time_zone
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/customtype"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/timezone"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// dstInstants are the instants on both sides of the daylight saving time changes of New York in 2025,
+// the clocks go forward at 02:00 on March 9 and back at 02:00 on November 2.
+var dstInstants = []struct {
+	utc     string
+	newYork string
+}{
+	{"2025-03-09T06:30:00Z", "2025-03-09T01:30:00-05:00"},
+	{"2025-03-09T07:30:00Z", "2025-03-09T03:30:00-04:00"},
+	{"2025-11-02T05:30:00Z", "2025-11-02T01:30:00-04:00"},
+	{"2025-11-02T06:30:00Z", "2025-11-02T01:30:00-05:00"},
+}
+
+// newRouter serves the audit log behind the time zone middleware.
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(timezone.TimeZone())
+	router.GET("/api/v1/audit", handler.NewAuditLogHandler(service.NewAuditLogService(repository.NewAuditLogRepository())).GetAuditLogs)
+	router.POST("/api/v1/audit", func(c *gin.Context) { httputil.Success(c, "Accepted", nil) })
+	return router
+}
+
+func TestTimeZone_StoredInUTC(t *testing.T) {
+	db := setupDatabase(t)
+	repo := repository.NewAuditLogRepository()
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	for _, instant := range dstInstants {
+		local, err := time.Parse(time.RFC3339, instant.newYork)
+		require.NoError(t, err)
+
+		// The time is written with the offset of New York, and read back as the same instant in UTC
+		created, err := repo.CreateAuditLog(db, entity.AuditLog{Actor: "admin", Action: "update", EntityType: "user", CreatedAt: local.In(newYork)})
+		require.NoError(t, err)
+
+		var stored entity.AuditLog
+		require.NoError(t, db.First(&stored, created.ID).Error)
+		assert.Equal(t, instant.utc, stored.CreatedAt.Format(time.RFC3339))
+		assert.Equal(t, time.UTC, stored.CreatedAt.Location())
+	}
+
+	// The times stamped by GORM are in UTC as well
+	stamped, err := repo.CreateAuditLog(db, entity.AuditLog{Actor: "admin", Action: "create", EntityType: "user"})
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, stamped.CreatedAt.Location())
+
+	// The range filters compare the instants, whatever the offset they were given with
+	from, _ := time.Parse(time.RFC3339, "2025-03-09T03:00:00-04:00")
+	to, _ := time.Parse(time.RFC3339, "2025-11-02T01:45:00-04:00")
+	_, total, err := service.NewAuditLogService(repo).GetAuditLogs(context.Background(), entity.AuditLogFilter{From: &from, To: &to, Page: 1, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+}
+
+func TestTimeZone_RoundTripAcrossDST(t *testing.T) {
+	db := setupDatabase(t)
+	for _, instant := range dstInstants {
+		createdAt, _ := time.Parse(time.RFC3339, instant.utc)
+		require.NoError(t, db.Create(&entity.AuditLog{Actor: "admin", Action: "update", EntityType: "user", CreatedAt: createdAt}).Error)
+	}
+	router := newRouter()
+
+	tests := []struct {
+		name  string
+		tz    string
+		times func(utc, newYork string) string
+	}{
+		{"UTC by default", "", func(utc, _ string) string { return utc }},
+		{"New York", "America/New_York", func(_, newYork string) string { return newYork }},
+		{"Jakarta", "Asia/Jakarta", func(utc, _ string) string {
+			t, _ := time.Parse(time.RFC3339, utc)
+			return t.Add(7*time.Hour).Format("2006-01-02T15:04:05") + "+07:00"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/api/v1/audit?limit=10"
+			if tt.tz != "" {
+				path += "&tz=" + tt.tz
+			}
+			req, _ := http.NewRequest("GET", path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response struct {
+				Data []struct {
+					CreatedAt string `json:"createdAt"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Len(t, response.Data, len(dstInstants))
+
+			// The entries are the most recent first
+			for i, instant := range dstInstants {
+				displayed := response.Data[len(dstInstants)-1-i].CreatedAt
+				assert.Equal(t, tt.times(instant.utc, instant.newYork), displayed)
+
+				// The displayed time is the same instant as the stored one
+				parsed, err := time.Parse(time.RFC3339, displayed)
+				require.NoError(t, err)
+				stored, _ := time.Parse(time.RFC3339, instant.utc)
+				assert.True(t, parsed.Equal(stored))
+			}
+		})
+	}
+}
+
+func TestTimeZone_InvalidTimeZone(t *testing.T) {
+	setupDatabase(t)
+	router := newRouter()
+
+	tests := []struct {
+		name   string
+		method string
+		tz     string
+		status int
+	}{
+		{"unknown zone", "GET", "Mars/Olympus_Mons", http.StatusBadRequest},
+		{"server zone", "GET", "Local", http.StatusBadRequest},
+		{"offset", "GET", "+07:00", http.StatusBadRequest},
+		{"known zone", "GET", "Europe/Paris", http.StatusOK},
+		{"ignored on writes", "POST", "Mars/Olympus_Mons", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, "/api/v1/audit?tz="+tt.tz, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestTimeZone_ResponseData(t *testing.T) {
+	at := time.Date(2025, 1, 1, 2, 0, 0, 0, time.UTC)
+	birthDate := customtype.Date{Time: time.Date(1990, 6, 15, 0, 0, 0, 0, time.UTC)}
+	data := gin.H{
+		"at":       at,
+		"pointer":  &at,
+		"nested":   []gin.H{{"at": at}},
+		"birthday": birthDate,
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(timezone.TimeZone())
+	router.GET("/data", func(c *gin.Context) { httputil.Success(c, "OK", data) })
+
+	req, _ := http.NewRequest("GET", "/data?tz=America/Los_Angeles", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			At       string              `json:"at"`
+			Pointer  string              `json:"pointer"`
+			Nested   []map[string]string `json:"nested"`
+			Birthday string              `json:"birthday"`
+		} `json:"data"`
+		Timestamp string `json:"timestamp"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2024-12-31T18:00:00-08:00", response.Data.At)
+	assert.Equal(t, "2024-12-31T18:00:00-08:00", response.Data.Pointer)
+	assert.Equal(t, "2024-12-31T18:00:00-08:00", response.Data.Nested[0]["at"])
+
+	// A calendar date has no time zone, it is not shifted to the previous day
+	assert.Equal(t, "1990-06-15", response.Data.Birthday)
+
+	// The data of the handler is left unchanged
+	assert.Equal(t, time.UTC, data["at"].(time.Time).Location())
+	assert.Equal(t, time.UTC, data["nested"].([]gin.H)[0]["at"].(time.Time).Location())
+}
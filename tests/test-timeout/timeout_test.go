@@ -0,0 +1,210 @@
+package test_timeout
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/timeout"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes, used to capture the log output.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// setupRouter registers a slow and a fast route behind the timeout middleware.
+// The slow route keeps writing its response after the deadline, like a handler waiting on a slow query would.
+func setupRouter(cfg timeout.TimeoutConfig, cancelled chan<- bool) *gin.Engine {
+	router := gin.New()
+	router.Use(timeout.TimeoutWithConfig(cfg))
+	router.GET("/api/v1/slow", func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			cancelled <- true
+			// The deadline and the timeout response fire together, write once the response is sent
+			time.Sleep(20 * time.Millisecond)
+		case <-time.After(time.Second):
+			cancelled <- false
+		}
+		c.Header("X-Handler", "slow")
+		httputil.Success(c, "Too late", nil)
+	})
+	router.GET("/api/v1/fast", func(c *gin.Context) {
+		c.Header("X-Handler", "fast")
+		httputil.Success(c, "All good", nil)
+	})
+	return router
+}
+
+func TestTimeout_SlowHandler(t *testing.T) {
+	// Capture the gin debug output, which warns when the headers are written twice
+	gin.SetMode(gin.DebugMode)
+	defer gin.SetMode(gin.TestMode)
+	ginOutput := &syncBuffer{}
+	defaultWriter := gin.DefaultWriter
+	gin.DefaultWriter = ginOutput
+	defer func() { gin.DefaultWriter = defaultWriter }()
+
+	cancelled := make(chan bool, 1)
+	router := setupRouter(timeout.TimeoutConfig{Default: 50 * time.Millisecond}, cancelled)
+
+	// Use a real server to capture the "superfluous response.WriteHeader call" messages
+	serverOutput := &syncBuffer{}
+	server := httptest.NewUnstartedServer(router)
+	server.Config.ErrorLog = log.New(serverOutput, "", 0)
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/slow")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	var response httputil.HttpResponse
+	assert.NoError(t, json.Unmarshal(body, &response))
+
+	assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	assert.Equal(t, http.StatusGatewayTimeout, response.Status)
+	assert.Equal(t, "/api/v1/slow", response.Path)
+	assert.Empty(t, resp.Header.Get("X-Handler"))
+
+	// The downstream context must be cancelled when the deadline fires
+	assert.True(t, <-cancelled)
+
+	// Give the handler time to finish writing after the timeout response
+	time.Sleep(50 * time.Millisecond)
+	assert.NotContains(t, serverOutput.String(), "superfluous")
+	assert.NotContains(t, ginOutput.String(), "Headers were already written")
+}
+
+func TestTimeout_FastHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := setupRouter(timeout.TimeoutConfig{Default: time.Second}, make(chan bool, 1))
+
+	req, _ := http.NewRequest("GET", "/api/v1/fast", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "fast", w.Header().Get("X-Handler"))
+}
+
+func TestTimeout_RouteOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := timeout.TimeoutConfig{
+		Default:   50 * time.Millisecond,
+		Overrides: map[string]time.Duration{"/api/v1/slow": 5 * time.Second},
+	}
+	cancelled := make(chan bool, 1)
+	router := setupRouter(cfg, cancelled)
+
+	req, _ := http.NewRequest("GET", "/api/v1/slow", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// The handler completes before the overridden deadline
+	assert.False(t, <-cancelled)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "slow", w.Header().Get("X-Handler"))
+}
+
+func TestTimeout_StreamCrossingDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(timeout.TimeoutWithConfig(timeout.TimeoutConfig{Default: 50 * time.Millisecond}))
+	router.GET("/api/v1/stream", func(c *gin.Context) {
+		stream := httputil.StreamList(c, "Items retrieved")
+		assert.NoError(t, stream.Write([]string{"first"}))
+
+		// Keep flushing while the deadline fires on the timer goroutine
+		deadline := time.After(100 * time.Millisecond)
+		for done := false; !done; {
+			select {
+			case <-deadline:
+				done = true
+			default:
+				c.Writer.Flush()
+			}
+		}
+
+		assert.NoError(t, stream.Write([]string{"second"}))
+		assert.NoError(t, stream.End(&httputil.Cursor{Partial: true}))
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/stream")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	// The stream started before the deadline, so it is completed rather than replaced by the timeout response
+	body, _ := io.ReadAll(resp.Body)
+	var response struct {
+		Status int      `json:"status"`
+		Data   []string `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &response))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"first", "second"}, response.Data)
+}
+
+func TestTimeout_FlushBeforeWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(timeout.TimeoutWithConfig(timeout.TimeoutConfig{Default: 50 * time.Millisecond}))
+	router.GET("/api/v1/stream", func(c *gin.Context) {
+		// A flush with nothing written must not commit a 200 in place of the timeout response
+		c.Writer.Flush()
+		<-c.Request.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		c.Writer.Flush()
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/stream")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+}
+
+func TestTimeoutConfig_For(t *testing.T) {
+	cfg := timeout.TimeoutConfig{
+		Default: 30 * time.Second,
+		Overrides: map[string]time.Duration{
+			"/api/v1/consumers":        time.Minute,
+			"/api/v1/consumers/export": 5 * time.Minute,
+		},
+	}
+
+	assert.Equal(t, 30*time.Second, cfg.For("/auth/login"))
+	assert.Equal(t, time.Minute, cfg.For("/api/v1/consumers/:id"))
+	assert.Equal(t, 5*time.Minute, cfg.For("/api/v1/consumers/export"))
+}
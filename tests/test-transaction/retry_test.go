@@ -0,0 +1,131 @@
+package test_transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// contendedRepository is a user repository whose writes are aborted by PostgreSQL the first times they run,
+// after the row is written, like a transaction losing a conflict with a concurrent one.
+type contendedRepository struct {
+	repository.UserRepository
+	code     string
+	failures int
+	calls    int
+}
+
+func (r *contendedRepository) abort() error {
+	r.calls++
+	if r.calls <= r.failures {
+		return fmt.Errorf("failed to write user: %w", &pgconn.PgError{Code: r.code, Message: "could not serialize access"})
+	}
+	return nil
+}
+
+func (r *contendedRepository) CreateUser(tx *gorm.DB, user entity.User) (entity.User, error) {
+	created, err := r.UserRepository.CreateUser(tx, user)
+	if err != nil {
+		return entity.User{}, err
+	}
+	return created, r.abort()
+}
+
+func (r *contendedRepository) UpdateUser(tx *gorm.DB, user entity.User) (entity.User, error) {
+	updated, err := r.UserRepository.UpdateUser(tx, user)
+	if err != nil {
+		return entity.User{}, err
+	}
+	return updated, r.abort()
+}
+
+// adminContext returns a context carrying the admin performing the requests.
+func adminContext() context.Context {
+	return metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{UserID: 1, Username: "admin"})
+}
+
+func newUserRequest() entity.UserCreateRequest {
+	return entity.UserCreateRequest{
+		Username:  "contended",
+		Password:  "P@ssw0rd123",
+		Email:     "contended@mygmail.com",
+		Firstname: "Contended",
+		UserType:  "USER_ACCOUNT",
+	}
+}
+
+func TestCreateUser_RetriedOnSerializationFailure(t *testing.T) {
+	db := setupDatabase(t)
+	repo := &contendedRepository{UserRepository: repository.NewUserRepository(), code: "40001", failures: 1}
+	s := service.NewUserService(repo)
+
+	created, err := s.CreateUser(adminContext(), newUserRequest())
+	require.NoError(t, err)
+	assert.Equal(t, 2, repo.calls)
+
+	// The aborted attempt left nothing behind
+	assert.Equal(t, int64(1), countUsers(t, db))
+	var history int64
+	require.NoError(t, db.Table("password_history").Where("user_id = ?", created.ID).Count(&history).Error)
+	assert.Equal(t, int64(1), history)
+}
+
+func TestResetUserPassword_RetriedOnDeadlock(t *testing.T) {
+	setupDatabase(t)
+	created, err := service.NewUserService(repository.NewUserRepository()).CreateUser(adminContext(), newUserRequest())
+	require.NoError(t, err)
+
+	repo := &contendedRepository{UserRepository: repository.NewUserRepository(), code: "40P01", failures: 1}
+	_, err = service.NewUserService(repo).ResetUserPassword(adminContext(), created.ID, "N3w-P@ssw0rd")
+	require.NoError(t, err)
+	assert.Equal(t, 2, repo.calls)
+}
+
+func TestCreateUser_ContentionExhausted(t *testing.T) {
+	t.Setenv("DB_TX_MAX_ATTEMPTS", "2")
+	db := setupDatabase(t)
+	repo := &contendedRepository{UserRepository: repository.NewUserRepository(), code: "40001", failures: 10}
+	s := service.NewUserService(repo)
+
+	_, err := s.CreateUser(adminContext(), newUserRequest())
+	assert.True(t, errors.Is(err, database.ErrContention))
+	assert.Equal(t, 2, repo.calls)
+	assert.Equal(t, int64(0), countUsers(t, db))
+
+	// The contention is answered with a 503 the client can retry
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/users", func(c *gin.Context) { httputil.ServerError(c, "Failed to create user", err) })
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/users", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+}
+
+func TestCreateUser_OtherErrorsNotRetried(t *testing.T) {
+	setupDatabase(t)
+	repo := &contendedRepository{UserRepository: repository.NewUserRepository(), code: "23505", failures: 1}
+	s := service.NewUserService(repo)
+
+	_, err := s.CreateUser(adminContext(), newUserRequest())
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, database.ErrContention))
+	assert.Equal(t, 1, repo.calls)
+}
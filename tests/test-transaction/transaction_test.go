@@ -0,0 +1,99 @@
+package test_transaction
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/transaction"
+)
+
+// setupRouter returns a router running the handler in a request transaction, as the admin.
+// The handler creates a user through the service, which opens its own nested transaction, then runs the given ending.
+func setupRouter(t *testing.T, end func(c *gin.Context)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	router := gin.New()
+	router.Use(gin.Recovery(), func(c *gin.Context) {
+		ctx := metacontext.InjectUserInformationMeta(c.Request.Context(), metacontext.UserInformationMeta{UserID: 1, Username: "admin"})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}, transaction.Transaction())
+	router.POST("/users", func(c *gin.Context) {
+		_, err := s.CreateUser(c.Request.Context(), entity.UserCreateRequest{
+			Username:  "newuser",
+			Password:  "P@ssw0rd123",
+			Email:     "newuser@mygmail.com",
+			Firstname: "New",
+			UserType:  "USER_ACCOUNT",
+		})
+		require.NoError(t, err)
+		end(c)
+	})
+
+	return router
+}
+
+// countUsers counts the users committed to the database.
+func countUsers(t *testing.T, db *gorm.DB) int64 {
+	var count int64
+	require.NoError(t, db.Model(&entity.User{}).Count(&count).Error)
+	return count
+}
+
+func TestTransaction_CommitOnSuccess(t *testing.T) {
+	db := setupDatabase(t)
+	router := setupRouter(t, func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	req, _ := http.NewRequest("POST", "/users", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, int64(1), countUsers(t, db))
+}
+
+func TestTransaction_RollbackOnPanic(t *testing.T) {
+	db := setupDatabase(t)
+	router := setupRouter(t, func(c *gin.Context) {
+		panic("failure after the first write")
+	})
+
+	req, _ := http.NewRequest("POST", "/users", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, int64(0), countUsers(t, db))
+
+	// The password history written in the same request is rolled back too
+	var history int64
+	require.NoError(t, db.Model(&entity.PasswordHistory{}).Count(&history).Error)
+	assert.Equal(t, int64(0), history)
+}
+
+func TestTransaction_RollbackOnErrorStatus(t *testing.T) {
+	db := setupDatabase(t)
+	router := setupRouter(t, func(c *gin.Context) {
+		c.Status(http.StatusConflict)
+	})
+
+	req, _ := http.NewRequest("POST", "/users", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, int64(0), countUsers(t, db))
+}
@@ -0,0 +1,36 @@
+package test_user_export
+
+import (
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// userCount is the number of users of the test database.
+const userCount = 25
+
+// setupDatabase opens an SQLite database holding userCount users with the ROLE_USER role, last updated on 2025-01-01,
+// and makes the services use it instead of PostgreSQL. The users 10 and 20 were updated on 2025-06-01,
+// the user 7 was deleted on 2025-06-02.
+func setupDatabase(t *testing.T) *gorm.DB {
+	db := testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true)`,
+		fmt.Sprintf(`WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < %d)
+			INSERT INTO users (username, password, email, firstname, is_enabled, is_account_non_expired, is_account_non_locked,
+				is_credentials_non_expired, user_type, updated_at)
+			SELECT 'user' || n, '!', 'user' || n || '@mygmail.com', 'User', true, true, true, true, 'USER_ACCOUNT', '2025-01-01 00:00:00+00:00' FROM seq`, userCount),
+		`INSERT INTO user_roles (user_id, role_id) SELECT id, 1 FROM users`,
+		`UPDATE users SET firstname = 'Updated', updated_at = '2025-06-01 00:00:00+00:00' WHERE id IN (10, 20)`,
+		`UPDATE users SET is_deleted = true, deleted_by = 1, deleted_at = '2025-06-02 00:00:00+00:00',
+			updated_at = '2025-06-02 00:00:00+00:00' WHERE id = 7`,
+	)
+	if err := database.MigrateUserChangeIndexes(db); err != nil {
+		t.Fatalf("failed to index SQLite database: %v", err)
+	}
+
+	return db
+}
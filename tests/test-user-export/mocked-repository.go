@@ -0,0 +1,71 @@
+package test_user_export
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+)
+
+// generatedRepository is a user repository yielding total generated users in batches, without a database,
+// so the export of a table far larger than the test database can be measured.
+// Every sampleEvery batches it collects the garbage and records the peak of the heap in use.
+type generatedRepository struct {
+	repository.UserRepository
+	total       int
+	sampleEvery int
+
+	batchSize int
+	batches   int
+	peakHeap  uint64
+}
+
+// GetUsersInBatches hands the generated users over to fn in new batches of batchSize, like the reading of a table.
+func (r *generatedRepository) GetUsersInBatches(tx *gorm.DB, updatedSince *time.Time, batchSize int, fn func([]entity.User) error, opts ...repository.ReadOption) error {
+	r.batchSize = batchSize
+	enabled, deleted := true, false
+	updatedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for start := 1; start <= r.total; start += batchSize {
+		users := make([]entity.User, 0, batchSize)
+		for id := start; id < start+batchSize && id <= r.total; id++ {
+			users = append(users, entity.User{
+				ID:        int64(id),
+				TenantID:  1,
+				Username:  fmt.Sprintf("user%d", id),
+				Password:  "!",
+				Email:     fmt.Sprintf("user%d@mygmail.com", id),
+				Firstname: "User",
+				IsEnabled: &enabled,
+				IsDeleted: &deleted,
+				UserType:  "USER_ACCOUNT",
+				Metadata:  entity.UserMetadata{"team": "blue"},
+				UpdatedAt: &updatedAt,
+				Roles:     []entity.Role{{ID: 1, Name: "ROLE_USER"}},
+			})
+		}
+
+		if err := fn(users); err != nil {
+			return err
+		}
+		r.batches++
+
+		if r.sampleEvery > 0 && r.batches%r.sampleEvery == 0 {
+			r.sample()
+		}
+	}
+
+	return nil
+}
+
+// sample records the heap in use once the garbage is collected, if it is the highest so far.
+func (r *generatedRepository) sample() {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	r.peakHeap = max(r.peakHeap, stats.HeapAlloc)
+}
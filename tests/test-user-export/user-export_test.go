@@ -0,0 +1,275 @@
+package test_user_export
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	fieldutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/field-util"
+)
+
+// flushRecorder records the response along with the number of flushes.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (w *flushRecorder) Flush() {
+	w.flushes++
+	w.ResponseRecorder.Flush()
+}
+
+// discardWriter counts the bytes of the response without keeping them, so the test itself holds no body.
+// The flush hook is called on every flush, e.g. to disconnect the client.
+type discardWriter struct {
+	header  http.Header
+	status  int
+	written int
+	flushes int
+	onFlush func()
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *discardWriter) Write(data []byte) (int, error) {
+	w.written += len(data)
+	return len(data), nil
+}
+
+func (w *discardWriter) Flush() {
+	w.flushes++
+	if w.onFlush != nil {
+		w.onFlush()
+	}
+}
+
+// setupRouter registers the export of the users backed by the repository, authenticated as the admin.
+func setupRouter(repo repository.UserRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		ctx := metacontext.InjectUserInformationMeta(c.Request.Context(), metacontext.UserInformationMeta{
+			UserID: 1, Username: "admin", Roles: []string{"ROLE_ADMIN"}, TenantID: metacontext.DefaultTenantID,
+		})
+		c.Request = c.Request.WithContext(metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID))
+		c.Next()
+	})
+
+	h := handler.NewUserHandler(service.NewUserService(repo))
+	router.GET("/api/v1/users/stream", h.ExportUsers)
+	return router
+}
+
+// export requests the export of the users with the query and decodes its lines, unless it is a CSV export.
+func export(t *testing.T, router *gin.Engine, query url.Values) (*flushRecorder, []entity.UserResponse) {
+	req, _ := http.NewRequest("GET", "/api/v1/users/stream?"+query.Encode(), nil)
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	router.ServeHTTP(w, req)
+
+	var users []entity.UserResponse
+	if w.Code == http.StatusOK && w.Header().Get("Content-Type") == "application/x-ndjson" {
+		scanner := bufio.NewScanner(bytes.NewReader(w.Body.Bytes()))
+		for scanner.Scan() {
+			var user entity.UserResponse
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &user), scanner.Text())
+			users = append(users, user)
+		}
+		require.NoError(t, scanner.Err())
+	}
+	return w, users
+}
+
+// userIDs returns the IDs of the users.
+func userIDs(users []entity.UserResponse) []int64 {
+	ids := make([]int64, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+// exportGenerated exports the total generated users into a discarding writer, and returns the repository and the writer.
+func exportGenerated(total int) (*generatedRepository, *discardWriter) {
+	repo := &generatedRepository{total: total, sampleEvery: 10}
+	w := &discardWriter{header: make(http.Header)}
+	req, _ := http.NewRequest("GET", "/api/v1/users/stream", nil)
+	setupRouter(repo).ServeHTTP(w, req)
+	return repo, w
+}
+
+func TestExportUsers_NDJSON(t *testing.T) {
+	setupDatabase(t)
+	t.Setenv("USER_EXPORT_BATCH_SIZE", "10")
+	router := setupRouter(repository.NewUserRepository())
+
+	// One user per line, every batch of 10 users flushed on its own
+	w, users := export(t, router, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	require.Len(t, users, userCount-1)
+	assert.NotContains(t, userIDs(users), int64(7))
+	assert.Equal(t, int64(1), users[0].ID)
+	assert.Equal(t, int64(userCount), users[len(users)-1].ID)
+	assert.Equal(t, 3, w.flushes)
+	assert.NotContains(t, w.Body.String(), "password")
+
+	_, users = export(t, router, url.Values{"includeDeleted": {"true"}})
+	assert.Len(t, users, userCount)
+}
+
+func TestExportUsers_UpdatedSince(t *testing.T) {
+	setupDatabase(t)
+	router := setupRouter(repository.NewUserRepository())
+
+	// The incremental sync returns the updates and the deletions since the last one
+	w, users := export(t, router, url.Values{"updatedSince": {"2025-03-01T00:00:00Z"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []int64{7, 10, 20}, userIDs(users))
+	assert.True(t, *users[0].IsDeleted)
+	assert.Equal(t, "Updated", users[1].Firstname)
+
+	// Nothing changed since, the response is empty
+	w, users = export(t, router, url.Values{"updatedSince": {"2025-06-02T00:00:00Z"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Empty(t, users)
+
+	for _, query := range []url.Values{
+		{"updatedSince": {"yesterday"}},
+		{"includeDeleted": {"maybe"}},
+	} {
+		w, _ := export(t, router, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query.Encode())
+	}
+}
+
+func TestExportUsers_FlatMemory(t *testing.T) {
+	setupDatabase(t)
+	t.Setenv("USER_EXPORT_BATCH_SIZE", "500")
+
+	// Exporting 10 times more users keeps the same few batches in memory, while the response grows with them
+	small, smallBody := exportGenerated(10_000)
+	large, largeBody := exportGenerated(100_000)
+	require.Equal(t, http.StatusOK, largeBody.status)
+	assert.Equal(t, 500, large.batchSize)
+	assert.Equal(t, 200, large.batches)
+	assert.Greater(t, largeBody.written, 9*smallBody.written)
+	assert.Equal(t, 200, largeBody.flushes)
+
+	// Nothing of the exported users is retained, the heap in use stays within 1MiB of the small export
+	// while the large one writes about 28MiB
+	assert.Less(t, large.peakHeap, small.peakHeap+1<<20,
+		"the export of 100,000 users peaked at %d bytes in use, the export of 10,000 users at %d bytes, for %d bytes written",
+		large.peakHeap, small.peakHeap, largeBody.written)
+}
+
+func TestExportUsers_ClientDisconnects(t *testing.T) {
+	setupDatabase(t)
+	t.Setenv("USER_EXPORT_BATCH_SIZE", "100")
+
+	// The client disconnects once it received the first batch
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := &generatedRepository{total: 100_000}
+	w := &discardWriter{header: make(http.Header), onFlush: cancel}
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/api/v1/users/stream", nil)
+	setupRouter(repo).ServeHTTP(w, req)
+
+	// The export stops before the next batch
+	assert.Equal(t, 1, repo.batches)
+	assert.Equal(t, 1, w.flushes)
+	assert.Equal(t, http.StatusOK, w.status)
+}
+
+func TestExportUsers_CSV(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("USER_EXPORT_BATCH_SIZE", "10")
+	router := setupRouter(repository.NewUserRepository())
+	require.NoError(t, db.Exec(`UPDATE users SET firstname = '=HYPERLINK("http://evil")' WHERE id = 2`).Error)
+
+	// One row per user after the header row, every batch of 10 users flushed on its own
+	w, _ := export(t, router, url.Values{"format": {"csv"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=users.csv", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, 3, w.flushes)
+	assert.NotContains(t, w.Body.String(), "password")
+
+	records, err := csv.NewReader(bytes.NewReader(w.Body.Bytes())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, userCount)
+	header := records[0]
+	assert.Equal(t, []string{"id", "tenantId", "username", "email", "firstName"}, header[:5])
+	column := func(record []string, name string) string {
+		for i, column := range header {
+			if column == name {
+				return record[i]
+			}
+		}
+		t.Fatalf("no column %s", name)
+		return ""
+	}
+	assert.Equal(t, "1", column(records[1], "id"))
+	assert.Equal(t, "user1@mygmail.com", column(records[1], "email"))
+	assert.Equal(t, "ROLE_USER", column(records[1], "roles"))
+	assert.Equal(t, "{}", column(records[1], "metadata"))
+	assert.Equal(t, "2025-01-01T00:00:00Z", column(records[1], "updatedAt"))
+	assert.Equal(t, "", column(records[1], "deletedAt"))
+
+	// A cell starting like a formula is not evaluated by a spreadsheet
+	assert.Equal(t, `'=HYPERLINK("http://evil")`, column(records[2], "firstName"))
+
+	// The columns follow the field naming, an empty export keeps its header row
+	fieldutil.SetFieldNaming(fieldutil.SnakeCase)
+	t.Cleanup(func() { fieldutil.SetFieldNaming(fieldutil.CamelCase) })
+	w, _ = export(t, router, url.Values{"format": {"CSV"}, "updatedSince": {"2025-06-02T00:00:00Z"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	records, err = csv.NewReader(bytes.NewReader(w.Body.Bytes())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, []string{"id", "tenant_id", "username", "email", "first_name"}, records[0][:5])
+
+	w, _ = export(t, router, url.Values{"format": {"xml"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestExportUsers_ReleasesConnections(t *testing.T) {
+	db := setupDatabase(t)
+	t.Setenv("USER_EXPORT_BATCH_SIZE", "5")
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+
+	// Every batch is written out while no connection is in use, a slow client does not hold one for the whole export
+	var inUse []int
+	w := &discardWriter{header: make(http.Header), onFlush: func() {
+		inUse = append(inUse, sqlDB.Stats().InUse)
+	}}
+	for _, format := range []string{"ndjson", "csv"} {
+		inUse = nil
+		req, _ := http.NewRequest("GET", "/api/v1/users/stream?format="+format, nil)
+		setupRouter(repository.NewUserRepository()).ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.status)
+		assert.Equal(t, []int{0, 0, 0, 0, 0}, inUse, format)
+	}
+}
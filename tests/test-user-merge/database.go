@@ -0,0 +1,52 @@
+package test_user_merge
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/config/database"
+	"github.com/yoanesber/go-consumer-api-with-jwt/tests/internal/testdb"
+)
+
+// testPassword is the password of the users of the test database.
+const testPassword = "P@ssw0rd123"
+
+// setupDatabase opens an SQLite database with the tables of the migration and makes the services use it
+// instead of PostgreSQL. It holds the enabled admin (ID 1) with the ROLE_USER and ROLE_ADMIN roles, alice (ID 2) with
+// the ROLE_USER role, her duplicate alice.smith (ID 3) with the ROLE_USER and ROLE_AUDITOR roles and two sessions,
+// the deleted bob (ID 4) and the disabled carol (ID 5).
+func setupDatabase(t *testing.T) *gorm.DB {
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash the password: %v", err)
+	}
+
+	db := testdb.Open(t,
+		`INSERT INTO roles (name, is_default) VALUES ('ROLE_USER', true), ('ROLE_ADMIN', false), ('ROLE_AUDITOR', false)`,
+		fmt.Sprintf(`INSERT INTO users (username, password, email, firstname, lastname, is_enabled, is_account_non_expired,
+			is_account_non_locked, is_credentials_non_expired, user_type, metadata) VALUES
+			('admin', '%[1]s', 'admin@mygmail.com', 'Admin', NULL, true, true, true, true, 'USER_ACCOUNT', '{}'),
+			('alice', '%[1]s', 'alice@mygmail.com', 'Alice', 'Smith', true, true, true, true, 'USER_ACCOUNT', '{"team":"blue","plan":"pro"}'),
+			('alice.smith', '%[1]s', 'alice.smith@gmail.com', 'Alice', 'Smith', true, true, true, true, 'USER_ACCOUNT', '{"team":"red","region":"eu"}'),
+			('bob', '%[1]s', 'bob@mygmail.com', 'Bob', NULL, true, true, true, true, 'USER_ACCOUNT', '{}'),
+			('carol', '%[1]s', 'carol@mygmail.com', 'Carol', NULL, false, true, true, true, 'USER_ACCOUNT', '{}')`, hash),
+		`UPDATE users SET is_deleted = true, deleted_by = 1, deleted_at = CURRENT_TIMESTAMP WHERE id = 4`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES (1, 1), (1, 2), (2, 1), (3, 1), (3, 3), (4, 1), (5, 1)`,
+		`INSERT INTO refresh_token (token, session_id, user_id, expiry_date, created_at) VALUES
+			('laptop', 'laptop-session', 3, datetime('now', '+1 day'), CURRENT_TIMESTAMP),
+			('phone', 'phone-session', 3, datetime('now', '+1 day'), CURRENT_TIMESTAMP),
+			('alice', 'alice-session', 2, datetime('now', '+1 day'), CURRENT_TIMESTAMP)`,
+		`INSERT INTO audit_logs (tenant_id, actor, action, entity_type, entity_id, created_at) VALUES
+			(1, 'alice.smith', 'login', 'user', '3', CURRENT_TIMESTAMP)`,
+	)
+
+	// Record the actor of the writes like the PostgreSQL connection does
+	if err := database.RegisterAuditCallbacks(db); err != nil {
+		t.Fatalf("failed to register the audit callbacks: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,183 @@
+package test_user_merge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+)
+
+// callerContext returns a context authenticated as the given user of the default tenant.
+func callerContext(userID int64, roles ...string) context.Context {
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: userID, Username: "admin", Roles: roles, TenantID: metacontext.DefaultTenantID,
+	})
+	return metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID)
+}
+
+// adminContext returns a context authenticated as the admin.
+func adminContext() context.Context {
+	return callerContext(1, "ROLE_USER", "ROLE_ADMIN")
+}
+
+// count returns the number of rows of the table matching the condition.
+func count(t *testing.T, db *gorm.DB, table string, query string, args ...any) int64 {
+	var n int64
+	require.NoError(t, db.Table(table).Where(query, args...).Count(&n).Error)
+	return n
+}
+
+func TestMergeUsers_DryRun(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	result, err := s.MergeUsers(adminContext(), 2, entity.UserMergeRequest{SourceID: 3, DryRun: true})
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []string{"ROLE_AUDITOR"}, result.AddedRoles)
+	assert.Equal(t, []string{"region"}, result.AddedMetadataKeys)
+	assert.Equal(t, []string{"team"}, result.ConflictingMetadataKeys)
+	assert.Equal(t, 2, result.RevokedSessions)
+	assert.Equal(t, int64(2), result.Target.ID)
+
+	// Nothing is written
+	source, err := s.GetUserByID(3)
+	require.NoError(t, err)
+	assert.Nil(t, source.MergedInto)
+	target, err := s.GetUserByID(2)
+	require.NoError(t, err)
+	assert.Len(t, target.Roles, 1)
+	assert.NotContains(t, target.Metadata, "region")
+	assert.Equal(t, int64(2), count(t, db, "refresh_token", "user_id = ?", 3))
+	assert.Zero(t, count(t, db, "outbox_events", "1 = 1"))
+	assert.Zero(t, count(t, db, "audit_logs", "action = ?", "merge"))
+}
+
+func TestMergeUsers_Merge(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	result, err := s.MergeUsers(adminContext(), 2, entity.UserMergeRequest{SourceID: 3})
+	require.NoError(t, err)
+	assert.False(t, result.DryRun)
+	assert.Equal(t, []string{"ROLE_AUDITOR"}, result.AddedRoles)
+	assert.Equal(t, 2, result.RevokedSessions)
+
+	// The target holds the roles of both users, and its own value of the conflicting metadata key
+	target, err := s.GetUserByID(2)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ROLE_USER", "ROLE_AUDITOR"}, service.ExtractRoleNames(target.Roles))
+	assert.Equal(t, entity.UserMetadata{"team": "blue", "plan": "pro", "region": "eu"}, target.Metadata)
+	assert.ElementsMatch(t, []string{"ROLE_USER", "ROLE_AUDITOR"}, service.ExtractRoleNames(result.Target.Roles))
+
+	// The source is deleted with a reference to the target, and its sessions are revoked
+	_, err = s.GetUserByID(3)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	var source entity.User
+	require.NoError(t, db.Unscoped().First(&source, 3).Error)
+	require.NotNil(t, source.MergedInto)
+	assert.Equal(t, int64(2), *source.MergedInto)
+	require.NotNil(t, source.DeletedBy)
+	assert.Equal(t, int64(1), *source.DeletedBy)
+	assert.True(t, source.DeletedAt.Valid)
+	assert.Zero(t, count(t, db, "refresh_token", "user_id = ?", 3))
+	assert.Equal(t, int64(1), count(t, db, "refresh_token", "user_id = ?", 2))
+
+	// The history of the source is kept, the merge is recorded on both users
+	assert.Equal(t, int64(1), count(t, db, "audit_logs", "action = ? AND entity_id = ?", "login", "3"))
+	assert.Equal(t, int64(1), count(t, db, "audit_logs", "action = ? AND entity_id = ?", "merge", "3"))
+	assert.Equal(t, int64(1), count(t, db, "audit_logs", "action = ? AND entity_id = ?", "merge", "2"))
+
+	var event entity.OutboxEvent
+	require.NoError(t, db.First(&event, "type = ?", entity.OutboxEventUserMerged).Error)
+	assert.Equal(t, "2", event.AggregateID)
+	var payload entity.UserMergedPayload
+	require.NoError(t, json.Unmarshal([]byte(event.Payload), &payload))
+	assert.Equal(t, entity.UserMergedPayload{
+		TargetID: 2, SourceID: 3, SourceUsername: "alice.smith", SourceEmail: "alice.smith@gmail.com", AddedRoles: []string{"ROLE_AUDITOR"},
+	}, payload)
+
+	// A merged user cannot be merged again
+	_, err = s.MergeUsers(adminContext(), 2, entity.UserMergeRequest{SourceID: 3})
+	assert.ErrorIs(t, err, service.ErrMergeDeletedUser)
+}
+
+func TestMergeUsers_Refused(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserService(repository.NewUserRepository())
+
+	_, err := s.MergeUsers(adminContext(), 2, entity.UserMergeRequest{SourceID: 2})
+	assert.ErrorIs(t, err, service.ErrMergeIntoSelf)
+
+	// Neither into a deleted target nor from a deleted source
+	_, err = s.MergeUsers(adminContext(), 4, entity.UserMergeRequest{SourceID: 3})
+	assert.ErrorIs(t, err, service.ErrMergeDeletedUser)
+	_, err = s.MergeUsers(adminContext(), 2, entity.UserMergeRequest{SourceID: 4})
+	assert.ErrorIs(t, err, service.ErrMergeDeletedUser)
+
+	_, err = s.MergeUsers(adminContext(), 99, entity.UserMergeRequest{SourceID: 3})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// The last enabled admin would only survive in a disabled user
+	_, err = s.MergeUsers(adminContext(), 5, entity.UserMergeRequest{SourceID: 1})
+	assert.ErrorIs(t, err, service.ErrLastAdmin)
+
+	// Only an admin may grant ROLE_ADMIN through a merge
+	_, err = s.MergeUsers(callerContext(2, "ROLE_USER"), 2, entity.UserMergeRequest{SourceID: 1})
+	assert.ErrorIs(t, err, service.ErrRoleAssignmentForbidden)
+
+	// Every refusal rolls back the whole merge
+	assert.Zero(t, count(t, db, "users", "merged_into IS NOT NULL"))
+	assert.Zero(t, count(t, db, "outbox_events", "1 = 1"))
+}
+
+func TestMergeUsers_Handler(t *testing.T) {
+	setupDatabase(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository()))
+	router.POST("/api/v1/admin/users/:id/merge", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(adminContext())
+		h.MergeUsers(c)
+	})
+
+	merge := func(path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, merge("/api/v1/admin/users/abc/merge", `{"sourceId": 3}`).Code)
+	assert.Equal(t, http.StatusBadRequest, merge("/api/v1/admin/users/2/merge", `{"sourceId": 2}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, merge("/api/v1/admin/users/2/merge", `{"sourceId": 0}`).Code)
+	assert.Equal(t, http.StatusNotFound, merge("/api/v1/admin/users/99/merge", `{"sourceId": 3}`).Code)
+	assert.Equal(t, http.StatusConflict, merge("/api/v1/admin/users/4/merge", `{"sourceId": 3}`).Code)
+
+	w := merge("/api/v1/admin/users/2/merge", `{"sourceId": 3, "dryRun": true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = merge("/api/v1/admin/users/2/merge", `{"sourceId": 3}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Data entity.UserMergeResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.False(t, resp.Data.DryRun)
+	assert.Equal(t, []string{"region"}, resp.Data.AddedMetadataKeys)
+	assert.NotContains(t, w.Body.String(), "password")
+}
@@ -0,0 +1,152 @@
+package test_user_note
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/repository"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	metacontext "github.com/yoanesber/go-consumer-api-with-jwt/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-consumer-api-with-jwt/pkg/middleware/pathparam"
+)
+
+const (
+	aliceID int64 = 1
+	rootID  int64 = 2
+	bobID   int64 = 3
+)
+
+// adminContext returns a context authenticated as the admin of the default tenant with the given ID and roles.
+func adminContext(userID int64, username string, roles ...string) context.Context {
+	ctx := metacontext.InjectUserInformationMeta(context.Background(), metacontext.UserInformationMeta{
+		UserID: userID, Username: username, Roles: roles, TenantID: metacontext.DefaultTenantID,
+	})
+	return metacontext.InjectTenantID(ctx, metacontext.DefaultTenantID)
+}
+
+func aliceContext() context.Context {
+	return adminContext(aliceID, "alice", "ROLE_ADMIN")
+}
+
+func rootContext() context.Context {
+	return adminContext(rootID, "root", "ROLE_ADMIN", "ROLE_SUPER_ADMIN")
+}
+
+func TestUserNotes_CreateAndList(t *testing.T) {
+	setupDatabase(t)
+	s := service.NewUserNoteService(repository.NewUserNoteRepository())
+
+	first, err := s.CreateUserNote(aliceContext(), bobID, entity.UserNoteRequest{Body: "Verified identity via ticket #1234", Pinned: true})
+	require.NoError(t, err)
+	assert.Equal(t, aliceID, first.AuthorID)
+	assert.Equal(t, bobID, first.UserID)
+	_, err = s.CreateUserNote(rootContext(), bobID, entity.UserNoteRequest{Body: "Asked for a refund"})
+	require.NoError(t, err)
+	_, err = s.CreateUserNote(aliceContext(), bobID, entity.UserNoteRequest{Body: "Called back"})
+	require.NoError(t, err)
+
+	// The pinned notes come first, then the most recent
+	notes, total, err := s.GetUserNotes(aliceContext(), bobID, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	require.Len(t, notes, 2)
+	assert.Equal(t, "Verified identity via ticket #1234", notes[0].Body)
+	assert.Equal(t, "Called back", notes[1].Body)
+
+	notes, _, err = s.GetUserNotes(aliceContext(), bobID, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, notes, 1)
+	assert.Equal(t, rootID, notes[0].AuthorID)
+
+	// The notes of an unknown user cannot be read nor written
+	_, _, err = s.GetUserNotes(aliceContext(), 99, 1, 10)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = s.CreateUserNote(aliceContext(), 99, entity.UserNoteRequest{Body: "Nobody"})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestUserNotes_Delete(t *testing.T) {
+	db := setupDatabase(t)
+	s := service.NewUserNoteService(repository.NewUserNoteRepository())
+
+	byAlice, err := s.CreateUserNote(aliceContext(), bobID, entity.UserNoteRequest{Body: "Verified identity via ticket #1234"})
+	require.NoError(t, err)
+	byRoot, err := s.CreateUserNote(rootContext(), bobID, entity.UserNoteRequest{Body: "Asked for a refund"})
+	require.NoError(t, err)
+
+	// Another admin cannot delete the note, a super admin can delete any note
+	assert.ErrorIs(t, s.DeleteUserNote(aliceContext(), bobID, byRoot.ID), service.ErrUserNoteDeleteForbidden)
+	require.NoError(t, s.DeleteUserNote(rootContext(), bobID, byAlice.ID))
+	require.NoError(t, s.DeleteUserNote(rootContext(), bobID, byRoot.ID))
+
+	// The note must belong to the user
+	assert.ErrorIs(t, s.DeleteUserNote(rootContext(), bobID, byRoot.ID), gorm.ErrRecordNotFound)
+	note, err := s.CreateUserNote(aliceContext(), bobID, entity.UserNoteRequest{Body: "Called back"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.DeleteUserNote(aliceContext(), aliceID, note.ID), gorm.ErrRecordNotFound)
+	require.NoError(t, s.DeleteUserNote(aliceContext(), bobID, note.ID))
+
+	// The notes and their deletion are kept in the audit trail of the user
+	var logs []entity.AuditLog
+	require.NoError(t, db.Where("entity_type = ? AND entity_id = ?", "user", "3").Order("id ASC").Find(&logs).Error)
+	actions := make([]string, 0, len(logs))
+	for _, log := range logs {
+		actions = append(actions, log.Action+" by "+log.Actor)
+	}
+	assert.Equal(t, []string{"create_note by user:alice", "create_note by user:root", "delete_note by user:root", "delete_note by user:root",
+		"create_note by user:alice", "delete_note by user:alice"}, actions)
+	require.NotNil(t, logs[2].Details)
+	assert.True(t, strings.HasSuffix(*logs[2].Details, "Verified identity via ticket #1234"))
+}
+
+func TestUserNotes_Handler(t *testing.T) {
+	setupDatabase(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(aliceContext())
+		c.Next()
+	})
+	h := handler.NewUserNoteHandler(service.NewUserNoteService(repository.NewUserNoteRepository()))
+	router.GET("/admin/users/:id/notes", pathparam.PathInt64("id"), h.GetUserNotes)
+	router.POST("/admin/users/:id/notes", pathparam.PathInt64("id"), h.CreateUserNote)
+	router.DELETE("/admin/users/:id/notes/:noteId", pathparam.PathInt64("id"), pathparam.PathInt64("noteId"), h.DeleteUserNote)
+
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("POST", "/admin/users/3/notes", `{"body": "Verified identity via ticket #1234", "pinned": true}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusUnprocessableEntity, request("POST", "/admin/users/3/notes", `{"body": ""}`).Code)
+	assert.Equal(t, http.StatusNotFound, request("POST", "/admin/users/99/notes", `{"body": "Nobody"}`).Code)
+
+	w = request("GET", "/admin/users/3/notes?limit=5", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data []entity.UserNote `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data, 1)
+	assert.True(t, body.Data[0].Pinned)
+	assert.Equal(t, aliceID, body.Data[0].AuthorID)
+
+	assert.Equal(t, http.StatusBadRequest, request("GET", "/admin/users/3/notes?page=0", "").Code)
+	assert.Equal(t, http.StatusNotFound, request("DELETE", "/admin/users/3/notes/99", "").Code)
+	assert.Equal(t, http.StatusOK, request("DELETE", "/admin/users/3/notes/1", "").Code)
+}
@@ -0,0 +1,183 @@
+package test_user_response
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	fieldutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/field-util"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+)
+
+// update rewrites the golden files with the current responses, run `go test ./tests/test-user-response -update`
+// only when the contract of the responses is changed on purpose.
+var update = flag.Bool("update", false, "update the golden files")
+
+// minimalUser returns a user with only its required fields set, as created without any optional value.
+func minimalUser() entity.User {
+	enabled, nonExpired, nonLocked, credentialsNonExpired, deleted := true, true, true, true, false
+	return entity.User{
+		ID:                      1,
+		TenantID:                1,
+		Username:                "minimal",
+		Password:                "$2a$10$hashedpassword",
+		Email:                   "minimal@mygmail.com",
+		Firstname:               "Minimal",
+		IsEnabled:               &enabled,
+		IsAccountNonExpired:     &nonExpired,
+		IsAccountNonLocked:      &nonLocked,
+		IsCredentialsNonExpired: &credentialsNonExpired,
+		IsDeleted:               &deleted,
+		UserType:                "USER_ACCOUNT",
+	}
+}
+
+// maximalUser returns a user with every field set, including the deletion and the merge.
+func maximalUser() entity.User {
+	at := func(day int) *time.Time {
+		t := time.Date(2025, 1, day, 8, 30, 0, 0, time.UTC)
+		return &t
+	}
+	id := func(id int64) *int64 { return &id }
+	lastname, maxSessions, description := "Maximal", 3, "Regular user"
+	enabled, nonExpired, nonLocked, credentialsNonExpired, deleted := false, false, false, false, true
+
+	return entity.User{
+		ID:                        2,
+		TenantID:                  3,
+		Username:                  "maximal",
+		Password:                  "$2a$10$hashedpassword",
+		Email:                     "maximal@mygmail.com",
+		Firstname:                 "Max",
+		Lastname:                  &lastname,
+		IsEnabled:                 &enabled,
+		IsAccountNonExpired:       &nonExpired,
+		IsAccountNonLocked:        &nonLocked,
+		IsCredentialsNonExpired:   &credentialsNonExpired,
+		IsDeleted:                 &deleted,
+		AccountExpirationDate:     at(31),
+		CredentialsExpirationDate: at(30),
+		UserType:                  "SERVICE_ACCOUNT",
+		LastLogin:                 at(20),
+		MaxSessions:               &maxSessions,
+		Metadata:                  entity.UserMetadata{"department": "finance", "region": "emea"},
+		CreatedBy:                 id(1),
+		CreatedAt:                 at(1),
+		UpdatedBy:                 id(4),
+		UpdatedAt:                 at(21),
+		DeletedBy:                 id(4),
+		DeletedAt:                 gorm.DeletedAt{Time: *at(22), Valid: true},
+		MergedInto:                id(5),
+		Roles: []entity.Role{
+			{ID: 1, Name: "ROLE_USER", Description: &description, IsDefault: true},
+			{ID: 3, Name: "ROLE_ADMIN", ExpiresAt: at(31)},
+		},
+	}
+}
+
+// assertGolden asserts the JSON of the response matches the golden file, or rewrites it with -update.
+func assertGolden(t *testing.T, name string, response any) {
+	actual, err := json.MarshalIndent(response, "", "  ")
+	require.NoError(t, err)
+	actual = append(actual, '\n')
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(path, actual, 0o644))
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual), "the response no longer matches %s, run the tests with -update if the change is intended", path)
+}
+
+func TestUserResponse_Golden(t *testing.T) {
+	tests := []struct {
+		golden string
+		user   entity.User
+	}{
+		{"minimal-user.json", minimalUser()},
+		{"maximal-user.json", maximalUser()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			assertGolden(t, tt.golden, tt.user.ToResponse())
+		})
+	}
+}
+
+func TestUserResponse_SameKeys(t *testing.T) {
+	keys := func(user entity.User, naming fieldutil.FieldNaming) []string {
+		fieldutil.SetFieldNaming(naming)
+		defer fieldutil.SetFieldNaming(fieldutil.CamelCase)
+
+		data, err := json.Marshal(httputil.ResponseBody(user.ToResponse()))
+		require.NoError(t, err)
+
+		var fields map[string]any
+		require.NoError(t, json.Unmarshal(data, &fields))
+		delete(fields, "roles")
+
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		return names
+	}
+
+	// The unset optional fields are null rather than omitted, in every field naming
+	for _, naming := range []fieldutil.FieldNaming{fieldutil.CamelCase, fieldutil.SnakeCase} {
+		assert.ElementsMatch(t, keys(maximalUser(), naming), keys(minimalUser(), naming), naming)
+	}
+
+	user := minimalUser()
+	var minimal map[string]any
+	data, err := json.Marshal(user.ToResponse())
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &minimal))
+	assert.Contains(t, minimal, "lastLogin")
+	assert.Nil(t, minimal["lastLogin"])
+	assert.Contains(t, minimal, "createdBy")
+	assert.Nil(t, minimal["createdBy"])
+	assert.Equal(t, map[string]any{}, minimal["metadata"])
+	assert.NotContains(t, minimal, "password")
+}
+
+func TestUserResponse_NullVersusAbsent(t *testing.T) {
+	fields := func(user entity.User) map[string]any {
+		data, err := json.Marshal(user.ToResponse())
+		require.NoError(t, err)
+
+		var fields map[string]any
+		require.NoError(t, json.Unmarshal(data, &fields))
+		return fields
+	}
+
+	// The last login is null until the first login, then its timestamp
+	user := minimalUser()
+	assert.Contains(t, fields(user), "lastLogin")
+	assert.Nil(t, fields(user)["lastLogin"])
+
+	lastLogin := time.Date(2025, 1, 20, 8, 30, 0, 0, time.UTC)
+	user.LastLogin = &lastLogin
+	assert.Equal(t, "2025-01-20T08:30:00Z", fields(user)["lastLogin"])
+
+	// The roles are absent when they were not loaded, and empty when the user holds none
+	user.Roles = nil
+	assert.NotContains(t, fields(user), "roles")
+
+	user.Roles = []entity.Role{}
+	assert.Equal(t, []any{}, fields(user)["roles"])
+
+	user.Roles = maximalUser().Roles
+	assert.Len(t, fields(user)["roles"], 2)
+}
@@ -0,0 +1,83 @@
+package test_user
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+// setupBatchGetRouter registers the batch get route with two dummy users.
+func setupBatchGetRouter() *gin.Engine {
+	admin := getDummyUser()
+	user := getDummyUser()
+	user.ID = 2
+	user.Username = "user"
+	user.Email = "user@mygmail.com"
+
+	h := handler.NewUserHandler(NewUserMockedService(admin, user))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/users/batch-get", h.BatchGetUsers)
+	return router
+}
+
+func TestBatchGetUsers_MixOfExistingAndMissingIDs(t *testing.T) {
+	router := setupBatchGetRouter()
+
+	req, _ := http.NewRequest("POST", "/api/v1/users/batch-get", bytes.NewBufferString(`{"ids": [1, 2, 42]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data map[string]map[string]any `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.Data, 2)
+	assert.Contains(t, body.Data, "1")
+	assert.Contains(t, body.Data, "2")
+	assert.NotContains(t, body.Data, "42")
+
+	// The users are sanitized and come with their roles
+	assert.NotContains(t, body.Data["1"], "password")
+	assert.Equal(t, "user", body.Data["2"]["username"])
+	assert.NotEmpty(t, body.Data["1"]["roles"])
+}
+
+func TestBatchGetUsers_InvalidRequest(t *testing.T) {
+	router := setupBatchGetRouter()
+
+	tooMany, _ := json.Marshal(map[string][]int{"ids": make([]int, 101)})
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"missing ids", `{}`, http.StatusUnprocessableEntity},
+		{"empty ids", `{"ids": []}`, http.StatusUnprocessableEntity},
+		{"invalid id", `{"ids": [0]}`, http.StatusUnprocessableEntity},
+		{"not a number", `{"ids": ["abc"]}`, http.StatusBadRequest},
+		{"too many ids", string(tooMany), http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/v1/users/batch-get", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package test_user
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+)
+
+// setupBulkDeleteRouter registers the bulk delete route with two dummy users.
+func setupBulkDeleteRouter() (*gin.Engine, service.UserService) {
+	admin := getDummyUser()
+	user := getDummyUser()
+	user.ID = 2
+	user.Username = "user"
+	user.Email = "user@mygmail.com"
+
+	s := NewUserMockedService(admin, user)
+	h := handler.NewUserHandler(s)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/users/bulk-delete", h.BulkDeleteUsers)
+	return router, s
+}
+
+func TestBulkDeleteUsers_Confirmed(t *testing.T) {
+	router, s := setupBulkDeleteRouter()
+
+	req, _ := http.NewRequest("POST", "/api/v1/users/bulk-delete", bytes.NewBufferString(`{"ids": [2, 42], "confirmationToken": "DELETE 2 USERS"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data []entity.UserBulkDeleteResult `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, []entity.UserBulkDeleteResult{
+		{ID: 2, Status: entity.UserBulkDeleteDeleted},
+		{ID: 42, Status: entity.UserBulkDeleteNotFound},
+	}, body.Data)
+
+	_, err := s.GetUserByID(2)
+	assert.Error(t, err)
+}
+
+func TestBulkDeleteUsers_InvalidConfirmationToken(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"missing token", `{"ids": [1, 2]}`},
+		{"wrong token", `{"ids": [1, 2], "confirmationToken": "yes"}`},
+		{"token for another number of users", `{"ids": [1, 2], "confirmationToken": "DELETE 1 USERS"}`},
+		{"duplicate IDs", `{"ids": [2, 2], "confirmationToken": "DELETE 2 USERS"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, s := setupBulkDeleteRouter()
+
+			req, _ := http.NewRequest("POST", "/api/v1/users/bulk-delete", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+			// The whole operation is rejected, no user is deleted
+			for _, id := range []int64{1, 2} {
+				_, err := s.GetUserByID(id)
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
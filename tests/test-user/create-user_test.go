@@ -0,0 +1,50 @@
+package test_user
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+func TestCreateUser_Handler(t *testing.T) {
+	h := handler.NewUserHandler(NewUserMockedService(getDummyUser()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/users", h.CreateUser)
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		location string
+	}{
+		{"created", `{"username": "newuser", "password": "P@ssw0rd123", "email": "newuser@mygmail.com", "firstName": "New", "userType": "USER_ACCOUNT"}`, http.StatusCreated, "/api/v1/users/2"},
+		{"duplicate username", `{"username": "newuser", "password": "P@ssw0rd123", "email": "other@mygmail.com", "firstName": "New", "userType": "USER_ACCOUNT"}`, http.StatusConflict, ""},
+		{"unknown role", `{"username": "other", "password": "P@ssw0rd123", "email": "other@mygmail.com", "firstName": "New", "userType": "USER_ACCOUNT", "roles": ["ROLE_ROOT"]}`, http.StatusUnprocessableEntity, ""},
+		{"role without prefix", `{"username": "other", "password": "P@ssw0rd123", "email": "other@mygmail.com", "firstName": "New", "userType": "USER_ACCOUNT", "roles": ["admin"]}`, http.StatusUnprocessableEntity, ""},
+		{"lowercase role", `{"username": "other", "password": "P@ssw0rd123", "email": "other@mygmail.com", "firstName": "New", "userType": "USER_ACCOUNT", "roles": [" role_moderator "]}`, http.StatusCreated, "/api/v1/users/3"},
+		{"short password", `{"username": "other", "password": "short", "email": "other@mygmail.com", "firstName": "New", "userType": "USER_ACCOUNT"}`, http.StatusUnprocessableEntity, ""},
+		{"unknown user type", `{"username": "other", "password": "P@ssw0rd123", "email": "other@mygmail.com", "firstName": "New", "userType": "ROBOT"}`, http.StatusUnprocessableEntity, ""},
+		{"malformed JSON", `{"username": "other", "password": `, http.StatusBadRequest, ""},
+		{"wrong field type", `{"username": 42, "password": "P@ssw0rd123", "email": "other@mygmail.com", "firstName": "New", "userType": "USER_ACCOUNT"}`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/v1/users", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package test_user
+
+import (
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+)
+
+// boolPtr returns a pointer to the given bool.
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// getDummyUser returns a dummy user entity for testing purposes.
+func getDummyUser() entity.User {
+	return entity.User{
+		ID:                      1,
+		Username:                "admin",
+		Password:                "$2a$10$eP5Sddi7Q5Jv6seppeF93.XsWGY8r4PnsqprWGb5AxsZ9TpwULIGa",
+		Email:                   "admin@mygmail.com",
+		Firstname:               "Admin",
+		IsEnabled:               boolPtr(true),
+		IsAccountNonExpired:     boolPtr(true),
+		IsAccountNonLocked:      boolPtr(true),
+		IsCredentialsNonExpired: boolPtr(true),
+		IsDeleted:               boolPtr(false),
+		UserType:                "USER_ACCOUNT",
+		Roles:                   []entity.Role{{ID: 3, Name: "ROLE_ADMIN"}},
+	}
+}
@@ -0,0 +1,40 @@
+package test_user
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+func TestForcePasswordChange_Handler(t *testing.T) {
+	h := handler.NewUserHandler(NewUserMockedService(getDummyUser()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/users/:id/force-password-change", h.ForcePasswordChange)
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"invalid ID", "/api/v1/users/abc/force-password-change", http.StatusBadRequest},
+		{"unknown user", "/api/v1/users/42/force-password-change", http.StatusNotFound},
+		{"forced", "/api/v1/users/1/force-password-change", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+}
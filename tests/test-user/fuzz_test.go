@@ -0,0 +1,83 @@
+package test_user
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+// FuzzCreateUser_Body sends arbitrary bodies through the real binder and validator of CreateUser.
+// The handler must never panic, and only respond with 201 Created, 400 Bad Request or 422 Unprocessable Entity.
+func FuzzCreateUser_Body(f *testing.F) {
+	seeds := []string{
+		`{"username": "newuser", "password": "P@ssw0rd123", "email": "newuser@mygmail.com", "firstName": "New", "userType": "USER_ACCOUNT"}`,
+		`{"username": "ユーザー名", "password": "P@ssw0rd123", "email": "ユーザー@mygmail.com", "firstName": "Ünïcødé", "userType": "USER_ACCOUNT"}`,
+		`{"username": "newuser", "password": "P@ssw0rd123", "email": "newuser@mygmail.com", "firstName": "New", "lastName": null, "userType": "USER_ACCOUNT", "roles": ["ROLE_ADMIN", "ROLE_ADMIN"]}`,
+		`{"username": 1234567890123456789, "password": true, "roles": "ROLE_ADMIN"}`,
+		`{"username": "%_", "password": "%%%%____", "email": "a@b", "firstName": "_", "userType": "SERVICE_ACCOUNT"}`,
+		`{"roles": [null]}`,
+		`[]`,
+		`null`,
+		`{`,
+		``,
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	gin.SetMode(gin.TestMode)
+	f.Fuzz(func(t *testing.T, body string) {
+		h := handler.NewUserHandler(NewUserMockedService())
+		router := gin.New()
+		router.POST("/api/v1/users", h.CreateUser)
+
+		req, _ := http.NewRequest("POST", "/api/v1/users", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		switch w.Code {
+		case http.StatusCreated, http.StatusBadRequest, http.StatusUnprocessableEntity:
+		default:
+			t.Fatalf("unexpected status %d for body %q: %s", w.Code, body, w.Body.String())
+		}
+	})
+}
+
+// FuzzUserID_PathParameter sends arbitrary user IDs in the path.
+// The handler must never panic, and respond with 200 OK for the existing user, 404 Not Found or 400 Bad Request.
+func FuzzUserID_PathParameter(f *testing.F) {
+	for _, seed := range []string{"1", "2", "0", "-1", "9223372036854775807", "9223372036854775808", "1234567890123456789", "1e3", "0x1", " 1", "١", "%00"} {
+		f.Add(seed)
+	}
+
+	gin.SetMode(gin.TestMode)
+	f.Fuzz(func(t *testing.T, id string) {
+		// A path parameter cannot be empty nor hold a slash, the router redirects or does not match these paths
+		if id == "" || strings.Contains(id, "/") {
+			return
+		}
+
+		h := handler.NewUserHandler(NewUserMockedService(getDummyUser()))
+		router := gin.New()
+		router.PATCH("/api/v1/users/:id/session-limit", h.UpdateUserSessionLimit)
+
+		req, _ := http.NewRequest("PATCH", "/api/v1/users/"+url.PathEscape(id)+"/session-limit", bytes.NewBufferString(`{"maxSessions": 5}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		switch w.Code {
+		case http.StatusOK, http.StatusNotFound, http.StatusBadRequest:
+		default:
+			t.Fatalf("unexpected status %d for ID %q: %s", w.Code, id, w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package test_user
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+func TestGetUsers_Handler(t *testing.T) {
+	h := handler.NewUserHandler(NewUserMockedService(getDummyUser()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/users", h.GetUsers)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"all users", "", http.StatusOK},
+		{"modified since", "modifiedSince=" + url.QueryEscape("2025-01-31T23:59:59+07:00"), http.StatusOK},
+		{"modified since in UTC", "modifiedSince=2025-01-31T23:59:59Z", http.StatusOK},
+		{"date without time", "modifiedSince=2025-01-31", http.StatusBadRequest},
+		{"unix timestamp", "modifiedSince=1738367999", http.StatusBadRequest},
+		{"updated since", "updatedSince=2025-01-31T23:59:59Z", http.StatusOK},
+		{"invalid updatedSince", "updatedSince=2025-01-31", http.StatusBadRequest},
+		{"created since", "createdSince=" + url.QueryEscape("2025-01-31T23:59:59+07:00"), http.StatusOK},
+		{"invalid createdSince", "createdSince=yesterday", http.StatusBadRequest},
+		{"combined filters", "updatedSince=2025-01-31T23:59:59Z&createdSince=2025-01-01T00:00:00Z&includeDeleted=true", http.StatusOK},
+		{"partial with createdSince", "partial=true&createdSince=2025-01-31T23:59:59Z", http.StatusBadRequest},
+		{"include deleted", "includeDeleted=true", http.StatusOK},
+		{"invalid includeDeleted", "includeDeleted=maybe", http.StatusBadRequest},
+		{"invalid page", "page=0", http.StatusBadRequest},
+		{"invalid limit", "limit=abc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/v1/users?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package test_user
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+// setupMetadataRouter registers the metadata routes with a dummy user holding a CRM ID.
+func setupMetadataRouter(t *testing.T) *gin.Engine {
+	t.Setenv("USER_METADATA_KEYS", "crmId, employeeNumber")
+
+	user := getDummyUser()
+	user.Metadata = entity.UserMetadata{"crmId": "C-123"}
+	h := handler.NewUserHandler(NewUserMockedService(user))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/users/by-metadata", h.GetUsersByMetadata)
+	router.PATCH("/api/v1/users/:id/metadata", h.UpdateUserMetadata)
+	return router
+}
+
+func TestUpdateUserMetadata(t *testing.T) {
+	router := setupMetadataRouter(t)
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		status   int
+		metadata entity.UserMetadata
+	}{
+		{"set a key", "/api/v1/users/1/metadata", `{"metadata": {"employeeNumber": "E-42"}}`, http.StatusOK, entity.UserMetadata{"crmId": "C-123", "employeeNumber": "E-42"}},
+		{"remove a key", "/api/v1/users/1/metadata", `{"metadata": {"crmId": null}}`, http.StatusOK, entity.UserMetadata{"employeeNumber": "E-42"}},
+		{"key not allowed", "/api/v1/users/1/metadata", `{"metadata": {"notes": "anything"}}`, http.StatusUnprocessableEntity, nil},
+		{"empty value", "/api/v1/users/1/metadata", `{"metadata": {"crmId": ""}}`, http.StatusUnprocessableEntity, nil},
+		{"no key", "/api/v1/users/1/metadata", `{"metadata": {}}`, http.StatusUnprocessableEntity, nil},
+		{"unknown user", "/api/v1/users/99/metadata", `{"metadata": {"crmId": "C-1"}}`, http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("PATCH", tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data entity.UserResponse `json:"data"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.metadata, body.Data.Metadata)
+		})
+	}
+}
+
+func TestGetUsersByMetadata(t *testing.T) {
+	router := setupMetadataRouter(t)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		count  int
+	}{
+		{"matching user", "?key=crmId&value=C-123", http.StatusOK, 1},
+		{"no matching user", "?key=crmId&value=C-999", http.StatusOK, 0},
+		{"key not allowed", "?key=notes&value=C-123", http.StatusBadRequest, 0},
+		{"missing value", "?key=crmId", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/v1/users/by-metadata"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data []entity.UserResponse `json:"data"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Len(t, body.Data, tt.count)
+		})
+	}
+}
+
+func TestUserMetadata_ApplyTo(t *testing.T) {
+	user := entity.User{Metadata: entity.UserMetadata{"crmId": "C-123"}}
+	value := "C-456"
+	req := entity.UserMetadataRequest{Metadata: map[string]*string{"crmId": &value, "employeeNumber": nil}}
+
+	changes, err := req.ApplyTo(&user)
+	assert.NoError(t, err)
+
+	// Removing an absent key is not a change
+	if assert.Len(t, changes, 1) {
+		assert.Equal(t, "crmId", changes[0].Key)
+		assert.Equal(t, "C-123", *changes[0].Old)
+		assert.Equal(t, "C-456", *changes[0].New)
+	}
+
+	// The metadata round-trips through its database representation
+	stored, err := user.Metadata.Value()
+	assert.NoError(t, err)
+	var scanned entity.UserMetadata
+	assert.NoError(t, scanned.Scan(stored))
+	assert.Equal(t, user.Metadata, scanned)
+}
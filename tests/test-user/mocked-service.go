@@ -0,0 +1,513 @@
+package test_user
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/service"
+	textutil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/text-util"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// userMockedService is a struct that implements the UserService interface.
+// It serves the dummy users directly, so the handlers can be tested without a database connection.
+type userMockedService struct {
+	users map[int64]entity.User
+}
+
+// NewUserMockedService creates a new instance of UserService backed by the given users.
+// It initializes the userMockedService struct and returns it.
+func NewUserMockedService(users ...entity.User) service.UserService {
+	s := &userMockedService{users: make(map[int64]entity.User)}
+	for _, u := range users {
+		s.users[u.ID] = u
+	}
+	return s
+}
+
+// GetUserByID returns the dummy user with the given ID.
+func (s *userMockedService) GetUserByID(id int64) (entity.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return entity.User{}, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+// GetUsersByIDs returns the dummy users with the given IDs in their order, along with the unknown IDs.
+func (s *userMockedService) GetUsersByIDs(ctx context.Context, ids []int64, withRoles bool) ([]entity.User, []int64, error) {
+	var users []entity.User
+	var missing []int64
+	for _, id := range ids {
+		if user, ok := s.users[id]; ok {
+			users = append(users, user)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return users, missing, nil
+}
+
+// GetUserByUsername returns the dummy user with the given username.
+func (s *userMockedService) GetUserByUsername(username string) (entity.User, error) {
+	for _, user := range s.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return entity.User{}, gorm.ErrRecordNotFound
+}
+
+// GetUserByEmail returns the dummy user with the given email.
+func (s *userMockedService) GetUserByEmail(email string) (entity.User, error) {
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return entity.User{}, gorm.ErrRecordNotFound
+}
+
+// UpdateLastLogin updates the last login time of the dummy user.
+func (s *userMockedService) UpdateLastLogin(id int64, lastLogin time.Time) (bool, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return false, err
+	}
+
+	user.LastLogin = &lastLogin
+	s.users[id] = user
+	return true, nil
+}
+
+// UpdateUserStatus applies the provided status flags to the dummy user.
+func (s *userMockedService) UpdateUserStatus(ctx context.Context, id int64, req entity.UserStatusRequest) (entity.User, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	req.ApplyTo(&user)
+	s.users[id] = user
+	return user, nil
+}
+
+// UpdateUserSessionLimit overrides the session limit of the dummy user.
+func (s *userMockedService) UpdateUserSessionLimit(ctx context.Context, id int64, maxSessions *int) (entity.User, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	user.MaxSessions = maxSessions
+	s.users[id] = user
+	return user, nil
+}
+
+// UpdateUserMetadata applies the provided metadata keys to the dummy user.
+func (s *userMockedService) UpdateUserMetadata(ctx context.Context, id int64, req entity.UserMetadataRequest) (entity.User, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	if _, err := req.ApplyTo(&user); err != nil {
+		return entity.User{}, err
+	}
+	s.users[id] = user
+	return user, nil
+}
+
+// ResetUserPassword replaces the password of the dummy user, rejecting its current password.
+func (s *userMockedService) ResetUserPassword(ctx context.Context, id int64, password string) (entity.User, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	if user.Password == password {
+		return entity.User{}, service.ErrPasswordReused
+	}
+	user.Password = password
+	s.users[id] = user
+	return user, nil
+}
+
+// ForcePasswordChange expires the credentials of the dummy user with the given ID.
+func (s *userMockedService) ForcePasswordChange(ctx context.Context, id int64) (entity.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return entity.User{}, gorm.ErrRecordNotFound
+	}
+
+	expired := false
+	user.IsCredentialsNonExpired = &expired
+	s.users[id] = user
+	return user, nil
+}
+
+// ExpireUserCredentials expires the credentials of the dummy user with the given ID.
+func (s *userMockedService) ExpireUserCredentials(ctx context.Context, id int64) (entity.User, error) {
+	return s.ForcePasswordChange(ctx, id)
+}
+
+// ExpireRoleCredentials expires the credentials of the dummy users holding the role, except those already expired.
+func (s *userMockedService) ExpireRoleCredentials(ctx context.Context, roleName string) (entity.CredentialExpiryResult, error) {
+	result := entity.CredentialExpiryResult{Role: validation.NormalizeRoleName(roleName), SessionsRevoked: true}
+	for id, user := range s.users {
+		if !slices.Contains(service.ExtractRoleNames(user.Roles), result.Role) {
+			continue
+		}
+		if user.IsCredentialsNonExpired != nil && !*user.IsCredentialsNonExpired {
+			continue
+		}
+		if _, err := s.ForcePasswordChange(ctx, id); err != nil {
+			return result, err
+		}
+		result.AffectedUsers++
+	}
+
+	return result, nil
+}
+
+// ReassignRole moves the dummy users holding a role to another one, those already holding it are skipped.
+func (s *userMockedService) ReassignRole(ctx context.Context, req entity.RoleReassignmentRequest) (entity.RoleReassignmentResult, error) {
+	from, to := validation.NormalizeRoleName(req.From), validation.NormalizeRoleName(req.To)
+	if from == to {
+		return entity.RoleReassignmentResult{}, service.ErrSameRole
+	}
+
+	result := entity.RoleReassignmentResult{From: from, To: to, DryRun: req.DryRun}
+	for id, user := range s.users {
+		names := service.ExtractRoleNames(user.Roles)
+		if !slices.Contains(names, from) {
+			continue
+		}
+		if slices.Contains(names, to) {
+			result.SkippedUsers++
+			continue
+		}
+		result.ReassignedUsers++
+		if req.DryRun {
+			continue
+		}
+
+		roles := slices.DeleteFunc(slices.Clone(user.Roles), func(role entity.Role) bool { return role.Name == from })
+		user.Roles = append(roles, entity.Role{Name: to})
+		s.users[id] = user
+	}
+
+	return result, nil
+}
+
+// UpdateUserRoles replaces the roles of the dummy user with the given ID, the unknown roles are rejected.
+func (s *userMockedService) UpdateUserRoles(ctx context.Context, id int64, req entity.UserRolesRequest) (entity.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return entity.User{}, gorm.ErrRecordNotFound
+	}
+
+	roles := make([]entity.Role, 0, len(req.Roles))
+	for _, name := range req.Roles {
+		name = validation.NormalizeRoleName(name)
+		if !slices.Contains([]string{"ROLE_USER", "ROLE_MODERATOR", "ROLE_ADMIN", "ROLE_SUPER_ADMIN"}, name) {
+			return entity.User{}, fmt.Errorf("%w: %s", service.ErrUnknownRole, name)
+		}
+		roles = append(roles, entity.Role{Name: name})
+	}
+
+	user.Roles = roles
+	s.users[id] = user
+	return user, nil
+}
+
+// PatchUserRoles adds and removes some roles of the dummy user with the given ID, the unknown roles are rejected.
+func (s *userMockedService) PatchUserRoles(ctx context.Context, id int64, req entity.UserRolesPatchRequest) (entity.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return entity.User{}, gorm.ErrRecordNotFound
+	}
+
+	for _, name := range append(slices.Clone(req.Add), req.Remove...) {
+		if !slices.Contains([]string{"ROLE_USER", "ROLE_MODERATOR", "ROLE_ADMIN", "ROLE_SUPER_ADMIN"}, validation.NormalizeRoleName(name)) {
+			return entity.User{}, fmt.Errorf("%w: %s", service.ErrUnknownRole, validation.NormalizeRoleName(name))
+		}
+	}
+
+	roles := slices.DeleteFunc(slices.Clone(user.Roles), func(role entity.Role) bool {
+		return slices.ContainsFunc(req.Remove, func(name string) bool { return validation.NormalizeRoleName(name) == role.Name })
+	})
+	for _, name := range req.Add {
+		name = validation.NormalizeRoleName(name)
+		if !slices.ContainsFunc(roles, func(role entity.Role) bool { return role.Name == name }) {
+			roles = append(roles, entity.Role{Name: name})
+		}
+	}
+
+	user.Roles = roles
+	s.users[id] = user
+	return user, nil
+}
+
+// BulkDeleteUsers removes the dummy users with the given IDs, the unknown IDs are reported as not found.
+func (s *userMockedService) BulkDeleteUsers(ctx context.Context, ids []int64) ([]entity.UserBulkDeleteResult, error) {
+	results := make([]entity.UserBulkDeleteResult, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := s.users[id]; !ok {
+			results = append(results, entity.UserBulkDeleteResult{ID: id, Status: entity.UserBulkDeleteNotFound})
+			continue
+		}
+		delete(s.users, id)
+		results = append(results, entity.UserBulkDeleteResult{ID: id, Status: entity.UserBulkDeleteDeleted})
+	}
+	return results, nil
+}
+
+// MergeUsers removes the dummy source user unless it is a dry run, the unknown IDs are reported as not found.
+func (s *userMockedService) MergeUsers(ctx context.Context, targetID int64, req entity.UserMergeRequest) (entity.UserMergeResult, error) {
+	if req.SourceID == targetID {
+		return entity.UserMergeResult{}, service.ErrMergeIntoSelf
+	}
+	target, ok := s.users[targetID]
+	if !ok {
+		return entity.UserMergeResult{}, gorm.ErrRecordNotFound
+	}
+	if _, ok := s.users[req.SourceID]; !ok {
+		return entity.UserMergeResult{}, gorm.ErrRecordNotFound
+	}
+
+	if !req.DryRun {
+		delete(s.users, req.SourceID)
+	}
+	return entity.UserMergeResult{TargetID: targetID, SourceID: req.SourceID, DryRun: req.DryRun, Target: target.ToResponse()}, nil
+}
+
+// GetUsersByMetadata returns the dummy users whose metadata holds the given key and value.
+func (s *userMockedService) GetUsersByMetadata(ctx context.Context, key string, value string) ([]entity.User, error) {
+	var users []entity.User
+	for _, user := range s.users {
+		if v, ok := user.Metadata[key]; ok && v == value {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// CreateUser adds a dummy user with the next ID, without the password.
+func (s *userMockedService) CreateUser(ctx context.Context, req entity.UserCreateRequest) (entity.User, error) {
+	for _, user := range s.users {
+		if user.Username == req.Username || user.Email == req.Email {
+			return entity.User{}, service.ErrUserAlreadyExists
+		}
+	}
+	for _, name := range req.Roles {
+		if !slices.Contains([]string{"ROLE_USER", "ROLE_MODERATOR", "ROLE_ADMIN", "ROLE_SUPER_ADMIN"}, validation.NormalizeRoleName(name)) {
+			return entity.User{}, fmt.Errorf("%w: %s", service.ErrUnknownRole, name)
+		}
+	}
+
+	user := entity.User{
+		ID:        int64(len(s.users) + 1),
+		Username:  req.Username,
+		Email:     req.Email,
+		Firstname: req.Firstname,
+		Lastname:  req.Lastname,
+		UserType:  req.UserType,
+	}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+// RegisterUser adds a disabled dummy user with the self-registration role and the next ID.
+func (s *userMockedService) RegisterUser(ctx context.Context, req entity.UserRegisterRequest) (entity.User, error) {
+	for _, user := range s.users {
+		if user.Username == req.Username || user.Email == req.Email {
+			return entity.User{}, service.ErrUserAlreadyExists
+		}
+	}
+
+	disabled := false
+	user := entity.User{
+		ID:        int64(len(s.users) + 1),
+		Username:  req.Username,
+		Email:     req.Email,
+		Firstname: req.Firstname,
+		Lastname:  req.Lastname,
+		IsEnabled: &disabled,
+		UserType:  "USER_ACCOUNT",
+		Roles:     []entity.Role{{Name: service.GetSelfRegistrationRole()}},
+	}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+// GetUsers returns a page of the dummy users ordered by ID, or of those updated after filter.ModifiedSince.
+// The deleted users are left out unless includeDeleted or filter.ModifiedSince is given, the other filters are ignored.
+func (s *userMockedService) GetUsers(ctx context.Context, filter entity.UserFilter, includeDeleted bool, page int, limit int) ([]entity.User, int64, error) {
+	modifiedSince := filter.ModifiedSince
+	var users []entity.User
+	for _, user := range s.users {
+		if user.DeletedAt.Valid && !includeDeleted && modifiedSince == nil {
+			continue
+		}
+		if modifiedSince == nil || (user.UpdatedAt != nil && user.UpdatedAt.After(*modifiedSince)) {
+			users = append(users, user)
+		}
+	}
+	slices.SortFunc(users, func(a, b entity.User) int { return int(a.ID - b.ID) })
+
+	total := int64(len(users))
+	start := min((page-1)*limit, len(users))
+	return users[start:min(start+limit, len(users))], total, nil
+}
+
+// StreamUsers hands the dummy users ordered by ID over in a single batch, the cursor is the plain ID of the last one.
+func (s *userMockedService) StreamUsers(ctx context.Context, includeDeleted bool, cursor string, limit int, emit func([]entity.User) error) (string, bool, error) {
+	afterID := int64(0)
+	if cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			return "", false, service.ErrInvalidCursor
+		}
+		afterID = id
+	}
+
+	var users []entity.User
+	for _, user := range s.users {
+		if user.ID > afterID && (!user.DeletedAt.Valid || includeDeleted) {
+			users = append(users, user)
+		}
+	}
+	slices.SortFunc(users, func(a, b entity.User) int { return int(a.ID - b.ID) })
+
+	if len(users) == 0 {
+		return "", false, nil
+	}
+	if len(users) <= limit {
+		return "", false, emit(users)
+	}
+	return strconv.FormatInt(users[limit-1].ID, 10), false, emit(users[:limit])
+}
+
+// ExportUsers hands the dummy users ordered by ID over in a single batch, those updated after updatedSince if given.
+func (s *userMockedService) ExportUsers(ctx context.Context, updatedSince *time.Time, includeDeleted bool, emit func([]entity.User) error) error {
+	var users []entity.User
+	for _, user := range s.users {
+		if updatedSince != nil && (user.UpdatedAt == nil || !user.UpdatedAt.After(*updatedSince)) {
+			continue
+		}
+		if !user.DeletedAt.Valid || includeDeleted || updatedSince != nil {
+			users = append(users, user)
+		}
+	}
+	slices.SortFunc(users, func(a, b entity.User) int { return int(a.ID - b.ID) })
+
+	if len(users) == 0 {
+		return nil
+	}
+	return emit(users)
+}
+
+// GetDeletedUsers returns a page of the soft-deleted dummy users ordered by ID.
+func (s *userMockedService) GetDeletedUsers(ctx context.Context, page int, limit int) ([]entity.User, int64, error) {
+	var users []entity.User
+	for _, user := range s.users {
+		if user.DeletedAt.Valid {
+			users = append(users, user)
+		}
+	}
+	slices.SortFunc(users, func(a, b entity.User) int { return int(a.ID - b.ID) })
+
+	total := int64(len(users))
+	start := min((page-1)*limit, len(users))
+	return users[start:min(start+limit, len(users))], total, nil
+}
+
+// GetDuplicateUsers returns a page of the dummy users that are not deleted grouped by normalized email address.
+func (s *userMockedService) GetDuplicateUsers(ctx context.Context, page int, limit int) ([]entity.DuplicateUserCluster, int64, error) {
+	groups := make(map[string][]entity.User)
+	for _, user := range s.users {
+		if !user.DeletedAt.Valid {
+			email := textutil.NormalizeEmail(user.Email)
+			groups[email] = append(groups[email], user)
+		}
+	}
+
+	var clusters []entity.DuplicateUserCluster
+	for email, users := range groups {
+		if len(users) < 2 {
+			continue
+		}
+		slices.SortFunc(users, func(a, b entity.User) int { return int(a.ID - b.ID) })
+
+		cluster := entity.DuplicateUserCluster{TenantID: 1, Reason: entity.DuplicateReasonEmail, Key: email, Confidence: 0.9}
+		for _, user := range users {
+			cluster.Users = append(cluster.Users, user.ToResponse())
+		}
+		clusters = append(clusters, cluster)
+	}
+	slices.SortFunc(clusters, func(a, b entity.DuplicateUserCluster) int { return strings.Compare(a.Key, b.Key) })
+
+	total := int64(len(clusters))
+	start := min((page-1)*limit, len(clusters))
+	return clusters[start:min(start+limit, len(clusters))], total, nil
+}
+
+// GetUserCountsByRole counts the dummy users that are not deleted holding each role.
+func (s *userMockedService) GetUserCountsByRole(ctx context.Context) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for _, user := range s.users {
+		if user.DeletedAt.Valid {
+			continue
+		}
+		for _, role := range user.Roles {
+			counts[role.Name]++
+		}
+	}
+	return counts, nil
+}
+
+// GetUserSummary counts the dummy users by state.
+func (s *userMockedService) GetUserSummary(ctx context.Context) (entity.UserSummary, error) {
+	var summary entity.UserSummary
+	for _, user := range s.users {
+		switch {
+		case user.DeletedAt.Valid:
+			summary.Deleted++
+			continue
+		case user.IsEnabled != nil && *user.IsEnabled:
+			summary.Enabled++
+		default:
+			summary.Disabled++
+		}
+		if user.IsAccountNonLocked == nil || !*user.IsAccountNonLocked {
+			summary.Locked++
+		}
+		summary.Total++
+	}
+	return summary, nil
+}
+
+// PurgeUser removes the dummy user if it was soft-deleted before the retention period.
+func (s *userMockedService) PurgeUser(ctx context.Context, id int64) error {
+	user, ok := s.users[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if !user.DeletedAt.Valid {
+		return service.ErrUserNotDeleted
+	}
+	if time.Since(user.DeletedAt.Time) < time.Duration(service.GetUserPurgeRetentionDays())*24*time.Hour {
+		return service.ErrUserDeletedRecently
+	}
+
+	delete(s.users, id)
+	return nil
+}
@@ -0,0 +1,67 @@
+package test_user
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+// deletedUser returns a dummy user soft-deleted by the admin at the given time.
+func deletedUser(id int64, deletedAt time.Time) entity.User {
+	deleted, deletedBy := true, int64(1)
+	return entity.User{
+		ID:        id,
+		Username:  "deleted",
+		IsDeleted: &deleted,
+		DeletedBy: &deletedBy,
+		DeletedAt: gorm.DeletedAt{Time: deletedAt, Valid: true},
+	}
+}
+
+func TestPurgeUser_Handler(t *testing.T) {
+	t.Setenv("USER_PURGE_RETENTION_DAYS", "30")
+	h := handler.NewUserHandler(NewUserMockedService(
+		getDummyUser(),
+		deletedUser(2, time.Now().Add(-31*24*time.Hour)),
+		deletedUser(3, time.Now().Add(-time.Hour)),
+	))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/users/deleted", h.GetDeletedUsers)
+	router.DELETE("/api/v1/users/:id/purge", h.PurgeUser)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{"list deleted users", "GET", "/api/v1/users/deleted", http.StatusOK},
+		{"invalid page", "GET", "/api/v1/users/deleted?page=0", http.StatusBadRequest},
+		{"invalid ID", "DELETE", "/api/v1/users/abc/purge", http.StatusBadRequest},
+		{"unknown user", "DELETE", "/api/v1/users/42/purge", http.StatusNotFound},
+		{"user not deleted", "DELETE", "/api/v1/users/1/purge", http.StatusConflict},
+		{"deleted recently", "DELETE", "/api/v1/users/3/purge", http.StatusConflict},
+		{"deleted past the retention", "DELETE", "/api/v1/users/2/purge", http.StatusOK},
+		{"already purged", "DELETE", "/api/v1/users/2/purge", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package test_user
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+// setupStatsRouter registers the stats routes with an admin, a disabled and locked user, and a deleted user.
+func setupStatsRouter() *gin.Engine {
+	user := getDummyUser()
+	user.ID = 2
+	user.Username = "user"
+	user.IsEnabled = boolPtr(false)
+	user.IsAccountNonLocked = boolPtr(false)
+	user.Roles = []entity.Role{{ID: 1, Name: "ROLE_USER"}}
+
+	deleted := deletedUser(3, time.Now())
+	deleted.Roles = []entity.Role{{ID: 1, Name: "ROLE_USER"}}
+
+	h := handler.NewUserHandler(NewUserMockedService(getDummyUser(), user, deleted))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/stats/users-by-role", h.GetUserCountsByRole)
+	router.GET("/api/v1/stats/users-summary", h.GetUserSummary)
+	return router
+}
+
+func TestGetUserCountsByRole_Handler(t *testing.T) {
+	router := setupStatsRouter()
+
+	req, _ := http.NewRequest("GET", "/api/v1/stats/users-by-role", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var body struct {
+		Data map[string]int64 `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]int64{"ROLE_ADMIN": 1, "ROLE_USER": 1}, body.Data)
+}
+
+func TestGetUserSummary_Handler(t *testing.T) {
+	router := setupStatsRouter()
+
+	req, _ := http.NewRequest("GET", "/api/v1/stats/users-summary", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var body struct {
+		Data map[string]int64 `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]int64{"total": 2, "enabled": 1, "disabled": 1, "locked": 1, "deleted": 1}, body.Data)
+}
@@ -0,0 +1,88 @@
+package test_user
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+func TestUserStatusRequest_OnlyProvidedFlagsApplied(t *testing.T) {
+	user := getDummyUser()
+
+	var req entity.UserStatusRequest
+	err := json.Unmarshal([]byte(`{"isEnabled": false}`), &req)
+	assert.NoError(t, err)
+
+	req.ApplyTo(&user)
+
+	assert.False(t, *user.IsEnabled)
+	assert.True(t, *user.IsAccountNonLocked)
+	assert.True(t, *user.IsAccountNonExpired)
+	assert.True(t, *user.IsCredentialsNonExpired)
+	assert.True(t, req.RevokesSessions())
+}
+
+func TestUserStatusRequest_EnablingDoesNotRevokeSessions(t *testing.T) {
+	req := entity.UserStatusRequest{IsEnabled: boolPtr(true), IsAccountNonLocked: boolPtr(true)}
+	assert.False(t, req.RevokesSessions())
+}
+
+func TestUpdateUserStatus_Handler(t *testing.T) {
+	h := handler.NewUserHandler(NewUserMockedService(getDummyUser()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PATCH("/api/v1/users/:id/status", h.UpdateUserStatus)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"only isEnabled", "/api/v1/users/1/status", `{"isEnabled": false}`, http.StatusOK},
+		{"no flag", "/api/v1/users/1/status", `{}`, http.StatusUnprocessableEntity},
+		{"invalid id", "/api/v1/users/abc/status", `{"isEnabled": false}`, http.StatusBadRequest},
+		{"unknown user", "/api/v1/users/99/status", `{"isEnabled": false}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("PATCH", tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestUpdateUserStatus_ResponseHasNoPassword(t *testing.T) {
+	h := handler.NewUserHandler(NewUserMockedService(getDummyUser()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PATCH("/api/v1/users/:id/status", h.UpdateUserStatus)
+
+	req, _ := http.NewRequest("PATCH", "/api/v1/users/1/status", bytes.NewBufferString(`{"isAccountNonLocked": false}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.NotContains(t, body.Data, "password")
+	assert.Equal(t, false, body.Data["isAccountNonLocked"])
+	assert.Equal(t, true, body.Data["isEnabled"])
+}
@@ -0,0 +1,62 @@
+package test_user
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/handler"
+)
+
+// TestValidationStatus checks that a body which cannot be parsed is answered with 400,
+// and a well-formed body failing the validation with 422.
+func TestValidationStatus(t *testing.T) {
+	t.Setenv("USER_METADATA_KEYS", "crmId")
+	h := handler.NewUserHandler(NewUserMockedService(getDummyUser()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/users", h.CreateUser)
+	router.POST("/auth/register", h.RegisterUser)
+	router.PATCH("/api/v1/users/:id/status", h.UpdateUserStatus)
+	router.PATCH("/api/v1/users/:id/session-limit", h.UpdateUserSessionLimit)
+	router.PATCH("/api/v1/users/:id/password", h.ResetUserPassword)
+	router.PATCH("/api/v1/users/:id/metadata", h.UpdateUserMetadata)
+	router.POST("/api/v1/users/batch-get", h.BatchGetUsers)
+
+	tests := []struct {
+		method  string
+		path    string
+		invalid string
+	}{
+		{"POST", "/api/v1/users", `{"username": "ab", "password": "short", "email": "not-an-email", "firstName": "New", "userType": "USER_ACCOUNT"}`},
+		{"POST", "/api/v1/users", `{"username": "newuser", "password": "P@ssw0rd123", "email": "new@mygmail.com", "firstName": "New", "userType": "USER_ACCOUNT", "roles": ["ROLE_UNKNOWN"]}`},
+		{"POST", "/auth/register", `{"username": "newuser", "password": "P@ssw0rd123", "email": "not-an-email", "firstName": "New"}`},
+		{"PATCH", "/api/v1/users/1/status", `{}`},
+		{"PATCH", "/api/v1/users/1/session-limit", `{"maxSessions": -1}`},
+		{"PATCH", "/api/v1/users/1/password", `{"password": "short"}`},
+		{"PATCH", "/api/v1/users/1/metadata", `{"metadata": {"notes": "anything"}}`},
+		{"POST", "/api/v1/users/batch-get", `{"ids": [0]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			for body, status := range map[string]int{
+				`{"unterminated": `: http.StatusBadRequest,
+				`[1, 2, 3]`:         http.StatusBadRequest,
+				tt.invalid:          http.StatusUnprocessableEntity,
+			} {
+				req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(body))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, status, w.Code, "%s: %s", body, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package test_validation
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/go-playground/validator.v9"
+
+	"github.com/yoanesber/go-consumer-api-with-jwt/internal/entity"
+	httputil "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/http-util"
+	validation "github.com/yoanesber/go-consumer-api-with-jwt/pkg/util/validation-util"
+)
+
+// tagged holds a field for each custom validation tag.
+type tagged struct {
+	Username string `json:"username" validate:"username"`
+	Password string `json:"password" validate:"password"`
+	RoleName string `json:"roleName" validate:"rolename"`
+	UserType string `json:"userType" validate:"usertype"`
+}
+
+// validTagged returns a value passing every custom validation tag.
+func validTagged() tagged {
+	return tagged{Username: "newuser", Password: "P@ssw0rd123", RoleName: "ROLE_USER", UserType: "USER_ACCOUNT"}
+}
+
+// failedTags returns the JSON field names of the validation errors by their tag.
+func failedTags(t *testing.T, err error) map[string]string {
+	var ve validator.ValidationErrors
+	require.ErrorAs(t, err, &ve)
+
+	tags := make(map[string]string)
+	for _, fe := range ve {
+		tags[fe.Tag()] = fe.Field()
+	}
+	return tags
+}
+
+func TestRegisterValidators_CustomTags(t *testing.T) {
+	v := validator.New()
+	require.NoError(t, validation.RegisterValidators(v))
+
+	assert.NoError(t, v.Struct(validTagged()))
+
+	// Usernames may hold any letter, not the spaces nor the control characters
+	value := validTagged()
+	value.Username = "ユーザー名"
+	assert.NoError(t, v.Struct(value))
+
+	invalid := tagged{
+		Username: "new user",
+		Password: strings.Repeat("é", 40),
+		RoleName: "admin",
+		UserType: "ADMIN_ACCOUNT",
+	}
+	assert.Equal(t, map[string]string{
+		"username": "username",
+		"password": "password",
+		"rolename": "roleName",
+		"usertype": "userType",
+	}, failedTags(t, v.Struct(invalid)))
+
+	// The password is limited in bytes, a password of 72 ASCII characters is accepted
+	value = validTagged()
+	value.Password = strings.Repeat("a", validation.MaxPasswordBytes)
+	assert.NoError(t, v.Struct(value))
+	value.Username = "new\tuser"
+	assert.Equal(t, map[string]string{"username": "username"}, failedTags(t, v.Struct(value)))
+}
+
+func TestSharedValidator_CustomTagsActive(t *testing.T) {
+	validation.ClearValidator()
+	require.True(t, validation.Init())
+
+	// The entities and the gin binding use the same validator, holding the custom tags
+	v := validation.GetValidator()
+	assert.Same(t, v, binding.Validator.Engine())
+	assert.NoError(t, v.Struct(validTagged()))
+	assert.Error(t, v.Struct(tagged{Username: "new user", RoleName: "ROLE_USER", UserType: "USER_ACCOUNT"}))
+
+	// A re-initialization registers them on the new validator as well
+	validation.ClearValidator()
+	v = validation.GetValidator()
+	assert.Same(t, v, binding.Validator.Engine())
+	assert.Error(t, v.Struct(tagged{Username: "newuser", RoleName: "ROLE_USER", UserType: "SYSTEM"}))
+}
+
+func TestSharedValidator_EntityValidate(t *testing.T) {
+	req := entity.UserCreateRequest{
+		Username:  "new user",
+		Password:  strings.Repeat("é", 40),
+		Email:     "newuser@mygmail.com",
+		Firstname: "New",
+		UserType:  "ADMIN_ACCOUNT",
+		Roles:     []string{"admin"},
+	}
+
+	err := req.Validate()
+	assert.Equal(t, map[string]string{
+		"username": "username",
+		"password": "password",
+		"rolename": "roles[0]",
+		"usertype": "userType",
+	}, failedTags(t, err))
+
+	assert.ElementsMatch(t, []map[string]string{
+		{"field": "username", "message": "username must not contain spaces nor control characters"},
+		{"field": "password", "message": "password must be at most 72 bytes"},
+		{"field": "userType", "message": "userType must be SERVICE_ACCOUNT or USER_ACCOUNT"},
+		{"field": "roles[0]", "message": "roles[0] must be a role name such as ROLE_USER"},
+	}, validation.FormatValidationErrors(err))
+
+	// The same tags apply to the users themselves, whose password holds the hash
+	user := entity.User{
+		Username:  "new user",
+		Password:  "$2a$10$" + strings.Repeat("x", 53),
+		Email:     "newuser@mygmail.com",
+		Firstname: "New",
+		UserType:  "USER_ACCOUNT",
+		Roles:     []entity.Role{{ID: 1, Name: "ROLE_USER"}},
+	}
+	assert.Equal(t, map[string]string{"username": "username"}, failedTags(t, user.Validate()))
+}
+
+func TestSharedValidator_HandlerBinding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/users", func(c *gin.Context) {
+		var req entity.UserCreateRequest
+		if err := httputil.BindJSON(c, &req); err != nil {
+			httputil.BadRequest(c, "Invalid request body", err.Error())
+			return
+		}
+		if err := req.Validate(); err != nil {
+			httputil.UnprocessableEntityMap(c, "Failed to create user", validation.FormatValidationErrors(err))
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"valid body", `{"username": "newuser", "password": "P@ssw0rd123", "email": "newuser@mygmail.com", "firstName": "New", "userType": "USER_ACCOUNT"}`, http.StatusCreated},
+		{"custom tag failing", `{"username": "new user", "password": "P@ssw0rd123", "email": "newuser@mygmail.com", "firstName": "New", "userType": "SYSTEM"}`, http.StatusUnprocessableEntity},
+		{"malformed body", `{"username": `, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/v1/users", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// The binding leaves the validation to the Validate methods, their errors are answered with 422
+			assert.Equal(t, tt.want, w.Code, w.Body.String())
+		})
+	}
+}